@@ -0,0 +1,356 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gliwka/SAMLurai/internal/metadata"
+	"github.com/gliwka/SAMLurai/internal/output"
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/gliwka/SAMLurai/internal/sp"
+	"github.com/spf13/cobra"
+)
+
+var (
+	attrQueryIssuer        string
+	attrQueryNameID        string
+	attrQueryNameIDFormat  string
+	attrQueryAttributes    []string
+	attrQueryIdPMetadata   string
+	attrQueryIdPEntityID   string
+	attrQueryDestination   string
+	attrQueryIdPCert       string
+	attrQuerySignKey       string
+	attrQuerySignCert      string
+	attrQuerySigAlg        string
+	attrQueryTLSClientAuth bool
+	attrQueryTimeout       time.Duration
+	attrQueryDryRun        bool
+	attrQueryShowXML       bool
+)
+
+// soapAction is the SOAPAction header the SAML SOAP binding recommends
+const soapAction = "http://www.oasis-open.org/committees/security"
+
+// maxAttrQueryBodySize bounds how much of the attribute authority's answer
+// is read
+const maxAttrQueryBodySize = 1 << 20
+
+var attrQueryCmd = &cobra.Command{
+	Use:   "attrquery",
+	Short: "Send an AttributeQuery to an attribute authority and inspect the Response",
+	Long: `Build an AttributeQuery for a subject, send it to the AttributeService of
+an IdP's attribute authority with the SOAP binding, and inspect the Response
+it returns: its signatures are verified, an encrypted assertion is decrypted
+and the attributes are printed.
+
+The attribute authority is configured with --idp-metadata (a file or URL),
+whose AttributeAuthorityDescriptor provides the SOAP AttributeService and
+signing certificates, or with --destination and --idp-cert.
+
+The query is signed with --sign-key and --sign-cert, which most attribute
+authorities require; the key also decrypts encrypted assertions. Some, such
+as the Shibboleth IdP, authenticate the requester by TLS client certificate
+instead, which --tls-client-auth presents.
+
+--attribute names an attribute to request; without it, the attribute
+authority returns every attribute the requester may see. name=value asks
+for the attribute only if the subject has that value.
+
+Examples:
+  # Query all attributes of a user
+  samlurai attrquery --idp-metadata https://idp.example.com/metadata \
+    --issuer https://sp.example.com --nameid jdoe --nameid-format persistent \
+    --sign-key sp-key.pem --sign-cert sp.pem
+
+  # Request specific attributes, authenticating with the TLS client certificate
+  samlurai attrquery --destination https://idp.example.com/idp/profile/SAML2/SOAP/AttributeQuery \
+    --idp-cert idp.pem --issuer https://sp.example.com --nameid jdoe@example.com \
+    --attribute urn:oid:0.9.2342.19200300.100.1.3 --attribute urn:oid:1.3.6.1.4.1.5923.1.1.1.7 \
+    --sign-key sp-key.pem --sign-cert sp.pem --tls-client-auth
+
+  # Print the SOAP request without sending it
+  samlurai attrquery --destination https://aa.example.com/soap --issuer https://sp.example.com \
+    --nameid jdoe --dry-run`,
+	RunE: runAttrQuery,
+}
+
+func init() {
+	rootCmd.AddCommand(attrQueryCmd)
+
+	flags := attrQueryCmd.Flags()
+	flags.StringVar(&attrQueryIssuer, "issuer", "", "Entity ID of the requester (required)")
+	flags.StringVar(&attrQueryNameID, "nameid", "", "NameID of the subject whose attributes are queried (required)")
+	flags.StringVar(&attrQueryNameIDFormat, "nameid-format", "", "NameID format: email, persistent, transient, unspecified, or a URI")
+	flags.StringArrayVar(&attrQueryAttributes, "attribute", nil, "Attribute to request, as name or name=value (repeatable; default: all)")
+	flags.StringVar(&attrQueryIdPMetadata, "idp-metadata", "", "Metadata of the attribute authority (file or URL)")
+	flags.StringVar(&attrQueryIdPEntityID, "idp-entity-id", "", "Entity ID of the attribute authority, to select it from aggregate metadata and check the Issuer")
+	flags.StringVar(&attrQueryDestination, "destination", "", "AttributeService URL (default: the SOAP AttributeService in --idp-metadata)")
+	flags.StringVar(&attrQueryIdPCert, "idp-cert", "", "Signing certificate of the attribute authority (PEM or DER) (default: from --idp-metadata)")
+	flags.StringVar(&attrQuerySignKey, "sign-key", "", "Private key to sign the query and decrypt with (PEM)")
+	flags.StringVar(&attrQuerySignCert, "sign-cert", "", "Certificate of the key")
+	flags.StringVar(&attrQuerySigAlg, "sig-alg", "", "Signature algorithm, e.g. rsa-sha256 (default: from key type)")
+	flags.BoolVar(&attrQueryTLSClientAuth, "tls-client-auth", false, "Present the signing certificate as TLS client certificate")
+	flags.DurationVar(&attrQueryTimeout, "timeout", 30*time.Second, "Timeout for the request")
+	flags.BoolVar(&attrQueryDryRun, "dry-run", false, "Print the SOAP request instead of sending it")
+	flags.BoolVar(&attrQueryShowXML, "show-xml", false, "Also print the XML of the Response")
+}
+
+// attrQueryOutput is the JSON output of attrquery
+type attrQueryOutput struct {
+	Destination string       `json:"destination"`
+	QueryID     string       `json:"query_id"`
+	Request     string       `json:"request"`
+	Status      int          `json:"status,omitempty"`
+	StatusText  string       `json:"status_text,omitempty"`
+	Response    *sp.Received `json:"response,omitempty"`
+}
+
+func runAttrQuery(cmd *cobra.Command, args []string) error {
+	if attrQueryIssuer == "" {
+		return errors.New("--issuer is required")
+	}
+	if attrQueryNameID == "" {
+		return errors.New("--nameid is required")
+	}
+	if attrQueryTLSClientAuth && attrQuerySignKey == "" {
+		return errors.New("--tls-client-auth requires --sign-key")
+	}
+
+	opts := saml.AttributeQueryOptions{
+		Issuer: attrQueryIssuer,
+		NameID: attrQueryNameID,
+	}
+	var err error
+	if attrQueryNameIDFormat != "" {
+		if opts.NameIDFormat, err = metadata.ResolveNameIDFormat(attrQueryNameIDFormat); err != nil {
+			return err
+		}
+	}
+	if opts.Attributes, err = parseQueryAttributes(attrQueryAttributes); err != nil {
+		return err
+	}
+	idpEntityID, certs, err := configureAttributeAuthority(cmd, &opts)
+	if err != nil {
+		return err
+	}
+
+	var key crypto.Signer
+	var cert *x509.Certificate
+	if attrQuerySignKey != "" {
+		if key, cert, err = loadSigningKeyPair(attrQuerySignKey, attrQuerySignCert, attrQueryIssuer); err != nil {
+			return err
+		}
+	} else if attrQuerySignCert != "" {
+		return errors.New("--sign-cert requires --sign-key")
+	}
+
+	doc, err := saml.BuildAttributeQuery(opts, time.Now())
+	if err != nil {
+		return err
+	}
+	if key != nil {
+		sigAlg, err := resolveSigAlgFor(attrQuerySigAlg, key)
+		if err != nil {
+			return err
+		}
+		if err := saml.SignEnveloped(doc.Root(), key, cert, sigAlg); err != nil {
+			return fmt.Errorf("failed to sign AttributeQuery: %w", err)
+		}
+	} else if !attrQueryDryRun {
+		notef(cmd, "Sending an unsigned AttributeQuery; most attribute authorities require --sign-key\n")
+	}
+	query, err := doc.WriteToBytes()
+	if err != nil {
+		return fmt.Errorf("failed to serialize AttributeQuery: %w", err)
+	}
+	envelope, err := saml.WrapSOAP(query)
+	if err != nil {
+		return err
+	}
+
+	result := attrQueryOutput{
+		Destination: opts.Destination,
+		QueryID:     doc.Root().SelectAttrValue("ID", ""),
+		Request:     string(envelope),
+	}
+	if attrQueryDryRun {
+		return printAttrQuery(cmd, result)
+	}
+
+	var clientCert *tls.Certificate
+	if attrQueryTLSClientAuth {
+		clientCert = &tls.Certificate{Certificate: [][]byte{cert.Raw}, PrivateKey: key, Leaf: cert}
+	}
+	body, err := postSOAP(cmd, &result, envelope, clientCert)
+	if err != nil {
+		return err
+	}
+	response, err := saml.UnwrapSOAP(body)
+	if err != nil {
+		if result.Status != http.StatusOK {
+			return fmt.Errorf("attribute authority answered HTTP %d %s: %w", result.Status, result.StatusText, err)
+		}
+		return withExitCode(ExitParse, err)
+	}
+
+	var decryptor *saml.Decryptor
+	if decrypter, ok := key.(crypto.Decrypter); ok {
+		decryptor = saml.NewDecryptorWithKey(decrypter)
+	}
+	received := sp.Inspect(response, certs, decryptor)
+	received.Warnings = attrQueryWarnings(received.Info, result.QueryID, idpEntityID)
+	result.Response = &received
+	if err := printAttrQuery(cmd, result); err != nil {
+		return err
+	}
+	if received.Verdict == saml.VerdictSigFail {
+		return withExitCode(ExitSignature, fmt.Errorf("signature verification failed: %s", received.VerifyError))
+	}
+	return nil
+}
+
+// parseQueryAttributes parses --attribute values, names that may be
+// followed by =value, collecting the values of repeated names in order
+func parseQueryAttributes(values []string) ([]saml.Attribute, error) {
+	var attrs []saml.Attribute
+	index := map[string]int{}
+	for _, value := range values {
+		name, v, hasValue := strings.Cut(value, "=")
+		if name == "" {
+			return nil, fmt.Errorf("invalid attribute %q, expected name or name=value", value)
+		}
+		i, seen := index[name]
+		if !seen {
+			i = len(attrs)
+			index[name] = i
+			attrs = append(attrs, saml.Attribute{Name: name})
+		}
+		if hasValue {
+			attrs[i].Values = append(attrs[i].Values, v)
+		}
+	}
+	return attrs, nil
+}
+
+// configureAttributeAuthority sets the destination of opts from
+// --idp-metadata, with --destination and --idp-cert taking precedence, and
+// returns the entity ID and signing certificates of the attribute authority
+func configureAttributeAuthority(cmd *cobra.Command, opts *saml.AttributeQueryOptions) (string, []*x509.Certificate, error) {
+	entityID := attrQueryIdPEntityID
+	opts.Destination = attrQueryDestination
+	var certs []*x509.Certificate
+	if attrQueryIdPMetadata != "" {
+		doc, err := metadata.Load(cmd.Context(), attrQueryIdPMetadata, metadata.Options{})
+		if err != nil {
+			return "", nil, err
+		}
+		idpInfo, err := metadata.ParseIdP(doc.Data, attrQueryIdPEntityID)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid IdP metadata: %w", err)
+		}
+		if entityID == "" {
+			entityID = idpInfo.EntityID
+		}
+		if opts.Destination == "" {
+			opts.Destination = idpInfo.AttributeServices[metadata.BindingSOAP]
+		}
+		if opts.Destination == "" {
+			return "", nil, errors.New("IdP metadata has no SOAP AttributeService")
+		}
+		certs = idpInfo.SigningCerts
+	}
+	if opts.Destination == "" {
+		return "", nil, errors.New("--idp-metadata or --destination is required")
+	}
+	if attrQueryIdPCert != "" {
+		cert, err := saml.LoadCertificate(attrQueryIdPCert)
+		if err != nil {
+			return "", nil, fmt.Errorf("IdP certificate: %w", err)
+		}
+		certs = []*x509.Certificate{cert}
+	}
+	return entityID, certs, nil
+}
+
+// postSOAP sends the envelope to the destination with the SOAP binding and
+// returns the body of the answer
+func postSOAP(cmd *cobra.Command, result *attrQueryOutput, envelope []byte, clientCert *tls.Certificate) ([]byte, error) {
+	req, err := http.NewRequestWithContext(cmd.Context(), http.MethodPost, result.Destination, bytes.NewReader(envelope))
+	if err != nil {
+		return nil, fmt.Errorf("invalid destination URL: %w", err)
+	}
+	req.Header.Set("Content-Type", "text/xml; charset=utf-8")
+	req.Header.Set("SOAPAction", soapAction)
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if clientCert != nil {
+		transport.TLSClientConfig = &tls.Config{Certificates: []tls.Certificate{*clientCert}}
+	}
+	client := &http.Client{Timeout: attrQueryTimeout, Transport: transport}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to post to %s: %w", result.Destination, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxAttrQueryBodySize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the attribute authority's answer: %w", err)
+	}
+	result.Status = resp.StatusCode
+	result.StatusText = http.StatusText(resp.StatusCode)
+	return body, nil
+}
+
+// attrQueryWarnings checks a Response to an AttributeQuery: its validity
+// and audience, and whether it answers the query and comes from the
+// attribute authority
+func attrQueryWarnings(info *saml.SAMLInfo, queryID, entityID string) []string {
+	if info == nil {
+		return nil
+	}
+	warnings := saml.WarningsWithOptions(info, saml.CheckOptions{
+		Now:              time.Now(),
+		ExpectedAudience: attrQueryIssuer,
+		URLs:             saml.DefaultURLNormalization(),
+	})
+	if info.InResponseTo != queryID {
+		warnings = append(warnings, fmt.Sprintf("InResponseTo %q does not match the AttributeQuery %s", info.InResponseTo, queryID))
+	}
+	if entityID != "" && info.Issuer != "" && info.Issuer != entityID {
+		warnings = append(warnings, fmt.Sprintf("issuer %s is not the attribute authority %s", info.Issuer, entityID))
+	}
+	return warnings
+}
+
+// printAttrQuery prints the request, or the Response it was answered with
+func printAttrQuery(cmd *cobra.Command, result attrQueryOutput) error {
+	out := cmd.OutOrStdout()
+	formatter := output.NewFormatter(outputFormat)
+	if formatter.IsJSON() {
+		formatted, err := formatter.FormatJSON(result)
+		if err != nil {
+			return fmt.Errorf("failed to format output: %w", err)
+		}
+		fmt.Fprint(out, formatted)
+		return nil
+	}
+
+	if result.Response == nil {
+		fmt.Fprintf(out, "Would post to %s:\n\n%s\n", result.Destination, result.Request)
+		return nil
+	}
+	fmt.Fprintf(out, "POST %s\n", result.Destination)
+	fmt.Fprintf(out, "HTTP %d %s\n\n", result.Status, result.StatusText)
+	printInspected(cmd, formatter, *result.Response, attrQueryShowXML)
+	return nil
+}
@@ -0,0 +1,208 @@
+package cmd
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func resetAttrQueryFlags() {
+	attrQueryIssuer = ""
+	attrQueryNameID = ""
+	attrQueryNameIDFormat = ""
+	attrQueryAttributes = nil
+	attrQueryIdPMetadata = ""
+	attrQueryIdPEntityID = ""
+	attrQueryDestination = ""
+	attrQueryIdPCert = ""
+	attrQuerySignKey = ""
+	attrQuerySignCert = ""
+	attrQuerySigAlg = ""
+	attrQueryTLSClientAuth = false
+	attrQueryTimeout = 30 * time.Second
+	attrQueryDryRun = false
+	attrQueryShowXML = false
+	outputFormat = "pretty"
+}
+
+// writeAttrQueryKeyPair writes a generated key and certificate as PEM and
+// returns their paths
+func writeAttrQueryKeyPair(t *testing.T, commonName string) (string, string) {
+	t.Helper()
+	key, cert, err := saml.NewSelfSignedKey(commonName)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600))
+	certPath := filepath.Join(dir, "cert.pem")
+	require.NoError(t, os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}), 0644))
+	return keyPath, certPath
+}
+
+// attributeAuthority answers AttributeQueries with a signed Response for
+// their subject, and records the queries it receives
+func attributeAuthority(t *testing.T) (*httptest.Server, string, *[]*saml.SAMLInfo) {
+	t.Helper()
+	key, cert, err := saml.NewSelfSignedKey("idp.example.com")
+	require.NoError(t, err)
+	certPath := filepath.Join(t.TempDir(), "idp.pem")
+	require.NoError(t, os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}), 0644))
+
+	var queries []*saml.SAMLInfo
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		assert.Equal(t, soapAction, r.Header.Get("SOAPAction"))
+		query, err := saml.UnwrapSOAP(body)
+		require.NoError(t, err)
+		info, err := saml.NewParser().Parse(query)
+		require.NoError(t, err)
+		queries = append(queries, info)
+
+		doc, err := saml.BuildResponse(saml.ResponseOptions{
+			Issuer:       "https://idp.example.com",
+			Destination:  info.Issuer,
+			InResponseTo: info.ID,
+			Audience:     info.Issuer,
+			NameID:       info.Subject.NameID,
+			Attributes:   []saml.Attribute{{Name: "mail", Values: []string{"alice@example.com"}}},
+		}, time.Now())
+		require.NoError(t, err)
+		require.NoError(t, saml.SignEnveloped(doc.Root(), key, cert, saml.SigAlgRSASHA256))
+		response, err := doc.WriteToBytes()
+		require.NoError(t, err)
+		envelope, err := saml.WrapSOAP(response)
+		require.NoError(t, err)
+		w.Header().Set("Content-Type", "text/xml")
+		_, _ = w.Write(envelope)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, certPath, &queries
+}
+
+func TestAttrQueryCmd(t *testing.T) {
+	defer resetAttrQueryFlags()
+	srv, idpCert, queries := attributeAuthority(t)
+	keyPath, certPath := writeAttrQueryKeyPair(t, "sp.example.com")
+
+	output, err := executeCommand(rootCmd, "attrquery", "--destination", srv.URL, "--idp-cert", idpCert,
+		"--issuer", "https://sp.example.com", "--nameid", "alice", "--nameid-format", "persistent",
+		"--attribute", "mail", "--attribute", "eduPersonAffiliation=staff",
+		"--sign-key", keyPath, "--sign-cert", certPath)
+	require.NoError(t, err)
+	assert.Contains(t, output, "POST "+srv.URL+"\nHTTP 200 OK\n")
+	assert.Contains(t, output, "✅ Signature verified")
+	assert.Contains(t, output, "alice@example.com")
+	assert.NotContains(t, output, "⚠️")
+
+	require.Len(t, *queries, 1)
+	query := (*queries)[0]
+	assert.Equal(t, "AttributeQuery", query.Type)
+	assert.Equal(t, "https://sp.example.com", query.Issuer)
+	assert.Equal(t, "urn:oasis:names:tc:SAML:2.0:nameid-format:persistent", query.Subject.NameIDFormat)
+	require.Len(t, query.RequestedAttributes, 2)
+	require.Len(t, query.Attributes, 1)
+	assert.Equal(t, []string{"staff"}, query.Attributes[0].Values)
+	require.NotNil(t, query.Signature)
+}
+
+func TestAttrQueryCmd_WrongCertificate(t *testing.T) {
+	defer resetAttrQueryFlags()
+	srv, _, _ := attributeAuthority(t)
+	_, otherCert := writeAttrQueryKeyPair(t, "other.example.com")
+
+	output, err := executeCommand(rootCmd, "attrquery", "--destination", srv.URL, "--idp-cert", otherCert,
+		"--issuer", "https://sp.example.com", "--nameid", "alice", "-o", "json")
+	require.Error(t, err)
+	assert.Equal(t, ExitSignature, ExitCode(err))
+
+	var result attrQueryOutput
+	// The JSON follows the note that the query is unsigned
+	require.NoError(t, json.NewDecoder(strings.NewReader(output[strings.Index(output, "{"):])).Decode(&result))
+	require.NotNil(t, result.Response)
+	assert.Equal(t, saml.VerdictSigFail, result.Response.Verdict)
+}
+
+func TestAttrQueryCmd_Fault(t *testing.T) {
+	defer resetAttrQueryFlags()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = io.WriteString(w, `<soap11:Envelope xmlns:soap11="http://schemas.xmlsoap.org/soap/envelope/"><soap11:Body><soap11:Fault><faultcode>soap11:Client</faultcode><faultstring>unknown requester</faultstring></soap11:Fault></soap11:Body></soap11:Envelope>`)
+	}))
+	defer srv.Close()
+
+	_, err := executeCommand(rootCmd, "attrquery", "--destination", srv.URL,
+		"--issuer", "https://sp.example.com", "--nameid", "alice")
+	assert.ErrorContains(t, err, "HTTP 500 Internal Server Error: SOAP fault soap11:Client: unknown requester")
+}
+
+func TestAttrQueryCmd_DryRun(t *testing.T) {
+	defer resetAttrQueryFlags()
+
+	output, err := executeCommand(rootCmd, "attrquery", "--destination", "https://aa.example.com/soap",
+		"--issuer", "https://sp.example.com", "--nameid", "alice", "--dry-run")
+	require.NoError(t, err)
+	assert.Contains(t, output, "Would post to https://aa.example.com/soap:")
+	assert.Contains(t, output, `<soap11:Envelope xmlns:soap11="http://schemas.xmlsoap.org/soap/envelope/">`)
+	assert.Contains(t, output, "<saml:NameID>alice</saml:NameID>")
+}
+
+func TestAttrQueryCmd_Metadata(t *testing.T) {
+	defer resetAttrQueryFlags()
+	_, cert, err := saml.NewSelfSignedKey("idp.example.com")
+	require.NoError(t, err)
+	md := `<md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" xmlns:ds="http://www.w3.org/2000/09/xmldsig#" entityID="https://idp.example.com">
+  <md:AttributeAuthorityDescriptor protocolSupportEnumeration="urn:oasis:names:tc:SAML:2.0:protocol">
+    <md:KeyDescriptor use="signing"><ds:KeyInfo><ds:X509Data><ds:X509Certificate>` + base64.StdEncoding.EncodeToString(cert.Raw) + `</ds:X509Certificate></ds:X509Data></ds:KeyInfo></md:KeyDescriptor>
+    <md:AttributeService Binding="urn:oasis:names:tc:SAML:2.0:bindings:SOAP" Location="https://idp.example.com/aa"/>
+  </md:AttributeAuthorityDescriptor>
+</md:EntityDescriptor>`
+
+	output, err := executeCommand(rootCmd, "attrquery", "--idp-metadata", createTempFile(t, md),
+		"--issuer", "https://sp.example.com", "--nameid", "alice", "--dry-run")
+	require.NoError(t, err)
+	assert.Contains(t, output, "Would post to https://idp.example.com/aa:")
+}
+
+func TestAttrQueryCmd_Usage(t *testing.T) {
+	defer resetAttrQueryFlags()
+
+	_, err := executeCommand(rootCmd, "attrquery", "--nameid", "alice", "--destination", "https://aa.example.com")
+	assert.ErrorContains(t, err, "--issuer is required")
+
+	resetAttrQueryFlags()
+	_, err = executeCommand(rootCmd, "attrquery", "--issuer", "https://sp.example.com", "--nameid", "alice")
+	assert.ErrorContains(t, err, "--idp-metadata or --destination is required")
+
+	resetAttrQueryFlags()
+	_, err = executeCommand(rootCmd, "attrquery", "--issuer", "https://sp.example.com", "--nameid", "alice",
+		"--destination", "https://aa.example.com", "--tls-client-auth")
+	assert.ErrorContains(t, err, "--tls-client-auth requires --sign-key")
+}
+
+func TestParseQueryAttributes(t *testing.T) {
+	attrs, err := parseQueryAttributes([]string{"mail", "affiliation=staff", "affiliation=member"})
+	require.NoError(t, err)
+	assert.Equal(t, []saml.Attribute{
+		{Name: "mail"},
+		{Name: "affiliation", Values: []string{"staff", "member"}},
+	}, attrs)
+
+	_, err = parseQueryAttributes([]string{"=x"})
+	assert.Error(t, err)
+}
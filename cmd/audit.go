@@ -0,0 +1,394 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gliwka/SAMLurai/internal/inspect"
+	"github.com/gliwka/SAMLurai/internal/metadata"
+	"github.com/gliwka/SAMLurai/internal/output"
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/spf13/cobra"
+)
+
+var (
+	auditFile         string
+	auditKey          string
+	auditMaxLifetime  time.Duration
+	auditMinSeverity  string
+	auditMetadata     string
+	auditMetadataCert string
+)
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Check SAML messages for security issues",
+	Long: `Check SAML messages for security issues such as XML Signature Wrapping
+(XSW) attacks, weak cryptography and missing protections.
+
+The input can be a SAML XML file, base64-encoded SAML, a HAR file or a
+SAML-tracer export; every message in a HAR file is audited. Encrypted
+assertions are decrypted first if -k is given.
+
+The following checks are performed:
+  - duplicate-id: several elements share an ID
+  - multiple-assertions: more than one Assertion element
+  - unsigned-assertion: the signed element does not enclose the assertion
+    a service provider would consume
+  - dangling-reference: a signature references an ID that does not exist
+  - detached-signature: a signature covers an element that does not enclose it
+  - nameid-comment: an XML comment splits a NameID value
+  - weak-algorithm: SHA-1 or MD5 signatures and digests, RSA-1.5 key transport
+  - short-key: signing certificates with RSA keys below 2048 bits
+  - certificate-expiry: expired certificates or ones expiring within 30 days
+  - missing-signature: unsigned Response or Assertion
+  - long-lifetime: assertions valid for longer than --max-lifetime
+  - missing-audience: assertions without an AudienceRestriction
+  - unsigned-redirect: HTTP-Redirect binding messages without a signature
+  - dtd: DOCTYPE or entity declarations, as used in XXE attacks
+  - certificate-mismatch: with --metadata, a signature certificate that
+    matches no signing KeyDescriptor in the IdP metadata
+  - attribute-scope: with --metadata, a scoped attribute such as
+    eduPersonPrincipalName whose scope is not among the shibmd:Scope
+    extensions of the IdP metadata (scope injection)
+  - replay: in a HAR file, a Response with the payload or assertion ID of
+    one delivered earlier
+  - expired: in a HAR file, a message whose conditions had already expired
+    when it was sent
+
+Plugins with the audit capability add their own checks, with names
+prefixed by the plugin name; see samlurai plugins.
+
+--metadata accepts a file or an http(s) URL. Fetched metadata is cached
+according to its cacheDuration and validUntil, expired metadata is
+rejected, and a metadata signature is verified if present (against
+--metadata-cert if given, which then makes the signature mandatory).
+Without --metadata-cert a signature can only be checked against its own
+certificate, which shows the metadata is intact but not who signed it;
+this is noted on stderr. For aggregates such as federation feeds, the
+entity matching each message's Issuer is used.
+
+Each finding has a severity of high, medium or low. The command exits with
+an error if any findings at or above --min-severity are reported: exit code
+3 for a certificate-mismatch, else 4 for an expired message, else 6.
+
+Examples:
+  # Audit a captured response
+  samlurai audit -f response.xml
+
+  # Audit all messages in a HAR file
+  samlurai audit -f session.har -k sp-key.pem
+
+  # Check the signing certificate against the IdP metadata
+  samlurai audit -f response.xml --metadata idp-metadata.xml
+
+  # ... or against a federation feed, verifying the feed's signature
+  samlurai audit -f session.har --metadata https://mdq.example.org/feed.xml --metadata-cert federation.pem
+
+  # Only report high severity findings, e.g. in CI
+  samlurai audit -f response.xml --min-severity high
+
+  # Machine-readable findings: an array with one object per message, as
+  # the summary by severity goes to stderr
+  samlurai audit -f response.xml -o json`,
+	RunE: runAudit,
+}
+
+func init() {
+	rootCmd.AddCommand(auditCmd)
+
+	auditCmd.Flags().StringVarP(&auditFile, "file", "f", "", "Read SAML from file (supports XML, base64, or HAR files)")
+	auditCmd.Flags().StringVarP(&auditKey, "key", "k", "", "Path to private key for decryption (PEM format)")
+	auditCmd.Flags().DurationVar(&auditMaxLifetime, "max-lifetime", time.Hour, "Longest acceptable assertion validity window (0 to disable)")
+	auditCmd.Flags().StringVar(&auditMinSeverity, "min-severity", saml.SeverityLow, "Only report findings at or above this severity: low, medium or high")
+	auditCmd.Flags().StringVar(&auditMetadata, "metadata", "", "IdP metadata file or URL whose signing certificates signature certificates must match")
+	auditCmd.Flags().StringVar(&auditMetadataCert, "metadata-cert", "", "Certificate (PEM) that must have signed the metadata")
+}
+
+// severityRank orders severities from least to most severe
+var severityRank = map[string]int{
+	saml.SeverityLow:    1,
+	saml.SeverityMedium: 2,
+	saml.SeverityHigh:   3,
+}
+
+// auditResult holds the findings for a single message
+type auditResult struct {
+	Index    int                 `json:"index"`
+	Type     string              `json:"type"`
+	URL      string              `json:"url,omitempty"`
+	Error    string              `json:"error,omitempty"`
+	Findings []saml.AuditFinding `json:"findings"`
+}
+
+// auditReport holds the results of an audit, and the number of findings
+// by severity
+type auditReport struct {
+	Messages []auditResult
+	Summary  map[string]int
+}
+
+func runAudit(cmd *cobra.Command, args []string) error {
+	minRank, ok := severityRank[auditMinSeverity]
+	if !ok {
+		return fmt.Errorf("invalid severity %q: must be low, medium or high", auditMinSeverity)
+	}
+
+	var trust *metadataTrust
+	if auditMetadata != "" {
+		var err error
+		if trust, err = loadMetadataTrust(cmd, auditMetadata, auditMetadataCert); err != nil {
+			return err
+		}
+	}
+
+	input, err := getInspectInput(cmd, auditFile)
+	if err != nil {
+		return err
+	}
+
+	result, err := inspect.Run(cmd.Context(), inspect.Request{
+		Input:    input,
+		Filename: auditFile,
+		KeyPath:  auditKey,
+	})
+	if err != nil {
+		return err
+	}
+
+	report := auditReport{
+		Messages: []auditResult{},
+		Summary:  map[string]int{saml.SeverityHigh: 0, saml.SeverityMedium: 0, saml.SeverityLow: 0},
+	}
+	issues := 0
+	for _, msg := range result.Messages {
+		r := auditResult{Index: msg.Index(), Type: msg.Type(), Findings: []saml.AuditFinding{}}
+
+		opts := saml.DefaultAuditOptions()
+		opts.MaxLifetime = auditMaxLifetime
+		if trust != nil && bytes.Contains(msg.XML, []byte("X509Certificate")) {
+			opts.TrustedCertificates = trust.signingCerts(cmd, messageIssuer(msg.Info))
+		}
+		if trust != nil {
+			opts.Scopes = trust.permittedScopes(messageIssuer(msg.Info))
+		}
+		if msg.Extracted != nil {
+			r.URL = msg.Extracted.URL
+		}
+		opts.RedirectQuery = redirectQuery(msg)
+
+		findings, err := saml.Audit(msg.XML, opts)
+		if err != nil {
+			r.Error = err.Error()
+		}
+		if msg.Replay != nil {
+			findings = append(findings, saml.AuditFinding{Check: saml.CheckReplay, Severity: saml.SeverityHigh, Message: msg.Replay.String()})
+		}
+		if err == nil {
+			pluginFindings, err := auditWithPlugins(cmd.Context(), msg.XML)
+			if err != nil {
+				return err
+			}
+			findings = append(findings, pluginFindings...)
+		}
+		if msg.ExpiredWhenSent() {
+			findings = append(findings, saml.AuditFinding{Check: saml.CheckExpired, Severity: saml.SeverityMedium, Message: "the message or its assertion had already expired when it was sent"})
+		}
+		for _, f := range findings {
+			if severityRank[f.Severity] >= minRank {
+				r.Findings = append(r.Findings, f)
+				report.Summary[f.Severity]++
+			}
+		}
+		issues += len(r.Findings)
+		report.Messages = append(report.Messages, r)
+	}
+
+	if output.NewFormatter(outputFormat).IsJSON() {
+		// stdout holds only the results, as it always has; the summary
+		// is for whoever watches stderr
+		formatted, err := output.NewFormatter(outputFormat).FormatJSON(report.Messages)
+		if err != nil {
+			return fmt.Errorf("failed to format output: %w", err)
+		}
+		fmt.Fprint(cmd.OutOrStdout(), formatted)
+		if summary := auditSummary(report); summary != "" {
+			notef(cmd, "%s\n", summary)
+		}
+	} else {
+		printAuditResults(cmd, report, result.IsHAR)
+	}
+
+	if issues > 0 {
+		return withExitCode(auditExitCode(report), fmt.Errorf("found %d issue(s)", issues))
+	}
+	return nil
+}
+
+// redirectQuery returns the query string of a HAR message sent with the
+// HTTP-Redirect binding, which carries its signature, or nil
+func redirectQuery(msg inspect.Message) url.Values {
+	if msg.Extracted == nil || (msg.Extracted.Source != "request-query" && msg.Extracted.Source != "response-location") {
+		return nil
+	}
+	u, err := url.Parse(msg.Extracted.URL)
+	if err != nil {
+		return nil
+	}
+	return u.Query()
+}
+
+// auditExitCode returns the exit code for the findings of an audit:
+// ExitSignature if a signature certificate is not trusted, else
+// ExitExpired if a message had expired when sent, else ExitFindings
+func auditExitCode(report auditReport) int {
+	code := ExitFindings
+	for _, r := range report.Messages {
+		for _, f := range r.Findings {
+			switch {
+			case f.Check == saml.CheckCertMismatch:
+				return ExitSignature
+			case f.Check == saml.CheckExpired:
+				code = ExitExpired
+			}
+		}
+	}
+	return code
+}
+
+func printAuditResults(cmd *cobra.Command, report auditReport, isHAR bool) {
+	w := cmd.OutOrStdout()
+	results := report.Messages
+	if isHAR && len(results) == 0 {
+		fmt.Fprintln(w, "No SAML assertions found in the HAR file.")
+		return
+	}
+
+	for i, r := range results {
+		if isHAR {
+			fmt.Fprintf(w, "[%d/%d] %s from %s\n", i+1, len(results), r.Type, truncateURL(r.URL, 70))
+		}
+		switch {
+		case r.Error != "":
+			fmt.Fprintf(w, "⚠️  Skipped: %s\n", r.Error)
+		case len(r.Findings) == 0:
+			fmt.Fprintf(w, "✓ %s: no issues found\n", r.Type)
+		}
+		for _, f := range r.Findings {
+			fmt.Fprintf(w, "[%s] %s: %s\n", strings.ToUpper(f.Severity), f.Check, f.Message)
+		}
+		if isHAR {
+			fmt.Fprintln(w)
+		}
+	}
+
+	if summary := auditSummary(report); summary != "" {
+		fmt.Fprintln(w, summary)
+	}
+}
+
+// auditSummary counts the findings of report by severity, or returns ""
+// if there are none
+func auditSummary(report auditReport) string {
+	s := report.Summary
+	if s[saml.SeverityHigh]+s[saml.SeverityMedium]+s[saml.SeverityLow] == 0 {
+		return ""
+	}
+	return fmt.Sprintf("Summary: %d high, %d medium, %d low", s[saml.SeverityHigh], s[saml.SeverityMedium], s[saml.SeverityLow])
+}
+
+// metadataTrust resolves the signing certificates of the metadata entity
+// that issued a message
+type metadataTrust struct {
+	data   []byte
+	certs  map[string][]saml.ExtractedCertificate
+	scopes map[string][]saml.Scope
+}
+
+// loadMetadataTrust loads metadata from a file or URL, optionally requiring
+// a signature by the certificate at certPath
+func loadMetadataTrust(cmd *cobra.Command, source, certPath string) (*metadataTrust, error) {
+	var opts metadata.Options
+	if certPath != "" {
+		cert, err := saml.LoadCertificate(certPath)
+		if err != nil {
+			return nil, err
+		}
+		opts.SigningCerts = []*x509.Certificate{cert}
+	}
+	doc, err := metadata.Load(cmd.Context(), source, opts)
+	if err != nil {
+		return nil, err
+	}
+	if doc.SelfSigned {
+		notef(cmd, "⚠️  The metadata signature was only checked against its own certificate; pass --metadata-cert to verify who signed it\n")
+	}
+
+	trust := &metadataTrust{
+		data:   doc.Data,
+		certs:  map[string][]saml.ExtractedCertificate{},
+		scopes: map[string][]saml.Scope{},
+	}
+	// Report unusable metadata up front unless entities are picked per issuer
+	entity, err := saml.SelectEntity(doc.Data, "")
+	if errors.Is(err, saml.ErrEntityIDRequired) {
+		return trust, nil
+	}
+	if err == nil {
+		_, err = saml.MetadataSigningCertificates(entity)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid metadata: %w", err)
+	}
+	return trust, nil
+}
+
+// signingCerts returns the signing certificates of the entity issuer, or of
+// the only entity in the metadata. Problems are reported once per issuer
+// and disable the comparison for its messages.
+func (m *metadataTrust) signingCerts(cmd *cobra.Command, issuer string) []saml.ExtractedCertificate {
+	if certs, ok := m.certs[issuer]; ok {
+		return certs
+	}
+	entity, err := saml.SelectEntity(m.data, issuer)
+	var certs []saml.ExtractedCertificate
+	if err == nil {
+		certs, err = saml.MetadataSigningCertificates(entity)
+	}
+	if err != nil {
+		notef(cmd, "⚠️  Not comparing certificates of %s with the metadata: %v\n", issuer, err)
+	}
+	m.certs[issuer] = certs
+	return certs
+}
+
+// permittedScopes returns the shibmd:Scope values of the entity issuer, or
+// of the only entity in the metadata. Unusable metadata disables the check
+// for its messages; certificate problems are reported by signingCerts.
+func (m *metadataTrust) permittedScopes(issuer string) []saml.Scope {
+	if scopes, ok := m.scopes[issuer]; ok {
+		return scopes
+	}
+	entity, err := saml.SelectEntity(m.data, issuer)
+	var scopes []saml.Scope
+	if err == nil {
+		scopes, _ = saml.MetadataScopes(entity)
+	}
+	m.scopes[issuer] = scopes
+	return scopes
+}
+
+// messageIssuer returns the Issuer of a message or of its assertion
+func messageIssuer(info *saml.SAMLInfo) string {
+	for ; info != nil; info = info.Assertion {
+		if info.Issuer != "" {
+			return info.Issuer
+		}
+	}
+	return ""
+}
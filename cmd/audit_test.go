@@ -0,0 +1,282 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func resetAuditFlags() {
+	auditFile = ""
+	auditKey = ""
+	auditMaxLifetime = time.Hour
+	auditMinSeverity = "low"
+	auditMetadata = ""
+	auditMetadataCert = ""
+	outputFormat = "pretty"
+}
+
+func TestAuditCmd_UnsignedResponse(t *testing.T) {
+	resetAuditFlags()
+
+	output, err := executeCommand(rootCmd, "audit", "-f", "../testdata/fixtures/assertions/response.xml")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "found 1 issue(s)")
+	assert.Contains(t, output, "[HIGH] missing-signature: neither the Response nor the Assertion is signed")
+	assert.Contains(t, output, "Summary: 1 high, 0 medium, 0 low")
+}
+
+func TestAuditCmd_Clean(t *testing.T) {
+	resetAuditFlags()
+
+	tmpFile := createTempFile(t, `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_r">
+  <saml:Assertion ID="_a">
+    <ds:Signature xmlns:ds="http://www.w3.org/2000/09/xmldsig#"><ds:SignedInfo><ds:Reference URI="#_a"/></ds:SignedInfo></ds:Signature>
+    <saml:Conditions><saml:AudienceRestriction><saml:Audience>https://sp.example.com</saml:Audience></saml:AudienceRestriction></saml:Conditions>
+  </saml:Assertion>
+</samlp:Response>`)
+	defer os.Remove(tmpFile)
+
+	output, err := executeCommand(rootCmd, "audit", "-f", tmpFile, "--min-severity", "medium")
+	require.NoError(t, err)
+	assert.Contains(t, output, "✓ Response: no issues found")
+
+	resetAuditFlags()
+	_, err = executeCommand(rootCmd, "audit", "-f", tmpFile, "--min-severity", "critical")
+	assert.EqualError(t, err, `invalid severity "critical": must be low, medium or high`)
+}
+
+func TestAuditCmd_SignatureWrapping(t *testing.T) {
+	resetAuditFlags()
+
+	tmpFile := createTempFile(t, `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_r">
+  <samlp:Extensions>
+    <saml:Assertion ID="_a">
+      <ds:Signature xmlns:ds="http://www.w3.org/2000/09/xmldsig#"><ds:SignedInfo><ds:Reference URI="#_a"/></ds:SignedInfo></ds:Signature>
+    </saml:Assertion>
+  </samlp:Extensions>
+  <saml:Assertion ID="_a">
+    <saml:Subject><saml:NameID>admin@example.com<!---->.evil.com</saml:NameID></saml:Subject>
+  </saml:Assertion>
+</samlp:Response>`)
+	defer os.Remove(tmpFile)
+
+	output, err := executeCommand(rootCmd, "audit", "-f", tmpFile)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "found 6 issue(s)")
+	assert.Contains(t, output, "[HIGH] duplicate-id:")
+	assert.Contains(t, output, "[HIGH] unsigned-assertion:")
+	assert.Contains(t, output, "[HIGH] nameid-comment:")
+
+	assert.Contains(t, output, "[MEDIUM] missing-audience:")
+
+	resetAuditFlags()
+	output, err = executeCommand(rootCmd, "audit", "-f", tmpFile, "-o", "json", "--min-severity", "high")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "found 5 issue(s)")
+	assert.Contains(t, output, `"type": "Response"`)
+	assert.Contains(t, output, `"check": "multiple-assertions"`)
+	assert.NotContains(t, output, `"check": "missing-audience"`)
+	assert.Contains(t, output, "Summary: 5 high, 0 medium, 0 low")
+
+	// stdout is the array of results; the summary goes to stderr
+	var stdout, stderr bytes.Buffer
+	rootCmd.SetOut(&stdout)
+	rootCmd.SetErr(&stderr)
+	rootCmd.SetArgs([]string{"audit", "-f", tmpFile, "-o", "json", "--min-severity", "high"})
+	require.Error(t, rootCmd.Execute())
+	var results []auditResult
+	require.NoError(t, json.NewDecoder(&stdout).Decode(&results))
+	require.Len(t, results, 1)
+	assert.Len(t, results[0].Findings, 5)
+	assert.Contains(t, stderr.String(), "Summary: 5 high, 0 medium, 0 low")
+}
+
+func TestAuditCmd_DTD(t *testing.T) {
+	resetAuditFlags()
+
+	tmpFile := createTempFile(t, `<!DOCTYPE r [<!ENTITY xxe SYSTEM "file:///etc/passwd">]>
+<saml:Assertion xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_a"><saml:Issuer>&xxe;</saml:Issuer></saml:Assertion>`)
+	defer os.Remove(tmpFile)
+
+	output, err := executeCommand(rootCmd, "audit", "-f", tmpFile)
+	require.Error(t, err)
+	assert.Contains(t, output, "[HIGH] dtd: document has a DOCTYPE referencing external resources")
+
+	resetInspectFlags()
+	_, err = executeCommand(rootCmd, "inspect", "-f", tmpFile)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "document contains a DTD")
+}
+
+func TestAuditCmd_Metadata(t *testing.T) {
+	resetAuditFlags()
+
+	validUntil := time.Now().Add(365 * 24 * time.Hour)
+	metadataCert := testCertificateBase64(t, validUntil)
+	signatureCert := testCertificateBase64(t, validUntil)
+
+	metadataFile := createTempFile(t, `<md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" xmlns:ds="http://www.w3.org/2000/09/xmldsig#" entityID="https://idp.example.com">
+  <md:IDPSSODescriptor><md:KeyDescriptor use="signing"><ds:KeyInfo><ds:X509Data><ds:X509Certificate>`+metadataCert+`</ds:X509Certificate></ds:X509Data></ds:KeyInfo></md:KeyDescriptor></md:IDPSSODescriptor>
+</md:EntityDescriptor>`)
+	defer os.Remove(metadataFile)
+
+	responseFile := createTempFile(t, `<saml:Assertion xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_a">
+  <ds:Signature xmlns:ds="http://www.w3.org/2000/09/xmldsig#"><ds:SignedInfo><ds:Reference URI="#_a"/></ds:SignedInfo>
+    <ds:KeyInfo><ds:X509Data><ds:X509Certificate>`+signatureCert+`</ds:X509Certificate></ds:X509Data></ds:KeyInfo>
+  </ds:Signature>
+  <saml:Conditions><saml:AudienceRestriction><saml:Audience>https://sp.example.com</saml:Audience></saml:AudienceRestriction></saml:Conditions>
+</saml:Assertion>`)
+	defer os.Remove(responseFile)
+
+	output, err := executeCommand(rootCmd, "audit", "-f", responseFile, "--metadata", metadataFile)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "found 1 issue(s)")
+	assert.Contains(t, output, "[HIGH] certificate-mismatch:")
+
+	keylessFile := createTempFile(t, `<md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" entityID="https://idp.example.com"><md:IDPSSODescriptor/></md:EntityDescriptor>`)
+	defer os.Remove(keylessFile)
+
+	resetAuditFlags()
+	_, err = executeCommand(rootCmd, "audit", "-f", responseFile, "--metadata", keylessFile)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid metadata: metadata has no signing certificates")
+}
+
+func TestAuditCmd_MetadataScopes(t *testing.T) {
+	resetAuditFlags()
+	defer resetAuditFlags()
+
+	metadataCert := testCertificateBase64(t, time.Now().Add(365*24*time.Hour))
+	metadataFile := createTempFile(t, `<md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" xmlns:ds="http://www.w3.org/2000/09/xmldsig#" xmlns:shibmd="urn:mace:shibboleth:metadata:1.0" entityID="https://idp.example.org">
+  <md:IDPSSODescriptor>
+    <md:Extensions><shibmd:Scope regexp="false">example.org</shibmd:Scope></md:Extensions>
+    <md:KeyDescriptor use="signing"><ds:KeyInfo><ds:X509Data><ds:X509Certificate>`+metadataCert+`</ds:X509Certificate></ds:X509Data></ds:KeyInfo></md:KeyDescriptor>
+  </md:IDPSSODescriptor>
+</md:EntityDescriptor>`)
+	defer os.Remove(metadataFile)
+
+	assertionFile := createTempFile(t, `<saml:Assertion xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_a">
+  <saml:Issuer>https://idp.example.org</saml:Issuer>
+  <saml:Conditions><saml:AudienceRestriction><saml:Audience>https://sp.example.com</saml:Audience></saml:AudienceRestriction></saml:Conditions>
+  <saml:AttributeStatement>
+    <saml:Attribute Name="urn:oid:1.3.6.1.4.1.5923.1.1.1.6"><saml:AttributeValue>admin@university.edu</saml:AttributeValue></saml:Attribute>
+  </saml:AttributeStatement>
+</saml:Assertion>`)
+	defer os.Remove(assertionFile)
+
+	output, err := executeCommand(rootCmd, "audit", "-f", assertionFile, "--metadata", metadataFile, "--min-severity", "high")
+	require.Error(t, err)
+	assert.Equal(t, ExitFindings, ExitCode(err))
+	assert.Contains(t, output, `[HIGH] attribute-scope: eduPersonPrincipalName value "admin@university.edu" has scope "university.edu", which the IdP may not assert (possible scope injection; permitted: example.org)`)
+}
+
+func TestAuditCmd_MetadataSelfSigned(t *testing.T) {
+	resetAuditFlags()
+	defer resetAuditFlags()
+
+	key, cert, err := saml.NewSelfSignedKey("federation.example.org")
+	require.NoError(t, err)
+	doc, err := saml.ReadDocument([]byte(`<md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" xmlns:ds="http://www.w3.org/2000/09/xmldsig#" ID="_md" entityID="https://idp.example.org">
+  <md:IDPSSODescriptor><md:KeyDescriptor use="signing"><ds:KeyInfo><ds:X509Data><ds:X509Certificate>` + base64.StdEncoding.EncodeToString(cert.Raw) + `</ds:X509Certificate></ds:X509Data></ds:KeyInfo></md:KeyDescriptor></md:IDPSSODescriptor>
+</md:EntityDescriptor>`))
+	require.NoError(t, err)
+	require.NoError(t, saml.SignEnveloped(doc.Root(), key, cert, saml.SigAlgRSASHA256))
+	signed, err := doc.WriteToString()
+	require.NoError(t, err)
+	metadataFile := createTempFile(t, signed)
+	defer os.Remove(metadataFile)
+	certFile := createTempFile(t, string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})))
+	defer os.Remove(certFile)
+
+	assertionFile := createTempFile(t, `<saml:Assertion xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_a">
+  <saml:Issuer>https://idp.example.org</saml:Issuer>
+  <saml:Conditions><saml:AudienceRestriction><saml:Audience>https://sp.example.com</saml:Audience></saml:AudienceRestriction></saml:Conditions>
+</saml:Assertion>`)
+	defer os.Remove(assertionFile)
+
+	// The KeyInfo certificate proves integrity, not who signed
+	output, _ := executeCommand(rootCmd, "audit", "-f", assertionFile, "--metadata", metadataFile)
+	assert.Contains(t, output, "metadata signature was only checked against its own certificate")
+
+	resetAuditFlags()
+	output, _ = executeCommand(rootCmd, "audit", "-f", assertionFile, "--metadata", metadataFile, "--metadata-cert", certFile)
+	assert.NotContains(t, output, "only checked against its own certificate")
+}
+
+func TestAuditCmd_MetadataAggregateURL(t *testing.T) {
+	resetAuditFlags()
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	validUntil := time.Now().Add(365 * 24 * time.Hour)
+	idpCert := testCertificateBase64(t, validUntil)
+	otherCert := testCertificateBase64(t, validUntil)
+	entity := func(entityID, cert string) string {
+		return `<md:EntityDescriptor entityID="` + entityID + `"><md:IDPSSODescriptor><md:KeyDescriptor use="signing"><ds:KeyInfo><ds:X509Data><ds:X509Certificate>` + cert + `</ds:X509Certificate></ds:X509Data></ds:KeyInfo></md:KeyDescriptor></md:IDPSSODescriptor></md:EntityDescriptor>`
+	}
+	feed := `<md:EntitiesDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" xmlns:ds="http://www.w3.org/2000/09/xmldsig#">` +
+		entity("https://other.example.com", otherCert) + entity("https://idp.example.com", idpCert) + `</md:EntitiesDescriptor>`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(feed))
+	}))
+	defer server.Close()
+
+	assertion := func(issuer string) string {
+		return `<saml:Assertion xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_a">
+  <saml:Issuer>` + issuer + `</saml:Issuer>
+  <ds:Signature xmlns:ds="http://www.w3.org/2000/09/xmldsig#"><ds:SignedInfo><ds:Reference URI="#_a"/></ds:SignedInfo>
+    <ds:KeyInfo><ds:X509Data><ds:X509Certificate>` + idpCert + `</ds:X509Certificate></ds:X509Data></ds:KeyInfo>
+  </ds:Signature>
+  <saml:Conditions><saml:AudienceRestriction><saml:Audience>https://sp.example.com</saml:Audience></saml:AudienceRestriction></saml:Conditions>
+</saml:Assertion>`
+	}
+
+	matching := createTempFile(t, assertion("https://idp.example.com"))
+	defer os.Remove(matching)
+	output, err := executeCommand(rootCmd, "audit", "-f", matching, "--metadata", server.URL)
+	require.NoError(t, err)
+	assert.NotContains(t, output, "certificate-mismatch")
+
+	resetAuditFlags()
+	mismatching := createTempFile(t, assertion("https://other.example.com"))
+	defer os.Remove(mismatching)
+	output, err = executeCommand(rootCmd, "audit", "-f", mismatching, "--metadata", server.URL)
+	require.Error(t, err)
+	assert.Contains(t, output, "[HIGH] certificate-mismatch:")
+
+	resetAuditFlags()
+	unknown := createTempFile(t, assertion("https://unknown.example.com"))
+	defer os.Remove(unknown)
+	output, err = executeCommand(rootCmd, "audit", "-f", unknown, "--metadata", server.URL)
+	require.NoError(t, err)
+	assert.Contains(t, output, `Not comparing certificates of https://unknown.example.com with the metadata: entity "https://unknown.example.com" not found`)
+}
+
+func TestAuditCmd_HARReplay(t *testing.T) {
+	resetAuditFlags()
+
+	response, err := os.ReadFile(filepath.Join("..", "testdata", "fixtures", "assertions", "response.xml"))
+	require.NoError(t, err)
+	post := `{"request": {"method": "POST", "url": "https://sp.example.com/acs",
+			"postData": {"mimeType": "text/plain", "params": [{"name": "SAMLResponse", "value": "` + url.QueryEscape(base64.StdEncoding.EncodeToString(response)) + `"}]}},
+			"response": {"content": {"mimeType": "text/html", "text": ""}}}`
+	harPath := createTempFile(t, `{"log": {"entries": [`+post+`, `+post+`]}}`)
+	defer os.Remove(harPath)
+
+	output, err := executeCommand(rootCmd, "audit", "-f", harPath)
+	require.Error(t, err)
+	assert.Contains(t, output, "[HIGH] replay: replay of message 1: identical payload delivered again")
+	assert.Contains(t, output, "Summary: 3 high, 0 medium, 0 low")
+}
@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gliwka/SAMLurai/internal/inspect"
+	"github.com/gliwka/SAMLurai/internal/output"
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/spf13/cobra"
+)
+
+var (
+	certsFile   string
+	certsKey    string
+	certsOutDir string
+)
+
+// certExpiryWarning is how far ahead expiring certificates are flagged
+const certExpiryWarning = 30 * 24 * time.Hour
+
+var certsCmd = &cobra.Command{
+	Use:   "certs",
+	Short: "Extract X.509 certificates from SAML messages and metadata",
+	Long: `Extract every X.509 certificate from an assertion, response, metadata
+document or HAR file and show its subject, issuer, validity and SHA-1 and
+SHA-256 fingerprints. Certificates that are expired or expire within 30 days
+are flagged. Duplicates are listed once.
+
+With --out-dir, each certificate is also written as a PEM file.
+
+Examples:
+  # List certificates in a response
+  samlurai certs -f response.xml
+
+  # Export the signing certificates from IdP metadata
+  samlurai certs -f idp-metadata.xml --out-dir ./certs
+
+  # All certificates seen in a HAR capture, as JSON
+  samlurai certs -f session.har -o json`,
+	RunE: runCerts,
+}
+
+func init() {
+	rootCmd.AddCommand(certsCmd)
+
+	certsCmd.Flags().StringVarP(&certsFile, "file", "f", "", "Read SAML or metadata from file (supports XML, base64, or HAR files)")
+	certsCmd.Flags().StringVarP(&certsKey, "key", "k", "", "Path to private key for decryption (PEM format)")
+	certsCmd.Flags().StringVar(&certsOutDir, "out-dir", "", "Write each certificate as a PEM file to this directory")
+}
+
+// certEntry is a certificate together with its expiry status and export path
+type certEntry struct {
+	saml.ExtractedCertificate
+	Message int    `json:"message"`
+	Warning string `json:"warning,omitempty"`
+	File    string `json:"file,omitempty"`
+}
+
+func runCerts(cmd *cobra.Command, args []string) error {
+	input, err := getInspectInput(cmd, certsFile)
+	if err != nil {
+		return err
+	}
+
+	result, err := inspect.Run(cmd.Context(), inspect.Request{
+		Input:    input,
+		Filename: certsFile,
+		KeyPath:  certsKey,
+	})
+	if err != nil {
+		return err
+	}
+
+	entries := []certEntry{}
+	seen := map[string]bool{}
+	now := time.Now()
+	for _, msg := range result.Messages {
+		certs, err := saml.ExtractCertificates(msg.XML)
+		if err != nil {
+			notef(cmd, "⚠️  Skipping message %d: %v\n", msg.Index(), err)
+			continue
+		}
+		for _, cert := range certs {
+			if seen[cert.SHA256Fingerprint] {
+				continue
+			}
+			seen[cert.SHA256Fingerprint] = true
+			entries = append(entries, certEntry{
+				ExtractedCertificate: cert,
+				Message:              msg.Index(),
+				Warning:              certWarning(cert.Certificate.NotBefore, cert.Certificate.NotAfter, now),
+			})
+		}
+	}
+
+	if certsOutDir != "" {
+		if err := os.MkdirAll(certsOutDir, 0755); err != nil {
+			return fmt.Errorf("failed to create output directory: %w", err)
+		}
+		for i := range entries {
+			name := fmt.Sprintf("cert-%d-%s.pem", i+1, strings.ToLower(strings.ReplaceAll(entries[i].SHA256Fingerprint, ":", "")[:16]))
+			path := filepath.Join(certsOutDir, name)
+			if err := os.WriteFile(path, entries[i].PEM(), 0644); err != nil {
+				return fmt.Errorf("failed to write certificate: %w", err)
+			}
+			entries[i].File = path
+		}
+	}
+
+	if output.NewFormatter(outputFormat).IsJSON() {
+		formatted, err := output.NewFormatter(outputFormat).FormatJSON(entries)
+		if err != nil {
+			return fmt.Errorf("failed to format output: %w", err)
+		}
+		fmt.Fprint(cmd.OutOrStdout(), formatted)
+		return nil
+	}
+
+	w := cmd.OutOrStdout()
+	if len(entries) == 0 {
+		fmt.Fprintln(w, "No certificates found.")
+		return nil
+	}
+	for i, e := range entries {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "[%d] %s\n", i+1, e.Subject)
+		fmt.Fprintf(w, "  Location:      %s", e.Location)
+		if result.IsHAR {
+			fmt.Fprintf(w, " (message %d)", e.Message)
+		}
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "  Issuer:        %s\n", e.Issuer)
+		fmt.Fprintf(w, "  Serial:        %s\n", e.Serial)
+		fmt.Fprintf(w, "  Valid From:    %s\n", e.NotBefore.Format(time.RFC3339))
+		fmt.Fprintf(w, "  Valid Until:   %s\n", e.NotAfter.Format(time.RFC3339))
+		fmt.Fprintf(w, "  SHA-1:         %s\n", e.SHA1Fingerprint)
+		fmt.Fprintf(w, "  SHA-256:       %s\n", e.SHA256Fingerprint)
+		if e.Warning != "" {
+			fmt.Fprintf(w, "  ⚠️  %s\n", e.Warning)
+		}
+		if e.File != "" {
+			fmt.Fprintf(w, "  Saved to:      %s\n", e.File)
+		}
+	}
+	return nil
+}
+
+// certWarning describes a validity problem of a certificate at now, or
+// returns an empty string
+func certWarning(notBefore, notAfter, now time.Time) string {
+	switch {
+	case now.After(notAfter):
+		return "Certificate has expired"
+	case now.Before(notBefore):
+		return "Certificate is not yet valid"
+	case notAfter.Sub(now) < certExpiryWarning:
+		return fmt.Sprintf("Certificate expires in %d day(s)", int(notAfter.Sub(now).Hours()/24)+1)
+	}
+	return ""
+}
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func resetCertsFlags() {
+	certsFile = ""
+	certsKey = ""
+	certsOutDir = ""
+	outputFormat = "pretty"
+}
+
+// testCertificateBase64 returns a base64 DER certificate valid until notAfter
+func testCertificateBase64(t *testing.T, notAfter time.Time) string {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(42),
+		Subject:      pkix.Name{CommonName: "idp.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	return base64.StdEncoding.EncodeToString(der)
+}
+
+func TestCertsCmd_Metadata(t *testing.T) {
+	resetCertsFlags()
+
+	cert := testCertificateBase64(t, time.Now().Add(10*24*time.Hour))
+	metadata := `<md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" xmlns:ds="http://www.w3.org/2000/09/xmldsig#" entityID="https://idp.example.com">
+  <md:IDPSSODescriptor>
+    <md:KeyDescriptor use="signing"><ds:KeyInfo><ds:X509Data><ds:X509Certificate>` + cert + `</ds:X509Certificate></ds:X509Data></ds:KeyInfo></md:KeyDescriptor>
+    <md:KeyDescriptor use="encryption"><ds:KeyInfo><ds:X509Data><ds:X509Certificate>` + cert + `</ds:X509Certificate></ds:X509Data></ds:KeyInfo></md:KeyDescriptor>
+  </md:IDPSSODescriptor>
+</md:EntityDescriptor>`
+	tmpFile := createTempFile(t, metadata)
+	defer os.Remove(tmpFile)
+	outDir := filepath.Join(t.TempDir(), "certs")
+
+	output, err := executeCommand(rootCmd, "certs", "-f", tmpFile, "--out-dir", outDir)
+	require.NoError(t, err)
+
+	assert.Contains(t, output, "[1] CN=idp.example.com")
+	assert.NotContains(t, output, "[2]", "duplicate certificates are listed once")
+	assert.Contains(t, output, "Location:      KeyDescriptor (signing)")
+	assert.Contains(t, output, "Serial:        42")
+	assert.Contains(t, output, "SHA-256:")
+	assert.Contains(t, output, "⚠️  Certificate expires in 10 day(s)")
+
+	files, err := filepath.Glob(filepath.Join(outDir, "cert-1-*.pem"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Contains(t, output, "Saved to:      "+files[0])
+	assert.Contains(t, string(mustReadFile(t, files[0])), "-----BEGIN CERTIFICATE-----")
+}
+
+func TestCertsCmd_JSON(t *testing.T) {
+	resetCertsFlags()
+
+	cert := testCertificateBase64(t, time.Now().Add(-time.Hour))
+	tmpFile := createTempFile(t, `<saml:Assertion xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_a">
+  <ds:Signature xmlns:ds="http://www.w3.org/2000/09/xmldsig#"><ds:KeyInfo><ds:X509Data><ds:X509Certificate>`+cert+`</ds:X509Certificate></ds:X509Data></ds:KeyInfo></ds:Signature>
+</saml:Assertion>`)
+	defer os.Remove(tmpFile)
+
+	output, err := executeCommand(rootCmd, "certs", "-f", tmpFile, "-o", "json")
+	require.NoError(t, err)
+	assert.Contains(t, output, `"location": "Signature"`)
+	assert.Contains(t, output, `"sha1_fingerprint": "`)
+	assert.Contains(t, output, `"warning": "Certificate has expired"`)
+}
+
+func TestCertsCmd_None(t *testing.T) {
+	resetCertsFlags()
+
+	output, err := executeCommand(rootCmd, "certs", "-f", filepath.Join("..", "testdata", "fixtures", "assertions", "response.xml"))
+	require.NoError(t, err)
+	assert.Contains(t, output, "No certificates found.")
+}
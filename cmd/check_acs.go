@@ -0,0 +1,273 @@
+package cmd
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gliwka/SAMLurai/internal/metadata"
+	"github.com/gliwka/SAMLurai/internal/output"
+	"github.com/gliwka/SAMLurai/internal/probe"
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/spf13/cobra"
+)
+
+var (
+	checkACSMetadata string
+	checkACSEntityID string
+	checkACSFile     string
+	checkACSNoSLO    bool
+	checkACSCACert   string
+	checkACSTimeout  time.Duration
+)
+
+var checkCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Check the configuration around an SSO integration",
+}
+
+var checkACSCmd = &cobra.Command{
+	Use:   "acs",
+	Short: "Probe the ACS and SLO endpoints of an SP",
+	Long: `Probe the AssertionConsumerService and SingleLogoutService endpoints of
+an SP, to rule out endpoint misconfiguration when SSO fails.
+
+The endpoints are taken from the SP metadata (--metadata, a file or URL) or
+from the AssertionConsumerServiceURL of an AuthnRequest (-f, stdin or
+--clipboard, in any encoding inspect accepts). Given both, the endpoints of
+the metadata are probed and the AuthnRequest's ACS is checked against them,
+as IdPs reject AuthnRequests naming an ACS that is not registered.
+
+Each endpoint is sent the request a browser makes for its binding, without
+a SAML message: an empty form POST for HTTP-POST, a GET for HTTP-Redirect
+and HTTP-Artifact. Redirects are followed like a browser does. Reported
+are:
+  - DNS and connection failures and timeouts
+  - the TLS certificate: trust, hostname and expiry (--ca-cert adds an
+    internal CA to the system roots)
+  - statuses showing the path is not served (404) or the server is failing
+    (502, 503, 504)
+  - redirects that turn the POST into a GET, which drops the SAML message
+  - endpoints served over plain HTTP
+
+As the probe carries no SAML message, an error status such as 400 or 500
+is expected from many SPs and only noted.
+
+The command exits with an error if any endpoint has a problem.
+
+Examples:
+  # Probe the endpoints of an SP
+  samlurai check acs --metadata https://sp.example.com/saml/metadata
+
+  # Probe the ACS an AuthnRequest asks the IdP to post to
+  samlurai check acs -f authnrequest.txt
+
+  # Check the AuthnRequest against the registered metadata
+  samlurai check acs -f authnrequest.txt --metadata sp-metadata.xml --ca-cert corp-root.pem`,
+	Args: cobra.NoArgs,
+	RunE: runCheckACS,
+}
+
+func init() {
+	rootCmd.AddCommand(checkCmd)
+	checkCmd.AddCommand(checkACSCmd)
+
+	flags := checkACSCmd.Flags()
+	flags.StringVar(&checkACSMetadata, "metadata", "", "SP metadata (file or URL)")
+	flags.StringVar(&checkACSEntityID, "entity-id", "", "Entity ID of the SP, to select it from aggregate metadata")
+	flags.StringVarP(&checkACSFile, "file", "f", "", "Read an AuthnRequest from file")
+	flags.BoolVar(&checkACSNoSLO, "no-slo", false, "Only probe ACS endpoints")
+	flags.StringVar(&checkACSCACert, "ca-cert", "", "Additional trusted CA certificates (PEM)")
+	flags.DurationVar(&checkACSTimeout, "timeout", probe.DefaultTimeout, "Timeout for each endpoint")
+}
+
+// checkACSOutput is the JSON output of check acs
+type checkACSOutput struct {
+	EntityID  string          `json:"entity_id,omitempty"`
+	Endpoints []*probe.Result `json:"endpoints"`
+}
+
+func runCheckACS(cmd *cobra.Command, args []string) error {
+	formatter := output.NewFormatter(outputFormat)
+	if !formatter.IsJSON() && outputFormat != "pretty" {
+		return fmt.Errorf("check acs supports -o pretty, json or psobject, not %s", outputFormat)
+	}
+
+	targets, entityID, unregistered, err := checkACSTargets(cmd)
+	if err != nil {
+		return err
+	}
+	opts := probe.Options{Timeout: checkACSTimeout}
+	if checkACSCACert != "" {
+		if opts.RootCAs, err = loadCAPool(checkACSCACert); err != nil {
+			return err
+		}
+	}
+
+	result := checkACSOutput{EntityID: entityID}
+	failed := 0
+	for _, target := range targets {
+		r := probe.Probe(cmd.Context(), target, opts)
+		if target.URL == unregistered {
+			r.Problems = append(r.Problems, "not registered as an AssertionConsumerService in the SP metadata: the IdP will reject the AuthnRequest")
+		}
+		if !r.OK() {
+			failed++
+		}
+		result.Endpoints = append(result.Endpoints, r)
+	}
+
+	if formatter.IsJSON() {
+		formatted, err := formatter.FormatJSON(result)
+		if err != nil {
+			return fmt.Errorf("failed to format output: %w", err)
+		}
+		fmt.Fprint(cmd.OutOrStdout(), formatted)
+	} else {
+		printCheckACS(cmd.OutOrStdout(), result, failed)
+	}
+
+	if failed > 0 {
+		return withExitCode(ExitFindings, fmt.Errorf("%d of %d endpoints failed the check", failed, len(targets)))
+	}
+	return nil
+}
+
+// checkACSTargets collects the endpoints to probe from the metadata and
+// the AuthnRequest. It also returns the AuthnRequest's ACS URL if the
+// metadata does not register it.
+func checkACSTargets(cmd *cobra.Command) ([]probe.Target, string, string, error) {
+	var requested *probe.Target
+	if checkACSFile != "" || checkACSMetadata == "" {
+		input, err := getInspectInput(cmd, checkACSFile)
+		if err != nil {
+			return nil, "", "", err
+		}
+		if requested, err = authnRequestACS(input); err != nil {
+			return nil, "", "", err
+		}
+	}
+
+	if checkACSMetadata == "" {
+		if requested == nil {
+			return nil, "", "", errors.New("the AuthnRequest has no AssertionConsumerServiceURL, so the IdP uses the one registered for the SP: pass the SP metadata with --metadata")
+		}
+		return []probe.Target{*requested}, "", "", nil
+	}
+
+	doc, err := metadata.Load(cmd.Context(), checkACSMetadata, metadata.Options{})
+	if err != nil {
+		return nil, "", "", err
+	}
+	info, err := metadata.ParseSP(doc.Data, checkACSEntityID)
+	if err != nil {
+		return nil, "", "", fmt.Errorf("invalid SP metadata: %w", err)
+	}
+	if len(info.ACS) == 0 {
+		return nil, "", "", errors.New("SP metadata has no AssertionConsumerService")
+	}
+
+	var targets []probe.Target
+	registered := false
+	for _, endpoint := range info.ACS {
+		targets = append(targets, probe.Target{Role: probe.RoleACS, Binding: endpoint.Binding, URL: endpoint.URL})
+		registered = registered || requested == nil || endpoint.URL == requested.URL
+	}
+	if !checkACSNoSLO {
+		for _, endpoint := range info.SLO {
+			targets = append(targets, probe.Target{Role: probe.RoleSLO, Binding: endpoint.Binding, URL: endpoint.URL})
+		}
+	}
+	if !registered {
+		return append([]probe.Target{*requested}, targets...), info.EntityID, requested.URL, nil
+	}
+	return targets, info.EntityID, "", nil
+}
+
+// authnRequestACS returns the ACS an AuthnRequest names, or nil if it
+// names none
+func authnRequestACS(input string) (*probe.Target, error) {
+	xmlData, err := saml.NewDecoder().SmartDecode(input)
+	if err != nil {
+		return nil, withExitCode(ExitParse, fmt.Errorf("failed to decode input: %w", err))
+	}
+	info, err := saml.NewParser().Parse(xmlData)
+	if err != nil {
+		return nil, withExitCode(ExitParse, fmt.Errorf("failed to parse SAML: %w", err))
+	}
+	if info.Type != "AuthnRequest" {
+		return nil, fmt.Errorf("expected an AuthnRequest, got %s", info.Type)
+	}
+	if info.AssertionConsumerServiceURL == "" {
+		return nil, nil
+	}
+	binding := info.ProtocolBinding
+	if binding == "" {
+		binding = saml.BindingHTTPPost
+	}
+	return &probe.Target{Role: probe.RoleACS, Binding: binding, URL: info.AssertionConsumerServiceURL}, nil
+}
+
+// loadCAPool returns the system roots with the PEM certificates of path
+// added
+func loadCAPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA certificates: %w", err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no PEM certificates in %s", path)
+	}
+	return pool, nil
+}
+
+func printCheckACS(w io.Writer, result checkACSOutput, failed int) {
+	if result.EntityID != "" {
+		fmt.Fprintf(w, "Endpoints of %s:\n\n", result.EntityID)
+	}
+
+	for _, r := range result.Endpoints {
+		icon := "✓"
+		switch {
+		case !r.OK():
+			icon = "❌"
+		case len(r.Warnings) > 0:
+			icon = "⚠️ "
+		}
+		binding := strings.TrimPrefix(r.Binding, "urn:oasis:names:tc:SAML:2.0:bindings:")
+		fmt.Fprintf(w, "%s %s %s %s\n", icon, r.Role, binding, r.URL)
+
+		if len(r.Hops) > 0 {
+			steps := []string{r.Hops[0].Method}
+			for _, hop := range r.Hops {
+				if hop.Location != "" {
+					steps = append(steps, fmt.Sprintf("%d %s", hop.Status, truncateURL(hop.Location, 60)))
+				} else {
+					steps = append(steps, fmt.Sprintf("%d", hop.Status))
+				}
+			}
+			fmt.Fprintf(w, "     %s (%.0f ms)\n", strings.Join(steps, " → "), r.DurationMS)
+		}
+		if r.TLS != nil {
+			fmt.Fprintf(w, "     %s, %s, valid until %s (%d days)\n", r.TLS.Version, r.TLS.Subject, r.TLS.NotAfter.Format(time.DateOnly), r.TLS.DaysLeft)
+		}
+		for _, problem := range r.Problems {
+			fmt.Fprintf(w, "     ❌ %s\n", problem)
+		}
+		for _, warning := range r.Warnings {
+			fmt.Fprintf(w, "     ⚠️  %s\n", warning)
+		}
+	}
+
+	if failed == 0 {
+		fmt.Fprintf(w, "\n✓ All %d endpoint(s) reachable\n", len(result.Endpoints))
+	}
+}
@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func resetCheckACSFlags() {
+	checkACSMetadata = ""
+	checkACSEntityID = ""
+	checkACSFile = ""
+	checkACSNoSLO = false
+	checkACSCACert = ""
+	outputFormat = "pretty"
+}
+
+func checkACSServer(t *testing.T) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/acs":
+			w.WriteHeader(http.StatusBadRequest)
+		case "/slo":
+			http.Redirect(w, r, "/", http.StatusFound)
+		case "/":
+			w.WriteHeader(http.StatusOK)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func checkACSMetadataFile(t *testing.T, base, acsPath string) string {
+	md := `<md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" entityID="https://sp.example.com">
+  <md:SPSSODescriptor protocolSupportEnumeration="urn:oasis:names:tc:SAML:2.0:protocol">
+    <md:SingleLogoutService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect" Location="` + base + `/slo"/>
+    <md:AssertionConsumerService index="0" Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST" Location="` + base + acsPath + `"/>
+  </md:SPSSODescriptor>
+</md:EntityDescriptor>`
+	path := createTempFile(t, md)
+	t.Cleanup(func() { os.Remove(path) })
+	return path
+}
+
+func TestCheckACSCmd_Metadata(t *testing.T) {
+	resetCheckACSFlags()
+	srv := checkACSServer(t)
+
+	output, err := executeCommand(rootCmd, "check", "acs", "--metadata", checkACSMetadataFile(t, srv.URL, "/acs"))
+	require.NoError(t, err)
+	assert.Contains(t, output, "Endpoints of https://sp.example.com:")
+	assert.Contains(t, output, "⚠️  ACS HTTP-POST "+srv.URL+"/acs")
+	assert.Contains(t, output, "POST → 400")
+	assert.Contains(t, output, "⚠️  SLO HTTP-Redirect "+srv.URL+"/slo")
+	assert.Contains(t, output, "GET → 302 "+srv.URL+"/ → 200")
+	assert.Contains(t, output, "served over plain HTTP")
+	assert.Contains(t, output, "✓ All 2 endpoint(s) reachable")
+}
+
+func TestCheckACSCmd_NotFound(t *testing.T) {
+	resetCheckACSFlags()
+	srv := checkACSServer(t)
+
+	output, err := executeCommand(rootCmd, "check", "acs", "--no-slo", "-o", "json", "--metadata", checkACSMetadataFile(t, srv.URL, "/saml/acs"))
+	require.Error(t, err)
+	assert.Equal(t, ExitFindings, ExitCode(err))
+	assert.Contains(t, err.Error(), "1 of 1 endpoints failed the check")
+
+	var result checkACSOutput
+	require.NoError(t, json.NewDecoder(strings.NewReader(output)).Decode(&result))
+	require.Len(t, result.Endpoints, 1)
+	assert.Equal(t, "ACS", result.Endpoints[0].Role)
+	assert.Equal(t, 404, result.Endpoints[0].Status)
+	assert.Contains(t, result.Endpoints[0].Problems, "404 Not Found: the path is not served")
+}
+
+func TestCheckACSCmd_AuthnRequest(t *testing.T) {
+	resetCheckACSFlags()
+	srv := checkACSServer(t)
+
+	request := `<samlp:AuthnRequest xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_q" Version="2.0" ` +
+		`AssertionConsumerServiceURL="` + srv.URL + `/acs/other"/>`
+	requestFile := createTempFile(t, base64.StdEncoding.EncodeToString([]byte(request)))
+	defer os.Remove(requestFile)
+
+	output, err := executeCommand(rootCmd, "check", "acs", "-f", requestFile)
+	require.Error(t, err)
+	assert.Contains(t, output, "❌ ACS HTTP-POST "+srv.URL+"/acs/other")
+
+	// The AuthnRequest's ACS is also checked against the metadata
+	resetCheckACSFlags()
+	output, err = executeCommand(rootCmd, "check", "acs", "-f", requestFile, "--no-slo", "--metadata", checkACSMetadataFile(t, srv.URL, "/acs"))
+	require.Error(t, err)
+	assert.Contains(t, output, "not registered as an AssertionConsumerService in the SP metadata")
+	assert.Contains(t, err.Error(), "1 of 2 endpoints failed the check")
+}
@@ -0,0 +1,187 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/gliwka/SAMLurai/internal/flow"
+	"github.com/gliwka/SAMLurai/internal/output"
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/spf13/cobra"
+)
+
+var (
+	checkFlowFile string
+	checkFlowSpec string
+)
+
+var checkFlowCmd = &cobra.Command{
+	Use:   "check-flow",
+	Short: "Compare a HAR capture against the expected login flow",
+	Long: `Compare the requests in a HAR capture against a YAML definition of a
+healthy login and report the step at which the capture diverged.
+
+Steps are matched in order; unrelated requests between them are ignored.
+Each step lists the criteria a request must meet:
+
+  name: Okta login
+  steps:
+    - name: AuthnRequest
+      saml: AuthnRequest
+    - name: IdP login
+      url: https://idp.example.com/login
+      status: 2xx
+    - name: Response
+      saml: Response
+      saml_status: Success
+    - name: ACS redirect
+      url: https://sp.example.com/acs
+      status: 302
+    - name: App
+      url: https://app.example.com/
+      status: 200
+
+Criteria: method, url (prefix), url_pattern (regular expression), status
+(302 or 3xx), saml (message type) and saml_status (e.g. Success).
+
+Responses delivered more than once, with an identical payload or a
+repeated assertion ID, are reported as replays.
+
+For each Response posted to an ACS, the session cookie the SP set in
+return is shown, with when the session began relative to the assertion's
+NotBefore/NotOnOrAfter window and whether the browser sent it back.
+
+The command exits with an error if the flow diverged.
+
+Examples:
+  # Check a capture against the happy path
+  samlurai check-flow --spec login.yaml -f capture.har
+
+  # Machine-readable output
+  samlurai check-flow --spec login.yaml -f capture.har -o json`,
+	RunE: runCheckFlow,
+}
+
+func init() {
+	rootCmd.AddCommand(checkFlowCmd)
+
+	checkFlowCmd.Flags().StringVarP(&checkFlowFile, "file", "f", "", "Read the HAR capture from file")
+	checkFlowCmd.Flags().StringVar(&checkFlowSpec, "spec", "", "YAML definition of the expected flow (required)")
+	_ = checkFlowCmd.MarkFlagRequired("spec")
+}
+
+func runCheckFlow(cmd *cobra.Command, args []string) error {
+	spec, err := flow.LoadSpec(checkFlowSpec)
+	if err != nil {
+		return err
+	}
+
+	input, err := getInspectInput(cmd, checkFlowFile)
+	if err != nil {
+		return err
+	}
+	if !saml.LooksLikeHAR(input) {
+		return fmt.Errorf("check-flow requires a HAR file")
+	}
+	var har saml.HAR
+	if err := json.Unmarshal([]byte(input), &har); err != nil {
+		return withExitCode(ExitParse, fmt.Errorf("failed to parse HAR file: %w", err))
+	}
+
+	result := flow.Check(har.Log.Entries, spec)
+
+	if output.NewFormatter(outputFormat).IsJSON() {
+		formatted, err := output.NewFormatter(outputFormat).FormatJSON(result)
+		if err != nil {
+			return fmt.Errorf("failed to format output: %w", err)
+		}
+		fmt.Fprint(cmd.OutOrStdout(), formatted)
+	} else {
+		printFlowResult(cmd, result)
+	}
+
+	if !result.OK() {
+		step := spec.Steps[result.DivergedAt-1]
+		return withExitCode(ExitFindings, fmt.Errorf("flow diverged at step %d (%s)", result.DivergedAt, step.Name))
+	}
+	return nil
+}
+
+func printFlowResult(cmd *cobra.Command, result *flow.Result) {
+	w := cmd.OutOrStdout()
+	if result.Name != "" {
+		fmt.Fprintf(w, "Flow: %s\n\n", result.Name)
+	}
+
+	for i, sr := range result.Steps {
+		switch sr.State {
+		case flow.StateMatched:
+			fmt.Fprintf(w, "✓ %d. %s\n", i+1, sr.Step.Name)
+			fmt.Fprintf(w, "     entry %d: %s\n", sr.Entry, describeFlowEntry(sr))
+		case flow.StateDiverged:
+			fmt.Fprintf(w, "✗ %d. %s\n", i+1, sr.Step.Name)
+			if sr.Entry > 0 {
+				fmt.Fprintf(w, "     closest: entry %d: %s\n", sr.Entry, describeFlowEntry(sr))
+			}
+			for _, problem := range sr.Problems {
+				fmt.Fprintf(w, "     - %s\n", problem)
+			}
+		default:
+			fmt.Fprintf(w, "- %d. %s (not reached)\n", i+1, sr.Step.Name)
+		}
+	}
+
+	if len(result.Replays) > 0 {
+		fmt.Fprintln(w)
+	}
+	for _, replay := range result.Replays {
+		what := "identical payload"
+		if replay.AssertionID != "" {
+			what = "assertion " + replay.AssertionID
+		}
+		fmt.Fprintf(w, "⚠️  entry %d replays entry %d: %s delivered again\n", replay.Index, replay.Of, what)
+	}
+
+	if len(result.Sessions) > 0 {
+		fmt.Fprintln(w)
+	}
+	for _, session := range result.Sessions {
+		printFlowSession(w, session)
+	}
+
+	if result.OK() {
+		fmt.Fprintln(w, "\n✓ Flow matches the spec")
+	}
+}
+
+// printFlowSession describes the SP session established after an ACS POST
+func printFlowSession(w io.Writer, session flow.Session) {
+	if session.Entry == 0 {
+		fmt.Fprintf(w, "⚠️  entry %d: no session cookie was set after the ACS POST\n", session.ACSEntry)
+		return
+	}
+
+	fmt.Fprintf(w, "🍪 entry %d: session cookie %s set after the ACS POST in entry %d\n", session.Entry, strings.Join(session.Cookies, ", "), session.ACSEntry)
+	if timing := session.Timing(); timing != "" && session.InWindow() {
+		fmt.Fprintf(w, "     session began %s\n", timing)
+	} else if timing != "" {
+		fmt.Fprintf(w, "     ⚠️  session began %s\n", timing)
+	}
+	if session.UsedAt > 0 {
+		fmt.Fprintf(w, "     first sent back in entry %d\n", session.UsedAt)
+	} else {
+		fmt.Fprintf(w, "     never sent back in the capture\n")
+	}
+}
+
+// describeFlowEntry summarizes the request of a step result, e.g.
+// "POST https://sp.example.com/acs → 302"
+func describeFlowEntry(sr flow.StepResult) string {
+	desc := strings.TrimSpace(sr.Method + " " + truncateURL(sr.URL, 70))
+	if sr.Status != 0 {
+		desc += fmt.Sprintf(" → %d", sr.Status)
+	}
+	return desc
+}
@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func resetCheckFlowFlags() {
+	checkFlowFile = ""
+	checkFlowSpec = ""
+	outputFormat = "pretty"
+}
+
+const checkFlowSpecYAML = `name: Test login
+steps:
+  - name: AuthnRequest
+    saml: AuthnRequest
+  - name: ACS
+    method: POST
+    url: https://sp.example.com/acs
+    status: 302
+`
+
+func checkFlowHAR(acsStatus string) string {
+	authnRequest := base64.StdEncoding.EncodeToString([]byte(`<samlp:AuthnRequest xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_q"/>`))
+	return `{"log": {"entries": [
+		{"request": {"method": "GET", "url": "https://idp.example.com/sso?SAMLRequest=` + authnRequest + `"},
+		 "response": {"status": 200, "content": {"text": ""}}},
+		{"request": {"method": "POST", "url": "https://sp.example.com/acs"},
+		 "response": {"status": ` + acsStatus + `, "content": {"text": ""}}}
+	]}}`
+}
+
+func TestCheckFlowCmd_Matches(t *testing.T) {
+	resetCheckFlowFlags()
+
+	specFile := createTempFile(t, checkFlowSpecYAML)
+	defer os.Remove(specFile)
+	harFile := createTempFile(t, checkFlowHAR("302"))
+	defer os.Remove(harFile)
+
+	output, err := executeCommand(rootCmd, "check-flow", "--spec", specFile, "-f", harFile)
+	require.NoError(t, err)
+	assert.Contains(t, output, "Flow: Test login")
+	assert.Contains(t, output, "✓ 1. AuthnRequest")
+	assert.Contains(t, output, "entry 2: POST https://sp.example.com/acs → 302")
+	assert.Contains(t, output, "✓ Flow matches the spec")
+}
+
+func TestCheckFlowCmd_Diverged(t *testing.T) {
+	resetCheckFlowFlags()
+
+	specFile := createTempFile(t, checkFlowSpecYAML)
+	defer os.Remove(specFile)
+	harFile := createTempFile(t, checkFlowHAR("500"))
+	defer os.Remove(harFile)
+
+	output, err := executeCommand(rootCmd, "check-flow", "--spec", specFile, "-f", harFile)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "flow diverged at step 2 (ACS)")
+	assert.Contains(t, output, "✗ 2. ACS")
+	assert.Contains(t, output, "closest: entry 2: POST https://sp.example.com/acs → 500")
+	assert.Contains(t, output, "- status 500, expected 302")
+}
+
+func TestCheckFlowCmd_JSON(t *testing.T) {
+	resetCheckFlowFlags()
+
+	specFile := createTempFile(t, checkFlowSpecYAML)
+	defer os.Remove(specFile)
+	harFile := createTempFile(t, checkFlowHAR("500"))
+	defer os.Remove(harFile)
+
+	output, err := executeCommand(rootCmd, "check-flow", "--spec", specFile, "-f", harFile, "-o", "json")
+	require.Error(t, err)
+	assert.Contains(t, output, `"diverged_at": 2`)
+	assert.Contains(t, output, `"state": "diverged"`)
+}
+
+func TestCheckFlowCmd_RequiresHAR(t *testing.T) {
+	resetCheckFlowFlags()
+
+	specFile := createTempFile(t, checkFlowSpecYAML)
+	defer os.Remove(specFile)
+	xmlFile := createTempFile(t, `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol"/>`)
+	defer os.Remove(xmlFile)
+
+	_, err := executeCommand(rootCmd, "check-flow", "--spec", specFile, "-f", xmlFile)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "check-flow requires a HAR file")
+}
+
+func TestCheckFlowCmd_Session(t *testing.T) {
+	resetCheckFlowFlags()
+
+	response := base64.StdEncoding.EncodeToString([]byte(`<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_r"><saml:Assertion xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_a"><saml:Conditions NotBefore="2024-01-15T10:00:00Z" NotOnOrAfter="2024-01-15T10:05:00Z"/></saml:Assertion></samlp:Response>`))
+	har := `{"log": {"entries": [
+		{"request": {"method": "GET", "url": "https://idp.example.com/sso?SAMLRequest=` + base64.StdEncoding.EncodeToString([]byte(`<samlp:AuthnRequest xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_q"/>`)) + `"},
+		 "response": {"status": 200, "content": {}}},
+		{"startedDateTime": "2024-01-15T10:06:30Z",
+		 "request": {"method": "POST", "url": "https://sp.example.com/acs", "postData": {"mimeType": "application/x-www-form-urlencoded", "params": [{"name": "SAMLResponse", "value": "` + response + `"}]}},
+		 "response": {"status": 302, "cookies": [{"name": "sp_session", "value": "s1"}], "content": {}}}
+	]}}`
+
+	specFile := createTempFile(t, checkFlowSpecYAML)
+	defer os.Remove(specFile)
+	harFile := createTempFile(t, har)
+	defer os.Remove(harFile)
+
+	output, err := executeCommand(rootCmd, "check-flow", "--spec", specFile, "-f", harFile)
+	require.NoError(t, err)
+	assert.Contains(t, output, "🍪 entry 2: session cookie sp_session set after the ACS POST in entry 2")
+	assert.Contains(t, output, "⚠️  session began 1m30s after the assertion expired")
+	assert.Contains(t, output, "never sent back in the capture")
+}
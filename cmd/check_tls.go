@@ -0,0 +1,250 @@
+package cmd
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/gliwka/SAMLurai/internal/inspect"
+	"github.com/gliwka/SAMLurai/internal/metadata"
+	"github.com/gliwka/SAMLurai/internal/output"
+	"github.com/gliwka/SAMLurai/internal/probe"
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/spf13/cobra"
+)
+
+var (
+	checkTLSMetadata string
+	checkTLSEntityID string
+	checkTLSURLs     []string
+	checkTLSFile     string
+	checkTLSKey      string
+	checkTLSIdPCert  string
+	checkTLSTimeout  time.Duration
+)
+
+var checkTLSCmd = &cobra.Command{
+	Use:   "tls",
+	Short: "Compare the TLS certificate of an IdP with its SAML signing certificates",
+	Long: `Download the TLS certificate an IdP's SSO endpoint serves and compare it
+with the SAML certificates, to catch the frequent confusion between the two:
+an SP configured with the certificate shown by the browser's padlock instead
+of the signing certificate from the IdP metadata.
+
+The SAML certificates are the signing certificates of the IdP metadata
+(--metadata, a file or URL), the certificates in the signatures of the
+messages given with -f (a Response or a HAR file) and the certificate the SP
+was configured with (--idp-cert). The TLS certificate is downloaded from
+each host of the SingleSignOnService endpoints in the metadata, or from
+--url.
+
+Flagged are:
+  - a TLS certificate configured or published where the SAML signing
+    certificate belongs, while messages are signed with another certificate
+  - a CA certificate of the TLS chain configured as signing certificate
+  - an IdP signing with its TLS certificate, which breaks SSO at every TLS
+    renewal
+  - SAML and TLS certificates sharing a key pair
+
+The command exits with an error if a certificate was confused.
+
+Examples:
+  # Compare an IdP's TLS and signing certificates
+  samlurai check tls --metadata https://idp.example.com/metadata
+
+  # Check the certificate an SP was configured with
+  samlurai check tls --metadata idp-metadata.xml --idp-cert configured.pem
+
+  # Compare with the certificates that signed the messages of a capture
+  samlurai check tls -f session.har --url https://idp.example.com/sso`,
+	Args: cobra.NoArgs,
+	RunE: runCheckTLS,
+}
+
+func init() {
+	checkCmd.AddCommand(checkTLSCmd)
+
+	flags := checkTLSCmd.Flags()
+	flags.StringVar(&checkTLSMetadata, "metadata", "", "IdP metadata (file or URL)")
+	flags.StringVar(&checkTLSEntityID, "entity-id", "", "Entity ID of the IdP, to select it from aggregate metadata")
+	flags.StringArrayVar(&checkTLSURLs, "url", nil, "IdP URL to download the TLS certificate from (repeatable; default: the SingleSignOnService hosts in --metadata)")
+	flags.StringVarP(&checkTLSFile, "file", "f", "", "Read signed messages from file (supports XML, base64, or HAR files)")
+	flags.StringVarP(&checkTLSKey, "key", "k", "", "Path to private key to decrypt encrypted assertions (PEM format)")
+	flags.StringVar(&checkTLSIdPCert, "idp-cert", "", "Certificate the SP trusts for the IdP (PEM or DER)")
+	flags.DurationVar(&checkTLSTimeout, "timeout", probe.DefaultTimeout, "Timeout for each TLS connection")
+}
+
+func runCheckTLS(cmd *cobra.Command, args []string) error {
+	formatter := output.NewFormatter(outputFormat)
+	if !formatter.IsJSON() && outputFormat != "pretty" {
+		return fmt.Errorf("check tls supports -o pretty, json or psobject, not %s", outputFormat)
+	}
+
+	certs, urls, err := checkTLSInputs(cmd)
+	if err != nil {
+		return err
+	}
+	if len(certs) == 0 {
+		return errors.New("no SAML certificates to compare: pass --metadata, -f or --idp-cert")
+	}
+	if len(urls) == 0 {
+		return errors.New("no URL to download the TLS certificate from: pass --url or --metadata with a SingleSignOnService")
+	}
+
+	var results []*probe.TLSComparison
+	failed := 0
+	for _, u := range urls {
+		var result *probe.TLSComparison
+		chain, err := probe.FetchTLSChain(cmd.Context(), u, checkTLSTimeout)
+		if err != nil {
+			result = &probe.TLSComparison{URL: u, Problems: []string{"failed to download the TLS certificate: " + err.Error()}}
+		} else {
+			result = probe.CompareTLS(u, chain, certs)
+		}
+		if !result.OK() {
+			failed++
+		}
+		results = append(results, result)
+	}
+
+	if formatter.IsJSON() {
+		formatted, err := formatter.FormatJSON(results)
+		if err != nil {
+			return fmt.Errorf("failed to format output: %w", err)
+		}
+		fmt.Fprint(cmd.OutOrStdout(), formatted)
+	} else {
+		printCheckTLS(cmd.OutOrStdout(), results, distinctCertificates(certs))
+	}
+
+	if failed > 0 {
+		return withExitCode(ExitFindings, fmt.Errorf("TLS and SAML certificates confused at %d of %d hosts", failed, len(urls)))
+	}
+	return nil
+}
+
+// checkTLSInputs collects the SAML certificates and the URLs to download
+// TLS certificates from, one per host
+func checkTLSInputs(cmd *cobra.Command) ([]probe.SAMLCertificate, []string, error) {
+	var certs []probe.SAMLCertificate
+	urls := checkTLSURLs
+
+	if checkTLSMetadata != "" {
+		doc, err := metadata.Load(cmd.Context(), checkTLSMetadata, metadata.Options{})
+		if err != nil {
+			return nil, nil, err
+		}
+		info, err := metadata.ParseIdP(doc.Data, checkTLSEntityID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid IdP metadata: %w", err)
+		}
+		for _, cert := range info.SigningCerts {
+			certs = append(certs, probe.SAMLCertificate{Certificate: cert, Kind: probe.CertMetadata, Source: "IdP metadata"})
+		}
+		if len(urls) == 0 {
+			urls = hostURLs(info.SSO)
+		}
+	}
+
+	if checkTLSFile != "" {
+		input, err := getInspectInput(cmd, checkTLSFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		result, err := inspect.Run(cmd.Context(), inspect.Request{Input: input, Filename: checkTLSFile, KeyPath: checkTLSKey})
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, msg := range result.Messages {
+			found, err := saml.ExtractCertificates(msg.XML)
+			if err != nil {
+				notef(cmd, "⚠️  Skipping message %d: %v\n", msg.Index(), err)
+				continue
+			}
+			source := "the message signature"
+			if result.IsHAR {
+				source = fmt.Sprintf("the signature of message %d", msg.Index())
+			}
+			for _, cert := range found {
+				if cert.Location == "Signature" {
+					certs = append(certs, probe.SAMLCertificate{Certificate: cert.Certificate, Kind: probe.CertSignature, Source: source})
+				}
+			}
+		}
+	}
+
+	if checkTLSIdPCert != "" {
+		cert, err := saml.LoadCertificate(checkTLSIdPCert)
+		if err != nil {
+			return nil, nil, fmt.Errorf("IdP certificate: %w", err)
+		}
+		certs = append(certs, probe.SAMLCertificate{Certificate: cert, Kind: probe.CertConfigured, Source: "--idp-cert"})
+	}
+	return certs, urls, nil
+}
+
+// distinctCertificates counts the different certificates among certs
+func distinctCertificates(certs []probe.SAMLCertificate) int {
+	var distinct []*x509.Certificate
+	for _, cert := range certs {
+		seen := false
+		for _, d := range distinct {
+			seen = seen || d.Equal(cert.Certificate)
+		}
+		if !seen {
+			distinct = append(distinct, cert.Certificate)
+		}
+	}
+	return len(distinct)
+}
+
+// hostURLs returns one of the endpoint locations for each host, in order
+func hostURLs(endpoints map[string]string) []string {
+	locations := make([]string, 0, len(endpoints))
+	for _, location := range endpoints {
+		locations = append(locations, location)
+	}
+	sort.Strings(locations)
+
+	var urls []string
+	hosts := map[string]bool{}
+	for _, location := range locations {
+		u, err := url.Parse(location)
+		if err != nil || u.Scheme != "https" || hosts[u.Host] {
+			continue
+		}
+		hosts[u.Host] = true
+		urls = append(urls, location)
+	}
+	return urls
+}
+
+func printCheckTLS(w io.Writer, results []*probe.TLSComparison, certs int) {
+	for i, r := range results {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "TLS certificate of %s\n", r.URL)
+		for j, cert := range r.Chain {
+			role := "leaf"
+			if j > 0 {
+				role = "CA"
+			}
+			fmt.Fprintf(w, "  [%s] %s\n", role, cert.Subject)
+			fmt.Fprintf(w, "       SHA-256 %s, valid until %s\n", cert.SHA256Fingerprint, cert.NotAfter.Format(time.DateOnly))
+		}
+		for _, problem := range r.Problems {
+			fmt.Fprintf(w, "  ❌ %s\n", problem)
+		}
+		for _, warning := range r.Warnings {
+			fmt.Fprintf(w, "  ⚠️  %s\n", warning)
+		}
+		if len(r.Problems) == 0 && len(r.Warnings) == 0 {
+			fmt.Fprintf(w, "  ✓ Distinct from the %d SAML certificate(s)\n", certs)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gliwka/SAMLurai/internal/probe"
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func resetCheckTLSFlags() {
+	checkTLSMetadata = ""
+	checkTLSEntityID = ""
+	checkTLSURLs = nil
+	checkTLSFile = ""
+	checkTLSKey = ""
+	checkTLSIdPCert = ""
+	outputFormat = "pretty"
+}
+
+// checkTLSSetup starts an IdP served over TLS and writes its metadata,
+// with a signing certificate distinct from the TLS certificate, and the
+// TLS certificate as PEM
+func checkTLSSetup(t *testing.T) (srv *httptest.Server, metadataFile, tlsCertFile string) {
+	srv = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(srv.Close)
+
+	_, signing, err := saml.NewSelfSignedKey("idp-signing")
+	require.NoError(t, err)
+	md := `<md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" xmlns:ds="http://www.w3.org/2000/09/xmldsig#" entityID="https://idp.example.com">
+  <md:IDPSSODescriptor protocolSupportEnumeration="urn:oasis:names:tc:SAML:2.0:protocol">
+    <md:KeyDescriptor use="signing"><ds:KeyInfo><ds:X509Data><ds:X509Certificate>` + base64.StdEncoding.EncodeToString(signing.Raw) + `</ds:X509Certificate></ds:X509Data></ds:KeyInfo></md:KeyDescriptor>
+    <md:SingleSignOnService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect" Location="` + srv.URL + `/sso/redirect"/>
+    <md:SingleSignOnService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST" Location="` + srv.URL + `/sso/post"/>
+  </md:IDPSSODescriptor>
+</md:EntityDescriptor>`
+
+	dir := t.TempDir()
+	metadataFile = filepath.Join(dir, "idp-metadata.xml")
+	require.NoError(t, os.WriteFile(metadataFile, []byte(md), 0644))
+	tlsCertFile = filepath.Join(dir, "tls.pem")
+	require.NoError(t, os.WriteFile(tlsCertFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0644))
+	return srv, metadataFile, tlsCertFile
+}
+
+func TestCheckTLSCmd_Distinct(t *testing.T) {
+	resetCheckTLSFlags()
+	srv, metadataFile, _ := checkTLSSetup(t)
+
+	output, err := executeCommand(rootCmd, "check", "tls", "--metadata", metadataFile)
+	require.NoError(t, err)
+	assert.Contains(t, output, "TLS certificate of "+srv.URL+"/sso/post")
+	assert.Equal(t, 1, strings.Count(output, "TLS certificate of "), "one check per host")
+	assert.Contains(t, output, "✓ Distinct from the 1 SAML certificate(s)")
+}
+
+func TestCheckTLSCmd_ConfiguredTLSCertificate(t *testing.T) {
+	resetCheckTLSFlags()
+	srv, metadataFile, tlsCertFile := checkTLSSetup(t)
+
+	output, err := executeCommand(rootCmd, "check", "tls", "--metadata", metadataFile, "--idp-cert", tlsCertFile, "-o", "json")
+	require.Error(t, err)
+	assert.Equal(t, ExitFindings, ExitCode(err))
+
+	var results []probe.TLSComparison
+	require.NoError(t, json.NewDecoder(strings.NewReader(output)).Decode(&results))
+	require.Len(t, results, 1)
+	assert.Equal(t, srv.URL+"/sso/post", results[0].URL)
+	require.Len(t, results[0].Problems, 1)
+	assert.Contains(t, results[0].Problems[0], "the certificate in --idp-cert is the TLS certificate of "+strings.TrimPrefix(srv.URL, "https://"))
+	assert.Contains(t, results[0].Problems[0], "not the SAML signing certificate, which is idp-signing")
+}
+
+func TestCheckTLSCmd_NoCertificates(t *testing.T) {
+	resetCheckTLSFlags()
+
+	_, err := executeCommand(rootCmd, "check", "tls", "--url", "https://idp.example.com/sso")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no SAML certificates to compare")
+}
@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/gliwka/SAMLurai/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var completionCmd = &cobra.Command{
+	Use:   "completion [bash|zsh|fish|powershell]",
+	Short: "Generate a shell completion script",
+	Long: `Generate a completion script for samlurai for the given shell. Besides
+commands and flags, it completes -o format values, --profile names from the
+config file, and file flags with the file types they expect, e.g. .pem files
+for --key.
+
+Examples:
+  # Bash, for the current shell
+  source <(samlurai completion bash)
+
+  # Bash, for every new shell (Linux)
+  samlurai completion bash > /etc/bash_completion.d/samlurai
+
+  # Zsh (compinit must be enabled)
+  samlurai completion zsh > "${fpath[1]}/_samlurai"
+
+  # Fish
+  samlurai completion fish > ~/.config/fish/completions/samlurai.fish
+
+  # PowerShell
+  samlurai completion powershell | Out-String | Invoke-Expression`,
+	DisableFlagsInUseLine: true,
+	ValidArgs:             []string{"bash", "zsh", "fish", "powershell"},
+	Args:                  cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+	RunE:                  runCompletion,
+}
+
+// outputFormats are the -o values, with descriptions for shells that
+// show them
+var outputFormats = []string{
+	"pretty\tHuman-readable, colored output",
+	"json\tJSON",
+	"psobject\tJSON for PowerShell's ConvertFrom-Json",
+	"jsonl\tOne JSON object per message",
+	"xml\tFormatted XML",
+	"csv\tOne row per attribute value",
+	"tsv\tOne tab-separated row per attribute value",
+	"otlp-trace\tOpenTelemetry trace of a HAR capture",
+	"dot\tGraphviz graph (graph only)",
+	"junit\tJUnit XML report (validate only)",
+	"sarif\tSARIF 2.1.0 log (validate only)",
+}
+
+// fileFlagExtensions are the file extensions completed for flags naming
+// files; an empty list completes any file
+var fileFlagExtensions = map[string][]string{
+	"file":            nil,
+	"key":             {"pem", "key"},
+	"cert":            {"pem", "crt", "cer", "der"},
+	"metadata-cert":   {"pem", "crt", "cer", "der"},
+	"sign-key":        {"pem", "key"},
+	"sign-cert":       {"pem", "crt", "cer", "der"},
+	"encryption-cert": {"pem", "crt", "cer", "der"},
+	"idp-cert":        {"pem", "crt", "cer", "der"},
+	"idp-metadata":    {"xml"},
+	"metadata":        {"xml"},
+	"key-map":         {"yaml", "yml"},
+	"spec":            {"yaml", "yml"},
+	"config":          {"yaml", "yml"},
+	"report":          {"html"},
+	"zip":             {"zip"},
+}
+
+// dirFlags are the flags naming directories
+var dirFlags = map[string]bool{
+	"dir":     true,
+	"out-dir": true,
+}
+
+var registerCompletionsOnce sync.Once
+
+func init() {
+	rootCmd.CompletionOptions.DisableDefaultCmd = true
+	rootCmd.AddCommand(completionCmd)
+}
+
+// registerCompletions registers the completion of flag values, including
+// the file and directory flags of every command. It runs once all commands
+// and flags are added.
+func registerCompletions() {
+	registerCompletionsOnce.Do(func() {
+		_ = rootCmd.RegisterFlagCompletionFunc("output", completeOutputFormat)
+		_ = rootCmd.RegisterFlagCompletionFunc("profile", completeProfile)
+		registerFileCompletions(rootCmd)
+	})
+}
+
+func registerFileCompletions(cmd *cobra.Command) {
+	for name, extensions := range fileFlagExtensions {
+		if cmd.LocalNonPersistentFlags().Lookup(name) != nil {
+			_ = cmd.MarkFlagFilename(name, extensions...)
+		}
+	}
+	for name := range dirFlags {
+		if cmd.LocalNonPersistentFlags().Lookup(name) != nil {
+			_ = cmd.MarkFlagDirname(name)
+		}
+	}
+	for _, child := range cmd.Commands() {
+		registerFileCompletions(child)
+	}
+}
+
+// completeOutputFormat completes the -o flag
+func completeOutputFormat(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return outputFormats, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeProfile completes the --profile flag with the profiles of the
+// config file
+func completeProfile(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	path, err := configPath()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	names := make([]string, 0, len(cfg.Profiles))
+	for name := range cfg.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+func runCompletion(cmd *cobra.Command, args []string) error {
+	out := cmd.OutOrStdout()
+	switch args[0] {
+	case "bash":
+		return cmd.Root().GenBashCompletionV2(out, true)
+	case "zsh":
+		return cmd.Root().GenZshCompletion(out)
+	case "fish":
+		return cmd.Root().GenFishCompletion(out, true)
+	case "powershell":
+		return cmd.Root().GenPowerShellCompletionWithDesc(out)
+	}
+	return fmt.Errorf("unsupported shell %q", args[0])
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompletionCmd(t *testing.T) {
+	for shell, marker := range map[string]string{
+		"bash":       "__start_samlurai",
+		"zsh":        "#compdef samlurai",
+		"fish":       "complete -c samlurai",
+		"powershell": "Register-ArgumentCompleter",
+	} {
+		output, err := executeCommand(rootCmd, "completion", shell)
+		require.NoError(t, err, shell)
+		assert.Contains(t, output, marker, shell)
+	}
+
+	_, err := executeCommand(rootCmd, "completion", "tcsh")
+	assert.Error(t, err)
+}
+
+func TestCompletion_Flags(t *testing.T) {
+	registerCompletions()
+
+	output, err := executeCommand(rootCmd, "__complete", "inspect", "-o", "")
+	require.NoError(t, err)
+	assert.Contains(t, output, "jsonl\tOne JSON object per message")
+	assert.Contains(t, output, ":4\n") // no file completion
+
+	// File flags complete the file types they expect
+	output, err = executeCommand(rootCmd, "__complete", "inspect", "--key", "")
+	require.NoError(t, err)
+	assert.Contains(t, output, "pem\nkey\n:8\n")
+
+	output, err = executeCommand(rootCmd, "__complete", "stats", "--dir", "")
+	require.NoError(t, err)
+	assert.Contains(t, output, ":16\n")
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("profiles:\n  staging: {}\n  prod: {}\n"), 0o600))
+	t.Setenv(configEnv, path)
+	output, err = executeCommand(rootCmd, "__complete", "inspect", "--profile", "")
+	require.NoError(t, err)
+	assert.Contains(t, output, "prod\nstaging\n:4\n")
+}
@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/gliwka/SAMLurai/internal/corpus"
+	"github.com/gliwka/SAMLurai/internal/output"
+	"github.com/gliwka/SAMLurai/internal/redact"
+	"github.com/spf13/cobra"
+)
+
+var (
+	corpusDir  string
+	corpusKeep []string
+)
+
+var corpusCmd = &cobra.Command{
+	Use:   "corpus",
+	Short: "Manage the fuzz corpus of the parser",
+	Long: `Manage the fuzz corpus the decode, parse and HAR extraction fuzz targets
+start from. go test runs every sample in it, so a message that once broke
+the parser stays covered.`,
+}
+
+var corpusAddCmd = &cobra.Command{
+	Use:   "add FILE...",
+	Short: "Add redacted real-world samples to the fuzz corpus",
+	Long: `Redact SAML messages and HAR captures and store them in the fuzz corpus,
+in the file format of go test.
+
+A message is stored decoded for FuzzParse and base64-encoded for
+FuzzSmartDecode. From a HAR file, SAML-tracer export or Fiddler session
+archive, every SAML message is stored like that, and a HAR file holding
+only those messages, with their binding and the scheme, host and path of
+their URL, is stored for FuzzExtractFromHAR. Headers, cookies and other
+requests are dropped.
+
+Values are masked as by redact. Run from the root of the repository or
+pass --dir.
+
+Examples:
+  # Keep a response that broke the parser as a fixture
+  samlurai corpus add response.xml
+
+  # Add the SAML messages of a captured login
+  samlurai corpus add login.har
+
+  # Then fuzz from the new samples
+  go test ./internal/saml -run '^$' -fuzz FuzzParse -fuzztime 1m`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runCorpusAdd,
+}
+
+func init() {
+	rootCmd.AddCommand(corpusCmd)
+	corpusCmd.AddCommand(corpusAddCmd)
+
+	corpusAddCmd.Flags().StringVar(&corpusDir, "dir", corpus.DefaultDir, "Fuzz corpus directory")
+	corpusAddCmd.Flags().StringSliceVar(&corpusKeep, "keep-attribute", nil, keepAttributeUsage)
+}
+
+func runCorpusAdd(cmd *cobra.Command, args []string) error {
+	redactor := redact.New(corpusKeep)
+	var added []corpus.Added
+	for _, path := range args {
+		data, err := readInputFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		samples, err := corpus.Samples(path, string(data), redactor)
+		if err != nil {
+			return withExitCode(ExitParse, fmt.Errorf("%s: %w", path, err))
+		}
+		written, err := corpus.Write(corpusDir, samples)
+		added = append(added, written...)
+		if err != nil {
+			return err
+		}
+	}
+
+	if output.NewFormatter(outputFormat).IsJSON() {
+		formatted, err := output.NewFormatter(outputFormat).FormatJSON(added)
+		if err != nil {
+			return fmt.Errorf("failed to format output: %w", err)
+		}
+		fmt.Fprint(cmd.OutOrStdout(), formatted)
+		return nil
+	}
+
+	w := cmd.OutOrStdout()
+	for _, a := range added {
+		if a.Existed {
+			fmt.Fprintf(w, "Exists %s\n", a.Path)
+		} else {
+			fmt.Fprintf(w, "Added  %s\n", a.Path)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gliwka/SAMLurai/internal/corpus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func resetCorpusFlags() {
+	corpusDir = corpus.DefaultDir
+	corpusKeep = nil
+	outputFormat = "pretty"
+}
+
+func TestCorpusAddCmd(t *testing.T) {
+	resetCorpusFlags()
+	defer resetCorpusFlags()
+	dir := t.TempDir()
+
+	output, err := executeCommand(rootCmd, "corpus", "add", "--dir", dir, "../testdata/fixtures/assertions/response.xml")
+	require.NoError(t, err)
+	assert.Contains(t, output, "Added  "+filepath.Join(dir, corpus.TargetParse))
+	assert.Contains(t, output, "Added  "+filepath.Join(dir, corpus.TargetSmartDecode))
+
+	paths, err := filepath.Glob(filepath.Join(dir, corpus.TargetParse, "*"))
+	require.NoError(t, err)
+	require.Len(t, paths, 1)
+	data, err := os.ReadFile(paths[0])
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "user@example.com")
+
+	// Adding the same file again adds nothing
+	output, err = executeCommand(rootCmd, "corpus", "add", "--dir", dir, "../testdata/fixtures/assertions/response.xml", "-o", "json")
+	require.NoError(t, err)
+	var added []corpus.Added
+	require.NoError(t, json.Unmarshal([]byte(output), &added))
+	require.Len(t, added, 2)
+	assert.True(t, added[0].Existed)
+	assert.Equal(t, paths[0], added[0].Path)
+}
+
+func TestCorpusAddCmd_HAR(t *testing.T) {
+	resetCorpusFlags()
+	defer resetCorpusFlags()
+	dir := t.TempDir()
+	harFile := createTempFile(t, checkFlowHAR("302"))
+	defer os.Remove(harFile)
+
+	output, err := executeCommand(rootCmd, "corpus", "add", "--dir", dir, harFile)
+	require.NoError(t, err)
+	assert.Contains(t, output, filepath.Join(dir, corpus.TargetExtractFromHAR))
+}
+
+func TestCorpusAddCmd_NoSAML(t *testing.T) {
+	resetCorpusFlags()
+	defer resetCorpusFlags()
+	file := createTempFile(t, "not SAML")
+	defer os.Remove(file)
+
+	_, err := executeCommand(rootCmd, "corpus", "add", "--dir", t.TempDir(), file)
+	require.Error(t, err)
+	assert.Equal(t, ExitParse, ExitCode(err))
+}
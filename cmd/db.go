@@ -0,0 +1,180 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gliwka/SAMLurai/internal/output"
+	"github.com/gliwka/SAMLurai/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var (
+	dbStore  string
+	dbIssuer string
+	dbType   string
+	dbNameID string
+	dbSince  string
+	dbUntil  string
+	dbLimit  int
+)
+
+var dbCmd = &cobra.Command{
+	Use:   "db",
+	Short: "Query messages saved by serve --persist",
+	Long: `Query the SAML messages saved by "samlurai serve --persist" or
+"serve --store", e.g. to analyze everything captured during a test window.
+
+Stores are given as a path, or as backend:location to select a storage
+backend; a path alone is a SQLite database. Without --store the default
+store in the user cache directory is used. Available backends: ` + strings.Join(store.Backends(), ", ") + `.`,
+}
+
+var dbListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List stored messages",
+	Long: `List stored messages, oldest first. --issuer, --type and --nameid
+match case-insensitive substrings. --since and --until take an RFC 3339 time
+or a duration before now, such as 2h.
+
+Examples:
+  # Everything issued by an IdP in the last two hours
+  samlurai db list --issuer idp.example.com --since 2h
+
+  # The last 10 responses as JSON
+  samlurai db list --type Response --limit 10 -o json`,
+	RunE: runDBList,
+}
+
+var dbShowCmd = &cobra.Command{
+	Use:   "show ID",
+	Short: "Show a stored message",
+	Long: `Show a stored message in the selected output format; -o xml prints the
+decoded XML.
+
+Examples:
+  samlurai db show 42
+  samlurai db show 42 -o xml`,
+	Args: cobra.ExactArgs(1),
+	RunE: runDBShow,
+}
+
+func init() {
+	rootCmd.AddCommand(dbCmd)
+	dbCmd.AddCommand(dbListCmd, dbShowCmd)
+
+	dbCmd.PersistentFlags().StringVar(&dbStore, "store", "", "Store to query (path or backend:location; default: the serve --persist store)")
+
+	dbListCmd.Flags().StringVar(&dbIssuer, "issuer", "", "Only messages whose issuer contains this")
+	dbListCmd.Flags().StringVar(&dbType, "type", "", "Only messages of this type, e.g. Response")
+	dbListCmd.Flags().StringVar(&dbNameID, "nameid", "", "Only messages whose NameID contains this")
+	dbListCmd.Flags().StringVar(&dbSince, "since", "", "Only messages captured at or after this time or duration ago")
+	dbListCmd.Flags().StringVar(&dbUntil, "until", "", "Only messages captured before this time or duration ago")
+	dbListCmd.Flags().IntVar(&dbLimit, "limit", 0, "Only the most recent N messages")
+}
+
+func runDBList(cmd *cobra.Command, args []string) error {
+	filter := store.Filter{Issuer: dbIssuer, Type: dbType, NameID: dbNameID, Limit: dbLimit}
+	var err error
+	if filter.Since, err = parseSince("--since", dbSince); err != nil {
+		return err
+	}
+	if filter.Until, err = parseSince("--until", dbUntil); err != nil {
+		return err
+	}
+
+	st, err := store.Open(dbStore)
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	records, err := st.List(cmd.Context(), filter)
+	if err != nil {
+		return err
+	}
+
+	formatter := output.NewFormatter(outputFormat)
+	if formatter.IsJSON() {
+		if records == nil {
+			records = []store.Record{}
+		}
+		formatted, err := formatter.FormatJSON(records)
+		if err != nil {
+			return fmt.Errorf("failed to format output: %w", err)
+		}
+		fmt.Fprint(cmd.OutOrStdout(), formatted)
+		return nil
+	}
+
+	if len(records) == 0 {
+		fmt.Fprintln(cmd.OutOrStdout(), "No stored messages match.")
+		return nil
+	}
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tCAPTURED\tTYPE\tISSUER\tNAMEID")
+	for _, r := range records {
+		typ := r.Type
+		if r.Error != "" {
+			typ += " (error)"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", r.ID, r.CapturedAt.Local().Format(time.RFC3339), typ, r.Issuer, r.NameID)
+	}
+	return w.Flush()
+}
+
+func runDBShow(cmd *cobra.Command, args []string) error {
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid ID %q", args[0])
+	}
+
+	st, err := store.Open(dbStore)
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	r, err := st.Get(cmd.Context(), id)
+	if err != nil {
+		return err
+	}
+
+	formatter := output.NewFormatter(outputFormat)
+	switch {
+	case r.XML == "" && r.Info == nil:
+	case outputFormat == "xml" || r.Info == nil:
+		formatted, err := formatter.FormatXML([]byte(r.XML))
+		if err != nil {
+			return fmt.Errorf("failed to format output: %w", err)
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), formatted)
+	default:
+		formatted, err := formatter.WithReferenceTime(r.CapturedAt, "capture").FormatSAMLInfo(r.Info)
+		if err != nil {
+			return fmt.Errorf("failed to format output: %w", err)
+		}
+		fmt.Fprint(cmd.OutOrStdout(), formatted)
+	}
+	if r.Error != "" {
+		notef(cmd, "⚠️  %s\n", r.Error)
+	}
+	return nil
+}
+
+// parseSince parses an RFC 3339 time or a duration before now
+func parseSince(flag, value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid %s %q: expected an RFC 3339 time or a duration such as 2h", flag, value)
+}
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/gliwka/SAMLurai/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func resetDBFlags() {
+	dbStore = ""
+	dbIssuer = ""
+	dbType = ""
+	dbNameID = ""
+	dbSince = ""
+	dbUntil = ""
+	dbLimit = 0
+	outputFormat = "pretty"
+}
+
+// testStore creates a store with a request and a response
+func testStore(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "captures.db")
+	st, err := store.OpenSQLite(path)
+	require.NoError(t, err)
+	defer st.Close()
+
+	captured := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	request := &saml.SAMLInfo{Type: "AuthnRequest", Issuer: "https://sp.example.com"}
+	response := &saml.SAMLInfo{
+		Type:    "Response",
+		Issuer:  "https://idp.example.com",
+		Subject: &saml.Subject{NameID: "alice@example.com"},
+	}
+	require.NoError(t, st.Save(context.Background(), []store.Record{
+		store.NewRecord(request, []byte(`<samlp:AuthnRequest xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_req"/>`), captured),
+		store.NewRecord(response, []byte(`<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_resp"/>`), captured.Add(time.Minute)),
+	}))
+	return path
+}
+
+func TestDBListCmd(t *testing.T) {
+	resetDBFlags()
+	defer resetDBFlags()
+	path := testStore(t)
+
+	output, err := executeCommand(rootCmd, "db", "list", "--store", path)
+	require.NoError(t, err)
+	assert.Contains(t, output, "ID  CAPTURED")
+	assert.Contains(t, output, "AuthnRequest")
+	assert.Contains(t, output, "alice@example.com")
+
+	output, err = executeCommand(rootCmd, "db", "list", "--store", path, "--issuer", "idp.example.com", "-o", "json")
+	require.NoError(t, err)
+	assert.Contains(t, output, `"name_id": "alice@example.com"`)
+	assert.NotContains(t, output, "AuthnRequest")
+
+	resetDBFlags()
+	output, err = executeCommand(rootCmd, "db", "list", "--store", path, "--since", "1h")
+	require.NoError(t, err)
+	assert.Contains(t, output, "No stored messages match.")
+
+	_, err = executeCommand(rootCmd, "db", "list", "--store", path, "--since", "last week")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid --since "last week"`)
+}
+
+func TestDBShowCmd(t *testing.T) {
+	resetDBFlags()
+	defer resetDBFlags()
+	path := testStore(t)
+
+	output, err := executeCommand(rootCmd, "db", "show", "2", "--store", path)
+	require.NoError(t, err)
+	assert.Contains(t, output, "alice@example.com")
+
+	output, err = executeCommand(rootCmd, "db", "show", "1", "--store", path, "-o", "xml")
+	require.NoError(t, err)
+	assert.Contains(t, output, `ID="_req"`)
+
+	_, err = executeCommand(rootCmd, "db", "show", "7", "--store", path)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "record not found: 7")
+}
@@ -2,17 +2,17 @@ package cmd
 
 import (
 	"fmt"
-	"io"
 	"os"
 	"strings"
 
+	"github.com/gliwka/SAMLurai/internal/clipboard"
 	"github.com/gliwka/SAMLurai/internal/output"
 	"github.com/gliwka/SAMLurai/internal/saml"
 	"github.com/spf13/cobra"
 )
 
 var (
-	decodeFile    string
+	decodeFiles   []string
 	decodeDeflate bool
 )
 
@@ -26,6 +26,10 @@ The input can be provided as:
   - From a file using the -f flag
   - From stdin (pipe)
 
+The -f flag can be repeated to decode several files, each under a header
+naming it. A file that fails does not stop the others; the exit code is
+that of the first failure.
+
 For SAML requests using HTTP-Redirect binding, use the --deflate flag
 to decompress the deflated content.
 
@@ -40,25 +44,43 @@ Examples:
   echo "PHNhbWxwOlJlc3BvbnNl..." | samlurai decode
 
   # Decode with deflate decompression
-  samlurai decode --deflate -f request.txt`,
+  samlurai decode --deflate -f request.txt
+
+  # Decode several files
+  samlurai decode -f first.txt -f second.txt`,
 	RunE: runDecode,
 }
 
 func init() {
 	rootCmd.AddCommand(decodeCmd)
 
-	decodeCmd.Flags().StringVarP(&decodeFile, "file", "f", "", "Read base64-encoded SAML from file")
+	decodeCmd.Flags().StringArrayVarP(&decodeFiles, "file", "f", nil, "Read base64-encoded SAML from file (repeatable)")
 	decodeCmd.Flags().BoolVar(&decodeDeflate, "deflate", false, "Apply deflate decompression (for HTTP-Redirect binding)")
 }
 
 func runDecode(cmd *cobra.Command, args []string) error {
+	if len(decodeFiles) > 1 {
+		return forEachFile(cmd, decodeFiles, outputFormat == "pretty", func(file string) error {
+			input, err := readDecodeFile(file)
+			if err != nil {
+				return err
+			}
+			return decodeInput(cmd, input)
+		})
+	}
+
 	input, err := getDecodeInput(cmd, args)
 	if err != nil {
 		return err
 	}
+	return decodeInput(cmd, input)
+}
 
+// decodeInput decodes base64-encoded SAML and writes it formatted
+func decodeInput(cmd *cobra.Command, input string) error {
 	decoder := saml.NewDecoder()
 	var decoded []byte
+	var err error
 
 	if decodeDeflate {
 		decoded, err = decoder.DecodeDeflate(input)
@@ -67,10 +89,10 @@ func runDecode(cmd *cobra.Command, args []string) error {
 	}
 
 	if err != nil {
-		return fmt.Errorf("failed to decode SAML: %w", err)
+		return withExitCode(ExitParse, fmt.Errorf("failed to decode SAML: %w", err))
 	}
 
-	formatter := output.NewFormatter(outputFormat)
+	formatter := output.NewFormatterWithOptions(outputFormat, !colorEnabled(cmd.OutOrStdout())).WithSyntaxHighlight(true)
 	formatted, err := formatter.FormatXML(decoded)
 	if err != nil {
 		return fmt.Errorf("failed to format output: %w", err)
@@ -82,27 +104,35 @@ func runDecode(cmd *cobra.Command, args []string) error {
 
 func getDecodeInput(cmd *cobra.Command, args []string) (string, error) {
 	// Priority: file flag > argument > stdin
-	if decodeFile != "" {
-		data, err := os.ReadFile(decodeFile)
-		if err != nil {
-			return "", fmt.Errorf("failed to read file: %w", err)
-		}
-		return strings.TrimSpace(string(data)), nil
+	if len(decodeFiles) > 0 {
+		return readDecodeFile(decodeFiles[0])
 	}
 
 	if len(args) > 0 {
 		return strings.TrimSpace(args[0]), nil
 	}
 
+	if inputFromClipboard {
+		return clipboard.ReadText()
+	}
+
 	// Check if stdin has data
 	stat, _ := os.Stdin.Stat()
 	if (stat.Mode() & os.ModeCharDevice) == 0 {
-		data, err := io.ReadAll(os.Stdin)
+		data, err := readInput(os.Stdin)
 		if err != nil {
 			return "", fmt.Errorf("failed to read stdin: %w", err)
 		}
 		return strings.TrimSpace(string(data)), nil
 	}
 
-	return "", fmt.Errorf("no input provided. Use -f flag, provide an argument, or pipe data to stdin")
+	return "", fmt.Errorf("no input provided. Use -f flag, provide an argument, --clipboard, or pipe data to stdin")
+}
+
+func readDecodeFile(file string) (string, error) {
+	data, err := readInputFile(file)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
 }
@@ -5,6 +5,7 @@ import (
 	"encoding/base64"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
 
@@ -133,9 +134,32 @@ func createTempFile(t *testing.T, content string) string {
 }
 
 func resetDecodeFlags() {
-	decodeFile = ""
+	decodeFiles = nil
 	decodeDeflate = false
+	inputFromClipboard = false
 	outputFormat = "pretty"
+	// --help sticks once given, like any other flag
+	if help := decodeCmd.Flags().Lookup("help"); help != nil {
+		_ = help.Value.Set("false")
+	}
+}
+
+func TestDecodeCmd_Clipboard(t *testing.T) {
+	if runtime.GOOS == "windows" || runtime.GOOS == "darwin" {
+		t.Skip("fake clipboard tools are only wired up for Linux/BSD")
+	}
+	resetDecodeFlags()
+	defer resetDecodeFlags()
+
+	encoded := base64.StdEncoding.EncodeToString([]byte(`<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_clip"/>`))
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "xclip"), []byte("#!/bin/sh\nprintf '"+encoded+"'\n"), 0755))
+	t.Setenv("PATH", dir)
+	t.Setenv("WAYLAND_DISPLAY", "")
+
+	output, err := executeCommand(rootCmd, "decode", "--clipboard", "-o", "xml")
+	require.NoError(t, err)
+	assert.Contains(t, output, `ID="_clip"`)
 }
 
 func TestDecodeCmd_WithWhitespace(t *testing.T) {
@@ -200,3 +224,37 @@ func TestDecodeCmd_MultipleOutputFormats(t *testing.T) {
 		})
 	}
 }
+
+func TestDecodeCmd_MultipleFiles(t *testing.T) {
+	resetDecodeFlags()
+	defer resetDecodeFlags()
+
+	first := createTempFile(t, base64.StdEncoding.EncodeToString([]byte(`<saml>first</saml>`)))
+	defer os.Remove(first)
+	second := createTempFile(t, base64.StdEncoding.EncodeToString([]byte(`<saml>second</saml>`)))
+	defer os.Remove(second)
+
+	output, err := executeCommand(rootCmd, "decode", "-f", first, "-f", second)
+	require.NoError(t, err)
+	assert.Contains(t, output, "▶ [1/2] "+first)
+	assert.Contains(t, output, "▶ [2/2] "+second)
+	assert.Less(t, strings.Index(output, "first"), strings.Index(output, "second"))
+
+	decodeFiles = nil
+	_, err = executeCommand(rootCmd, "decode", "-f", filepath.Join(t.TempDir(), "missing.txt"), "-f", first)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "1 of 2 files failed")
+}
+
+func TestDecodeCmd_GzippedFile(t *testing.T) {
+	resetDecodeFlags()
+	defer resetDecodeFlags()
+
+	encoded := base64.StdEncoding.EncodeToString([]byte(`<saml>compressed</saml>`))
+	path := filepath.Join(t.TempDir(), "response.txt.gz")
+	require.NoError(t, os.WriteFile(path, gzipData(t, encoded), 0o644))
+
+	output, err := executeCommand(rootCmd, "decode", "-f", path)
+	require.NoError(t, err)
+	assert.Contains(t, output, "compressed")
+}
@@ -6,6 +6,8 @@ import (
 	"os"
 	"strings"
 
+	"github.com/gliwka/SAMLurai/internal/clipboard"
+	"github.com/gliwka/SAMLurai/internal/hsm"
 	"github.com/gliwka/SAMLurai/internal/output"
 	"github.com/gliwka/SAMLurai/internal/saml"
 	"github.com/spf13/cobra"
@@ -14,6 +16,9 @@ import (
 var (
 	decryptFile    string
 	decryptKeyFile string
+	decryptKeyEnv  string
+	decryptPKCS11  hsm.Config
+	decryptKMSKey  string
 )
 
 var decryptCmd = &cobra.Command{
@@ -21,11 +26,25 @@ var decryptCmd = &cobra.Command{
 	Short: "Decrypt an encrypted SAML assertion",
 	Long: `Decrypt an encrypted SAML assertion using a private key.
 
+Encrypted NameIDs (EncryptedID) and attributes (EncryptedAttribute) are
+decrypted in place, both inside the decrypted assertion and in messages
+without an encrypted assertion, such as a LogoutRequest.
+
 The encrypted assertion can be provided from:
   - A file using the -f flag
   - From stdin (pipe)
 
-The private key must be in PEM format.
+The private key must be in PEM format. It is read from a file (-k), from
+stdin (-k -, with the input given by -f) or from an environment variable
+(--key-env), which may also hold the base64-encoded PEM.
+
+Keys on an HSM or smartcard are used through PKCS#11 (--pkcs11-module,
+with OpenSC's pkcs11-tool installed). Only the session key of each
+assertion is decrypted on the token; the private key never leaves it.
+
+Keys in a cloud KMS are referenced with --kms-key: an AWS KMS key ARN, a
+GCP Cloud KMS key version or an Azure Key Vault key URI. Session keys are
+decrypted through the aws, gcloud or az CLI, using its current login.
 
 If the input is base64-encoded (with optional deflate compression),
 it will be automatically decoded before decryption.
@@ -40,6 +59,18 @@ Examples:
   # Decrypt base64-encoded input (auto-detected)
   echo "PHNhbWw6RW5jcnlwdGVkQXNzZXJ0aW9uPi4uLg==" | samlurai decrypt -k private.pem
 
+  # Read the key from a CI secret
+  samlurai decrypt --key-env SP_PRIVATE_KEY -f encrypted.xml
+
+  # Read the key from a mounted secret on stdin
+  kubectl get secret sp-key -o jsonpath='{.data.tls\.key}' | base64 -d | samlurai decrypt -k - -f encrypted.xml
+
+  # Decrypt with a key that never leaves the HSM
+  SAMLURAI_PKCS11_PIN=1234 samlurai decrypt --pkcs11-module /usr/lib/softhsm/libsofthsm2.so --pkcs11-slot 0 --pkcs11-key-label sp-encryption -f encrypted.xml
+
+  # Decrypt with the SP key in AWS KMS
+  samlurai decrypt --kms-key arn:aws:kms:eu-central-1:111122223333:key/1234abcd-12ab-34cd-56ef-1234567890ab -f encrypted.xml
+
   # Output as JSON
   samlurai decrypt -k private.pem -f encrypted.xml -o json`,
 	RunE: runDecrypt,
@@ -49,11 +80,27 @@ func init() {
 	rootCmd.AddCommand(decryptCmd)
 
 	decryptCmd.Flags().StringVarP(&decryptFile, "file", "f", "", "Read encrypted SAML from file")
-	decryptCmd.Flags().StringVarP(&decryptKeyFile, "key", "k", "", "Path to private key (PEM format)")
-	_ = decryptCmd.MarkFlagRequired("key")
+	decryptCmd.Flags().StringVarP(&decryptKeyFile, "key", "k", "", "Path to private key (PEM format), or - to read it from stdin")
+	decryptCmd.Flags().StringVar(&decryptKeyEnv, "key-env", "", "Environment variable holding the private key (PEM or base64-encoded PEM)")
+	addPKCS11Flags(decryptCmd.Flags(), &decryptPKCS11)
+	decryptCmd.Flags().StringVar(&decryptKMSKey, "kms-key", "", kmsKeyUsage)
 }
 
 func runDecrypt(cmd *cobra.Command, args []string) error {
+	keys := keySource{path: decryptKeyFile, env: decryptKeyEnv, pkcs11: decryptPKCS11, kms: decryptKMSKey, inputFile: decryptFile}
+	if !keys.set() {
+		return fmt.Errorf("a private key is required: use --key, --key-env, --pkcs11-module or --kms-key")
+	}
+	if err := keys.validate(); err != nil {
+		return err
+	}
+
+	// A key on stdin must be read before the input is looked for there
+	decryptor, err := keys.load(cmd)
+	if err != nil {
+		return withExitCode(ExitDecrypt, fmt.Errorf("failed to load private key: %w", err))
+	}
+
 	input, err := getDecryptInput(cmd)
 	if err != nil {
 		return err
@@ -63,20 +110,15 @@ func runDecrypt(cmd *cobra.Command, args []string) error {
 	decoder := saml.NewDecoder()
 	xmlData, err := decoder.SmartDecode(input)
 	if err != nil {
-		return fmt.Errorf("failed to decode input: %w", err)
-	}
-
-	decryptor, err := saml.NewDecryptor(decryptKeyFile)
-	if err != nil {
-		return fmt.Errorf("failed to load private key: %w", err)
+		return withExitCode(ExitParse, fmt.Errorf("failed to decode input: %w", err))
 	}
 
 	decrypted, err := decryptor.Decrypt(xmlData)
 	if err != nil {
-		return fmt.Errorf("failed to decrypt SAML assertion: %w", err)
+		return withExitCode(ExitDecrypt, fmt.Errorf("failed to decrypt SAML assertion: %w", err))
 	}
 
-	formatter := output.NewFormatter(outputFormat)
+	formatter := output.NewFormatterWithOptions(outputFormat, !colorEnabled(cmd.OutOrStdout())).WithSyntaxHighlight(true)
 	formatted, err := formatter.FormatXML(decrypted)
 	if err != nil {
 		return fmt.Errorf("failed to format output: %w", err)
@@ -95,6 +137,10 @@ func getDecryptInput(cmd *cobra.Command) (string, error) {
 		return strings.TrimSpace(string(data)), nil
 	}
 
+	if inputFromClipboard {
+		return clipboard.ReadText()
+	}
+
 	// Check if stdin has data
 	stat, _ := os.Stdin.Stat()
 	if (stat.Mode() & os.ModeCharDevice) == 0 {
@@ -105,5 +151,5 @@ func getDecryptInput(cmd *cobra.Command) (string, error) {
 		return strings.TrimSpace(string(data)), nil
 	}
 
-	return "", fmt.Errorf("no input provided. Use -f flag or pipe data to stdin")
+	return "", fmt.Errorf("no input provided. Use -f flag, --clipboard, or pipe data to stdin")
 }
@@ -5,10 +5,16 @@ import (
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
+	"math/big"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/beevik/etree"
+	"github.com/crewjam/saml/xmlenc"
+	"github.com/gliwka/SAMLurai/internal/hsm"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -74,6 +80,9 @@ func TestDecryptCmd_HelpText(t *testing.T) {
 func resetDecryptFlags() {
 	decryptFile = ""
 	decryptKeyFile = ""
+	decryptKeyEnv = ""
+	decryptPKCS11 = hsm.Config{}
+	decryptKMSKey = ""
 	outputFormat = "pretty"
 }
 
@@ -97,3 +106,101 @@ func createTestKeyFile(t *testing.T) string {
 
 	return keyPath
 }
+
+func TestDecryptCmd_KeyFromStdin(t *testing.T) {
+	resetDecryptFlags()
+	defer rootCmd.SetIn(nil)
+
+	pemData, err := os.ReadFile(createTestKeyFile(t))
+	require.NoError(t, err)
+	inputFile := createTempFile(t, "<EncryptedAssertion>test</EncryptedAssertion>")
+	defer os.Remove(inputFile)
+
+	// The key loads, so decryption of the bogus input is what fails
+	rootCmd.SetIn(strings.NewReader(string(pemData)))
+	_, err = executeCommand(rootCmd, "decrypt", "-k", "-", "-f", inputFile)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to decrypt SAML assertion")
+
+	resetDecryptFlags()
+	_, err = executeCommand(rootCmd, "decrypt", "-k", "-")
+	assert.EqualError(t, err, "--key - reads the key from stdin, so the input must be given with -f or --clipboard")
+}
+
+func TestDecryptCmd_KeyFromEnv(t *testing.T) {
+	resetDecryptFlags()
+
+	pemData, err := os.ReadFile(createTestKeyFile(t))
+	require.NoError(t, err)
+	t.Setenv("SAMLURAI_TEST_KEY", string(pemData))
+	inputFile := createTempFile(t, "<EncryptedAssertion>test</EncryptedAssertion>")
+	defer os.Remove(inputFile)
+
+	_, err = executeCommand(rootCmd, "decrypt", "--key-env", "SAMLURAI_TEST_KEY", "-f", inputFile)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to decrypt SAML assertion")
+
+	resetDecryptFlags()
+	_, err = executeCommand(rootCmd, "decrypt", "--key-env", "SAMLURAI_TEST_KEY", "-k", "key.pem", "-f", inputFile)
+	assert.EqualError(t, err, "only one of --key, --key-env, --pkcs11-module and --kms-key may be given")
+}
+
+func TestDecryptCmd_PKCS11(t *testing.T) {
+	resetDecryptFlags()
+
+	// A stand-in for OpenSC's pkcs11-tool that fails to log in
+	bin := t.TempDir()
+	script := "#!/bin/sh\necho 'Using slot 0'\necho 'error: PKCS11 function C_Login failed: rv = CKR_PIN_INCORRECT (0xa0)' >&2\nexit 1\n"
+	require.NoError(t, os.WriteFile(filepath.Join(bin, "pkcs11-tool"), []byte(script), 0755))
+	t.Setenv("PATH", bin)
+
+	inputFile := createTempFile(t, encryptTestAssertion(t))
+	defer os.Remove(inputFile)
+
+	_, err := executeCommand(rootCmd, "decrypt", "--pkcs11-module", "p11.so", "--pkcs11-pin", "0000", "-f", inputFile)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "pkcs11-tool: error: PKCS11 function C_Login failed: rv = CKR_PIN_INCORRECT (0xa0)")
+}
+
+// encryptTestAssertion returns an EncryptedAssertion for a throwaway key
+func encryptTestAssertion(t *testing.T) string {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	template := &x509.Certificate{SerialNumber: big.NewInt(1), NotAfter: time.Now().Add(time.Hour)}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	encryptedData, err := xmlenc.OAEP().Encrypt(cert, []byte(`<saml:Assertion xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_a"/>`), nil)
+	require.NoError(t, err)
+	doc := etree.NewDocument()
+	doc.CreateElement("saml:EncryptedAssertion").AddChild(encryptedData)
+	out, err := doc.WriteToString()
+	require.NoError(t, err)
+	return out
+}
+
+func TestDecryptCmd_KMS(t *testing.T) {
+	resetDecryptFlags()
+
+	// A stand-in for the AWS CLI without credentials
+	bin := t.TempDir()
+	script := "#!/bin/sh\necho 'Unable to locate credentials. You can configure credentials by running \"aws configure\".' >&2\nexit 255\n"
+	require.NoError(t, os.WriteFile(filepath.Join(bin, "aws"), []byte(script), 0755))
+	t.Setenv("PATH", bin)
+
+	inputFile := createTempFile(t, encryptTestAssertion(t))
+	defer os.Remove(inputFile)
+
+	_, err := executeCommand(rootCmd, "decrypt", "--kms-key", "arn:aws:kms:eu-central-1:111122223333:key/abcd", "-f", inputFile)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "aws: Unable to locate credentials")
+
+	resetDecryptFlags()
+	_, err = executeCommand(rootCmd, "decrypt", "--kms-key", "alias/sp-key", "-f", inputFile)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `failed to load private key: unrecognized KMS key "alias/sp-key"`)
+}
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/gliwka/SAMLurai/internal/inspect"
+	"github.com/spf13/cobra"
+)
+
+// dumpAttributes writes the full values of the attributes given as
+// NAME=FILE specs, one value per line. NAME matches the attribute name or
+// its friendly name; values from all messages are included.
+func dumpAttributes(cmd *cobra.Command, specs []string, messages []inspect.Message) error {
+	for _, spec := range specs {
+		name, path, ok := strings.Cut(spec, "=")
+		if !ok || name == "" || path == "" {
+			return fmt.Errorf("invalid --dump-attribute %q: expected NAME=FILE", spec)
+		}
+
+		var values []string
+		found := false
+		for _, msg := range messages {
+			for info := msg.Info; info != nil; info = info.Assertion {
+				for _, attr := range info.Attributes {
+					if attr.Name == name || attr.FriendlyName == name {
+						found = true
+						values = append(values, attr.Values...)
+					}
+				}
+			}
+		}
+		if !found {
+			return fmt.Errorf("attribute %q not found", name)
+		}
+
+		if err := os.WriteFile(path, []byte(strings.Join(values, "\n")+"\n"), 0644); err != nil {
+			return fmt.Errorf("failed to write attribute file: %w", err)
+		}
+		notef(cmd, "Wrote %d value(s) of %s to %s\n", len(values), name, path)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"errors"
+
+	"github.com/gliwka/SAMLurai/internal/inspect"
+	"github.com/gliwka/SAMLurai/internal/saml"
+)
+
+// Exit codes, so scripts and CI gates can tell failures apart
+const (
+	// ExitOK means the command succeeded
+	ExitOK = 0
+
+	// ExitUsage means invalid flags or arguments, or a failure without a
+	// more specific code, e.g. an unreadable file
+	ExitUsage = 1
+
+	// ExitParse means the input could not be decoded or parsed as SAML
+	ExitParse = 2
+
+	// ExitSignature means a signature did not verify
+	ExitSignature = 3
+
+	// ExitExpired means a message had expired when it was sent
+	ExitExpired = 4
+
+	// ExitDecrypt means the private key was missing or could not be
+	// loaded, or decryption failed
+	ExitDecrypt = 5
+
+	// ExitFindings means audit found other issues, check-flow found the
+	// flow diverged, flow diff found the captures diverge, check acs found
+	// an endpoint problem, check tls found TLS and SAML certificates
+	// confused, lint found conformance issues or a validate check failed
+	ExitFindings = 6
+)
+
+// exitError is an error with the exit code it should end the process with
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+// withExitCode attaches an exit code to err; nil stays nil
+func withExitCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &exitError{code: code, err: err}
+}
+
+// ExitCode returns the exit code for an error returned by Execute
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+	var exitErr *exitError
+	if errors.As(err, &exitErr) {
+		return exitErr.code
+	}
+	var inputErr *inspect.InputError
+	if errors.As(err, &inputErr) {
+		return ExitParse
+	}
+
+	// Errors of the saml package that no command attached a code to
+	var encryptedErr *saml.ErrEncrypted
+	if errors.Is(err, saml.ErrNoKey) || errors.As(err, &encryptedErr) {
+		return ExitDecrypt
+	}
+	var parseErr *saml.ErrParse
+	if errors.Is(err, saml.ErrNotSAML) || errors.Is(err, saml.ErrBase64) || errors.Is(err, saml.ErrDeflate) || errors.As(err, &parseErr) {
+		return ExitParse
+	}
+	return ExitUsage
+}
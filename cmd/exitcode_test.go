@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExitCode(t *testing.T) {
+	assert.Equal(t, ExitOK, ExitCode(nil))
+	assert.Equal(t, ExitUsage, ExitCode(errors.New("unknown flag: --nope")))
+	assert.Equal(t, ExitDecrypt, ExitCode(fmt.Errorf("inspect: %w", withExitCode(ExitDecrypt, errors.New("bad key")))))
+	assert.Nil(t, withExitCode(ExitParse, nil))
+
+	// Errors of the saml package have the code of their kind
+	assert.Equal(t, ExitParse, ExitCode(fmt.Errorf("decode: %w", saml.ErrBase64)))
+	assert.Equal(t, ExitParse, ExitCode(&saml.ErrParse{Stage: "XML", Err: errors.New("EOF")}))
+	assert.Equal(t, ExitDecrypt, ExitCode(saml.ErrNoKey))
+	assert.Equal(t, ExitDecrypt, ExitCode(&saml.ErrEncrypted{Err: errors.New("bad padding")}))
+}
+
+func TestExitCode_Commands(t *testing.T) {
+	resetInspectFlags()
+	defer resetInspectFlags()
+
+	encrypted := createTempFile(t, `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_enc"><saml:EncryptedAssertion><xenc:EncryptedData xmlns:xenc="http://www.w3.org/2001/04/xmlenc#"/></saml:EncryptedAssertion></samlp:Response>`)
+	for _, tt := range []struct {
+		name string
+		args []string
+		code int
+	}{
+		{"unknown flag", []string{"inspect", "--no-such-flag"}, ExitUsage},
+		{"undecodable", []string{"inspect", "-f", createTempFile(t, "%%% not base64 %%%")}, ExitParse},
+		{"unparsable", []string{"inspect", "-f", createTempFile(t, "<samlp:Response")}, ExitParse},
+		{"no key", []string{"inspect", "-f", encrypted}, ExitDecrypt},
+		{"missing key", []string{"inspect", "-f", encrypted, "-k", filepath.Join(t.TempDir(), "missing.pem")}, ExitDecrypt},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			resetInspectFlags()
+			_, err := executeCommand(rootCmd, tt.args...)
+			assert.Error(t, err)
+			assert.Equal(t, tt.code, ExitCode(err))
+		})
+	}
+}
+
+func TestUsageOnlyForFlagErrors(t *testing.T) {
+	resetInspectFlags()
+	defer resetInspectFlags()
+	resetAuditFlags()
+	defer resetAuditFlags()
+
+	output, err := executeCommand(rootCmd, "audit", "-f", "../testdata/fixtures/signed/onelogin_response.xml", "-o", "json")
+	assert.Equal(t, ExitFindings, ExitCode(err))
+	assert.NotContains(t, output, "Usage:")
+
+	_, err = executeCommand(rootCmd, "inspect", "--no-such-flag")
+	assert.EqualError(t, err, "unknown flag: --no-such-flag\nRun 'samlurai inspect --help' for usage")
+}
+
+func TestAuditExitCode(t *testing.T) {
+	report := func(checks ...string) auditReport {
+		r := auditResult{}
+		for _, check := range checks {
+			r.Findings = append(r.Findings, saml.AuditFinding{Check: check})
+		}
+		return auditReport{Messages: []auditResult{r}}
+	}
+
+	assert.Equal(t, ExitFindings, auditExitCode(report(saml.CheckMissingAudience)))
+	assert.Equal(t, ExitExpired, auditExitCode(report(saml.CheckMissingAudience, saml.CheckExpired)))
+	assert.Equal(t, ExitSignature, auditExitCode(report(saml.CheckExpired, saml.CheckCertMismatch)))
+}
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/gliwka/SAMLurai/internal/output"
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/spf13/cobra"
+)
+
+var explainList bool
+
+var explainCmd = &cobra.Command{
+	Use:   "explain [TERM]",
+	Short: "Explain SAML status codes, algorithms, NameID formats and bindings",
+	Long: `Explain a SAML URI in plain language: a status code, a signature or
+digest algorithm, a NameID format or a binding. TERM is the full URI or its
+short name, e.g. Responder, InvalidNameIDPolicy, rsa-sha256, persistent or
+HTTP-POST. Short names are matched case-insensitively.
+
+Examples:
+  samlurai explain Responder
+  samlurai explain urn:oasis:names:tc:SAML:2.0:status:NoPassive
+  samlurai explain http://www.w3.org/2000/09/xmldsig#rsa-sha1
+
+  # Every term explain knows
+  samlurai explain --list`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if explainList {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.ExactArgs(1)(cmd, args)
+	},
+	RunE: runExplain,
+}
+
+func init() {
+	rootCmd.AddCommand(explainCmd)
+	explainCmd.Flags().BoolVar(&explainList, "list", false, "List every term that can be explained")
+}
+
+func runExplain(cmd *cobra.Command, args []string) error {
+	var terms []saml.Term
+	if explainList {
+		terms = saml.Terms()
+	} else {
+		terms = saml.LookupTerm(args[0])
+		if len(terms) == 0 {
+			return fmt.Errorf("%q is not a known SAML status code, algorithm, NameID format or binding; see samlurai explain --list", args[0])
+		}
+	}
+
+	out := cmd.OutOrStdout()
+	formatter := output.NewFormatter(outputFormat)
+	if formatter.IsJSON() {
+		formatted, err := formatter.FormatJSON(terms)
+		if err != nil {
+			return fmt.Errorf("failed to format output: %w", err)
+		}
+		fmt.Fprint(out, formatted)
+		return nil
+	}
+
+	for i, term := range terms {
+		if explainList {
+			fmt.Fprintf(out, "%-22s %-26s %s\n", term.Kind, term.Name, term.URI)
+			continue
+		}
+		if i > 0 {
+			fmt.Fprintln(out)
+		}
+		printTerm(out, term)
+	}
+	return nil
+}
+
+// printTerm prints the explanation of a glossary term
+func printTerm(out io.Writer, term saml.Term) {
+	fmt.Fprintf(out, "%s (%s)\n", term.Name, term.Kind)
+	fmt.Fprintf(out, "%s\n\n", term.URI)
+	fmt.Fprintf(out, "%s\n", term.Description)
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func resetExplainFlags() {
+	explainList = false
+	outputFormat = "pretty"
+}
+
+func TestExplainCmd(t *testing.T) {
+	defer resetExplainFlags()
+
+	output, err := executeCommand(rootCmd, "explain", "NoPassive")
+	require.NoError(t, err)
+	assert.Contains(t, output, "NoPassive (status code)\nurn:oasis:names:tc:SAML:2.0:status:NoPassive\n")
+	assert.Contains(t, output, "IsPassive")
+
+	output, err = executeCommand(rootCmd, "explain", "http://www.w3.org/2000/09/xmldsig#rsa-sha1", "-o", "json")
+	require.NoError(t, err)
+	var terms []saml.Term
+	require.NoError(t, json.Unmarshal([]byte(output), &terms))
+	require.Len(t, terms, 1)
+	assert.Equal(t, "rsa-sha1", terms[0].Name)
+	assert.Equal(t, saml.TermSignature, terms[0].Kind)
+}
+
+func TestExplainCmd_List(t *testing.T) {
+	defer resetExplainFlags()
+
+	output, err := executeCommand(rootCmd, "explain", "--list")
+	require.NoError(t, err)
+	assert.Contains(t, output, "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect")
+	assert.Contains(t, output, "urn:oasis:names:tc:SAML:2.0:nameid-format:persistent")
+
+	_, err = executeCommand(rootCmd, "explain", "--list", "Responder")
+	assert.Error(t, err)
+}
+
+func TestExplainCmd_Unknown(t *testing.T) {
+	defer resetExplainFlags()
+
+	_, err := executeCommand(rootCmd, "explain", "nonsense")
+	assert.ErrorContains(t, err, `"nonsense" is not a known SAML status code`)
+}
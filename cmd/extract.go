@@ -1,10 +1,16 @@
 package cmd
 
 import (
+	"archive/zip"
+	"crypto/x509"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 
+	"github.com/gliwka/SAMLurai/internal/inspect"
 	"github.com/gliwka/SAMLurai/internal/output"
 	"github.com/gliwka/SAMLurai/internal/saml"
 	"github.com/spf13/cobra"
@@ -14,6 +20,25 @@ var (
 	extractFile      string
 	extractOutputDir string
 	extractList      bool
+	extractMinConf   float64
+	extractDedupe    bool
+	extractFilter    saml.MessageFilter
+	extractReport    string
+	extractLogFormat string
+	extractVerify    bool
+	extractCert      string
+	extractRefDir    string
+	extractRaw       bool
+	extractFormat    string
+	extractZip       string
+	extractRedact    redactOptions
+	extractSockets   bool
+)
+
+// Formats extracted messages can be saved in
+const (
+	extractFormatXML    = "xml"
+	extractFormatBase64 = "b64"
 )
 
 var extractCmd = &cobra.Command{
@@ -28,6 +53,22 @@ scans the HAR file for SAML assertions in:
   - URL query parameters (HTTP-Redirect binding)
   - HTML responses containing hidden form fields
 
+JSON exports of the SAML-tracer browser extension and Fiddler session
+archives (.saz) are read the same way.
+Gzip- and zstd-compressed files, e.g. session.har.gz, are decompressed
+while they are read.
+
+With --log-format, the file is read as a log instead, line by line:
+  combined  Apache/NGINX combined or common access log; SAML parameters
+            are taken from the request URL (HTTP-Redirect binding)
+  raw       SAMLRequest/SAMLResponse/SAMLart/wresult parameters anywhere
+            in a line (query strings, key: value pairs, JSON fields) or
+            bare base64-encoded messages, for application logs
+
+Files with an extension a plugin's extract capability reads, e.g. a
+proprietary proxy capture, are handed to the plugin, which converts them
+into a HAR; see samlurai plugins.
+
 Each extracted SAML assertion is saved to a separate file with a 
 descriptive name indicating its type and source.
 
@@ -42,109 +83,462 @@ Examples:
   samlurai extract -f session.har --list
 
   # Extract from Chrome DevTools HAR export
-  samlurai extract -f chrome_network.har -d ./saml_assertions`,
+  samlurai extract -f chrome_network.har -d ./saml_assertions
+
+  # Extract from a SAML-tracer export
+  samlurai extract -f saml-tracer.json --list
+
+  # Also write an HTML report of the session
+  samlurai extract -f session.har --report report.html
+
+  # Skip low-confidence matches (e.g. blind base64 decoding of bodies)
+  samlurai extract -f session.har --min-confidence 0.7
+
+  # Only extract the Responses of one IdP
+  samlurai extract -f session.har --type Response --issuer https://idp.example.com
+
+  # Collapse messages the browser recorded more than once
+  samlurai extract -f session.har --list --dedupe
+
+  # Extract from a reverse proxy access log
+  samlurai extract -f access.log --log-format combined --list
+
+  # Extract from an application log that dumps SAMLResponse values
+  samlurai extract -f app.log --log-format raw -d ./extracted
+
+  # Keep the exact bytes of each message, e.g. to verify signatures later
+  samlurai extract -f session.har --raw
+
+  # Save the original base64-encoded values instead of XML
+  samlurai extract -f session.har --format b64
+
+  # Mask personal data in the saved files, e.g. to attach them to a ticket
+  samlurai extract -f session.har --redact --keep-attribute groups
+
+  # Bundle the files with an index.json manifest, e.g. to attach to a ticket
+  samlurai extract -f session.har --zip saml.zip
+
+  # Write all messages with their parsed details as one JSON document
+  samlurai extract -f session.har -o json > messages.json
+
+  # Verify signatures and mark each file _verified, _sigfail or _unsigned
+  samlurai extract -f session.har --verify -c idp.pem
+
+  # Only check that signed messages were not modified, marking them
+  # _selfsigned rather than _verified
+  samlurai extract -f session.har --verify
+
+  # Verify detached signatures over documents saved in ./refs, e.g.
+  # refs/policy.xml for a reference to cid:policy.xml
+  samlurai extract -f session.har --verify -c idp.pem --reference-dir refs`,
 	RunE: runExtract,
 }
 
 func init() {
 	rootCmd.AddCommand(extractCmd)
 
-	extractCmd.Flags().StringVarP(&extractFile, "file", "f", "", "HAR file to extract SAML from (required)")
+	extractCmd.Flags().StringVarP(&extractFile, "file", "f", "", "HAR or log file to extract SAML from (required)")
 	extractCmd.Flags().StringVarP(&extractOutputDir, "dir", "d", ".", "Output directory for extracted files")
 	extractCmd.Flags().BoolVar(&extractList, "list", false, "List found SAML assertions without extracting")
+	extractCmd.Flags().StringVar(&extractReport, "report", "", "Also write a self-contained HTML report to this file")
+	extractCmd.Flags().Float64Var(&extractMinConf, "min-confidence", 0, "Only keep SAML messages with at least this confidence score (0-1)")
+	addFilterFlags(extractCmd.Flags(), &extractFilter)
+	addRedactFlags(extractCmd.Flags(), &extractRedact)
+	extractCmd.Flags().BoolVar(&extractDedupe, "dedupe", false, "Collapse SAML messages recorded more than once, e.g. by browser preloads or retries")
+	extractCmd.Flags().StringVar(&extractLogFormat, "log-format", "", "Read the file as a log: "+strings.Join(saml.LogFormats(), ", "))
+	extractCmd.Flags().BoolVar(&extractSockets, "websockets", false, "Also search the WebSocket frames of HAR files (recorded by Chrome) for SAML")
+	extractCmd.Flags().BoolVar(&extractVerify, "verify", false, "Verify XML signatures and add the verdict to each filename")
+	extractCmd.Flags().StringVarP(&extractCert, "cert", "c", "", "Signer certificate for --verify (PEM or base64 DER); without it, signatures are checked against their own certificate and marked selfsigned")
+	extractCmd.Flags().StringVar(&extractRefDir, "reference-dir", "", "Directory holding the content external signature references point at, named like the last segment of the reference URI")
+	extractCmd.Flags().BoolVar(&extractRaw, "raw", false, "Save the decoded XML byte for byte instead of pretty-printing it, keeping signatures verifiable")
+	extractCmd.Flags().StringVar(&extractZip, "zip", "", "Save the files and an index.json manifest into this zip archive instead of a directory")
+	extractCmd.Flags().StringVar(&extractFormat, "format", extractFormatXML, "Save messages as decoded XML (xml) or as the original encoded value (b64)")
 	_ = extractCmd.MarkFlagRequired("file")
 }
 
 func runExtract(cmd *cobra.Command, args []string) error {
-	// Read HAR file
-	data, err := os.ReadFile(extractFile)
+	if extractFormat != extractFormatXML && extractFormat != extractFormatBase64 {
+		return fmt.Errorf("invalid --format %q: expected %s or %s", extractFormat, extractFormatXML, extractFormatBase64)
+	}
+
+	redactor, err := extractRedact.redactor()
 	if err != nil {
-		return fmt.Errorf("failed to read HAR file: %w", err)
+		return err
+	}
+
+	// JSON output bundles all messages on stdout instead of writing files
+	formatter := output.NewFormatter(outputFormat)
+	bundle := formatter.IsJSON() || formatter.IsJSONL()
+	if !bundle && outputFormat != "pretty" {
+		return fmt.Errorf("extract supports -o pretty, json, jsonl or psobject, not %s", outputFormat)
 	}
 
-	// Extract SAML assertions
-	extractor := saml.NewHARExtractor()
-	results, err := extractor.ExtractFromHAR(data)
+	kind := "HAR file"
+	if extractLogFormat != "" {
+		kind = "log file"
+	}
+
+	f, err := os.Open(extractFile)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", kind, err)
+	}
+	defer f.Close()
+	file, err := saml.DecompressReader(f)
 	if err != nil {
-		return fmt.Errorf("failed to extract SAML: %w", err)
+		return fmt.Errorf("failed to read %s: %w", kind, err)
 	}
+	defer file.Close()
 
-	if len(results) == 0 {
-		fmt.Fprintln(cmd.OutOrStdout(), "No SAML assertions found in the HAR file.")
+	// Extract SAML assertions. HAR files are streamed, as browser exports
+	// can be hundreds of megabytes.
+	extractor := saml.NewHARExtractor().WithWebSockets(extractSockets)
+	var results []saml.ExtractedSAML
+	if p := extractPlugin(extractFile); p != nil && extractLogFormat == "" {
+		// The plugin converts a format samlurai does not read into a HAR
+		data, err := io.ReadAll(file)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", extractFile, err)
+		}
+		har, err := p.Extract(cmd.Context(), extractFile, data)
+		if err != nil {
+			return err
+		}
+		if results, err = extractor.ExtractFromHAR(har); err != nil {
+			return withExitCode(ExitParse, fmt.Errorf("failed to extract SAML from the HAR of plugin %s: %w", p.Name, err))
+		}
+	} else if extractLogFormat != "" {
+		data, err := io.ReadAll(file)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", kind, err)
+		}
+		results, err = extractor.ExtractFromLog(data, extractLogFormat)
+		if err != nil {
+			return withExitCode(ExitParse, fmt.Errorf("failed to extract SAML: %w", err))
+		}
+	} else if results, err = extractor.ExtractReader(file); err != nil {
+		return withExitCode(ExitParse, fmt.Errorf("failed to extract SAML: %w", err))
+	}
+
+	results = saml.FilterByConfidence(results, extractMinConf)
+	results = extractFilter.Apply(results)
+	if extractDedupe {
+		results = saml.Dedupe(results)
+	}
+
+	var verifications []saml.MessageVerification
+	if extractVerify || extractCert != "" || extractRefDir != "" {
+		if verifications, err = verifyExtracted(results, extractCert, extractRefDir); err != nil {
+			return err
+		}
+	}
+
+	// Signatures are verified first, as redaction breaks them
+	if redactor != nil {
+		for i := range results {
+			if results[i], err = redactor.Extracted(results[i]); err != nil {
+				return fmt.Errorf("failed to redact message %d: %w", results[i].Index, err)
+			}
+		}
+	}
+
+	var messages []inspect.Message
+	if extractReport != "" || bundle {
+		if messages, err = inspect.Extracted(cmd.Context(), results, ""); err != nil {
+			return err
+		}
+	}
+
+	if extractReport != "" {
+		if err := writeReport(cmd, extractReport, extractFile, reportEntries(messages)); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case bundle:
+		err = writeExtractBundle(cmd, formatter, messages, verifications)
+	case len(results) == 0:
+		fmt.Fprintf(cmd.OutOrStdout(), "No SAML assertions found in the %s.\n", kind)
 		return nil
+	case extractList:
+		// List mode - just show what was found
+		err = listExtractedSAML(cmd, results, verifications)
+	default:
+		// Extract mode - save to files
+		files := extractedFiles(extractor, results, verifications, extractFormat, extractRaw)
+		if extractZip != "" {
+			err = zipExtractedSAML(cmd, results, verifications, files, extractZip)
+		} else {
+			err = saveExtractedSAML(cmd, results, verifications, files, extractOutputDir)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, v := range verifications {
+		if v.Verdict == saml.VerdictSigFail {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return withExitCode(ExitSignature, fmt.Errorf("%d message(s) failed signature verification", failed))
+	}
+	return nil
+}
+
+// verifyExtracted verifies the signatures of each message, against the
+// certificate at certPath if given. Without it, signatures can only be
+// checked against the certificate they carry, which anyone re-signing a
+// message controls, so messages that verify are marked selfsigned.
+// External references of detached signatures are resolved in refDir.
+func verifyExtracted(results []saml.ExtractedSAML, certPath, refDir string) ([]saml.MessageVerification, error) {
+	var certs []*x509.Certificate
+	if certPath != "" {
+		cert, err := saml.LoadCertificate(certPath)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
 	}
 
-	// List mode - just show what was found
-	if extractList {
-		return listExtractedSAML(cmd, results)
+	var resolve saml.ReferenceResolver
+	if refDir != "" {
+		resolve = saml.DirResolver(refDir)
+	}
+
+	verifications := make([]saml.MessageVerification, len(results))
+	for i, r := range results {
+		verifications[i] = saml.VerifyMessageWithResolver(r.DecodedXML, certs, resolve)
+		if certs == nil && verifications[i].Verdict == saml.VerdictVerified {
+			verifications[i].Verdict = saml.VerdictSelfSigned
+		}
 	}
+	return verifications, nil
+}
 
-	// Extract mode - save to files
-	return saveExtractedSAML(cmd, extractor, results)
+// printVerification prints the verdict of a message, if it was verified
+func printVerification(cmd *cobra.Command, verifications []saml.MessageVerification, i int) {
+	if verifications == nil {
+		return
+	}
+	v := verifications[i]
+	switch v.Verdict {
+	case saml.VerdictVerified:
+		fmt.Fprintf(cmd.OutOrStdout(), "      Signature: ✅ verified (%s)\n", strings.Join(v.Verified, ", "))
+	case saml.VerdictSelfSigned:
+		fmt.Fprintf(cmd.OutOrStdout(), "      Signature: ⚠️  unmodified, but checked only against its own certificate (%s); pass -c to verify the signer\n", strings.Join(v.Verified, ", "))
+	case saml.VerdictSigFail:
+		fmt.Fprintf(cmd.OutOrStdout(), "      Signature: ❌ %v\n", v.Err)
+	case saml.VerdictUnsigned:
+		if len(v.Verified) > 0 {
+			fmt.Fprintf(cmd.OutOrStdout(), "      Signature: ⚠️  none over the message; only detached content verified (%s)\n", strings.Join(v.Verified, ", "))
+			return
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "      Signature: none\n")
+	default:
+		fmt.Fprintf(cmd.OutOrStdout(), "      Signature: none\n")
+	}
 }
 
-func listExtractedSAML(cmd *cobra.Command, results []saml.ExtractedSAML) error {
+func listExtractedSAML(cmd *cobra.Command, results []saml.ExtractedSAML, verifications []saml.MessageVerification) error {
 	fmt.Fprintf(cmd.OutOrStdout(), "Found %d SAML assertion(s):\n\n", len(results))
 
-	for _, r := range results {
+	for i, r := range results {
 		fmt.Fprintf(cmd.OutOrStdout(), "  [%d] %s\n", r.Index, r.Type)
 		fmt.Fprintf(cmd.OutOrStdout(), "      Source: %s\n", r.Source)
 		if r.ParameterName != "" {
 			fmt.Fprintf(cmd.OutOrStdout(), "      Parameter: %s\n", r.ParameterName)
 		}
-		fmt.Fprintf(cmd.OutOrStdout(), "      URL: %s\n", truncateURL(r.URL, 60))
+		if r.Line > 0 {
+			fmt.Fprintf(cmd.OutOrStdout(), "      Line: %d\n", r.Line)
+		}
+		if r.URL != "" {
+			fmt.Fprintf(cmd.OutOrStdout(), "      URL: %s\n", truncateURL(r.URL, 60))
+		}
 		if r.WasDeflated {
 			fmt.Fprintf(cmd.OutOrStdout(), "      Encoding: base64 + deflate\n")
 		} else {
 			fmt.Fprintf(cmd.OutOrStdout(), "      Encoding: base64\n")
 		}
+		if r.SimpleSign != nil {
+			fmt.Fprintf(cmd.OutOrStdout(), "      Binding: HTTP-POST-SimpleSign (%s)\n", r.SimpleSign.SigAlg)
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "      Confidence: %.2f\n", r.Confidence)
+		if r.Occurrences > 1 {
+			fmt.Fprintf(cmd.OutOrStdout(), "      Seen: %d times\n", r.Occurrences)
+		}
+		printVerification(cmd, verifications, i)
 		fmt.Fprintln(cmd.OutOrStdout())
 	}
 
 	return nil
 }
 
-func saveExtractedSAML(cmd *cobra.Command, extractor *saml.HARExtractor, results []saml.ExtractedSAML) error {
-	// Create output directory if it doesn't exist
-	if err := os.MkdirAll(extractOutputDir, 0755); err != nil {
-		return fmt.Errorf("failed to create output directory: %w", err)
+// writeExtractBundle writes all messages with their metadata, decoded XML
+// and parsed details as one JSON array or a JSONL stream
+func writeExtractBundle(cmd *cobra.Command, formatter *output.Formatter, messages []inspect.Message, verifications []saml.MessageVerification) error {
+	records := jsonlRecords(messages)
+	for i := range records {
+		extracted := messages[i].Extracted
+		records[i].Confidence = extracted.Confidence
+		records[i].XML = string(extracted.DecodedXML)
+		if verifications != nil {
+			records[i].Signature = verifications[i].Verdict
+		}
+	}
+
+	var formatted string
+	var err error
+	if formatter.IsJSONL() {
+		formatted, err = formatter.FormatJSONL(records)
+	} else {
+		formatted, err = formatter.FormatJSON(records)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to format output: %w", err)
 	}
+	fmt.Fprint(cmd.OutOrStdout(), formatted)
+	return nil
+}
+
+// extractedFile is an extracted message ready to be saved
+type extractedFile struct {
+	Name string
+	Data []byte
+}
 
+// extractedFiles names and renders each message in the given format. XML is
+// pretty-printed unless raw is set, as re-encoding can change namespace
+// prefixes and whitespace and so break signature verification.
+func extractedFiles(extractor *saml.HARExtractor, results []saml.ExtractedSAML, verifications []saml.MessageVerification, format string, raw bool) []extractedFile {
 	formatter := output.NewFormatter("pretty")
-	savedFiles := []string{}
+	files := make([]extractedFile, len(results))
+	for i, r := range results {
+		name := extractor.GenerateFilename(r)
+		if verifications != nil {
+			name = extractor.GenerateVerifiedFilename(r, verifications[i].Verdict)
+		}
 
-	for _, r := range results {
-		filename := extractor.GenerateFilename(r)
-		filepath := filepath.Join(extractOutputDir, filename)
+		var data []byte
+		switch {
+		case format == extractFormatBase64:
+			name = strings.TrimSuffix(name, ".xml") + ".b64"
+			data = []byte(r.RawValue)
+		case raw:
+			data = r.DecodedXML
+		default:
+			formatted, err := formatter.FormatXML(r.DecodedXML)
+			if err != nil {
+				// If formatting fails, use raw XML
+				formatted = string(r.DecodedXML)
+			}
+			data = []byte(formatted)
+		}
+		files[i] = extractedFile{Name: name, Data: data}
+	}
+	return files
+}
 
-		// Format the XML nicely
-		formatted, err := formatter.FormatXML(r.DecodedXML)
-		if err != nil {
-			// If formatting fails, use raw XML
-			formatted = string(r.DecodedXML)
+func saveExtractedSAML(cmd *cobra.Command, results []saml.ExtractedSAML, verifications []saml.MessageVerification, files []extractedFile, outputDir string) error {
+	// Create output directory if it doesn't exist
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	for _, f := range files {
+		if err := os.WriteFile(filepath.Join(outputDir, f.Name), f.Data, 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", f.Name, err)
 		}
+	}
+
+	printSavedSAML(cmd, results, verifications, files, outputDir)
+	return nil
+}
+
+// zipManifestEntry describes one file of a --zip archive in its index.json
+type zipManifestEntry struct {
+	File          string  `json:"file"`
+	Index         int     `json:"index"`
+	Type          string  `json:"type"`
+	Source        string  `json:"source"`
+	URL           string  `json:"url,omitempty"`
+	ParameterName string  `json:"parameter_name,omitempty"`
+	Line          int     `json:"line,omitempty"`
+	Confidence    float64 `json:"confidence"`
+	Occurrences   int     `json:"occurrences,omitempty"`
+	Signature     string  `json:"signature,omitempty"`
+}
 
-		// Write to file
-		if err := os.WriteFile(filepath, []byte(formatted), 0644); err != nil {
-			return fmt.Errorf("failed to write %s: %w", filename, err)
+// zipExtractedSAML writes the files and an index.json manifest describing
+// them into a zip archive at zipPath
+func zipExtractedSAML(cmd *cobra.Command, results []saml.ExtractedSAML, verifications []saml.MessageVerification, files []extractedFile, zipPath string) error {
+	manifest := make([]zipManifestEntry, len(results))
+	for i, r := range results {
+		manifest[i] = zipManifestEntry{
+			File:          files[i].Name,
+			Index:         r.Index,
+			Type:          r.Type,
+			Source:        r.Source,
+			URL:           r.URL,
+			ParameterName: r.ParameterName,
+			Line:          r.Line,
+			Confidence:    r.Confidence,
+			Occurrences:   r.Occurrences,
+		}
+		if verifications != nil {
+			manifest[i].Signature = verifications[i].Verdict
 		}
+	}
+	index, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal index.json: %w", err)
+	}
 
-		savedFiles = append(savedFiles, filename)
+	f, err := os.Create(zipPath)
+	if err != nil {
+		return fmt.Errorf("failed to create archive: %w", err)
 	}
+	defer f.Close()
 
-	// Print summary
-	fmt.Fprintf(cmd.OutOrStdout(), "Extracted %d SAML assertion(s) to %s:\n\n", len(results), extractOutputDir)
+	archive := zip.NewWriter(f)
+	for _, file := range append(files, extractedFile{Name: "index.json", Data: index}) {
+		w, err := archive.Create(file.Name)
+		if err != nil {
+			return fmt.Errorf("failed to add %s to archive: %w", file.Name, err)
+		}
+		if _, err := w.Write(file.Data); err != nil {
+			return fmt.Errorf("failed to add %s to archive: %w", file.Name, err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+
+	printSavedSAML(cmd, results, verifications, files, zipPath)
+	return nil
+}
+
+// printSavedSAML prints which file each message was saved to
+func printSavedSAML(cmd *cobra.Command, results []saml.ExtractedSAML, verifications []saml.MessageVerification, files []extractedFile, dest string) {
+	fmt.Fprintf(cmd.OutOrStdout(), "Extracted %d SAML assertion(s) to %s:\n\n", len(results), dest)
 
 	for i, r := range results {
-		fmt.Fprintf(cmd.OutOrStdout(), "  [%d] %s → %s\n", r.Index, r.Type, savedFiles[i])
+		fmt.Fprintf(cmd.OutOrStdout(), "  [%d] %s → %s\n", r.Index, r.Type, files[i].Name)
 		fmt.Fprintf(cmd.OutOrStdout(), "      Source: %s", r.Source)
 		if r.ParameterName != "" {
 			fmt.Fprintf(cmd.OutOrStdout(), " (%s)", r.ParameterName)
 		}
+		if r.Occurrences > 1 {
+			fmt.Fprintf(cmd.OutOrStdout(), ", seen %d times", r.Occurrences)
+		}
 		fmt.Fprintln(cmd.OutOrStdout())
+		printVerification(cmd, verifications, i)
 	}
-
-	return nil
 }
 
 // truncateURL truncates a URL for display
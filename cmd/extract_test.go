@@ -1,12 +1,23 @@
 package cmd
 
 import (
+	"archive/zip"
 	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
+
+	"github.com/gliwka/SAMLurai/internal/saml"
 )
 
 func TestExtractCommand(t *testing.T) {
@@ -111,6 +122,36 @@ func TestExtractCommand(t *testing.T) {
 		}
 	})
 
+	t.Run("min confidence filter", func(t *testing.T) {
+		// Reset flags
+		extractFile = ""
+		extractOutputDir = "."
+		extractList = false
+		defer func() { extractMinConf = 0 }()
+
+		cmd := GetRootCmd()
+		buf := new(bytes.Buffer)
+		cmd.SetOut(buf)
+		cmd.SetErr(buf)
+
+		cmd.SetArgs([]string{"extract", "-f", harFile, "--list", "--min-confidence", "0.5"})
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("Command failed: %v", err)
+		}
+		if !strings.Contains(buf.String(), "Confidence:") {
+			t.Errorf("Expected confidence in list output, got: %s", buf.String())
+		}
+
+		buf.Reset()
+		cmd.SetArgs([]string{"extract", "-f", harFile, "--list", "--min-confidence", "1.01"})
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("Command failed: %v", err)
+		}
+		if !strings.Contains(buf.String(), "No SAML assertions found") {
+			t.Errorf("Expected all results to be filtered, got: %s", buf.String())
+		}
+	})
+
 	t.Run("no SAML found", func(t *testing.T) {
 		// Reset flags
 		extractFile = ""
@@ -285,3 +326,455 @@ func TestTruncateURL(t *testing.T) {
 		}
 	}
 }
+
+func TestExtractFromLog(t *testing.T) {
+	defer func() {
+		extractFile = ""
+		extractOutputDir = "."
+		extractList = false
+		extractLogFormat = ""
+	}()
+
+	samlResponse := `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_logged"/>`
+	logFile := filepath.Join(t.TempDir(), "app.log")
+	log := "INFO request received\nDEBUG SAMLResponse=" + base64.StdEncoding.EncodeToString([]byte(samlResponse)) + "\n"
+	if err := os.WriteFile(logFile, []byte(log), 0644); err != nil {
+		t.Fatalf("Failed to create log file: %v", err)
+	}
+
+	output, err := executeCommand(rootCmd, "extract", "-f", logFile, "--log-format", "raw", "--list")
+	if err != nil {
+		t.Fatalf("Command failed: %v", err)
+	}
+	for _, want := range []string{"[1] Response", "Source: log", "Line: 2"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected %q in output, got: %s", want, output)
+		}
+	}
+
+	_, err = executeCommand(rootCmd, "extract", "-f", logFile, "--log-format", "w3c")
+	if err == nil || !strings.Contains(err.Error(), `unknown log format "w3c"`) {
+		t.Errorf("Expected unknown log format error, got: %v", err)
+	}
+}
+
+func TestExtractVerify(t *testing.T) {
+	defer func() {
+		extractFile = ""
+		extractOutputDir = "."
+		extractList = false
+		extractVerify = false
+		extractCert = ""
+	}()
+
+	response, err := os.ReadFile(filepath.Join("..", "testdata", "fixtures", "signed", "onelogin_response.xml"))
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	tampered := strings.Replace(string(response), ">Ross<", ">Eve<", 1)
+
+	entry := func(xml string) string {
+		return `{"request": {"method": "POST", "url": "https://sp.example.com/acs",
+			"postData": {"mimeType": "application/x-www-form-urlencoded", "params": [{"name": "SAMLResponse", "value": "` + base64.StdEncoding.EncodeToString([]byte(xml)) + `"}]}},
+			"response": {"content": {"mimeType": "text/html", "text": ""}}}`
+	}
+	dir := t.TempDir()
+	harFile := filepath.Join(dir, "session.har")
+	har := `{"log": {"entries": [` + entry(string(response)) + `, ` + entry(tampered) + `]}}`
+	if err := os.WriteFile(harFile, []byte(har), 0644); err != nil {
+		t.Fatalf("Failed to create HAR file: %v", err)
+	}
+
+	outDir := filepath.Join(dir, "out")
+	certFile := filepath.Join("..", "testdata", "fixtures", "signed", "onelogin_cert.pem")
+	output, err := executeCommand(rootCmd, "extract", "-f", harFile, "-d", outDir, "--verify", "-c", certFile)
+	if err == nil || err.Error() != "1 message(s) failed signature verification" {
+		t.Errorf("Expected verification failure, got: %v", err)
+	}
+	for _, want := range []string{"Signature: ✅ verified", "Signature: ❌ Response: digest mismatch"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected %q in output, got: %s", want, output)
+		}
+	}
+
+	entries, err := os.ReadDir(outDir)
+	if err != nil {
+		t.Fatalf("Failed to read output dir: %v", err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if len(names) != 2 || !strings.HasSuffix(names[0], "_verified.xml") || !strings.HasSuffix(names[1], "_sigfail.xml") {
+		t.Errorf("Expected verdicts in filenames, got: %v", names)
+	}
+}
+
+func TestExtractVerify_SelfSigned(t *testing.T) {
+	defer func() {
+		extractFile = ""
+		extractOutputDir = "."
+		extractVerify = false
+		extractCert = ""
+	}()
+
+	response, err := os.ReadFile(filepath.Join("..", "testdata", "fixtures", "signed", "onelogin_response.xml"))
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	dir := t.TempDir()
+	harFile := filepath.Join(dir, "session.har")
+	har := `{"log": {"entries": [{"request": {"method": "POST", "url": "https://sp.example.com/acs",
+		"postData": {"mimeType": "application/x-www-form-urlencoded", "params": [{"name": "SAMLResponse", "value": "` + base64.StdEncoding.EncodeToString(response) + `"}]}},
+		"response": {"content": {"mimeType": "text/html", "text": ""}}}]}}`
+	if err := os.WriteFile(harFile, []byte(har), 0644); err != nil {
+		t.Fatalf("Failed to create HAR file: %v", err)
+	}
+
+	// Without -c the signer is unknown, so the message is not marked verified
+	outDir := filepath.Join(dir, "out")
+	output, err := executeCommand(rootCmd, "extract", "-f", harFile, "-d", outDir, "--verify")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(output, "checked only against its own certificate") {
+		t.Errorf("Expected self-signed verdict in output, got: %s", output)
+	}
+	entries, err := os.ReadDir(outDir)
+	if err != nil {
+		t.Fatalf("Failed to read output dir: %v", err)
+	}
+	if len(entries) != 1 || !strings.HasSuffix(entries[0].Name(), "_selfsigned.xml") {
+		t.Errorf("Expected a _selfsigned filename, got: %v", entries)
+	}
+}
+
+func TestExtractVerify_ReferenceDir(t *testing.T) {
+	defer func() {
+		extractFile = ""
+		extractList = false
+		extractVerify = false
+		extractCert = ""
+		extractRefDir = ""
+	}()
+
+	key, cert, err := saml.NewSelfSignedKey("idp.example.com")
+	if err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "idp.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}), 0644); err != nil {
+		t.Fatalf("Failed to write certificate: %v", err)
+	}
+	refDir := filepath.Join(dir, "refs")
+	policy := []byte("<policy/>")
+	if err := os.MkdirAll(refDir, 0755); err != nil {
+		t.Fatalf("Failed to create reference dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(refDir, "policy.xml"), policy, 0644); err != nil {
+		t.Fatalf("Failed to write reference: %v", err)
+	}
+
+	// A detached signature over cid:policy.xml; SignedInfo is written in
+	// its exclusive canonical form, which is what gets signed
+	digest := sha256.Sum256(policy)
+	signedInfo := `<ds:SignedInfo xmlns:ds="http://www.w3.org/2000/09/xmldsig#">` +
+		`<ds:CanonicalizationMethod Algorithm="http://www.w3.org/2001/10/xml-exc-c14n#"></ds:CanonicalizationMethod>` +
+		`<ds:SignatureMethod Algorithm="http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"></ds:SignatureMethod>` +
+		`<ds:Reference URI="cid:policy.xml"><ds:DigestMethod Algorithm="http://www.w3.org/2001/04/xmlenc#sha256"></ds:DigestMethod>` +
+		`<ds:DigestValue>` + base64.StdEncoding.EncodeToString(digest[:]) + `</ds:DigestValue></ds:Reference></ds:SignedInfo>`
+	hashed := sha256.Sum256([]byte(signedInfo))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hashed[:])
+	if err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+	request := `<samlp:LogoutRequest xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_l">` +
+		`<ds:Signature xmlns:ds="http://www.w3.org/2000/09/xmldsig#">` + signedInfo +
+		`<ds:SignatureValue>` + base64.StdEncoding.EncodeToString(signature) + `</ds:SignatureValue></ds:Signature></samlp:LogoutRequest>`
+	harFile := filepath.Join(dir, "session.har")
+	har := `{"log": {"entries": [{"request": {"method": "POST", "url": "https://idp.example.com/slo",
+		"postData": {"mimeType": "application/x-www-form-urlencoded", "params": [{"name": "SAMLRequest", "value": "` + base64.StdEncoding.EncodeToString([]byte(request)) + `"}]}},
+		"response": {"content": {"mimeType": "text/html", "text": ""}}}]}}`
+	if err := os.WriteFile(harFile, []byte(har), 0644); err != nil {
+		t.Fatalf("Failed to create HAR file: %v", err)
+	}
+
+	// Without the reference directory the detached signature cannot verify
+	output, err := executeCommand(rootCmd, "extract", "-f", harFile, "--list", "--verify", "-c", certFile)
+	if err == nil || !strings.Contains(output, "which is not resolved") {
+		t.Errorf("Expected unresolved reference failure, got: %v\n%s", err, output)
+	}
+
+	output, err = executeCommand(rootCmd, "extract", "-f", harFile, "--list", "--verify", "-c", certFile, "--reference-dir", refDir)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// The signature verifies, but does not cover the request itself
+	if !strings.Contains(output, "Signature: ⚠️  none over the message; only detached content verified (LogoutRequest (cid:policy.xml))") {
+		t.Errorf("Expected request unsigned with the detached signature verified, got: %s", output)
+	}
+}
+
+func TestExtractDedupe(t *testing.T) {
+	defer func() {
+		extractFile = ""
+		extractList = false
+		extractDedupe = false
+	}()
+
+	samlResponse := `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_retried"/>`
+	entry := `{
+		"request": {
+			"method": "POST",
+			"url": "https://sp.example.com/acs",
+			"postData": {
+				"mimeType": "application/x-www-form-urlencoded",
+				"params": [{"name": "SAMLResponse", "value": "` + base64.StdEncoding.EncodeToString([]byte(samlResponse)) + `"}]
+			}
+		},
+		"response": {"content": {"mimeType": "text/html", "text": ""}}
+	}`
+	harFile := filepath.Join(t.TempDir(), "retried.har")
+	if err := os.WriteFile(harFile, []byte(`{"log": {"entries": [`+entry+`,`+entry+`,`+entry+`]}}`), 0644); err != nil {
+		t.Fatalf("Failed to create HAR file: %v", err)
+	}
+
+	output, err := executeCommand(rootCmd, "extract", "-f", harFile, "--list")
+	if err != nil {
+		t.Fatalf("Command failed: %v", err)
+	}
+	if !strings.Contains(output, "Found 3 SAML assertion(s)") {
+		t.Errorf("Expected 3 messages without --dedupe, got: %s", output)
+	}
+
+	output, err = executeCommand(rootCmd, "extract", "-f", harFile, "--list", "--dedupe")
+	if err != nil {
+		t.Fatalf("Command failed: %v", err)
+	}
+	for _, want := range []string{"Found 1 SAML assertion(s)", "Seen: 3 times"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected %q in output, got: %s", want, output)
+		}
+	}
+}
+
+func TestExtractRawAndBase64(t *testing.T) {
+	defer func() {
+		extractFile = ""
+		extractOutputDir = "."
+		extractRaw = false
+		extractFormat = extractFormatXML
+	}()
+
+	// Whitespace inside the signed element must survive byte for byte
+	samlResponse := `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_raw"><saml:Issuer xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion">idp</saml:Issuer>   </samlp:Response>`
+	encoded := base64.StdEncoding.EncodeToString([]byte(samlResponse))
+	tmpDir := t.TempDir()
+	harFile := filepath.Join(tmpDir, "raw.har")
+	har := `{"log": {"entries": [{
+		"request": {"method": "POST", "url": "https://sp.example.com/acs",
+			"postData": {"mimeType": "application/x-www-form-urlencoded", "params": [{"name": "SAMLResponse", "value": "` + encoded + `"}]}},
+		"response": {"content": {"mimeType": "text/html", "text": ""}}}]}}`
+	if err := os.WriteFile(harFile, []byte(har), 0644); err != nil {
+		t.Fatalf("Failed to create HAR file: %v", err)
+	}
+
+	rawDir := filepath.Join(tmpDir, "raw")
+	if _, err := executeCommand(rootCmd, "extract", "-f", harFile, "-d", rawDir, "--raw"); err != nil {
+		t.Fatalf("Command failed: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(rawDir, "saml_001_response_request_body.xml"))
+	if err != nil {
+		t.Fatalf("Failed to read extracted file: %v", err)
+	}
+	if string(data) != samlResponse {
+		t.Errorf("Expected the decoded XML unchanged, got: %s", data)
+	}
+
+	extractRaw = false
+	b64Dir := filepath.Join(tmpDir, "b64")
+	if _, err := executeCommand(rootCmd, "extract", "-f", harFile, "-d", b64Dir, "--format", "b64"); err != nil {
+		t.Fatalf("Command failed: %v", err)
+	}
+	data, err = os.ReadFile(filepath.Join(b64Dir, "saml_001_response_request_body.b64"))
+	if err != nil {
+		t.Fatalf("Failed to read extracted file: %v", err)
+	}
+	if string(data) != encoded {
+		t.Errorf("Expected the original encoded value, got: %s", data)
+	}
+
+	_, err = executeCommand(rootCmd, "extract", "-f", harFile, "--format", "pem")
+	if err == nil || !strings.Contains(err.Error(), `invalid --format "pem"`) {
+		t.Errorf("Expected invalid format error, got: %v", err)
+	}
+}
+
+func TestExtractBundle(t *testing.T) {
+	defer func() {
+		extractFile = ""
+		outputFormat = "pretty"
+	}()
+
+	samlResponse := `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_bundled"><saml:Issuer xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion">https://idp.example.com</saml:Issuer></samlp:Response>`
+	samlRequest := `<samlp:AuthnRequest xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_req1"><saml:Issuer xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion">sp</saml:Issuer></samlp:AuthnRequest>`
+	harFile := filepath.Join(t.TempDir(), "bundle.har")
+	har := `{"log": {"entries": [
+		{"request": {"method": "GET", "url": "https://idp.example.com/sso?SAMLRequest=` + url.QueryEscape(base64.StdEncoding.EncodeToString([]byte(samlRequest))) + `"},
+			"response": {"content": {"mimeType": "text/html", "text": ""}}},
+		{"request": {"method": "POST", "url": "https://sp.example.com/acs",
+			"postData": {"mimeType": "application/x-www-form-urlencoded", "params": [{"name": "SAMLResponse", "value": "` + base64.StdEncoding.EncodeToString([]byte(samlResponse)) + `"}]}},
+			"response": {"content": {"mimeType": "text/html", "text": ""}}}]}}`
+	if err := os.WriteFile(harFile, []byte(har), 0644); err != nil {
+		t.Fatalf("Failed to create HAR file: %v", err)
+	}
+
+	output, err := executeCommand(rootCmd, "extract", "-f", harFile, "-o", "json")
+	if err != nil {
+		t.Fatalf("Command failed: %v", err)
+	}
+	var records []map[string]interface{}
+	if err := json.Unmarshal([]byte(output), &records); err != nil {
+		t.Fatalf("Expected a JSON array, got: %s", output)
+	}
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 records, got %d", len(records))
+	}
+	response := records[1]
+	if response["type"] != "Response" || response["source"] != "request-body" || response["xml"] != samlResponse {
+		t.Errorf("Unexpected record: %v", response)
+	}
+	if info, ok := response["info"].(map[string]interface{}); !ok || info["issuer"] != "https://idp.example.com" {
+		t.Errorf("Expected the parsed message in the record, got: %v", response["info"])
+	}
+
+	output, err = executeCommand(rootCmd, "extract", "-f", harFile, "-o", "jsonl")
+	if err != nil {
+		t.Fatalf("Command failed: %v", err)
+	}
+	if lines := strings.Split(strings.TrimSpace(output), "\n"); len(lines) != 2 {
+		t.Errorf("Expected 2 JSONL lines, got: %s", output)
+	}
+
+	_, err = executeCommand(rootCmd, "extract", "-f", harFile, "-o", "csv")
+	if err == nil || !strings.Contains(err.Error(), "extract supports -o pretty, json, jsonl or psobject") {
+		t.Errorf("Expected unsupported format error, got: %v", err)
+	}
+}
+
+func TestExtractZip(t *testing.T) {
+	defer func() {
+		extractFile = ""
+		extractZip = ""
+	}()
+
+	samlResponse := `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_zipped"/>`
+	tmpDir := t.TempDir()
+	harFile := filepath.Join(tmpDir, "zip.har")
+	har := `{"log": {"entries": [{
+		"request": {"method": "POST", "url": "https://sp.example.com/acs",
+			"postData": {"mimeType": "application/x-www-form-urlencoded", "params": [{"name": "SAMLResponse", "value": "` + base64.StdEncoding.EncodeToString([]byte(samlResponse)) + `"}]}},
+		"response": {"content": {"mimeType": "text/html", "text": ""}}}]}}`
+	if err := os.WriteFile(harFile, []byte(har), 0644); err != nil {
+		t.Fatalf("Failed to create HAR file: %v", err)
+	}
+
+	zipPath := filepath.Join(tmpDir, "saml.zip")
+	output, err := executeCommand(rootCmd, "extract", "-f", harFile, "--zip", zipPath)
+	if err != nil {
+		t.Fatalf("Command failed: %v", err)
+	}
+	if !strings.Contains(output, "Extracted 1 SAML assertion(s) to "+zipPath) {
+		t.Errorf("Expected the archive in the summary, got: %s", output)
+	}
+
+	archive, err := zip.OpenReader(zipPath)
+	if err != nil {
+		t.Fatalf("Failed to open archive: %v", err)
+	}
+	defer archive.Close()
+
+	var names []string
+	var manifest []zipManifestEntry
+	for _, f := range archive.File {
+		names = append(names, f.Name)
+		if f.Name != "index.json" {
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			t.Fatalf("Failed to open index.json: %v", err)
+		}
+		if err := json.NewDecoder(r).Decode(&manifest); err != nil {
+			t.Fatalf("Failed to decode index.json: %v", err)
+		}
+		r.Close()
+	}
+	if strings.Join(names, ",") != "saml_001_response_request_body.xml,index.json" {
+		t.Errorf("Unexpected archive contents: %v", names)
+	}
+	if len(manifest) != 1 || manifest[0].File != "saml_001_response_request_body.xml" || manifest[0].Type != "Response" || manifest[0].URL != "https://sp.example.com/acs" {
+		t.Errorf("Unexpected manifest: %+v", manifest)
+	}
+}
+
+func TestExtractGzippedHAR(t *testing.T) {
+	defer func() {
+		extractFile = ""
+		extractList = false
+	}()
+
+	samlResponse := `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_gzipped"/>`
+	har := `{"log": {"entries": [{
+		"request": {"method": "POST", "url": "https://sp.example.com/acs",
+			"postData": {"mimeType": "application/x-www-form-urlencoded", "params": [{"name": "SAMLResponse", "value": "` + base64.StdEncoding.EncodeToString([]byte(samlResponse)) + `"}]}},
+		"response": {"content": {"mimeType": "text/html", "text": ""}}
+	}]}}`
+	harFile := filepath.Join(t.TempDir(), "capture.har.gz")
+	if err := os.WriteFile(harFile, gzipData(t, har), 0644); err != nil {
+		t.Fatalf("Failed to create HAR file: %v", err)
+	}
+
+	output, err := executeCommand(rootCmd, "extract", "-f", harFile, "--list")
+	if err != nil {
+		t.Fatalf("Command failed: %v", err)
+	}
+	if !strings.Contains(output, "Found 1 SAML assertion(s)") {
+		t.Errorf("Expected the message of the gzipped HAR, got: %s", output)
+	}
+}
+
+func TestExtractSAZ(t *testing.T) {
+	defer func() {
+		extractFile = ""
+		extractList = false
+	}()
+
+	samlResponse := `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_fiddler"/>`
+	form := "SAMLResponse=" + url.QueryEscape(base64.StdEncoding.EncodeToString([]byte(samlResponse)))
+
+	var archive bytes.Buffer
+	w := zip.NewWriter(&archive)
+	f, err := w.Create("raw/1_c.txt")
+	if err != nil {
+		t.Fatalf("Failed to create SAZ archive: %v", err)
+	}
+	f.Write([]byte("POST https://sp.example.com/acs HTTP/1.1\r\nHost: sp.example.com\r\n" +
+		"Content-Type: application/x-www-form-urlencoded\r\nContent-Length: " + strconv.Itoa(len(form)) + "\r\n\r\n" + form))
+	if err := w.Close(); err != nil {
+		t.Fatalf("Failed to create SAZ archive: %v", err)
+	}
+	sazFile := filepath.Join(t.TempDir(), "capture.saz")
+	if err := os.WriteFile(sazFile, archive.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to create SAZ file: %v", err)
+	}
+
+	output, err := executeCommand(rootCmd, "extract", "-f", sazFile, "--list")
+	if err != nil {
+		t.Fatalf("Command failed: %v", err)
+	}
+	if !strings.Contains(output, "Found 1 SAML assertion(s)") {
+		t.Errorf("Expected the message of the SAZ archive, got: %s", output)
+	}
+}
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/spf13/cobra"
+)
+
+// readInputFile reads an input file, decompressing it if it is gzip or
+// zstd compressed, as browser and proxy exports often are
+func readInputFile(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readInput(f)
+}
+
+// readInput reads input from r, decompressing it if needed
+func readInput(r io.Reader) ([]byte, error) {
+	dr, err := saml.DecompressReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer dr.Close()
+	return io.ReadAll(dr)
+}
+
+// inputFiles returns the files named by -f flags followed by those named
+// by arguments. Arguments may be glob patterns, for shells that do not
+// expand them.
+func inputFiles(flagFiles, args []string) ([]string, error) {
+	files := append([]string(nil), flagFiles...)
+	for _, arg := range args {
+		if !strings.ContainsAny(arg, "*?[") {
+			files = append(files, arg)
+			continue
+		}
+		matches, err := filepath.Glob(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid file pattern %q: %w", arg, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no files match %q", arg)
+		}
+		files = append(files, matches...)
+	}
+	return files, nil
+}
+
+// forEachFile runs fn for each file, after a header naming it. The header
+// goes to stdout for pretty output and to stderr otherwise, to keep
+// machine-readable output intact. A failing file does not stop the others;
+// its error is reported and the first one decides the exit code.
+func forEachFile(cmd *cobra.Command, files []string, pretty bool, fn func(file string) error) error {
+	var first error
+	failed := 0
+	for i, file := range files {
+		if pretty {
+			if i > 0 {
+				fmt.Fprintln(cmd.OutOrStdout())
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "▶ [%d/%d] %s\n\n", i+1, len(files), file)
+		} else {
+			notef(cmd, "▶ [%d/%d] %s\n", i+1, len(files), file)
+		}
+
+		if err := fn(file); err != nil {
+			fmt.Fprintf(cmd.ErrOrStderr(), "Error: %s: %v\n", file, err)
+			failed++
+			if first == nil {
+				first = err
+			}
+		}
+	}
+	if first == nil {
+		return nil
+	}
+	return withExitCode(ExitCode(first), fmt.Errorf("%d of %d files failed", failed, len(files)))
+}
@@ -0,0 +1,15 @@
+package cmd
+
+import (
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/spf13/pflag"
+)
+
+// addFilterFlags registers the flags selecting which extracted messages
+// are processed
+func addFilterFlags(flags *pflag.FlagSet, filter *saml.MessageFilter) {
+	flags.StringSliceVar(&filter.Types, "type", nil, "Only process messages of these types, e.g. Response or AuthnRequest (repeatable)")
+	flags.StringVar(&filter.Issuer, "issuer", "", "Only process messages issued by this entity ID")
+	flags.StringVar(&filter.URLContains, "url-contains", "", "Only process messages found at a URL containing this text")
+	flags.StringSliceVar(&filter.Params, "param", nil, "Only process messages carried in these parameters, e.g. SAMLResponse (repeatable)")
+}
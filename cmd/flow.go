@@ -0,0 +1,219 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gliwka/SAMLurai/internal/flow"
+	"github.com/gliwka/SAMLurai/internal/output"
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/spf13/cobra"
+)
+
+var (
+	flowFile    string
+	flowDiagram string
+)
+
+var flowCmd = &cobra.Command{
+	Use:   "flow",
+	Short: "Show the login flow of a HAR capture as a sequence",
+	Long: `Show the exchange between the browser, the SPs and the IdPs in a HAR
+capture, in order: each request the browser sent and the reply it got,
+annotated with the SAML messages they carried, their binding and status.
+
+Hosts are told apart as SP or IdP by the messages they send and receive:
+an AuthnRequest goes from an SP to an IdP, and a Response back. Requests
+carrying SAML are shown, as are redirects and HTML pages of an SP or IdP;
+scripts, images and requests to other hosts are left out.
+
+With --diagram mermaid or --diagram plantuml the flow is written as a
+sequence diagram, e.g. for documentation or a support ticket.
+
+Examples:
+  # List the steps of the login
+  samlurai flow -f capture.har
+
+  # Mermaid sequence diagram, e.g. for a Markdown page
+  samlurai flow -f capture.har --diagram mermaid > login.mmd
+
+  # Render with PlantUML
+  samlurai flow -f capture.har --diagram plantuml | plantuml -pipe > login.png`,
+	Args: cobra.NoArgs,
+	RunE: runFlow,
+}
+
+var flowDiffCmd = &cobra.Command{
+	Use:   "diff GOOD BAD",
+	Short: "Compare the SAML messages of a working and a failing capture",
+	Long: `Compare the SAML messages of two HAR captures of a login, e.g. one that
+worked and one that failed, and show where they diverge.
+
+The messages are aligned by type and order, so a message only one capture
+has is reported as a missing or extra step. Messages at the same step are
+compared by:
+  - binding (HTTP-Redirect, HTTP-POST or HTTP-POST-SimpleSign)
+  - the HTTP status of the request delivering them
+  - SAML status
+  - whether the assertion is encrypted
+  - the names of the attributes
+  - the length of the validity window, and whether the message was
+    delivered within it
+
+A message the browser passes on, such as the Response in the IdP's form
+that is then posted to the ACS, is compared once, where it was delivered.
+
+The command exits with an error if the captures diverge.
+
+Examples:
+  # Find out why a login failed where another succeeded
+  samlurai flow diff good.har bad.har
+
+  # Machine-readable output
+  samlurai flow diff good.har bad.har -o json`,
+	Args: cobra.ExactArgs(2),
+	RunE: runFlowDiff,
+}
+
+func init() {
+	rootCmd.AddCommand(flowCmd)
+	flowCmd.AddCommand(flowDiffCmd)
+
+	flowCmd.Flags().StringVarP(&flowFile, "file", "f", "", "Read the HAR capture from file")
+	flowCmd.Flags().StringVar(&flowDiagram, "diagram", "", "Write a sequence diagram: "+strings.Join(flow.DiagramFormats, ", "))
+	_ = flowCmd.RegisterFlagCompletionFunc("diagram", cobra.FixedCompletions(flow.DiagramFormats, cobra.ShellCompDirectiveNoFileComp))
+}
+
+func runFlow(cmd *cobra.Command, args []string) error {
+	if flowDiagram != "" && flowDiagram != flow.DiagramMermaid && flowDiagram != flow.DiagramPlantUML {
+		return fmt.Errorf("invalid --diagram %q (expected %s)", flowDiagram, strings.Join(flow.DiagramFormats, " or "))
+	}
+
+	input, err := getInspectInput(cmd, flowFile)
+	if err != nil {
+		return err
+	}
+	har, err := parseFlowHAR(input)
+	if err != nil {
+		return err
+	}
+
+	seq := flow.NewSequence(har.Log.Entries)
+	if flowDiagram != "" {
+		return seq.WriteDiagram(cmd.OutOrStdout(), flowDiagram)
+	}
+
+	if output.NewFormatter(outputFormat).IsJSON() {
+		formatted, err := output.NewFormatter(outputFormat).FormatJSON(seq)
+		if err != nil {
+			return fmt.Errorf("failed to format output: %w", err)
+		}
+		fmt.Fprint(cmd.OutOrStdout(), formatted)
+		return nil
+	}
+
+	printSequence(cmd, seq)
+	return nil
+}
+
+func printSequence(cmd *cobra.Command, seq *flow.Sequence) {
+	w := cmd.OutOrStdout()
+	if len(seq.Arrows) == 0 {
+		fmt.Fprintln(w, "No SAML messages found in the HAR file.")
+		return
+	}
+
+	fmt.Fprintf(w, "▸ Participants\n")
+	for _, p := range seq.Participants[1:] {
+		fmt.Fprintf(w, "  %-8s  %s\n", p.ID, p.Host)
+	}
+
+	fmt.Fprintf(w, "\n▸ Flow\n")
+	for _, a := range seq.Arrows {
+		entry := fmt.Sprintf("%3d.", a.Entry)
+		if a.Reply {
+			entry = "    "
+		}
+		fmt.Fprintf(w, "  %s %s → %s  %s\n", entry, a.From, a.To, a.Label())
+	}
+}
+
+// parseFlowHAR parses a HAR capture for the flow commands
+func parseFlowHAR(input string) (*saml.HAR, error) {
+	if !saml.LooksLikeHAR(input) {
+		return nil, fmt.Errorf("flow requires a HAR file")
+	}
+	var har saml.HAR
+	if err := json.Unmarshal([]byte(input), &har); err != nil {
+		return nil, withExitCode(ExitParse, fmt.Errorf("failed to parse HAR file: %w", err))
+	}
+	return &har, nil
+}
+
+func runFlowDiff(cmd *cobra.Command, args []string) error {
+	var captures [2][]flow.Message
+	for i, path := range args {
+		data, err := readInputFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read file: %w", err)
+		}
+		har, err := parseFlowHAR(strings.TrimSpace(string(data)))
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		captures[i] = flow.Messages(har.Log.Entries)
+	}
+
+	diff := flow.Compare(captures[0], captures[1])
+
+	if output.NewFormatter(outputFormat).IsJSON() {
+		formatted, err := output.NewFormatter(outputFormat).FormatJSON(diff)
+		if err != nil {
+			return fmt.Errorf("failed to format output: %w", err)
+		}
+		fmt.Fprint(cmd.OutOrStdout(), formatted)
+	} else {
+		printFlowDiff(cmd, args, captures, diff)
+	}
+
+	if diff.DivergedAt > 0 {
+		return withExitCode(ExitFindings, fmt.Errorf("captures diverge at step %d", diff.DivergedAt))
+	}
+	return nil
+}
+
+// printFlowDiff lists the steps of both captures, marked = (same),
+// ~ (changed), - (only in the good capture) and + (only in the bad one)
+func printFlowDiff(cmd *cobra.Command, paths []string, captures [2][]flow.Message, diff *flow.Diff) {
+	w := cmd.OutOrStdout()
+	fmt.Fprintf(w, "Good: %s (%d message(s))\n", paths[0], len(captures[0]))
+	fmt.Fprintf(w, "Bad:  %s (%d message(s))\n\n", paths[1], len(captures[1]))
+
+	for i, step := range diff.Steps {
+		switch step.Kind {
+		case flow.DiffMissing:
+			fmt.Fprintf(w, "- %d. %s (only in good, entry %d)\n", i+1, describeFlowMessage(*step.Good), step.Good.Entry)
+		case flow.DiffExtra:
+			fmt.Fprintf(w, "+ %d. %s (only in bad, entry %d)\n", i+1, describeFlowMessage(*step.Bad), step.Bad.Entry)
+		default:
+			mark := "="
+			if step.Kind == flow.DiffChanged {
+				mark = "~"
+			}
+			fmt.Fprintf(w, "%s %d. %s (entries %d and %d)\n", mark, i+1, describeFlowMessage(*step.Good), step.Good.Entry, step.Bad.Entry)
+			for _, d := range step.Differences {
+				fmt.Fprintf(w, "     %s\n", d)
+			}
+		}
+	}
+
+	if diff.DivergedAt == 0 {
+		fmt.Fprintln(w, "\n✓ The captures do not diverge")
+	}
+}
+
+// describeFlowMessage summarizes a message, e.g. "Response (HTTP-POST, Success)"
+func describeFlowMessage(m flow.Message) string {
+	return flow.Annotation{Type: m.Type, Binding: m.Binding, Status: m.Status}.String()
+}
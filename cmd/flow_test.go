@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func resetFlowFlags() {
+	flowFile = ""
+	flowDiagram = ""
+	outputFormat = "pretty"
+}
+
+func TestFlowCmd(t *testing.T) {
+	resetFlowFlags()
+
+	harFile := createTempFile(t, checkFlowHAR("302"))
+	defer os.Remove(harFile)
+
+	output, err := executeCommand(rootCmd, "flow", "-f", harFile)
+	require.NoError(t, err)
+	assert.Contains(t, output, "IdP       idp.example.com")
+	assert.Contains(t, output, "  1. Browser → IdP  GET /sso — AuthnRequest (HTTP-Redirect)")
+	assert.Contains(t, output, "     IdP → Browser  200")
+}
+
+func TestFlowCmd_Mermaid(t *testing.T) {
+	resetFlowFlags()
+
+	harFile := createTempFile(t, checkFlowHAR("302"))
+	defer os.Remove(harFile)
+
+	output, err := executeCommand(rootCmd, "flow", "-f", harFile, "--diagram", "mermaid")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(output, "sequenceDiagram\n"), output)
+	assert.Contains(t, output, "    Browser->>IdP: GET /sso — AuthnRequest (HTTP-Redirect)\n")
+}
+
+func TestFlowCmd_PlantUML(t *testing.T) {
+	resetFlowFlags()
+
+	harFile := createTempFile(t, checkFlowHAR("302"))
+	defer os.Remove(harFile)
+
+	output, err := executeCommand(rootCmd, "flow", "-f", harFile, "--diagram", "plantuml")
+	require.NoError(t, err)
+	assert.Contains(t, output, "@startuml\n")
+	assert.Contains(t, output, "IdP --> Browser : 200\n")
+}
+
+func TestFlowCmd_InvalidDiagram(t *testing.T) {
+	resetFlowFlags()
+
+	_, err := executeCommand(rootCmd, "flow", "--diagram", "dot")
+	assert.EqualError(t, err, `invalid --diagram "dot" (expected mermaid or plantuml)`)
+}
+
+func TestFlowCmd_NotHAR(t *testing.T) {
+	resetFlowFlags()
+
+	file := createTempFile(t, "<samlp:Response/>")
+	defer os.Remove(file)
+
+	_, err := executeCommand(rootCmd, "flow", "-f", file)
+	assert.EqualError(t, err, "flow requires a HAR file")
+}
+
+func TestFlowDiffCmd(t *testing.T) {
+	resetFlowFlags()
+
+	good := createTempFile(t, checkFlowHAR("302"))
+	defer os.Remove(good)
+	bad := createTempFile(t, strings.Replace(checkFlowHAR("302"), `"status": 200`, `"status": 403`, 1))
+	defer os.Remove(bad)
+
+	output, err := executeCommand(rootCmd, "flow", "diff", good, bad)
+	require.Error(t, err)
+	assert.Equal(t, ExitFindings, ExitCode(err))
+	assert.EqualError(t, err, "captures diverge at step 1")
+	assert.Contains(t, output, "Good: "+good+" (1 message(s))")
+	assert.Contains(t, output, "~ 1. AuthnRequest (HTTP-Redirect) (entries 1 and 1)")
+	assert.Contains(t, output, "     HTTP status 200 → 403")
+}
+
+func TestFlowDiffCmd_Same(t *testing.T) {
+	resetFlowFlags()
+
+	good := createTempFile(t, checkFlowHAR("302"))
+	defer os.Remove(good)
+
+	output, err := executeCommand(rootCmd, "flow", "diff", good, good, "-o", "json")
+	require.NoError(t, err)
+	assert.Contains(t, output, `"kind": "same"`)
+}
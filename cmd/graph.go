@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/gliwka/SAMLurai/internal/graph"
+	"github.com/gliwka/SAMLurai/internal/output"
+	"github.com/spf13/cobra"
+)
+
+var (
+	graphDir string
+)
+
+var graphCmd = &cobra.Command{
+	Use:   "graph",
+	Short: "Map the SPs and IdPs observed across SAML captures",
+	Long: `Map the federation topology observed in all HAR, XML and base64 files
+in a directory (searched recursively): which SPs and IdPs exchanged which
+messages, and how often.
+
+Senders are taken from the Issuer of each message. Receivers are taken
+from the audience of assertions, or for requests from the IdP that
+answered them (matched by InResponseTo, across files). Requests that
+cannot be attributed to an entity point to their Destination URL.
+
+With -o dot the graph is written in the Graphviz DOT language, with edges
+labeled by message type and count.
+
+Examples:
+  # List the observed entities and message flows
+  samlurai graph --dir ./captures
+
+  # Render the topology with Graphviz
+  samlurai graph --dir ./captures -o dot | dot -Tsvg > federation.svg
+
+  # Machine-readable output
+  samlurai graph --dir ./captures -o json`,
+	RunE: runGraph,
+}
+
+func init() {
+	rootCmd.AddCommand(graphCmd)
+
+	graphCmd.Flags().StringVarP(&graphDir, "dir", "d", "", "Directory of captures to map (required)")
+	_ = graphCmd.MarkFlagRequired("dir")
+}
+
+func runGraph(cmd *cobra.Command, args []string) error {
+	c := graph.NewCollector()
+	if err := c.CollectDir(graphDir); err != nil {
+		return fmt.Errorf("failed to read directory: %w", err)
+	}
+	g := c.Graph()
+
+	if outputFormat == "dot" {
+		return g.WriteDOT(cmd.OutOrStdout())
+	}
+
+	if output.NewFormatter(outputFormat).IsJSON() {
+		formatted, err := output.NewFormatter(outputFormat).FormatJSON(g)
+		if err != nil {
+			return fmt.Errorf("failed to format output: %w", err)
+		}
+		fmt.Fprint(cmd.OutOrStdout(), formatted)
+		return nil
+	}
+
+	w := cmd.OutOrStdout()
+	fmt.Fprintf(w, "Files: %d (%d without SAML)\n", g.Files, g.FilesFailed)
+
+	if len(g.Nodes) > 0 {
+		fmt.Fprintf(w, "\n▸ Entities\n")
+		for _, n := range g.Nodes {
+			fmt.Fprintf(w, "  %-8s  %s\n", n.Role, n.ID)
+		}
+	}
+	if len(g.Edges) > 0 {
+		fmt.Fprintf(w, "\n▸ Messages\n")
+		for _, e := range g.Edges {
+			fmt.Fprintf(w, "  %6d  %s: %s → %s\n", e.Count, e.Type, e.From, e.To)
+		}
+	}
+	return nil
+}
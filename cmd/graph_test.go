@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGraphCmd(t *testing.T) {
+	graphDir = ""
+	outputFormat = "pretty"
+
+	fixtureDir := filepath.Join("..", "testdata", "fixtures", "assertions")
+
+	output, err := executeCommand(rootCmd, "graph", "--dir", fixtureDir)
+	require.NoError(t, err)
+	assert.Contains(t, output, "Files: 3")
+	assert.Contains(t, output, "IdP       https://idp.example.com")
+	assert.Contains(t, output, "     1  Response: https://idp.example.com → https://sp.example.com")
+}
+
+func TestGraphCmd_DOT(t *testing.T) {
+	graphDir = ""
+	defer func() { outputFormat = "pretty" }()
+
+	fixtureDir := filepath.Join("..", "testdata", "fixtures", "assertions")
+
+	output, err := executeCommand(rootCmd, "graph", "--dir", fixtureDir, "-o", "dot")
+	require.NoError(t, err)
+	assert.Contains(t, output, "digraph federation {")
+	assert.Contains(t, output, `"https://sp.example.com" -> "https://idp.example.com/sso" [label="AuthnRequest (1)"];`)
+}
@@ -0,0 +1,276 @@
+package cmd
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gliwka/SAMLurai/internal/idp"
+	"github.com/gliwka/SAMLurai/internal/metadata"
+	"github.com/gliwka/SAMLurai/internal/metrics"
+	"github.com/gliwka/SAMLurai/internal/output"
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/spf13/cobra"
+)
+
+var (
+	idpAddr           string
+	idpBaseURL        string
+	idpEntityID       string
+	idpSignKey        string
+	idpSignCert       string
+	idpSigAlg         string
+	idpNameID         string
+	idpNameIDFormat   string
+	idpAttributes     []string
+	idpSignAssertion  bool
+	idpSignResponse   bool
+	idpEncryptionCert string
+	idpACS            string
+	idpAudience       string
+	idpLifetime       time.Duration
+	idpMetrics        bool
+)
+
+var idpCmd = &cobra.Command{
+	Use:   "idp",
+	Short: "Run a minimal test IdP for end-to-end SSO tests",
+	Long: `Run a local identity provider that signs in a single test user without
+asking for credentials, so an SP can be tested end to end without setting
+up a full IdP.
+
+The IdP serves:
+  /metadata   its metadata, to register it with the SP
+  /sso        the SingleSignOnService, for the Redirect and POST bindings
+  /login      IdP-initiated login, with --acs and --audience
+  /metrics    with --metrics, counts of the AuthnRequests received and of
+              those that failed to parse or verify, for Prometheus
+
+Every AuthnRequest is answered with a successful Response, posted to the
+AssertionConsumerServiceURL of the request (or --acs) by an auto-submitting
+form. The assertion is signed by default; --sign-response signs the
+Response as well, and --encryption-cert encrypts the assertion for the SP
+with RSA-OAEP and AES-256-CBC. Without --sign-key, a key and self-signed
+certificate are generated at startup and published in the metadata.
+
+Each Response sent is printed; use -o jsonl for one JSON object per
+Response, including its XML.
+
+NameID formats: email, persistent, transient, unspecified, entity, or a
+format URI. Without --nameid-format, the format the SP asks for in its
+NameIDPolicy is used.
+
+Examples:
+  # Start an IdP with a generated key and register http://127.0.0.1:8081/metadata
+  samlurai idp
+
+  # Sign in bob with attributes, using a fixed key
+  samlurai idp --sign-key idp-key.pem --sign-cert idp.pem \
+    --nameid bob@example.com --nameid-format email \
+    --attribute mail=bob@example.com --attribute groups=staff --attribute groups=admins
+
+  # Signed Response with an encrypted assertion
+  samlurai idp --sign-response --encryption-cert sp.pem
+
+  # IdP-initiated login: open http://127.0.0.1:8081/login?RelayState=/home
+  samlurai idp --acs https://sp.example.com/acs --audience https://sp.example.com`,
+	RunE: runIdP,
+}
+
+func init() {
+	rootCmd.AddCommand(idpCmd)
+
+	flags := idpCmd.Flags()
+	flags.StringVar(&idpAddr, "addr", "127.0.0.1:8081", "Address to listen on")
+	flags.StringVar(&idpBaseURL, "base-url", "", "URL the IdP is reachable at (default: http://<addr>)")
+	flags.StringVar(&idpEntityID, "entity-id", "", "Entity ID of the IdP (default: the metadata URL)")
+	flags.StringVar(&idpSignKey, "sign-key", "", "Private key to sign with (PEM) (default: generated)")
+	flags.StringVar(&idpSignCert, "sign-cert", "", "Certificate of the signing key, published in the metadata")
+	flags.StringVar(&idpSigAlg, "sig-alg", "", "Signature algorithm, e.g. rsa-sha256 (default: from key type)")
+	flags.StringVar(&idpNameID, "nameid", "alice@example.com", "NameID of the test user")
+	flags.StringVar(&idpNameIDFormat, "nameid-format", "", "NameID format (default: as requested by the SP)")
+	flags.StringArrayVar(&idpAttributes, "attribute", nil, "Attribute as name=value (repeatable; repeat a name for multiple values)")
+	flags.BoolVar(&idpSignAssertion, "sign-assertion", true, "Sign the assertion")
+	flags.BoolVar(&idpSignResponse, "sign-response", false, "Sign the Response")
+	flags.StringVar(&idpEncryptionCert, "encryption-cert", "", "Encrypt assertions for this SP certificate (PEM or DER)")
+	flags.StringVar(&idpACS, "acs", "", "ACS URL for requests without one and for IdP-initiated login")
+	flags.StringVar(&idpAudience, "audience", "", "Entity ID of the SP (default: the Issuer of the AuthnRequest)")
+	flags.DurationVar(&idpLifetime, "lifetime", 5*time.Minute, "How long assertions are valid")
+	flags.BoolVar(&idpMetrics, "metrics", false, "Serve Prometheus metrics at /metrics")
+}
+
+func runIdP(cmd *cobra.Command, args []string) error {
+	formatter := output.NewFormatter(outputFormat)
+	if outputFormat != "pretty" && !formatter.IsJSONL() {
+		return fmt.Errorf("idp supports pretty and jsonl output, not %q", outputFormat)
+	}
+
+	baseURL := idpBaseURL
+	if baseURL == "" {
+		baseURL = "http://" + idpAddr
+	}
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	cfg := idp.Config{
+		EntityID:      idpEntityID,
+		BaseURL:       baseURL,
+		NameID:        idpNameID,
+		SignAssertion: idpSignAssertion,
+		SignResponse:  idpSignResponse,
+		ACS:           idpACS,
+		Audience:      idpAudience,
+		Lifetime:      idpLifetime,
+	}
+	if cfg.EntityID == "" {
+		cfg.EntityID = baseURL + idp.MetadataPath
+	}
+
+	var err error
+	if cfg.Key, cfg.Cert, err = loadSigningKeyPair(idpSignKey, idpSignCert, cfg.EntityID); err != nil {
+		return err
+	}
+	if cfg.SigAlg, err = resolveSigAlgFor(idpSigAlg, cfg.Key); err != nil {
+		return err
+	}
+	if idpNameIDFormat != "" {
+		if cfg.NameIDFormat, err = metadata.ResolveNameIDFormat(idpNameIDFormat); err != nil {
+			return err
+		}
+	}
+	if cfg.Attributes, err = parseAttributeFlags(idpAttributes); err != nil {
+		return err
+	}
+	if idpEncryptionCert != "" {
+		if cfg.EncryptionCert, err = saml.LoadCertificate(idpEncryptionCert); err != nil {
+			return fmt.Errorf("encryption certificate: %w", err)
+		}
+	}
+
+	handler, err := idp.NewServer(cfg)
+	if err != nil {
+		return err
+	}
+	out := cmd.OutOrStdout()
+	var mu sync.Mutex
+	handler.OnIssue(func(issued idp.Issued) {
+		mu.Lock()
+		defer mu.Unlock()
+		if formatter.IsJSONL() {
+			_ = json.NewEncoder(out).Encode(issued)
+			return
+		}
+		printIssued(cmd, issued)
+	})
+	if idpMetrics {
+		handler.WithMetrics(metrics.New())
+	}
+
+	if idpSignKey == "" {
+		notef(cmd, "Signing with a generated key; its certificate is in the metadata\n")
+	}
+	notef(cmd, "SAMLurai test IdP listening on %s\n", baseURL)
+	notef(cmd, "  Entity ID: %s\n", cfg.EntityID)
+	notef(cmd, "  Metadata:  %s%s\n", baseURL, idp.MetadataPath)
+	notef(cmd, "  SSO:       %s%s\n", baseURL, idp.SSOPath)
+	if cfg.ACS != "" && cfg.Audience != "" {
+		notef(cmd, "  Login:     %s%s\n", baseURL, idp.LoginPath)
+	}
+	if idpMetrics {
+		notef(cmd, "  Metrics:   %s%s\n", baseURL, metrics.Path)
+	}
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+	defer stop()
+	return listenAndServe(ctx, idpAddr, handler)
+}
+
+// printIssued prints a line describing a Response sent by the test IdP
+func printIssued(cmd *cobra.Command, issued idp.Issued) {
+	var props []string
+	if issued.Signed {
+		props = append(props, "signed")
+	}
+	if issued.Encrypted {
+		props = append(props, "encrypted")
+	}
+	request := "IdP-initiated"
+	if issued.RequestID != "" {
+		request = "in response to " + issued.RequestID
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "%s  Response %s %s → %s\n", time.Now().Format("15:04:05"), issued.ResponseID, request, issued.ACS)
+	fmt.Fprintf(cmd.OutOrStdout(), "          NameID %s for %s", issued.NameID, issued.Audience)
+	if len(props) > 0 {
+		fmt.Fprintf(cmd.OutOrStdout(), " (%s)", strings.Join(props, ", "))
+	}
+	fmt.Fprintln(cmd.OutOrStdout())
+}
+
+// loadSigningKeyPair loads a key and its certificate, or generates both
+// for commonName if no key is given
+func loadSigningKeyPair(keyPath, certPath, commonName string) (crypto.Signer, *x509.Certificate, error) {
+	if keyPath == "" {
+		if certPath != "" {
+			return nil, nil, fmt.Errorf("--sign-cert requires --sign-key")
+		}
+		return saml.NewSelfSignedKey(commonName)
+	}
+	if certPath == "" {
+		return nil, nil, fmt.Errorf("--sign-key requires --sign-cert")
+	}
+	key, err := saml.LoadSigningKey(keyPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load private key: %w", err)
+	}
+	cert, err := saml.LoadCertificate(certPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, cert, nil
+}
+
+// parseAttributeFlags parses name=value attributes, collecting the values
+// of repeated names in order
+func parseAttributeFlags(values []string) ([]saml.Attribute, error) {
+	var attrs []saml.Attribute
+	index := map[string]int{}
+	for _, value := range values {
+		name, v, ok := strings.Cut(value, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid attribute %q, expected name=value", value)
+		}
+		if i, seen := index[name]; seen {
+			attrs[i].Values = append(attrs[i].Values, v)
+			continue
+		}
+		index[name] = len(attrs)
+		attrs = append(attrs, saml.Attribute{Name: name, Values: []string{v}})
+	}
+	return attrs, nil
+}
+
+// listenAndServe serves handler on addr until ctx is done
+func listenAndServe(ctx context.Context, addr string, handler http.Handler) error {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return fmt.Errorf("failed to start server: %w", err)
+	}
+	return nil
+}
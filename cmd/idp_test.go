@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAttributeFlags(t *testing.T) {
+	attrs, err := parseAttributeFlags([]string{"mail=alice@example.com", "groups=staff", "groups=admins", "note=a=b"})
+	require.NoError(t, err)
+	assert.Equal(t, []saml.Attribute{
+		{Name: "mail", Values: []string{"alice@example.com"}},
+		{Name: "groups", Values: []string{"staff", "admins"}},
+		{Name: "note", Values: []string{"a=b"}},
+	}, attrs)
+
+	_, err = parseAttributeFlags([]string{"mail"})
+	assert.ErrorContains(t, err, `invalid attribute "mail"`)
+}
+
+func TestLoadSigningKeyPair(t *testing.T) {
+	key, cert, err := loadSigningKeyPair("", "", "https://idp.example.com")
+	require.NoError(t, err)
+	assert.NotNil(t, key)
+	assert.Equal(t, "https://idp.example.com", cert.Subject.CommonName)
+
+	_, _, err = loadSigningKeyPair("key.pem", "", "https://idp.example.com")
+	assert.ErrorContains(t, err, "--sign-key requires --sign-cert")
+
+	_, _, err = loadSigningKeyPair("", "cert.pem", "https://idp.example.com")
+	assert.ErrorContains(t, err, "--sign-cert requires --sign-key")
+}
+
+func TestIdPCmd_UnsupportedOutput(t *testing.T) {
+	defer func() { outputFormat = "pretty" }()
+
+	_, err := executeCommand(rootCmd, "idp", "-o", "csv")
+	assert.ErrorContains(t, err, `idp supports pretty and jsonl output, not "csv"`)
+}
@@ -1,24 +1,55 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
-	"io"
 	"os"
-	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/gliwka/SAMLurai/internal/clipboard"
+	"github.com/gliwka/SAMLurai/internal/hsm"
+	"github.com/gliwka/SAMLurai/internal/inspect"
 	"github.com/gliwka/SAMLurai/internal/output"
+	"github.com/gliwka/SAMLurai/internal/redact"
 	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/gliwka/SAMLurai/internal/trace"
 	"github.com/spf13/cobra"
 )
 
 var (
-	inspectFile   string
-	inspectKey    string
+	inspectFiles   []string
+	inspectKey     string
+	inspectMinConf float64
+	inspectDedupe  bool
+	inspectFilter  saml.MessageFilter
+	inspectIndex   int
+	inspectRedact  redactOptions
+	inspectLast    bool
+	inspectReport  string
+	inspectDest    string
+	inspectAud     string
+	inspectStrict  bool
+	inspectURLNorm string
+	inspectTmpl    string
+	inspectMaxLen  int
+	inspectDump    []string
+	inspectNow     string
+	inspectSkew    time.Duration
+	inspectKeyMap  string
+	inspectKeyEnv  string
+	inspectPKCS11  hsm.Config
+	inspectKMSKey  string
+	inspectTrace   bool
+	inspectShowRaw bool
+
+	inspectDir       string
+	inspectRecursive bool
+	inspectSockets   bool
 )
 
 var inspectCmd = &cobra.Command{
-	Use:   "inspect",
+	Use:   "inspect [FILE...]",
 	Short: "Inspect and display SAML assertion details",
 	Long: `Parse and display SAML assertion details in a human-readable format.
 
@@ -26,8 +57,14 @@ The input can be:
   - A SAML XML file
   - A base64-encoded SAML file
   - A HAR (HTTP Archive) file - displays all SAML assertions in order
+  - A SAML-tracer browser extension export (JSON)
   - Data from stdin (pipe)
 
+Several files, given with repeated -f flags or as arguments (glob patterns
+are expanded), are inspected one after the other, each under a header
+naming it. A file that fails does not stop the others; the exit code is
+that of the first failure.
+
 This command automatically:
   - Detects HAR files and extracts all SAML assertions
   - Decodes base64-encoded input (with optional deflate)
@@ -50,129 +87,510 @@ Examples:
   # Inspect HAR file with decryption key
   samlurai inspect -f session.har -k private.pem
 
+  # Mask personal data before sharing the output
+  samlurai inspect -f session.har --redact --keep-attribute groups
+
+  # Re-inspect only the third message of a HAR file, as JSON
+  samlurai inspect -f session.har --index 3 -o json
+
   # Inspect base64-encoded SAML (auto-decoded)
   echo "PHNhbWw+Li4uPC9zYW1sPg==" | samlurai inspect
 
   # Inspect encrypted assertion (auto-decrypted)
   samlurai inspect -f encrypted.xml -k private.pem
 
+  # Decrypt with a key held in an environment variable, e.g. a CI secret
+  samlurai inspect -f session.har --key-env SP_PRIVATE_KEY
+
+  # Decrypt each message with the key of its tenant's IdP
+  samlurai inspect -f capture.har --key-map tenant-keys.yaml
+
   # Output as JSON
-  samlurai inspect -f assertion.xml -o json`,
+  samlurai inspect -f assertion.xml -o json
+
+  # Attribute table across all messages in a HAR
+  samlurai inspect -f session.har -o csv > attributes.csv
+
+  # Write an HTML report for a support ticket
+  samlurai inspect -f session.har --report report.html
+
+  # Export a HAR flow as an OpenTelemetry trace (OTLP/JSON)
+  samlurai inspect -f session.har -o otlp-trace > trace.json
+
+  # Shape output for scripts with a Go template
+  samlurai inspect -f response.xml --template '{{.Assertion.Subject.NameID}} {{.Issuer}}'
+
+  # Check Destination, Recipient and Audience against the SP configuration
+  samlurai inspect -f response.xml --destination https://sp.example.com/acs --audience https://sp.example.com -o jsonl
+
+  # Write the full value of a long attribute to a file
+  samlurai inspect -f response.xml --dump-attribute userCertificate=cert.b64
+
+  # Check validity at the time a ticket was raised, allowing 5 minutes of skew
+  samlurai inspect -f response.xml --now 2024-01-15T10:30:00Z --clock-skew 5m
+
+  # Trace each decoding step as JSON lines on stderr
+  samlurai inspect -f request.txt --trace 2> trace.jsonl
+
+  # Show the original XML of the third message of a HAR, to copy it unchanged
+  samlurai inspect -f session.har --index 3 --show-raw
+
+  # Sweep a directory of saved assertions
+  samlurai inspect captures/*.xml
+
+  # Summarize a support bundle of HAR, XML and base64 files
+  samlurai inspect -d ./captures --recursive
+
+Validity (NotBefore/NotOnOrAfter, SessionNotOnOrAfter and the signing
+certificate) is evaluated at the current time, or for HAR files at the
+capture time of each request. --now overrides both.`,
 	RunE: runInspect,
 }
 
 func init() {
 	rootCmd.AddCommand(inspectCmd)
 
-	inspectCmd.Flags().StringVarP(&inspectFile, "file", "f", "", "Read SAML from file (supports XML, base64, or HAR files)")
-	inspectCmd.Flags().StringVarP(&inspectKey, "key", "k", "", "Path to private key for decryption (PEM format)")
+	inspectCmd.Flags().StringArrayVarP(&inspectFiles, "file", "f", nil, "Read SAML from file (supports XML, base64, or HAR files; repeatable)")
+	inspectCmd.Flags().StringVarP(&inspectKey, "key", "k", "", "Path to private key for decryption (PEM format), or - to read it from stdin")
+	inspectCmd.Flags().StringVar(&inspectKeyEnv, "key-env", "", "Environment variable holding the private key (PEM or base64-encoded PEM)")
+	addPKCS11Flags(inspectCmd.Flags(), &inspectPKCS11)
+	inspectCmd.Flags().StringVar(&inspectKMSKey, "kms-key", "", kmsKeyUsage)
+	inspectCmd.Flags().StringVar(&inspectKeyMap, "key-map", "", "YAML file mapping issuer entity IDs to private key paths, for per-tenant keys")
+	inspectCmd.Flags().Float64Var(&inspectMinConf, "min-confidence", 0, "Only show HAR messages with at least this confidence score (0-1)")
+	addFilterFlags(inspectCmd.Flags(), &inspectFilter)
+	addRedactFlags(inspectCmd.Flags(), &inspectRedact)
+	inspectCmd.Flags().IntVar(&inspectIndex, "index", 0, "Only show the HAR message at this position, as numbered in the full output")
+	inspectCmd.Flags().BoolVar(&inspectLast, "last", false, "Only show the last HAR message")
+	inspectCmd.Flags().BoolVar(&inspectDedupe, "dedupe", false, "Collapse HAR messages recorded more than once, e.g. by browser preloads or retries")
+	inspectCmd.Flags().StringVar(&inspectReport, "report", "", "Also write a self-contained HTML report to this file")
+	inspectCmd.Flags().StringVar(&inspectDest, "destination", "", "Expected Destination/Recipient URL (HAR files use each request URL)")
+	inspectCmd.Flags().StringVar(&inspectAud, "audience", "", "Expected audience (SP entity ID)")
+	inspectCmd.Flags().StringVar(&inspectTmpl, "template", "", "Format each message with a Go template applied to the parsed message")
+	inspectCmd.Flags().BoolVar(&inspectStrict, "strict-urls", false, "Compare URLs exactly instead of normalizing trailing slashes, default ports and host case")
+	inspectCmd.Flags().StringVar(&inspectURLNorm, "url-normalization", "", "Rules URLs are normalized with before they are compared: none, or a comma-separated list of trim_trailing_slash, strip_default_port, ignore_host_case and ignore_path_case (default: all but ignore_path_case)")
+	inspectCmd.Flags().IntVar(&inspectMaxLen, "max-value-length", 120, "Truncate attribute values longer than this in pretty output (0 to disable)")
+	inspectCmd.Flags().StringArrayVar(&inspectDump, "dump-attribute", nil, "Write the full values of an attribute to a file, as NAME=FILE (repeatable)")
+	inspectCmd.Flags().StringVar(&inspectNow, "now", "", "Evaluate validity at this time (RFC 3339) instead of the current or capture time")
+	inspectCmd.Flags().DurationVar(&inspectSkew, "clock-skew", 0, "Clock skew to tolerate when evaluating validity, e.g. 5m")
+	inspectCmd.Flags().BoolVar(&inspectTrace, "trace", false, "Write each decoding step (base64 variant, inflate, type detection, decryption, parsing) with sizes and timings as JSON lines to stderr")
+	inspectCmd.Flags().StringVarP(&inspectDir, "dir", "d", "", "Inspect every HAR, XML and base64 file in this directory and print a consolidated summary")
+	inspectCmd.Flags().BoolVar(&inspectRecursive, "recursive", false, "With --dir, also inspect files in subdirectories")
+	inspectCmd.Flags().BoolVar(&inspectSockets, "websockets", false, "Also search the WebSocket frames of HAR files (recorded by Chrome) for SAML")
+	inspectCmd.Flags().BoolVar(&inspectShowRaw, "show-raw", false, "Show the original XML of each message and assertion, byte for byte, alongside the parsed details (raw_xml in JSON)")
+}
+
+// inspectOptions holds the flag values for a single inspect invocation
+type inspectOptions struct {
+	file           string
+	key            string
+	keyEnv         string
+	pkcs11         hsm.Config
+	kmsKey         string
+	keyMap         inspect.KeyMap
+	minConfidence  float64
+	dedupe         bool
+	filter         saml.MessageFilter
+	selected       int
+	redact         redactOptions
+	report         string
+	format         string
+	destination    string
+	audience       string
+	urls           *saml.URLNormalization
+	template       string
+	maxValueLength int
+	dumpAttributes []string
+	now            time.Time
+	clockSkew      time.Duration
+	showRaw        bool
+	dir            string
+	recursive      bool
+	webSockets     bool
+}
+
+// formatter returns the output formatter for the flags, with the
+// reference time and clock skew used for validity
+func (o inspectOptions) formatter() *output.Formatter {
+	formatter := output.NewFormatter(o.format).WithMaxValueLength(o.maxValueLength).WithClockSkew(o.clockSkew)
+	if !o.now.IsZero() {
+		formatter.WithReferenceTime(o.now, "now")
+	}
+	return formatter
+}
+
+// parseReferenceTime parses the --now flag
+func parseReferenceTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid --now %q: expected an RFC 3339 time such as 2024-01-15T10:30:00Z", value)
+	}
+	return t, nil
+}
+
+// selectedMessage returns the inspect.Request.Select value for the --index
+// and --last flags
+func selectedMessage(index int, last bool) (int, error) {
+	switch {
+	case index != 0 && last:
+		return 0, fmt.Errorf("only one of --index and --last may be given")
+	case index < 0:
+		return 0, fmt.Errorf("invalid --index %d: messages are numbered from 1", index)
+	case last:
+		return inspect.SelectLast, nil
+	}
+	return index, nil
+}
+
+// urlNormalization returns the URL comparison rules selected by
+// --strict-urls and --url-normalization, or nil for the default rules
+func urlNormalization(strict bool, rules string) (*saml.URLNormalization, error) {
+	switch {
+	case strict:
+		n := saml.StrictURLNormalization()
+		return &n, nil
+	case rules == "":
+		return nil, nil
+	}
+	n, err := saml.ParseURLNormalization(rules)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --url-normalization: %w", err)
+	}
+	return &n, nil
+}
+
+// messageFormatter returns the formatter for msg. Its warnings are listed
+// in pretty output when --destination or --audience asked for the checks.
+func (o inspectOptions) messageFormatter(formatter *output.Formatter, msg inspect.Message) *output.Formatter {
+	if o.destination == "" && o.audience == "" {
+		return formatter
+	}
+	return formatter.WithWarnings(msg.Warnings())
+}
+
+// request builds the inspection of one input from the options
+func (o inspectOptions) request(input, filename string, redactor *redact.Redactor, keyPath string, decryptor *saml.Decryptor) inspect.Request {
+	return inspect.Request{
+		Input:         input,
+		Filename:      filename,
+		KeyPath:       keyPath,
+		Decryptor:     decryptor,
+		KeyMap:        o.keyMap,
+		MinConfidence: o.minConfidence,
+		Filter:        o.filter,
+		Dedupe:        o.dedupe,
+		Select:        o.selected,
+		Redactor:      redactor,
+		Destination:   o.destination,
+		Audience:      o.audience,
+		URLs:          o.urls,
+		Now:           o.now,
+		ClockSkew:     o.clockSkew,
+		RawXML:        o.showRaw,
+		WebSockets:    o.webSockets,
+	}
 }
 
 func runInspect(cmd *cobra.Command, args []string) error {
-	input, err := getInspectInput(cmd)
+	files, err := inputFiles(inspectFiles, args)
+	if err != nil {
+		return err
+	}
+	opts := inspectOptions{
+		key:            inspectKey,
+		keyEnv:         inspectKeyEnv,
+		pkcs11:         inspectPKCS11,
+		kmsKey:         inspectKMSKey,
+		minConfidence:  inspectMinConf,
+		dedupe:         inspectDedupe,
+		filter:         inspectFilter,
+		redact:         inspectRedact,
+		report:         inspectReport,
+		format:         outputFormat,
+		destination:    inspectDest,
+		audience:       inspectAud,
+		template:       inspectTmpl,
+		maxValueLength: inspectMaxLen,
+		dumpAttributes: inspectDump,
+		clockSkew:      inspectSkew,
+		showRaw:        inspectShowRaw,
+		dir:            inspectDir,
+		recursive:      inspectRecursive,
+		webSockets:     inspectSockets,
+	}
+	if err := opts.validateDir(len(files)); err != nil {
+		return err
+	}
+	if len(files) > 0 {
+		opts.file = files[0]
+	}
+	if len(files) > 1 && opts.report != "" {
+		return fmt.Errorf("--report takes a single input file")
+	}
+	if opts.selected, err = selectedMessage(inspectIndex, inspectLast); err != nil {
+		return err
+	}
+	redactor, err := opts.redact.redactor()
 	if err != nil {
 		return err
 	}
+	if opts.now, err = parseReferenceTime(inspectNow); err != nil {
+		return err
+	}
+	if inspectStrict && cmd.Flags().Changed("url-normalization") {
+		return fmt.Errorf("only one of --strict-urls and --url-normalization may be given")
+	}
+	if opts.urls, err = urlNormalization(inspectStrict, inspectURLNorm); err != nil {
+		return err
+	}
+	if inspectKeyMap != "" {
+		if opts.keyMap, err = inspect.LoadKeyMap(inspectKeyMap); err != nil {
+			return err
+		}
+	}
+
+	var tmpl *output.Template
+	if opts.template != "" {
+		if tmpl, err = output.NewTemplate(opts.template); err != nil {
+			return err
+		}
+	}
+
+	// A key on stdin must be read before the input is looked for there
+	keyPath := opts.key
+	var decryptor *saml.Decryptor
+	keys := keySource{path: opts.key, env: opts.keyEnv, pkcs11: opts.pkcs11, kms: opts.kmsKey, inputFile: opts.file}
+	if opts.dir != "" {
+		keys.inputFile = opts.dir
+	}
+	if err := keys.validate(); err != nil {
+		return err
+	}
+	if keys.inline() {
+		if decryptor, err = keys.load(cmd); err != nil {
+			return withExitCode(ExitDecrypt, fmt.Errorf("failed to load private key: %w", err))
+		}
+		keyPath = ""
+	}
+
+	if opts.dir != "" {
+		return runInspectDir(cmd, opts, redactor, keyPath, decryptor)
+	}
+	if len(files) > 1 {
+		return forEachFile(cmd, files, opts.format == "pretty", func(file string) error {
+			opts.file = file
+			return inspectInput(cmd, opts, redactor, keyPath, decryptor, tmpl)
+		})
+	}
+	return inspectInput(cmd, opts, redactor, keyPath, decryptor, tmpl)
+}
+
+// inspectInput inspects the input named by opts.file, or stdin
+func inspectInput(cmd *cobra.Command, opts inspectOptions, redactor *redact.Redactor, keyPath string, decryptor *saml.Decryptor, tmpl *output.Template) error {
+	input, err := getInspectInput(cmd, opts.file)
+	if err != nil {
+		return err
+	}
+
+	// The trace is written even if decoding fails, which is when it helps
+	var tr *trace.Trace
+	if inspectTrace {
+		tr = trace.New()
+		defer func() { _ = tr.WriteJSONL(cmd.ErrOrStderr()) }()
+	}
+
+	req := opts.request(input, opts.file, redactor, keyPath, decryptor)
+	req.Trace = tr
+	result, err := inspect.Run(cmd.Context(), req)
+	if err != nil {
+		return err
+	}
+
+	if err := dumpAttributes(cmd, opts.dumpAttributes, result.Messages); err != nil {
+		return err
+	}
 
 	// Check if input is a HAR file
-	if isHARFile(inspectFile, input) {
-		return runInspectHAR(cmd, []byte(input))
+	if result.IsHAR {
+		return runInspectHAR(cmd, opts, result, tmpl)
 	}
 
 	// Regular SAML inspection
-	return runInspectSAML(cmd, input)
+	return runInspectSAML(cmd, opts, result.Messages[0], tmpl)
 }
 
-// isHARFile checks if the input is likely a HAR file
-func isHARFile(filename, content string) bool {
-	// Check file extension
-	if filename != "" {
-		ext := strings.ToLower(filepath.Ext(filename))
-		if ext == ".har" {
-			return true
+// runInspectHAR handles inspection of HAR files
+func runInspectHAR(cmd *cobra.Command, opts inspectOptions, result *inspect.Result, tmpl *output.Template) error {
+	if opts.report != "" {
+		if err := writeReport(cmd, opts.report, opts.file, reportEntries(result.Messages)); err != nil {
+			return err
 		}
 	}
-	
-	// Check content for HAR JSON structure
-	trimmed := strings.TrimSpace(content)
-	if strings.HasPrefix(trimmed, "{") && strings.Contains(trimmed, `"log"`) && strings.Contains(trimmed, `"entries"`) {
-		return true
+
+	formatter := opts.formatter()
+
+	if len(result.Messages) == 0 {
+		// Keep stdout clean for pipelines
+		out := cmd.OutOrStdout()
+		if formatter.IsJSONL() || formatter.IsPSObject() {
+			out = cmd.ErrOrStderr()
+		}
+		fmt.Fprintln(out, "No SAML assertions found in the HAR file.")
+		return nil
 	}
-	
-	return false
-}
 
-// runInspectHAR handles inspection of HAR files
-func runInspectHAR(cmd *cobra.Command, data []byte) error {
-	extractor := saml.NewHARExtractor()
-	results, err := extractor.ExtractFromHAR(data)
-	if err != nil {
-		return fmt.Errorf("failed to parse HAR file: %w", err)
+	// Trace export covers the whole flow rather than individual messages
+	if opts.format == "otlp-trace" {
+		formatted, err := formatter.FormatOTLPTrace(result.Extracted)
+		if err != nil {
+			return fmt.Errorf("failed to format output: %w", err)
+		}
+		fmt.Fprint(cmd.OutOrStdout(), formatted)
+		return nil
 	}
 
-	if len(results) == 0 {
-		fmt.Fprintln(cmd.OutOrStdout(), "No SAML assertions found in the HAR file.")
+	// Templates replace the banners as well, so the output is script-friendly
+	if tmpl != nil {
+		for _, msg := range result.Messages {
+			if msg.Err != nil {
+				notef(cmd, "⚠️  Skipping message %d: %v\n", msg.Index(), msg.Err)
+				continue
+			}
+			formatted, err := tmpl.Execute(msg.Info)
+			if err != nil {
+				notef(cmd, "⚠️  Skipping message %d: %v\n", msg.Index(), err)
+				continue
+			}
+			fmt.Fprint(cmd.OutOrStdout(), formatted)
+		}
 		return nil
 	}
 
-	formatter := output.NewFormatter(outputFormat)
-	
+	// JSONL output has one compact object per message and no banners
+	if formatter.IsJSONL() {
+		formatted, err := formatter.FormatJSONL(jsonlRecords(result.Messages))
+		if err != nil {
+			return fmt.Errorf("failed to format output: %w", err)
+		}
+		fmt.Fprint(cmd.OutOrStdout(), formatted)
+		return nil
+	}
+
+	// PowerShell reads a single document, so messages become one JSON array
+	if formatter.IsPSObject() {
+		formatted, err := formatter.FormatJSON(jsonlRecords(result.Messages))
+		if err != nil {
+			return fmt.Errorf("failed to format output: %w", err)
+		}
+		fmt.Fprint(cmd.OutOrStdout(), formatted)
+		return nil
+	}
+
+	// Tabular output has one row per attribute across all messages
+	if formatter.IsTabular() {
+		var messages []output.MessageInfo
+		for _, msg := range result.Messages {
+			if msg.Err == nil {
+				messages = append(messages, output.MessageInfo{Index: msg.Index(), Info: msg.Info})
+			}
+		}
+		formatted, err := formatter.FormatAttributes(messages)
+		if err != nil {
+			return fmt.Errorf("failed to format output: %w", err)
+		}
+		fmt.Fprint(cmd.OutOrStdout(), formatted)
+		return nil
+	}
+
+	// A message picked with --index or --last is printed like a message
+	// file, without banners, so -o json and -o xml output can be parsed
+	if result.Selected > 0 && opts.format != "pretty" {
+		opts.report = ""
+		return runInspectSAML(cmd, opts, result.Messages[0], nil)
+	}
+
 	// Print header for HAR inspection
-	fmt.Fprintf(cmd.OutOrStdout(), "Found %d SAML message(s) in HAR file:\n\n", len(results))
+	if result.Selected > 0 {
+		fmt.Fprintf(cmd.OutOrStdout(), "Showing message %d of %d in HAR file:\n\n", result.Selected, result.Total)
+	} else {
+		fmt.Fprintf(cmd.OutOrStdout(), "Found %d SAML message(s) in HAR file:\n\n", len(result.Messages))
+	}
+
+	for i, msg := range result.Messages {
+		extracted := msg.Extracted
+
+		// Place the capture time on the lifetime bar rather than the current
+		// time, unless --now is given
+		switch {
+		case !opts.now.IsZero():
+		case extracted.StartedAt != nil:
+			formatter.WithReferenceTime(*extracted.StartedAt, "capture")
+		default:
+			formatter.WithReferenceTime(time.Time{}, "")
+		}
 
-	for i, extracted := range results {
 		// Print separator and context for each SAML message
 		if i > 0 {
 			fmt.Fprintln(cmd.OutOrStdout())
 		}
-		
+
 		fmt.Fprintf(cmd.OutOrStdout(), "━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
-		fmt.Fprintf(cmd.OutOrStdout(), " [%d/%d] %s from %s\n", i+1, len(results), extracted.Type, extracted.Source)
+		position, total := i+1, len(result.Messages)
+		if result.Selected > 0 {
+			position, total = result.Selected, result.Total
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), " [%d/%d] %s from %s\n", position, total, extracted.Type, extracted.Source)
 		if extracted.ParameterName != "" {
 			fmt.Fprintf(cmd.OutOrStdout(), "       Parameter: %s\n", extracted.ParameterName)
 		}
 		fmt.Fprintf(cmd.OutOrStdout(), "       URL: %s\n", truncateURL(extracted.URL, 70))
-		fmt.Fprintf(cmd.OutOrStdout(), "━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n\n")
-
-		// Process the SAML data
-		xmlData := extracted.DecodedXML
-
-		// Auto-decrypt if encrypted and key is provided
-		if saml.IsEncrypted(xmlData) {
-			if inspectKey == "" {
-				fmt.Fprintf(cmd.OutOrStdout(), "⚠️  Encrypted assertion detected - provide -k flag to decrypt\n\n")
-				// Still try to show what we can from the response wrapper
-				parser := saml.NewParser()
-				info, err := parser.ParsePartial(xmlData)
-				if err == nil && info != nil {
-					formatted, _ := formatter.FormatSAMLInfo(info)
-					fmt.Fprint(cmd.OutOrStdout(), formatted)
-				}
-				continue
+		if extracted.Occurrences > 1 {
+			fmt.Fprintf(cmd.OutOrStdout(), "       Seen: %d times\n", extracted.Occurrences)
+		}
+		if extracted.SimpleSign != nil {
+			fmt.Fprintf(cmd.OutOrStdout(), "       Binding: HTTP-POST-SimpleSign (%s)\n", extracted.SimpleSign.SigAlg)
+		}
+		if sent := msg.SentAt(); sent != nil {
+			fmt.Fprintf(cmd.OutOrStdout(), "       Sent: %s\n", sent.Format(time.RFC3339))
+			if msg.ExpiredWhenSent() {
+				fmt.Fprintf(cmd.OutOrStdout(), "       ⚠️  Assertion was already expired when sent\n")
 			}
-
-			decryptor, err := saml.NewDecryptor(inspectKey)
-			if err != nil {
-				fmt.Fprintf(cmd.OutOrStdout(), "⚠️  Failed to load private key: %v\n\n", err)
-				continue
+		}
+		if c := msg.Correlation; c != nil {
+			switch {
+			case c.Unsolicited:
+				fmt.Fprintf(cmd.OutOrStdout(), "       ⚠️  Unsolicited response (IdP-initiated SSO)\n")
+			case c.Request != 0:
+				fmt.Fprintf(cmd.OutOrStdout(), "       In response to: message %d\n", c.Request)
 			}
+			for _, problem := range c.Problems {
+				fmt.Fprintf(cmd.OutOrStdout(), "       ⚠️  %s\n", problem)
+			}
+		}
+		if msg.Replay != nil {
+			fmt.Fprintf(cmd.OutOrStdout(), "       ⚠️  %s\n", msg.Replay)
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n\n")
 
-			xmlData, err = decryptor.Decrypt(xmlData)
-			if err != nil {
-				fmt.Fprintf(cmd.OutOrStdout(), "⚠️  Failed to decrypt: %v\n\n", err)
-				continue
+		if errors.Is(msg.Err, inspect.ErrNoKey) {
+			fmt.Fprintf(cmd.OutOrStdout(), "⚠️  %s\n\n", missingKeyMessage(msg.Err))
+			// Still show what we can from the response wrapper
+			if msg.Info != nil {
+				formatted, _ := formatter.FormatSAMLInfo(msg.Info)
+				fmt.Fprint(cmd.OutOrStdout(), formatted)
 			}
+			continue
 		}
 
-		// Parse and display
-		parser := saml.NewParser()
-		info, err := parser.Parse(xmlData)
-		if err != nil {
-			fmt.Fprintf(cmd.OutOrStdout(), "⚠️  Failed to parse: %v\n\n", err)
+		var stageErr *inspect.StageError
+		if errors.As(msg.Err, &stageErr) {
+			fmt.Fprintf(cmd.OutOrStdout(), "⚠️  Failed to %s: %v\n\n", stageErr.Stage, stageErr.Err)
 			continue
 		}
 
-		formatted, err := formatter.FormatSAMLInfo(info)
+		formatted, err := opts.messageFormatter(formatter, msg).FormatSAMLInfo(msg.Info)
 		if err != nil {
 			fmt.Fprintf(cmd.OutOrStdout(), "⚠️  Failed to format: %v\n\n", err)
 			continue
@@ -184,41 +602,86 @@ func runInspectHAR(cmd *cobra.Command, data []byte) error {
 	return nil
 }
 
+// missingKeyMessage describes an encrypted message that could not be
+// decrypted for lack of a key
+func missingKeyMessage(err error) string {
+	if err == inspect.ErrNoKey {
+		return "Encrypted assertion detected - provide -k flag to decrypt"
+	}
+	return fmt.Sprintf("%v - add it to --key-map or provide -k", err)
+}
+
+// jsonlRecords converts inspected messages into JSONL records. Messages
+// that failed are kept with their error so consumers see every message.
+func jsonlRecords(messages []inspect.Message) []output.JSONLRecord {
+	records := make([]output.JSONLRecord, 0, len(messages))
+	for _, msg := range messages {
+		record := output.JSONLRecord{
+			SchemaVersion: output.SchemaVersion,
+			Index:         msg.Index(),
+			Type:          msg.Type(),
+			Info:          msg.Info,
+			Warnings:      msg.Warnings(),
+		}
+		if msg.Extracted != nil {
+			record.Source = msg.Extracted.Source
+			record.URL = msg.Extracted.URL
+			record.ParameterName = msg.Extracted.ParameterName
+			record.StartedAt = msg.Extracted.StartedAt
+		}
+		if msg.Correlation != nil {
+			record.InResponseTo = msg.Correlation.Request
+		}
+		if msg.Err != nil {
+			record.Error = msg.Err.Error()
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
 // runInspectSAML handles inspection of regular SAML files
-func runInspectSAML(cmd *cobra.Command, input string) error {
-	// Step 1: Auto-decode if input is base64-encoded
-	decoder := saml.NewDecoder()
-	xmlData, err := decoder.SmartDecode(input)
-	if err != nil {
-		return fmt.Errorf("failed to decode input: %w", err)
+func runInspectSAML(cmd *cobra.Command, opts inspectOptions, msg inspect.Message, tmpl *output.Template) error {
+	if opts.format == "otlp-trace" {
+		return fmt.Errorf("otlp-trace output is only supported for HAR files")
 	}
 
-	// Step 2: Auto-decrypt if encrypted and key is provided
-	if saml.IsEncrypted(xmlData) {
-		if inspectKey == "" {
-			return fmt.Errorf("encrypted SAML detected but no private key provided. Use -k flag to specify a key")
+	if errors.Is(msg.Err, inspect.ErrNoKey) && msg.Info != nil {
+		// Show the encryption metadata, which tells which key is needed
+		if formatted, err := opts.formatter().FormatSAMLInfo(msg.Info); err == nil {
+			fmt.Fprint(cmd.OutOrStdout(), formatted)
 		}
+	}
+	if err := messageError(msg); err != nil {
+		return err
+	}
 
-		decryptor, err := saml.NewDecryptor(inspectKey)
-		if err != nil {
-			return fmt.Errorf("failed to load private key: %w", err)
+	if opts.report != "" {
+		if err := writeReport(cmd, opts.report, opts.file, reportEntries([]inspect.Message{msg})); err != nil {
+			return err
 		}
+	}
 
-		xmlData, err = decryptor.Decrypt(xmlData)
+	if tmpl != nil {
+		formatted, err := tmpl.Execute(msg.Info)
 		if err != nil {
-			return fmt.Errorf("failed to decrypt SAML: %w", err)
+			return err
 		}
+		fmt.Fprint(cmd.OutOrStdout(), formatted)
+		return nil
 	}
 
-	// Step 3: Parse and display
-	parser := saml.NewParser()
-	info, err := parser.Parse(xmlData)
-	if err != nil {
-		return fmt.Errorf("failed to parse SAML: %w", err)
+	formatter := opts.formatter()
+	if formatter.IsJSONL() {
+		formatted, err := formatter.FormatJSONL(jsonlRecords([]inspect.Message{msg}))
+		if err != nil {
+			return fmt.Errorf("failed to format output: %w", err)
+		}
+		fmt.Fprint(cmd.OutOrStdout(), formatted)
+		return nil
 	}
 
-	formatter := output.NewFormatter(outputFormat)
-	formatted, err := formatter.FormatSAMLInfo(info)
+	formatted, err := opts.messageFormatter(formatter, msg).FormatSAMLInfo(msg.Info)
 	if err != nil {
 		return fmt.Errorf("failed to format output: %w", err)
 	}
@@ -227,24 +690,55 @@ func runInspectSAML(cmd *cobra.Command, input string) error {
 	return nil
 }
 
-func getInspectInput(cmd *cobra.Command) (string, error) {
-	if inspectFile != "" {
-		data, err := os.ReadFile(inspectFile)
+// messageError returns the error of a message that failed to decrypt or
+// parse, with the exit code of the stage that failed
+func messageError(msg inspect.Message) error {
+	if msg.Err == inspect.ErrNoKey {
+		return withExitCode(ExitDecrypt, fmt.Errorf("encrypted SAML detected but no private key provided. Use -k flag to specify a key"))
+	}
+	if errors.Is(msg.Err, inspect.ErrNoKey) {
+		return withExitCode(ExitDecrypt, fmt.Errorf("%v. Add it to --key-map or use -k", msg.Err))
+	}
+
+	var stageErr *inspect.StageError
+	if errors.As(msg.Err, &stageErr) {
+		switch stageErr.Stage {
+		case inspect.StageLoadKey:
+			return withExitCode(ExitDecrypt, fmt.Errorf("failed to load private key: %w", stageErr.Err))
+		case inspect.StageDecrypt:
+			return withExitCode(ExitDecrypt, fmt.Errorf("failed to %s SAML: %w", stageErr.Stage, stageErr.Err))
+		case inspect.StageParse:
+			return withExitCode(ExitParse, fmt.Errorf("failed to %s SAML: %w", stageErr.Stage, stageErr.Err))
+		default:
+			return fmt.Errorf("failed to %s SAML: %w", stageErr.Stage, stageErr.Err)
+		}
+	}
+
+	return nil
+}
+
+func getInspectInput(cmd *cobra.Command, file string) (string, error) {
+	if file != "" {
+		data, err := readInputFile(file)
 		if err != nil {
 			return "", fmt.Errorf("failed to read file: %w", err)
 		}
 		return strings.TrimSpace(string(data)), nil
 	}
 
+	if inputFromClipboard {
+		return clipboard.ReadText()
+	}
+
 	// Check if stdin has data
 	stat, _ := os.Stdin.Stat()
 	if (stat.Mode() & os.ModeCharDevice) == 0 {
-		data, err := io.ReadAll(os.Stdin)
+		data, err := readInput(os.Stdin)
 		if err != nil {
 			return "", fmt.Errorf("failed to read stdin: %w", err)
 		}
 		return strings.TrimSpace(string(data)), nil
 	}
 
-	return "", fmt.Errorf("no input provided. Use -f flag or pipe data to stdin")
+	return "", fmt.Errorf("no input provided. Use -f flag, --clipboard, or pipe data to stdin")
 }
@@ -0,0 +1,208 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/gliwka/SAMLurai/internal/inspect"
+	"github.com/gliwka/SAMLurai/internal/log"
+	"github.com/gliwka/SAMLurai/internal/output"
+	"github.com/gliwka/SAMLurai/internal/redact"
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/spf13/cobra"
+)
+
+// dirFile is the outcome of inspecting one file of a directory
+type dirFile struct {
+	File     string               `json:"file"`
+	Kind     string               `json:"kind"`
+	Messages []output.JSONLRecord `json:"messages"`
+	Error    string               `json:"error,omitempty"`
+
+	messages []inspect.Message
+	err      error
+}
+
+// validateDir rejects options that do not work with --dir
+func (o inspectOptions) validateDir(files int) error {
+	if o.dir == "" {
+		if o.recursive {
+			return fmt.Errorf("--recursive requires --dir")
+		}
+		return nil
+	}
+	switch {
+	case files > 0:
+		return fmt.Errorf("--dir cannot be combined with input files")
+	case o.template != "":
+		return fmt.Errorf("--template cannot be combined with --dir")
+	case len(o.dumpAttributes) > 0:
+		return fmt.Errorf("--dump-attribute cannot be combined with --dir")
+	}
+	switch o.format {
+	case "pretty", "json", "jsonl", "psobject":
+		return nil
+	}
+	return fmt.Errorf("--dir supports pretty, json, jsonl and psobject output, not %s", o.format)
+}
+
+// runInspectDir inspects every file in opts.dir that holds SAML and prints
+// a consolidated summary. Other files are skipped. As with several input
+// files, a file that fails does not stop the others and the first failure
+// decides the exit code.
+func runInspectDir(cmd *cobra.Command, opts inspectOptions, redactor *redact.Redactor, keyPath string, decryptor *saml.Decryptor) error {
+	paths, err := inspect.FindFiles(opts.dir, opts.recursive)
+	if err != nil {
+		return fmt.Errorf("failed to read directory: %w", err)
+	}
+
+	var files []dirFile
+	skipped := 0
+	for _, path := range paths {
+		data, err := readInputFile(path)
+		if err != nil {
+			files = append(files, dirFile{File: path, err: fmt.Errorf("failed to read file: %w", err)})
+			continue
+		}
+		input := strings.TrimSpace(string(data))
+		kind := inspect.DetectKind(path, input)
+		if kind == "" {
+			log.Debug("skipping file without SAML", "file", path)
+			skipped++
+			continue
+		}
+
+		file := dirFile{File: path, Kind: kind}
+		result, err := inspect.Run(cmd.Context(), opts.request(input, path, redactor, keyPath, decryptor))
+		switch {
+		case err != nil:
+			file.err = err
+		case result.IsHAR:
+			file.messages = result.Messages
+		default:
+			file.messages = result.Messages
+			file.err = messageError(result.Messages[0])
+		}
+		files = append(files, file)
+	}
+
+	var first error
+	failed := 0
+	var entries []output.ReportEntry
+	for i := range files {
+		file := &files[i]
+		file.Messages = jsonlRecords(file.messages)
+		for j := range file.Messages {
+			file.Messages[j].File = file.File
+		}
+		for _, entry := range reportEntries(file.messages) {
+			entry.File = file.File
+			entries = append(entries, entry)
+		}
+		if file.err != nil {
+			file.Error = file.err.Error()
+			failed++
+			if first == nil {
+				first = file.err
+			}
+		}
+	}
+
+	if opts.report != "" {
+		if err := writeReport(cmd, opts.report, opts.dir, entries); err != nil {
+			return err
+		}
+	}
+	if err := printDirSummary(cmd, opts, files, skipped); err != nil {
+		return err
+	}
+
+	if first == nil {
+		return nil
+	}
+	return withExitCode(ExitCode(first), fmt.Errorf("%d of %d files failed", failed, len(files)))
+}
+
+// printDirSummary writes the files of a directory with counts of their
+// messages, warnings and errors, followed by each warning and error
+func printDirSummary(cmd *cobra.Command, opts inspectOptions, files []dirFile, skipped int) error {
+	formatter := opts.formatter()
+	switch {
+	case formatter.IsJSONL():
+		var records []output.JSONLRecord
+		for _, file := range files {
+			if file.err != nil && len(file.Messages) == 0 {
+				fmt.Fprintf(cmd.ErrOrStderr(), "Error: %s: %v\n", file.File, file.err)
+			}
+			records = append(records, file.Messages...)
+		}
+		formatted, err := formatter.FormatJSONL(records)
+		if err != nil {
+			return fmt.Errorf("failed to format output: %w", err)
+		}
+		fmt.Fprint(cmd.OutOrStdout(), formatted)
+		return nil
+	case formatter.IsJSON() || formatter.IsPSObject():
+		if files == nil {
+			files = []dirFile{}
+		}
+		formatted, err := formatter.FormatJSON(files)
+		if err != nil {
+			return fmt.Errorf("failed to format output: %w", err)
+		}
+		fmt.Fprint(cmd.OutOrStdout(), formatted)
+		return nil
+	}
+
+	out := cmd.OutOrStdout()
+	fmt.Fprintf(out, "Inspected %d file(s) in %s", len(files), opts.dir)
+	if skipped > 0 {
+		fmt.Fprintf(out, ", skipped %d without SAML", skipped)
+	}
+	fmt.Fprint(out, ":\n\n")
+	if len(files) == 0 {
+		return nil
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "FILE\tKIND\tMESSAGES\tWARNINGS\tERRORS")
+	for _, file := range files {
+		warnings, errs := 0, 0
+		for _, record := range file.Messages {
+			warnings += len(record.Warnings)
+			if record.Error != "" {
+				errs++
+			}
+		}
+		if file.err != nil && len(file.Messages) == 0 {
+			errs++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\n", file.File, file.Kind, len(file.Messages), warnings, errs)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	var findings []string
+	for _, file := range files {
+		if file.err != nil && len(file.Messages) == 0 {
+			findings = append(findings, fmt.Sprintf("❌ %s: %v", file.File, file.err))
+		}
+		for _, record := range file.Messages {
+			if record.Error != "" {
+				findings = append(findings, fmt.Sprintf("❌ %s [%d] %s: %s", file.File, record.Index, record.Type, record.Error))
+			}
+			for _, warning := range record.Warnings {
+				findings = append(findings, fmt.Sprintf("⚠️  %s [%d] %s: %s", file.File, record.Index, record.Type, warning))
+			}
+		}
+	}
+	if len(findings) > 0 {
+		fmt.Fprintln(out, "\nFindings:")
+		for _, finding := range findings {
+			fmt.Fprintf(out, "  %s\n", finding)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// captureDir lays out a support bundle: SAML as XML and base64, a file
+// without SAML and a malformed message in a subdirectory
+func captureDir(t *testing.T) string {
+	t.Helper()
+	response, err := os.ReadFile(filepath.Join("..", "testdata", "fixtures", "assertions", "response.xml"))
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"response.xml":      string(response),
+		"notes.txt":         "The user could not log in at 10:42.",
+		"sub/encoded.txt":   base64.StdEncoding.EncodeToString(response),
+		"sub/truncated.xml": `<saml:Assertion xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion"><saml:Issuer>`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+	return dir
+}
+
+func TestInspectCmd_Dir(t *testing.T) {
+	resetInspectFlags()
+	defer resetInspectFlags()
+
+	dir := captureDir(t)
+	output, err := executeCommand(rootCmd, "inspect", "-d", dir, "--now", "2024-01-15T10:30:00Z")
+	require.NoError(t, err)
+	assert.Contains(t, output, "Inspected 1 file(s) in "+dir+", skipped 1 without SAML")
+	assert.Contains(t, output, filepath.Join(dir, "response.xml"))
+	assert.NotContains(t, output, "encoded.txt")
+	assert.Contains(t, output, "⚠️  "+filepath.Join(dir, "response.xml")+" [1] Response: neither response nor assertion is signed")
+}
+
+func TestInspectCmd_DirRecursive(t *testing.T) {
+	resetInspectFlags()
+	defer resetInspectFlags()
+
+	dir := captureDir(t)
+	report := filepath.Join(t.TempDir(), "report.html")
+	output, err := executeCommand(rootCmd, "inspect", "-d", dir, "--recursive", "--report", report)
+	require.Error(t, err)
+	assert.Equal(t, ExitParse, ExitCode(err))
+	assert.Contains(t, err.Error(), "1 of 3 files failed")
+	assert.Contains(t, output, "Inspected 3 file(s)")
+	assert.Regexp(t, `encoded\.txt\s+base64\s+1\s+\d+\s+0`, output)
+	assert.Regexp(t, `truncated\.xml\s+XML\s+1\s+0\s+1`, output)
+	assert.Contains(t, output, "❌ "+filepath.Join(dir, "sub", "truncated.xml"))
+
+	html, err := os.ReadFile(report)
+	require.NoError(t, err)
+	assert.Contains(t, string(html), "<th>File</th>")
+	assert.Contains(t, string(html), `id="msg-2-1"`)
+
+	inspectReport = ""
+	output, err = executeCommand(rootCmd, "inspect", "-d", dir, "--recursive", "-o", "json")
+	require.Error(t, err)
+	var files []dirFile
+	// The error follows the JSON document in the combined output
+	require.NoError(t, json.NewDecoder(strings.NewReader(output)).Decode(&files))
+	require.Len(t, files, 3)
+	assert.Equal(t, "XML", files[0].Kind)
+	assert.Equal(t, "base64", files[1].Kind)
+	assert.Equal(t, filepath.Join(dir, "sub", "encoded.txt"), files[1].Messages[0].File)
+	assert.NotEmpty(t, files[2].Error)
+}
+
+func TestInspectCmd_DirInvalidOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"recursive without dir", []string{"--recursive"}, "--recursive requires --dir"},
+		{"with files", []string{"-d", ".", "-f", "response.xml"}, "--dir cannot be combined with input files"},
+		{"template", []string{"-d", ".", "--template", "{{.ID}}"}, "--template cannot be combined with --dir"},
+		{"csv", []string{"-d", ".", "-o", "csv"}, "--dir supports pretty, json, jsonl and psobject output, not csv"},
+		{"missing dir", []string{"-d", filepath.Join("testdata", "missing")}, "failed to read directory"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetInspectFlags()
+			defer resetInspectFlags()
+
+			_, err := executeCommand(rootCmd, append([]string{"inspect"}, tt.args...)...)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
@@ -1,10 +1,19 @@
 package cmd
 
 import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/gliwka/SAMLurai/internal/trace"
+	"github.com/spf13/pflag"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -113,6 +122,556 @@ func TestInspectCmd_HelpText(t *testing.T) {
 }
 
 func resetInspectFlags() {
-	inspectFile = ""
+	inspectFiles = nil
+	inspectMinConf = 0
+	inspectDedupe = false
+	inspectFilter = saml.MessageFilter{}
+	inspectIndex = 0
+	inspectLast = false
+	inspectRedact = redactOptions{}
+	inspectReport = ""
+	inspectDest = ""
+	inspectAud = ""
+	inspectStrict = false
+	inspectURLNorm = ""
+	inspectTmpl = ""
+	inspectMaxLen = 120
+	inspectDump = nil
+	inspectNow = ""
+	inspectSkew = 0
+	inspectKey = ""
+	inspectKeyEnv = ""
+	inspectKeyMap = ""
+	inspectTrace = false
+	inspectShowRaw = false
+	inspectDir = ""
+	inspectRecursive = false
+	inspectSockets = false
 	outputFormat = "pretty"
+	inspectCmd.Flags().VisitAll(func(f *pflag.Flag) { f.Changed = false })
+}
+
+func TestInspectCmd_Report(t *testing.T) {
+	resetInspectFlags()
+	defer resetInspectFlags()
+
+	responsePath := filepath.Join("..", "testdata", "fixtures", "assertions", "response.xml")
+	reportPath := filepath.Join(t.TempDir(), "report.html")
+
+	output, err := executeCommand(rootCmd, "inspect", "-f", responsePath, "--report", reportPath)
+	require.NoError(t, err)
+	assert.Contains(t, output, "Report written to")
+
+	report, err := os.ReadFile(reportPath)
+	require.NoError(t, err)
+	assert.Contains(t, string(report), "<!DOCTYPE html>")
+	assert.Contains(t, string(report), "https://idp.example.com")
+	assert.Contains(t, string(report), "user@example.com")
+}
+
+func TestInspectCmd_CSVOutput(t *testing.T) {
+	resetInspectFlags()
+
+	responsePath := filepath.Join("..", "testdata", "fixtures", "assertions", "response.xml")
+
+	output, err := executeCommand(rootCmd, "inspect", "-f", responsePath, "-o", "csv")
+	require.NoError(t, err)
+
+	assert.Contains(t, output, "message_index,issuer,attribute_name,friendly_name,value\n")
+	assert.Contains(t, output, "1,https://idp.example.com,groups,Groups,admins\n")
+	assert.Contains(t, output, "1,https://idp.example.com,groups,Groups,users\n")
+}
+
+// writeFlowHAR writes a HAR with an AuthnRequest and its Response
+func writeFlowHAR(t *testing.T) string {
+	t.Helper()
+	response, err := os.ReadFile(filepath.Join("..", "testdata", "fixtures", "assertions", "response.xml"))
+	require.NoError(t, err)
+	request, err := os.ReadFile(filepath.Join("..", "testdata", "fixtures", "assertions", "request.xml"))
+	require.NoError(t, err)
+
+	har := `{"log": {"entries": [
+		{"request": {"method": "GET", "url": "https://idp.example.com/sso?SAMLRequest=` + url.QueryEscape(base64.StdEncoding.EncodeToString(request)) + `"},
+			"response": {"content": {"mimeType": "text/html", "text": ""}}},
+		{"request": {"method": "POST", "url": "https://sp.example.com/acs",
+			"postData": {"mimeType": "text/plain", "params": [{"name": "SAMLResponse", "value": "` + url.QueryEscape(base64.StdEncoding.EncodeToString(response)) + `"}]}},
+			"response": {"content": {"mimeType": "text/html", "text": ""}}}]}}`
+	harPath := filepath.Join(t.TempDir(), "flow.har")
+	require.NoError(t, os.WriteFile(harPath, []byte(har), 0644))
+	return harPath
+}
+
+func TestInspectCmd_HARSentTime(t *testing.T) {
+	resetInspectFlags()
+	defer resetInspectFlags()
+	outputFormat = "pretty"
+
+	response, err := os.ReadFile(filepath.Join("..", "testdata", "fixtures", "assertions", "response.xml"))
+	require.NoError(t, err)
+	harPath := createTempFile(t, `{"log": {"entries": [{"startedDateTime": "2024-01-15T10:37:00Z",
+		"request": {"method": "POST", "url": "https://sp.example.com/acs",
+			"postData": {"mimeType": "text/plain", "params": [{"name": "SAMLResponse", "value": "`+url.QueryEscape(base64.StdEncoding.EncodeToString(response))+`"}]}},
+		"response": {"content": {"mimeType": "text/html", "text": ""}}}]}}`)
+
+	output, err := executeCommand(rootCmd, "inspect", "-f", harPath)
+	require.NoError(t, err)
+	assert.Contains(t, output, "Sent: 2024-01-15T10:37:00Z")
+	assert.Contains(t, output, "Assertion was already expired when sent")
+	assert.Contains(t, output, "expired 2m before capture")
+
+	resetInspectFlags()
+	output, err = executeCommand(rootCmd, "inspect", "-f", harPath, "--clock-skew", "5m")
+	require.NoError(t, err)
+	assert.NotContains(t, output, "already expired")
+}
+
+func TestInspectCmd_HARCorrelation(t *testing.T) {
+	resetInspectFlags()
+	defer resetInspectFlags()
+	outputFormat = "pretty"
+
+	// The fixture response answers a different request than the fixture
+	// AuthnRequest
+	output, err := executeCommand(rootCmd, "inspect", "-f", writeFlowHAR(t))
+	require.NoError(t, err)
+	assert.Contains(t, output, "⚠️  InResponseTo _request456 matches no earlier AuthnRequest in the capture")
+}
+
+func TestInspectCmd_HARFilter(t *testing.T) {
+	resetInspectFlags()
+	defer resetInspectFlags()
+
+	harPath := writeFlowHAR(t)
+
+	output, err := executeCommand(rootCmd, "inspect", "-f", harPath, "-o", "jsonl", "--type", "Response", "--issuer", "https://idp.example.com")
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	require.Len(t, lines, 1)
+	assert.Contains(t, lines[0], `"type":"Response"`)
+
+	resetInspectFlags()
+	output, err = executeCommand(rootCmd, "inspect", "-f", harPath, "-o", "jsonl", "--param", "SAMLRequest", "--url-contains", "idp.example.com")
+	require.NoError(t, err)
+	lines = strings.Split(strings.TrimSpace(output), "\n")
+	require.Len(t, lines, 1)
+	assert.Contains(t, lines[0], `"type":"AuthnRequest"`)
+}
+
+func TestInspectCmd_HARIndex(t *testing.T) {
+	resetInspectFlags()
+	defer resetInspectFlags()
+
+	harPath := writeFlowHAR(t)
+
+	output, err := executeCommand(rootCmd, "inspect", "-f", harPath, "--index", "2")
+	require.NoError(t, err)
+	assert.Contains(t, output, "Showing message 2 of 2 in HAR file")
+	assert.Contains(t, output, "[2/2] Response from request-body")
+	assert.NotContains(t, output, "AuthnRequest from")
+
+	resetInspectFlags()
+	output, err = executeCommand(rootCmd, "inspect", "-f", harPath, "--last", "-o", "jsonl")
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	require.Len(t, lines, 1)
+	assert.Contains(t, lines[0], `"type":"Response"`)
+
+	// A single message is a JSON document of its own
+	resetInspectFlags()
+	output, err = executeCommand(rootCmd, "inspect", "-f", harPath, "--index", "1", "-o", "json")
+	require.NoError(t, err)
+	var info map[string]any
+	require.NoError(t, json.Unmarshal([]byte(output), &info))
+	assert.Equal(t, "AuthnRequest", info["type"])
+
+	resetInspectFlags()
+	output, err = executeCommand(rootCmd, "inspect", "-f", harPath, "--last", "-o", "json")
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal([]byte(output), &info))
+	assert.Equal(t, "Response", info["type"])
+
+	resetInspectFlags()
+	_, err = executeCommand(rootCmd, "inspect", "-f", harPath, "--index", "5")
+	assert.EqualError(t, err, "message 5 not found: the HAR file has 2 SAML message(s)")
+
+	resetInspectFlags()
+	_, err = executeCommand(rootCmd, "inspect", "-f", harPath, "--index", "1", "--last")
+	assert.EqualError(t, err, "only one of --index and --last may be given")
+}
+
+func TestInspectCmd_JSONLOutput(t *testing.T) {
+	resetInspectFlags()
+	defer resetInspectFlags()
+
+	harPath := writeFlowHAR(t)
+
+	output, err := executeCommand(rootCmd, "inspect", "-f", harPath, "-o", "jsonl")
+	require.NoError(t, err)
+	assert.NotContains(t, output, "━")
+
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	require.Len(t, lines, 2)
+
+	var record map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &record))
+	assert.Equal(t, "AuthnRequest", record["type"])
+	assert.Equal(t, "SAMLRequest", record["parameter_name"])
+
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &record))
+	assert.Equal(t, "Response", record["type"])
+	assert.Contains(t, record, "info")
+}
+
+func TestInspectCmd_PSObjectOutput(t *testing.T) {
+	resetInspectFlags()
+	defer resetInspectFlags()
+
+	output, err := executeCommand(rootCmd, "inspect", "-f", writeFlowHAR(t), "-o", "psobject")
+	require.NoError(t, err)
+	assert.NotContains(t, output, "━")
+	assert.NotContains(t, strings.ReplaceAll(output, "\r\n", ""), "\n", "all line endings must be CRLF")
+
+	var records []map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(output), &records))
+	require.Len(t, records, 2)
+	assert.Equal(t, "AuthnRequest", records[0]["type"])
+	assert.Equal(t, "Response", records[1]["type"])
+}
+
+func TestInspectCmd_DestinationCheck(t *testing.T) {
+	resetInspectFlags()
+	defer resetInspectFlags()
+
+	responsePath := filepath.Join("..", "testdata", "fixtures", "assertions", "response.xml")
+
+	output, err := executeCommand(rootCmd, "inspect", "-f", responsePath, "-o", "jsonl", "--destination", "https://SP.example.com:443/acs/")
+	require.NoError(t, err)
+	assert.NotContains(t, output, "destination https://sp.example.com/acs does not match")
+
+	output, err = executeCommand(rootCmd, "inspect", "-f", responsePath, "-o", "jsonl", "--destination", "https://SP.example.com:443/acs/", "--strict-urls")
+	require.NoError(t, err)
+	assert.Contains(t, output, "destination https://sp.example.com/acs does not match https://SP.example.com:443/acs/")
+
+	// Pretty output lists the mismatches too
+	resetInspectFlags()
+	output, err = executeCommand(rootCmd, "inspect", "-f", responsePath, "--destination", "https://sp.example.com/other", "--audience", "https://other.example.com")
+	require.NoError(t, err)
+	assert.Contains(t, output, "Validation Warnings")
+	assert.Contains(t, output, "destination https://sp.example.com/acs does not match https://sp.example.com/other")
+	assert.Contains(t, output, "audience restriction does not include https://other.example.com")
+
+	resetInspectFlags()
+	output, err = executeCommand(rootCmd, "inspect", "-f", responsePath, "-o", "jsonl", "--destination", "https://SP.example.com:443/acs/", "--url-normalization", "trim_trailing_slash,ignore_host_case")
+	require.NoError(t, err)
+	assert.Contains(t, output, "destination https://sp.example.com/acs does not match https://SP.example.com:443/acs/")
+
+	resetInspectFlags()
+	_, err = executeCommand(rootCmd, "inspect", "-f", responsePath, "--url-normalization", "none", "--strict-urls")
+	assert.EqualError(t, err, "only one of --strict-urls and --url-normalization may be given")
+
+	resetInspectFlags()
+	_, err = executeCommand(rootCmd, "inspect", "-f", responsePath, "--url-normalization", "ignore_query")
+	assert.ErrorContains(t, err, `invalid --url-normalization: unknown URL normalization rule "ignore_query"`)
+}
+
+func TestInspectCmd_Template(t *testing.T) {
+	resetInspectFlags()
+	defer resetInspectFlags()
+
+	responsePath := filepath.Join("..", "testdata", "fixtures", "assertions", "response.xml")
+
+	output, err := executeCommand(rootCmd, "inspect", "-f", responsePath, "--template", "{{.Assertion.Subject.NameID}} {{.Issuer}}")
+	require.NoError(t, err)
+	assert.Equal(t, "user@example.com https://idp.example.com\n", output)
+
+	_, err = executeCommand(rootCmd, "inspect", "-f", responsePath, "--template", "{{.Issuer")
+	assert.ErrorContains(t, err, "invalid template")
+}
+
+func TestInspectCmd_LongAttributeValues(t *testing.T) {
+	resetInspectFlags()
+
+	long := strings.Repeat("MIIC", 50)
+	tmpFile := createTempFile(t, `<saml:Assertion xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_a">
+  <saml:AttributeStatement>
+    <saml:Attribute Name="userCertificate"><saml:AttributeValue>`+long+`</saml:AttributeValue></saml:Attribute>
+  </saml:AttributeStatement>
+</saml:Assertion>`)
+	defer os.Remove(tmpFile)
+	dumpFile := filepath.Join(t.TempDir(), "cert.b64")
+
+	output, err := executeCommand(rootCmd, "inspect", "-f", tmpFile, "--dump-attribute", "userCertificate="+dumpFile)
+	require.NoError(t, err)
+	assert.Contains(t, output, long[:120]+"… (80 more chars)")
+	assert.NotContains(t, output, long)
+	assert.Contains(t, output, "Wrote 1 value(s) of userCertificate to "+dumpFile)
+
+	dumped, err := os.ReadFile(dumpFile)
+	require.NoError(t, err)
+	assert.Equal(t, long+"\n", string(dumped))
+
+	resetInspectFlags()
+	output, err = executeCommand(rootCmd, "inspect", "-f", tmpFile, "--max-value-length", "0")
+	require.NoError(t, err)
+	assert.Contains(t, output, long)
+
+	resetInspectFlags()
+	_, err = executeCommand(rootCmd, "inspect", "-f", tmpFile, "--dump-attribute", "missing="+dumpFile)
+	assert.EqualError(t, err, `attribute "missing" not found`)
+
+	resetInspectFlags()
+	_, err = executeCommand(rootCmd, "inspect", "-f", tmpFile, "--dump-attribute", "userCertificate")
+	assert.EqualError(t, err, `invalid --dump-attribute "userCertificate": expected NAME=FILE`)
+}
+
+func TestInspectCmd_ReferenceTime(t *testing.T) {
+	resetInspectFlags()
+	defer resetInspectFlags()
+
+	responsePath := filepath.Join("..", "testdata", "fixtures", "assertions", "response.xml")
+
+	output, err := executeCommand(rootCmd, "inspect", "-f", responsePath, "--now", "2024-01-15T10:30:00Z")
+	require.NoError(t, err)
+	assert.Contains(t, output, "valid now, 5m left")
+
+	output, err = executeCommand(rootCmd, "inspect", "-f", responsePath, "--now", "2024-01-15T10:37:00Z", "-o", "jsonl")
+	require.NoError(t, err)
+	assert.Contains(t, output, "assertion has expired (NotOnOrAfter 2024-01-15T10:35:00Z, 2m ago)")
+
+	output, err = executeCommand(rootCmd, "inspect", "-f", responsePath, "--now", "2024-01-15T10:37:00Z", "--clock-skew", "5m", "-o", "jsonl")
+	require.NoError(t, err)
+	assert.NotContains(t, output, "has expired")
+
+	_, err = executeCommand(rootCmd, "inspect", "-f", responsePath, "--now", "yesterday")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid --now "yesterday"`)
+}
+
+func TestInspectCmd_KeyMap(t *testing.T) {
+	resetInspectFlags()
+	defer resetInspectFlags()
+
+	encrypted := createTempFile(t, `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_enc"><saml:Issuer>https://idp.example.com</saml:Issuer><saml:EncryptedAssertion><xenc:EncryptedData xmlns:xenc="http://www.w3.org/2001/04/xmlenc#"/></saml:EncryptedAssertion></samlp:Response>`)
+	keyMap := createTempFile(t, "https://other.example.com: other.pem\n")
+
+	_, err := executeCommand(rootCmd, "inspect", "-f", encrypted, "--key-map", keyMap)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `for issuer "https://idp.example.com", which is not in the key map. Add it to --key-map or use -k`)
+
+	resetInspectFlags()
+	_, err = executeCommand(rootCmd, "inspect", "-f", encrypted, "--key-map", filepath.Join(t.TempDir(), "missing.yaml"))
+	require.Error(t, err)
+}
+
+func TestInspectCmd_KeyEnv(t *testing.T) {
+	resetInspectFlags()
+	defer resetInspectFlags()
+
+	encrypted := createTempFile(t, `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_enc"><saml:Issuer>https://idp.example.com</saml:Issuer><saml:EncryptedAssertion><xenc:EncryptedData xmlns:xenc="http://www.w3.org/2001/04/xmlenc#"/></saml:EncryptedAssertion></samlp:Response>`)
+	pemData, err := os.ReadFile(createTestKeyFile(t))
+	require.NoError(t, err)
+	t.Setenv("SAMLURAI_TEST_KEY", base64.StdEncoding.EncodeToString(pemData))
+
+	// The key is used: decryption of the empty EncryptedData fails
+	_, err = executeCommand(rootCmd, "inspect", "-f", encrypted, "--key-env", "SAMLURAI_TEST_KEY")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to decrypt")
+
+	resetInspectFlags()
+	_, err = executeCommand(rootCmd, "inspect", "-f", encrypted, "--key-env", "SAMLURAI_TEST_UNSET")
+	assert.EqualError(t, err, "failed to load private key: environment variable SAMLURAI_TEST_UNSET is not set")
+}
+
+func TestInspectCmd_EncryptedWithoutKey(t *testing.T) {
+	resetInspectFlags()
+
+	inputFile := createTempFile(t, encryptTestAssertion(t))
+	defer os.Remove(inputFile)
+
+	output, err := executeCommand(rootCmd, "inspect", "-f", inputFile)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no private key provided")
+	assert.Contains(t, output, "SAML EncryptedAssertion")
+	assert.Contains(t, output, "Key Transport")
+	assert.Contains(t, output, "rsa-oaep-mgf1p")
+	assert.Contains(t, output, "AES-CBC data encryption is vulnerable")
+}
+
+func TestInspectCmd_Trace(t *testing.T) {
+	resetInspectFlags()
+	defer resetInspectFlags()
+
+	// The trace is written for input that fails to parse as well
+	output, err := executeCommand(rootCmd, "inspect", "-f", createTempFile(t, "<samlp:Response"), "--trace")
+	require.Error(t, err)
+
+	var stages []string
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		if !strings.HasPrefix(line, "{") {
+			continue // the error printed by cobra
+		}
+		var step trace.Step
+		require.NoError(t, json.Unmarshal([]byte(line), &step), line)
+		stages = append(stages, step.Stage+" "+step.Outcome)
+	}
+	assert.Equal(t, []string{"base64 skipped", "detect-type ok", "decrypt skipped", "parse failed"}, stages)
+}
+
+func TestInspectCmd_Vendor(t *testing.T) {
+	resetInspectFlags()
+	defer resetInspectFlags()
+
+	responsePath := filepath.Join("..", "testdata", "fixtures", "signed", "onelogin_response.xml")
+	output, err := executeCommand(rootCmd, "inspect", "-f", responsePath)
+	require.NoError(t, err)
+	assert.Contains(t, output, "▸ IdP Vendor")
+	assert.Contains(t, output, "OneLogin (high confidence)")
+	assert.Contains(t, output, "💡 ")
+
+	inspectFiles = nil
+	output, err = executeCommand(rootCmd, "inspect", "-f", responsePath, "-o", "json")
+	require.NoError(t, err)
+	var info saml.SAMLInfo
+	require.NoError(t, json.Unmarshal([]byte(output), &info))
+	require.NotNil(t, info.Vendor)
+	assert.Equal(t, saml.VendorOneLogin, info.Vendor.Vendor)
+}
+
+func TestInspectCmd_ShowRaw(t *testing.T) {
+	resetInspectFlags()
+	defer resetInspectFlags()
+
+	responsePath := filepath.Join("..", "testdata", "fixtures", "assertions", "response.xml")
+	output, err := executeCommand(rootCmd, "inspect", "-f", responsePath)
+	require.NoError(t, err)
+	assert.NotContains(t, output, "▸ Raw XML")
+
+	inspectFiles = nil
+	output, err = executeCommand(rootCmd, "inspect", "-f", responsePath, "--show-raw")
+	require.NoError(t, err)
+	assert.Equal(t, 2, strings.Count(output, "▸ Raw XML"))
+	assert.Contains(t, output, `<saml:Assertion ID="_assertion789"`)
+
+	inspectFiles = nil
+	output, err = executeCommand(rootCmd, "inspect", "-f", responsePath, "--show-raw", "-o", "json")
+	require.NoError(t, err)
+	var info saml.SAMLInfo
+	require.NoError(t, json.Unmarshal([]byte(output), &info))
+	assert.True(t, strings.HasPrefix(info.RawXML, "<samlp:Response"))
+	require.NotNil(t, info.Assertion)
+	assert.True(t, strings.HasPrefix(info.Assertion.RawXML, "<saml:Assertion"))
+}
+
+func TestInspectCmd_PossibleCauses(t *testing.T) {
+	resetInspectFlags()
+	defer resetInspectFlags()
+
+	input := createTempFile(t, `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_0f1e2d3c-4b5a-6978-8796-a5b4c3d2e1f0" Version="2.0" IssueInstant="2024-01-01T00:00:00Z">
+  <saml:Issuer>http://adfs.example.com/adfs/services/trust</saml:Issuer>
+  <samlp:Status>
+    <samlp:StatusCode Value="urn:oasis:names:tc:SAML:2.0:status:Responder">
+      <samlp:StatusCode Value="urn:oasis:names:tc:SAML:2.0:status:InvalidNameIDPolicy"/>
+    </samlp:StatusCode>
+  </samlp:Status>
+</samlp:Response>`)
+
+	output, err := executeCommand(rootCmd, "inspect", "-f", input)
+	require.NoError(t, err)
+	assert.Contains(t, output, "Sub Status:")
+	assert.Contains(t, output, "▸ Possible Causes")
+	assert.Contains(t, output, "• No issuance transform rule of the relying party trust emits a Name ID")
+	assert.Contains(t, output, "→ Look up event 364 in the AD FS/Admin event log")
+}
+
+func TestInspectCmd_MultipleFiles(t *testing.T) {
+	resetInspectFlags()
+	defer resetInspectFlags()
+
+	fixtureDir := filepath.Join("..", "testdata", "fixtures", "assertions")
+	responsePath := filepath.Join(fixtureDir, "response.xml")
+	assertionPath := filepath.Join(fixtureDir, "assertion.xml")
+
+	output, err := executeCommand(rootCmd, "inspect", "-f", responsePath, "-f", assertionPath)
+	require.NoError(t, err)
+	assert.Contains(t, output, "▶ [1/2] "+responsePath)
+	assert.Contains(t, output, "▶ [2/2] "+assertionPath)
+	assert.Less(t, strings.Index(output, "[1/2]"), strings.Index(output, "[2/2]"))
+
+	inspectFiles = nil
+	output, err = executeCommand(rootCmd, "inspect", filepath.Join(fixtureDir, "*.xml"))
+	require.NoError(t, err)
+	assert.Contains(t, output, "▶ [3/3] "+responsePath)
+
+	inspectFiles = nil
+	invalid := createTempFile(t, "not valid XML at all")
+	defer os.Remove(invalid)
+	output, err = executeCommand(rootCmd, "inspect", invalid, responsePath)
+	require.Error(t, err)
+	assert.Equal(t, ExitParse, ExitCode(err))
+	assert.Contains(t, err.Error(), "1 of 2 files failed")
+	assert.Contains(t, output, "Error: "+invalid+": failed to parse SAML")
+	assert.Contains(t, output, "▶ [2/2] "+responsePath)
+
+	inspectFiles = nil
+	_, err = executeCommand(rootCmd, "inspect", filepath.Join(fixtureDir, "*.json"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no files match")
+
+	inspectFiles = nil
+	_, err = executeCommand(rootCmd, "inspect", responsePath, assertionPath, "--report", filepath.Join(t.TempDir(), "report.html"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "--report takes a single input file")
+}
+
+func TestInspectCmd_GzippedHAR(t *testing.T) {
+	resetInspectFlags()
+	defer resetInspectFlags()
+
+	response, err := os.ReadFile(filepath.Join("..", "testdata", "fixtures", "assertions", "response.xml"))
+	require.NoError(t, err)
+	har := `{"log": {"entries": [{"request": {"method": "POST", "url": "https://sp.example.com/acs",
+		"postData": {"mimeType": "text/plain", "params": [{"name": "SAMLResponse", "value": "` + url.QueryEscape(base64.StdEncoding.EncodeToString(response)) + `"}]}},
+		"response": {"content": {"mimeType": "text/html", "text": ""}}}]}}`
+	harPath := filepath.Join(t.TempDir(), "capture.har.gz")
+	require.NoError(t, os.WriteFile(harPath, gzipData(t, har), 0o644))
+
+	output, err := executeCommand(rootCmd, "inspect", "-f", harPath)
+	require.NoError(t, err)
+	assert.Contains(t, output, "Found 1 SAML message(s) in HAR file")
+	assert.Contains(t, output, "_assertion789")
+}
+
+// gzipData compresses s as a browser or proxy export would be
+func gzipData(t *testing.T, s string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	_, err := w.Write([]byte(s))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestInspectCmd_WebSockets(t *testing.T) {
+	resetInspectFlags()
+	defer resetInspectFlags()
+
+	response, err := os.ReadFile(filepath.Join("..", "testdata", "fixtures", "assertions", "response.xml"))
+	require.NoError(t, err)
+	harPath := createTempFile(t, `{"log": {"entries": [{"startedDateTime": "2024-01-15T10:00:00Z",
+		"request": {"method": "GET", "url": "wss://app.example.com/socket"},
+		"response": {"status": 101, "content": {"mimeType": "", "text": ""}},
+		"_webSocketMessages": [{"type": "receive", "time": 1705312920, "opcode": 1,
+			"data": "{\"SAMLResponse\": \"`+base64.StdEncoding.EncodeToString(response)+`\"}"}]}]}}`)
+
+	output, err := executeCommand(rootCmd, "inspect", "-f", harPath)
+	require.NoError(t, err)
+	assert.Contains(t, output, "No SAML assertions found")
+
+	inspectFiles = nil
+	output, err = executeCommand(rootCmd, "inspect", "-f", harPath, "--websockets")
+	require.NoError(t, err)
+	assert.Contains(t, output, "[1/1] Response from websocket-receive")
+	assert.Contains(t, output, "Sent: 2024-01-15T10:02:00Z")
+	assert.NotContains(t, output, "▶")
 }
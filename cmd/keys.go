@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/gliwka/SAMLurai/internal/hsm"
+	"github.com/gliwka/SAMLurai/internal/kms"
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// stdinKey is the --key value that reads the private key from stdin
+const stdinKey = "-"
+
+// keySource describes where a command's private key comes from
+type keySource struct {
+	// path is a PEM file, or stdinKey
+	path string
+
+	// env names an environment variable holding the PEM
+	env string
+
+	// pkcs11 selects a key on an HSM or smartcard
+	pkcs11 hsm.Config
+
+	// kms references a key in a cloud KMS
+	kms string
+
+	// inputFile is the -f flag; stdin carries either the key or the input
+	inputFile string
+}
+
+// kmsKeyUsage describes the --kms-key flag
+const kmsKeyUsage = "Private key in AWS KMS (key ARN), GCP Cloud KMS (key version name) or Azure Key Vault (key URI), used via the provider's CLI"
+
+// addPKCS11Flags registers the flags selecting a key on a PKCS#11 token
+func addPKCS11Flags(flags *pflag.FlagSet, config *hsm.Config) {
+	flags.StringVar(&config.Module, "pkcs11-module", "", "PKCS#11 library of an HSM or smartcard holding the private key (requires OpenSC's pkcs11-tool)")
+	flags.StringVar(&config.Slot, "pkcs11-slot", "", "PKCS#11 slot ID of the token (default: first slot with a token)")
+	flags.StringVar(&config.PIN, "pkcs11-pin", "", "User PIN of the PKCS#11 token; prefer setting "+hsm.PINEnv+", as other users can see command lines")
+	flags.StringVar(&config.Label, "pkcs11-key-label", "", "Label of the private key on the PKCS#11 token")
+}
+
+// validate rejects flag combinations that leave the key or the input
+// without a source
+func (k keySource) validate() error {
+	sources := 0
+	for _, set := range []bool{k.path != "", k.env != "", k.pkcs11.Module != "", k.kms != ""} {
+		if set {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return fmt.Errorf("only one of --key, --key-env, --pkcs11-module and --kms-key may be given")
+	}
+	if k.path == stdinKey && k.inputFile == "" && !inputFromClipboard {
+		return fmt.Errorf("--key - reads the key from stdin, so the input must be given with -f or --clipboard")
+	}
+	return nil
+}
+
+// set reports whether a key was given
+func (k keySource) set() bool {
+	return k.path != "" || k.env != "" || k.pkcs11.Module != "" || k.kms != ""
+}
+
+// inline reports whether the key is not a file, and so is set up front
+// rather than loaded when the first encrypted message is found
+func (k keySource) inline() bool {
+	return k.env != "" || k.path == stdinKey || k.pkcs11.Module != "" || k.kms != ""
+}
+
+// load reads the private key
+func (k keySource) load(cmd *cobra.Command) (*saml.Decryptor, error) {
+	switch {
+	case k.env != "":
+		value, ok := os.LookupEnv(k.env)
+		if !ok || strings.TrimSpace(value) == "" {
+			return nil, fmt.Errorf("environment variable %s is not set", k.env)
+		}
+		return saml.NewDecryptorFromPEM(envKeyPEM(value))
+	case k.path == stdinKey:
+		return saml.NewDecryptorFromReader(cmd.InOrStdin())
+	case k.pkcs11.Module != "":
+		key, err := hsm.New(k.pkcs11)
+		if err != nil {
+			return nil, err
+		}
+		return saml.NewDecryptorWithKey(key), nil
+	case k.kms != "":
+		key, err := kms.New(k.kms)
+		if err != nil {
+			return nil, err
+		}
+		return saml.NewDecryptorWithKey(key), nil
+	}
+	return saml.NewDecryptor(k.path)
+}
+
+// envKeyPEM returns the PEM held by an environment variable. CI systems
+// often store multi-line secrets base64-encoded, so a value that is not
+// PEM itself is decoded first.
+func envKeyPEM(value string) []byte {
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(value, "-----BEGIN") {
+		return []byte(value)
+	}
+	if decoded, err := base64.StdEncoding.DecodeString(value); err == nil {
+		return decoded
+	}
+	return []byte(value)
+}
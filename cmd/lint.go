@@ -0,0 +1,204 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/gliwka/SAMLurai/internal/inspect"
+	"github.com/gliwka/SAMLurai/internal/output"
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/spf13/cobra"
+)
+
+var (
+	lintFile      string
+	lintKey       string
+	lintDisable   []string
+	lintListRules bool
+)
+
+var lintCmd = &cobra.Command{
+	Use:   "lint",
+	Short: "Check SAML messages for conformance with the SAML 2.0 specifications",
+	Long: `Check SAML messages against rules derived from SAML 2.0 Core, Bindings and
+the Web Browser SSO profile, to find the spec violations that strict
+implementations reject while lenient ones let them pass.
+
+The input can be a SAML XML file, base64-encoded SAML, a HAR file or a
+SAML-tracer export; every message in a HAR file is checked. Encrypted
+assertions are decrypted first if -k is given, otherwise they are skipped.
+
+Among others, these rules are checked (--list-rules shows all of them with
+the sections of the specifications they derive from):
+  - version: Version="2.0" on messages and assertions
+  - id: an ID that is a valid xs:ID, e.g. not starting with a digit
+  - utc-time: times in UTC, ending in Z
+  - issuer: an Issuer on assertions, AuthnRequests and signed Responses
+  - bearer-recipient, bearer-not-on-or-after: a Recipient and NotOnOrAfter
+    on bearer SubjectConfirmationData
+  - audience-restriction: an AudienceRestriction on bearer assertions
+
+Rules are disabled with --disable, or within a document by an XML comment
+such as <!-- samlurai-lint-disable issuer version -->, which applies to the
+element it is in and that element's descendants; before the root element,
+it applies to the whole document.
+
+Plugins with the lint capability add their own rules, with IDs prefixed by
+the plugin name, e.g. acme/test-idp; see samlurai plugins. Their rules are
+only disabled with --disable.
+
+The command exits with an error if any issues are found.
+
+Examples:
+  # Lint a captured response
+  samlurai lint -f response.xml
+
+  # Lint all messages in a HAR file, without the ID length rule
+  samlurai lint -f session.har -k sp-key.pem --disable id-length
+
+  # List the rules
+  samlurai lint --list-rules`,
+	Args: cobra.NoArgs,
+	RunE: runLint,
+}
+
+func init() {
+	rootCmd.AddCommand(lintCmd)
+
+	flags := lintCmd.Flags()
+	flags.StringVarP(&lintFile, "file", "f", "", "Read SAML from file (supports XML, base64, or HAR files)")
+	flags.StringVarP(&lintKey, "key", "k", "", "Path to private key to decrypt encrypted assertions (PEM format)")
+	flags.StringSliceVar(&lintDisable, "disable", nil, "Rule to disable (repeatable)")
+	flags.BoolVar(&lintListRules, "list-rules", false, "List the rules and exit")
+}
+
+// lintResult holds the issues of a single message
+type lintResult struct {
+	Index  int              `json:"index"`
+	Type   string           `json:"type"`
+	URL    string           `json:"url,omitempty"`
+	Error  string           `json:"error,omitempty"`
+	Issues []saml.LintIssue `json:"issues"`
+}
+
+func runLint(cmd *cobra.Command, args []string) error {
+	formatter := output.NewFormatter(outputFormat)
+	rules := append(append([]saml.LintRule{}, saml.LintRules...), pluginLintRules()...)
+	if lintListRules {
+		if formatter.IsJSON() {
+			formatted, err := formatter.FormatJSON(rules)
+			if err != nil {
+				return fmt.Errorf("failed to format output: %w", err)
+			}
+			fmt.Fprint(cmd.OutOrStdout(), formatted)
+		} else {
+			printLintRules(cmd.OutOrStdout(), rules)
+		}
+		return nil
+	}
+
+	for _, id := range lintDisable {
+		known := false
+		for _, rule := range rules {
+			known = known || rule.ID == id
+		}
+		if !known {
+			return fmt.Errorf("unknown rule %q: see samlurai lint --list-rules", id)
+		}
+	}
+
+	input, err := getInspectInput(cmd, lintFile)
+	if err != nil {
+		return err
+	}
+	result, err := inspect.Run(cmd.Context(), inspect.Request{
+		Input:    input,
+		Filename: lintFile,
+		KeyPath:  lintKey,
+	})
+	if err != nil {
+		return err
+	}
+
+	results := []lintResult{}
+	issues := 0
+	for _, msg := range result.Messages {
+		r := lintResult{Index: msg.Index(), Type: msg.Type(), Issues: []saml.LintIssue{}}
+		if msg.Extracted != nil {
+			r.URL = msg.Extracted.URL
+		}
+		found, err := saml.Lint(msg.XML, saml.LintOptions{Disabled: lintDisable})
+		if err != nil {
+			r.Error = err.Error()
+		} else {
+			r.Issues = append(r.Issues, found...)
+			if found, err = lintWithPlugins(cmd.Context(), msg.XML, lintDisable); err != nil {
+				return err
+			}
+			r.Issues = append(r.Issues, found...)
+		}
+		issues += len(r.Issues)
+		results = append(results, r)
+	}
+
+	if formatter.IsJSON() {
+		formatted, err := formatter.FormatJSON(results)
+		if err != nil {
+			return fmt.Errorf("failed to format output: %w", err)
+		}
+		fmt.Fprint(cmd.OutOrStdout(), formatted)
+	} else {
+		printLintResults(cmd.OutOrStdout(), results, result.IsHAR)
+	}
+
+	if issues > 0 {
+		return withExitCode(ExitFindings, fmt.Errorf("found %d issue(s)", issues))
+	}
+	return nil
+}
+
+func printLintRules(w io.Writer, rules []saml.LintRule) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "RULE\tSEVERITY\tSPECIFICATION\tDESCRIPTION")
+	for _, rule := range rules {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", rule.ID, rule.Severity, rule.Spec, rule.Description)
+	}
+	tw.Flush()
+}
+
+func printLintResults(w io.Writer, results []lintResult, isHAR bool) {
+	if isHAR && len(results) == 0 {
+		fmt.Fprintln(w, "No SAML assertions found in the HAR file.")
+		return
+	}
+
+	counts := map[string]int{}
+	for i, r := range results {
+		if isHAR {
+			fmt.Fprintf(w, "[%d/%d] %s from %s\n", i+1, len(results), r.Type, truncateURL(r.URL, 70))
+		}
+		switch {
+		case r.Error != "":
+			fmt.Fprintf(w, "⚠️  Skipped: %s\n", r.Error)
+		case len(r.Issues) == 0:
+			fmt.Fprintf(w, "✓ %s: no issues found\n", r.Type)
+		}
+		for _, issue := range r.Issues {
+			if issue.Spec != "" {
+				fmt.Fprintf(w, "[%s] %s: %s (%s)\n", strings.ToUpper(issue.Severity), issue.Rule, issue.Message, issue.Spec)
+			} else {
+				fmt.Fprintf(w, "[%s] %s: %s\n", strings.ToUpper(issue.Severity), issue.Rule, issue.Message)
+			}
+			counts[issue.Severity]++
+		}
+		if isHAR {
+			fmt.Fprintln(w)
+		}
+	}
+
+	if counts[saml.SeverityError]+counts[saml.SeverityWarning] > 0 {
+		fmt.Fprintf(w, "Summary: %d error(s), %d warning(s)\n", counts[saml.SeverityError], counts[saml.SeverityWarning])
+	}
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/gliwka/SAMLurai/internal/output"
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/spf13/cobra"
+)
+
+var (
+	lintTemplateFile string
+)
+
+var lintTemplateCmd = &cobra.Command{
+	Use:   "lint-template",
+	Short: "Check an IdP response template for structural issues",
+	Long: `Check a SAML response template (plain XML or Velocity) for structural
+issues before it reaches production.
+
+Velocity directives (#if, #foreach, #set, ...) are ignored and placeholders
+such as $var, ${var}, $!{var} or {{var}} are treated as opaque values.
+
+The following checks are performed:
+  - Unclosed or mismatched elements
+  - Undeclared namespace prefixes on elements and attributes
+  - SAML elements without a namespace
+  - xs:dateTime attributes (IssueInstant, NotBefore, NotOnOrAfter, ...)
+    whose literal value or placeholder pattern cannot produce a valid
+    xs:dateTime
+
+The command exits with an error if any issues are found.
+
+Examples:
+  # Lint a Velocity response template
+  samlurai lint-template -f response.vm
+
+  # Machine-readable output
+  samlurai lint-template -f response.xml -o json`,
+	RunE: runLintTemplate,
+}
+
+func init() {
+	rootCmd.AddCommand(lintTemplateCmd)
+
+	lintTemplateCmd.Flags().StringVarP(&lintTemplateFile, "file", "f", "", "Template file to lint (required)")
+	_ = lintTemplateCmd.MarkFlagRequired("file")
+}
+
+func runLintTemplate(cmd *cobra.Command, args []string) error {
+	data, err := os.ReadFile(lintTemplateFile)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+
+	issues := saml.LintTemplate(data)
+
+	if output.NewFormatter(outputFormat).IsJSON() {
+		if issues == nil {
+			issues = []saml.TemplateIssue{}
+		}
+		formatted, err := output.NewFormatter(outputFormat).FormatJSON(issues)
+		if err != nil {
+			return fmt.Errorf("failed to format output: %w", err)
+		}
+		fmt.Fprint(cmd.OutOrStdout(), formatted)
+	} else {
+		if len(issues) == 0 {
+			fmt.Fprintf(cmd.OutOrStdout(), "✓ %s: no issues found\n", lintTemplateFile)
+		}
+		for _, issue := range issues {
+			fmt.Fprintf(cmd.OutOrStdout(), "%s:%d: %s: %s\n", lintTemplateFile, issue.Line, issue.Severity, issue.Message)
+		}
+	}
+
+	if len(issues) > 0 {
+		return fmt.Errorf("template has %d issue(s)", len(issues))
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func resetLintTemplateFlags() {
+	lintTemplateFile = ""
+	outputFormat = "pretty"
+}
+
+func TestLintTemplateCmd_Clean(t *testing.T) {
+	resetLintTemplateFlags()
+
+	tmpFile := createTempFile(t, `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="$id" IssueInstant="$now"/>`)
+	defer os.Remove(tmpFile)
+
+	output, err := executeCommand(rootCmd, "lint-template", "-f", tmpFile)
+	require.NoError(t, err)
+	assert.Contains(t, output, "no issues found")
+}
+
+func TestLintTemplateCmd_Issues(t *testing.T) {
+	resetLintTemplateFlags()
+
+	tmpFile := createTempFile(t, "<samlp:Response xmlns:samlp=\"urn:oasis:names:tc:SAML:2.0:protocol\">\n<saml:Issuer>$issuer</saml:Issuer>")
+	defer os.Remove(tmpFile)
+
+	output, err := executeCommand(rootCmd, "lint-template", "-f", tmpFile, "-o", "json")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "template has 2 issue(s)")
+	assert.Contains(t, output, `"line": 2`)
+	assert.Contains(t, output, "is not declared")
+	assert.Contains(t, output, "is never closed")
+}
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func resetLintFlags() {
+	lintFile = ""
+	lintKey = ""
+	lintDisable = nil
+	lintListRules = false
+	outputFormat = "pretty"
+}
+
+func TestLintCmd_Issues(t *testing.T) {
+	resetLintFlags()
+
+	output, err := executeCommand(rootCmd, "lint", "-f", "../testdata/fixtures/assertions/response.xml")
+	require.Error(t, err)
+	assert.Equal(t, ExitFindings, ExitCode(err))
+	assert.Contains(t, err.Error(), "found 5 issue(s)")
+	assert.Contains(t, output, `[ERROR] version: <samlp:Response ID="_response123"> has no Version attribute (SAML Core 2.3.3, 3.2.1, 3.2.2)`)
+	assert.Contains(t, output, "[ERROR] bearer-confirmation: ")
+	assert.Contains(t, output, "Summary: 3 error(s), 2 warning(s)")
+}
+
+func TestLintCmd_Disable(t *testing.T) {
+	resetLintFlags()
+
+	output, err := executeCommand(rootCmd, "lint", "-f", "../testdata/fixtures/assertions/response.xml", "-o", "json",
+		"--disable", "version,id-length", "--disable", "bearer-confirmation")
+	require.NoError(t, err)
+
+	var results []lintResult
+	require.NoError(t, json.NewDecoder(strings.NewReader(output)).Decode(&results))
+	require.Len(t, results, 1)
+	assert.Equal(t, "Response", results[0].Type)
+	assert.Empty(t, results[0].Issues)
+
+	resetLintFlags()
+	_, err = executeCommand(rootCmd, "lint", "-f", "../testdata/fixtures/assertions/response.xml", "--disable", "versoin")
+	assert.EqualError(t, err, `unknown rule "versoin": see samlurai lint --list-rules`)
+}
+
+func TestLintCmd_InlineSuppression(t *testing.T) {
+	resetLintFlags()
+
+	tmpFile := createTempFile(t, `<!-- samlurai-lint-disable id-length -->
+<samlp:LogoutRequest xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion"
+    ID="_l" Version="2.0" IssueInstant="2024-01-15T10:00:00Z">
+  <saml:Issuer>https://sp.example.com</saml:Issuer>
+  <saml:NameID>user@example.com</saml:NameID>
+</samlp:LogoutRequest>`)
+	defer os.Remove(tmpFile)
+
+	output, err := executeCommand(rootCmd, "lint", "-f", tmpFile)
+	require.NoError(t, err)
+	assert.Contains(t, output, "no issues found")
+}
+
+func TestLintCmd_ListRules(t *testing.T) {
+	resetLintFlags()
+
+	output, err := executeCommand(rootCmd, "lint", "--list-rules")
+	require.NoError(t, err)
+	assert.Contains(t, output, "RULE")
+	for _, rule := range saml.LintRules {
+		assert.Contains(t, output, rule.ID)
+	}
+
+	resetLintFlags()
+	output, err = executeCommand(rootCmd, "lint", "--list-rules", "-o", "json")
+	require.NoError(t, err)
+	var rules []saml.LintRule
+	require.NoError(t, json.NewDecoder(strings.NewReader(output)).Decode(&rules))
+	assert.Len(t, rules, len(saml.LintRules))
+}
@@ -0,0 +1,195 @@
+package cmd
+
+import (
+	"crypto"
+	"crypto/x509"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gliwka/SAMLurai/internal/metadata"
+	"github.com/gliwka/SAMLurai/internal/output"
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/spf13/cobra"
+)
+
+var (
+	logoutIssuer       string
+	logoutDestination  string
+	logoutNameID       string
+	logoutNameIDFormat string
+	logoutSessionIndex []string
+	logoutReason       string
+	logoutValidFor     time.Duration
+	logoutBinding      string
+	logoutRelayState   string
+	logoutSignKey      string
+	logoutSignCert     string
+	logoutSigAlg       string
+)
+
+var logoutRequestCmd = &cobra.Command{
+	Use:   "logoutrequest",
+	Short: "Build a LogoutRequest for the Redirect or POST binding",
+	Long: `Build a LogoutRequest to test single logout against an IdP or SP.
+
+With the Redirect binding (default), the URL to open is printed: the
+request is deflated and base64-encoded into the SAMLRequest parameter, and
+the query is signed with --sign-key. With the POST binding, an HTML page
+with a form that posts the request as soon as it loads is printed; with
+--sign-key the request carries an enveloped signature.
+
+Use -o xml for the LogoutRequest itself, or -o json for the XML together
+with the URL or form.
+
+Reasons: user, admin, or a reason URI.
+
+NameID formats: email, persistent, transient, unspecified, entity, or a
+format URI.
+
+Examples:
+  # Redirect URL ending one session of a user
+  samlurai logoutrequest --issuer https://sp.example.com \
+    --destination https://idp.example.com/slo \
+    --nameid alice@example.com --nameid-format email --session-index _abc123
+
+  # Signed Redirect URL
+  samlurai logoutrequest --issuer https://sp.example.com \
+    --destination https://idp.example.com/slo --nameid alice@example.com \
+    --sign-key sp-key.pem
+
+  # Auto-posting HTML form, signed, saved to open in a browser
+  samlurai logoutrequest --binding post --issuer https://sp.example.com \
+    --destination https://idp.example.com/slo --nameid alice@example.com \
+    --reason user --sign-key sp-key.pem --sign-cert sp.pem > logout.html`,
+	RunE: runLogoutRequest,
+}
+
+func init() {
+	rootCmd.AddCommand(logoutRequestCmd)
+
+	flags := logoutRequestCmd.Flags()
+	flags.StringVar(&logoutIssuer, "issuer", "", "Entity ID of the sender (required)")
+	flags.StringVar(&logoutDestination, "destination", "", "SingleLogoutService URL of the recipient (required)")
+	flags.StringVar(&logoutNameID, "nameid", "", "NameID of the principal to log out (required)")
+	flags.StringVar(&logoutNameIDFormat, "nameid-format", "", "NameID format")
+	flags.StringArrayVar(&logoutSessionIndex, "session-index", nil, "SessionIndex of a session to end (repeatable; default: all sessions)")
+	flags.StringVar(&logoutReason, "reason", "", "Reason for the logout: user, admin or a URI")
+	flags.DurationVar(&logoutValidFor, "valid-for", 0, "Set NotOnOrAfter this far in the future")
+	flags.StringVar(&logoutBinding, "binding", "redirect", "Binding to send the request with: redirect or post")
+	flags.StringVar(&logoutRelayState, "relay-state", "", "RelayState to send with the request")
+	flags.StringVar(&logoutSignKey, "sign-key", "", "Sign the request with this private key (PEM)")
+	flags.StringVar(&logoutSignCert, "sign-cert", "", "Certificate to include in the KeyInfo of a POST signature")
+	flags.StringVar(&logoutSigAlg, "sig-alg", "", "Signature algorithm for --sign-key, e.g. rsa-sha256 (default: from key type)")
+	_ = logoutRequestCmd.MarkFlagRequired("issuer")
+	_ = logoutRequestCmd.MarkFlagRequired("destination")
+	_ = logoutRequestCmd.MarkFlagRequired("nameid")
+}
+
+// logoutRequestOutput is the JSON output of logoutrequest
+type logoutRequestOutput struct {
+	ID          string `json:"id"`
+	Binding     string `json:"binding"`
+	Destination string `json:"destination"`
+	URL         string `json:"url,omitempty"`
+	Form        string `json:"form,omitempty"`
+	XML         string `json:"xml"`
+}
+
+func runLogoutRequest(cmd *cobra.Command, args []string) error {
+	binding := strings.ToLower(logoutBinding)
+	if binding != "redirect" && binding != "post" {
+		return fmt.Errorf("unsupported binding %q (use redirect or post)", logoutBinding)
+	}
+	if logoutSignCert != "" && logoutSignKey == "" {
+		return fmt.Errorf("--sign-cert requires --sign-key")
+	}
+
+	opts := saml.LogoutRequestOptions{
+		Issuer:         logoutIssuer,
+		Destination:    logoutDestination,
+		NameID:         logoutNameID,
+		SessionIndexes: logoutSessionIndex,
+		Reason:         logoutReason,
+	}
+	if logoutNameIDFormat != "" {
+		format, err := metadata.ResolveNameIDFormat(logoutNameIDFormat)
+		if err != nil {
+			return err
+		}
+		opts.NameIDFormat = format
+	}
+	now := time.Now()
+	if logoutValidFor > 0 {
+		opts.NotOnOrAfter = now.Add(logoutValidFor)
+	}
+
+	doc, err := saml.BuildLogoutRequest(opts, now)
+	if err != nil {
+		return err
+	}
+	req := doc.Root()
+
+	var key crypto.Signer
+	var sigAlg string
+	if logoutSignKey != "" {
+		if key, err = saml.LoadSigningKey(logoutSignKey); err != nil {
+			return fmt.Errorf("failed to load private key: %w", err)
+		}
+		if sigAlg, err = resolveSigAlgFor(logoutSigAlg, key); err != nil {
+			return err
+		}
+	}
+
+	// The POST binding signs the XML; the Redirect binding signs the query
+	if binding == "post" && key != nil {
+		var cert *x509.Certificate
+		if logoutSignCert != "" {
+			if cert, err = saml.LoadCertificate(logoutSignCert); err != nil {
+				return err
+			}
+		}
+		if err := saml.SignEnveloped(req, key, cert, sigAlg); err != nil {
+			return fmt.Errorf("failed to sign request: %w", err)
+		}
+	}
+
+	xmlData, err := doc.WriteToBytes()
+	if err != nil {
+		return fmt.Errorf("failed to serialize request: %w", err)
+	}
+
+	result := logoutRequestOutput{
+		ID:          req.SelectAttrValue("ID", ""),
+		Binding:     saml.BindingHTTPRedirect,
+		Destination: logoutDestination,
+		XML:         string(xmlData),
+	}
+	if binding == "post" {
+		result.Binding = saml.BindingHTTPPost
+		result.Form, err = saml.PostForm(logoutDestination, xmlData, "SAMLRequest", logoutRelayState)
+	} else {
+		result.URL, err = saml.RedirectURL(logoutDestination, xmlData, "SAMLRequest", logoutRelayState, key, sigAlg)
+	}
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	formatter := output.NewFormatter(outputFormat)
+	switch {
+	case formatter.IsJSON():
+		formatted, err := formatter.FormatJSON(result)
+		if err != nil {
+			return fmt.Errorf("failed to format output: %w", err)
+		}
+		fmt.Fprint(out, formatted)
+	case outputFormat == "xml":
+		fmt.Fprintln(out, result.XML)
+	case result.Form != "":
+		fmt.Fprint(out, result.Form)
+	default:
+		fmt.Fprintln(out, result.URL)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"html"
+	"math/big"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/beevik/etree"
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func resetLogoutRequestFlags() {
+	logoutIssuer = ""
+	logoutDestination = ""
+	logoutNameID = ""
+	logoutNameIDFormat = ""
+	logoutSessionIndex = nil
+	logoutReason = ""
+	logoutValidFor = 0
+	logoutBinding = "redirect"
+	logoutRelayState = ""
+	logoutSignKey = ""
+	logoutSignCert = ""
+	logoutSigAlg = ""
+	outputFormat = "pretty"
+}
+
+func TestLogoutRequestCmd_Redirect(t *testing.T) {
+	resetLogoutRequestFlags()
+	defer resetLogoutRequestFlags()
+
+	output, err := executeCommand(rootCmd, "logoutrequest",
+		"--issuer", "https://sp.example.com",
+		"--destination", "https://idp.example.com/slo",
+		"--nameid", "alice@example.com", "--nameid-format", "email",
+		"--session-index", "_s1", "--reason", "admin", "--relay-state", "/bye")
+	require.NoError(t, err)
+
+	u, err := url.Parse(strings.TrimSpace(output))
+	require.NoError(t, err)
+	assert.Equal(t, "idp.example.com", u.Host)
+	assert.Equal(t, "/bye", u.Query().Get("RelayState"))
+	assert.Empty(t, u.Query().Get("Signature"))
+
+	xmlData, err := saml.NewDecoder().DecodeDeflate(u.Query().Get("SAMLRequest"))
+	require.NoError(t, err)
+	xml := string(xmlData)
+	assert.Contains(t, xml, `Reason="urn:oasis:names:tc:SAML:2.0:logout:admin"`)
+	assert.Contains(t, xml, `<saml:NameID Format="urn:oasis:names:tc:SAML:1.1:nameid-format:emailAddress">alice@example.com</saml:NameID>`)
+	assert.Contains(t, xml, `<samlp:SessionIndex>_s1</samlp:SessionIndex>`)
+}
+
+func TestLogoutRequestCmd_PostSigned(t *testing.T) {
+	resetLogoutRequestFlags()
+	defer resetLogoutRequestFlags()
+
+	dir := t.TempDir()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	keyPath := filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}), 0600))
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "sp.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	certPath := filepath.Join(dir, "cert.pem")
+	require.NoError(t, os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644))
+
+	output, err := executeCommand(rootCmd, "logoutrequest", "--binding", "post",
+		"--issuer", "https://sp.example.com",
+		"--destination", "https://idp.example.com/slo",
+		"--nameid", "alice@example.com", "--sign-key", keyPath, "--sign-cert", certPath)
+	require.NoError(t, err)
+	assert.Contains(t, output, `<form method="post" action="https://idp.example.com/slo">`)
+
+	match := regexp.MustCompile(`name="SAMLRequest" value="([^"]+)"`).FindStringSubmatch(output)
+	require.Len(t, match, 2)
+	// Attribute values are HTML-escaped, e.g. + as &#43;
+	xmlData, err := base64.StdEncoding.DecodeString(html.UnescapeString(match[1]))
+	require.NoError(t, err)
+	doc := etree.NewDocument()
+	require.NoError(t, doc.ReadFromBytes(xmlData))
+	signer, err := saml.VerifySignature(doc.Root(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, der, signer.Raw)
+}
+
+func TestLogoutRequestCmd_JSON(t *testing.T) {
+	resetLogoutRequestFlags()
+	defer resetLogoutRequestFlags()
+
+	output, err := executeCommand(rootCmd, "logoutrequest", "-o", "json",
+		"--issuer", "https://sp.example.com",
+		"--destination", "https://idp.example.com/slo",
+		"--nameid", "alice@example.com")
+	require.NoError(t, err)
+
+	var result logoutRequestOutput
+	require.NoError(t, json.Unmarshal([]byte(output), &result))
+	assert.Equal(t, saml.BindingHTTPRedirect, result.Binding)
+	assert.True(t, strings.HasPrefix(result.URL, "https://idp.example.com/slo?SAMLRequest="))
+	assert.Contains(t, result.XML, `ID="`+result.ID+`"`)
+	assert.Empty(t, result.Form)
+}
+
+func TestLogoutRequestCmd_Invalid(t *testing.T) {
+	resetLogoutRequestFlags()
+	defer resetLogoutRequestFlags()
+
+	_, err := executeCommand(rootCmd, "logoutrequest", "--binding", "artifact",
+		"--issuer", "https://sp.example.com",
+		"--destination", "https://idp.example.com/slo",
+		"--nameid", "alice@example.com")
+	assert.ErrorContains(t, err, `unsupported binding "artifact"`)
+}
@@ -0,0 +1,295 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gliwka/SAMLurai/internal/metadata"
+	"github.com/gliwka/SAMLurai/internal/output"
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/spf13/cobra"
+)
+
+var (
+	mdGenConfig         string
+	mdGenEntityID       string
+	mdGenACS            []string
+	mdGenSLO            []string
+	mdGenSigningCert    string
+	mdGenEncryptionCert string
+	mdGenNameIDFormats  []string
+	mdGenAttributes     []string
+	mdGenServiceName    string
+	mdGenAuthnSigned    bool
+	mdGenWantSigned     bool
+	mdGenValidFor       time.Duration
+	mdGenSignKey        string
+	mdGenSigAlg         string
+
+	mdDiffEntityID string
+)
+
+var metadataCmd = &cobra.Command{
+	Use:   "metadata",
+	Short: "Work with SAML metadata",
+}
+
+var metadataGenerateCmd = &cobra.Command{
+	Use:   "generate",
+	Short: "Generate SP metadata from flags or a config file",
+	Long: `Generate an SP EntityDescriptor to register a new service provider
+with an IdP.
+
+The SP is described by flags, a YAML config file, or both; flags override
+the values in the config file:
+
+  entity_id: https://sp.example.com/saml
+  acs:
+    - url: https://sp.example.com/saml/acs
+    - url: https://sp.example.com/saml/acs/artifact
+      binding: artifact
+  slo:
+    - url: https://sp.example.com/saml/slo
+  signing_cert: sp-signing.pem
+  encryption_cert: sp-encryption.pem
+  name_id_formats: [persistent, email]
+  authn_requests_signed: true
+  want_assertions_signed: true
+  service_name: Example App
+  requested_attributes:
+    - name: urn:oid:0.9.2342.19200300.100.1.3
+      friendly_name: mail
+      required: true
+  valid_for: 8760h
+
+Bindings: post (default for ACS), redirect (default for SLO), artifact,
+soap, paos, simplesign, or a binding URI. On the command line a binding is
+given as a prefix, e.g. --acs artifact=https://sp.example.com/acs.
+
+NameID formats: email, persistent, transient, unspecified, entity, or a
+format URI.
+
+Examples:
+  # Minimal metadata
+  samlurai metadata generate --entity-id https://sp.example.com --acs https://sp.example.com/acs
+
+  # With certificates, logout and requested attributes
+  samlurai metadata generate --entity-id https://sp.example.com \
+    --acs https://sp.example.com/acs --slo https://sp.example.com/slo \
+    --signing-cert sp.pem --encryption-cert sp.pem --nameid-format persistent \
+    --attribute urn:oid:0.9.2342.19200300.100.1.3 > sp-metadata.xml
+
+  # From a config file, signed with the SP key
+  samlurai metadata generate --config sp.yaml --sign-key sp-key.pem`,
+	RunE: runMetadataGenerate,
+}
+
+var metadataDiffCmd = &cobra.Command{
+	Use:   "diff OLD NEW",
+	Short: "Compare two versions of an entity's metadata",
+	Long: `Compare two versions of an entity's metadata, given as files or URLs,
+and report what changed, e.g. to review an IdP migration or detect drift:
+
+  - endpoints added, removed or moved to another location
+  - certificate rollovers, added and removed certificates
+  - NameID formats added or removed
+  - attributes offered by an IdP, and attributes an SP requests or requires
+  - signing requirements such as WantAssertionsSigned
+
+validUntil and cacheDuration are not compared. Expired metadata is accepted,
+so archived versions can be compared. Aggregates need --entity-id to select
+the entity.
+
+The command exits with an error if the versions differ.
+
+Examples:
+  # Review a new IdP metadata file against the registered one
+  samlurai metadata diff idp-metadata.xml idp-metadata-new.xml
+
+  # Detect drift between a saved copy and the published metadata
+  samlurai metadata diff saved.xml https://idp.example.com/metadata -o json`,
+	Args: cobra.ExactArgs(2),
+	RunE: runMetadataDiff,
+}
+
+func init() {
+	rootCmd.AddCommand(metadataCmd)
+	metadataCmd.AddCommand(metadataGenerateCmd)
+	metadataCmd.AddCommand(metadataDiffCmd)
+
+	flags := metadataGenerateCmd.Flags()
+	flags.StringVar(&mdGenConfig, "config", "", "YAML file describing the SP")
+	flags.StringVar(&mdGenEntityID, "entity-id", "", "Entity ID of the SP")
+	flags.StringArrayVar(&mdGenACS, "acs", nil, "AssertionConsumerService URL, optionally prefixed with binding= (repeatable; the first is the default)")
+	flags.StringArrayVar(&mdGenSLO, "slo", nil, "SingleLogoutService URL, optionally prefixed with binding= (repeatable)")
+	flags.StringVar(&mdGenSigningCert, "signing-cert", "", "Signing certificate (PEM or DER)")
+	flags.StringVar(&mdGenEncryptionCert, "encryption-cert", "", "Encryption certificate (PEM or DER)")
+	flags.StringArrayVar(&mdGenNameIDFormats, "nameid-format", nil, "Supported NameID format (repeatable)")
+	flags.StringArrayVar(&mdGenAttributes, "attribute", nil, "Requested attribute name (repeatable)")
+	flags.StringVar(&mdGenServiceName, "service-name", "", "Name of the service requesting the attributes")
+	flags.BoolVar(&mdGenAuthnSigned, "authn-requests-signed", false, "Declare that AuthnRequests are signed")
+	flags.BoolVar(&mdGenWantSigned, "want-assertions-signed", false, "Require signed assertions")
+	flags.DurationVar(&mdGenValidFor, "valid-for", 0, "Set validUntil this far in the future")
+	flags.StringVar(&mdGenSignKey, "sign-key", "", "Sign the metadata with this private key (PEM) and the signing certificate")
+	flags.StringVar(&mdGenSigAlg, "sig-alg", "rsa-sha256", "Signature algorithm for --sign-key")
+
+	metadataDiffCmd.Flags().StringVar(&mdDiffEntityID, "entity-id", "", "Entity to compare when a document is an aggregate")
+}
+
+func runMetadataGenerate(cmd *cobra.Command, args []string) error {
+	cfg := &metadata.SPConfig{}
+	if mdGenConfig != "" {
+		var err error
+		if cfg, err = metadata.LoadSPConfig(mdGenConfig); err != nil {
+			return err
+		}
+	}
+
+	flags := cmd.Flags()
+	if flags.Changed("entity-id") {
+		cfg.EntityID = mdGenEntityID
+	}
+	if flags.Changed("acs") {
+		cfg.ACS = parseEndpoints(mdGenACS)
+	}
+	if flags.Changed("slo") {
+		cfg.SLO = parseEndpoints(mdGenSLO)
+	}
+	if flags.Changed("signing-cert") {
+		cfg.SigningCert = mdGenSigningCert
+	}
+	if flags.Changed("encryption-cert") {
+		cfg.EncryptionCert = mdGenEncryptionCert
+	}
+	if flags.Changed("nameid-format") {
+		cfg.NameIDFormats = mdGenNameIDFormats
+	}
+	if flags.Changed("attribute") {
+		cfg.RequestedAttributes = nil
+		for _, name := range mdGenAttributes {
+			cfg.RequestedAttributes = append(cfg.RequestedAttributes, metadata.RequestedAttribute{Name: name})
+		}
+	}
+	if flags.Changed("service-name") {
+		cfg.ServiceName = mdGenServiceName
+	}
+	if flags.Changed("authn-requests-signed") {
+		cfg.AuthnRequestsSigned = mdGenAuthnSigned
+	}
+	if flags.Changed("want-assertions-signed") {
+		cfg.WantAssertionsSigned = mdGenWantSigned
+	}
+	if flags.Changed("valid-for") {
+		cfg.ValidFor = mdGenValidFor
+	}
+
+	var signer *metadata.Signer
+	if mdGenSignKey != "" {
+		sigAlg, err := saml.ResolveSigAlg(mdGenSigAlg)
+		if err != nil {
+			return err
+		}
+		key, err := saml.LoadSigningKey(mdGenSignKey)
+		if err != nil {
+			return err
+		}
+		signer = &metadata.Signer{Key: key, SigAlg: sigAlg}
+	}
+
+	generated, err := metadata.GenerateSP(cfg, time.Now(), signer)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(cmd.OutOrStdout(), string(generated))
+	return nil
+}
+
+func parseEndpoints(values []string) []metadata.Endpoint {
+	endpoints := make([]metadata.Endpoint, len(values))
+	for i, value := range values {
+		endpoints[i] = metadata.ParseEndpoint(value)
+	}
+	return endpoints
+}
+
+func runMetadataDiff(cmd *cobra.Command, args []string) error {
+	var versions [2][]byte
+	for i, source := range args {
+		doc, err := metadata.Load(cmd.Context(), source, metadata.Options{AllowExpired: true})
+		if err != nil {
+			return fmt.Errorf("%s: %w", source, err)
+		}
+		versions[i] = doc.Data
+	}
+
+	changes, err := metadata.Diff(versions[0], versions[1], mdDiffEntityID)
+	if err != nil {
+		return err
+	}
+
+	formatter := output.NewFormatter(outputFormat)
+	if formatter.IsJSON() {
+		if changes == nil {
+			changes = []metadata.Change{}
+		}
+		formatted, err := formatter.FormatJSON(changes)
+		if err != nil {
+			return fmt.Errorf("failed to format output: %w", err)
+		}
+		fmt.Fprint(cmd.OutOrStdout(), formatted)
+	} else {
+		printMetadataChanges(cmd, changes)
+	}
+
+	if len(changes) > 0 {
+		return fmt.Errorf("found %d change(s)", len(changes))
+	}
+	return nil
+}
+
+// printMetadataChanges lists changes by role, marked + (added), - (removed)
+// and ~ (changed)
+func printMetadataChanges(cmd *cobra.Command, changes []metadata.Change) {
+	out := cmd.OutOrStdout()
+	if len(changes) == 0 {
+		fmt.Fprintln(out, "✅ No changes")
+		return
+	}
+
+	var roles []string
+	byRole := map[string][]metadata.Change{}
+	for _, change := range changes {
+		if _, ok := byRole[change.Role]; !ok {
+			roles = append(roles, change.Role)
+		}
+		byRole[change.Role] = append(byRole[change.Role], change)
+	}
+
+	for i, role := range roles {
+		if i > 0 {
+			fmt.Fprintln(out)
+		}
+		if role == "" {
+			fmt.Fprintln(out, "EntityDescriptor")
+		} else {
+			fmt.Fprintln(out, role)
+		}
+		for _, change := range byRole[role] {
+			switch change.Kind {
+			case metadata.ChangeAdded:
+				fmt.Fprintf(out, "  + %s%s\n", change.Subject, valueSuffix(change.New))
+			case metadata.ChangeRemoved:
+				fmt.Fprintf(out, "  - %s%s\n", change.Subject, valueSuffix(change.Old))
+			default:
+				fmt.Fprintf(out, "  ~ %s: %s → %s\n", change.Subject, change.Old, change.New)
+			}
+		}
+	}
+}
+
+func valueSuffix(value string) string {
+	if value == "" {
+		return ""
+	}
+	return ": " + value
+}
@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func resetMetadataFlags() {
+	mdGenConfig = ""
+	mdGenEntityID = ""
+	mdGenACS = nil
+	mdGenSLO = nil
+	mdGenSigningCert = ""
+	mdGenEncryptionCert = ""
+	mdGenNameIDFormats = nil
+	mdGenAttributes = nil
+	mdGenServiceName = ""
+	mdGenAuthnSigned = false
+	mdGenWantSigned = false
+	mdGenValidFor = 0
+	mdGenSignKey = ""
+	mdGenSigAlg = "rsa-sha256"
+	mdDiffEntityID = ""
+	metadataGenerateCmd.Flags().VisitAll(func(f *pflag.Flag) { f.Changed = false })
+}
+
+func TestMetadataGenerateCmd_Flags(t *testing.T) {
+	resetMetadataFlags()
+	defer resetMetadataFlags()
+
+	output, err := executeCommand(rootCmd, "metadata", "generate",
+		"--entity-id", "https://sp.example.com",
+		"--acs", "https://sp.example.com/acs",
+		"--slo", "post=https://sp.example.com/slo",
+		"--nameid-format", "email",
+		"--attribute", "mail",
+		"--want-assertions-signed")
+	require.NoError(t, err)
+	assert.Contains(t, output, `entityID="https://sp.example.com"`)
+	assert.Contains(t, output, `WantAssertionsSigned="true"`)
+	assert.Contains(t, output, `<md:SingleLogoutService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST" Location="https://sp.example.com/slo"/>`)
+	assert.Contains(t, output, `<md:NameIDFormat>urn:oasis:names:tc:SAML:1.1:nameid-format:emailAddress</md:NameIDFormat>`)
+	assert.Contains(t, output, `<md:RequestedAttribute Name="mail" isRequired="false"/>`)
+}
+
+func TestMetadataGenerateCmd_ConfigWithOverrides(t *testing.T) {
+	resetMetadataFlags()
+	defer resetMetadataFlags()
+
+	cert := testCertificateBase64(t, time.Now().Add(24*time.Hour))
+	certFile := createTempFile(t, "-----BEGIN CERTIFICATE-----\n"+cert+"\n-----END CERTIFICATE-----\n")
+	defer os.Remove(certFile)
+	configFile := createTempFile(t, `entity_id: https://old.example.com
+acs:
+  - url: https://sp.example.com/acs
+signing_cert: `+certFile+`
+`)
+	defer os.Remove(configFile)
+
+	output, err := executeCommand(rootCmd, "metadata", "generate", "--config", configFile, "--entity-id", "https://new.example.com")
+	require.NoError(t, err)
+	assert.Contains(t, output, `entityID="https://new.example.com"`)
+	assert.Contains(t, output, `<md:KeyDescriptor use="signing">`)
+}
+
+func TestMetadataGenerateCmd_Missing(t *testing.T) {
+	resetMetadataFlags()
+	defer resetMetadataFlags()
+
+	_, err := executeCommand(rootCmd, "metadata", "generate", "--entity-id", "https://sp.example.com")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "at least one AssertionConsumerService URL is required")
+}
+
+func TestMetadataDiffCmd(t *testing.T) {
+	resetMetadataFlags()
+	defer resetMetadataFlags()
+	outputFormat = "pretty"
+
+	idp := func(sso string) string {
+		return `<md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" entityID="https://idp.example.com" validUntil="2020-01-01T00:00:00Z">` +
+			`<md:IDPSSODescriptor protocolSupportEnumeration="urn:oasis:names:tc:SAML:2.0:protocol">` +
+			`<md:SingleSignOnService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect" Location="` + sso + `"/>` +
+			`</md:IDPSSODescriptor></md:EntityDescriptor>`
+	}
+	oldFile := createTempFile(t, idp("https://idp.example.com/sso"))
+	defer os.Remove(oldFile)
+	newFile := createTempFile(t, idp("https://login.example.com/sso"))
+	defer os.Remove(newFile)
+
+	output, err := executeCommand(rootCmd, "metadata", "diff", oldFile, newFile)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "found 1 change(s)")
+	assert.Contains(t, output, "IDPSSODescriptor\n  ~ SingleSignOnService (HTTP-Redirect): https://idp.example.com/sso → https://login.example.com/sso")
+
+	output, err = executeCommand(rootCmd, "metadata", "diff", oldFile, oldFile)
+	require.NoError(t, err)
+	assert.Contains(t, output, "No changes")
+}
+
+func TestMetadataDiffCmd_JSON(t *testing.T) {
+	resetMetadataFlags()
+	defer resetMetadataFlags()
+	defer func() { outputFormat = "pretty" }()
+
+	oldFile := createTempFile(t, `<md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" entityID="https://sp.example.com"><md:SPSSODescriptor/></md:EntityDescriptor>`)
+	defer os.Remove(oldFile)
+	newFile := createTempFile(t, `<md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" entityID="https://sp.example.com"><md:SPSSODescriptor>`+
+		`<md:NameIDFormat>urn:oasis:names:tc:SAML:2.0:nameid-format:persistent</md:NameIDFormat></md:SPSSODescriptor></md:EntityDescriptor>`)
+	defer os.Remove(newFile)
+
+	output, err := executeCommand(rootCmd, "metadata", "diff", oldFile, newFile, "-o", "json")
+	require.Error(t, err)
+	assert.Contains(t, output, `"kind": "added"`)
+	assert.Contains(t, output, `"category": "name-id-format"`)
+	assert.Contains(t, output, `"subject": "urn:oasis:names:tc:SAML:2.0:nameid-format:persistent"`)
+}
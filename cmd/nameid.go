@@ -0,0 +1,271 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/gliwka/SAMLurai/internal/metadata"
+	"github.com/gliwka/SAMLurai/internal/output"
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/spf13/cobra"
+)
+
+var (
+	nameIDFile   string
+	nameIDFormat string
+
+	nameIDRelyingParty string
+	nameIDSource       string
+	nameIDSalt         string
+	nameIDAlgorithm    string
+	nameIDScope        string
+	nameIDExpect       string
+)
+
+var nameIDCmd = &cobra.Command{
+	Use:   "nameid",
+	Short: "Explain NameID values and formats, and compute persistent IDs",
+}
+
+var nameIDExplainCmd = &cobra.Command{
+	Use:   "explain [VALUE]",
+	Short: "Explain a NameID value and its format",
+	Long: `Explain what a NameID value is and how it was likely generated, and
+what its format URI means.
+
+Recognized values include Shibboleth's computed persistent IDs (Base64 of a
+SHA-1 or SHA-256 digest), computed pairwise-ids (Base32@scope), encrypted
+transient IDs, eduPersonTargetedID in its string form IdP!SP!value, UUIDs,
+hex digests and email addresses. Values that do not suit their format, such
+as an email address sent as persistent NameID, are flagged.
+
+Without VALUE, every NameID in a SAML message (XML or base64) is explained,
+including eduPersonTargetedID attribute values. With only --format, the
+format is described.
+
+Examples:
+  # A value and its format
+  samlurai nameid explain 6aP4RNZUCAd+FGrXHVuJ6/BfZXM= --format persistent
+
+  # Every NameID in a Response
+  samlurai nameid explain -f response.xml
+
+  # What a format URI means
+  samlurai nameid explain --format urn:oasis:names:tc:SAML:2.0:nameid-format:transient`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runNameIDExplain,
+}
+
+var nameIDComputeCmd = &cobra.Command{
+	Use:   "compute",
+	Short: "Compute the persistent ID or pairwise-id the Shibboleth IdP sends an SP",
+	Long: `Compute a persistent NameID the way the Shibboleth IdP's computed
+strategy does, to check the IdP's configuration or predict the identifier
+an SP will see:
+
+  SHA(SP entity ID + "!" + source attribute value + "!" + salt)
+
+The persistent ID is the Base64 digest. With --scope, the pairwise-id is
+computed too: the Base32 digest followed by @scope. The digest is SHA-1, as
+the IdP's default, or SHA-256 with --algorithm.
+
+With --expect, the value an IdP actually sent is compared with the computed
+ones, and the command fails if it matches neither.
+
+Examples:
+  samlurai nameid compute --sp https://sp.example.com --source jdoe --salt "$SALT"
+
+  # Verify the pairwise-id received by the SP
+  samlurai nameid compute --sp https://sp.example.com --source jdoe --salt "$SALT" \
+    --scope example.org --expect 5GR7QRGWKQEAO7QUNLLR2W4J5PYF6ZLT@example.org`,
+	RunE: runNameIDCompute,
+}
+
+func init() {
+	rootCmd.AddCommand(nameIDCmd)
+	nameIDCmd.AddCommand(nameIDExplainCmd)
+	nameIDCmd.AddCommand(nameIDComputeCmd)
+
+	flags := nameIDExplainCmd.Flags()
+	flags.StringVarP(&nameIDFile, "file", "f", "", "Explain the NameIDs of a SAML message in this file")
+	flags.StringVar(&nameIDFormat, "format", "", "NameID format: email, persistent, transient, unspecified, entity, or a URI")
+
+	flags = nameIDComputeCmd.Flags()
+	flags.StringVar(&nameIDRelyingParty, "sp", "", "Entity ID of the SP (required)")
+	flags.StringVar(&nameIDSource, "source", "", "Value of the source attribute, e.g. the uid (required)")
+	flags.StringVar(&nameIDSalt, "salt", "", "Salt configured at the IdP (required)")
+	flags.StringVar(&nameIDAlgorithm, "algorithm", "SHA-1", "Digest algorithm: SHA-1 or SHA-256")
+	flags.StringVar(&nameIDScope, "scope", "", "Scope of the pairwise-id; computes it too")
+	flags.StringVar(&nameIDExpect, "expect", "", "Value received from the IdP, to compare with the computed ones")
+}
+
+func runNameIDExplain(cmd *cobra.Command, args []string) error {
+	format := ""
+	if nameIDFormat != "" {
+		var err error
+		if format, err = metadata.ResolveNameIDFormat(nameIDFormat); err != nil {
+			return err
+		}
+	}
+
+	var analyses []saml.NameIDAnalysis
+	switch {
+	case len(args) == 1:
+		analyses = append(analyses, saml.AnalyzeNameID(args[0], format))
+
+	case nameIDFile == "" && format != "":
+		f, ok := saml.DescribeNameIDFormat(format)
+		if !ok {
+			return fmt.Errorf("%s is not a NameID format defined by SAML", format)
+		}
+		return printNameIDFormat(cmd, f)
+
+	default:
+		input, err := getInspectInput(cmd, nameIDFile)
+		if err != nil {
+			return err
+		}
+		xmlData, err := saml.NewDecoder().SmartDecode(input)
+		if err != nil {
+			return withExitCode(ExitParse, fmt.Errorf("failed to decode input: %w", err))
+		}
+		if analyses, err = saml.AnalyzeNameIDs(xmlData); err != nil {
+			return withExitCode(ExitParse, err)
+		}
+		if len(analyses) == 0 {
+			return fmt.Errorf("the message has no NameIDs; encrypted ones must be decrypted first")
+		}
+	}
+
+	out := cmd.OutOrStdout()
+	formatter := output.NewFormatter(outputFormat)
+	if formatter.IsJSON() {
+		formatted, err := formatter.FormatJSON(analyses)
+		if err != nil {
+			return fmt.Errorf("failed to format output: %w", err)
+		}
+		fmt.Fprint(out, formatted)
+		return nil
+	}
+	for i, a := range analyses {
+		if i > 0 {
+			fmt.Fprintln(out)
+		}
+		printNameIDAnalysis(out, a)
+	}
+	return nil
+}
+
+// printNameIDAnalysis prints what is known about a NameID value
+func printNameIDAnalysis(out io.Writer, a saml.NameIDAnalysis) {
+	if a.Location != "" {
+		fmt.Fprintf(out, "▸ %s\n", a.Location)
+	}
+	fmt.Fprintf(out, "Value:           %s\n", a.Value)
+	fmt.Fprintf(out, "Kind:            %s\n", a.Kind)
+	for _, detail := range a.Details {
+		fmt.Fprintf(out, "                 %s\n", detail)
+	}
+	if a.Format != nil {
+		name := a.Format.URI
+		if a.Format.Name != "" {
+			name = fmt.Sprintf("%s (%s)", a.Format.Name, a.Format.URI)
+		}
+		fmt.Fprintf(out, "Format:          %s\n", name)
+		fmt.Fprintf(out, "                 %s\n", a.Format.Description)
+	}
+	if a.NameQualifier != "" {
+		fmt.Fprintf(out, "NameQualifier:   %s\n", a.NameQualifier)
+	}
+	if a.SPNameQualifier != "" {
+		fmt.Fprintf(out, "SPNameQualifier: %s\n", a.SPNameQualifier)
+	}
+	for _, warning := range a.Warnings {
+		fmt.Fprintf(out, "⚠️  %s\n", warning)
+	}
+}
+
+// printNameIDFormat prints the description of a NameID format
+func printNameIDFormat(cmd *cobra.Command, f saml.NameIDFormat) error {
+	out := cmd.OutOrStdout()
+	formatter := output.NewFormatter(outputFormat)
+	if formatter.IsJSON() {
+		formatted, err := formatter.FormatJSON(f)
+		if err != nil {
+			return fmt.Errorf("failed to format output: %w", err)
+		}
+		fmt.Fprint(out, formatted)
+		return nil
+	}
+	fmt.Fprintf(out, "%s (%s)\n%s\n", f.Name, f.URI, f.Description)
+	return nil
+}
+
+// computedIDs is the JSON output of nameid compute
+type computedIDs struct {
+	PersistentID string `json:"persistent_id"`
+	PairwiseID   string `json:"pairwise_id,omitempty"`
+	Expected     string `json:"expected,omitempty"`
+	Matches      string `json:"matches,omitempty"`
+}
+
+func runNameIDCompute(cmd *cobra.Command, args []string) error {
+	opts := saml.ComputedIDOptions{
+		RelyingParty: nameIDRelyingParty,
+		SourceValue:  nameIDSource,
+		Salt:         nameIDSalt,
+		Algorithm:    nameIDAlgorithm,
+	}
+	if opts.RelyingParty == "" || opts.SourceValue == "" || opts.Salt == "" {
+		return fmt.Errorf("--sp, --source and --salt are required")
+	}
+
+	var result computedIDs
+	var err error
+	if result.PersistentID, err = saml.ComputePersistentID(opts); err != nil {
+		return err
+	}
+	if nameIDScope != "" {
+		if result.PairwiseID, err = saml.ComputePairwiseID(opts, nameIDScope); err != nil {
+			return err
+		}
+	}
+	if nameIDExpect != "" {
+		result.Expected = nameIDExpect
+		switch nameIDExpect {
+		case result.PersistentID:
+			result.Matches = "persistent_id"
+		case result.PairwiseID:
+			result.Matches = "pairwise_id"
+		}
+	}
+
+	out := cmd.OutOrStdout()
+	formatter := output.NewFormatter(outputFormat)
+	if formatter.IsJSON() {
+		formatted, err := formatter.FormatJSON(result)
+		if err != nil {
+			return fmt.Errorf("failed to format output: %w", err)
+		}
+		fmt.Fprint(out, formatted)
+	} else {
+		fmt.Fprintf(out, "Persistent ID: %s\n", result.PersistentID)
+		if result.PairwiseID != "" {
+			fmt.Fprintf(out, "Pairwise ID:   %s\n", result.PairwiseID)
+		}
+		switch {
+		case result.Expected == "":
+		case result.Matches == "persistent_id":
+			fmt.Fprintf(out, "✅ %s matches the persistent ID\n", result.Expected)
+		case result.Matches == "pairwise_id":
+			fmt.Fprintf(out, "✅ %s matches the pairwise-id\n", result.Expected)
+		default:
+			fmt.Fprintf(out, "❌ %s matches neither; check the source attribute, salt, algorithm and SP entity ID\n", result.Expected)
+		}
+	}
+
+	if result.Expected != "" && result.Matches == "" {
+		return withExitCode(ExitFindings, fmt.Errorf("%s does not match the computed identifiers", result.Expected))
+	}
+	return nil
+}
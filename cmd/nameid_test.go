@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func resetNameIDFlags() {
+	nameIDFile = ""
+	nameIDFormat = ""
+	nameIDRelyingParty = ""
+	nameIDSource = ""
+	nameIDSalt = ""
+	nameIDAlgorithm = "SHA-1"
+	nameIDScope = ""
+	nameIDExpect = ""
+	outputFormat = "pretty"
+}
+
+func TestNameIDExplainCmd_Value(t *testing.T) {
+	defer resetNameIDFlags()
+
+	output, err := executeCommand(rootCmd, "nameid", "explain", "6aP4RNZUCAd+FGrXHVuJ6/BfZXM=", "--format", "persistent")
+	require.NoError(t, err)
+	assert.Contains(t, output, "Kind:            computed persistent ID\n")
+	assert.Contains(t, output, "Format:          persistent (urn:oasis:names:tc:SAML:2.0:nameid-format:persistent)\n")
+	assert.NotContains(t, output, "⚠️")
+}
+
+func TestNameIDExplainCmd_Message(t *testing.T) {
+	defer resetNameIDFlags()
+
+	output, err := executeCommand(rootCmd, "nameid", "explain", "-f", "../testdata/fixtures/assertions/response.xml")
+	require.NoError(t, err)
+	assert.Contains(t, output, "▸ Subject\n")
+	assert.Contains(t, output, "Value:")
+}
+
+func TestNameIDExplainCmd_Format(t *testing.T) {
+	defer resetNameIDFlags()
+
+	output, err := executeCommand(rootCmd, "nameid", "explain", "--format", "transient")
+	require.NoError(t, err)
+	assert.Contains(t, output, "transient (urn:oasis:names:tc:SAML:2.0:nameid-format:transient)\n")
+	assert.Contains(t, output, "valid for this session only")
+}
+
+func TestNameIDComputeCmd(t *testing.T) {
+	defer resetNameIDFlags()
+
+	output, err := executeCommand(rootCmd, "nameid", "compute", "--sp", "https://sp.example.com", "--source", "jdoe",
+		"--salt", "s3cr3tsalt", "--scope", "example.org", "--expect", "5GR7QRGWKQEAO7QUNLLR2W4J5PYF6ZLT@example.org")
+	require.NoError(t, err)
+	assert.Contains(t, output, "Persistent ID: 6aP4RNZUCAd+FGrXHVuJ6/BfZXM=\n")
+	assert.Contains(t, output, "Pairwise ID:   5GR7QRGWKQEAO7QUNLLR2W4J5PYF6ZLT@example.org\n")
+	assert.Contains(t, output, "✅ 5GR7QRGWKQEAO7QUNLLR2W4J5PYF6ZLT@example.org matches the pairwise-id\n")
+}
+
+func TestNameIDComputeCmd_Mismatch(t *testing.T) {
+	defer resetNameIDFlags()
+
+	output, err := executeCommand(rootCmd, "nameid", "compute", "--sp", "https://sp.example.com", "--source", "jdoe",
+		"--salt", "wrong", "--expect", "6aP4RNZUCAd+FGrXHVuJ6/BfZXM=")
+	require.Error(t, err)
+	assert.Equal(t, ExitFindings, ExitCode(err))
+	assert.Contains(t, output, "❌ 6aP4RNZUCAd+FGrXHVuJ6/BfZXM= matches neither")
+}
+
+func TestNameIDComputeCmd_Required(t *testing.T) {
+	defer resetNameIDFlags()
+
+	_, err := executeCommand(rootCmd, "nameid", "compute", "--sp", "https://sp.example.com")
+	assert.ErrorContains(t, err, "--sp, --source and --salt are required")
+}
@@ -0,0 +1,235 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/gliwka/SAMLurai/internal/output"
+	"github.com/gliwka/SAMLurai/internal/plugin"
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/spf13/cobra"
+)
+
+// pluginDirEnv names the plugin directory, instead of the plugins
+// directory next to the config file
+const pluginDirEnv = "SAMLURAI_PLUGIN_DIR"
+
+var (
+	noPlugins bool
+
+	// plugins are the plugins discovered before the command runs
+	plugins []*plugin.Plugin
+)
+
+var pluginsCmd = &cobra.Command{
+	Use:   "plugins",
+	Short: "List the installed plugins",
+	Long: `List the plugins found in the plugin directory: ~/.config/samlurai/plugins
+(next to the config file), or the directory named by SAMLURAI_PLUGIN_DIR.
+
+Plugins add lint rules, audit checks, extraction sources and attribute
+names without forking samlurai. Each plugin is a subdirectory holding its
+command and files, described by a YAML manifest named plugin.yaml:
+
+  name: acme
+  command: ./acme-saml          # relative to the plugin directory, or on $PATH
+  args: [--samlurai]
+  capabilities: [lint, audit, extract]
+  extensions: [.acmelog]        # files extract hands to the plugin
+  attribute_map: names.yaml     # in the format of --attribute-map
+  timeout: 10s
+  rules:
+    - id: test-idp
+      severity: error           # error or warning
+      spec: ACME SSO policy 2.1
+      description: Production SPs only trust production IdPs
+
+For each request, the command is run with a JSON request on stdin and
+answers with a JSON response on stdout:
+  lint     {"version":1,"method":"lint","xml":"<samlp:Response ..."}
+           → {"issues":[{"rule":"test-idp","message":"..."}]}
+  audit    {"version":1,"method":"audit","xml":"<samlp:Response ..."}
+           → {"findings":[{"check":"...","severity":"high","message":"..."}]}
+  extract  {"version":1,"method":"extract","filename":"x.acmelog","data":"<base64>"}
+           → {"har":{"log":{"entries":[...]}}}
+A response with an "error" member, a non-zero exit status or output on
+stderr fails the request.
+
+Rule and check IDs are prefixed with the plugin name, e.g. acme/test-idp,
+so lint --disable acme/test-idp disables a plugin rule. --no-plugins runs a
+command without plugins.
+
+Examples:
+  # List the installed plugins
+  samlurai plugins
+
+  # Use plugins from another directory
+  SAMLURAI_PLUGIN_DIR=./plugins samlurai lint -f response.xml`,
+	Args: cobra.NoArgs,
+	RunE: runPlugins,
+}
+
+func init() {
+	rootCmd.AddCommand(pluginsCmd)
+	rootCmd.PersistentFlags().BoolVar(&noPlugins, "no-plugins", false, "Do not load plugins")
+}
+
+// pluginInfo is the JSON output of the plugins command
+type pluginInfo struct {
+	Name         string   `json:"name"`
+	Manifest     string   `json:"manifest"`
+	Command      string   `json:"command,omitempty"`
+	Capabilities []string `json:"capabilities"`
+	Extensions   []string `json:"extensions,omitempty"`
+	AttributeMap string   `json:"attribute_map,omitempty"`
+	Rules        []string `json:"rules,omitempty"`
+}
+
+func runPlugins(cmd *cobra.Command, args []string) error {
+	infos := []pluginInfo{}
+	for _, p := range plugins {
+		info := pluginInfo{
+			Name:         p.Name,
+			Manifest:     p.Path,
+			Command:      p.Command,
+			Capabilities: append([]string{}, p.Capabilities...),
+			Extensions:   p.Extensions,
+			AttributeMap: p.AttributeMap,
+		}
+		for _, rule := range p.LintRules() {
+			info.Rules = append(info.Rules, rule.ID)
+		}
+		infos = append(infos, info)
+	}
+
+	formatter := output.NewFormatter(outputFormat)
+	if formatter.IsJSON() {
+		formatted, err := formatter.FormatJSON(infos)
+		if err != nil {
+			return fmt.Errorf("failed to format output: %w", err)
+		}
+		fmt.Fprint(cmd.OutOrStdout(), formatted)
+		return nil
+	}
+
+	if len(infos) == 0 {
+		dir, _ := pluginDir()
+		fmt.Fprintf(cmd.OutOrStdout(), "No plugins installed in %s\n", dir)
+		return nil
+	}
+	printPlugins(cmd.OutOrStdout(), infos)
+	return nil
+}
+
+func printPlugins(w io.Writer, infos []pluginInfo) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tCAPABILITIES\tRULES\tMANIFEST")
+	for _, info := range infos {
+		capabilities := info.Capabilities
+		if info.AttributeMap != "" {
+			capabilities = append(capabilities, "attributes")
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\n", info.Name, strings.Join(capabilities, ", "), len(info.Rules), info.Manifest)
+	}
+	tw.Flush()
+}
+
+// pluginDir returns the plugin directory, from SAMLURAI_PLUGIN_DIR or next
+// to the config file
+func pluginDir() (string, error) {
+	if dir := os.Getenv(pluginDirEnv); dir != "" {
+		return dir, nil
+	}
+	path, err := configPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(path), "plugins"), nil
+}
+
+// loadPlugins discovers the plugins in the plugin directory, unless
+// --no-plugins is given
+func loadPlugins(cmd *cobra.Command) error {
+	plugins = nil
+	// Completion requests must not fail on a broken manifest
+	if noPlugins || cmd.Name() == cobra.ShellCompRequestCmd || cmd.Name() == cobra.ShellCompNoDescRequestCmd {
+		return nil
+	}
+	dir, err := pluginDir()
+	if err != nil {
+		// Without a home directory there is no default plugin directory
+		return nil
+	}
+	plugins, err = plugin.Discover(dir)
+	return err
+}
+
+// pluginsWith returns the plugins that declare a capability
+func pluginsWith(capability string) []*plugin.Plugin {
+	var matching []*plugin.Plugin
+	for _, p := range plugins {
+		if p.Has(capability) {
+			matching = append(matching, p)
+		}
+	}
+	return matching
+}
+
+// pluginLintRules returns the lint rules of all plugins
+func pluginLintRules() []saml.LintRule {
+	var rules []saml.LintRule
+	for _, p := range plugins {
+		rules = append(rules, p.LintRules()...)
+	}
+	return rules
+}
+
+// lintWithPlugins checks a message against the rules of the plugins,
+// skipping the disabled ones
+func lintWithPlugins(ctx context.Context, xmlData []byte, disabled []string) ([]saml.LintIssue, error) {
+	skip := map[string]bool{}
+	for _, rule := range disabled {
+		skip[rule] = true
+	}
+	var issues []saml.LintIssue
+	for _, p := range pluginsWith(plugin.CapabilityLint) {
+		found, err := p.Lint(ctx, xmlData)
+		if err != nil {
+			return nil, err
+		}
+		for _, issue := range found {
+			if !skip[issue.Rule] {
+				issues = append(issues, issue)
+			}
+		}
+	}
+	return issues, nil
+}
+
+// auditWithPlugins runs the audit checks of the plugins on a message
+func auditWithPlugins(ctx context.Context, xmlData []byte) ([]saml.AuditFinding, error) {
+	var findings []saml.AuditFinding
+	for _, p := range pluginsWith(plugin.CapabilityAudit) {
+		found, err := p.Audit(ctx, xmlData)
+		if err != nil {
+			return nil, err
+		}
+		findings = append(findings, found...)
+	}
+	return findings, nil
+}
+
+// extractPlugin returns the plugin that reads the file at path, if any
+func extractPlugin(path string) *plugin.Plugin {
+	for _, p := range pluginsWith(plugin.CapabilityExtract) {
+		if p.Reads(path) {
+			return p
+		}
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// installTestPlugin installs a plugin whose command answers lint, audit
+// and extract requests, and points SAMLURAI_PLUGIN_DIR at it
+func installTestPlugin(t *testing.T) string {
+	root := t.TempDir()
+	dir := filepath.Join(root, "acme")
+	require.NoError(t, os.Mkdir(dir, 0755))
+	response := `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_plugin"/>`
+	har := `{"log":{"entries":[{"request":{"method":"POST","url":"https://sp.example.com/acs",` +
+		`"postData":{"mimeType":"application/x-www-form-urlencoded","params":[{"name":"SAMLResponse","value":"` +
+		base64.StdEncoding.EncodeToString([]byte(response)) + `"}]}},"response":{"status":200,"content":{"mimeType":"text/html","text":""}}}]}}`
+
+	script := `#!/bin/sh
+input=$(cat)
+case "$input" in
+*'"method":"lint"'*) echo '{"issues":[{"rule":"test-idp","message":"the Issuer is a test IdP"}]}' ;;
+*'"method":"audit"'*) echo '{"findings":[{"check":"shared-key","severity":"high","message":"signed with a shared key"}]}' ;;
+*'"method":"extract"'*) echo '{"har":` + har + `}' ;;
+esac
+`
+	manifest := `name: acme
+command: ./acme-saml
+capabilities: [lint, audit, extract]
+extensions: [.acmelog]
+attribute_map: names.yaml
+rules:
+  - id: test-idp
+    severity: warning
+    description: Production SPs only trust production IdPs
+`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "acme-saml"), []byte(script), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "plugin.yaml"), []byte(manifest), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "names.yaml"), []byte("urn:oid:1.3.6.1.4.1.99999.1: acmeBadge\n"), 0644))
+	t.Setenv(pluginDirEnv, root)
+	return dir
+}
+
+func TestPluginsCmd(t *testing.T) {
+	noPlugins = false
+	outputFormat = "pretty"
+	dir := installTestPlugin(t)
+
+	output, err := executeCommand(rootCmd, "plugins")
+	require.NoError(t, err)
+	assert.Contains(t, output, "acme")
+	assert.Contains(t, output, "lint, audit, extract, attributes")
+	assert.Contains(t, output, filepath.Join(dir, "plugin.yaml"))
+
+	output, err = executeCommand(rootCmd, "plugins", "-o", "json")
+	require.NoError(t, err)
+	var infos []pluginInfo
+	require.NoError(t, json.NewDecoder(strings.NewReader(output)).Decode(&infos))
+	require.Len(t, infos, 1)
+	assert.Equal(t, []string{"acme/test-idp"}, infos[0].Rules)
+
+	outputFormat = "pretty"
+	output, err = executeCommand(rootCmd, "plugins", "--no-plugins")
+	require.NoError(t, err)
+	assert.Contains(t, output, "No plugins installed")
+	noPlugins = false
+}
+
+func TestPluginsCmd_InvalidManifest(t *testing.T) {
+	noPlugins = false
+	outputFormat = "pretty"
+	root := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(root, "broken"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "broken", "plugin.yaml"), []byte("name: Broken\n"), 0644))
+	t.Setenv(pluginDirEnv, root)
+
+	_, err := executeCommand(rootCmd, "plugins")
+	assert.ErrorContains(t, err, `invalid name "Broken"`)
+}
+
+func TestLintCmd_Plugin(t *testing.T) {
+	resetLintFlags()
+	noPlugins = false
+	installTestPlugin(t)
+
+	output, err := executeCommand(rootCmd, "lint", "-f", "../testdata/fixtures/assertions/response.xml")
+	require.Error(t, err)
+	assert.Contains(t, output, "[WARNING] acme/test-idp: the Issuer is a test IdP\n")
+	assert.Contains(t, output, "Summary: 3 error(s), 3 warning(s)")
+
+	resetLintFlags()
+	output, err = executeCommand(rootCmd, "lint", "--list-rules")
+	require.NoError(t, err)
+	assert.Contains(t, output, "acme/test-idp")
+
+	resetLintFlags()
+	output, err = executeCommand(rootCmd, "lint", "-f", "../testdata/fixtures/assertions/response.xml", "--disable", "acme/test-idp")
+	require.Error(t, err)
+	assert.NotContains(t, output, "acme/test-idp")
+}
+
+func TestAuditCmd_Plugin(t *testing.T) {
+	resetAuditFlags()
+	noPlugins = false
+	installTestPlugin(t)
+
+	output, err := executeCommand(rootCmd, "audit", "-f", "../testdata/fixtures/assertions/response.xml")
+	require.Error(t, err)
+	assert.Contains(t, output, "[HIGH] acme/shared-key: signed with a shared key")
+	assert.Contains(t, output, "Summary: 2 high, 0 medium, 0 low")
+}
+
+func TestExtractCmd_Plugin(t *testing.T) {
+	noPlugins = false
+	outputFormat = "pretty"
+	extractFile = ""
+	extractList = false
+	extractLogFormat = ""
+	installTestPlugin(t)
+
+	capture := filepath.Join(t.TempDir(), "capture.acmelog")
+	require.NoError(t, os.WriteFile(capture, []byte("proprietary capture"), 0644))
+
+	output, err := executeCommand(rootCmd, "extract", "-f", capture, "--list")
+	require.NoError(t, err)
+	assert.Contains(t, output, "Found 1 SAML assertion")
+	assert.Contains(t, output, "https://sp.example.com/acs")
+	extractList = false
+}
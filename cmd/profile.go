@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/gliwka/SAMLurai/internal/config"
+	"github.com/gliwka/SAMLurai/internal/log"
+	"github.com/spf13/cobra"
+)
+
+// Environment variables selecting the profile and the config file
+const (
+	profileEnv = "SAMLURAI_PROFILE"
+	configEnv  = "SAMLURAI_CONFIG"
+)
+
+// profileOptOutAnnotation lists the profile flags a command ignores, e.g.
+// because its --key is a signing rather than a decryption key
+const profileOptOutAnnotation = "samlurai_profile_opt_out"
+
+// keyFlags are the flags giving a key some other way than --key, which a
+// profile's key must not conflict with
+var keyFlags = []string{"key-env", "key-map", "pkcs11-module", "kms-key"}
+
+// loadProfile applies the selected profile of the config file to the flags
+// of cmd that were not given on the command line
+func loadProfile(cmd *cobra.Command, args []string) error {
+	// Completion requests must not fail on a broken config file
+	if cmd.Name() == cobra.ShellCompRequestCmd || cmd.Name() == cobra.ShellCompNoDescRequestCmd {
+		return nil
+	}
+
+	name := profileName
+	if name == "" {
+		name = os.Getenv(profileEnv)
+	}
+
+	path, err := configPath()
+	if err != nil {
+		if name == "" {
+			return nil
+		}
+		return err
+	}
+
+	cfg, err := config.Load(path)
+	if err != nil {
+		if config.IsNotExist(err) && name == "" {
+			return nil
+		}
+		return err
+	}
+	profile, err := cfg.Profile(name)
+	if err != nil || profile == nil {
+		return err
+	}
+	if name == "" {
+		name = cfg.DefaultProfile
+	}
+	log.Debug("applying config file profile", "path", path, "profile", name)
+	return applyProfile(cmd, profile)
+}
+
+// configPath returns the path of the config file, from SAMLURAI_CONFIG or
+// the default location
+func configPath() (string, error) {
+	if path := os.Getenv(configEnv); path != "" {
+		return path, nil
+	}
+	return config.DefaultPath()
+}
+
+// applyProfile sets the flags of cmd the profile holds values for, unless
+// they were given on the command line
+func applyProfile(cmd *cobra.Command, profile *config.Profile) error {
+	optOut := map[string]bool{}
+	if names := cmd.Annotations[profileOptOutAnnotation]; names != "" {
+		for _, name := range strings.Split(names, ",") {
+			optOut[name] = true
+		}
+	}
+	for _, name := range keyFlags {
+		if f := cmd.Flags().Lookup(name); f != nil && f.Changed {
+			optOut["key"] = true
+		}
+	}
+
+	values := profile.Flags()
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		f := cmd.Flags().Lookup(name)
+		if f == nil || f.Changed || optOut[name] {
+			continue
+		}
+		if err := f.Value.Set(values[name]); err != nil {
+			return fmt.Errorf("invalid %s %q in profile: %w", name, values[name], err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gliwka/SAMLurai/internal/config"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyProfile(t *testing.T) {
+	var key, keyEnv, audience string
+	var skew time.Duration
+	newCmd := func() *cobra.Command {
+		key, keyEnv, audience, skew = "", "", "", 0
+		cmd := &cobra.Command{Use: "test"}
+		cmd.Flags().StringVarP(&key, "key", "k", "", "")
+		cmd.Flags().StringVar(&keyEnv, "key-env", "", "")
+		cmd.Flags().StringVar(&audience, "audience", "", "")
+		cmd.Flags().DurationVar(&skew, "clock-skew", 0, "")
+		return cmd
+	}
+	profile := &config.Profile{Key: "/keys/sp.pem", Metadata: "idp.xml", SPEntityID: "https://sp.example.com", ClockSkew: "2m"}
+
+	cmd := newCmd()
+	require.NoError(t, applyProfile(cmd, profile))
+	assert.Equal(t, "/keys/sp.pem", key)
+	assert.Equal(t, "https://sp.example.com", audience)
+	assert.Equal(t, 2*time.Minute, skew)
+
+	// Flags on the command line take precedence, and another key source
+	// replaces the profile's key
+	cmd = newCmd()
+	require.NoError(t, cmd.ParseFlags([]string{"--audience", "https://other.example.com", "--key-env", "SP_KEY"}))
+	require.NoError(t, applyProfile(cmd, profile))
+	assert.Equal(t, "https://other.example.com", audience)
+	assert.Empty(t, key)
+	assert.Equal(t, 2*time.Minute, skew)
+
+	cmd = newCmd()
+	cmd.Annotations = map[string]string{profileOptOutAnnotation: "key,clock-skew"}
+	require.NoError(t, applyProfile(cmd, profile))
+	assert.Empty(t, key)
+	assert.Zero(t, skew)
+}
+
+func TestProfileFlag(t *testing.T) {
+	resetInspectFlags()
+	defer resetInspectFlags()
+	defer func() { profileName = "" }()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("profiles:\n  staging:\n    sp_entity_id: https://sp.example.com\n"), 0o600))
+	t.Setenv(configEnv, path)
+	responsePath := filepath.Join("..", "testdata", "fixtures", "assertions", "response.xml")
+
+	_, err := executeCommand(rootCmd, "inspect", "-f", responsePath, "--profile", "prod")
+	assert.EqualError(t, err, `unknown profile "prod"; defined profiles: staging`)
+
+	_, err = executeCommand(rootCmd, "inspect", "-f", responsePath, "--profile", "staging")
+	require.NoError(t, err)
+	assert.Equal(t, "https://sp.example.com", inspectAud)
+
+	// Without a config file, only an explicitly selected profile is an error
+	profileName = ""
+	t.Setenv(configEnv, filepath.Join(t.TempDir(), "missing.yaml"))
+	t.Setenv(profileEnv, "")
+	_, err = executeCommand(rootCmd, "completion", "bash")
+	assert.NoError(t, err)
+	t.Setenv(profileEnv, "staging")
+	_, err = executeCommand(rootCmd, "inspect", "-f", responsePath)
+	assert.ErrorContains(t, err, "failed to read config file")
+}
+
+func TestProfileFlag_URLNormalization(t *testing.T) {
+	resetInspectFlags()
+	defer resetInspectFlags()
+	defer func() { profileName = "" }()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("profiles:\n  strict:\n    url_normalization: none\n"), 0o600))
+	t.Setenv(configEnv, path)
+	responsePath := filepath.Join("..", "testdata", "fixtures", "assertions", "response.xml")
+
+	// The profile's rules apply to the comparison
+	output, err := executeCommand(rootCmd, "inspect", "-f", responsePath, "-o", "jsonl", "--profile", "strict", "--destination", "https://sp.example.com/acs/")
+	require.NoError(t, err)
+	assert.Contains(t, output, "destination https://sp.example.com/acs does not match https://sp.example.com/acs/")
+
+	// --url-normalization on the command line takes precedence
+	resetInspectFlags()
+	output, err = executeCommand(rootCmd, "inspect", "-f", responsePath, "-o", "jsonl", "--profile", "strict", "--destination", "https://sp.example.com/acs/", "--url-normalization", "trim_trailing_slash")
+	require.NoError(t, err)
+	assert.NotContains(t, output, "does not match")
+}
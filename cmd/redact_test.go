@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gliwka/SAMLurai/internal/redact"
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func resetRedactFlags() {
+	redactFile = ""
+	redactKeep = nil
+	outputFormat = "pretty"
+}
+
+func TestRedactCmd(t *testing.T) {
+	resetRedactFlags()
+	defer resetRedactFlags()
+
+	responsePath := filepath.Join("..", "testdata", "fixtures", "assertions", "response.xml")
+	output, err := executeCommand(rootCmd, "redact", "-f", responsePath, "--keep-attribute", "groups")
+	require.NoError(t, err)
+
+	assert.NotContains(t, output, "user@example.com")
+	assert.NotContains(t, output, "_session123")
+	assert.Contains(t, output, "REDACTED")
+	assert.Contains(t, output, "https://idp.example.com")
+	assert.Contains(t, output, "admins")
+}
+
+func TestRedactCmd_HAR(t *testing.T) {
+	resetRedactFlags()
+	defer resetRedactFlags()
+
+	_, err := executeCommand(rootCmd, "redact", "-f", writeFlowHAR(t))
+	assert.EqualError(t, err, "redact reads a single SAML message; use inspect --redact or extract --redact for HAR files")
+}
+
+func TestRedactCmd_DTD(t *testing.T) {
+	resetRedactFlags()
+	defer resetRedactFlags()
+	file := createTempFile(t, `<!DOCTYPE r [<!ENTITY xxe SYSTEM "file:///etc/passwd">]><samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol"><Issuer>&xxe;</Issuer></samlp:Response>`)
+	defer os.Remove(file)
+
+	_, err := executeCommand(rootCmd, "redact", "-f", file)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, saml.ErrDTD)
+}
+
+func TestInspectCmd_Redact(t *testing.T) {
+	resetInspectFlags()
+	defer resetInspectFlags()
+
+	output, err := executeCommand(rootCmd, "inspect", "-f", writeFlowHAR(t), "--redact", "-o", "jsonl")
+	require.NoError(t, err)
+	assert.NotContains(t, output, "user@example.com")
+	assert.Contains(t, output, `"name_id":"REDACTED"`)
+}
+
+func resetAnonymizeFlags() {
+	anonymizeFile = ""
+	anonymizeSecret = ""
+	anonymizeKeep = nil
+	outputFormat = "pretty"
+}
+
+func TestAnonymizeCmd(t *testing.T) {
+	resetAnonymizeFlags()
+	defer resetAnonymizeFlags()
+
+	responsePath := filepath.Join("..", "testdata", "fixtures", "assertions", "response.xml")
+	output, err := executeCommand(rootCmd, "anonymize", "-f", responsePath, "--secret", "s3cret", "-o", "xml")
+	require.NoError(t, err)
+
+	pseudonym := redact.Pseudonym([]byte("s3cret"), "user@example.com")
+	assert.NotContains(t, output, "user@example.com")
+	assert.Equal(t, 2, strings.Count(output, pseudonym), "NameID and email attribute share a pseudonym")
+	assert.Contains(t, output, "https://idp.example.com")
+}
+
+func TestInspectCmd_Anonymize(t *testing.T) {
+	resetInspectFlags()
+	defer resetInspectFlags()
+
+	output, err := executeCommand(rootCmd, "inspect", "-f", writeFlowHAR(t), "--anonymize", "--anonymize-secret", "s3cret", "-o", "jsonl")
+	require.NoError(t, err)
+	assert.Contains(t, output, `"name_id":"`+redact.Pseudonym([]byte("s3cret"), "user@example.com")+`"`)
+
+	resetInspectFlags()
+	_, err = executeCommand(rootCmd, "inspect", "-f", writeFlowHAR(t), "--anonymize", "--redact")
+	assert.EqualError(t, err, "only one of --redact and --anonymize may be given")
+}
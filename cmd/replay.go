@@ -0,0 +1,305 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gliwka/SAMLurai/internal/inspect"
+	"github.com/gliwka/SAMLurai/internal/output"
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/spf13/cobra"
+)
+
+var (
+	replayFile         string
+	replayIndex        int
+	replayACS          string
+	replayRelayState   string
+	replayIssueInstant string
+	replayNotOnOrAfter string
+	replayNewIDs       bool
+	replayHeaders      []string
+	replayTimeout      time.Duration
+	replayDryRun       bool
+)
+
+// maxReplayBodySize bounds how much of the SP's answer is read
+const maxReplayBodySize = 1 << 20
+
+var replayCmd = &cobra.Command{
+	Use:   "replay",
+	Short: "Resubmit a captured Response to an SP's ACS",
+	Long: `POST a captured SAML Response to an AssertionConsumerService again, to
+test how the SP handles replayed, expired or re-dated Responses. The SP's
+HTTP status, redirect location and page are printed.
+
+The input is a Response as XML or base64, or a HAR file. From a HAR file,
+the last Response posted by the browser is replayed, or the message at
+--index as numbered by inspect. The ACS URL is the URL the Response was
+posted to in the HAR file, or the Destination of the Response; --acs
+overrides both.
+
+Before sending, fields can be rewritten:
+  --issue-instant     IssueInstant of the Response and its assertions
+  --not-on-or-after   NotOnOrAfter of Conditions and SubjectConfirmationData
+  --new-ids           fresh IDs for the Response and its assertions
+
+Times are RFC 3339, "now", or a duration from now such as 10m or -1h.
+Rewriting invalidates the signatures over the changed elements, so a
+correct SP rejects the result; encrypted assertions are sent unchanged.
+Redirects are not followed.
+
+Examples:
+  # Replay the Response of a HAR capture unchanged
+  samlurai replay -f login.har
+
+  # Replay the third message against a staging ACS
+  samlurai replay -f login.har --index 3 --acs https://sp.staging.example.com/acs
+
+  # Check the SP rejects an expired Response, sending its session cookie
+  samlurai replay -f response.xml --not-on-or-after -1h --header 'Cookie: session=abc'
+
+  # Show what would be sent without sending it
+  samlurai replay -f response.xml --issue-instant now --new-ids --dry-run -o json`,
+	RunE: runReplay,
+}
+
+func init() {
+	rootCmd.AddCommand(replayCmd)
+
+	flags := replayCmd.Flags()
+	flags.StringVarP(&replayFile, "file", "f", "", "Read the Response from file (XML, base64 or HAR)")
+	flags.IntVar(&replayIndex, "index", 0, "Replay the HAR message at this position, as numbered by inspect (default: the last Response)")
+	flags.StringVar(&replayACS, "acs", "", "ACS URL to post to (default: from the HAR file or the Destination)")
+	flags.StringVar(&replayRelayState, "relay-state", "", "RelayState to send with the Response")
+	flags.StringVar(&replayIssueInstant, "issue-instant", "", "Rewrite IssueInstant: RFC 3339 time, now, or a duration from now")
+	flags.StringVar(&replayNotOnOrAfter, "not-on-or-after", "", "Rewrite NotOnOrAfter: RFC 3339 time, now, or a duration from now")
+	flags.BoolVar(&replayNewIDs, "new-ids", false, "Give the Response and its assertions fresh IDs")
+	flags.StringArrayVar(&replayHeaders, "header", nil, "HTTP header to send, as 'Name: value' (repeatable)")
+	flags.DurationVar(&replayTimeout, "timeout", 30*time.Second, "How long to wait for the SP")
+	flags.BoolVar(&replayDryRun, "dry-run", false, "Print what would be sent without sending it")
+}
+
+// replayOutput is the JSON output of replay
+type replayOutput struct {
+	ACS        string   `json:"acs"`
+	Changes    []string `json:"changes,omitempty"`
+	XML        string   `json:"xml"`
+	Status     int      `json:"status,omitempty"`
+	StatusText string   `json:"status_text,omitempty"`
+	Location   string   `json:"location,omitempty"`
+	Body       string   `json:"body,omitempty"`
+}
+
+func runReplay(cmd *cobra.Command, args []string) error {
+	if replayIndex < 0 {
+		return fmt.Errorf("invalid --index %d: messages are numbered from 1", replayIndex)
+	}
+	now := time.Now()
+	var opts saml.RewriteOptions
+	var err error
+	if opts.IssueInstant, err = parseReplayTime("--issue-instant", replayIssueInstant, now); err != nil {
+		return err
+	}
+	if opts.NotOnOrAfter, err = parseReplayTime("--not-on-or-after", replayNotOnOrAfter, now); err != nil {
+		return err
+	}
+	opts.NewIDs = replayNewIDs
+	header, err := parseHeaderFlags(replayHeaders)
+	if err != nil {
+		return err
+	}
+
+	input, err := getInspectInput(cmd, replayFile)
+	if err != nil {
+		return err
+	}
+	xmlData, postedTo, err := selectResponse(replayFile, input, replayIndex)
+	if err != nil {
+		return err
+	}
+
+	result := replayOutput{ACS: replayACS}
+	if result.ACS == "" {
+		result.ACS = postedTo
+	}
+	if result.ACS == "" {
+		info, err := saml.NewParser().ParsePartial(xmlData)
+		if err == nil {
+			result.ACS = info.Destination
+		}
+	}
+	if result.ACS == "" {
+		return fmt.Errorf("no ACS URL: the Response has no Destination, use --acs")
+	}
+
+	if xmlData, result.Changes, err = saml.Rewrite(xmlData, opts); err != nil {
+		return err
+	}
+	result.XML = string(xmlData)
+	if len(result.Changes) > 0 && saml.VerifyMessage(xmlData, nil).Verdict == saml.VerdictSigFail {
+		notef(cmd, "⚠️  Rewriting broke the signature; a correct SP rejects this Response\n")
+	}
+
+	if !replayDryRun {
+		if err := postReplay(cmd, &result, xmlData, header); err != nil {
+			return err
+		}
+	}
+	return printReplay(cmd, result)
+}
+
+// selectResponse returns a Response from input: the input itself, or from
+// a HAR file the message at the 1-based index or else the last Response
+// posted by the browser, with the URL it was posted to
+func selectResponse(filename, input string, index int) ([]byte, string, error) {
+	if !inspect.IsHAR(filename, input) {
+		if index > 1 {
+			return nil, "", fmt.Errorf("message %d not found: the input holds a single SAML message", index)
+		}
+		xmlData, err := saml.NewDecoder().SmartDecode(input)
+		if err != nil {
+			return nil, "", withExitCode(ExitParse, fmt.Errorf("failed to decode input: %w", err))
+		}
+		if typ := saml.DetectType(xmlData); typ != "Response" {
+			return nil, "", fmt.Errorf("expected a Response, got %s", typ)
+		}
+		return xmlData, "", nil
+	}
+
+	results, err := saml.NewHARExtractor().Extract([]byte(input))
+	if err != nil {
+		return nil, "", withExitCode(ExitParse, fmt.Errorf("failed to parse HAR file: %w", err))
+	}
+	var selected *saml.ExtractedSAML
+	if index > 0 {
+		if index > len(results) {
+			return nil, "", fmt.Errorf("message %d not found: the HAR file has %d SAML message(s)", index, len(results))
+		}
+		selected = &results[index-1]
+		if selected.Type != "Response" {
+			return nil, "", fmt.Errorf("message %d is a %s, not a Response", index, selected.Type)
+		}
+	} else {
+		for i := range results {
+			if results[i].Type == "Response" && strings.HasPrefix(results[i].Source, "request-") {
+				selected = &results[i]
+			}
+		}
+		if selected == nil {
+			return nil, "", fmt.Errorf("no Response posted by the browser found in the HAR file")
+		}
+	}
+	postedTo := ""
+	if strings.HasPrefix(selected.Source, "request-") {
+		postedTo = selected.URL
+	}
+	return selected.DecodedXML, postedTo, nil
+}
+
+// parseReplayTime parses a time flag: an RFC 3339 time, "now", or a
+// duration relative to now
+func parseReplayTime(flag, value string, now time.Time) (time.Time, error) {
+	switch {
+	case value == "":
+		return time.Time{}, nil
+	case value == "now":
+		return now, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid %s %q: expected an RFC 3339 time, now, or a duration such as 10m or -1h", flag, value)
+}
+
+// parseHeaderFlags parses 'Name: value' headers
+func parseHeaderFlags(values []string) (http.Header, error) {
+	header := http.Header{}
+	for _, value := range values {
+		name, v, ok := strings.Cut(value, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid header %q, expected 'Name: value'", value)
+		}
+		header.Add(strings.TrimSpace(name), strings.TrimSpace(v))
+	}
+	return header, nil
+}
+
+// postReplay posts the Response to the ACS with the HTTP-POST binding and
+// records the SP's answer
+func postReplay(cmd *cobra.Command, result *replayOutput, xmlData []byte, header http.Header) error {
+	form := url.Values{"SAMLResponse": {base64.StdEncoding.EncodeToString(xmlData)}}
+	if replayRelayState != "" {
+		form.Set("RelayState", replayRelayState)
+	}
+	req, err := http.NewRequestWithContext(cmd.Context(), http.MethodPost, result.ACS, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("invalid ACS URL: %w", err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	client := &http.Client{
+		Timeout: replayTimeout,
+		// The SP's redirect is part of its answer
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post to %s: %w", result.ACS, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxReplayBodySize))
+	if err != nil {
+		return fmt.Errorf("failed to read the SP's response: %w", err)
+	}
+	result.Status = resp.StatusCode
+	result.StatusText = http.StatusText(resp.StatusCode)
+	result.Location = resp.Header.Get("Location")
+	result.Body = string(body)
+	return nil
+}
+
+// printReplay prints what was sent and how the SP answered
+func printReplay(cmd *cobra.Command, result replayOutput) error {
+	out := cmd.OutOrStdout()
+	formatter := output.NewFormatter(outputFormat)
+	if formatter.IsJSON() {
+		formatted, err := formatter.FormatJSON(result)
+		if err != nil {
+			return fmt.Errorf("failed to format output: %w", err)
+		}
+		fmt.Fprint(out, formatted)
+		return nil
+	}
+
+	for _, change := range result.Changes {
+		fmt.Fprintf(out, "Rewrote %s\n", change)
+	}
+	if result.Status == 0 {
+		fmt.Fprintf(out, "Would post to %s:\n\n%s\n", result.ACS, result.XML)
+		return nil
+	}
+	fmt.Fprintf(out, "POST %s\n", result.ACS)
+	fmt.Fprintf(out, "HTTP %d %s\n", result.Status, result.StatusText)
+	if result.Location != "" {
+		fmt.Fprintf(out, "Location: %s\n", result.Location)
+	}
+	if result.Body != "" {
+		fmt.Fprintf(out, "\n%s\n", strings.TrimRight(result.Body, "\n"))
+	}
+	return nil
+}
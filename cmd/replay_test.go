@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func resetReplayFlags() {
+	replayFile = ""
+	replayIndex = 0
+	replayACS = ""
+	replayRelayState = ""
+	replayIssueInstant = ""
+	replayNotOnOrAfter = ""
+	replayNewIDs = false
+	replayHeaders = nil
+	replayTimeout = 30 * time.Second
+	replayDryRun = false
+	outputFormat = "pretty"
+}
+
+// replayACSServer records the form posted to it and answers with a redirect
+func replayACSServer(t *testing.T) (*httptest.Server, *http.Request) {
+	t.Helper()
+	received := &http.Request{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		*received = *r
+		http.Redirect(w, r, "/error?reason=replay", http.StatusFound)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, received
+}
+
+func TestReplayCmd_HAR(t *testing.T) {
+	defer resetReplayFlags()
+	srv, received := replayACSServer(t)
+
+	output, err := executeCommand(rootCmd, "replay", "-f", writeFlowHAR(t), "--acs", srv.URL+"/acs",
+		"--relay-state", "/app", "--header", "Cookie: session=abc")
+	require.NoError(t, err)
+	assert.Contains(t, output, "POST "+srv.URL+"/acs\nHTTP 302 Found\nLocation: /error?reason=replay\n")
+
+	response, err := os.ReadFile(filepath.Join("..", "testdata", "fixtures", "assertions", "response.xml"))
+	require.NoError(t, err)
+	posted, err := base64.StdEncoding.DecodeString(received.PostForm.Get("SAMLResponse"))
+	require.NoError(t, err)
+	assert.Equal(t, string(response), string(posted))
+	assert.Equal(t, "/app", received.PostForm.Get("RelayState"))
+	assert.Equal(t, "session=abc", received.Header.Get("Cookie"))
+}
+
+func TestReplayCmd_HARIndexNotResponse(t *testing.T) {
+	defer resetReplayFlags()
+
+	_, err := executeCommand(rootCmd, "replay", "-f", writeFlowHAR(t), "--index", "1")
+	assert.ErrorContains(t, err, "message 1 is a AuthnRequest, not a Response")
+}
+
+func TestReplayCmd_RewriteDryRun(t *testing.T) {
+	defer resetReplayFlags()
+
+	path := filepath.Join("..", "testdata", "fixtures", "assertions", "response.xml")
+	output, err := executeCommand(rootCmd, "replay", "-f", path, "--acs", "https://sp.example.com/acs",
+		"--issue-instant", "2030-01-01T00:00:00Z", "--not-on-or-after", "2030-01-01T00:05:00Z", "--new-ids", "--dry-run", "-o", "json")
+	require.NoError(t, err)
+
+	var result replayOutput
+	require.NoError(t, json.Unmarshal([]byte(output), &result))
+	assert.Equal(t, "https://sp.example.com/acs", result.ACS)
+	assert.Zero(t, result.Status)
+	assert.NotEmpty(t, result.Changes)
+
+	info, err := saml.NewParser().Parse([]byte(result.XML))
+	require.NoError(t, err)
+	require.NotNil(t, info.IssueInstant)
+	assert.Equal(t, "2030-01-01T00:00:00Z", info.IssueInstant.Format(time.RFC3339))
+}
+
+func TestParseReplayTime(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value string
+		want  time.Time
+	}{
+		{"", time.Time{}},
+		{"now", now},
+		{"-1h", now.Add(-time.Hour)},
+		{"2030-01-01T00:00:00Z", time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, err := parseReplayTime("--not-on-or-after", tt.value, now)
+		require.NoError(t, err, tt.value)
+		assert.True(t, tt.want.Equal(got), tt.value)
+	}
+
+	_, err := parseReplayTime("--not-on-or-after", "tomorrow", now)
+	assert.ErrorContains(t, err, `invalid --not-on-or-after "tomorrow"`)
+}
+
+func TestParseHeaderFlags(t *testing.T) {
+	header, err := parseHeaderFlags([]string{"Cookie: a=b", "X-Test:1"})
+	require.NoError(t, err)
+	assert.Equal(t, "a=b", header.Get("Cookie"))
+	assert.Equal(t, "1", header.Get("X-Test"))
+
+	_, err = parseHeaderFlags([]string{"no colon"})
+	assert.ErrorContains(t, err, `invalid header "no colon"`)
+}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/gliwka/SAMLurai/internal/inspect"
+	"github.com/gliwka/SAMLurai/internal/output"
+	"github.com/spf13/cobra"
+)
+
+// reportEntries converts inspected messages into report entries.
+// Problems are recorded on the entry so the rest of the report still renders.
+func reportEntries(messages []inspect.Message) []output.ReportEntry {
+	entries := make([]output.ReportEntry, 0, len(messages))
+	for _, msg := range messages {
+		entry := output.ReportEntry{
+			Index:    msg.Index(),
+			Type:     msg.Type(),
+			Info:     msg.Info,
+			Warnings: msg.Warnings(),
+		}
+		if msg.Extracted != nil {
+			entry.Source = msg.Extracted.Source
+			entry.URL = msg.Extracted.URL
+			entry.ParameterName = msg.Extracted.ParameterName
+			entry.StartedAt = msg.Extracted.StartedAt
+		}
+		if errors.Is(msg.Err, inspect.ErrNoKey) {
+			entry.Error = missingKeyMessage(msg.Err)
+		} else if msg.Err != nil {
+			entry.Error = msg.Err.Error()
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// writeReport renders the entries into a self-contained HTML file
+func writeReport(cmd *cobra.Command, path, input string, entries []output.ReportEntry) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create report: %w", err)
+	}
+	defer f.Close()
+
+	report := output.Report{
+		Input:       input,
+		GeneratedAt: time.Now(),
+		Entries:     entries,
+	}
+	if err := output.WriteHTMLReport(f, report); err != nil {
+		return err
+	}
+
+	notef(cmd, "Report written to %s\n", path)
+	return nil
+}
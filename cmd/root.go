@@ -1,9 +1,17 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
+	"time"
 
+	"github.com/gliwka/SAMLurai/internal/log"
+	"github.com/gliwka/SAMLurai/internal/output"
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/mattn/go-isatty"
 	"github.com/spf13/cobra"
 )
 
@@ -12,7 +20,17 @@ var (
 	version = "dev"
 
 	// Global flags
-	outputFormat string
+	outputFormat       string
+	inputFromClipboard bool
+	profileName        string
+	verbose            bool
+	quiet              bool
+	noResolve          bool
+	attributeMapFile   string
+	timeout            time.Duration
+
+	// stopTimeout releases the --timeout context of the command
+	stopTimeout context.CancelFunc = func() {}
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -34,21 +52,152 @@ Examples:
   samlurai decrypt -k private.pem -f encrypted.xml
 
   # Inspect SAML assertion details
-  samlurai inspect -f assertion.xml`,
-	Version: version,
+  samlurai inspect -f assertion.xml
+
+  # Decode a SAMLResponse copied from the browser's dev tools
+  samlurai decode --clipboard
+
+  # Load into PowerShell objects
+  samlurai inspect -f session.har -o psobject | ConvertFrom-Json
+
+  # Name attributes of an in-house schema in pretty output
+  samlurai inspect -f response.xml --attribute-map attributes.yaml
+
+  # Use the key, audience and clock skew of a profile in the config file
+  samlurai inspect -f response.xml --profile staging
+
+  # Give up on a capture that takes longer than 30 seconds to process
+  samlurai inspect -f huge.har --timeout 30s
+
+Flag defaults for each environment can be kept as named profiles in
+~/.config/samlurai/config.yaml (or the file named by SAMLURAI_CONFIG).
+Flags given on the command line take precedence over the profile.
+
+Exit codes:
+  0  success
+  1  usage error, or a failure without a more specific code
+  2  the input could not be decoded or parsed
+  3  a signature did not verify
+  4  a message had expired when it was sent (audit)
+  5  the private key was missing or unusable, or decryption failed
+  6  audit found other issues, check-flow found the flow diverged, flow
+     diff found the captures diverge, check acs found an endpoint problem,
+     check tls found TLS and SAML certificates confused, lint found
+     conformance issues, or a validate check failed`,
+	Version:           version,
+	PersistentPreRunE: setUp,
+
+	// Most errors are findings or bad input, not misuse; the full usage
+	// would bury them and the -o json output
+	SilenceUsage: true,
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 func Execute() error {
+	registerCompletions()
+	defer func() { stopTimeout() }()
 	return rootCmd.Execute()
 }
 
 func init() {
-	rootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", "pretty", "Output format: pretty, json, xml")
+	rootCmd.SetFlagErrorFunc(flagError)
+	rootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", "pretty", "Output format: pretty, json, psobject, jsonl, xml, csv, tsv, otlp-trace (HAR only), dot (graph only), junit, sarif (validate only)")
+	rootCmd.PersistentFlags().BoolVar(&inputFromClipboard, "clipboard", false, "Read input from the system clipboard instead of stdin")
+	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Log decoding decisions, such as the base64 variant that matched and whether deflate was applied, to stderr")
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "Print only errors to stderr, without warnings and notices")
+	rootCmd.PersistentFlags().StringVar(&profileName, "profile", "", "Config file profile to take flag defaults from (default: $SAMLURAI_PROFILE or default_profile)")
+	rootCmd.PersistentFlags().BoolVar(&noResolve, "no-resolve", false, "Show attribute OIDs and claim URIs as sent, without resolving them to friendly names")
+	rootCmd.PersistentFlags().StringVar(&attributeMapFile, "attribute-map", "", "YAML file mapping further attribute names to friendly names, e.g. urn:oid:1.2.3: badgeNumber")
+	_ = rootCmd.MarkPersistentFlagFilename("attribute-map", "yaml", "yml")
+	rootCmd.PersistentFlags().DurationVar(&timeout, "timeout", 0, "Give up on the command after this long, e.g. 30s, so huge or adversarial input cannot hang it (default: no limit)")
 	rootCmd.SetOut(os.Stdout)
 	rootCmd.SetErr(os.Stderr)
 }
 
+// setUp configures logging, loads plugins and applies the config file
+// profile before a command runs
+func setUp(cmd *cobra.Command, args []string) error {
+	level := slog.LevelWarn
+	switch {
+	case verbose && quiet:
+		return fmt.Errorf("only one of --quiet and --verbose may be given")
+	case verbose:
+		level = slog.LevelDebug
+	case quiet:
+		level = slog.LevelError
+	}
+	log.Setup(cmd.ErrOrStderr(), level)
+
+	if err := setUpTimeout(cmd); err != nil {
+		return err
+	}
+	if err := loadPlugins(cmd); err != nil {
+		return err
+	}
+	if err := setUpAttributeNames(); err != nil {
+		return err
+	}
+	return loadProfile(cmd, args)
+}
+
+// flagError points to the help of the command for invalid flags, as usage
+// is not printed with errors
+func flagError(cmd *cobra.Command, err error) error {
+	return fmt.Errorf("%w\nRun '%s --help' for usage", err, cmd.CommandPath())
+}
+
+// setUpTimeout gives the command a context that ends after --timeout. The
+// context of the root command is used otherwise, so a deadline does not
+// outlive the run that set it.
+func setUpTimeout(cmd *cobra.Command) error {
+	if timeout < 0 {
+		return fmt.Errorf("invalid --timeout %s: must not be negative", timeout)
+	}
+	ctx := cmd.Root().Context()
+	if timeout > 0 {
+		stopTimeout()
+		ctx, stopTimeout = context.WithTimeoutCause(ctx, timeout, fmt.Errorf("timed out after %s (--timeout): %w", timeout, context.DeadlineExceeded))
+	}
+	cmd.SetContext(ctx)
+	return nil
+}
+
+// setUpAttributeNames configures how pretty output resolves attribute names
+// without a FriendlyName: by the built-in dictionary, the attribute maps of
+// plugins and --attribute-map, or not at all with --no-resolve
+func setUpAttributeNames() error {
+	if noResolve {
+		if attributeMapFile != "" {
+			return fmt.Errorf("only one of --no-resolve and --attribute-map may be given")
+		}
+		output.SetAttributeDictionary(nil)
+		return nil
+	}
+	names := saml.NewAttributeDictionary()
+	for _, p := range plugins {
+		if p.AttributeMap != "" {
+			if err := names.LoadFile(p.AttributeMap); err != nil {
+				return fmt.Errorf("plugin %s: %w", p.Name, err)
+			}
+		}
+	}
+	if attributeMapFile != "" {
+		if err := names.LoadFile(attributeMapFile); err != nil {
+			return err
+		}
+	}
+	output.SetAttributeDictionary(names)
+	return nil
+}
+
+// notef prints a warning or notice to stderr, unless --quiet is given
+func notef(cmd *cobra.Command, format string, args ...interface{}) {
+	if quiet {
+		return
+	}
+	fmt.Fprintf(cmd.ErrOrStderr(), format, args...)
+}
+
 // SetVersion sets the version for the root command (used in tests)
 func SetVersion(v string) {
 	version = v
@@ -60,6 +209,19 @@ func GetRootCmd() *cobra.Command {
 	return rootCmd
 }
 
+// colorEnabled reports whether colored output should be written to w: it
+// must be a terminal and NO_COLOR must not be set
+func colorEnabled(w io.Writer) bool {
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return false
+	}
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	return isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
+}
+
 // OutputWriter returns the writer for command output
 func OutputWriter() *os.File {
 	return os.Stdout
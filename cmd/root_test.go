@@ -1,8 +1,11 @@
 package cmd
 
 import (
+	"context"
+	"path/filepath"
 	"testing"
 
+	"github.com/gliwka/SAMLurai/internal/saml"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -59,3 +62,97 @@ func TestRootCmd_Examples(t *testing.T) {
 	// Should contain example usage
 	assert.Contains(t, output, "Examples:")
 }
+
+func TestRootCmd_Verbose(t *testing.T) {
+	resetInspectFlags()
+	defer resetInspectFlags()
+	defer func() { verbose, quiet = false, false }()
+
+	deflated, err := saml.NewDecoder().EncodeDeflate([]byte(`<samlp:AuthnRequest xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_v"/>`))
+	require.NoError(t, err)
+	input := createTempFile(t, deflated)
+
+	output, err := executeCommand(rootCmd, "inspect", "-f", input, "-v")
+	require.NoError(t, err)
+	assert.Contains(t, output, `msg="base64 decoded" variant=standard`)
+	assert.Contains(t, output, `msg="decoded content is not XML; deflate applied"`)
+
+	verbose = false
+	output, err = executeCommand(rootCmd, "inspect", "-f", input)
+	require.NoError(t, err)
+	assert.NotContains(t, output, "level=DEBUG")
+
+	_, err = executeCommand(rootCmd, "inspect", "-f", input, "-v", "-q")
+	assert.EqualError(t, err, "only one of --quiet and --verbose may be given")
+}
+
+func TestRootCmd_Quiet(t *testing.T) {
+	resetInspectFlags()
+	defer resetInspectFlags()
+	defer func() { quiet = false }()
+
+	responsePath := filepath.Join("..", "testdata", "fixtures", "assertions", "response.xml")
+	reportPath := filepath.Join(t.TempDir(), "report.html")
+
+	output, err := executeCommand(rootCmd, "inspect", "-f", responsePath, "--report", reportPath, "-q")
+	require.NoError(t, err)
+	assert.NotContains(t, output, "Report written to")
+	assert.FileExists(t, reportPath)
+}
+
+func TestRootCmd_AttributeNames(t *testing.T) {
+	resetInspectFlags()
+	defer resetInspectFlags()
+	defer func() {
+		noResolve, attributeMapFile = false, ""
+		require.NoError(t, setUpAttributeNames())
+	}()
+
+	input := createTempFile(t, `<saml:Assertion xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_a" Version="2.0" IssueInstant="2024-01-01T00:00:00Z">
+  <saml:Issuer>https://idp.example.com</saml:Issuer>
+  <saml:AttributeStatement>
+    <saml:Attribute Name="urn:oid:0.9.2342.19200300.100.1.3"><saml:AttributeValue>alice@example.com</saml:AttributeValue></saml:Attribute>
+    <saml:Attribute Name="urn:oid:1.3.6.1.4.1.99999.1.1"><saml:AttributeValue>4711</saml:AttributeValue></saml:Attribute>
+  </saml:AttributeStatement>
+</saml:Assertion>`)
+
+	output, err := executeCommand(rootCmd, "inspect", "-f", input)
+	require.NoError(t, err)
+	assert.Contains(t, output, "mail (urn:oid:0.9.2342.19200300.100.1.3)")
+	assert.NotContains(t, output, "badgeNumber")
+
+	attributeMap := createTempFile(t, "urn:oid:1.3.6.1.4.1.99999.1.1: badgeNumber\n")
+	output, err = executeCommand(rootCmd, "inspect", "-f", input, "--attribute-map", attributeMap)
+	require.NoError(t, err)
+	assert.Contains(t, output, "badgeNumber (urn:oid:1.3.6.1.4.1.99999.1.1)")
+
+	attributeMapFile = ""
+	output, err = executeCommand(rootCmd, "inspect", "-f", input, "--no-resolve")
+	require.NoError(t, err)
+	assert.NotContains(t, output, "mail (")
+
+	_, err = executeCommand(rootCmd, "inspect", "-f", input, "--no-resolve", "--attribute-map", attributeMap)
+	assert.EqualError(t, err, "only one of --no-resolve and --attribute-map may be given")
+}
+
+func TestRootCmd_Timeout(t *testing.T) {
+	resetInspectFlags()
+	defer resetInspectFlags()
+	defer func() { timeout = 0 }()
+
+	harFile := createTempFile(t, checkFlowHAR("302"))
+
+	_, err := executeCommand(rootCmd, "inspect", "-f", harFile, "--timeout", "1ns")
+	require.Error(t, err)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Contains(t, err.Error(), "timed out after 1ns (--timeout)")
+
+	// The deadline does not carry over to the next run
+	timeout = 0
+	output, err := executeCommand(rootCmd, "inspect", "-f", harFile)
+	require.NoError(t, err)
+	assert.Contains(t, output, "AuthnRequest")
+
+	_, err = executeCommand(rootCmd, "inspect", "-f", harFile, "--timeout", "-1s")
+	assert.EqualError(t, err, "invalid --timeout -1s: must not be negative")
+}
@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"github.com/gliwka/SAMLurai/internal/output"
+	"github.com/spf13/cobra"
+)
+
+var schemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Print the JSON Schema of the JSON output of messages",
+	Long: `Print the JSON Schema (draft 2020-12) of the -o json and -o jsonl output
+of inspect, extract and the other commands that print parsed messages.
+
+Every message carries a schema_version field. The minor version is raised
+when fields are added, and the major version when fields are removed,
+renamed or change their type, so a parser written against one version
+can read every output of the same major version.
+
+Examples:
+  # Save the schema for a downstream parser
+  samlurai schema > samlurai.schema.json
+
+  # Validate output against it
+  samlurai inspect -f response.xml -o json > response.json
+  check-jsonschema --schemafile samlurai.schema.json response.json`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		_, err := cmd.OutOrStdout().Write(output.Schema)
+		return err
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(schemaCmd)
+}
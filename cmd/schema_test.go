@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/gliwka/SAMLurai/internal/output"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchemaCmd(t *testing.T) {
+	out, err := executeCommand(rootCmd, "schema")
+	require.NoError(t, err)
+
+	var schema map[string]any
+	require.NoError(t, json.Unmarshal([]byte(out), &schema))
+	assert.Contains(t, schema, "$defs")
+	assert.Contains(t, schema["description"], "schema_version "+output.SchemaVersion)
+
+	_, err = executeCommand(rootCmd, "schema", "extra")
+	assert.Error(t, err)
+}
+
+func TestInspectCmd_SchemaVersion(t *testing.T) {
+	resetInspectFlags()
+	defer resetInspectFlags()
+
+	tmpFile := createTempFile(t, `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_r" Version="2.0"/>`)
+	out, err := executeCommand(rootCmd, "inspect", "-f", tmpFile, "-o", "json")
+	require.NoError(t, err)
+
+	var decoded map[string]any
+	require.NoError(t, json.Unmarshal([]byte(out), &decoded))
+	assert.Equal(t, output.SchemaVersion, decoded["schema_version"])
+}
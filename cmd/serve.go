@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gliwka/SAMLurai/internal/metrics"
+	"github.com/gliwka/SAMLurai/internal/server"
+	"github.com/gliwka/SAMLurai/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var (
+	serveAddr    string
+	servePersist bool
+	serveStore   string
+	serveMetrics bool
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Start a local web UI for inspecting SAML",
+	Long: `Start a local web server that provides a browser-based UI for SAMLurai.
+
+The web UI supports:
+  - Drag-and-drop upload of HAR, XML or base64-encoded SAML files
+  - Pasting SAML directly into the page
+  - Uploading a PEM private key to decrypt encrypted assertions
+  - Collapsible views of the parsed messages and their raw XML
+
+By default the server only listens on localhost. Uploaded data and keys
+are processed in memory and never written to disk.
+
+With --persist or --store, every decoded message is also saved to a store
+for later analysis with "samlurai db". Keys are never stored.
+
+With --metrics, counts of the messages inspected, of parse, signature and
+decryption failures and of expired assertions are served at /metrics in
+the Prometheus text format (or OpenMetrics, if the scraper asks for it),
+to monitor long-running debugging sessions. Signatures are only checked
+for integrity, against the certificates they carry.
+
+Examples:
+  # Start the web UI on the default address
+  samlurai serve
+
+  # Listen on a different port
+  samlurai serve --addr 127.0.0.1:9000
+
+  # Keep everything inspected during a test window
+  samlurai serve --store test-window.db
+
+  # Or as JSON Lines, to process with jq
+  samlurai serve --store jsonl:test-window.jsonl
+
+  # Let Prometheus scrape http://127.0.0.1:8080/metrics
+  samlurai serve --metrics`,
+	RunE: runServe,
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+
+	serveCmd.Flags().StringVar(&serveAddr, "addr", "127.0.0.1:8080", "Address to listen on")
+	serveCmd.Flags().BoolVar(&servePersist, "persist", false, "Save decoded messages to the default store")
+	serveCmd.Flags().StringVar(&serveStore, "store", "", "Save decoded messages to this store (path or backend:location)")
+	serveCmd.Flags().BoolVar(&serveMetrics, "metrics", false, "Serve Prometheus metrics at /metrics")
+}
+
+func runServe(cmd *cobra.Command, args []string) error {
+	handler := server.NewServer()
+	if servePersist || serveStore != "" {
+		st, err := store.Open(serveStore)
+		if err != nil {
+			return err
+		}
+		defer st.Close()
+		handler.WithStore(st)
+	}
+	if serveMetrics {
+		handler.WithMetrics(metrics.New())
+	}
+
+	srv := &http.Server{
+		Addr:              serveAddr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "SAMLurai web UI listening on http://%s\n", serveAddr)
+	if serveMetrics {
+		fmt.Fprintf(cmd.OutOrStdout(), "Metrics at http://%s%s\n", serveAddr, metrics.Path)
+	}
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return fmt.Errorf("failed to start server: %w", err)
+	}
+	return nil
+}
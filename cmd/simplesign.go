@@ -0,0 +1,206 @@
+package cmd
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/gliwka/SAMLurai/internal/clipboard"
+	"github.com/gliwka/SAMLurai/internal/output"
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/spf13/cobra"
+)
+
+var (
+	simpleSignFile       string
+	simpleSignCert       string
+	simpleSignKey        string
+	simpleSignParam      string
+	simpleSignRelayState string
+	simpleSignSigAlg     string
+)
+
+var simpleSignCmd = &cobra.Command{
+	Use:   "simplesign",
+	Short: "Verify and create HTTP-POST-SimpleSign messages",
+	Long: `Verify and create messages for the HTTP-POST-SimpleSign binding.
+
+SimpleSign signs the base64-encoded message together with the RelayState
+and SigAlg form fields, instead of embedding an XML signature. The
+signature is sent in the Signature form field.
+
+Examples:
+  # Verify a captured form body against the IdP certificate
+  samlurai simplesign verify -f form.txt --cert idp.pem
+
+  # Sign a response and print the form body to POST
+  samlurai simplesign sign -f response.xml -k idp-key.pem --relay-state /home`,
+}
+
+var simpleSignVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Verify the signature of a SimpleSign form body",
+	Long: `Verify the signature of a URL-encoded SimpleSign form body containing
+SAMLRequest or SAMLResponse, an optional RelayState, SigAlg and Signature.
+
+Examples:
+  samlurai simplesign verify -f form.txt --cert idp.pem
+  cat form.txt | samlurai simplesign verify --cert idp.pem`,
+	RunE: runSimpleSignVerify,
+}
+
+var simpleSignSignCmd = &cobra.Command{
+	Use:   "sign",
+	Short: "Create a signed SimpleSign form body",
+	Long: `Sign a SAML message for the HTTP-POST-SimpleSign binding and print the
+URL-encoded form body.
+
+The input may be XML or base64-encoded SAML. The parameter name is derived
+from the message type unless --param is given.
+
+Examples:
+  samlurai simplesign sign -f response.xml -k idp-key.pem
+  samlurai simplesign sign -f request.xml -k sp-key.pem --sig-alg rsa-sha512`,
+	// -k is the signing key, not the decryption key of a profile
+	Annotations: map[string]string{profileOptOutAnnotation: "key"},
+	RunE:        runSimpleSignSign,
+}
+
+func init() {
+	rootCmd.AddCommand(simpleSignCmd)
+	simpleSignCmd.AddCommand(simpleSignVerifyCmd)
+	simpleSignCmd.AddCommand(simpleSignSignCmd)
+
+	simpleSignVerifyCmd.Flags().StringVarP(&simpleSignFile, "file", "f", "", "Read the form body from file")
+	simpleSignVerifyCmd.Flags().StringVar(&simpleSignCert, "cert", "", "Signer certificate (PEM or base64 DER) (required)")
+	_ = simpleSignVerifyCmd.MarkFlagRequired("cert")
+
+	simpleSignSignCmd.Flags().StringVarP(&simpleSignFile, "file", "f", "", "Read the SAML message from file")
+	simpleSignSignCmd.Flags().StringVarP(&simpleSignKey, "key", "k", "", "Private key to sign with (PEM format) (required)")
+	simpleSignSignCmd.Flags().StringVar(&simpleSignParam, "param", "", "Form parameter name: SAMLRequest or SAMLResponse (default: from message type)")
+	simpleSignSignCmd.Flags().StringVar(&simpleSignRelayState, "relay-state", "", "RelayState to include in the signature")
+	simpleSignSignCmd.Flags().StringVar(&simpleSignSigAlg, "sig-alg", "", "Signature algorithm, e.g. rsa-sha256 or ecdsa-sha256 (default: from key type)")
+	_ = simpleSignSignCmd.MarkFlagRequired("key")
+}
+
+func runSimpleSignVerify(cmd *cobra.Command, args []string) error {
+	input, err := getSimpleSignInput()
+	if err != nil {
+		return err
+	}
+
+	msg, err := saml.ParseSimpleSignForm(input)
+	if err != nil {
+		return withExitCode(ExitParse, err)
+	}
+
+	cert, err := saml.LoadCertificate(simpleSignCert)
+	if err != nil {
+		return err
+	}
+
+	verifyErr := withExitCode(ExitSignature, msg.Verify(cert))
+
+	if output.NewFormatter(outputFormat).IsJSON() {
+		result := struct {
+			*saml.SimpleSignMessage
+			Valid bool   `json:"valid"`
+			Error string `json:"error,omitempty"`
+		}{SimpleSignMessage: msg, Valid: verifyErr == nil}
+		if verifyErr != nil {
+			result.Error = verifyErr.Error()
+		}
+		formatted, err := output.NewFormatter(outputFormat).FormatJSON(result)
+		if err != nil {
+			return fmt.Errorf("failed to format output: %w", err)
+		}
+		fmt.Fprint(cmd.OutOrStdout(), formatted)
+		return verifyErr
+	}
+
+	if verifyErr != nil {
+		return verifyErr
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "✓ %s signature valid (%s)\n", msg.ParameterName, msg.SigAlg)
+	if msg.RelayState != "" {
+		fmt.Fprintf(cmd.OutOrStdout(), "  RelayState: %s\n", msg.RelayState)
+	}
+	return nil
+}
+
+func runSimpleSignSign(cmd *cobra.Command, args []string) error {
+	input, err := getSimpleSignInput()
+	if err != nil {
+		return err
+	}
+
+	xmlData, err := saml.NewDecoder().SmartDecode(input)
+	if err != nil {
+		return withExitCode(ExitParse, fmt.Errorf("failed to decode input: %w", err))
+	}
+
+	key, err := saml.LoadSigningKey(simpleSignKey)
+	if err != nil {
+		return fmt.Errorf("failed to load private key: %w", err)
+	}
+
+	sigAlg, err := resolveSigAlgFor(simpleSignSigAlg, key)
+	if err != nil {
+		return err
+	}
+
+	param := simpleSignParam
+	if param == "" {
+		param = saml.ParameterNameFor(xmlData)
+	}
+
+	msg, err := saml.SignSimpleSign(xmlData, param, simpleSignRelayState, sigAlg, key)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(cmd.OutOrStdout(), msg.Encode())
+	return nil
+}
+
+// resolveSigAlgFor resolves a --sig-alg value, defaulting to SHA-256 with
+// the algorithm of key
+func resolveSigAlgFor(name string, key crypto.Signer) (string, error) {
+	if name == "" {
+		name = saml.SigAlgRSASHA256
+		if _, ok := key.(*ecdsa.PrivateKey); ok {
+			name = saml.SigAlgECDSASHA256
+		}
+	}
+	return saml.ResolveSigAlg(name)
+}
+
+func getSimpleSignInput() (string, error) {
+	if simpleSignFile != "" {
+		data, err := os.ReadFile(simpleSignFile)
+		if err != nil {
+			return "", fmt.Errorf("failed to read file: %w", err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+
+	if inputFromClipboard {
+		return clipboard.ReadText()
+	}
+
+	// Check if stdin has data
+	stat, _ := os.Stdin.Stat()
+	if (stat.Mode() & os.ModeCharDevice) == 0 {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return "", fmt.Errorf("failed to read stdin: %w", err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+
+	return "", fmt.Errorf("no input provided. Use -f flag, --clipboard, or pipe data to stdin")
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func resetSimpleSignFlags() {
+	simpleSignFile = ""
+	simpleSignCert = ""
+	simpleSignKey = ""
+	simpleSignParam = ""
+	simpleSignRelayState = ""
+	simpleSignSigAlg = ""
+	outputFormat = "pretty"
+}
+
+func TestSimpleSignCmd_SignAndVerify(t *testing.T) {
+	resetSimpleSignFlags()
+	defer resetSimpleSignFlags()
+
+	dir := t.TempDir()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	keyPath := filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}), 0600))
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "idp.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	certPath := filepath.Join(dir, "cert.pem")
+	require.NoError(t, os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644))
+
+	responsePath := filepath.Join("..", "testdata", "fixtures", "assertions", "response.xml")
+	output, err := executeCommand(rootCmd, "simplesign", "sign", "-f", responsePath, "-k", keyPath, "--relay-state", "/home")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(output, "SAMLResponse="))
+	assert.Contains(t, output, "&RelayState=%2Fhome&SigAlg=http%3A%2F%2Fwww.w3.org%2F2001%2F04%2Fxmldsig-more%23rsa-sha256&Signature=")
+
+	formPath := filepath.Join(dir, "form.txt")
+	require.NoError(t, os.WriteFile(formPath, []byte(output), 0644))
+
+	resetSimpleSignFlags()
+	output, err = executeCommand(rootCmd, "simplesign", "verify", "-f", formPath, "--cert", certPath)
+	require.NoError(t, err)
+	assert.Contains(t, output, "✓ SAMLResponse signature valid")
+	assert.Contains(t, output, "RelayState: /home")
+
+	// Changing the RelayState breaks the signature
+	tampered := strings.Replace(string(mustReadFile(t, formPath)), "RelayState=%2Fhome", "RelayState=%2Fadmin", 1)
+	require.NoError(t, os.WriteFile(formPath, []byte(tampered), 0644))
+
+	resetSimpleSignFlags()
+	_, err = executeCommand(rootCmd, "simplesign", "verify", "-f", formPath, "--cert", certPath)
+	assert.ErrorContains(t, err, "signature verification failed")
+}
+
+func mustReadFile(t *testing.T, path string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return data
+}
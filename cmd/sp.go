@@ -0,0 +1,302 @@
+package cmd
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gliwka/SAMLurai/internal/metadata"
+	"github.com/gliwka/SAMLurai/internal/metrics"
+	"github.com/gliwka/SAMLurai/internal/output"
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/gliwka/SAMLurai/internal/sp"
+	"github.com/spf13/cobra"
+)
+
+var (
+	spAddr         string
+	spBaseURL      string
+	spEntityID     string
+	spIdPMetadata  string
+	spIdPEntityID  string
+	spIdPSSO       string
+	spIdPCert      string
+	spBinding      string
+	spSignKey      string
+	spSignCert     string
+	spSigAlg       string
+	spSignRequests bool
+	spNameIDFormat string
+	spClockSkew    time.Duration
+	spShowXML      bool
+	spMetrics      bool
+)
+
+var spCmd = &cobra.Command{
+	Use:   "sp",
+	Short: "Run a test SP that sends AuthnRequests and inspects the Responses it receives",
+	Long: `Run a local service provider for testing an IdP end to end. Every
+Response posted to its ACS is decoded, verified, decrypted and printed as it
+arrives.
+
+The SP serves:
+  /metadata   its metadata, to register it with the IdP
+  /login      starts SP-initiated login by sending an AuthnRequest to the IdP
+  /acs        the AssertionConsumerService, for the HTTP-POST binding
+  /metrics    with --metrics, counts of the Responses received and of
+              parse, signature and decryption failures and expired
+              assertions, for Prometheus
+
+The IdP is configured with --idp-metadata (a file or URL), or with --idp-sso
+and --idp-cert. Responses are verified against the IdP's signing
+certificates; without them, the certificate in the signature is used, which
+shows the Response is intact but not who signed it.
+
+Without --sign-key, a key and self-signed certificate are generated at
+startup and published in the metadata. The key signs AuthnRequests with
+--sign-requests and decrypts encrypted assertions.
+
+Each Response is checked for expiry, Destination, Recipient, audience and
+issuer, and whether it answers an AuthnRequest sent by this SP. Use -o jsonl
+for one JSON object per Response or AuthnRequest, including its XML.
+
+Examples:
+  # Register http://127.0.0.1:8082/metadata with the IdP, then open
+  # http://127.0.0.1:8082/login
+  samlurai sp --idp-metadata https://idp.example.com/metadata
+
+  # Against the test IdP, with signed AuthnRequests
+  samlurai idp &
+  samlurai sp --idp-metadata http://127.0.0.1:8081/metadata --sign-requests
+
+  # Fixed key, POST binding, and the XML of every Response
+  samlurai sp --idp-sso https://idp.example.com/sso --idp-cert idp.pem \
+    --sign-key sp-key.pem --sign-cert sp.pem --binding post --show-xml`,
+	RunE: runSP,
+}
+
+func init() {
+	rootCmd.AddCommand(spCmd)
+
+	flags := spCmd.Flags()
+	flags.StringVar(&spAddr, "addr", "127.0.0.1:8082", "Address to listen on")
+	flags.StringVar(&spBaseURL, "base-url", "", "URL the SP is reachable at (default: http://<addr>)")
+	flags.StringVar(&spEntityID, "entity-id", "", "Entity ID of the SP (default: the metadata URL)")
+	flags.StringVar(&spIdPMetadata, "idp-metadata", "", "Metadata of the IdP (file or URL)")
+	flags.StringVar(&spIdPEntityID, "idp-entity-id", "", "Entity ID of the IdP, to select it from aggregate metadata and check the Issuer")
+	flags.StringVar(&spIdPSSO, "idp-sso", "", "SingleSignOnService URL of the IdP (default: from --idp-metadata)")
+	flags.StringVar(&spIdPCert, "idp-cert", "", "Signing certificate of the IdP (PEM or DER) (default: from --idp-metadata)")
+	flags.StringVar(&spBinding, "binding", "redirect", "Binding for AuthnRequests: redirect or post")
+	flags.StringVar(&spSignKey, "sign-key", "", "Private key to sign and decrypt with (PEM) (default: generated)")
+	flags.StringVar(&spSignCert, "sign-cert", "", "Certificate of the key, published in the metadata")
+	flags.StringVar(&spSigAlg, "sig-alg", "", "Signature algorithm, e.g. rsa-sha256 (default: from key type)")
+	flags.BoolVar(&spSignRequests, "sign-requests", false, "Sign AuthnRequests")
+	flags.StringVar(&spNameIDFormat, "nameid-format", "", "NameID format to request: email, persistent, transient, unspecified, or a URI")
+	flags.DurationVar(&spClockSkew, "clock-skew", 0, "Clock skew to tolerate when checking validity windows")
+	flags.BoolVar(&spShowXML, "show-xml", false, "Also print the XML of each Response")
+	flags.BoolVar(&spMetrics, "metrics", false, "Serve Prometheus metrics at /metrics")
+}
+
+func runSP(cmd *cobra.Command, args []string) error {
+	formatter := output.NewFormatter(outputFormat)
+	if outputFormat != "pretty" && !formatter.IsJSONL() {
+		return fmt.Errorf("sp supports pretty and jsonl output, not %q", outputFormat)
+	}
+
+	baseURL := spBaseURL
+	if baseURL == "" {
+		baseURL = "http://" + spAddr
+	}
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	cfg := sp.Config{
+		EntityID:     spEntityID,
+		BaseURL:      baseURL,
+		IdPEntityID:  spIdPEntityID,
+		IdPSSO:       spIdPSSO,
+		SignRequests: spSignRequests,
+		ClockSkew:    spClockSkew,
+	}
+	if cfg.EntityID == "" {
+		cfg.EntityID = baseURL + sp.MetadataPath
+	}
+
+	var err error
+	switch strings.ToLower(spBinding) {
+	case "redirect":
+		cfg.Binding = saml.BindingHTTPRedirect
+	case "post":
+		cfg.Binding = saml.BindingHTTPPost
+	default:
+		return fmt.Errorf("unknown binding %q, expected redirect or post", spBinding)
+	}
+	if err := configureSPIdP(cmd, &cfg); err != nil {
+		return err
+	}
+	if cfg.Key, cfg.Cert, err = loadSigningKeyPair(spSignKey, spSignCert, cfg.EntityID); err != nil {
+		return err
+	}
+	if cfg.SigAlg, err = resolveSigAlgFor(spSigAlg, cfg.Key); err != nil {
+		return err
+	}
+	if decrypter, ok := cfg.Key.(crypto.Decrypter); ok {
+		cfg.Decryptor = saml.NewDecryptorWithKey(decrypter)
+	}
+	if spNameIDFormat != "" {
+		if cfg.NameIDFormat, err = metadata.ResolveNameIDFormat(spNameIDFormat); err != nil {
+			return err
+		}
+	}
+
+	handler, err := sp.NewServer(cfg)
+	if err != nil {
+		return err
+	}
+	out := cmd.OutOrStdout()
+	var mu sync.Mutex
+	handler.OnSend(func(sent sp.Sent) {
+		mu.Lock()
+		defer mu.Unlock()
+		if formatter.IsJSONL() {
+			_ = json.NewEncoder(out).Encode(struct {
+				Type string `json:"type"`
+				sp.Sent
+			}{"AuthnRequest", sent})
+			return
+		}
+		fmt.Fprintf(out, "%s  AuthnRequest %s → %s\n\n", time.Now().Format("15:04:05"), sent.ID, sent.Destination)
+	})
+	handler.OnReceive(func(received sp.Received) {
+		mu.Lock()
+		defer mu.Unlock()
+		if formatter.IsJSONL() {
+			_ = json.NewEncoder(out).Encode(struct {
+				Type string `json:"type"`
+				sp.Received
+			}{"Response", received})
+			return
+		}
+		printReceived(cmd, formatter, received)
+	})
+	if spMetrics {
+		handler.WithMetrics(metrics.New())
+	}
+
+	if spSignKey == "" {
+		notef(cmd, "Using a generated key; its certificate is in the metadata\n")
+	}
+	if len(cfg.IdPCerts) == 0 {
+		notef(cmd, "No IdP certificate configured; signatures are checked against their own KeyInfo\n")
+	}
+	notef(cmd, "SAMLurai test SP listening on %s\n", baseURL)
+	notef(cmd, "  Entity ID: %s\n", cfg.EntityID)
+	notef(cmd, "  Metadata:  %s%s\n", baseURL, sp.MetadataPath)
+	notef(cmd, "  ACS:       %s\n", handler.ACSURL())
+	if cfg.IdPSSO != "" {
+		notef(cmd, "  Login:     %s%s\n", baseURL, sp.LoginPath)
+	}
+	if spMetrics {
+		notef(cmd, "  Metrics:   %s%s\n", baseURL, metrics.Path)
+	}
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+	defer stop()
+	return listenAndServe(ctx, spAddr, handler)
+}
+
+// configureSPIdP fills in the IdP of cfg from --idp-metadata, with
+// --idp-sso and --idp-cert taking precedence
+func configureSPIdP(cmd *cobra.Command, cfg *sp.Config) error {
+	if spIdPMetadata != "" {
+		doc, err := metadata.Load(cmd.Context(), spIdPMetadata, metadata.Options{})
+		if err != nil {
+			return err
+		}
+		idpInfo, err := metadata.ParseIdP(doc.Data, spIdPEntityID)
+		if err != nil {
+			return fmt.Errorf("invalid IdP metadata: %w", err)
+		}
+		if cfg.IdPEntityID == "" {
+			cfg.IdPEntityID = idpInfo.EntityID
+		}
+		if cfg.IdPSSO == "" {
+			cfg.IdPSSO = idpInfo.SSO[cfg.Binding]
+		}
+		if cfg.IdPSSO == "" {
+			return fmt.Errorf("IdP metadata has no SingleSignOnService for %s", cfg.Binding)
+		}
+		cfg.IdPCerts = idpInfo.SigningCerts
+	}
+	if spIdPCert != "" {
+		cert, err := saml.LoadCertificate(spIdPCert)
+		if err != nil {
+			return fmt.Errorf("IdP certificate: %w", err)
+		}
+		cfg.IdPCerts = []*x509.Certificate{cert}
+	}
+	return nil
+}
+
+// printReceived prints a Response posted to the test SP's ACS
+func printReceived(cmd *cobra.Command, formatter *output.Formatter, received sp.Received) {
+	out := cmd.OutOrStdout()
+
+	fmt.Fprintf(out, "━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
+	fmt.Fprintf(out, " %s  Response received", time.Now().Format("15:04:05"))
+	if received.RelayState != "" {
+		fmt.Fprintf(out, " (RelayState %s)", received.RelayState)
+	}
+	fmt.Fprintf(out, "\n━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n\n")
+	printInspected(cmd, formatter, received, spShowXML)
+}
+
+// printInspected prints the verdict, warnings and contents of a Response
+// inspected by the sp package, and its XML if showXML is set
+func printInspected(cmd *cobra.Command, formatter *output.Formatter, received sp.Received, showXML bool) {
+	out := cmd.OutOrStdout()
+	switch received.Verdict {
+	case saml.VerdictVerified:
+		fmt.Fprintf(out, "✅ Signature verified (%s)\n", strings.Join(received.Verified, ", "))
+	case saml.VerdictSelfSigned:
+		fmt.Fprintf(out, "⚠️  Signature unmodified, but checked only against its own certificate (%s); pass --idp-cert or --idp-metadata to verify the signer\n", strings.Join(received.Verified, ", "))
+	case saml.VerdictSigFail:
+		fmt.Fprintf(out, "❌ Signature verification failed: %s\n", received.VerifyError)
+	case saml.VerdictUnsigned:
+		fmt.Fprintf(out, "⚠️  Not signed\n")
+	}
+	if received.Decrypted {
+		fmt.Fprintf(out, "🔓 Assertion decrypted\n")
+	}
+	if received.Error != "" {
+		fmt.Fprintf(out, "⚠️  %s\n", received.Error)
+	}
+	for _, warning := range received.Warnings {
+		fmt.Fprintf(out, "⚠️  %s\n", warning)
+	}
+	fmt.Fprintln(out)
+
+	if received.Info != nil {
+		formatted, err := formatter.FormatSAMLInfo(received.Info)
+		if err != nil {
+			fmt.Fprintf(out, "⚠️  Failed to format: %v\n\n", err)
+		} else {
+			fmt.Fprint(out, formatted)
+			fmt.Fprintln(out)
+		}
+	}
+	if showXML {
+		xmlData := received.XML
+		if received.AssertionXML != "" {
+			xmlData = received.AssertionXML
+		}
+		if pretty, err := formatter.FormatXML([]byte(xmlData)); err == nil {
+			xmlData = pretty
+		}
+		fmt.Fprintln(out, xmlData)
+	}
+}
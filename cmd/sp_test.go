@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/gliwka/SAMLurai/internal/idp"
+	"github.com/gliwka/SAMLurai/internal/output"
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/gliwka/SAMLurai/internal/sp"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func resetSPFlags() {
+	spIdPMetadata = ""
+	spIdPEntityID = ""
+	spIdPSSO = ""
+	spIdPCert = ""
+	spShowXML = false
+}
+
+func TestConfigureSPIdP_Metadata(t *testing.T) {
+	defer resetSPFlags()
+
+	key, cert, err := saml.NewSelfSignedKey("idp.example.com")
+	require.NoError(t, err)
+	server, err := idp.NewServer(idp.Config{
+		EntityID:      "https://idp.example.com",
+		BaseURL:       "https://idp.example.com",
+		Key:           key,
+		Cert:          cert,
+		SigAlg:        saml.SigAlgRSASHA256,
+		NameID:        "alice",
+		SignAssertion: true,
+	})
+	require.NoError(t, err)
+	data, err := server.Metadata()
+	require.NoError(t, err)
+	spIdPMetadata = createTempFile(t, string(data))
+
+	cmd := &cobra.Command{}
+	cfg := sp.Config{Binding: saml.BindingHTTPRedirect}
+	require.NoError(t, configureSPIdP(cmd, &cfg))
+	assert.Equal(t, "https://idp.example.com", cfg.IdPEntityID)
+	assert.Equal(t, "https://idp.example.com/sso", cfg.IdPSSO)
+	require.Len(t, cfg.IdPCerts, 1)
+	assert.Equal(t, cert.Raw, cfg.IdPCerts[0].Raw)
+
+	spIdPSSO = "https://idp.example.com/other"
+	cfg = sp.Config{Binding: saml.BindingHTTPPost, IdPSSO: spIdPSSO}
+	require.NoError(t, configureSPIdP(cmd, &cfg))
+	assert.Equal(t, "https://idp.example.com/other", cfg.IdPSSO)
+}
+
+func TestPrintReceived(t *testing.T) {
+	defer resetSPFlags()
+
+	var out bytes.Buffer
+	cmd := &cobra.Command{}
+	cmd.SetOut(&out)
+	printReceived(cmd, output.NewFormatter("pretty"), sp.Received{
+		RelayState:  "/app",
+		Verdict:     saml.VerdictSigFail,
+		VerifyError: "Response: signature mismatch",
+		Warnings:    []string{"unsolicited Response (IdP-initiated SSO)"},
+	})
+	assert.Contains(t, out.String(), "Response received (RelayState /app)")
+	assert.Contains(t, out.String(), "❌ Signature verification failed: Response: signature mismatch")
+	assert.Contains(t, out.String(), "⚠️  unsolicited Response (IdP-initiated SSO)")
+
+	out.Reset()
+	printReceived(cmd, output.NewFormatter("pretty"), sp.Received{Verdict: saml.VerdictSelfSigned, Verified: []string{"Response"}})
+	assert.Contains(t, out.String(), "⚠️  Signature unmodified, but checked only against its own certificate (Response); pass --idp-cert or --idp-metadata to verify the signer")
+}
+
+func TestSPCmd_UnsupportedOutput(t *testing.T) {
+	defer func() { outputFormat = "pretty" }()
+
+	_, err := executeCommand(rootCmd, "sp", "-o", "csv")
+	assert.ErrorContains(t, err, `sp supports pretty and jsonl output, not "csv"`)
+}
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/gliwka/SAMLurai/internal/output"
+	"github.com/gliwka/SAMLurai/internal/stats"
+	"github.com/spf13/cobra"
+)
+
+var (
+	statsDir string
+)
+
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Aggregate anonymized statistics across many SAML captures",
+	Long: `Aggregate anonymized statistics across all HAR, XML and base64 files
+in a directory (searched recursively).
+
+Only aggregate counts are reported; no subjects, identifiers or attribute
+values are included in the output. The statistics cover:
+  - Message types
+  - Signature and digest algorithms
+  - Encryption usage
+  - Assertion validity window lengths
+  - NameID formats
+  - IdP vendors (guessed from the issuer)
+
+Examples:
+  # Summarize a directory of captures
+  samlurai stats --dir ./captures
+
+  # Machine-readable output
+  samlurai stats --dir ./captures -o json`,
+	RunE: runStats,
+}
+
+func init() {
+	rootCmd.AddCommand(statsCmd)
+
+	statsCmd.Flags().StringVarP(&statsDir, "dir", "d", "", "Directory of captures to aggregate (required)")
+	_ = statsCmd.MarkFlagRequired("dir")
+}
+
+func runStats(cmd *cobra.Command, args []string) error {
+	s := stats.New()
+	if err := s.CollectDir(statsDir); err != nil {
+		return fmt.Errorf("failed to read directory: %w", err)
+	}
+
+	if output.NewFormatter(outputFormat).IsJSON() {
+		formatted, err := output.NewFormatter(outputFormat).FormatJSON(s)
+		if err != nil {
+			return fmt.Errorf("failed to format output: %w", err)
+		}
+		fmt.Fprint(cmd.OutOrStdout(), formatted)
+		return nil
+	}
+
+	w := cmd.OutOrStdout()
+	fmt.Fprintf(w, "Files: %d (%d without SAML)\n", s.Files, s.FilesFailed)
+	fmt.Fprintf(w, "Messages: %d\n", s.Messages)
+
+	printCounts(w, "Message Types", s.MessageTypes)
+	printCounts(w, "Signature Algorithms", s.SignatureAlgorithms)
+	printCounts(w, "Digest Algorithms", s.DigestAlgorithms)
+	printCounts(w, "Encryption", s.Encryption)
+	printCounts(w, "Validity Windows", s.ValidityWindows)
+	printCounts(w, "NameID Formats", s.NameIDFormats)
+	printCounts(w, "IdP Vendors", s.Vendors)
+
+	return nil
+}
+
+// printCounts prints a section of counts, most frequent first
+func printCounts(w io.Writer, title string, counts map[string]int) {
+	if len(counts) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n▸ %s\n", title)
+	for _, key := range stats.SortedKeys(counts) {
+		fmt.Fprintf(w, "  %6d  %s\n", counts[key], key)
+	}
+}
@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatsCmd(t *testing.T) {
+	statsDir = ""
+	outputFormat = "pretty"
+
+	fixtureDir := filepath.Join("..", "testdata", "fixtures", "assertions")
+
+	output, err := executeCommand(rootCmd, "stats", "--dir", fixtureDir)
+	require.NoError(t, err)
+	assert.Contains(t, output, "Files: 3")
+	assert.Contains(t, output, "Message Types")
+	assert.Contains(t, output, "NameID Formats")
+	// Only aggregates are reported
+	assert.NotContains(t, output, "user@example.com")
+}
+
+func TestStatsCmd_JSON(t *testing.T) {
+	statsDir = ""
+	defer func() { outputFormat = "pretty" }()
+
+	fixtureDir := filepath.Join("..", "testdata", "fixtures", "assertions")
+
+	output, err := executeCommand(rootCmd, "stats", "--dir", fixtureDir, "-o", "json")
+	require.NoError(t, err)
+	assert.Contains(t, output, `"messages": 3`)
+	assert.Contains(t, output, `"validity_windows"`)
+}
@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gliwka/SAMLurai/internal/inspect"
+	"github.com/gliwka/SAMLurai/internal/output"
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/gliwka/SAMLurai/internal/tail"
+	"github.com/spf13/cobra"
+)
+
+var (
+	tailFile      string
+	tailFormat    string
+	tailKey       string
+	tailFromStart bool
+	tailNoFollow  bool
+	tailPoll      time.Duration
+)
+
+var tailCmd = &cobra.Command{
+	Use:   "tail",
+	Short: "Follow a log or HAR file and decode SAML messages as they appear",
+	Long: `Follow a growing reverse-proxy log or HAR file and print the SAML
+messages in each new line or entry as it is written, for debugging against
+live traffic.
+
+Formats:
+  combined  Apache/NGINX combined or common log format; SAML parameters are
+            taken from the request URL (HTTP-Redirect binding)
+  raw       SAMLRequest/SAMLResponse/SAMLart/wresult parameters anywhere
+            in a line, or bare base64-encoded messages, for application
+            and other logs
+  har       A HAR file that is rewritten as requests are captured
+
+Without --format, files ending in .har are followed as HAR and everything
+else as a combined log. Only messages written after the command started are
+shown unless --from-start is given. Rotated and truncated log files are
+reopened. Press Ctrl+C to stop.
+
+Only pretty and jsonl output are supported, since messages are printed as
+they arrive.
+
+Examples:
+  # Watch an NGINX access log
+  samlurai tail -f /var/log/nginx/access.log
+
+  # Scan an application log once and exit
+  samlurai tail -f app.log --format raw --no-follow
+
+  # Stream decoded messages from a growing HAR capture to jq
+  samlurai tail -f capture.har -o jsonl | jq -c '{type, issuer: .info.issuer}'`,
+	RunE: runTail,
+}
+
+func init() {
+	rootCmd.AddCommand(tailCmd)
+
+	tailCmd.Flags().StringVarP(&tailFile, "file", "f", "", "Log or HAR file to follow (required)")
+	tailCmd.Flags().StringVar(&tailFormat, "format", "", "File format: "+strings.Join(append(saml.LogFormats(), tail.FormatHAR), ", ")+" (default: by extension)")
+	tailCmd.Flags().StringVarP(&tailKey, "key", "k", "", "Path to private key for decryption (PEM format)")
+	tailCmd.Flags().BoolVar(&tailFromStart, "from-start", false, "Also show messages already in the file")
+	tailCmd.Flags().BoolVar(&tailNoFollow, "no-follow", false, "Scan the whole file once and exit")
+	tailCmd.Flags().DurationVar(&tailPoll, "poll", tail.DefaultPollInterval, "How often to check the file for new data")
+	_ = tailCmd.MarkFlagRequired("file")
+}
+
+func runTail(cmd *cobra.Command, args []string) error {
+	formatter := output.NewFormatter(outputFormat)
+	if outputFormat != "pretty" && !formatter.IsJSONL() {
+		return fmt.Errorf("tail supports pretty and jsonl output, not %q", outputFormat)
+	}
+
+	format := tailFormat
+	if format == "" {
+		format = saml.LogFormatCombined
+		if strings.EqualFold(filepath.Ext(tailFile), ".har") {
+			format = tail.FormatHAR
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+	defer stop()
+
+	opts := tail.Options{
+		Format:       format,
+		FromStart:    tailFromStart,
+		Once:         tailNoFollow,
+		PollInterval: tailPoll,
+	}
+	return tail.Follow(ctx, tailFile, opts, func(results []saml.ExtractedSAML) error {
+		messages, err := inspect.Extracted(ctx, results, tailKey)
+		if err != nil {
+			return err
+		}
+		if formatter.IsJSONL() {
+			formatted, err := formatter.FormatJSONL(jsonlRecords(messages))
+			if err != nil {
+				return fmt.Errorf("failed to format output: %w", err)
+			}
+			fmt.Fprint(cmd.OutOrStdout(), formatted)
+			return nil
+		}
+		for _, msg := range messages {
+			printTailMessage(cmd, formatter, msg)
+		}
+		return nil
+	})
+}
+
+// printTailMessage prints one message with a banner identifying the log
+// line or HAR entry it came from
+func printTailMessage(cmd *cobra.Command, formatter *output.Formatter, msg inspect.Message) {
+	out := cmd.OutOrStdout()
+	extracted := msg.Extracted
+
+	at := ""
+	if extracted.StartedAt != nil {
+		at = " at " + extracted.StartedAt.Format(time.RFC3339)
+		formatter.WithReferenceTime(*extracted.StartedAt, "logged")
+	} else {
+		formatter.WithReferenceTime(time.Time{}, "")
+	}
+
+	fmt.Fprintf(out, "━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
+	fmt.Fprintf(out, " [%d] %s%s\n", msg.Index(), msg.Type(), at)
+	if extracted.URL != "" {
+		fmt.Fprintf(out, "       URL: %s\n", truncateURL(extracted.URL, 70))
+	}
+	fmt.Fprintf(out, "━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n\n")
+
+	if errors.Is(msg.Err, inspect.ErrNoKey) {
+		fmt.Fprintf(out, "⚠️  Encrypted assertion detected - provide -k flag to decrypt\n\n")
+	} else if msg.Err != nil {
+		fmt.Fprintf(out, "⚠️  %v\n\n", msg.Err)
+		return
+	}
+	if msg.Info == nil {
+		return
+	}
+	formatted, err := formatter.FormatSAMLInfo(msg.Info)
+	if err != nil {
+		fmt.Fprintf(out, "⚠️  Failed to format: %v\n\n", err)
+		return
+	}
+	fmt.Fprint(out, formatted)
+	fmt.Fprintln(out)
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func resetTailFlags() {
+	tailFile = ""
+	tailFormat = ""
+	tailKey = ""
+	tailFromStart = false
+	tailNoFollow = false
+	outputFormat = "pretty"
+}
+
+func writeAccessLog(t *testing.T) string {
+	t.Helper()
+	deflated, err := saml.NewDecoder().Deflate([]byte(`<samlp:AuthnRequest xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_tail" Destination="https://idp.example.com/sso"/>`))
+	require.NoError(t, err)
+	param := url.QueryEscape(base64.StdEncoding.EncodeToString(deflated))
+
+	path := filepath.Join(t.TempDir(), "access.log")
+	log := `203.0.113.7 - - [10/Oct/2025:13:55:36 +0000] "GET /favicon.ico HTTP/1.1" 404 0 "-" "curl"
+203.0.113.7 - - [10/Oct/2025:13:55:37 +0000] "GET /sso?SAMLRequest=` + param + ` HTTP/1.1" 302 0 "-" "curl"
+`
+	require.NoError(t, os.WriteFile(path, []byte(log), 0644))
+	return path
+}
+
+func TestTailCmd_NoFollow(t *testing.T) {
+	resetTailFlags()
+	defer resetTailFlags()
+
+	output, err := executeCommand(rootCmd, "tail", "-f", writeAccessLog(t), "--no-follow")
+	require.NoError(t, err)
+	assert.Contains(t, output, "[1] AuthnRequest at 2025-10-10T13:55:37Z")
+	assert.Contains(t, output, "URL: /sso?SAMLRequest=")
+	assert.Contains(t, output, "_tail")
+}
+
+func TestTailCmd_JSONL(t *testing.T) {
+	resetTailFlags()
+	defer resetTailFlags()
+
+	output, err := executeCommand(rootCmd, "tail", "-f", writeAccessLog(t), "--no-follow", "-o", "jsonl")
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	require.Len(t, lines, 1)
+	assert.Contains(t, lines[0], `"type":"AuthnRequest"`)
+}
+
+func TestTailCmd_UnsupportedOutput(t *testing.T) {
+	resetTailFlags()
+	defer resetTailFlags()
+
+	_, err := executeCommand(rootCmd, "tail", "-f", writeAccessLog(t), "-o", "csv")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `tail supports pretty and jsonl output, not "csv"`)
+}
@@ -0,0 +1,185 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gliwka/SAMLurai/internal/output"
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/spf13/cobra"
+)
+
+var (
+	tamperFile      string
+	tamperIndex     int
+	tamperMutations []string
+	tamperNameID    string
+	tamperExtend    time.Duration
+	tamperOutDir    string
+	tamperList      bool
+)
+
+var tamperCmd = &cobra.Command{
+	Use:   "tamper",
+	Short: "Produce mutated Responses for negative security testing of an SP",
+	Long: `Apply built-in mutations to a signed Response, each producing a payload a
+correct SP must reject. Signatures are never recomputed, so every payload
+tests whether the SP really validates what it receives.
+
+Mutations:
+` + tamperMutationList() + `
+Without --mutation, every mutation that applies to the Response is
+produced. Each payload is printed base64-encoded, ready to post as
+SAMLResponse; -o json adds the XML, and --out-dir writes one XML file per
+mutation for use with replay.
+
+The input is a Response as XML or base64, or a HAR file, from which the
+last Response posted by the browser is taken, or the message at --index.
+
+Only test SPs you are authorized to test.
+
+Examples:
+  # List the mutations
+  samlurai tamper --list
+
+  # All mutations of the Response in a HAR capture, written to a directory
+  samlurai tamper -f login.har --out-dir payloads/
+
+  # Signature wrapping with a chosen NameID, posted to the SP
+  samlurai tamper -f response.xml --mutation xsw --nameid admin@example.com --out-dir payloads/
+  samlurai replay -f payloads/xsw.xml --acs https://sp.example.com/acs`,
+	RunE: runTamper,
+}
+
+func init() {
+	rootCmd.AddCommand(tamperCmd)
+
+	flags := tamperCmd.Flags()
+	flags.StringVarP(&tamperFile, "file", "f", "", "Read the Response from file (XML, base64 or HAR)")
+	flags.IntVar(&tamperIndex, "index", 0, "Use the HAR message at this position, as numbered by inspect (default: the last Response)")
+	flags.StringArrayVar(&tamperMutations, "mutation", nil, "Mutation to apply (repeatable; default: all)")
+	flags.StringVar(&tamperNameID, "nameid", "admin@example.com", "NameID put into the Response by the nameid and xsw mutations")
+	flags.DurationVar(&tamperExtend, "extend", 365*24*time.Hour, "How far extend-validity pushes back NotOnOrAfter")
+	flags.StringVar(&tamperOutDir, "out-dir", "", "Write each payload as <mutation>.xml to this directory")
+	flags.BoolVar(&tamperList, "list", false, "List the mutations and exit")
+}
+
+// tamperMutationList describes the mutations for the help text
+func tamperMutationList() string {
+	var b strings.Builder
+	for _, m := range saml.TamperMutations() {
+		fmt.Fprintf(&b, "  %-16s %s\n", m.Name, m.Description)
+	}
+	return b.String()
+}
+
+// tamperPayload is the JSON output for one mutation
+type tamperPayload struct {
+	Mutation     string `json:"mutation"`
+	Description  string `json:"description"`
+	SAMLResponse string `json:"saml_response"`
+	XML          string `json:"xml"`
+}
+
+func runTamper(cmd *cobra.Command, args []string) error {
+	formatter := output.NewFormatter(outputFormat)
+	if tamperList {
+		if formatter.IsJSON() {
+			formatted, err := formatter.FormatJSON(saml.TamperMutations())
+			if err != nil {
+				return fmt.Errorf("failed to format output: %w", err)
+			}
+			fmt.Fprint(cmd.OutOrStdout(), formatted)
+			return nil
+		}
+		fmt.Fprint(cmd.OutOrStdout(), tamperMutationList())
+		return nil
+	}
+	if tamperIndex < 0 {
+		return fmt.Errorf("invalid --index %d: messages are numbered from 1", tamperIndex)
+	}
+
+	// Unknown names are rejected before any input is read
+	selected := tamperMutations
+	explicit := len(selected) > 0
+	if !explicit {
+		for _, m := range saml.TamperMutations() {
+			selected = append(selected, m.Name)
+		}
+	}
+	descriptions := map[string]string{}
+	for _, m := range saml.TamperMutations() {
+		descriptions[m.Name] = m.Description
+	}
+	for _, name := range selected {
+		if _, ok := descriptions[name]; !ok {
+			return fmt.Errorf("unknown mutation %q, see --list", name)
+		}
+	}
+
+	input, err := getInspectInput(cmd, tamperFile)
+	if err != nil {
+		return err
+	}
+	xmlData, _, err := selectResponse(tamperFile, input, tamperIndex)
+	if err != nil {
+		return err
+	}
+
+	opts := saml.TamperOptions{NameID: tamperNameID, Extend: tamperExtend}
+	var payloads []tamperPayload
+	for _, name := range selected {
+		tampered, err := saml.Tamper(xmlData, name, opts)
+		if errors.Is(err, saml.ErrNotApplicable) {
+			if explicit {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			notef(cmd, "Skipping %s: %v\n", name, err)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		payloads = append(payloads, tamperPayload{
+			Mutation:     name,
+			Description:  descriptions[name],
+			SAMLResponse: base64.StdEncoding.EncodeToString(tampered),
+			XML:          string(tampered),
+		})
+	}
+
+	if tamperOutDir != "" {
+		if err := os.MkdirAll(tamperOutDir, 0755); err != nil {
+			return fmt.Errorf("failed to create output directory: %w", err)
+		}
+		for _, p := range payloads {
+			if err := os.WriteFile(filepath.Join(tamperOutDir, p.Mutation+".xml"), []byte(p.XML), 0644); err != nil {
+				return fmt.Errorf("failed to write %s.xml: %w", p.Mutation, err)
+			}
+		}
+	}
+
+	out := cmd.OutOrStdout()
+	if formatter.IsJSON() {
+		formatted, err := formatter.FormatJSON(payloads)
+		if err != nil {
+			return fmt.Errorf("failed to format output: %w", err)
+		}
+		fmt.Fprint(out, formatted)
+		return nil
+	}
+	for _, p := range payloads {
+		fmt.Fprintf(out, "▸ %s: %s\n", p.Mutation, p.Description)
+		if tamperOutDir != "" {
+			fmt.Fprintf(out, "  %s\n\n", filepath.Join(tamperOutDir, p.Mutation+".xml"))
+			continue
+		}
+		fmt.Fprintf(out, "%s\n\n", p.SAMLResponse)
+	}
+	return nil
+}
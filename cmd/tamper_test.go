@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func resetTamperFlags() {
+	tamperFile = ""
+	tamperIndex = 0
+	tamperMutations = nil
+	tamperNameID = "admin@example.com"
+	tamperExtend = 365 * 24 * time.Hour
+	tamperOutDir = ""
+	tamperList = false
+	outputFormat = "pretty"
+}
+
+func TestTamperCmd_AllMutations(t *testing.T) {
+	defer resetTamperFlags()
+
+	path := filepath.Join("..", "testdata", "fixtures", "signed", "onelogin_response.xml")
+	output, err := executeCommand(rootCmd, "tamper", "-f", path, "-o", "json")
+	require.NoError(t, err)
+
+	var payloads []tamperPayload
+	require.NoError(t, json.Unmarshal([]byte(output[strings.Index(output, "["):]), &payloads))
+	var names []string
+	for _, p := range payloads {
+		names = append(names, p.Mutation)
+		decoded, err := base64.StdEncoding.DecodeString(p.SAMLResponse)
+		require.NoError(t, err)
+		assert.Equal(t, p.XML, string(decoded))
+	}
+	assert.Contains(t, names, saml.MutationStripSignature)
+	assert.Contains(t, names, saml.MutationAlgNone)
+}
+
+func TestTamperCmd_OutDir(t *testing.T) {
+	defer resetTamperFlags()
+
+	dir := t.TempDir()
+	path := filepath.Join("..", "testdata", "fixtures", "signed", "onelogin_response.xml")
+	output, err := executeCommand(rootCmd, "tamper", "-f", path, "--mutation", "nameid", "--nameid", "mallory", "--out-dir", dir)
+	require.NoError(t, err)
+	assert.Contains(t, output, "▸ nameid: ")
+
+	data, err := os.ReadFile(filepath.Join(dir, "nameid.xml"))
+	require.NoError(t, err)
+	info, err := saml.NewParser().Parse(data)
+	require.NoError(t, err)
+	assert.Equal(t, "mallory", info.Assertion.Subject.NameID)
+}
+
+func TestTamperCmd_Errors(t *testing.T) {
+	defer resetTamperFlags()
+
+	_, err := executeCommand(rootCmd, "tamper", "--mutation", "bogus")
+	assert.ErrorContains(t, err, `unknown mutation "bogus"`)
+	resetTamperFlags()
+
+	path := filepath.Join("..", "testdata", "fixtures", "assertions", "response.xml")
+	_, err = executeCommand(rootCmd, "tamper", "-f", path, "--mutation", "strip-signature")
+	assert.ErrorIs(t, err, saml.ErrNotApplicable)
+}
+
+func TestTamperCmd_List(t *testing.T) {
+	defer resetTamperFlags()
+
+	output, err := executeCommand(rootCmd, "tamper", "--list")
+	require.NoError(t, err)
+	assert.Contains(t, output, "xsw ")
+	assert.Contains(t, output, "extend-validity ")
+}
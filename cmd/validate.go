@@ -0,0 +1,421 @@
+package cmd
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/gliwka/SAMLurai/internal/batch"
+	"github.com/gliwka/SAMLurai/internal/inspect"
+	"github.com/gliwka/SAMLurai/internal/output"
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/spf13/cobra"
+)
+
+var (
+	validateBatch  string
+	validateFile   string
+	validateKey    string
+	validateNow    string
+	validateExpect string
+)
+
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Validate captured SAML messages against the expected SSO configuration",
+	Long: `Validate the SAML messages of several captures against the issuers,
+audiences, keys and metadata they are expected to use, e.g. as a regression
+check of SSO configurations in a CI pipeline, or test a single capture
+against the contract of an SP.
+
+The captures and expectations are listed in a YAML manifest given with
+--batch; paths are relative to the manifest, and defaults apply to every
+case that does not set them itself:
+
+  defaults:
+    audience: https://sp.example.com
+    metadata: idp-metadata.xml    # a file or an http(s) URL
+    expect: sp-contract.yaml      # expectations, see below
+    lint: true
+  cases:
+    - name: Okta production
+      input: captures/okta.har    # XML, base64, HAR or SAML-tracer export
+      issuer: http://www.okta.com/exk1fcia6d6EMsf331d8
+      destination: https://sp.example.com/acs
+      key: sp-key.pem             # to decrypt encrypted assertions
+    - name: ADFS staging
+      input: captures/adfs.xml
+      issuer: http://adfs.example.com/adfs/services/trust
+      metadata: adfs-metadata.xml
+      metadata_cert: federation.pem
+      now: 2024-01-15T10:30:00Z   # evaluate validity at capture time
+      clock_skew: 2m
+      audit: true
+      min_severity: medium
+
+Each case runs these checks on every message in its input:
+  - parse: the input holds SAML messages that can be decrypted and parsed
+  - issuer: with issuer, the message or its assertion has that Issuer
+  - conditions: none of the warnings samlurai inspect shows, such as a
+    failed status, an expired validity window, a missing signature, or
+    another audience or destination than expected
+  - signature: with cert or metadata, the message or its assertions are
+    signed by that certificate or a signing certificate of the metadata
+  - expect: with expect, the assertions meet the expectations
+  - lint: with lint: true, the lint rules (see samlurai lint)
+  - audit: with audit: true, the audit checks at or above min_severity
+    (see samlurai audit)
+
+Expectations codify the contract of an SP: what the assertions it receives
+must contain. Formats, classes and algorithms are given as URIs or by the
+last segment of their URI; attribute names match the Name or FriendlyName,
+and every value of an attribute must match its value pattern:
+
+  issuer: https://idp.example.com
+  audience: https://sp.example.com
+  name_id_format: emailAddress
+  authn_context: PasswordProtectedTransport
+  signature_algorithm: rsa-sha256
+  attributes:
+    - name: mail
+      value: '^[^@]+@example\.com$'
+    - name: groups
+      optional: true              # only checked if present
+
+--expect applies expectations to every case without its own. With -f
+instead of --batch, a single capture is validated, e.g.
+samlurai validate -f capture.har --expect sp-contract.yaml.
+
+With -o junit the outcome is written as JUnit XML, with a test suite per
+case and a test case per check; with -o sarif as a SARIF 2.1.0 log of the
+failures, e.g. for GitHub code scanning. The command exits with an error
+if any check fails.
+
+Examples:
+  # Validate all captures listed in the manifest
+  samlurai validate --batch sso.yaml
+
+  # Test a capture against the SP's contract
+  samlurai validate -f capture.har --expect sp-contract.yaml -k sp-key.pem
+
+  # JUnit report for the CI test results view
+  samlurai validate --batch sso.yaml -o junit > samlurai.xml
+
+  # SARIF report for code scanning
+  samlurai validate --batch sso.yaml -o sarif > samlurai.sarif`,
+	Args: cobra.NoArgs,
+	RunE: runValidate,
+}
+
+func init() {
+	rootCmd.AddCommand(validateCmd)
+
+	validateCmd.Flags().StringVar(&validateBatch, "batch", "", "YAML manifest of the captures to validate")
+	validateCmd.Flags().StringVarP(&validateFile, "file", "f", "", "Validate a single capture instead of a manifest (XML, base64, HAR or SAML-tracer export)")
+	validateCmd.Flags().StringVarP(&validateKey, "key", "k", "", "Path to private key for decryption (PEM format), with -f")
+	validateCmd.Flags().StringVar(&validateNow, "now", "", "Evaluate validity at this time (RFC 3339), with -f")
+	validateCmd.Flags().StringVar(&validateExpect, "expect", "", "YAML expectations the assertions must meet, for cases without their own")
+	_ = validateCmd.MarkFlagFilename("batch", "yaml", "yml")
+	_ = validateCmd.MarkFlagFilename("expect", "yaml", "yml")
+}
+
+func runValidate(cmd *cobra.Command, args []string) error {
+	manifest, err := loadValidateManifest()
+	if err != nil {
+		return err
+	}
+
+	report := batch.Report{Cases: []batch.CaseResult{}}
+	for _, c := range manifest.Cases {
+		result, err := validateCase(cmd, c)
+		if err != nil {
+			return fmt.Errorf("%s: %w", c.Name, err)
+		}
+		report.Cases = append(report.Cases, result)
+	}
+
+	w := cmd.OutOrStdout()
+	switch formatter := output.NewFormatter(outputFormat); {
+	case outputFormat == "junit":
+		err = report.WriteJUnit(w)
+	case outputFormat == "sarif":
+		err = report.WriteSARIF(w, version)
+	case formatter.IsJSON():
+		var formatted string
+		if formatted, err = formatter.FormatJSON(report); err == nil {
+			fmt.Fprint(w, formatted)
+		}
+	default:
+		printValidateReport(w, report)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to format output: %w", err)
+	}
+
+	checks, failed, _ := report.Counts()
+	if failed > 0 {
+		return withExitCode(ExitFindings, fmt.Errorf("%d of %d check(s) failed", failed, checks))
+	}
+	return nil
+}
+
+// loadValidateManifest loads the manifest of --batch, or makes one of the
+// capture given with -f
+func loadValidateManifest() (*batch.Manifest, error) {
+	switch {
+	case validateBatch != "" && validateFile != "":
+		return nil, errors.New("only one of --batch and -f may be given")
+	case validateBatch == "" && validateFile == "":
+		return nil, errors.New("a manifest (--batch) or a capture (-f) is required")
+	}
+	if validateBatch != "" {
+		if validateKey != "" || validateNow != "" {
+			return nil, errors.New("-k and --now apply to -f; set key and now in the manifest")
+		}
+		manifest, err := batch.LoadManifest(validateBatch)
+		if err != nil {
+			return nil, err
+		}
+		for i := range manifest.Cases {
+			if manifest.Cases[i].Expect == "" {
+				manifest.Cases[i].Expect = validateExpect
+			}
+		}
+		return manifest, nil
+	}
+	return batch.NewManifest(batch.Case{
+		Input:    validateFile,
+		Settings: batch.Settings{Key: validateKey, Now: validateNow, Expect: validateExpect},
+	})
+}
+
+// validateCase runs the checks of a case. Problems with the input or the
+// expected certificates fail a check; only plugin failures are returned.
+func validateCase(cmd *cobra.Command, c batch.Case) (batch.CaseResult, error) {
+	result := batch.CaseResult{Name: c.Name, Input: c.Input}
+	parse := batch.CheckResult{Name: batch.CheckParse}
+	fail := func(check *batch.CheckResult, rule, level string, index int, message string) {
+		check.Failures = append(check.Failures, batch.Failure{Rule: rule, Level: level, Index: index, Message: message})
+	}
+
+	// The manifest has been validated, so the settings parse
+	skew, _ := c.ClockSkewDuration()
+	now, _ := c.NowTime()
+
+	var messages []inspect.Message
+	data, err := readInputFile(c.Input)
+	if err != nil {
+		err = fmt.Errorf("failed to read file: %w", err)
+	} else {
+		var run *inspect.Result
+		run, err = inspect.Run(cmd.Context(), inspect.Request{
+			Input:       strings.TrimSpace(string(data)),
+			Filename:    c.Input,
+			KeyPath:     c.Key,
+			Audience:    c.Audience,
+			Destination: c.Destination,
+			ClockSkew:   skew,
+			Now:         now,
+		})
+		if err == nil {
+			messages = run.Messages
+		}
+	}
+	switch {
+	case err != nil:
+		fail(&parse, batch.CheckParse, batch.LevelError, 0, err.Error())
+	case len(messages) == 0:
+		fail(&parse, batch.CheckParse, batch.LevelError, 0, "no SAML messages found")
+	}
+	result.Messages = len(messages)
+
+	// Messages that failed to parse are reported once, by the parse check
+	var parsed []inspect.Message
+	for _, msg := range messages {
+		if msg.Err != nil {
+			fail(&parse, batch.CheckParse, batch.LevelError, msg.Index(), msg.Err.Error())
+		} else {
+			parsed = append(parsed, msg)
+		}
+	}
+	result.Checks = append(result.Checks, parse)
+
+	skip := func(check batch.CheckResult) batch.CheckResult {
+		if len(parsed) == 0 {
+			check.Skipped = "no message could be parsed"
+		}
+		return check
+	}
+
+	if c.Issuer != "" {
+		check := skip(batch.CheckResult{Name: batch.CheckIssuer})
+		for _, msg := range parsed {
+			if issuer := messageIssuer(msg.Info); issuer != c.Issuer {
+				fail(&check, batch.CheckIssuer, batch.LevelError, msg.Index(), fmt.Sprintf("Issuer is %q, expected %q", issuer, c.Issuer))
+			}
+		}
+		result.Checks = append(result.Checks, check)
+	}
+
+	conditions := skip(batch.CheckResult{Name: batch.CheckConditions})
+	for _, msg := range parsed {
+		for _, warning := range msg.Warnings() {
+			fail(&conditions, batch.CheckConditions, batch.LevelError, msg.Index(), warning)
+		}
+	}
+	result.Checks = append(result.Checks, conditions)
+
+	var trust *metadataTrust
+	if c.Cert != "" || c.Metadata != "" {
+		check := skip(batch.CheckResult{Name: batch.CheckSignature})
+		var certs []*x509.Certificate
+		if c.Metadata != "" {
+			trust, err = loadMetadataTrust(cmd, c.Metadata, c.MetadataCert)
+		} else {
+			var cert *x509.Certificate
+			if cert, err = saml.LoadCertificate(c.Cert); err == nil {
+				certs = append(certs, cert)
+			}
+		}
+		if err != nil {
+			fail(&check, batch.CheckSignature, batch.LevelError, 0, err.Error())
+			trust = nil
+		} else {
+			for _, msg := range parsed {
+				validateSignature(cmd, &check, msg, certs, trust)
+			}
+		}
+		result.Checks = append(result.Checks, check)
+	}
+
+	if c.Expect != "" {
+		check := skip(batch.CheckResult{Name: batch.CheckExpect})
+		if expectations, err := batch.LoadExpectations(c.Expect); err != nil {
+			fail(&check, batch.CheckExpect, batch.LevelError, 0, err.Error())
+		} else {
+			checked := 0
+			for _, msg := range parsed {
+				if !batch.CarriesAssertion(msg.Info) {
+					continue
+				}
+				checked++
+				for _, f := range expectations.Check(msg.Info) {
+					fail(&check, f.Rule, f.Level, msg.Index(), f.Message)
+				}
+			}
+			if checked == 0 && len(parsed) > 0 {
+				fail(&check, batch.ExpectAssertion, batch.LevelError, 0, "no Response or assertion to check")
+			}
+		}
+		result.Checks = append(result.Checks, check)
+	}
+
+	if c.LintEnabled() {
+		check := skip(batch.CheckResult{Name: batch.CheckLint})
+		for _, msg := range parsed {
+			issues, err := saml.Lint(msg.XML, saml.LintOptions{})
+			if err != nil {
+				fail(&check, batch.CheckLint, batch.LevelError, msg.Index(), err.Error())
+				continue
+			}
+			pluginIssues, err := lintWithPlugins(cmd.Context(), msg.XML, nil)
+			if err != nil {
+				return result, err
+			}
+			for _, issue := range append(issues, pluginIssues...) {
+				fail(&check, "lint/"+issue.Rule, issue.Severity, msg.Index(), issue.Message)
+			}
+		}
+		result.Checks = append(result.Checks, check)
+	}
+
+	if c.AuditEnabled() {
+		check := skip(batch.CheckResult{Name: batch.CheckAudit})
+		minRank := severityRank[c.MinSeverity]
+		for _, msg := range parsed {
+			opts := saml.DefaultAuditOptions()
+			if !now.IsZero() {
+				opts.Now = now
+			}
+			opts.RedirectQuery = redirectQuery(msg)
+			if trust != nil {
+				opts.TrustedCertificates = trust.signingCerts(cmd, messageIssuer(msg.Info))
+				opts.Scopes = trust.permittedScopes(messageIssuer(msg.Info))
+			}
+			findings, err := saml.Audit(msg.XML, opts)
+			if err != nil {
+				fail(&check, batch.CheckAudit, batch.LevelError, msg.Index(), err.Error())
+				continue
+			}
+			pluginFindings, err := auditWithPlugins(cmd.Context(), msg.XML)
+			if err != nil {
+				return result, err
+			}
+			if msg.Replay != nil {
+				findings = append(findings, saml.AuditFinding{Check: saml.CheckReplay, Severity: saml.SeverityHigh, Message: msg.Replay.String()})
+			}
+			for _, f := range append(findings, pluginFindings...) {
+				if severityRank[f.Severity] < minRank {
+					continue
+				}
+				level := batch.LevelWarning
+				if f.Severity == saml.SeverityHigh {
+					level = batch.LevelError
+				}
+				fail(&check, "audit/"+f.Check, level, msg.Index(), f.Message)
+			}
+		}
+		result.Checks = append(result.Checks, check)
+	}
+	return result, nil
+}
+
+// validateSignature verifies the signatures of a message against certs,
+// or the signing certificates its issuer has in the metadata
+func validateSignature(cmd *cobra.Command, check *batch.CheckResult, msg inspect.Message, certs []*x509.Certificate, trust *metadataTrust) {
+	fail := func(message string) {
+		check.Failures = append(check.Failures, batch.Failure{Rule: batch.CheckSignature, Level: batch.LevelError, Index: msg.Index(), Message: message})
+	}
+	if trust != nil {
+		issuer := messageIssuer(msg.Info)
+		for _, cert := range trust.signingCerts(cmd, issuer) {
+			certs = append(certs, cert.Certificate)
+		}
+		if len(certs) == 0 {
+			fail(fmt.Sprintf("the metadata has no signing certificates for %q", issuer))
+			return
+		}
+	}
+	switch v := saml.VerifyMessage(msg.XML, certs); v.Verdict {
+	case saml.VerdictUnsigned:
+		fail("the message is not signed")
+	case saml.VerdictSigFail:
+		fail(v.Err.Error())
+	}
+}
+
+func printValidateReport(w io.Writer, report batch.Report) {
+	for _, c := range report.Cases {
+		mark := "✓"
+		if c.Failed() > 0 {
+			mark = "✗"
+		}
+		fmt.Fprintf(w, "%s %s (%s, %d message(s))\n", mark, c.Name, c.Input, c.Messages)
+		for _, check := range c.Checks {
+			switch {
+			case check.Failed():
+				fmt.Fprintf(w, "    ✗ %s\n", check.Name)
+				for _, f := range check.Failures {
+					fmt.Fprintf(w, "        [%s] %s: %s\n", strings.ToUpper(f.Level), f.Rule, f)
+				}
+			case check.Skipped != "":
+				fmt.Fprintf(w, "    - %s: skipped, %s\n", check.Name, check.Skipped)
+			}
+		}
+	}
+
+	checks, failed, skipped := report.Counts()
+	fmt.Fprintf(w, "\nSummary: %d case(s), %d check(s), %d failed, %d skipped\n", len(report.Cases), checks, failed, skipped)
+}
@@ -0,0 +1,264 @@
+package cmd
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func resetValidateFlags() {
+	validateBatch = ""
+	validateFile = ""
+	validateKey = ""
+	validateNow = ""
+	validateExpect = ""
+	outputFormat = "pretty"
+}
+
+// writeValidateManifest writes a manifest next to symlinks of the fixtures
+// it names, so its relative paths resolve
+func writeValidateManifest(t *testing.T, manifest string) string {
+	dir := t.TempDir()
+	fixtures, err := filepath.Abs(filepath.Join("..", "testdata", "fixtures"))
+	require.NoError(t, err)
+	require.NoError(t, os.Symlink(fixtures, filepath.Join(dir, "fixtures")))
+	path := filepath.Join(dir, "sso.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(manifest), 0644))
+	return path
+}
+
+const validatePassingManifest = `defaults:
+  issuer: https://app.onelogin.com/saml/metadata/503983
+  now: 2016-01-05T17:54:00Z
+cases:
+  - name: OneLogin
+    input: fixtures/signed/onelogin_response.xml
+    audience: https://29ee6d2e.ngrok.io/saml/metadata
+    destination: https://29ee6d2e.ngrok.io/saml/acs
+  - name: OneLogin signature
+    input: fixtures/signed/onelogin_response.xml
+    cert: fixtures/signed/onelogin_cert.pem
+`
+
+const validateFailingManifest = `defaults:
+  now: 2024-01-15T10:30:00Z
+cases:
+  - name: Wrong issuer
+    input: fixtures/assertions/response.xml
+    issuer: https://other-idp.example.com
+    lint: true
+  - name: Unsigned
+    input: fixtures/assertions/response.xml
+    cert: fixtures/signed/onelogin_cert.pem
+  - name: Missing capture
+    input: fixtures/missing.har
+`
+
+func TestValidateCmd_Passes(t *testing.T) {
+	resetValidateFlags()
+	path := writeValidateManifest(t, validatePassingManifest)
+
+	output, err := executeCommand(rootCmd, "validate", "--batch", path)
+	require.NoError(t, err, output)
+	assert.Contains(t, output, "✓ OneLogin (")
+	assert.Contains(t, output, "✓ OneLogin signature (")
+	assert.Contains(t, output, "Summary: 2 case(s), 7 check(s), 0 failed, 0 skipped")
+}
+
+func TestValidateCmd_Fails(t *testing.T) {
+	resetValidateFlags()
+	path := writeValidateManifest(t, validateFailingManifest)
+
+	output, err := executeCommand(rootCmd, "validate", "--batch", path)
+	require.Error(t, err)
+	assert.Equal(t, ExitFindings, ExitCode(err))
+	assert.EqualError(t, err, "6 of 9 check(s) failed")
+	assert.Contains(t, output, "✗ Wrong issuer (")
+	assert.Contains(t, output, `[ERROR] issuer: message 1: Issuer is "https://idp.example.com", expected "https://other-idp.example.com"`)
+	assert.Contains(t, output, "[ERROR] lint/")
+	assert.Contains(t, output, "[ERROR] signature: message 1: the message is not signed")
+	assert.Contains(t, output, "[ERROR] parse: failed to read file")
+	assert.Contains(t, output, "- conditions: skipped, no message could be parsed")
+}
+
+// writeWrappedResponse writes a Response whose signed assertion is followed
+// by an unsigned one with another NameID, and the signing certificate
+func writeWrappedResponse(t *testing.T) (string, string) {
+	t.Helper()
+	key, cert, err := saml.NewSelfSignedKey("idp.example.com")
+	require.NoError(t, err)
+	doc, err := saml.BuildResponse(saml.ResponseOptions{
+		Issuer:      "https://idp.example.com",
+		Destination: "https://sp.example.com/acs",
+		Audience:    "https://sp.example.com",
+		NameID:      "alice@example.com",
+	}, time.Now())
+	require.NoError(t, err)
+	signed := saml.ResponseAssertion(doc.Root())
+	require.NoError(t, saml.SignEnveloped(signed, key, cert, saml.SigAlgRSASHA256))
+
+	forged := signed.Copy()
+	forged.RemoveChild(forged.SelectElement("Signature"))
+	forged.CreateAttr("ID", "_forged")
+	forged.FindElement(".//NameID").SetText("eve@example.com")
+	doc.Root().AddChild(forged)
+	xmlData, err := doc.WriteToBytes()
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	responsePath := filepath.Join(dir, "wrapped.xml")
+	require.NoError(t, os.WriteFile(responsePath, xmlData, 0644))
+	certPath := filepath.Join(dir, "idp.pem")
+	require.NoError(t, os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}), 0644))
+	return responsePath, certPath
+}
+
+func TestValidateCmd_SignatureWrapping(t *testing.T) {
+	resetValidateFlags()
+	responsePath, certPath := writeWrappedResponse(t)
+	path := writeValidateManifest(t, `cases:
+  - name: Wrapped
+    input: `+responsePath+`
+    cert: `+certPath+`
+`)
+
+	output, err := executeCommand(rootCmd, "validate", "--batch", path)
+	require.Error(t, err)
+	assert.Equal(t, ExitFindings, ExitCode(err))
+	assert.Contains(t, output, "✗ Wrapped (")
+	assert.Contains(t, output, "[ERROR] signature: message 1: assertion is neither signed nor covered by a signed message")
+}
+
+func TestValidateCmd_JUnit(t *testing.T) {
+	resetValidateFlags()
+	path := writeValidateManifest(t, validateFailingManifest)
+
+	output, err := executeCommand(rootCmd, "validate", "--batch", path, "-o", "junit")
+	require.Error(t, err)
+
+	var suites struct {
+		Tests    int `xml:"tests,attr"`
+		Failures int `xml:"failures,attr"`
+		Suites   []struct {
+			Name  string `xml:"name,attr"`
+			Cases []struct {
+				Name    string    `xml:"name,attr"`
+				Failure *struct{} `xml:"failure"`
+			} `xml:"testcase"`
+		} `xml:"testsuite"`
+	}
+	require.NoError(t, xml.Unmarshal([]byte(output), &suites))
+	assert.Equal(t, 9, suites.Tests)
+	assert.Equal(t, 6, suites.Failures)
+	require.Len(t, suites.Suites, 3)
+	assert.Equal(t, "Unsigned", suites.Suites[1].Name)
+	assert.Equal(t, "signature", suites.Suites[1].Cases[2].Name)
+	assert.NotNil(t, suites.Suites[1].Cases[2].Failure)
+}
+
+func TestValidateCmd_SARIF(t *testing.T) {
+	resetValidateFlags()
+	path := writeValidateManifest(t, validateFailingManifest)
+
+	output, err := executeCommand(rootCmd, "validate", "--batch", path, "-o", "sarif")
+	require.Error(t, err)
+
+	var log struct {
+		Version string `json:"version"`
+		Runs    []struct {
+			Results []struct {
+				RuleID    string `json:"ruleId"`
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct {
+							URI string `json:"uri"`
+						} `json:"artifactLocation"`
+					} `json:"physicalLocation"`
+				} `json:"locations"`
+			} `json:"results"`
+		} `json:"runs"`
+	}
+	require.NoError(t, json.NewDecoder(strings.NewReader(output)).Decode(&log))
+	assert.Equal(t, "2.1.0", log.Version)
+	require.Len(t, log.Runs, 1)
+	results := log.Runs[0].Results
+	require.NotEmpty(t, results)
+	assert.Equal(t, "issuer", results[0].RuleID)
+	assert.True(t, strings.HasSuffix(results[0].Locations[0].PhysicalLocation.ArtifactLocation.URI, "fixtures/assertions/response.xml"))
+	assert.Equal(t, "parse", results[len(results)-1].RuleID)
+}
+
+func TestValidateCmd_InvalidManifest(t *testing.T) {
+	resetValidateFlags()
+	path := createTempFile(t, "cases: []\n")
+	defer os.Remove(path)
+
+	_, err := executeCommand(rootCmd, "validate", "--batch", path)
+	assert.EqualError(t, err, "manifest has no cases")
+}
+
+const validateExpectations = `issuer: https://app.onelogin.com/saml/metadata/503983
+name_id_format: emailAddress
+signature_algorithm: rsa-sha1
+attributes:
+  - name: User.email
+    value: '@kndr\.org$'
+`
+
+func TestValidateCmd_Expect(t *testing.T) {
+	resetValidateFlags()
+	expect := createTempFile(t, validateExpectations)
+	defer os.Remove(expect)
+
+	output, err := executeCommand(rootCmd, "validate", "-f", "../testdata/fixtures/signed/onelogin_response.xml",
+		"--expect", expect, "--now", "2016-01-05T17:54:00Z")
+	require.NoError(t, err, output)
+	assert.Contains(t, output, "✓ ../testdata/fixtures/signed/onelogin_response.xml (")
+	assert.Contains(t, output, "Summary: 1 case(s), 3 check(s), 0 failed, 0 skipped")
+}
+
+func TestValidateCmd_ExpectFails(t *testing.T) {
+	resetValidateFlags()
+	expect := createTempFile(t, "name_id_format: persistent\nattributes:\n  - name: groups\n")
+	defer os.Remove(expect)
+	path := writeValidateManifest(t, validatePassingManifest)
+
+	output, err := executeCommand(rootCmd, "validate", "--batch", path, "--expect", expect)
+	require.Error(t, err)
+	assert.EqualError(t, err, "2 of 9 check(s) failed")
+	assert.Contains(t, output, "[ERROR] expect/name_id_format: message 1: NameID format is urn:oasis:names:tc:SAML:1.1:nameid-format:emailAddress, expected persistent")
+	assert.Contains(t, output, "[ERROR] expect/attribute: message 1: attribute groups is missing")
+}
+
+func TestValidateCmd_ExpectNoAssertion(t *testing.T) {
+	resetValidateFlags()
+	expect := createTempFile(t, validateExpectations)
+	defer os.Remove(expect)
+
+	output, err := executeCommand(rootCmd, "validate", "-f", "../testdata/fixtures/assertions/request.xml", "--expect", expect)
+	require.Error(t, err)
+	assert.Contains(t, output, "[ERROR] expect/assertion: no Response or assertion to check")
+}
+
+func TestValidateCmd_Inputs(t *testing.T) {
+	resetValidateFlags()
+	_, err := executeCommand(rootCmd, "validate")
+	assert.EqualError(t, err, "a manifest (--batch) or a capture (-f) is required")
+
+	resetValidateFlags()
+	_, err = executeCommand(rootCmd, "validate", "--batch", "sso.yaml", "-f", "capture.har")
+	assert.EqualError(t, err, "only one of --batch and -f may be given")
+
+	resetValidateFlags()
+	_, err = executeCommand(rootCmd, "validate", "--batch", "sso.yaml", "-k", "sp-key.pem")
+	assert.EqualError(t, err, "-k and --now apply to -f; set key and now in the manifest")
+}
@@ -0,0 +1,261 @@
+package batch
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"gopkg.in/yaml.v3"
+)
+
+// Expectations are the contract an SP has with its IdPs: what the
+// assertions it receives must contain, e.g.
+//
+//	issuer: https://idp.example.com
+//	audience: https://sp.example.com
+//	name_id_format: emailAddress
+//	authn_context: PasswordProtectedTransport
+//	signature_algorithm: rsa-sha256
+//	attributes:
+//	  - name: mail
+//	    value: '^[^@]+@example\.com$'
+//	  - name: groups
+//	    optional: true
+//
+// Formats, classes and algorithms are given as URIs or by the last
+// segment of their URI. Issuer and audience are compared as URLs, ignoring
+// a trailing slash, default ports and host case.
+type Expectations struct {
+	Issuer             string                 `yaml:"issuer"`
+	Audience           string                 `yaml:"audience"`
+	NameIDFormat       string                 `yaml:"name_id_format"`
+	AuthnContext       string                 `yaml:"authn_context"`
+	SignatureAlgorithm string                 `yaml:"signature_algorithm"`
+	Attributes         []AttributeExpectation `yaml:"attributes"`
+}
+
+// AttributeExpectation is an attribute the assertion must carry
+type AttributeExpectation struct {
+	// Name matches the Name or the FriendlyName of the attribute
+	Name string `yaml:"name"`
+
+	// Value is a regular expression every value must match
+	Value string `yaml:"value"`
+
+	// Optional attributes are only checked if present. A required
+	// attribute must have a value.
+	Optional bool `yaml:"optional"`
+
+	value *regexp.Regexp
+}
+
+// Rules of the failures Check reports
+const (
+	ExpectIssuer             = "expect/issuer"
+	ExpectAudience           = "expect/audience"
+	ExpectNameIDFormat       = "expect/name_id_format"
+	ExpectAuthnContext       = "expect/authn_context"
+	ExpectSignatureAlgorithm = "expect/signature_algorithm"
+	ExpectAttribute          = "expect/attribute"
+	ExpectAssertion          = "expect/assertion"
+)
+
+// LoadExpectations reads and validates an expectations file
+func LoadExpectations(path string) (*Expectations, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read expectations: %w", err)
+	}
+	return ParseExpectations(data)
+}
+
+// ParseExpectations parses and validates expectations
+func ParseExpectations(data []byte) (*Expectations, error) {
+	var e Expectations
+	if err := yaml.Unmarshal(data, &e); err != nil {
+		return nil, fmt.Errorf("failed to parse expectations: %w", err)
+	}
+	if e.Issuer == "" && e.Audience == "" && e.NameIDFormat == "" && e.AuthnContext == "" && e.SignatureAlgorithm == "" && len(e.Attributes) == 0 {
+		return nil, errors.New("expectations are empty")
+	}
+	for i := range e.Attributes {
+		a := &e.Attributes[i]
+		if a.Name == "" {
+			return nil, fmt.Errorf("attribute %d: name is required", i+1)
+		}
+		if a.Value == "" {
+			continue
+		}
+		value, err := regexp.Compile(a.Value)
+		if err != nil {
+			return nil, fmt.Errorf("attribute %s: invalid value pattern: %w", a.Name, err)
+		}
+		a.value = value
+	}
+	return &e, nil
+}
+
+// CarriesAssertion reports whether a message is a Response or an
+// assertion, whose expectations Check checks
+func CarriesAssertion(info *saml.SAMLInfo) bool {
+	return strings.HasPrefix(info.Type, "Response") || info.Type == "Assertion"
+}
+
+// Check returns the expectations a message does not meet. Messages other
+// than Responses and assertions are not checked; a Response must carry a
+// decrypted assertion.
+func (e *Expectations) Check(info *saml.SAMLInfo) []Failure {
+	var failures []Failure
+	fail := func(rule, format string, args ...any) {
+		failures = append(failures, Failure{Rule: rule, Level: LevelError, Message: fmt.Sprintf(format, args...)})
+	}
+	if !CarriesAssertion(info) {
+		return nil
+	}
+
+	assertion := info
+	if info.Type != "Assertion" {
+		assertion = info.Assertion
+		switch {
+		case assertion == nil && info.Type == "Response (Encrypted)":
+			fail(ExpectAssertion, "the assertion is encrypted; give the key to decrypt it")
+			return failures
+		case assertion == nil:
+			fail(ExpectAssertion, "the response carries no assertion")
+			return failures
+		}
+	}
+
+	if e.Issuer != "" {
+		issuer := assertion.Issuer
+		if issuer == "" {
+			issuer = info.Issuer
+		}
+		if !saml.DefaultURLNormalization().Equal(issuer, e.Issuer) {
+			fail(ExpectIssuer, "Issuer is %q, expected %q", issuer, e.Issuer)
+		}
+	}
+
+	if e.Audience != "" {
+		var audiences []string
+		if assertion.Conditions != nil {
+			audiences = assertion.Conditions.AudienceRestriction
+		}
+		found := false
+		for _, audience := range audiences {
+			found = found || saml.DefaultURLNormalization().Equal(audience, e.Audience)
+		}
+		if !found {
+			fail(ExpectAudience, "audience %q is not among %s", e.Audience, quoteList(audiences))
+		}
+	}
+
+	if e.NameIDFormat != "" {
+		var format string
+		if assertion.Subject != nil {
+			format = assertion.Subject.NameIDFormat
+		}
+		if !termMatches(e.NameIDFormat, format) {
+			fail(ExpectNameIDFormat, "NameID format is %s, expected %s", orNone(format), e.NameIDFormat)
+		}
+	}
+
+	if e.AuthnContext != "" {
+		var class string
+		if assertion.AuthnStatement != nil {
+			class = assertion.AuthnStatement.AuthnContextClassRef
+		}
+		if !termMatches(e.AuthnContext, class) {
+			fail(ExpectAuthnContext, "AuthnContext class is %s, expected %s", orNone(class), e.AuthnContext)
+		}
+	}
+
+	if e.SignatureAlgorithm != "" {
+		e.checkSignatures(info, assertion, fail)
+	}
+
+	for _, a := range e.Attributes {
+		attribute := findAttribute(assertion.Attributes, a.Name)
+		if attribute == nil {
+			if !a.Optional {
+				fail(ExpectAttribute, "attribute %s is missing", a.Name)
+			}
+			continue
+		}
+		if len(attribute.Values) == 0 && !a.Optional {
+			fail(ExpectAttribute, "attribute %s has no values", a.Name)
+			continue
+		}
+		if a.value == nil {
+			continue
+		}
+		for _, value := range attribute.Values {
+			if !a.value.MatchString(value) {
+				fail(ExpectAttribute, "attribute %s value %q does not match %s", a.Name, value, a.Value)
+			}
+		}
+	}
+	return failures
+}
+
+// checkSignatures checks that every signature of the message and its
+// assertion uses the expected algorithm, and that there is one
+func (e *Expectations) checkSignatures(info, assertion *saml.SAMLInfo, fail func(string, string, ...any)) {
+	signed := false
+	for _, signature := range []*saml.SignatureInfo{info.Signature, assertion.Signature} {
+		if signature == nil || !signature.Signed {
+			continue
+		}
+		signed = true
+		if !termMatches(e.SignatureAlgorithm, signature.SignatureMethod) {
+			fail(ExpectSignatureAlgorithm, "signature algorithm is %s, expected %s", orNone(signature.SignatureMethod), e.SignatureAlgorithm)
+			return
+		}
+	}
+	if !signed {
+		fail(ExpectSignatureAlgorithm, "the message is not signed, expected a %s signature", e.SignatureAlgorithm)
+	}
+}
+
+// findAttribute returns the attribute with the name or friendly name
+func findAttribute(attributes []saml.Attribute, name string) *saml.Attribute {
+	for i, a := range attributes {
+		if a.Name == name || a.FriendlyName == name {
+			return &attributes[i]
+		}
+	}
+	return nil
+}
+
+// termMatches reports whether a URI is the expected one, or ends in the
+// expected short name after its last ':', '#' or '/'
+func termMatches(expected, uri string) bool {
+	if expected == uri {
+		return true
+	}
+	if uri == "" || strings.ContainsAny(expected, ":#/") {
+		return false
+	}
+	return strings.EqualFold(uri[strings.LastIndexAny(uri, ":#/")+1:], expected)
+}
+
+func quoteList(values []string) string {
+	if len(values) == 0 {
+		return "none"
+	}
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = fmt.Sprintf("%q", v)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return s
+}
@@ -0,0 +1,123 @@
+package batch
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func oneLoginResponse(t *testing.T) *saml.SAMLInfo {
+	data, err := os.ReadFile(filepath.Join("..", "..", "testdata", "fixtures", "signed", "onelogin_response.xml"))
+	require.NoError(t, err)
+	info, err := saml.NewParser().Parse(data)
+	require.NoError(t, err)
+	return info
+}
+
+func TestExpectations_Check(t *testing.T) {
+	e, err := ParseExpectations([]byte(`issuer: https://app.onelogin.com/saml/metadata/503983
+audience: https://29ee6d2e.ngrok.io/saml/metadata
+name_id_format: emailAddress
+authn_context: urn:oasis:names:tc:SAML:2.0:ac:classes:PasswordProtectedTransport
+signature_algorithm: rsa-sha1
+attributes:
+  - name: User.email
+    value: '^[^@]+@kndr\.org$'
+  - name: department
+    optional: true
+`))
+	require.NoError(t, err)
+	assert.Empty(t, e.Check(oneLoginResponse(t)))
+}
+
+func TestExpectations_Check_Failures(t *testing.T) {
+	e, err := ParseExpectations([]byte(`issuer: https://idp.example.com
+audience: https://sp.example.com
+name_id_format: persistent
+authn_context: Kerberos
+signature_algorithm: rsa-sha256
+attributes:
+  - name: User.email
+    value: '@example\.com$'
+  - name: groups
+`))
+	require.NoError(t, err)
+
+	var got []string
+	for _, f := range e.Check(oneLoginResponse(t)) {
+		assert.Equal(t, LevelError, f.Level)
+		got = append(got, f.Rule+": "+f.Message)
+	}
+	assert.Equal(t, []string{
+		`expect/issuer: Issuer is "https://app.onelogin.com/saml/metadata/503983", expected "https://idp.example.com"`,
+		`expect/audience: audience "https://sp.example.com" is not among "https://29ee6d2e.ngrok.io/saml/metadata"`,
+		"expect/name_id_format: NameID format is urn:oasis:names:tc:SAML:1.1:nameid-format:emailAddress, expected persistent",
+		"expect/authn_context: AuthnContext class is urn:oasis:names:tc:SAML:2.0:ac:classes:PasswordProtectedTransport, expected Kerberos",
+		"expect/signature_algorithm: signature algorithm is http://www.w3.org/2000/09/xmldsig#rsa-sha1, expected rsa-sha256",
+		`expect/attribute: attribute User.email value "ross@kndr.org" does not match @example\.com$`,
+		"expect/attribute: attribute groups is missing",
+	}, got)
+}
+
+func TestExpectations_Check_Messages(t *testing.T) {
+	e := &Expectations{Issuer: "https://idp.example.com"}
+
+	assert.Nil(t, e.Check(&saml.SAMLInfo{Type: "AuthnRequest"}), "requests are not checked")
+	assert.False(t, CarriesAssertion(&saml.SAMLInfo{Type: "LogoutResponse"}))
+
+	failures := e.Check(&saml.SAMLInfo{Type: "Response (Encrypted)"})
+	require.Len(t, failures, 1)
+	assert.Equal(t, ExpectAssertion, failures[0].Rule)
+	assert.Contains(t, failures[0].Message, "give the key")
+
+	failures = e.Check(&saml.SAMLInfo{Type: "Response"})
+	require.Len(t, failures, 1)
+	assert.Equal(t, "the response carries no assertion", failures[0].Message)
+
+	assert.Empty(t, e.Check(&saml.SAMLInfo{Type: "Assertion", Issuer: "https://idp.example.com"}))
+	// Issuer and audience are compared as URLs
+	e = &Expectations{Issuer: "https://IdP.example.com/", Audience: "https://sp.example.com:443"}
+	assert.Empty(t, e.Check(&saml.SAMLInfo{Type: "Assertion", Issuer: "https://idp.example.com",
+		Conditions: &saml.Conditions{AudienceRestriction: []string{"https://sp.example.com/"}}}))
+
+	// A required attribute without values fails, an optional one does not
+	e = &Expectations{Attributes: []AttributeExpectation{{Name: "groups"}, {Name: "department", Optional: true}}}
+	failures = e.Check(&saml.SAMLInfo{Type: "Assertion", Attributes: []saml.Attribute{{Name: "groups"}, {Name: "department"}}})
+	require.Len(t, failures, 1)
+	assert.Equal(t, "attribute groups has no values", failures[0].Message)
+
+	failures = (&Expectations{SignatureAlgorithm: "rsa-sha256"}).Check(&saml.SAMLInfo{Type: "Assertion"})
+	require.Len(t, failures, 1)
+	assert.Equal(t, "the message is not signed, expected a rsa-sha256 signature", failures[0].Message)
+}
+
+func TestParseExpectations_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{"empty", "attributes: []\n", "expectations are empty"},
+		{"no name", "attributes:\n  - value: x\n", "attribute 1: name is required"},
+		{"pattern", "attributes:\n  - name: mail\n    value: '('\n", "attribute mail: invalid value pattern"},
+		{"yaml", "issuer: [", "failed to parse expectations"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseExpectations([]byte(tt.data))
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestTermMatches(t *testing.T) {
+	assert.True(t, termMatches("urn:a:b:emailAddress", "urn:a:b:emailAddress"))
+	assert.True(t, termMatches("emailaddress", "urn:a:b:emailAddress"))
+	assert.True(t, termMatches("rsa-sha256", "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"))
+	assert.False(t, termMatches("urn:x:emailAddress", "urn:a:b:emailAddress"))
+	assert.False(t, termMatches("persistent", ""))
+}
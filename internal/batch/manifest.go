@@ -0,0 +1,273 @@
+// Package batch describes the SSO configurations checked by validate
+// --batch and renders the outcome as JUnit XML or SARIF for CI pipelines.
+package batch
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Manifest lists the inputs to validate, e.g.
+//
+//	defaults:
+//	  metadata: idp-metadata.xml
+//	  audience: https://sp.example.com
+//	  expect: sp-contract.yaml
+//	  lint: true
+//	cases:
+//	  - name: Okta production
+//	    input: captures/okta.har
+//	    issuer: http://www.okta.com/exk1fcia6d6EMsf331d8
+//	    key: sp-key.pem
+//	  - name: ADFS staging
+//	    input: captures/adfs.xml
+//	    issuer: http://adfs.example.com/adfs/services/trust
+//	    metadata: adfs-metadata.xml
+//	    audit: true
+//
+// Paths are relative to the manifest.
+type Manifest struct {
+	// Defaults apply to every case that does not set them itself
+	Defaults Settings `yaml:"defaults"`
+
+	Cases []Case `yaml:"cases"`
+}
+
+// Case is one input and what is expected of its messages
+type Case struct {
+	Name string `yaml:"name"`
+
+	// Input is a SAML XML, base64 or HAR file
+	Input string `yaml:"input"`
+
+	Settings `yaml:",inline"`
+}
+
+// Settings are the expectations and keys of a case
+type Settings struct {
+	// Issuer is the expected Issuer of every message
+	Issuer string `yaml:"issuer"`
+
+	// Audience is the SP entity ID expected in audience restrictions
+	Audience string `yaml:"audience"`
+
+	// Destination is the URL messages are expected to be delivered to
+	Destination string `yaml:"destination"`
+
+	// Key is a PEM private key to decrypt encrypted assertions
+	Key string `yaml:"key"`
+
+	// Cert is a PEM certificate signatures must verify against
+	Cert string `yaml:"cert"`
+
+	// Metadata is IdP metadata (a file or an http(s) URL) whose signing
+	// certificates signatures must verify against
+	Metadata string `yaml:"metadata"`
+
+	// MetadataCert is a PEM certificate that must have signed the metadata
+	MetadataCert string `yaml:"metadata_cert"`
+
+	// Expect is a file of expectations the assertions must meet, see
+	// Expectations
+	Expect string `yaml:"expect"`
+
+	// ClockSkew is tolerated on either side of validity windows, e.g. 2m
+	ClockSkew string `yaml:"clock_skew"`
+
+	// Now is the RFC 3339 time validity is evaluated against, for
+	// captures that have expired since
+	Now string `yaml:"now"`
+
+	// Lint also checks the messages against the lint rules
+	Lint *bool `yaml:"lint"`
+
+	// Audit also runs the audit checks
+	Audit *bool `yaml:"audit"`
+
+	// MinSeverity is the least severe audit finding that fails a case:
+	// low (the default), medium or high
+	MinSeverity string `yaml:"min_severity"`
+}
+
+// LoadManifest reads and validates a manifest, resolving its paths
+// relative to the manifest's directory
+func LoadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	m, err := ParseManifest(data)
+	if err != nil {
+		return nil, err
+	}
+	m.resolve(filepath.Dir(path))
+	return m, nil
+}
+
+// ParseManifest parses and validates a manifest. Case settings are merged
+// with the defaults.
+func ParseManifest(data []byte) (*Manifest, error) {
+	var m Manifest
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// NewManifest creates a manifest of cases given on the command line, with
+// paths as they are
+func NewManifest(cases ...Case) (*Manifest, error) {
+	m := &Manifest{Cases: cases}
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// validate names the cases, merges their settings with the defaults and
+// validates them
+func (m *Manifest) validate() error {
+	if len(m.Cases) == 0 {
+		return errors.New("manifest has no cases")
+	}
+
+	names := map[string]bool{}
+	for i := range m.Cases {
+		c := &m.Cases[i]
+		if c.Input == "" {
+			return fmt.Errorf("case %d: input is required", i+1)
+		}
+		if c.Name == "" {
+			c.Name = c.Input
+		}
+		if names[c.Name] {
+			return fmt.Errorf("case %q is declared twice", c.Name)
+		}
+		names[c.Name] = true
+
+		c.Settings = c.Settings.merge(m.Defaults)
+		if err := c.validate(); err != nil {
+			return fmt.Errorf("%s: %w", c.Name, err)
+		}
+	}
+	return nil
+}
+
+// merge fills the settings that are not set from defaults
+func (s Settings) merge(defaults Settings) Settings {
+	pick := func(value, fallback string) string {
+		if value != "" {
+			return value
+		}
+		return fallback
+	}
+	s.Issuer = pick(s.Issuer, defaults.Issuer)
+	s.Audience = pick(s.Audience, defaults.Audience)
+	s.Destination = pick(s.Destination, defaults.Destination)
+	s.Key = pick(s.Key, defaults.Key)
+	s.Cert = pick(s.Cert, defaults.Cert)
+	s.Metadata = pick(s.Metadata, defaults.Metadata)
+	s.MetadataCert = pick(s.MetadataCert, defaults.MetadataCert)
+	s.Expect = pick(s.Expect, defaults.Expect)
+	s.ClockSkew = pick(s.ClockSkew, defaults.ClockSkew)
+	s.Now = pick(s.Now, defaults.Now)
+	s.MinSeverity = pick(s.MinSeverity, defaults.MinSeverity)
+	if s.Lint == nil {
+		s.Lint = defaults.Lint
+	}
+	if s.Audit == nil {
+		s.Audit = defaults.Audit
+	}
+	return s
+}
+
+func (c *Case) validate() error {
+	if _, err := c.ClockSkewDuration(); err != nil {
+		return err
+	}
+	if _, err := c.NowTime(); err != nil {
+		return err
+	}
+	switch c.MinSeverity {
+	case "", "low", "medium", "high":
+	default:
+		return fmt.Errorf("invalid min_severity %q: must be low, medium or high", c.MinSeverity)
+	}
+	if c.Cert != "" && c.Metadata != "" {
+		return errors.New("only one of cert and metadata may be given")
+	}
+	if c.MetadataCert != "" && c.Metadata == "" {
+		return errors.New("metadata_cert requires metadata")
+	}
+	return nil
+}
+
+// resolve makes the file paths of the cases relative to dir. Metadata
+// URLs are kept as they are.
+func (m *Manifest) resolve(dir string) {
+	join := func(path string) string {
+		if path == "" || filepath.IsAbs(path) {
+			return path
+		}
+		return filepath.Join(dir, path)
+	}
+	for i := range m.Cases {
+		c := &m.Cases[i]
+		c.Input = join(c.Input)
+		c.Key = join(c.Key)
+		c.Cert = join(c.Cert)
+		c.MetadataCert = join(c.MetadataCert)
+		c.Expect = join(c.Expect)
+		if !isURL(c.Metadata) {
+			c.Metadata = join(c.Metadata)
+		}
+	}
+}
+
+// ClockSkewDuration returns the parsed clock_skew, or zero
+func (s Settings) ClockSkewDuration() (time.Duration, error) {
+	if s.ClockSkew == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s.ClockSkew)
+	if err != nil {
+		return 0, fmt.Errorf("invalid clock_skew %q: %w", s.ClockSkew, err)
+	}
+	return d, nil
+}
+
+// NowTime returns the parsed now, or the zero time
+func (s Settings) NowTime() (time.Time, error) {
+	if s.Now == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, s.Now)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid now %q: expected an RFC 3339 time such as 2024-01-15T10:30:00Z", s.Now)
+	}
+	return t, nil
+}
+
+// LintEnabled reports whether lint: true is set
+func (s Settings) LintEnabled() bool {
+	return s.Lint != nil && *s.Lint
+}
+
+// AuditEnabled reports whether audit: true is set
+func (s Settings) AuditEnabled() bool {
+	return s.Audit != nil && *s.Audit
+}
+
+func isURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https")
+}
@@ -0,0 +1,100 @@
+package batch
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testManifest = `defaults:
+  audience: https://sp.example.com
+  metadata: idp-metadata.xml
+  clock_skew: 2m
+  expect: contract.yaml
+  lint: true
+cases:
+  - name: Okta production
+    input: captures/okta.har
+    issuer: http://www.okta.com/exk1
+    key: /etc/sp/sp-key.pem
+  - input: captures/adfs.xml
+    metadata: https://adfs.example.com/FederationMetadata.xml
+    lint: false
+    audit: true
+    min_severity: high
+    now: 2024-01-15T10:30:00Z
+`
+
+func TestLoadManifest(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "sso.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(testManifest), 0644))
+
+	m, err := LoadManifest(path)
+	require.NoError(t, err)
+	require.Len(t, m.Cases, 2)
+
+	okta := m.Cases[0]
+	assert.Equal(t, "Okta production", okta.Name)
+	assert.Equal(t, filepath.Join(dir, "captures", "okta.har"), okta.Input)
+	assert.Equal(t, "/etc/sp/sp-key.pem", okta.Key)
+	assert.Equal(t, filepath.Join(dir, "idp-metadata.xml"), okta.Metadata)
+	assert.Equal(t, "https://sp.example.com", okta.Audience)
+	assert.Equal(t, filepath.Join(dir, "contract.yaml"), okta.Expect)
+	assert.True(t, okta.LintEnabled())
+	assert.False(t, okta.AuditEnabled())
+	skew, err := okta.ClockSkewDuration()
+	require.NoError(t, err)
+	assert.Equal(t, 2*time.Minute, skew)
+
+	adfs := m.Cases[1]
+	assert.Equal(t, "captures/adfs.xml", adfs.Name, "unnamed cases are named after their input")
+	assert.Equal(t, "https://adfs.example.com/FederationMetadata.xml", adfs.Metadata)
+	assert.False(t, adfs.LintEnabled(), "cases override the defaults")
+	assert.True(t, adfs.AuditEnabled())
+	now, err := adfs.NowTime()
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC), now)
+
+	_, err = LoadManifest(filepath.Join(dir, "missing.yaml"))
+	assert.ErrorContains(t, err, "failed to read manifest")
+}
+
+func TestNewManifest(t *testing.T) {
+	m, err := NewManifest(Case{Input: "capture.har", Settings: Settings{Expect: "contract.yaml"}})
+	require.NoError(t, err)
+	require.Len(t, m.Cases, 1)
+	assert.Equal(t, "capture.har", m.Cases[0].Name)
+	assert.Equal(t, "contract.yaml", m.Cases[0].Expect, "paths are kept as they are")
+
+	_, err = NewManifest(Case{Input: "capture.har", Settings: Settings{Now: "today"}})
+	assert.ErrorContains(t, err, `capture.har: invalid now "today"`)
+}
+
+func TestParseManifest_Invalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		wantErr  string
+	}{
+		{"no cases", "defaults:\n  audience: x\n", "manifest has no cases"},
+		{"no input", "cases:\n  - name: a\n", "case 1: input is required"},
+		{"duplicate", "cases:\n  - input: a.xml\n  - input: a.xml\n", `case "a.xml" is declared twice`},
+		{"clock skew", "cases:\n  - input: a.xml\n    clock_skew: soon\n", `a.xml: invalid clock_skew "soon"`},
+		{"now", "defaults:\n  now: yesterday\ncases:\n  - input: a.xml\n", `a.xml: invalid now "yesterday"`},
+		{"severity", "cases:\n  - input: a.xml\n    min_severity: critical\n", `invalid min_severity "critical"`},
+		{"cert and metadata", "cases:\n  - input: a.xml\n    cert: a.pem\n    metadata: md.xml\n", "only one of cert and metadata may be given"},
+		{"metadata cert", "cases:\n  - input: a.xml\n    metadata_cert: fed.pem\n", "metadata_cert requires metadata"},
+		{"yaml", "cases: [", "failed to parse manifest"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseManifest([]byte(tt.manifest))
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
@@ -0,0 +1,317 @@
+package batch
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Checks performed for each case
+const (
+	// CheckParse fails if the input holds no SAML message, or a message
+	// could not be decrypted or parsed
+	CheckParse = "parse"
+
+	// CheckIssuer fails if a message has another Issuer than expected
+	CheckIssuer = "issuer"
+
+	// CheckConditions fails on the warnings of inspect, e.g. a failed
+	// status, an expired validity window, a missing signature, or an
+	// unexpected audience or destination
+	CheckConditions = "conditions"
+
+	// CheckSignature fails if a message is unsigned or its signature does
+	// not verify against the expected certificates
+	CheckSignature = "signature"
+
+	// CheckExpect fails if an assertion does not meet the expectations
+	CheckExpect = "expect"
+
+	// CheckLint fails on lint issues
+	CheckLint = "lint"
+
+	// CheckAudit fails on audit findings
+	CheckAudit = "audit"
+)
+
+// checkDescriptions describe the checks in SARIF rules
+var checkDescriptions = map[string]string{
+	CheckParse:      "The input holds SAML messages that can be decoded, decrypted and parsed",
+	CheckIssuer:     "Messages are issued by the expected entity",
+	CheckConditions: "Messages succeeded, are signed, are within their validity window and name the expected audience and destination",
+	CheckSignature:  "Messages are signed by a certificate of the expected IdP",
+	CheckExpect:     "Assertions meet the expected contract: issuer, audience, NameID format, AuthnContext, signature algorithm and attributes",
+	CheckLint:       "Messages conform to the SAML 2.0 specifications",
+	CheckAudit:      "Messages have no security issues",
+}
+
+// Failure levels, as in SARIF
+const (
+	LevelError   = "error"
+	LevelWarning = "warning"
+)
+
+// Report is the outcome of validating all cases of a manifest
+type Report struct {
+	Cases []CaseResult `json:"cases"`
+}
+
+// CaseResult holds the checks of one case
+type CaseResult struct {
+	Name  string `json:"name"`
+	Input string `json:"input"`
+
+	// Messages is the number of SAML messages found in the input
+	Messages int `json:"messages"`
+
+	Checks []CheckResult `json:"checks"`
+}
+
+// CheckResult is the outcome of one check of a case. A check without
+// failures passed.
+type CheckResult struct {
+	Name     string    `json:"name"`
+	Failures []Failure `json:"failures,omitempty"`
+
+	// Skipped explains why the check was not performed
+	Skipped string `json:"skipped,omitempty"`
+}
+
+// Failure is one reason a check failed
+type Failure struct {
+	// Rule identifies what failed: the check, or for lint and audit the
+	// rule or audit check, e.g. lint/utc-time or audit/weak-algorithm
+	Rule    string `json:"rule"`
+	Level   string `json:"level"`
+	Message string `json:"message"`
+
+	// Index is the position of the message in its input
+	Index int `json:"index,omitempty"`
+}
+
+// Failed reports whether the check failed
+func (c CheckResult) Failed() bool {
+	return len(c.Failures) > 0
+}
+
+// Failed returns the number of failed checks of the case
+func (c CaseResult) Failed() int {
+	failed := 0
+	for _, check := range c.Checks {
+		if check.Failed() {
+			failed++
+		}
+	}
+	return failed
+}
+
+// Counts returns the number of checks, failed checks and skipped checks
+func (r Report) Counts() (checks, failed, skipped int) {
+	for _, c := range r.Cases {
+		for _, check := range c.Checks {
+			checks++
+			switch {
+			case check.Failed():
+				failed++
+			case check.Skipped != "":
+				skipped++
+			}
+		}
+	}
+	return checks, failed, skipped
+}
+
+// String returns the failure as a line of text, prefixed with the message
+// index if known
+func (f Failure) String() string {
+	if f.Index > 0 {
+		return fmt.Sprintf("message %d: %s", f.Index, f.Message)
+	}
+	return f.Message
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Skipped  int             `xml:"skipped,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	File      string        `xml:"file,attr,omitempty"`
+	Failure   *junitFailure `xml:"failure"`
+	Skipped   *junitSkipped `xml:"skipped"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+// WriteJUnit renders the report as JUnit XML, with a test suite per case
+// and a test case per check
+func (r Report) WriteJUnit(w io.Writer) error {
+	suites := junitTestSuites{Name: "samlurai validate"}
+	for _, c := range r.Cases {
+		suite := junitTestSuite{Name: c.Name}
+		for _, check := range c.Checks {
+			tc := junitTestCase{Name: check.Name, ClassName: c.Name, File: c.Input}
+			switch {
+			case check.Failed():
+				lines := make([]string, len(check.Failures))
+				for i, f := range check.Failures {
+					lines[i] = fmt.Sprintf("[%s] %s: %s", strings.ToUpper(f.Level), f.Rule, f.String())
+				}
+				tc.Failure = &junitFailure{
+					Message: fmt.Sprintf("%d failure(s), first: %s", len(check.Failures), check.Failures[0].String()),
+					Type:    check.Name,
+					Text:    strings.Join(lines, "\n"),
+				}
+				suite.Failures++
+			case check.Skipped != "":
+				tc.Skipped = &junitSkipped{Message: check.Skipped}
+				suite.Skipped++
+			}
+			suite.Cases = append(suite.Cases, tc)
+			suite.Tests++
+		}
+		suites.Suites = append(suites.Suites, suite)
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Skipped += suite.Skipped
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// SARIFSchema is the JSON schema of the SARIF 2.1.0 log format
+const SARIFSchema = "https://json.schemastore.org/sarif-2.1.0.json"
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version,omitempty"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string        `json:"id"`
+	ShortDescription *sarifMessage `json:"shortDescription,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID     string            `json:"ruleId"`
+	Level      string            `json:"level"`
+	Message    sarifMessage      `json:"message"`
+	Locations  []sarifLocation   `json:"locations"`
+	Properties map[string]string `json:"properties"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+// WriteSARIF renders the failures of the report as a SARIF 2.1.0 log,
+// e.g. for GitHub code scanning. Passed and skipped checks are not listed.
+func (r Report) WriteSARIF(w io.Writer, version string) error {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "samlurai",
+			Version:        version,
+			InformationURI: "https://github.com/gliwka/SAMLurai",
+			Rules:          []sarifRule{},
+		}},
+		Results: []sarifResult{},
+	}
+
+	rules := map[string]bool{}
+	for _, c := range r.Cases {
+		for _, check := range c.Checks {
+			for _, f := range check.Failures {
+				rules[f.Rule] = true
+				run.Results = append(run.Results, sarifResult{
+					RuleID:  f.Rule,
+					Level:   f.Level,
+					Message: sarifMessage{Text: fmt.Sprintf("%s: %s", c.Name, f.String())},
+					Locations: []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{
+						ArtifactLocation: sarifArtifactLocation{URI: filepath.ToSlash(c.Input)},
+					}}},
+					Properties: map[string]string{"case": c.Name, "check": check.Name},
+				})
+			}
+		}
+	}
+	ids := make([]string, 0, len(rules))
+	for id := range rules {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		rule := sarifRule{ID: id}
+		if description, ok := checkDescriptions[id]; ok {
+			rule.ShortDescription = &sarifMessage{Text: description}
+		}
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, rule)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifLog{Schema: SARIFSchema, Version: "2.1.0", Runs: []sarifRun{run}})
+}
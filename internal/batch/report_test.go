@@ -0,0 +1,87 @@
+package batch
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testReport() Report {
+	return Report{Cases: []CaseResult{
+		{Name: "Okta", Input: "captures/okta.har", Messages: 2, Checks: []CheckResult{
+			{Name: CheckParse},
+			{Name: CheckIssuer, Failures: []Failure{{Rule: CheckIssuer, Level: LevelError, Index: 2, Message: `Issuer is "x", expected "y"`}}},
+			{Name: CheckLint, Failures: []Failure{{Rule: "lint/utc-time", Level: LevelWarning, Index: 1, Message: "IssueInstant is not in UTC"}}},
+		}},
+		{Name: "ADFS", Input: "adfs.xml", Checks: []CheckResult{
+			{Name: CheckParse, Failures: []Failure{{Rule: CheckParse, Level: LevelError, Message: "no SAML messages found"}}},
+			{Name: CheckConditions, Skipped: "no message could be parsed"},
+		}},
+	}}
+}
+
+func TestReport_Counts(t *testing.T) {
+	checks, failed, skipped := testReport().Counts()
+	assert.Equal(t, 5, checks)
+	assert.Equal(t, 3, failed)
+	assert.Equal(t, 1, skipped)
+	assert.Equal(t, 2, testReport().Cases[0].Failed())
+}
+
+func TestReport_WriteJUnit(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, testReport().WriteJUnit(&buf))
+
+	var suites junitTestSuites
+	require.NoError(t, xml.Unmarshal(buf.Bytes(), &suites))
+	assert.Equal(t, 5, suites.Tests)
+	assert.Equal(t, 3, suites.Failures)
+	assert.Equal(t, 1, suites.Skipped)
+	require.Len(t, suites.Suites, 2)
+
+	okta := suites.Suites[0]
+	assert.Equal(t, "Okta", okta.Name)
+	require.Len(t, okta.Cases, 3)
+	assert.Nil(t, okta.Cases[0].Failure)
+	require.NotNil(t, okta.Cases[1].Failure)
+	assert.Equal(t, `1 failure(s), first: message 2: Issuer is "x", expected "y"`, okta.Cases[1].Failure.Message)
+	assert.Equal(t, `[ERROR] issuer: message 2: Issuer is "x", expected "y"`, okta.Cases[1].Failure.Text)
+	assert.Equal(t, "captures/okta.har", okta.Cases[1].File)
+
+	adfs := suites.Suites[1]
+	require.NotNil(t, adfs.Cases[1].Skipped)
+	assert.Equal(t, "no message could be parsed", adfs.Cases[1].Skipped.Message)
+}
+
+func TestReport_WriteSARIF(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, testReport().WriteSARIF(&buf, "1.2.3"))
+
+	var log sarifLog
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &log))
+	assert.Equal(t, "2.1.0", log.Version)
+	assert.Equal(t, SARIFSchema, log.Schema)
+	require.Len(t, log.Runs, 1)
+	run := log.Runs[0]
+	assert.Equal(t, "1.2.3", run.Tool.Driver.Version)
+
+	var ids []string
+	for _, rule := range run.Tool.Driver.Rules {
+		ids = append(ids, rule.ID)
+	}
+	assert.Equal(t, []string{"issuer", "lint/utc-time", "parse"}, ids)
+	assert.Equal(t, checkDescriptions[CheckIssuer], run.Tool.Driver.Rules[0].ShortDescription.Text)
+	assert.Nil(t, run.Tool.Driver.Rules[1].ShortDescription)
+
+	require.Len(t, run.Results, 3)
+	lint := run.Results[1]
+	assert.Equal(t, "lint/utc-time", lint.RuleID)
+	assert.Equal(t, LevelWarning, lint.Level)
+	assert.Equal(t, "Okta: message 1: IssueInstant is not in UTC", lint.Message.Text)
+	assert.Equal(t, "captures/okta.har", lint.Locations[0].PhysicalLocation.ArtifactLocation.URI)
+	assert.Equal(t, map[string]string{"case": "Okta", "check": CheckLint}, lint.Properties)
+}
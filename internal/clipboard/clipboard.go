@@ -0,0 +1,25 @@
+// Package clipboard reads text from the system clipboard, so SAML messages
+// copied from browser dev tools can be inspected without a temporary file.
+package clipboard
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrEmpty is returned when the clipboard holds no text
+var ErrEmpty = errors.New("clipboard does not contain text")
+
+// ReadText returns the text on the clipboard with surrounding whitespace
+// removed
+func ReadText() (string, error) {
+	text, err := readText()
+	if err != nil {
+		return "", err
+	}
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return "", ErrEmpty
+	}
+	return text, nil
+}
@@ -0,0 +1,44 @@
+//go:build !windows && !darwin
+
+package clipboard
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// readers are the clipboard tools tried in order; Wayland sessions prefer
+// wl-paste since X11 tools only see XWayland's clipboard
+func readers() [][]string {
+	x11 := [][]string{
+		{"xclip", "-selection", "clipboard", "-out"},
+		{"xsel", "--clipboard", "--output"},
+	}
+	wayland := []string{"wl-paste", "--no-newline"}
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		return append([][]string{wayland}, x11...)
+	}
+	return append(x11, wayland)
+}
+
+func readText() (string, error) {
+	for _, reader := range readers() {
+		path, err := exec.LookPath(reader[0])
+		if err != nil {
+			continue
+		}
+		out, err := exec.Command(path, reader[1:]...).Output()
+		if err != nil {
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				// wl-paste and xclip exit non-zero on an empty clipboard
+				return "", ErrEmpty
+			}
+			return "", fmt.Errorf("failed to read clipboard: %w", err)
+		}
+		return string(out), nil
+	}
+	return "", fmt.Errorf("no clipboard tool found: install wl-clipboard, xclip or xsel")
+}
@@ -0,0 +1,43 @@
+//go:build !windows && !darwin
+
+package clipboard
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTool installs an executable script named name on an isolated PATH
+func fakeTool(t *testing.T, dir, name, script string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script+"\n"), 0755))
+}
+
+func TestReadText(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("PATH", dir)
+	t.Setenv("WAYLAND_DISPLAY", "")
+
+	_, err := ReadText()
+	assert.EqualError(t, err, "no clipboard tool found: install wl-clipboard, xclip or xsel")
+
+	fakeTool(t, dir, "wl-paste", "printf 'from wayland'")
+	fakeTool(t, dir, "xsel", "printf '  PHNhbWxwOlJlc3BvbnNl\\n'")
+
+	text, err := ReadText()
+	require.NoError(t, err)
+	assert.Equal(t, "PHNhbWxwOlJlc3BvbnNl", text)
+
+	t.Setenv("WAYLAND_DISPLAY", "wayland-0")
+	text, err = ReadText()
+	require.NoError(t, err)
+	assert.Equal(t, "from wayland", text)
+
+	fakeTool(t, dir, "wl-paste", "exit 1")
+	_, err = ReadText()
+	assert.ErrorIs(t, err, ErrEmpty)
+}
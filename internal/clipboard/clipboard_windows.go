@@ -0,0 +1,62 @@
+//go:build windows
+
+package clipboard
+
+import (
+	"fmt"
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+// cfUnicodeText is the CF_UNICODETEXT clipboard format: NUL-terminated UTF-16
+const cfUnicodeText = 13
+
+var (
+	user32   = syscall.NewLazyDLL("user32.dll")
+	kernel32 = syscall.NewLazyDLL("kernel32.dll")
+
+	isClipboardFormatAvailable = user32.NewProc("IsClipboardFormatAvailable")
+	openClipboard              = user32.NewProc("OpenClipboard")
+	closeClipboard             = user32.NewProc("CloseClipboard")
+	getClipboardData           = user32.NewProc("GetClipboardData")
+	globalLock                 = kernel32.NewProc("GlobalLock")
+	globalUnlock               = kernel32.NewProc("GlobalUnlock")
+	lstrlenW                   = kernel32.NewProc("lstrlenW")
+	rtlMoveMemory              = kernel32.NewProc("RtlMoveMemory")
+)
+
+// readText reads CF_UNICODETEXT through the Win32 clipboard API, which
+// unlike clip.exe/Get-Clipboard keeps non-ASCII text intact
+func readText() (string, error) {
+	// The clipboard is opened by, and must be closed from, the same thread
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	if ok, _, _ := isClipboardFormatAvailable.Call(cfUnicodeText); ok == 0 {
+		return "", ErrEmpty
+	}
+	if ok, _, err := openClipboard.Call(0); ok == 0 {
+		return "", fmt.Errorf("failed to open clipboard: %w", err)
+	}
+	defer closeClipboard.Call()
+
+	handle, _, err := getClipboardData.Call(cfUnicodeText)
+	if handle == 0 {
+		return "", fmt.Errorf("failed to read clipboard: %w", err)
+	}
+	ptr, _, err := globalLock.Call(handle)
+	if ptr == 0 {
+		return "", fmt.Errorf("failed to read clipboard: %w", err)
+	}
+	defer globalUnlock.Call(handle)
+
+	// Copy out of the global memory block rather than aliasing it
+	n, _, _ := lstrlenW.Call(ptr)
+	if n == 0 {
+		return "", ErrEmpty
+	}
+	buf := make([]uint16, n)
+	rtlMoveMemory.Call(uintptr(unsafe.Pointer(&buf[0])), ptr, n*2)
+	return syscall.UTF16ToString(buf), nil
+}
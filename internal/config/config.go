@@ -0,0 +1,174 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the samlurai configuration file, holding flag defaults for
+// each environment a user debugs, e.g.
+//
+//	default_profile: staging
+//	profiles:
+//	  staging:
+//	    key: keys/staging-sp.pem
+//	    metadata: https://idp.staging.example.com/metadata
+//	    sp_entity_id: https://sp.staging.example.com
+//	    clock_skew: 2m
+//	    url_normalization: trim_trailing_slash,ignore_host_case
+//	  prod:
+//	    key: ~/secrets/prod-sp.pem
+//	    output: json
+type Config struct {
+	// DefaultProfile is used when no profile is selected
+	DefaultProfile string             `yaml:"default_profile"`
+	Profiles       map[string]Profile `yaml:"profiles"`
+}
+
+// Profile holds the flag defaults of one environment. Flags given on the
+// command line take precedence.
+type Profile struct {
+	// Key is the private key for decryption (--key)
+	Key string `yaml:"key"`
+
+	// Metadata is the IdP metadata file or URL (--metadata)
+	Metadata string `yaml:"metadata"`
+
+	// SPEntityID is the expected audience (--audience)
+	SPEntityID string `yaml:"sp_entity_id"`
+
+	// ClockSkew is the clock skew to tolerate, e.g. 2m (--clock-skew)
+	ClockSkew string `yaml:"clock_skew"`
+
+	// URLNormalization lists the rules Destination, Recipient and Audience
+	// URLs are normalized with before they are compared, e.g.
+	// trim_trailing_slash,ignore_host_case, or none (--url-normalization)
+	URLNormalization string `yaml:"url_normalization"`
+
+	// Output is the output format (--output)
+	Output string `yaml:"output"`
+}
+
+// DefaultPath returns the path of the configuration file:
+// $XDG_CONFIG_HOME/samlurai/config.yaml, or ~/.config/samlurai/config.yaml
+func DefaultPath() (string, error) {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to locate config file: %w", err)
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "samlurai", "config.yaml"), nil
+}
+
+// Load reads the configuration file at path. A missing file is reported
+// with an error wrapping os.ErrNotExist. Relative key and metadata paths
+// are resolved against the directory of the file, and ~ against the home
+// directory.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	var config Config
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	for name, profile := range config.Profiles {
+		if profile.ClockSkew != "" {
+			if _, err := time.ParseDuration(profile.ClockSkew); err != nil {
+				return nil, fmt.Errorf("profile %q: invalid clock_skew %q: expected a duration such as 2m", name, profile.ClockSkew)
+			}
+		}
+		if profile.URLNormalization != "" {
+			if _, err := saml.ParseURLNormalization(profile.URLNormalization); err != nil {
+				return nil, fmt.Errorf("profile %q: invalid url_normalization: %w", name, err)
+			}
+		}
+		if profile.Key, err = resolvePath(path, profile.Key); err != nil {
+			return nil, err
+		}
+		if !strings.Contains(profile.Metadata, "://") {
+			if profile.Metadata, err = resolvePath(path, profile.Metadata); err != nil {
+				return nil, err
+			}
+		}
+		config.Profiles[name] = profile
+	}
+	if config.DefaultProfile != "" {
+		if _, ok := config.Profiles[config.DefaultProfile]; !ok {
+			return nil, fmt.Errorf("default_profile %q is not defined in %s", config.DefaultProfile, path)
+		}
+	}
+	return &config, nil
+}
+
+// Profile returns the named profile, or the default profile for an empty
+// name. It returns nil if no name is given and there is no default.
+func (c *Config) Profile(name string) (*Profile, error) {
+	if name == "" {
+		name = c.DefaultProfile
+	}
+	if name == "" {
+		return nil, nil
+	}
+	profile, ok := c.Profiles[name]
+	if !ok {
+		names := make([]string, 0, len(c.Profiles))
+		for n := range c.Profiles {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown profile %q; defined profiles: %s", name, strings.Join(names, ", "))
+	}
+	return &profile, nil
+}
+
+// Flags returns the values the profile sets, by flag name
+func (p Profile) Flags() map[string]string {
+	flags := map[string]string{}
+	for name, value := range map[string]string{
+		"key":               p.Key,
+		"metadata":          p.Metadata,
+		"audience":          p.SPEntityID,
+		"clock-skew":        p.ClockSkew,
+		"url-normalization": p.URLNormalization,
+		"output":            p.Output,
+	} {
+		if value != "" {
+			flags[name] = value
+		}
+	}
+	return flags
+}
+
+// IsNotExist reports whether err is due to a missing config file
+func IsNotExist(err error) bool {
+	return errors.Is(err, os.ErrNotExist)
+}
+
+// resolvePath resolves a path in the config file at configPath
+func resolvePath(configPath, path string) (string, error) {
+	switch {
+	case path == "" || path == "-" || filepath.IsAbs(path):
+		return path, nil
+	case path == "~" || strings.HasPrefix(path, "~/"):
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve %s: %w", path, err)
+		}
+		return filepath.Join(home, strings.TrimPrefix(path, "~")), nil
+	}
+	return filepath.Join(filepath.Dir(configPath), path), nil
+}
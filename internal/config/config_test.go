@@ -0,0 +1,91 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	path := writeConfig(t, `
+default_profile: staging
+profiles:
+  staging:
+    key: keys/sp.pem
+    metadata: https://idp.staging.example.com/metadata
+    sp_entity_id: https://sp.staging.example.com
+    clock_skew: 2m
+    url_normalization: trim_trailing_slash,strip_default_port
+  prod:
+    key: /etc/samlurai/prod.pem
+    metadata: idp-metadata.xml
+    output: json
+`)
+
+	config, err := Load(path)
+	require.NoError(t, err)
+
+	staging, err := config.Profile("")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(filepath.Dir(path), "keys", "sp.pem"), staging.Key)
+	assert.Equal(t, "https://idp.staging.example.com/metadata", staging.Metadata)
+	assert.Equal(t, map[string]string{
+		"key":               staging.Key,
+		"metadata":          "https://idp.staging.example.com/metadata",
+		"audience":          "https://sp.staging.example.com",
+		"clock-skew":        "2m",
+		"url-normalization": "trim_trailing_slash,strip_default_port",
+	}, staging.Flags())
+
+	prod, err := config.Profile("prod")
+	require.NoError(t, err)
+	assert.Equal(t, "/etc/samlurai/prod.pem", prod.Key)
+	assert.Equal(t, filepath.Join(filepath.Dir(path), "idp-metadata.xml"), prod.Metadata)
+	assert.Equal(t, "json", prod.Flags()["output"])
+
+	_, err = config.Profile("dev")
+	assert.EqualError(t, err, `unknown profile "dev"; defined profiles: prod, staging`)
+}
+
+func TestLoad_NoDefault(t *testing.T) {
+	config, err := Load(writeConfig(t, "profiles:\n  dev:\n    output: xml\n"))
+	require.NoError(t, err)
+
+	profile, err := config.Profile("")
+	require.NoError(t, err)
+	assert.Nil(t, profile)
+}
+
+func TestLoad_Errors(t *testing.T) {
+	_, err := Load(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.True(t, IsNotExist(err))
+
+	_, err = Load(writeConfig(t, "profiles:\n  dev:\n    clock_skew: soon\n"))
+	assert.EqualError(t, err, `profile "dev": invalid clock_skew "soon": expected a duration such as 2m`)
+
+	_, err = Load(writeConfig(t, "profiles:\n  dev:\n    url_normalization: ignore_query\n"))
+	assert.ErrorContains(t, err, `profile "dev": invalid url_normalization: unknown URL normalization rule "ignore_query"`)
+
+	_, err = Load(writeConfig(t, "default_profile: prod\nprofiles:\n  dev: {}\n"))
+	assert.ErrorContains(t, err, `default_profile "prod" is not defined`)
+
+	_, err = Load(writeConfig(t, "profiles: [\n"))
+	assert.ErrorContains(t, err, "failed to parse config file")
+}
+
+func TestDefaultPath(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", "/tmp/xdg")
+	path, err := DefaultPath()
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join("/tmp/xdg", "samlurai", "config.yaml"), path)
+}
@@ -0,0 +1,172 @@
+// Package corpus stores redacted real-world SAML samples in the fuzz corpus
+// of the saml package, so go test replays them and fuzzing starts from them.
+package corpus
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/gliwka/SAMLurai/internal/inspect"
+	"github.com/gliwka/SAMLurai/internal/redact"
+	"github.com/gliwka/SAMLurai/internal/saml"
+)
+
+// Fuzz targets of the saml package samples are stored for
+const (
+	TargetSmartDecode    = "FuzzSmartDecode"
+	TargetParse          = "FuzzParse"
+	TargetExtractFromHAR = "FuzzExtractFromHAR"
+)
+
+// DefaultDir is the fuzz corpus of the saml package, relative to the root
+// of the repository
+const DefaultDir = "internal/saml/testdata/fuzz"
+
+// Sample is an input of a fuzz target
+type Sample struct {
+	Target string
+	Data   []byte
+}
+
+// Added is a sample written to the corpus
+type Added struct {
+	Target string `json:"target"`
+	Path   string `json:"path"`
+
+	// Existed is set if the corpus already held the sample
+	Existed bool `json:"existed,omitempty"`
+}
+
+// Samples returns the samples of a HAR capture or a SAML message, with
+// NameIDs, attribute values and signature values redacted by r. A capture
+// gives the decoded and encoded form of each message, and a HAR file
+// rebuilt from them without headers, cookies or other requests.
+func Samples(filename, input string, r *redact.Redactor) ([]Sample, error) {
+	if !inspect.IsHAR(filename, input) {
+		xmlData, err := saml.NewDecoder().SmartDecode(input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode input: %w", err)
+		}
+		redacted, err := r.XML(xmlData)
+		if err != nil {
+			return nil, fmt.Errorf("failed to redact input: %w", err)
+		}
+		return []Sample{
+			{Target: TargetParse, Data: redacted},
+			{Target: TargetSmartDecode, Data: []byte(saml.NewDecoder().Encode(redacted))},
+		}, nil
+	}
+
+	extracted, err := saml.NewHARExtractor().Extract([]byte(input))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse HAR file: %w", err)
+	}
+	if len(extracted) == 0 {
+		return nil, errors.New("no SAML messages found in the capture")
+	}
+
+	var samples []Sample
+	var har saml.HAR
+	for _, e := range extracted {
+		redacted, err := r.Extracted(e)
+		if err != nil {
+			return nil, fmt.Errorf("failed to redact message %d: %w", e.Index, err)
+		}
+		entry, encoded, err := harEntry(e, redacted.DecodedXML)
+		if err != nil {
+			return nil, fmt.Errorf("message %d: %w", e.Index, err)
+		}
+		har.Log.Entries = append(har.Log.Entries, entry)
+		samples = append(samples,
+			Sample{Target: TargetParse, Data: redacted.DecodedXML},
+			Sample{Target: TargetSmartDecode, Data: []byte(encoded)},
+		)
+	}
+
+	data, err := json.MarshalIndent(har, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(samples, Sample{Target: TargetExtractFromHAR, Data: data}), nil
+}
+
+// harEntry returns a HAR entry delivering the redacted message with the
+// binding of the extracted one, and the encoded message: deflated in the
+// query for messages sent in the URL, base64 in a form otherwise. Only the
+// scheme, host and path of the URL are kept.
+func harEntry(e saml.ExtractedSAML, xmlData []byte) (saml.HAREntry, string, error) {
+	target := "https://example.com/"
+	if u, err := url.Parse(e.URL); err == nil && u.Host != "" {
+		target = (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: u.Path}).String()
+	}
+	param := e.ParameterName
+	if param == "" {
+		param = "SAMLResponse"
+	}
+
+	d := saml.NewDecoder()
+	if e.WasDeflated {
+		encoded, err := d.EncodeDeflate(xmlData)
+		if err != nil {
+			return saml.HAREntry{}, "", err
+		}
+		return saml.HAREntry{
+			Request: saml.HARRequest{
+				Method: "GET",
+				URL:    target + "?" + url.Values{param: {encoded}}.Encode(),
+			},
+			Response: saml.HARResponse{Status: 302},
+		}, encoded, nil
+	}
+
+	encoded := d.Encode(xmlData)
+	return saml.HAREntry{
+		Request: saml.HARRequest{
+			Method: "POST",
+			URL:    target,
+			PostData: &saml.HARPostData{
+				MimeType: "application/x-www-form-urlencoded",
+				Text:     url.Values{param: {encoded}}.Encode(),
+			},
+		},
+		Response: saml.HARResponse{Status: 200},
+	}, encoded, nil
+}
+
+// Write stores samples in dir, in a subdirectory per target and in the
+// file format of go test, named like the files go test -fuzz writes.
+// Samples the corpus already holds are reported as existing.
+func Write(dir string, samples []Sample) ([]Added, error) {
+	var added []Added
+	for _, s := range samples {
+		content := Encode(s)
+		path := filepath.Join(dir, s.Target, fmt.Sprintf("%x", sha256.Sum256(content))[:16])
+		if _, err := os.Stat(path); err == nil {
+			added = append(added, Added{Target: s.Target, Path: path, Existed: true})
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return added, fmt.Errorf("failed to create corpus directory: %w", err)
+		}
+		if err := os.WriteFile(path, content, 0o644); err != nil {
+			return added, fmt.Errorf("failed to write sample: %w", err)
+		}
+		added = append(added, Added{Target: s.Target, Path: path})
+	}
+	return added, nil
+}
+
+// Encode returns a sample in the file format of go test: FuzzSmartDecode
+// takes a string, the other targets a []byte
+func Encode(s Sample) []byte {
+	kind := "[]byte"
+	if s.Target == TargetSmartDecode {
+		kind = "string"
+	}
+	return fmt.Appendf(nil, "go test fuzz v1\n%s(%q)\n", kind, s.Data)
+}
@@ -0,0 +1,112 @@
+package corpus
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gliwka/SAMLurai/internal/redact"
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testResponse = `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_r">` +
+	`<saml:Assertion ID="_a"><saml:Subject><saml:NameID>alice@example.com</saml:NameID></saml:Subject></saml:Assertion></samlp:Response>`
+
+// testHAR returns a capture with a deflated AuthnRequest in the URL and a
+// response posted in a form, with headers and cookies
+func testHAR(t *testing.T) string {
+	t.Helper()
+	d := saml.NewDecoder()
+	request, err := d.EncodeDeflate([]byte(`<samlp:AuthnRequest xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_q"/>`))
+	require.NoError(t, err)
+	response := d.Encode([]byte(testResponse))
+	return `{"log": {"entries": [
+		{"request": {"method": "GET", "url": "https://idp.example.com/sso?SAMLRequest=` + strings.ReplaceAll(request, "+", "%2B") + `&session=secret",
+		             "cookies": [{"name": "sid", "value": "secret"}]},
+		 "response": {"status": 200, "content": {"text": ""}}},
+		{"request": {"method": "POST", "url": "https://sp.example.com/acs?session=secret",
+		             "headers": [{"name": "Authorization", "value": "Bearer secret"}],
+		             "postData": {"mimeType": "application/x-www-form-urlencoded", "params": [{"name": "SAMLResponse", "value": "` + response + `"}]}},
+		 "response": {"status": 302, "content": {"text": ""}}}
+	]}}`
+}
+
+func TestSamples_Message(t *testing.T) {
+	samples, err := Samples("response.xml", testResponse, redact.New(nil))
+	require.NoError(t, err)
+	require.Len(t, samples, 2)
+
+	assert.Equal(t, TargetParse, samples[0].Target)
+	assert.NotContains(t, string(samples[0].Data), "alice@example.com")
+	assert.Contains(t, string(samples[0].Data), redact.Mask)
+
+	assert.Equal(t, TargetSmartDecode, samples[1].Target)
+	decoded, err := saml.NewDecoder().SmartDecode(string(samples[1].Data))
+	require.NoError(t, err)
+	assert.Equal(t, samples[0].Data, decoded)
+}
+
+func TestSamples_HAR(t *testing.T) {
+	samples, err := Samples("login.har", testHAR(t), redact.New(nil))
+	require.NoError(t, err)
+	require.Len(t, samples, 5)
+
+	var targets []string
+	for _, s := range samples {
+		targets = append(targets, s.Target)
+		assert.NotContains(t, string(s.Data), "secret")
+		assert.NotContains(t, string(s.Data), "alice@example.com")
+	}
+	assert.Equal(t, []string{TargetParse, TargetSmartDecode, TargetParse, TargetSmartDecode, TargetExtractFromHAR}, targets)
+
+	// The rebuilt capture keeps the bindings of the messages
+	extracted, err := saml.NewHARExtractor().ExtractFromHAR(samples[4].Data)
+	require.NoError(t, err)
+	require.Len(t, extracted, 2)
+	assert.Equal(t, "AuthnRequest", extracted[0].Type)
+	assert.True(t, extracted[0].WasDeflated)
+	assert.Equal(t, "https://idp.example.com/sso?SAMLRequest="+url.QueryEscape(string(samples[1].Data)), extracted[0].URL)
+	assert.Equal(t, "Response", extracted[1].Type)
+	assert.Equal(t, "https://sp.example.com/acs", extracted[1].URL)
+	assert.Equal(t, samples[2].Data, extracted[1].DecodedXML)
+}
+
+func TestSamples_NoSAML(t *testing.T) {
+	_, err := Samples("empty.har", `{"log": {"entries": []}}`, redact.New(nil))
+	assert.EqualError(t, err, "no SAML messages found in the capture")
+
+	_, err = Samples("", "not SAML", redact.New(nil))
+	assert.Error(t, err)
+}
+
+func TestWrite(t *testing.T) {
+	dir := t.TempDir()
+	samples := []Sample{
+		{Target: TargetSmartDecode, Data: []byte("PHNhbWw+")},
+		{Target: TargetParse, Data: []byte("<a>\"\n</a>")},
+	}
+
+	added, err := Write(dir, samples)
+	require.NoError(t, err)
+	require.Len(t, added, 2)
+	assert.False(t, added[0].Existed)
+	assert.Equal(t, TargetSmartDecode, added[0].Target)
+	assert.Equal(t, filepath.Join(dir, TargetSmartDecode), filepath.Dir(added[0].Path))
+
+	data, err := os.ReadFile(added[0].Path)
+	require.NoError(t, err)
+	assert.Equal(t, "go test fuzz v1\nstring(\"PHNhbWw+\")\n", string(data))
+	data, err = os.ReadFile(added[1].Path)
+	require.NoError(t, err)
+	assert.Equal(t, "go test fuzz v1\n[]byte(\"<a>\\\"\\n</a>\")\n", string(data))
+
+	// Samples are stored once
+	again, err := Write(dir, samples)
+	require.NoError(t, err)
+	assert.True(t, again[0].Existed)
+	assert.Equal(t, added[0].Path, again[0].Path)
+}
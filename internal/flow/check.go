@@ -0,0 +1,231 @@
+package flow
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gliwka/SAMLurai/internal/saml"
+)
+
+// Step states
+const (
+	StateMatched    = "matched"
+	StateDiverged   = "diverged"
+	StateNotReached = "not-reached"
+)
+
+// StepResult is the outcome of one step of the spec
+type StepResult struct {
+	Step  Step   `json:"step"`
+	State string `json:"state"`
+
+	// Entry is the 1-based HAR entry that matched the step or, for the
+	// diverged step, came closest to matching it
+	Entry  int    `json:"entry,omitempty"`
+	Method string `json:"method,omitempty"`
+	URL    string `json:"url,omitempty"`
+	Status int    `json:"status,omitempty"`
+
+	// Problems explain why the diverged step did not match
+	Problems []string `json:"problems,omitempty"`
+}
+
+// Result is the comparison of a capture against a spec
+type Result struct {
+	Name  string       `json:"name,omitempty"`
+	Steps []StepResult `json:"steps"`
+
+	// DivergedAt is the 1-based step at which the capture diverged, or 0
+	DivergedAt int `json:"diverged_at,omitempty"`
+
+	// Replays lists Responses delivered more than once. Their Index and Of
+	// are 1-based HAR entries rather than message indexes.
+	Replays []saml.Replay `json:"replays,omitempty"`
+
+	// Sessions are the SP sessions established after each ACS POST
+	Sessions []Session `json:"sessions,omitempty"`
+}
+
+// OK reports whether every step was matched
+func (r *Result) OK() bool {
+	return r.DivergedAt == 0
+}
+
+// samlMessage is a SAML message found in a HAR entry
+type samlMessage struct {
+	Type   string
+	Status string
+}
+
+// Check matches the steps of spec in order against the HAR entries. Steps
+// may be separated by any number of unrelated entries; the first step
+// without a matching entry after the previous match is where the flow
+// diverged.
+func Check(entries []saml.HAREntry, spec *Spec) *Result {
+	result := &Result{Name: spec.Name}
+	messages := make([][]samlMessage, len(entries))
+	extractor := saml.NewHARExtractor()
+	parser := saml.NewParser()
+	replays := saml.NewReplayDetector()
+	for i, entry := range entries {
+		for _, extracted := range extractor.ExtractFromEntry(entry) {
+			msg := samlMessage{Type: extracted.Type}
+			info, err := parser.ParsePartial(extracted.DecodedXML)
+			if err == nil && info.Status != nil {
+				msg.Status = info.Status.StatusCode
+			}
+			messages[i] = append(messages[i], msg)
+
+			extracted.Index = i + 1
+			if replay := replays.Observe(extracted, info); replay != nil {
+				result.Replays = append(result.Replays, *replay)
+			}
+		}
+	}
+
+	cursor := 0
+	for i, step := range spec.Steps {
+		sr := StepResult{Step: step, State: StateNotReached}
+		if result.DivergedAt != 0 {
+			result.Steps = append(result.Steps, sr)
+			continue
+		}
+
+		matched := -1
+		closest, closestScore := -1, 0
+		var closestProblems []string
+		for j := cursor; j < len(entries); j++ {
+			problems, score := step.compare(entries[j], messages[j])
+			if len(problems) == 0 {
+				matched = j
+				break
+			}
+			if score > closestScore {
+				closest, closestScore, closestProblems = j, score, problems
+			}
+		}
+
+		switch {
+		case matched >= 0:
+			sr.State = StateMatched
+			sr.setEntry(matched, entries[matched])
+			cursor = matched + 1
+		case closest >= 0:
+			sr.State = StateDiverged
+			sr.setEntry(closest, entries[closest])
+			sr.Problems = closestProblems
+			result.DivergedAt = i + 1
+		default:
+			sr.State = StateDiverged
+			if cursor == 0 {
+				sr.Problems = []string{"no matching request in the capture"}
+			} else {
+				sr.Problems = []string{fmt.Sprintf("no matching request after entry %d", cursor)}
+			}
+			result.DivergedAt = i + 1
+		}
+		result.Steps = append(result.Steps, sr)
+	}
+	result.Sessions = Sessions(entries)
+	return result
+}
+
+func (sr *StepResult) setEntry(index int, entry saml.HAREntry) {
+	sr.Entry = index + 1
+	sr.Method = entry.Request.Method
+	sr.URL = entry.Request.URL
+	sr.Status = entry.Response.Status
+}
+
+// compare checks entry against the step's criteria. It returns the unmet
+// criteria and how many were met, which ranks near misses. Entries at a
+// different URL, or without the expected SAML message if the step names no
+// URL, are not near misses and score 0.
+func (s Step) compare(entry saml.HAREntry, messages []samlMessage) ([]string, int) {
+	var problems []string
+	score := 0
+	located := true
+	check := func(ok bool, problem string) {
+		if ok {
+			score++
+		} else {
+			problems = append(problems, problem)
+		}
+	}
+	locate := func(ok bool, problem string) {
+		located = located && ok
+		check(ok, problem)
+	}
+
+	if s.Method != "" {
+		check(strings.EqualFold(entry.Request.Method, s.Method),
+			fmt.Sprintf("method %s, expected %s", entry.Request.Method, strings.ToUpper(s.Method)))
+	}
+	if s.URL != "" {
+		locate(strings.HasPrefix(entry.Request.URL, s.URL), fmt.Sprintf("URL does not start with %s", s.URL))
+	}
+	if s.urlPattern != nil {
+		locate(s.urlPattern.MatchString(entry.Request.URL), fmt.Sprintf("URL does not match %s", s.URLPattern))
+	}
+	if s.Status != "" {
+		check(statusMatches(entry.Response.Status, s.Status), describeStatus(entry.Response.Status, s.Status))
+	}
+	if s.SAML != "" {
+		checkType := check
+		if s.URL == "" && s.urlPattern == nil {
+			checkType = locate
+		}
+		s.compareSAML(messages, checkType, check)
+	}
+	if !located {
+		return problems, 0
+	}
+	return problems, score
+}
+
+// compareSAML checks the SAML message type and then its status. A missing
+// message is a single problem; its status is not reported separately.
+func (s Step) compareSAML(messages []samlMessage, checkType, check func(bool, string)) {
+	var found *samlMessage
+	var types []string
+	for i := range messages {
+		types = append(types, messages[i].Type)
+		if messages[i].Type == s.SAML && found == nil {
+			found = &messages[i]
+		}
+	}
+
+	if found == nil {
+		problem := fmt.Sprintf("no SAML %s", s.SAML)
+		if len(types) > 0 {
+			problem += fmt.Sprintf(" (found %s)", strings.Join(types, ", "))
+		}
+		checkType(false, problem)
+		return
+	}
+	checkType(true, "")
+
+	if s.SAMLStatus != "" {
+		got := found.Status
+		if got == "" {
+			got = "none"
+		}
+		check(found.Status == s.SAMLStatus, fmt.Sprintf("SAML status %s, expected %s", got, s.SAMLStatus))
+	}
+}
+
+// statusMatches reports whether code satisfies an exact or class status
+func statusMatches(code int, want string) bool {
+	if strings.HasSuffix(want, "xx") {
+		return code/100 == int(want[0]-'0')
+	}
+	return strconv.Itoa(code) == want
+}
+
+func describeStatus(code int, want string) string {
+	if code == 0 {
+		return fmt.Sprintf("no response status, expected %s", want)
+	}
+	return fmt.Sprintf("status %d, expected %s", code, want)
+}
@@ -0,0 +1,89 @@
+package flow
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Diagram formats
+const (
+	DiagramMermaid  = "mermaid"
+	DiagramPlantUML = "plantuml"
+)
+
+// DiagramFormats lists the formats WriteDiagram renders
+var DiagramFormats = []string{DiagramMermaid, DiagramPlantUML}
+
+// WriteDiagram renders the sequence as a Mermaid or PlantUML sequence
+// diagram
+func (s *Sequence) WriteDiagram(w io.Writer, format string) error {
+	switch format {
+	case DiagramMermaid:
+		return s.WriteMermaid(w)
+	case DiagramPlantUML:
+		return s.WritePlantUML(w)
+	}
+	return fmt.Errorf("unknown diagram format %q (expected %s)", format, strings.Join(DiagramFormats, " or "))
+}
+
+// WriteMermaid renders the sequence as a Mermaid sequence diagram, e.g.
+// for Markdown documentation. Replies are drawn dashed.
+func (s *Sequence) WriteMermaid(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("sequenceDiagram\n")
+	for _, p := range s.Participants {
+		label := p.ID
+		if p.Host != "" && p.Role != "" {
+			label = p.Role + "<br/>" + p.Host
+		} else if p.Host != "" {
+			label = p.Host
+		}
+		fmt.Fprintf(&b, "    participant %s as %s\n", p.ID, mermaidEscape(label))
+	}
+	for _, a := range s.Arrows {
+		arrow := "->>"
+		if a.Reply {
+			arrow = "-->>"
+		}
+		fmt.Fprintf(&b, "    %s%s%s: %s\n", a.From, arrow, a.To, mermaidEscape(a.Label()))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WritePlantUML renders the sequence as a PlantUML sequence diagram.
+// Replies are drawn dashed.
+func (s *Sequence) WritePlantUML(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("@startuml\n")
+	for _, p := range s.Participants {
+		kind := "participant"
+		label := p.ID
+		switch {
+		case p.Role == RoleBrowser:
+			kind = "actor"
+		case p.Host != "" && p.Role != "":
+			label = p.Role + `\n` + p.Host
+		case p.Host != "":
+			label = p.Host
+		}
+		fmt.Fprintf(&b, "%s \"%s\" as %s\n", kind, strings.ReplaceAll(label, `"`, `'`), p.ID)
+	}
+	for _, a := range s.Arrows {
+		arrow := "->"
+		if a.Reply {
+			arrow = "-->"
+		}
+		fmt.Fprintf(&b, "%s %s %s : %s\n", a.From, arrow, a.To, a.Label())
+	}
+	b.WriteString("@enduml\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// mermaidEscape replaces the characters that end a Mermaid statement or
+// start an entity code with entity codes
+func mermaidEscape(s string) string {
+	return strings.NewReplacer("#", "#35;", ";", "#59;").Replace(s)
+}
@@ -0,0 +1,319 @@
+package flow
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gliwka/SAMLurai/internal/saml"
+)
+
+// Kinds of DiffStep
+const (
+	DiffSame    = "same"
+	DiffChanged = "changed"
+	DiffMissing = "missing"
+	DiffExtra   = "extra"
+)
+
+// Delivery timings of a Message relative to its validity window
+const (
+	TimingValid   = "valid"
+	TimingEarly   = "not yet valid"
+	TimingExpired = "expired"
+)
+
+// Message is a SAML message of a capture, as compared by Diff
+type Message struct {
+	// Entry is the 1-based HAR entry that delivered the message
+	Entry   int    `json:"entry"`
+	URL     string `json:"url"`
+	Type    string `json:"type"`
+	Binding string `json:"binding"`
+
+	// HTTPStatus is the status of the response to the entry
+	HTTPStatus int    `json:"http_status,omitempty"`
+	Status     string `json:"status,omitempty"`
+
+	// Encrypted is set for Responses with encrypted assertions, whose
+	// attributes and conditions are not known
+	Encrypted  bool     `json:"encrypted,omitempty"`
+	Attributes []string `json:"attributes,omitempty"`
+
+	// Window is the length of the assertion's validity window, and Timing
+	// where the capture time of the entry fell relative to it
+	Window time.Duration `json:"window,omitempty"`
+	Timing string        `json:"timing,omitempty"`
+}
+
+// DiffStep pairs the messages the two captures have at one step of the
+// flow. Either is nil for a step only one capture has.
+type DiffStep struct {
+	Kind        string   `json:"kind"`
+	Good        *Message `json:"good,omitempty"`
+	Bad         *Message `json:"bad,omitempty"`
+	Differences []string `json:"differences,omitempty"`
+}
+
+// Diff is the comparison of two captures of a flow
+type Diff struct {
+	Steps []DiffStep `json:"steps"`
+
+	// DivergedAt is the 1-based step at which the captures diverged, or 0
+	DivergedAt int `json:"diverged_at,omitempty"`
+}
+
+// Messages lists the SAML messages of a capture in order. A message the
+// browser is seen passing on, such as a Response in the IdP's form that is
+// then posted to the ACS, is listed once, where it was delivered.
+func Messages(entries []saml.HAREntry) []Message {
+	var found []saml.ExtractedSAML
+	var entryOf []int
+	extractor := saml.NewHARExtractor()
+	for i, entry := range entries {
+		for _, extracted := range extractor.ExtractFromEntry(entry) {
+			found = append(found, extracted)
+			entryOf = append(entryOf, i)
+		}
+	}
+
+	parser := saml.NewParser()
+	var messages []Message
+	for i, extracted := range found {
+		if passedOn(extracted, found[i+1:]) {
+			continue
+		}
+		entry := entries[entryOf[i]]
+		msg := Message{
+			Entry:      entryOf[i] + 1,
+			URL:        extracted.URL,
+			Type:       strings.TrimSuffix(extracted.Type, " (Encrypted)"),
+			Binding:    binding(extracted),
+			HTTPStatus: entry.Response.Status,
+			Encrypted:  strings.HasSuffix(extracted.Type, " (Encrypted)"),
+		}
+		if info, err := parser.Parse(extracted.DecodedXML); err == nil {
+			msg.describe(info, startedAt(entry))
+		} else if info, err := parser.ParsePartial(extracted.DecodedXML); err == nil && info.Status != nil {
+			msg.Status = info.Status.StatusCode
+		}
+		messages = append(messages, msg)
+	}
+	return messages
+}
+
+// passedOn reports whether a message found in a response is delivered by
+// a later request of the capture
+func passedOn(extracted saml.ExtractedSAML, later []saml.ExtractedSAML) bool {
+	var delivery string
+	switch extracted.Source {
+	case "response-location":
+		delivery = "request-query"
+	case "response-body":
+		delivery = "request-body"
+	default:
+		return false
+	}
+	for _, l := range later {
+		if l.Source == delivery && bytes.Equal(l.DecodedXML, extracted.DecodedXML) {
+			return true
+		}
+	}
+	return false
+}
+
+// describe fills in the status, attributes and validity window of a parsed
+// message. The conditions of the assertion take precedence over those of
+// the message.
+func (m *Message) describe(info *saml.SAMLInfo, capturedAt *time.Time) {
+	if info.Status != nil {
+		m.Status = info.Status.StatusCode
+	}
+	var conditions *saml.Conditions
+	attributes := map[string]bool{}
+	for i := info; i != nil; i = i.Assertion {
+		if i.Conditions != nil {
+			conditions = i.Conditions
+		}
+		for _, a := range i.Attributes {
+			attributes[a.Name] = true
+		}
+	}
+	for name := range attributes {
+		m.Attributes = append(m.Attributes, name)
+	}
+	sort.Strings(m.Attributes)
+
+	if conditions == nil {
+		return
+	}
+	if conditions.NotBefore != nil && conditions.NotOnOrAfter != nil {
+		m.Window = conditions.NotOnOrAfter.Sub(*conditions.NotBefore)
+	}
+	if capturedAt != nil {
+		switch timing, _ := saml.CheckWindow(conditions.NotBefore, conditions.NotOnOrAfter, *capturedAt, 0); timing {
+		case saml.TimingEarly:
+			m.Timing = TimingEarly
+		case saml.TimingExpired:
+			m.Timing = TimingExpired
+		default:
+			m.Timing = TimingValid
+		}
+	}
+}
+
+// Compare aligns the messages of a good and a bad capture by type and
+// order, and reports the steps only one capture has and the differences
+// between the messages both have
+func Compare(good, bad []Message) *Diff {
+	d := &Diff{Steps: []DiffStep{}}
+	for _, pair := range align(good, bad) {
+		step := DiffStep{Kind: DiffSame}
+		switch {
+		case pair[0] < 0:
+			step.Kind = DiffExtra
+			step.Bad = &bad[pair[1]]
+		case pair[1] < 0:
+			step.Kind = DiffMissing
+			step.Good = &good[pair[0]]
+		default:
+			step.Good, step.Bad = &good[pair[0]], &bad[pair[1]]
+			if step.Differences = differences(*step.Good, *step.Bad); len(step.Differences) > 0 {
+				step.Kind = DiffChanged
+			}
+		}
+		if step.Kind != DiffSame && d.DivergedAt == 0 {
+			d.DivergedAt = len(d.Steps) + 1
+		}
+		d.Steps = append(d.Steps, step)
+	}
+	return d
+}
+
+// align pairs up the indexes of good and bad messages along the longest
+// common subsequence of their types. Unpaired messages have -1 as the
+// other index and are placed before the next pair.
+func align(good, bad []Message) [][2]int {
+	// lcs[i][j] is the length of the longest common subsequence of
+	// good[i:] and bad[j:]
+	lcs := make([][]int, len(good)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(bad)+1)
+	}
+	for i := len(good) - 1; i >= 0; i-- {
+		for j := len(bad) - 1; j >= 0; j-- {
+			if good[i].Type == bad[j].Type {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var pairs [][2]int
+	i, j := 0, 0
+	for i < len(good) && j < len(bad) {
+		switch {
+		case good[i].Type == bad[j].Type:
+			pairs = append(pairs, [2]int{i, j})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			pairs = append(pairs, [2]int{i, -1})
+			i++
+		default:
+			pairs = append(pairs, [2]int{-1, j})
+			j++
+		}
+	}
+	for ; i < len(good); i++ {
+		pairs = append(pairs, [2]int{i, -1})
+	}
+	for ; j < len(bad); j++ {
+		pairs = append(pairs, [2]int{-1, j})
+	}
+	return pairs
+}
+
+// differences describes how two messages of the same type differ
+func differences(good, bad Message) []string {
+	var diffs []string
+	differ := func(what, g, b string) {
+		if g != b {
+			diffs = append(diffs, fmt.Sprintf("%s %s → %s", what, orNone(g), orNone(b)))
+		}
+	}
+
+	differ("binding", good.Binding, bad.Binding)
+	differ("HTTP status", statusText(good.HTTPStatus), statusText(bad.HTTPStatus))
+	differ("SAML status", good.Status, bad.Status)
+	if good.Encrypted != bad.Encrypted {
+		differ("assertion", encryptedText(good.Encrypted), encryptedText(bad.Encrypted))
+		return diffs
+	}
+
+	if missing, extra := setDifference(good.Attributes, bad.Attributes); len(missing)+len(extra) > 0 {
+		var parts []string
+		if len(missing) > 0 {
+			parts = append(parts, "missing "+strings.Join(missing, ", "))
+		}
+		if len(extra) > 0 {
+			parts = append(parts, "extra "+strings.Join(extra, ", "))
+		}
+		diffs = append(diffs, "attributes: "+strings.Join(parts, "; "))
+	}
+	differ("validity window", windowText(good.Window), windowText(bad.Window))
+	differ("delivered", good.Timing, bad.Timing)
+	return diffs
+}
+
+// setDifference returns the names only in good and only in bad
+func setDifference(good, bad []string) (missing, extra []string) {
+	inBad := map[string]bool{}
+	for _, name := range bad {
+		inBad[name] = true
+	}
+	inGood := map[string]bool{}
+	for _, name := range good {
+		inGood[name] = true
+		if !inBad[name] {
+			missing = append(missing, name)
+		}
+	}
+	for _, name := range bad {
+		if !inGood[name] {
+			extra = append(extra, name)
+		}
+	}
+	return missing, extra
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return s
+}
+
+func statusText(status int) string {
+	if status == 0 {
+		return ""
+	}
+	return fmt.Sprint(status)
+}
+
+func windowText(window time.Duration) string {
+	if window == 0 {
+		return ""
+	}
+	return saml.FormatDuration(window)
+}
+
+func encryptedText(encrypted bool) string {
+	if encrypted {
+		return "encrypted"
+	}
+	return "not encrypted"
+}
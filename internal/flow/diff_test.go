@@ -0,0 +1,107 @@
+package flow
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessages(t *testing.T) {
+	messages := Messages(testEntries("Success", 302))
+
+	require.Len(t, messages, 2, "the Response in the IdP's form is listed where it was posted")
+	assert.Equal(t, Message{Entry: 2, URL: "https://idp.example.com/sso", Type: "AuthnRequest", Binding: "HTTP-Redirect", HTTPStatus: 200}, messages[0])
+	assert.Equal(t, 4, messages[1].Entry)
+	assert.Equal(t, "Response", messages[1].Type)
+	assert.Equal(t, "HTTP-POST", messages[1].Binding)
+	assert.Equal(t, 302, messages[1].HTTPStatus)
+	assert.Equal(t, "Success", messages[1].Status)
+}
+
+func TestMessages_Assertion(t *testing.T) {
+	response := `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_r" Version="2.0" IssueInstant="2024-01-15T10:30:00Z">
+  <samlp:Status><samlp:StatusCode Value="urn:oasis:names:tc:SAML:2.0:status:Success"/></samlp:Status>
+  <saml:Assertion ID="_a" Version="2.0" IssueInstant="2024-01-15T10:30:00Z">
+    <saml:Issuer>https://idp.example.com</saml:Issuer>
+    <saml:Conditions NotBefore="2024-01-15T10:29:00Z" NotOnOrAfter="2024-01-15T10:34:00Z"/>
+    <saml:AttributeStatement>
+      <saml:Attribute Name="mail"><saml:AttributeValue>a@example.com</saml:AttributeValue></saml:Attribute>
+      <saml:Attribute Name="groups"><saml:AttributeValue>admins</saml:AttributeValue></saml:Attribute>
+    </saml:AttributeStatement>
+  </saml:Assertion>
+</samlp:Response>`
+	messages := Messages([]saml.HAREntry{{
+		StartedDateTime: "2024-01-15T10:40:00Z",
+		Request: saml.HARRequest{
+			Method: "POST",
+			URL:    "https://sp.example.com/acs",
+			PostData: &saml.HARPostData{
+				MimeType: "application/x-www-form-urlencoded",
+				Params:   []saml.HARNameValue{{Name: "SAMLResponse", Value: base64.StdEncoding.EncodeToString([]byte(response))}},
+			},
+		},
+		Response: saml.HARResponse{Status: 500},
+	}})
+
+	require.Len(t, messages, 1)
+	assert.Equal(t, []string{"groups", "mail"}, messages[0].Attributes)
+	assert.Equal(t, 5*time.Minute, messages[0].Window)
+	assert.Equal(t, TimingExpired, messages[0].Timing)
+}
+
+func TestCompare(t *testing.T) {
+	diff := Compare(Messages(testEntries("Success", 302)), Messages(testEntries("Responder", 500)))
+
+	require.Len(t, diff.Steps, 2)
+	assert.Equal(t, DiffSame, diff.Steps[0].Kind)
+	assert.Equal(t, DiffChanged, diff.Steps[1].Kind)
+	assert.Equal(t, []string{"HTTP status 302 → 500", "SAML status Success → Responder"}, diff.Steps[1].Differences)
+	assert.Equal(t, 2, diff.DivergedAt)
+}
+
+func TestCompare_Same(t *testing.T) {
+	diff := Compare(Messages(testEntries("Success", 302)), Messages(testEntries("Success", 302)))
+	assert.Zero(t, diff.DivergedAt)
+}
+
+func TestCompare_Steps(t *testing.T) {
+	good := []Message{
+		{Type: "AuthnRequest", Binding: "HTTP-Redirect"},
+		{Type: "Response", Binding: "HTTP-POST", Attributes: []string{"groups", "mail"}, Window: 5 * time.Minute, Timing: TimingValid},
+		{Type: "LogoutRequest", Binding: "HTTP-Redirect"},
+	}
+	bad := []Message{
+		{Type: "AuthnRequest", Binding: "HTTP-POST"},
+		{Type: "AuthnRequest", Binding: "HTTP-POST"},
+		{Type: "Response", Binding: "HTTP-POST", Attributes: []string{"mail", "upn"}, Window: time.Minute, Timing: TimingExpired},
+	}
+	diff := Compare(good, bad)
+
+	var kinds []string
+	for _, step := range diff.Steps {
+		kinds = append(kinds, step.Kind)
+	}
+	assert.Equal(t, []string{DiffChanged, DiffExtra, DiffChanged, DiffMissing}, kinds)
+	assert.Equal(t, 1, diff.DivergedAt)
+	assert.Equal(t, []string{"binding HTTP-Redirect → HTTP-POST"}, diff.Steps[0].Differences)
+	assert.Equal(t, []string{
+		"attributes: missing groups; extra upn",
+		"validity window 5m → 1m",
+		"delivered valid → expired",
+	}, diff.Steps[2].Differences)
+	assert.Equal(t, "LogoutRequest", diff.Steps[3].Good.Type)
+	assert.Nil(t, diff.Steps[3].Bad)
+}
+
+func TestCompare_Encrypted(t *testing.T) {
+	good := []Message{{Type: "Response", Attributes: []string{"mail"}}}
+	bad := []Message{{Type: "Response", Encrypted: true}}
+
+	diff := Compare(good, bad)
+	assert.Equal(t, []string{"assertion not encrypted → encrypted"}, diff.Steps[0].Differences,
+		"the attributes of encrypted assertions are not compared")
+}
@@ -0,0 +1,249 @@
+package flow
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSpec = `name: Test login
+steps:
+  - name: AuthnRequest
+    saml: AuthnRequest
+  - name: Response
+    saml: Response
+    saml_status: Success
+  - name: ACS redirect
+    url: https://sp.example.com/acs
+    status: 3xx
+  - name: App
+    url: https://app.example.com/
+    status: 200
+`
+
+func samlResponse(status string) string {
+	return base64.StdEncoding.EncodeToString([]byte(`<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_r"><samlp:Status><samlp:StatusCode Value="urn:oasis:names:tc:SAML:2.0:status:` + status + `"/></samlp:Status></samlp:Response>`))
+}
+
+func testEntries(responseStatus string, acsStatus int) []saml.HAREntry {
+	authnRequest := base64.StdEncoding.EncodeToString([]byte(`<samlp:AuthnRequest xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_q"/>`))
+	return []saml.HAREntry{
+		{
+			Request:  saml.HARRequest{Method: "GET", URL: "https://sp.example.com/login"},
+			Response: saml.HARResponse{Status: 200},
+		},
+		{
+			Request: saml.HARRequest{
+				Method:      "GET",
+				URL:         "https://idp.example.com/sso",
+				QueryString: []saml.HARNameValue{{Name: "SAMLRequest", Value: authnRequest}},
+			},
+			Response: saml.HARResponse{Status: 200},
+		},
+		{
+			// The IdP answers the login with an auto-submitting form
+			Request: saml.HARRequest{Method: "POST", URL: "https://idp.example.com/login"},
+			Response: saml.HARResponse{
+				Status: 200,
+				Content: saml.HARContent{
+					MimeType: "text/html",
+					Text:     `<form method="post" action="https://sp.example.com/acs"><input type="hidden" name="SAMLResponse" value="` + samlResponse(responseStatus) + `"/></form>`,
+				},
+			},
+		},
+		{
+			Request: saml.HARRequest{
+				Method: "POST",
+				URL:    "https://sp.example.com/acs",
+				PostData: &saml.HARPostData{
+					MimeType: "application/x-www-form-urlencoded",
+					Params:   []saml.HARNameValue{{Name: "SAMLResponse", Value: samlResponse(responseStatus)}},
+				},
+			},
+			Response: saml.HARResponse{Status: acsStatus},
+		},
+		{
+			Request:  saml.HARRequest{Method: "GET", URL: "https://app.example.com/home"},
+			Response: saml.HARResponse{Status: 200},
+		},
+	}
+}
+
+func TestParseSpec(t *testing.T) {
+	spec, err := ParseSpec([]byte(testSpec))
+	require.NoError(t, err)
+	assert.Equal(t, "Test login", spec.Name)
+	require.Len(t, spec.Steps, 4)
+	assert.Equal(t, "3xx", spec.Steps[2].Status)
+	assert.Equal(t, "200", spec.Steps[3].Status)
+}
+
+func TestParseSpec_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		wantErr string
+	}{
+		{"no steps", "name: empty\n", "spec has no steps"},
+		{"no criteria", "steps:\n  - name: nothing\n", "nothing: at least one of"},
+		{"status without saml", "steps:\n  - saml_status: Success\n", "step 1: saml_status requires saml"},
+		{"bad status", "steps:\n  - status: 3x\n", `invalid status "3x"`},
+		{"bad pattern", "steps:\n  - url_pattern: '('\n", "invalid url_pattern"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseSpec([]byte(tt.spec))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	spec, err := ParseSpec([]byte(testSpec))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		entries      []saml.HAREntry
+		wantDiverged int
+		wantEntries  []int
+		wantProblems []string
+	}{
+		{
+			name:        "happy path",
+			entries:     testEntries("Success", 302),
+			wantEntries: []int{2, 3, 4, 5},
+		},
+		{
+			name:         "IdP returned an error status",
+			entries:      testEntries("Requester", 302),
+			wantDiverged: 2,
+			wantEntries:  []int{2, 3, 0, 0},
+			wantProblems: []string{"SAML status Requester, expected Success"},
+		},
+		{
+			name:         "ACS failed",
+			entries:      testEntries("Success", 500),
+			wantDiverged: 3,
+			wantEntries:  []int{2, 3, 4, 0},
+			wantProblems: []string{"status 500, expected 3xx"},
+		},
+		{
+			name:         "flow never started",
+			entries:      testEntries("Success", 302)[:1],
+			wantDiverged: 1,
+			wantEntries:  []int{0, 0, 0, 0},
+			wantProblems: []string{"no matching request in the capture"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := Check(tt.entries, spec)
+			assert.Equal(t, tt.wantDiverged, result.DivergedAt)
+			assert.Equal(t, tt.wantDiverged == 0, result.OK())
+
+			var entries []int
+			for _, sr := range result.Steps {
+				entries = append(entries, sr.Entry)
+			}
+			assert.Equal(t, tt.wantEntries, entries)
+
+			if tt.wantDiverged > 0 {
+				diverged := result.Steps[tt.wantDiverged-1]
+				assert.Equal(t, StateDiverged, diverged.State)
+				assert.Equal(t, tt.wantProblems, diverged.Problems)
+				for _, sr := range result.Steps[tt.wantDiverged:] {
+					assert.Equal(t, StateNotReached, sr.State)
+				}
+			}
+		})
+	}
+}
+
+func TestCheck_Replays(t *testing.T) {
+	spec, err := ParseSpec([]byte(testSpec))
+	require.NoError(t, err)
+
+	entries := testEntries("Success", 302)
+	assert.Empty(t, Check(entries, spec).Replays)
+
+	// The SP resubmits the response
+	entries = append(entries, entries[3])
+	result := Check(entries, spec)
+	assert.True(t, result.OK())
+	assert.Equal(t, []saml.Replay{{Index: 6, Of: 4}}, result.Replays)
+}
+
+func TestSessions(t *testing.T) {
+	assertion := `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_r"><saml:Assertion xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_a"><saml:Conditions NotBefore="2024-01-15T10:00:00Z" NotOnOrAfter="2024-01-15T10:05:00Z"/></saml:Assertion></samlp:Response>`
+	acs := func(started string) saml.HAREntry {
+		return saml.HAREntry{
+			StartedDateTime: started,
+			Request: saml.HARRequest{
+				Method: "POST",
+				URL:    "https://sp.example.com/acs",
+				PostData: &saml.HARPostData{
+					MimeType: "application/x-www-form-urlencoded",
+					Params:   []saml.HARNameValue{{Name: "SAMLResponse", Value: base64.StdEncoding.EncodeToString([]byte(assertion))}},
+				},
+			},
+			Response: saml.HARResponse{Status: 302},
+		}
+	}
+
+	entries := []saml.HAREntry{
+		acs("2024-01-15T10:00:02Z"),
+		{
+			// The SP sets its session on the redirect after the ACS
+			StartedDateTime: "2024-01-15T10:00:03Z",
+			Request:         saml.HARRequest{Method: "GET", URL: "https://sp.example.com/callback"},
+			Response: saml.HARResponse{Status: 302, Headers: []saml.HARNameValue{
+				{Name: "Set-Cookie", Value: "tracking=; Max-Age=0"},
+				{Name: "Set-Cookie", Value: "JSESSIONID=abc123; Path=/; HttpOnly; Secure"},
+			}},
+		},
+		{
+			Request:  saml.HARRequest{Method: "GET", URL: "https://app.example.com/"},
+			Response: saml.HARResponse{Status: 200},
+		},
+		{
+			Request:  saml.HARRequest{Method: "GET", URL: "https://sp.example.com/home", Headers: []saml.HARNameValue{{Name: "Cookie", Value: "a=b; JSESSIONID=abc123"}}},
+			Response: saml.HARResponse{Status: 200},
+		},
+		acs("2024-01-15T10:06:00Z"),
+	}
+
+	sessions := Sessions(entries)
+	require.Len(t, sessions, 2)
+
+	first := sessions[0]
+	assert.Equal(t, 1, first.ACSEntry)
+	assert.Equal(t, 2, first.Entry)
+	assert.Equal(t, []string{"JSESSIONID"}, first.Cookies)
+	assert.Equal(t, 4, first.UsedAt)
+	assert.True(t, first.InWindow())
+	assert.Equal(t, "3s after NotBefore, 4m57s before NotOnOrAfter", first.Timing())
+
+	// A late ACS POST whose session, set on the ACS response itself, began
+	// after the assertion expired
+	entries[4].Response.Cookies = []saml.HARCookie{{Name: "sid", Value: "x"}}
+	late := Sessions(entries)[1]
+	assert.Equal(t, 5, late.Entry)
+	assert.False(t, late.InWindow())
+	assert.Equal(t, "1m0s after the assertion expired", late.Timing())
+	assert.Zero(t, late.UsedAt)
+}
+
+func TestSessions_NoCookie(t *testing.T) {
+	sessions := Sessions(testEntries("Success", 302))
+	require.Len(t, sessions, 1)
+	assert.Equal(t, 4, sessions[0].ACSEntry)
+	assert.Zero(t, sessions[0].Entry)
+	assert.Empty(t, sessions[0].Timing())
+}
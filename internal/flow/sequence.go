@@ -0,0 +1,257 @@
+package flow
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/gliwka/SAMLurai/internal/saml"
+)
+
+// Participant roles
+const (
+	RoleBrowser = "Browser"
+	RoleSP      = "SP"
+	RoleIdP     = "IdP"
+)
+
+// Participant is a party to the login: the browser, or a host acting as SP
+// or IdP. Hosts whose role the SAML messages do not reveal have no role.
+type Participant struct {
+	ID   string `json:"id"`
+	Role string `json:"role,omitempty"`
+	Host string `json:"host,omitempty"`
+}
+
+// Label names the participant in diagrams, e.g. "SP sp.example.com"
+func (p Participant) Label() string {
+	if p.Host == "" {
+		return p.ID
+	}
+	if p.Role == "" {
+		return p.Host
+	}
+	return p.Role + " " + p.Host
+}
+
+// Annotation is a SAML message carried by an arrow
+type Annotation struct {
+	Type    string `json:"type"`
+	Binding string `json:"binding,omitempty"`
+	Status  string `json:"status,omitempty"`
+}
+
+// String describes the message, e.g. "Response (HTTP-POST, Success)"
+func (a Annotation) String() string {
+	var details []string
+	if a.Binding != "" {
+		details = append(details, a.Binding)
+	}
+	if a.Status != "" {
+		details = append(details, a.Status)
+	}
+	if len(details) == 0 {
+		return a.Type
+	}
+	return fmt.Sprintf("%s (%s)", a.Type, strings.Join(details, ", "))
+}
+
+// Arrow is a request the browser sent or the reply it received
+type Arrow struct {
+	// Entry is the 1-based HAR entry of the request
+	Entry int    `json:"entry"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+
+	// Reply is set for the response to a request
+	Reply  bool   `json:"reply,omitempty"`
+	Method string `json:"method,omitempty"`
+	Path   string `json:"path,omitempty"`
+	Status int    `json:"status,omitempty"`
+
+	SAML []Annotation `json:"saml,omitempty"`
+}
+
+// Label describes the arrow, e.g. "GET /sso — AuthnRequest (HTTP-Redirect)"
+func (a Arrow) Label() string {
+	label := a.Method + " " + a.Path
+	if a.Reply {
+		label = fmt.Sprint(a.Status)
+	}
+	if len(a.SAML) > 0 {
+		messages := make([]string, len(a.SAML))
+		for i, m := range a.SAML {
+			messages[i] = m.String()
+		}
+		label += " — " + strings.Join(messages, ", ")
+	}
+	return label
+}
+
+// Sequence is the exchange between the browser, SPs and IdPs in a capture
+type Sequence struct {
+	Participants []Participant `json:"participants"`
+	Arrows       []Arrow       `json:"arrows"`
+}
+
+// entrySAML is the SAML found in the request and the response of an entry
+type entrySAML struct {
+	request, response []Annotation
+}
+
+// NewSequence builds the sequence of a capture. Hosts are told apart as SP
+// or IdP by the messages they send and receive: an AuthnRequest goes from
+// an SP to an IdP and a Response back. Entries are shown if they carry
+// SAML, or are redirects or HTML pages of an SP or IdP; other requests,
+// such as for scripts and images, are left out.
+func NewSequence(entries []saml.HAREntry) *Sequence {
+	extractor := saml.NewHARExtractor()
+	parser := saml.NewParser()
+
+	found := make([]entrySAML, len(entries))
+	roles := make(map[string]string)
+	assign := func(host, role string) {
+		if host != "" && roles[host] == "" {
+			roles[host] = role
+		}
+	}
+	for i, entry := range entries {
+		for _, extracted := range extractor.ExtractFromEntry(entry) {
+			annotation := Annotation{
+				Type:    strings.TrimSuffix(extracted.Type, " (Encrypted)"),
+				Binding: binding(extracted),
+			}
+			if info, err := parser.ParsePartial(extracted.DecodedXML); err == nil && info.Status != nil {
+				annotation.Status = info.Status.StatusCode
+			}
+
+			// The receiver of a request or Location, and the sender of a
+			// response, reveal their role by the message type
+			sender, receiver := "", ""
+			switch extracted.Source {
+			case "request-query", "request-body":
+				receiver = hostOf(extracted.URL)
+				found[i].request = append(found[i].request, annotation)
+			case "response-location":
+				sender, receiver = hostOf(entry.Request.URL), hostOf(extracted.URL)
+				found[i].response = append(found[i].response, annotation)
+			default:
+				sender = hostOf(entry.Request.URL)
+				found[i].response = append(found[i].response, annotation)
+			}
+			switch annotation.Type {
+			case "AuthnRequest":
+				assign(sender, RoleSP)
+				assign(receiver, RoleIdP)
+			case "Response":
+				assign(sender, RoleIdP)
+				assign(receiver, RoleSP)
+			}
+		}
+	}
+
+	seq := &Sequence{Arrows: []Arrow{}}
+	ids := map[string]string{}
+	for i, entry := range entries {
+		host := hostOf(entry.Request.URL)
+		carriesSAML := len(found[i].request)+len(found[i].response) > 0
+		if !carriesSAML && (roles[host] == "" || !isPage(entry)) {
+			continue
+		}
+		if _, ok := ids[host]; !ok {
+			ids[host] = ""
+		}
+
+		u, _ := url.Parse(entry.Request.URL)
+		path := "/"
+		if u != nil && u.Path != "" {
+			path = u.Path
+		}
+		seq.Arrows = append(seq.Arrows, Arrow{
+			Entry:  i + 1,
+			From:   RoleBrowser,
+			To:     host,
+			Method: entry.Request.Method,
+			Path:   path,
+			SAML:   found[i].request,
+		})
+		if entry.Response.Status != 0 {
+			seq.Arrows = append(seq.Arrows, Arrow{
+				Entry:  i + 1,
+				From:   host,
+				To:     RoleBrowser,
+				Reply:  true,
+				Status: entry.Response.Status,
+				SAML:   found[i].response,
+			})
+		}
+	}
+
+	seq.Participants = participants(ids, roles)
+	for i := range seq.Arrows {
+		if id, ok := ids[seq.Arrows[i].From]; ok {
+			seq.Arrows[i].From = id
+		}
+		if id, ok := ids[seq.Arrows[i].To]; ok {
+			seq.Arrows[i].To = id
+		}
+	}
+	return seq
+}
+
+// participants orders the hosts as SPs, IdPs and others after the browser
+// and fills in their IDs: the role, numbered from the second host with the
+// same role, or "Host" and a number
+func participants(ids map[string]string, roles map[string]string) []Participant {
+	hosts := make([]string, 0, len(ids))
+	for host := range ids {
+		hosts = append(hosts, host)
+	}
+	rank := map[string]int{RoleSP: 0, RoleIdP: 1, "": 2}
+	sort.Slice(hosts, func(i, j int) bool {
+		ri, rj := rank[roles[hosts[i]]], rank[roles[hosts[j]]]
+		if ri != rj {
+			return ri < rj
+		}
+		return hosts[i] < hosts[j]
+	})
+
+	list := []Participant{{ID: RoleBrowser, Role: RoleBrowser}}
+	counts := map[string]int{}
+	for _, host := range hosts {
+		role := roles[host]
+		counts[role]++
+		id := role
+		switch {
+		case role == "":
+			id = fmt.Sprintf("Host%d", counts[role])
+		case counts[role] > 1:
+			id = fmt.Sprintf("%s%d", role, counts[role])
+		}
+		ids[host] = id
+		list = append(list, Participant{ID: id, Role: role, Host: host})
+	}
+	return list
+}
+
+// binding names the binding a message was sent with
+func binding(extracted saml.ExtractedSAML) string {
+	switch {
+	case extracted.SimpleSign != nil:
+		return "HTTP-POST-SimpleSign"
+	case extracted.Source == "request-query" || extracted.Source == "response-location":
+		return "HTTP-Redirect"
+	default:
+		return "HTTP-POST"
+	}
+}
+
+// isPage reports whether an entry is a redirect or an HTML page, rather
+// than a resource the page loaded
+func isPage(entry saml.HAREntry) bool {
+	if entry.Response.Status/100 == 3 {
+		return true
+	}
+	return strings.HasPrefix(strings.ToLower(entry.Response.Content.MimeType), "text/html")
+}
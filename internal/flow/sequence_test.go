@@ -0,0 +1,101 @@
+package flow
+
+import (
+	"bytes"
+	"net/url"
+	"testing"
+
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSequence(t *testing.T) {
+	seq := NewSequence(testEntries("Success", 302))
+
+	assert.Equal(t, []Participant{
+		{ID: "Browser", Role: RoleBrowser},
+		{ID: "SP", Role: RoleSP, Host: "sp.example.com"},
+		{ID: "IdP", Role: RoleIdP, Host: "idp.example.com"},
+	}, seq.Participants)
+
+	var labels []string
+	for _, a := range seq.Arrows {
+		labels = append(labels, a.From+" → "+a.To+": "+a.Label())
+	}
+	assert.Equal(t, []string{
+		"Browser → IdP: GET /sso — AuthnRequest (HTTP-Redirect)",
+		"IdP → Browser: 200",
+		"Browser → IdP: POST /login",
+		"IdP → Browser: 200 — Response (HTTP-POST, Success)",
+		"Browser → SP: POST /acs — Response (HTTP-POST, Success)",
+		"SP → Browser: 302",
+	}, labels, "the SP's login page without content type and the app are left out")
+	assert.Equal(t, 2, seq.Arrows[0].Entry)
+	assert.True(t, seq.Arrows[1].Reply)
+}
+
+func TestNewSequence_RedirectFromSP(t *testing.T) {
+	location := "https://idp.example.com/sso?SAMLRequest=" + url.QueryEscape(deflatedAuthnRequest(t))
+	seq := NewSequence([]saml.HAREntry{
+		{
+			Request: saml.HARRequest{Method: "GET", URL: "https://sp.example.com/login"},
+			Response: saml.HARResponse{
+				Status:  302,
+				Headers: []saml.HARNameValue{{Name: "Location", Value: location}},
+			},
+		},
+		{
+			Request:  saml.HARRequest{Method: "GET", URL: "https://cdn.example.com/logo.png"},
+			Response: saml.HARResponse{Status: 200, Content: saml.HARContent{MimeType: "image/png"}},
+		},
+	})
+
+	require.Len(t, seq.Participants, 2, "the image is left out")
+	assert.Equal(t, Participant{ID: "SP", Role: RoleSP, Host: "sp.example.com"}, seq.Participants[1])
+	require.Len(t, seq.Arrows, 2)
+	assert.Equal(t, "SP", seq.Arrows[1].From)
+	assert.Equal(t, "302 — AuthnRequest (HTTP-Redirect)", seq.Arrows[1].Label())
+}
+
+func TestSequence_WriteMermaid(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, NewSequence(testEntries("Responder", 302)).WriteDiagram(&buf, DiagramMermaid))
+
+	assert.Equal(t, `sequenceDiagram
+    participant Browser as Browser
+    participant SP as SP<br/>sp.example.com
+    participant IdP as IdP<br/>idp.example.com
+    Browser->>IdP: GET /sso — AuthnRequest (HTTP-Redirect)
+    IdP-->>Browser: 200
+    Browser->>IdP: POST /login
+    IdP-->>Browser: 200 — Response (HTTP-POST, Responder)
+    Browser->>SP: POST /acs — Response (HTTP-POST, Responder)
+    SP-->>Browser: 302
+`, buf.String())
+}
+
+func TestSequence_WritePlantUML(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, NewSequence(testEntries("Success", 302)).WriteDiagram(&buf, DiagramPlantUML))
+
+	out := buf.String()
+	assert.Contains(t, out, "@startuml\nactor \"Browser\" as Browser\nparticipant \"SP\\nsp.example.com\" as SP\n")
+	assert.Contains(t, out, "Browser -> IdP : GET /sso — AuthnRequest (HTTP-Redirect)\n")
+	assert.Contains(t, out, "SP --> Browser : 302\n@enduml\n")
+}
+
+func TestSequence_WriteDiagram_Unknown(t *testing.T) {
+	err := NewSequence(nil).WriteDiagram(&bytes.Buffer{}, "dot")
+	assert.EqualError(t, err, `unknown diagram format "dot" (expected mermaid or plantuml)`)
+}
+
+func TestMermaidEscape(t *testing.T) {
+	assert.Equal(t, "GET /a#59;b#35;c", mermaidEscape("GET /a;b#c"))
+}
+
+func deflatedAuthnRequest(t *testing.T) string {
+	encoded, err := saml.NewDecoder().EncodeDeflate([]byte(`<samlp:AuthnRequest xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_q"/>`))
+	require.NoError(t, err)
+	return encoded
+}
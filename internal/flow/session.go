@@ -0,0 +1,161 @@
+package flow
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gliwka/SAMLurai/internal/saml"
+)
+
+// Session is the SP session a capture shows being established after a
+// Response was posted to the ACS
+type Session struct {
+	// ACSEntry is the 1-based HAR entry posting the Response
+	ACSEntry int    `json:"acs_entry"`
+	ACS      string `json:"acs"`
+
+	// Entry is the 1-based HAR entry whose response set the session
+	// cookies, or 0 if no cookie was set
+	Entry     int        `json:"entry,omitempty"`
+	Cookies   []string   `json:"cookies,omitempty"`
+	StartedAt *time.Time `json:"started_at,omitempty"`
+
+	// NotBefore and NotOnOrAfter are the validity window of the assertion
+	NotBefore    *time.Time `json:"not_before,omitempty"`
+	NotOnOrAfter *time.Time `json:"not_on_or_after,omitempty"`
+
+	// UsedAt is the first later HAR entry sending a session cookie back,
+	// or 0 if none did
+	UsedAt int `json:"used_at,omitempty"`
+}
+
+// InWindow reports whether the session began within the validity window
+// of the assertion. Sessions without a known start or window are assumed
+// to have.
+func (s Session) InWindow() bool {
+	if s.StartedAt == nil {
+		return true
+	}
+	if s.NotBefore != nil && s.StartedAt.Before(*s.NotBefore) {
+		return false
+	}
+	return s.NotOnOrAfter == nil || s.StartedAt.Before(*s.NotOnOrAfter)
+}
+
+// Timing describes when the session began relative to the validity window
+// of the assertion, e.g. "3s after NotBefore, 4m57s before NotOnOrAfter"
+func (s Session) Timing() string {
+	if s.StartedAt == nil || (s.NotBefore == nil && s.NotOnOrAfter == nil) {
+		return ""
+	}
+	start := *s.StartedAt
+	switch {
+	case s.NotBefore != nil && start.Before(*s.NotBefore):
+		return fmt.Sprintf("%s before the assertion became valid", s.NotBefore.Sub(start).Round(time.Second))
+	case s.NotOnOrAfter != nil && !start.Before(*s.NotOnOrAfter):
+		return fmt.Sprintf("%s after the assertion expired", start.Sub(*s.NotOnOrAfter).Round(time.Second))
+	}
+
+	var parts []string
+	if s.NotBefore != nil {
+		parts = append(parts, fmt.Sprintf("%s after NotBefore", start.Sub(*s.NotBefore).Round(time.Second)))
+	}
+	if s.NotOnOrAfter != nil {
+		parts = append(parts, fmt.Sprintf("%s before NotOnOrAfter", s.NotOnOrAfter.Sub(start).Round(time.Second)))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Sessions finds the sessions established after each ACS POST. The session
+// cookie is taken from the ACS response or, for SPs that set it on a
+// redirect, the first later response from the same host that sets one,
+// up to the next ACS POST.
+func Sessions(entries []saml.HAREntry) []Session {
+	extractor := saml.NewHARExtractor()
+	parser := saml.NewParser()
+
+	var acsEntries []int
+	responses := make(map[int]saml.ExtractedSAML)
+	for i, entry := range entries {
+		if !strings.EqualFold(entry.Request.Method, "POST") {
+			continue
+		}
+		for _, extracted := range extractor.ExtractFromEntry(entry) {
+			if extracted.Source == "request-body" && extracted.Type == "Response" {
+				acsEntries = append(acsEntries, i)
+				responses[i] = extracted
+				break
+			}
+		}
+	}
+
+	var sessions []Session
+	for n, i := range acsEntries {
+		session := Session{ACSEntry: i + 1, ACS: entries[i].Request.URL}
+		if info, err := parser.Parse(responses[i].DecodedXML); err == nil && info.Assertion != nil && info.Assertion.Conditions != nil {
+			session.NotBefore = info.Assertion.Conditions.NotBefore
+			session.NotOnOrAfter = info.Assertion.Conditions.NotOnOrAfter
+		}
+
+		end := len(entries)
+		if n+1 < len(acsEntries) {
+			end = acsEntries[n+1]
+		}
+		host := hostOf(entries[i].Request.URL)
+		var set []saml.HARCookie
+		for j := i; j < end; j++ {
+			if hostOf(entries[j].Request.URL) != host {
+				continue
+			}
+			if set = entries[j].Response.SetCookies(); len(set) > 0 {
+				session.Entry = j + 1
+				session.StartedAt = startedAt(entries[j])
+				break
+			}
+		}
+		for _, c := range set {
+			session.Cookies = append(session.Cookies, c.Name)
+		}
+
+		if session.Entry > 0 {
+			session.UsedAt = firstUse(entries, session.Entry, set)
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions
+}
+
+// firstUse returns the first HAR entry after the 1-based entry from that
+// sends back one of the cookies, or 0
+func firstUse(entries []saml.HAREntry, from int, cookies []saml.HARCookie) int {
+	for j := from; j < len(entries); j++ {
+		for _, sent := range entries[j].Request.SentCookies() {
+			for _, c := range cookies {
+				if sent.Name == c.Name && sent.Value == c.Value {
+					return j + 1
+				}
+			}
+		}
+	}
+	return 0
+}
+
+// hostOf returns the lowercased host of rawURL
+func hostOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Host)
+}
+
+// startedAt returns when a HAR entry was started, or nil
+func startedAt(entry saml.HAREntry) *time.Time {
+	t, err := time.Parse(time.RFC3339Nano, entry.StartedDateTime)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
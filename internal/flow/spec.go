@@ -0,0 +1,109 @@
+// Package flow compares the requests in a HAR capture against a reference
+// definition of a healthy login flow.
+package flow
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Spec is the expected sequence of a healthy login, e.g.
+//
+//	name: Okta login
+//	steps:
+//	  - name: AuthnRequest
+//	    saml: AuthnRequest
+//	  - name: IdP login
+//	    url: https://idp.example.com/login
+//	    status: 2xx
+//	  - name: Response
+//	    saml: Response
+//	    saml_status: Success
+//	  - name: ACS redirect
+//	    url: https://sp.example.com/acs
+//	    status: 302
+//	  - name: App
+//	    url: https://app.example.com/
+//	    status: 200
+type Spec struct {
+	Name  string `yaml:"name" json:"name,omitempty"`
+	Steps []Step `yaml:"steps" json:"steps"`
+}
+
+// Step is one expected request of the flow. All criteria that are set must
+// hold for a HAR entry to match.
+type Step struct {
+	Name string `yaml:"name" json:"name"`
+
+	// Method is the HTTP method, e.g. POST
+	Method string `yaml:"method" json:"method,omitempty"`
+
+	// URL is a prefix of the request URL
+	URL string `yaml:"url" json:"url,omitempty"`
+
+	// URLPattern is a regular expression matched against the request URL
+	URLPattern string `yaml:"url_pattern" json:"url_pattern,omitempty"`
+
+	// Status is the response status code, either exact ("302") or a class
+	// ("3xx")
+	Status string `yaml:"status" json:"status,omitempty"`
+
+	// SAML is the type of a SAML message carried by the entry, e.g.
+	// AuthnRequest or Response
+	SAML string `yaml:"saml" json:"saml,omitempty"`
+
+	// SAMLStatus is the status code of that SAML message, e.g. Success
+	SAMLStatus string `yaml:"saml_status" json:"saml_status,omitempty"`
+
+	urlPattern *regexp.Regexp
+}
+
+var statusPattern = regexp.MustCompile(`^[1-5]([0-9]{2}|xx)$`)
+
+// LoadSpec reads and validates a YAML flow definition
+func LoadSpec(path string) (*Spec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spec: %w", err)
+	}
+	return ParseSpec(data)
+}
+
+// ParseSpec parses and validates a YAML flow definition
+func ParseSpec(data []byte) (*Spec, error) {
+	var spec Spec
+	if err := yaml.Unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("failed to parse spec: %w", err)
+	}
+	if len(spec.Steps) == 0 {
+		return nil, errors.New("spec has no steps")
+	}
+
+	for i := range spec.Steps {
+		step := &spec.Steps[i]
+		if step.Name == "" {
+			step.Name = fmt.Sprintf("step %d", i+1)
+		}
+		if step.SAMLStatus != "" && step.SAML == "" {
+			return nil, fmt.Errorf("%s: saml_status requires saml", step.Name)
+		}
+		if step.Method == "" && step.URL == "" && step.URLPattern == "" && step.Status == "" && step.SAML == "" {
+			return nil, fmt.Errorf("%s: at least one of method, url, url_pattern, status or saml is required", step.Name)
+		}
+		if step.Status != "" && !statusPattern.MatchString(step.Status) {
+			return nil, fmt.Errorf("%s: invalid status %q: expected a code like 302 or a class like 3xx", step.Name, step.Status)
+		}
+		if step.URLPattern != "" {
+			re, err := regexp.Compile(step.URLPattern)
+			if err != nil {
+				return nil, fmt.Errorf("%s: invalid url_pattern: %w", step.Name, err)
+			}
+			step.urlPattern = re
+		}
+	}
+	return &spec, nil
+}
@@ -0,0 +1,46 @@
+package graph
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// nodeShapes distinguishes roles in the rendered graph
+var nodeShapes = map[string]string{
+	RoleSP:       "box",
+	RoleIdP:      "ellipse",
+	RoleEndpoint: "note",
+}
+
+// WriteDOT renders the graph in the Graphviz DOT language, e.g. for
+// `dot -Tsvg`. Edges are labeled with the message type and count.
+func (g *Graph) WriteDOT(w io.Writer) error {
+	if _, err := fmt.Fprintln(w, "digraph federation {\n  rankdir=LR;"); err != nil {
+		return err
+	}
+	for _, n := range g.Nodes {
+		label := `"` + escape(n.ID) + `\n(` + n.Role + `)"`
+		if _, err := fmt.Fprintf(w, "  %s [shape=%s, label=%s];\n", quote(n.ID), nodeShapes[n.Role], label); err != nil {
+			return err
+		}
+	}
+	for _, e := range g.Edges {
+		label := fmt.Sprintf("%s (%d)", e.Type, e.Count)
+		if _, err := fmt.Fprintf(w, "  %s -> %s [label=%s];\n", quote(e.From), quote(e.To), quote(label)); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintln(w, "}")
+	return err
+}
+
+// quote returns s as a DOT string literal
+func quote(s string) string {
+	return `"` + escape(s) + `"`
+}
+
+// escape escapes the characters that end or escape a DOT string
+func escape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s)
+}
@@ -0,0 +1,226 @@
+// Package graph maps the federation topology observed in SAML captures:
+// which SPs and IdPs exchanged which messages.
+package graph
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gliwka/SAMLurai/internal/saml"
+)
+
+// Entity roles
+const (
+	RoleSP       = "SP"
+	RoleIdP      = "IdP"
+	RoleEndpoint = "endpoint"
+)
+
+// Node is an entity observed in the captures. Endpoints are URLs that
+// messages were sent to but that could not be attributed to an entity ID.
+type Node struct {
+	ID   string `json:"id"`
+	Role string `json:"role"`
+}
+
+// Edge counts the messages of one type sent from one entity to another
+type Edge struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Type  string `json:"type"`
+	Count int    `json:"count"`
+}
+
+// Graph is the topology observed across captures
+type Graph struct {
+	Files       int    `json:"files"`
+	FilesFailed int    `json:"files_failed"`
+	Nodes       []Node `json:"nodes"`
+	Edges       []Edge `json:"edges"`
+}
+
+// message is what the collector retains of a SAML message
+type message struct {
+	typ          string
+	id           string
+	issuer       string
+	inResponseTo string
+	destination  string
+	acs          string
+	audience     string
+}
+
+// Collector gathers messages from captures. Senders and receivers are only
+// resolved in Graph, so requests can be attributed using responses found
+// in later files.
+type Collector struct {
+	files       int
+	filesFailed int
+	messages    []message
+}
+
+// NewCollector creates an empty Collector
+func NewCollector() *Collector {
+	return &Collector{}
+}
+
+// CollectDir walks dir recursively and adds every file
+func (c *Collector) CollectDir(dir string) error {
+	return filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		c.AddFile(path)
+		return nil
+	})
+}
+
+// AddFile reads a HAR, SAML-tracer, XML or base64 file and adds its messages.
+// Files that cannot be read or contain no SAML are counted as failed.
+func (c *Collector) AddFile(path string) {
+	c.files++
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		c.filesFailed++
+		return
+	}
+
+	content := strings.TrimSpace(string(data))
+	if saml.LooksLikeHAR(content) || saml.LooksLikeSAMLTracer(content) {
+		results, err := saml.NewHARExtractor().Extract([]byte(content))
+		if err != nil || len(results) == 0 {
+			c.filesFailed++
+			return
+		}
+		for _, r := range results {
+			// The IdP's form is posted onwards by the browser; count the
+			// message once, when it is delivered
+			if r.Source == "response-body" {
+				continue
+			}
+			c.AddXML(r.DecodedXML)
+		}
+		return
+	}
+
+	xmlData, err := saml.NewDecoder().SmartDecode(content)
+	if err != nil || !c.AddXML(xmlData) {
+		c.filesFailed++
+	}
+}
+
+// AddXML adds a single SAML document. Only the envelope of encrypted
+// assertions is used. It returns false if the document could not be parsed.
+func (c *Collector) AddXML(xmlData []byte) bool {
+	info, err := saml.NewParser().ParsePartial(xmlData)
+	if err != nil {
+		return false
+	}
+
+	m := message{
+		typ:          strings.TrimSuffix(info.Type, " (Encrypted)"),
+		id:           info.ID,
+		issuer:       info.Issuer,
+		inResponseTo: info.InResponseTo,
+		destination:  info.Destination,
+		acs:          info.AssertionConsumerServiceURL,
+	}
+	for a := info; a != nil; a = a.Assertion {
+		if m.issuer == "" {
+			m.issuer = a.Issuer
+		}
+		if a.Conditions != nil && len(a.Conditions.AudienceRestriction) > 0 && m.audience == "" {
+			m.audience = a.Conditions.AudienceRestriction[0]
+		}
+	}
+	c.messages = append(c.messages, m)
+	return true
+}
+
+// Graph resolves the sender and receiver of every message and counts the
+// edges between them. Receivers are taken from, in order: the audience of
+// an assertion, the IdP that answered a request, and the entity whose
+// endpoint the message was sent to. Otherwise the destination URL is used.
+func (c *Collector) Graph() *Graph {
+	requests := map[string]message{}
+	for _, m := range c.messages {
+		if m.typ == "AuthnRequest" && m.id != "" {
+			requests[m.id] = m
+		}
+	}
+
+	// Learn which entity sits behind each endpoint
+	endpoints := map[string]string{}
+	for _, m := range c.messages {
+		if m.typ == "AuthnRequest" && m.acs != "" && m.issuer != "" {
+			endpoints[m.acs] = m.issuer
+		}
+		if req, ok := requests[m.inResponseTo]; ok && req.destination != "" && m.issuer != "" {
+			endpoints[req.destination] = m.issuer
+		}
+	}
+
+	g := &Graph{Files: c.files, FilesFailed: c.filesFailed, Nodes: []Node{}, Edges: []Edge{}}
+	roles := map[string]string{}
+	setRole := func(id, role string) {
+		// An entity seen as IdP anywhere stays one; endpoints never
+		// override entities
+		if current, ok := roles[id]; !ok || current == RoleEndpoint || (current == RoleSP && role == RoleIdP) {
+			roles[id] = role
+		}
+	}
+	counts := map[Edge]int{}
+	for _, m := range c.messages {
+		if m.issuer == "" {
+			continue
+		}
+		to := m.audience
+		if to == "" {
+			to = endpoints[m.destination]
+		}
+		if to == "" {
+			to = m.destination
+		}
+		if to == "" {
+			continue
+		}
+
+		fromRole, toRole := RoleSP, RoleIdP
+		if m.typ == "Response" || m.typ == "Assertion" {
+			fromRole, toRole = RoleIdP, RoleSP
+		}
+		if to == m.destination && endpoints[m.destination] == "" {
+			toRole = RoleEndpoint
+		}
+		setRole(m.issuer, fromRole)
+		setRole(to, toRole)
+		counts[Edge{From: m.issuer, To: to, Type: m.typ}]++
+	}
+
+	for id, role := range roles {
+		g.Nodes = append(g.Nodes, Node{ID: id, Role: role})
+	}
+	sort.Slice(g.Nodes, func(i, j int) bool { return g.Nodes[i].ID < g.Nodes[j].ID })
+
+	for edge, count := range counts {
+		edge.Count = count
+		g.Edges = append(g.Edges, edge)
+	}
+	sort.Slice(g.Edges, func(i, j int) bool {
+		a, b := g.Edges[i], g.Edges[j]
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		if a.To != b.To {
+			return a.To < b.To
+		}
+		return a.Type < b.Type
+	})
+	return g
+}
@@ -0,0 +1,59 @@
+package graph
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testRequest  = `<samlp:AuthnRequest xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_q%s" Destination="https://idp.example.com/sso" AssertionConsumerServiceURL="https://sp.example.com/acs"><saml:Issuer>https://sp.example.com</saml:Issuer></samlp:AuthnRequest>`
+	testResponse = `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_r" InResponseTo="_q1" Destination="https://sp.example.com/acs"><saml:Issuer>https://idp.example.com</saml:Issuer><saml:Assertion ID="_a"><saml:Conditions><saml:AudienceRestriction><saml:Audience>https://sp.example.com</saml:Audience></saml:AudienceRestriction></saml:Conditions></saml:Assertion></samlp:Response>`
+	otherRequest = `<samlp:AuthnRequest xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_o" Destination="https://login.other.example/saml"><saml:Issuer>https://app.example.com</saml:Issuer></samlp:AuthnRequest>`
+)
+
+func TestCollector_Graph(t *testing.T) {
+	c := NewCollector()
+	// The response names the request it answers, which attributes both
+	// requests to the same IdP endpoint
+	require.True(t, c.AddXML([]byte(strings.Replace(testRequest, "%s", "1", 1))))
+	require.True(t, c.AddXML([]byte(strings.Replace(testRequest, "%s", "2", 1))))
+	require.True(t, c.AddXML([]byte(testResponse)))
+	require.True(t, c.AddXML([]byte(otherRequest)))
+	assert.False(t, c.AddXML([]byte("not xml")))
+
+	g := c.Graph()
+	assert.Equal(t, []Node{
+		{ID: "https://app.example.com", Role: RoleSP},
+		{ID: "https://idp.example.com", Role: RoleIdP},
+		{ID: "https://login.other.example/saml", Role: RoleEndpoint},
+		{ID: "https://sp.example.com", Role: RoleSP},
+	}, g.Nodes)
+	assert.Equal(t, []Edge{
+		{From: "https://app.example.com", To: "https://login.other.example/saml", Type: "AuthnRequest", Count: 1},
+		{From: "https://idp.example.com", To: "https://sp.example.com", Type: "Response", Count: 1},
+		{From: "https://sp.example.com", To: "https://idp.example.com", Type: "AuthnRequest", Count: 2},
+	}, g.Edges)
+}
+
+func TestGraph_WriteDOT(t *testing.T) {
+	g := &Graph{
+		Nodes: []Node{
+			{ID: "https://idp.example.com", Role: RoleIdP},
+			{ID: `urn:"sp"`, Role: RoleSP},
+		},
+		Edges: []Edge{{From: "https://idp.example.com", To: `urn:"sp"`, Type: "Response", Count: 3}},
+	}
+
+	var b strings.Builder
+	require.NoError(t, g.WriteDOT(&b))
+	assert.Equal(t, `digraph federation {
+  rankdir=LR;
+  "https://idp.example.com" [shape=ellipse, label="https://idp.example.com\n(IdP)"];
+  "urn:\"sp\"" [shape=box, label="urn:\"sp\"\n(SP)"];
+  "https://idp.example.com" -> "urn:\"sp\"" [label="Response (3)"];
+}
+`, b.String())
+}
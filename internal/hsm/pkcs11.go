@@ -0,0 +1,150 @@
+// Package hsm decrypts with private keys held in a hardware security module
+// or smartcard, which never leave the device. Operations go through
+// OpenSC's pkcs11-tool, so any PKCS#11 module can be used without linking
+// against it.
+package hsm
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Config selects a key on a PKCS#11 token
+type Config struct {
+	// Module is the path of the PKCS#11 library, e.g.
+	// /usr/lib/softhsm/libsofthsm2.so
+	Module string
+
+	// Slot is the slot ID of the token; the first slot with a token is used
+	// if empty
+	Slot string
+
+	// PIN is the user PIN of the token; PINEnv is used if empty
+	PIN string
+
+	// Label selects the private key by label when the token holds several
+	Label string
+}
+
+// Key is a private key on a PKCS#11 token. It implements crypto.Decrypter,
+// so it can unwrap the session keys of encrypted assertions.
+type Key struct {
+	config Config
+
+	// run executes pkcs11-tool with args and the additional environment
+	// variables env, passing stdin
+	run func(args, env []string, stdin []byte) ([]byte, error)
+}
+
+// PINEnv is the environment variable the PIN is read from if Config has
+// none. pkcs11-tool is passed the PIN in it too, so the PIN does not
+// appear on a command line, where other local users could read it.
+const PINEnv = "SAMLURAI_PKCS11_PIN"
+
+// New returns the key selected by config. The token is first accessed by
+// Decrypt.
+func New(config Config) (*Key, error) {
+	if config.Module == "" {
+		return nil, errors.New("a PKCS#11 module is required")
+	}
+	if config.PIN == "" {
+		config.PIN = os.Getenv(PINEnv)
+	}
+	return &Key{config: config, run: runTool}, nil
+}
+
+// Public is not available without reading the key from the token, which
+// decryption does not need
+func (k *Key) Public() crypto.PublicKey {
+	return nil
+}
+
+// Decrypt decrypts msg on the token with RSA-PKCS (for
+// *rsa.PKCS1v15DecryptOptions) or RSA-PKCS-OAEP (for *rsa.OAEPOptions)
+func (k *Key) Decrypt(_ io.Reader, msg []byte, opts crypto.DecrypterOpts) ([]byte, error) {
+	args := []string{"--module", k.config.Module, "--decrypt"}
+	var env []string
+	if k.config.Slot != "" {
+		args = append(args, "--slot", k.config.Slot)
+	}
+	if k.config.PIN != "" {
+		args = append(args, "--login", "--pin", "env:"+PINEnv)
+		env = append(env, PINEnv+"="+k.config.PIN)
+	}
+	if k.config.Label != "" {
+		args = append(args, "--label", k.config.Label)
+	}
+
+	switch o := opts.(type) {
+	case *rsa.PKCS1v15DecryptOptions:
+		args = append(args, "--mechanism", "RSA-PKCS")
+	case *rsa.OAEPOptions:
+		mgf := o.MGFHash
+		if mgf == 0 {
+			mgf = o.Hash
+		}
+		hashName, ok := toolHashes[o.Hash]
+		if !ok {
+			return nil, fmt.Errorf("unsupported OAEP hash %v", o.Hash)
+		}
+		mgfName, ok := toolMGFs[mgf]
+		if !ok {
+			return nil, fmt.Errorf("unsupported OAEP mask hash %v", mgf)
+		}
+		args = append(args, "--mechanism", "RSA-PKCS-OAEP", "--hash-algorithm", hashName, "--mgf", mgfName)
+	default:
+		return nil, fmt.Errorf("unsupported decryption options %T", opts)
+	}
+
+	return k.run(args, env, msg)
+}
+
+// toolHashes and toolMGFs map hashes to their pkcs11-tool names
+var (
+	toolHashes = map[crypto.Hash]string{
+		crypto.SHA1:   "SHA-1",
+		crypto.SHA256: "SHA256",
+		crypto.SHA384: "SHA384",
+		crypto.SHA512: "SHA512",
+	}
+	toolMGFs = map[crypto.Hash]string{
+		crypto.SHA1:   "MGF1-SHA1",
+		crypto.SHA256: "MGF1-SHA256",
+		crypto.SHA384: "MGF1-SHA384",
+		crypto.SHA512: "MGF1-SHA512",
+	}
+)
+
+// runTool runs pkcs11-tool, returning its output or its error message
+func runTool(args, env []string, stdin []byte) ([]byte, error) {
+	path, err := exec.LookPath("pkcs11-tool")
+	if err != nil {
+		return nil, errors.New("pkcs11-tool not found: install OpenSC")
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(path, args...)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("pkcs11-tool: %s", lastLine(msg))
+		}
+		return nil, fmt.Errorf("pkcs11-tool: %w", err)
+	}
+	return stdout.Bytes(), nil
+}
+
+// lastLine returns the last line of tool output, which holds the error
+func lastLine(s string) string {
+	return s[strings.LastIndex(s, "\n")+1:]
+}
@@ -0,0 +1,126 @@
+package hsm
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKey_Decrypt(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   Config
+		opts     crypto.DecrypterOpts
+		wantArgs []string
+		wantEnv  []string
+	}{
+		{
+			name:     "PKCS#1 v1.5",
+			config:   Config{Module: "/usr/lib/softhsm/libsofthsm2.so"},
+			opts:     &rsa.PKCS1v15DecryptOptions{},
+			wantArgs: []string{"--module", "/usr/lib/softhsm/libsofthsm2.so", "--decrypt", "--mechanism", "RSA-PKCS"},
+		},
+		{
+			name:   "OAEP on a selected key",
+			config: Config{Module: "p11.so", Slot: "0", PIN: "1234", Label: "sp-encryption"},
+			opts:   &rsa.OAEPOptions{Hash: crypto.SHA256},
+			wantArgs: []string{"--module", "p11.so", "--decrypt", "--slot", "0", "--login", "--pin", "env:SAMLURAI_PKCS11_PIN", "--label", "sp-encryption",
+				"--mechanism", "RSA-PKCS-OAEP", "--hash-algorithm", "SHA256", "--mgf", "MGF1-SHA256"},
+			wantEnv: []string{"SAMLURAI_PKCS11_PIN=1234"},
+		},
+		{
+			name:     "OAEP with SHA-1 mask",
+			config:   Config{Module: "p11.so"},
+			opts:     &rsa.OAEPOptions{Hash: crypto.SHA256, MGFHash: crypto.SHA1},
+			wantArgs: []string{"--module", "p11.so", "--decrypt", "--mechanism", "RSA-PKCS-OAEP", "--hash-algorithm", "SHA256", "--mgf", "MGF1-SHA1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := New(tt.config)
+			require.NoError(t, err)
+
+			var gotArgs, gotEnv []string
+			var gotStdin []byte
+			key.run = func(args, env []string, stdin []byte) ([]byte, error) {
+				gotArgs, gotEnv, gotStdin = args, env, stdin
+				return []byte("session key"), nil
+			}
+
+			plaintext, err := key.Decrypt(rand.Reader, []byte("wrapped"), tt.opts)
+			require.NoError(t, err)
+			assert.Equal(t, []byte("session key"), plaintext)
+			assert.Equal(t, tt.wantArgs, gotArgs)
+			assert.Equal(t, tt.wantEnv, gotEnv)
+			assert.Equal(t, []byte("wrapped"), gotStdin)
+		})
+	}
+}
+
+func TestKey_DecryptErrors(t *testing.T) {
+	_, err := New(Config{})
+	assert.EqualError(t, err, "a PKCS#11 module is required")
+
+	key, err := New(Config{Module: "p11.so"})
+	require.NoError(t, err)
+	key.run = func([]string, []string, []byte) ([]byte, error) {
+		return nil, errors.New("pkcs11-tool: error: PKCS11 function C_Login failed: rv = CKR_PIN_INCORRECT (0xa0)")
+	}
+
+	_, err = key.Decrypt(rand.Reader, nil, &rsa.OAEPOptions{Hash: crypto.MD5})
+	assert.EqualError(t, err, "unsupported OAEP hash MD5")
+
+	_, err = key.Decrypt(rand.Reader, nil, &rsa.PKCS1v15DecryptOptions{})
+	assert.ErrorContains(t, err, "CKR_PIN_INCORRECT")
+}
+
+// TestRunTool_PINNotInArgs runs a stand-in pkcs11-tool that records its
+// command line and environment
+func TestRunTool_PINNotInArgs(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the stand-in pkcs11-tool is a shell script")
+	}
+	dir := t.TempDir()
+	script := "#!/bin/sh\necho \"$@\" > " + filepath.Join(dir, "args") + "\necho \"$" + PINEnv + "\" > " + filepath.Join(dir, "pin") + "\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "pkcs11-tool"), []byte(script), 0755))
+	t.Setenv("PATH", dir)
+
+	key, err := New(Config{Module: "p11.so", PIN: "secret-pin"})
+	require.NoError(t, err)
+	_, err = key.Decrypt(rand.Reader, []byte("wrapped"), &rsa.PKCS1v15DecryptOptions{})
+	require.NoError(t, err)
+
+	args, err := os.ReadFile(filepath.Join(dir, "args"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(args), "secret-pin")
+	assert.Contains(t, string(args), "--pin env:"+PINEnv)
+	pin, err := os.ReadFile(filepath.Join(dir, "pin"))
+	require.NoError(t, err)
+	assert.Equal(t, "secret-pin\n", string(pin))
+}
+
+func TestNew_PINFromEnv(t *testing.T) {
+	t.Setenv(PINEnv, "4321")
+	key, err := New(Config{Module: "p11.so"})
+	require.NoError(t, err)
+
+	var gotArgs, gotEnv []string
+	key.run = func(args, env []string, _ []byte) ([]byte, error) {
+		gotArgs, gotEnv = args, env
+		return nil, nil
+	}
+	_, err = key.Decrypt(rand.Reader, nil, &rsa.PKCS1v15DecryptOptions{})
+	require.NoError(t, err)
+	assert.Contains(t, gotArgs, "--login")
+	assert.NotContains(t, gotArgs, "4321")
+	assert.Equal(t, []string{"SAMLURAI_PKCS11_PIN=4321"}, gotEnv)
+}
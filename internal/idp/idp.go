@@ -0,0 +1,342 @@
+// Package idp implements a minimal SAML identity provider for end-to-end
+// tests of service providers. It signs in a single, configured test user
+// without asking for credentials.
+package idp
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/beevik/etree"
+	"github.com/gliwka/SAMLurai/internal/log"
+	"github.com/gliwka/SAMLurai/internal/metrics"
+	"github.com/gliwka/SAMLurai/internal/saml"
+)
+
+// Endpoint paths, relative to the base URL
+const (
+	MetadataPath = "/metadata"
+	SSOPath      = "/sso"
+	LoginPath    = "/login"
+)
+
+// Config describes the test IdP
+type Config struct {
+	// EntityID is the entity ID of the IdP
+	EntityID string
+
+	// BaseURL is the URL the IdP is reachable at, used for the endpoints
+	// in its metadata
+	BaseURL string
+
+	// Key and Cert sign Responses and Assertions with SigAlg
+	Key    crypto.Signer
+	Cert   *x509.Certificate
+	SigAlg string
+
+	// NameID identifies the test user. If NameIDFormat is empty, the
+	// format requested in the NameIDPolicy of the AuthnRequest is used.
+	NameID       string
+	NameIDFormat string
+
+	// Attributes are sent in every assertion
+	Attributes []saml.Attribute
+
+	// SignAssertion and SignResponse choose what is signed
+	SignAssertion bool
+	SignResponse  bool
+
+	// EncryptionCert, if set, encrypts assertions for the SP
+	EncryptionCert *x509.Certificate
+
+	// ACS receives Responses to AuthnRequests without an
+	// AssertionConsumerServiceURL, and IdP-initiated Responses
+	ACS string
+
+	// Audience is the entity ID of the SP; empty uses the Issuer of the
+	// AuthnRequest
+	Audience string
+
+	// Lifetime is how long assertions are valid; zero means 5 minutes
+	Lifetime time.Duration
+}
+
+// Issued describes a Response sent by the IdP
+type Issued struct {
+	// RequestID is the ID of the AuthnRequest; empty for IdP-initiated
+	// logins
+	RequestID string `json:"request_id,omitempty"`
+
+	// Binding is the binding the AuthnRequest arrived with
+	Binding string `json:"binding,omitempty"`
+
+	ResponseID string `json:"response_id"`
+	Audience   string `json:"audience"`
+	ACS        string `json:"acs"`
+	NameID     string `json:"name_id"`
+	Signed     bool   `json:"signed"`
+	Encrypted  bool   `json:"encrypted"`
+	RelayState string `json:"relay_state,omitempty"`
+
+	// XML is the Response as sent
+	XML string `json:"xml"`
+}
+
+// Server serves the IdP endpoints
+type Server struct {
+	cfg     Config
+	mux     *http.ServeMux
+	onIssue func(Issued)
+	metrics *metrics.Metrics
+}
+
+// NewServer creates an IdP for cfg
+func NewServer(cfg Config) (*Server, error) {
+	if cfg.EntityID == "" {
+		return nil, errors.New("an entity ID is required")
+	}
+	if cfg.NameID == "" {
+		return nil, errors.New("a NameID is required")
+	}
+	if (cfg.SignAssertion || cfg.SignResponse) && (cfg.Key == nil || cfg.Cert == nil) {
+		return nil, errors.New("signing requires a key and certificate")
+	}
+	cfg.BaseURL = strings.TrimSuffix(cfg.BaseURL, "/")
+
+	s := &Server{cfg: cfg, mux: http.NewServeMux()}
+	s.mux.HandleFunc(MetadataPath, s.handleMetadata)
+	s.mux.HandleFunc(SSOPath, s.handleSSO)
+	s.mux.HandleFunc(LoginPath, s.handleLogin)
+	return s, nil
+}
+
+// OnIssue calls fn for every Response sent
+func (s *Server) OnIssue(fn func(Issued)) *Server {
+	s.onIssue = fn
+	return s
+}
+
+// WithMetrics counts the AuthnRequests received in m and serves them at
+// metrics.Path
+func (s *Server) WithMetrics(m *metrics.Metrics) *Server {
+	s.metrics = m
+	s.mux.Handle(metrics.Path, m)
+	return s
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Metadata returns the IdP's EntityDescriptor
+func (s *Server) Metadata() ([]byte, error) {
+	doc := etree.NewDocument()
+	doc.CreateProcInst("xml", `version="1.0" encoding="UTF-8"`)
+	entity := doc.CreateElement("md:EntityDescriptor")
+	entity.CreateAttr("xmlns:md", saml.MetadataNamespace)
+	entity.CreateAttr("xmlns:ds", saml.XMLDSigNamespace)
+	entity.CreateAttr("entityID", s.cfg.EntityID)
+
+	idp := entity.CreateElement("md:IDPSSODescriptor")
+	idp.CreateAttr("WantAuthnRequestsSigned", "false")
+	idp.CreateAttr("protocolSupportEnumeration", saml.SAMLPNamespace)
+	if s.cfg.Cert != nil {
+		kd := idp.CreateElement("md:KeyDescriptor")
+		kd.CreateAttr("use", "signing")
+		kd.CreateElement("ds:KeyInfo").CreateElement("ds:X509Data").CreateElement("ds:X509Certificate").
+			SetText(base64.StdEncoding.EncodeToString(s.cfg.Cert.Raw))
+	}
+	if s.cfg.NameIDFormat != "" {
+		idp.CreateElement("md:NameIDFormat").SetText(s.cfg.NameIDFormat)
+	}
+	for _, binding := range []string{saml.BindingHTTPRedirect, saml.BindingHTTPPost} {
+		sso := idp.CreateElement("md:SingleSignOnService")
+		sso.CreateAttr("Binding", binding)
+		sso.CreateAttr("Location", s.cfg.BaseURL+SSOPath)
+	}
+
+	doc.Indent(2)
+	return doc.WriteToBytes()
+}
+
+func (s *Server) handleMetadata(w http.ResponseWriter, r *http.Request) {
+	data, err := s.Metadata()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/samlmetadata+xml")
+	_, _ = w.Write(data)
+}
+
+// handleSSO answers an AuthnRequest sent with the Redirect or POST binding
+// with a Response posted to the SP
+func (s *Server) handleSSO(w http.ResponseWriter, r *http.Request) {
+	binding := saml.BindingHTTPRedirect
+	values := r.URL.Query()
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		binding = saml.BindingHTTPPost
+		if err := r.ParseForm(); err != nil {
+			s.reject(w, http.StatusBadRequest, fmt.Errorf("failed to parse form: %w", err))
+			return
+		}
+		values = r.PostForm
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	encoded := values.Get("SAMLRequest")
+	if encoded == "" {
+		s.reject(w, http.StatusBadRequest, errors.New("no SAMLRequest parameter"))
+		return
+	}
+	xmlData, err := saml.NewDecoder().SmartDecode(encoded)
+	if err != nil {
+		s.metrics.Message("")
+		s.metrics.ParseFailure()
+		s.reject(w, http.StatusBadRequest, fmt.Errorf("failed to decode SAMLRequest: %w", err))
+		return
+	}
+	req, err := saml.NewParser().Parse(xmlData)
+	if err != nil {
+		s.metrics.Message("")
+		s.metrics.ParseFailure()
+		s.reject(w, http.StatusBadRequest, err)
+		return
+	}
+	s.metrics.Message(req.Type)
+	// POST binding requests carry their signature in the XML
+	if s.metrics != nil && binding == saml.BindingHTTPPost && saml.VerifyMessage(xmlData, nil).Verdict == saml.VerdictSigFail {
+		s.metrics.SignatureFailure()
+	}
+	if req.Type != "AuthnRequest" {
+		s.reject(w, http.StatusBadRequest, fmt.Errorf("expected an AuthnRequest, got %s", req.Type))
+		return
+	}
+	if req.ProtocolBinding != "" && req.ProtocolBinding != saml.BindingHTTPPost {
+		s.reject(w, http.StatusBadRequest, fmt.Errorf("unsupported ProtocolBinding %s; only HTTP-POST is supported", req.ProtocolBinding))
+		return
+	}
+	log.Debug("received AuthnRequest", "id", req.ID, "issuer", req.Issuer, "binding", binding)
+
+	acs := req.AssertionConsumerServiceURL
+	if acs == "" {
+		acs = s.cfg.ACS
+	}
+	if acs == "" {
+		s.reject(w, http.StatusBadRequest, errors.New("AuthnRequest has no AssertionConsumerServiceURL and no default ACS is configured"))
+		return
+	}
+	audience := s.cfg.Audience
+	if audience == "" {
+		audience = req.Issuer
+	}
+	format := s.cfg.NameIDFormat
+	if format == "" && req.NameIDPolicy != nil {
+		format = req.NameIDPolicy.Format
+	}
+
+	s.issue(w, Issued{
+		RequestID:  req.ID,
+		Binding:    binding,
+		Audience:   audience,
+		ACS:        acs,
+		RelayState: values.Get("RelayState"),
+	}, format)
+}
+
+// handleLogin sends an IdP-initiated Response to the configured ACS
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	if s.cfg.ACS == "" || s.cfg.Audience == "" {
+		s.reject(w, http.StatusBadRequest, errors.New("IdP-initiated login requires a configured ACS and audience"))
+		return
+	}
+	s.issue(w, Issued{
+		Audience:   s.cfg.Audience,
+		ACS:        s.cfg.ACS,
+		RelayState: r.URL.Query().Get("RelayState"),
+	}, s.cfg.NameIDFormat)
+}
+
+// issue builds, signs and encrypts a Response for the test user and
+// writes the form that posts it to the ACS
+func (s *Server) issue(w http.ResponseWriter, issued Issued, nameIDFormat string) {
+	doc, err := saml.BuildResponse(saml.ResponseOptions{
+		Issuer:       s.cfg.EntityID,
+		Destination:  issued.ACS,
+		InResponseTo: issued.RequestID,
+		Audience:     issued.Audience,
+		NameID:       s.cfg.NameID,
+		NameIDFormat: nameIDFormat,
+		Attributes:   s.cfg.Attributes,
+		Lifetime:     s.cfg.Lifetime,
+	}, time.Now())
+	if err != nil {
+		s.reject(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// The assertion is signed before it is encrypted, and the Response
+	// after, so each signature covers what the SP sees
+	resp := doc.Root()
+	if s.cfg.SignAssertion {
+		if err := saml.SignEnveloped(saml.ResponseAssertion(resp), s.cfg.Key, s.cfg.Cert, s.cfg.SigAlg); err != nil {
+			s.reject(w, http.StatusInternalServerError, fmt.Errorf("failed to sign assertion: %w", err))
+			return
+		}
+	}
+	if s.cfg.EncryptionCert != nil {
+		if err := saml.EncryptAssertion(resp, s.cfg.EncryptionCert); err != nil {
+			s.reject(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+	if s.cfg.SignResponse {
+		if err := saml.SignEnveloped(resp, s.cfg.Key, s.cfg.Cert, s.cfg.SigAlg); err != nil {
+			s.reject(w, http.StatusInternalServerError, fmt.Errorf("failed to sign response: %w", err))
+			return
+		}
+	}
+
+	xmlData, err := doc.WriteToBytes()
+	if err != nil {
+		s.reject(w, http.StatusInternalServerError, err)
+		return
+	}
+	form, err := saml.PostForm(issued.ACS, xmlData, "SAMLResponse", issued.RelayState)
+	if err != nil {
+		s.reject(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	issued.ResponseID = resp.SelectAttrValue("ID", "")
+	issued.NameID = s.cfg.NameID
+	issued.Signed = s.cfg.SignAssertion || s.cfg.SignResponse
+	issued.Encrypted = s.cfg.EncryptionCert != nil
+	issued.XML = string(xmlData)
+	if s.onIssue != nil {
+		s.onIssue(issued)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	_, _ = w.Write([]byte(form))
+}
+
+// reject logs why a request could not be answered and reports it to the
+// browser
+func (s *Server) reject(w http.ResponseWriter, status int, err error) {
+	log.Warn("request rejected", "error", err)
+	http.Error(w, err.Error(), status)
+}
@@ -0,0 +1,206 @@
+package idp
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/beevik/etree"
+	"github.com/gliwka/SAMLurai/internal/metrics"
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testAuthnRequest = `<samlp:AuthnRequest xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_req1" Version="2.0" IssueInstant="2024-05-01T12:00:00Z" AssertionConsumerServiceURL="https://sp.example.com/acs"><saml:Issuer>https://sp.example.com</saml:Issuer><samlp:NameIDPolicy Format="urn:oasis:names:tc:SAML:2.0:nameid-format:persistent"/></samlp:AuthnRequest>`
+
+func newTestServer(t *testing.T, configure func(*Config)) (*Server, *x509.Certificate) {
+	t.Helper()
+
+	key, cert, err := saml.NewSelfSignedKey("idp.example.com")
+	require.NoError(t, err)
+	cfg := Config{
+		EntityID:      "https://idp.example.com",
+		BaseURL:       "http://127.0.0.1:8081/",
+		Key:           key,
+		Cert:          cert,
+		SigAlg:        saml.SigAlgRSASHA256,
+		NameID:        "alice",
+		Attributes:    []saml.Attribute{{Name: "mail", Values: []string{"alice@example.com"}}},
+		SignAssertion: true,
+	}
+	if configure != nil {
+		configure(&cfg)
+	}
+	s, err := NewServer(cfg)
+	require.NoError(t, err)
+	return s, cert
+}
+
+// postedResponse returns the action and decoded SAMLResponse of the
+// auto-posting form in body
+func postedResponse(t *testing.T, body string) (string, []byte) {
+	t.Helper()
+
+	action := regexp.MustCompile(`action="([^"]+)"`).FindStringSubmatch(body)
+	require.Len(t, action, 2)
+	value := regexp.MustCompile(`name="SAMLResponse" value="([^"]+)"`).FindStringSubmatch(body)
+	require.Len(t, value, 2)
+	xmlData, err := base64.StdEncoding.DecodeString(html.UnescapeString(value[1]))
+	require.NoError(t, err)
+	return html.UnescapeString(action[1]), xmlData
+}
+
+func TestServer_Metadata(t *testing.T) {
+	s, cert := newTestServer(t, nil)
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, MetadataPath, nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `entityID="https://idp.example.com"`)
+	assert.Contains(t, rec.Body.String(), `Location="http://127.0.0.1:8081/sso"`)
+
+	certs, err := saml.MetadataSigningCertificates(rec.Body.Bytes())
+	require.NoError(t, err)
+	require.Len(t, certs, 1)
+	assert.Equal(t, cert.Raw, certs[0].Certificate.Raw)
+}
+
+func TestServer_SSORedirect(t *testing.T) {
+	var issued []Issued
+	s, cert := newTestServer(t, nil)
+	s.OnIssue(func(i Issued) { issued = append(issued, i) })
+
+	encoded, err := saml.NewDecoder().EncodeDeflate([]byte(testAuthnRequest))
+	require.NoError(t, err)
+	target := SSOPath + "?SAMLRequest=" + url.QueryEscape(encoded) + "&RelayState=%2Fapp"
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Contains(t, rec.Body.String(), `name="RelayState" value="/app"`)
+
+	action, xmlData := postedResponse(t, rec.Body.String())
+	assert.Equal(t, "https://sp.example.com/acs", action)
+
+	info, err := saml.NewParser().Parse(xmlData)
+	require.NoError(t, err)
+	assert.Equal(t, "_req1", info.InResponseTo)
+	require.NotNil(t, info.Assertion)
+	assert.Equal(t, "alice", info.Assertion.Subject.NameID)
+	assert.Equal(t, "urn:oasis:names:tc:SAML:2.0:nameid-format:persistent", info.Assertion.Subject.NameIDFormat)
+	assert.Equal(t, []string{"https://sp.example.com"}, info.Assertion.Conditions.AudienceRestriction)
+
+	doc := etree.NewDocument()
+	require.NoError(t, doc.ReadFromBytes(xmlData))
+	_, err = saml.VerifySignature(saml.ResponseAssertion(doc.Root()), []*x509.Certificate{cert})
+	assert.NoError(t, err)
+
+	require.Len(t, issued, 1)
+	assert.Equal(t, saml.BindingHTTPRedirect, issued[0].Binding)
+	assert.Equal(t, "/app", issued[0].RelayState)
+	assert.True(t, issued[0].Signed)
+	assert.False(t, issued[0].Encrypted)
+}
+
+func TestServer_SSOPostEncrypted(t *testing.T) {
+	spKey, spCert, err := saml.NewSelfSignedKey("sp.example.com")
+	require.NoError(t, err)
+	s, cert := newTestServer(t, func(cfg *Config) {
+		cfg.EncryptionCert = spCert
+		cfg.SignResponse = true
+	})
+
+	form := url.Values{"SAMLRequest": {base64.StdEncoding.EncodeToString([]byte(testAuthnRequest))}}
+	req := httptest.NewRequest(http.MethodPost, SSOPath, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	_, xmlData := postedResponse(t, rec.Body.String())
+	assert.True(t, saml.IsEncrypted(xmlData))
+
+	doc := etree.NewDocument()
+	require.NoError(t, doc.ReadFromBytes(xmlData))
+	_, err = saml.VerifySignature(doc.Root(), []*x509.Certificate{cert})
+	require.NoError(t, err)
+
+	decryptor, err := saml.NewDecryptorFromPEM(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(spKey)}))
+	require.NoError(t, err)
+	decrypted, err := decryptor.Decrypt(xmlData)
+	require.NoError(t, err)
+	assert.Contains(t, string(decrypted), "alice@example.com")
+}
+
+func TestServer_Login(t *testing.T) {
+	s, _ := newTestServer(t, func(cfg *Config) {
+		cfg.ACS = "https://sp.example.com/acs"
+		cfg.Audience = "https://sp.example.com"
+		cfg.Lifetime = time.Minute
+	})
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, LoginPath, nil))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	_, xmlData := postedResponse(t, rec.Body.String())
+	info, err := saml.NewParser().Parse(xmlData)
+	require.NoError(t, err)
+	assert.Empty(t, info.InResponseTo)
+	assert.Equal(t, []string{"https://sp.example.com"}, info.Assertion.Conditions.AudienceRestriction)
+}
+
+func TestServer_Rejects(t *testing.T) {
+	s, _ := newTestServer(t, nil)
+
+	tests := []struct {
+		name   string
+		target string
+		want   string
+	}{
+		{"no request", SSOPath, "no SAMLRequest"},
+		{"not a request", SSOPath + "?SAMLRequest=" + url.QueryEscape(base64.StdEncoding.EncodeToString([]byte(`<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_r"/>`))), "expected an AuthnRequest"},
+		{"no acs", SSOPath + "?SAMLRequest=" + url.QueryEscape(base64.StdEncoding.EncodeToString([]byte(`<samlp:AuthnRequest xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_r"/>`))), "no default ACS"},
+		{"login without acs", LoginPath, "requires a configured ACS"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.want)
+		})
+	}
+}
+
+func TestServer_Metrics(t *testing.T) {
+	s, _ := newTestServer(t, nil)
+	s.WithMetrics(metrics.New())
+
+	encoded, err := saml.NewDecoder().EncodeDeflate([]byte(testAuthnRequest))
+	require.NoError(t, err)
+	for _, request := range []string{encoded, "not base64!"} {
+		s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, SSOPath+"?SAMLRequest="+url.QueryEscape(request), nil))
+	}
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, metrics.Path, nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `samlurai_messages_total{type="AuthnRequest"} 1`)
+	assert.Contains(t, rec.Body.String(), "samlurai_parse_failures_total 1\n")
+}
+
+func TestNewServer_Invalid(t *testing.T) {
+	_, err := NewServer(Config{EntityID: "https://idp.example.com", NameID: "alice", SignAssertion: true})
+	assert.ErrorContains(t, err, "signing requires a key and certificate")
+}
@@ -0,0 +1,102 @@
+package inspect
+
+import (
+	"fmt"
+
+	"github.com/gliwka/SAMLurai/internal/saml"
+)
+
+// Correlation links a Response in a HAR capture to the AuthnRequest it
+// answers
+type Correlation struct {
+	// Request is the index of the AuthnRequest named by InResponseTo, or 0
+	// if there is none
+	Request int `json:"request,omitempty"`
+
+	// Unsolicited is set for responses without InResponseTo, as sent for
+	// IdP-initiated SSO
+	Unsolicited bool `json:"unsolicited,omitempty"`
+
+	// Problems explain where the response does not fit the request
+	Problems []string `json:"problems,omitempty"`
+}
+
+// correlate matches each Response to an earlier AuthnRequest by
+// InResponseTo, and checks that it was sent to the ACS URL the request
+// asked for and that its authentication context satisfies the requested
+// one. Messages whose envelope could not be parsed are skipped.
+func correlate(messages []Message) {
+	requests := map[string]*Message{}
+	for i := range messages {
+		msg := &messages[i]
+		info := msg.Info
+		if info == nil {
+			continue
+		}
+
+		switch info.Type {
+		case "AuthnRequest":
+			if info.ID != "" {
+				requests[info.ID] = msg
+			}
+			continue
+		case "Response":
+		default:
+			continue
+		}
+
+		c := &Correlation{}
+		msg.Correlation = c
+		if info.InResponseTo == "" {
+			c.Unsolicited = true
+			continue
+		}
+
+		if a := info.Assertion; a != nil && a.Subject != nil && a.Subject.InResponseTo != "" && a.Subject.InResponseTo != info.InResponseTo {
+			c.Problems = append(c.Problems, fmt.Sprintf("assertion answers %s, but the response answers %s", a.Subject.InResponseTo, info.InResponseTo))
+		}
+
+		request, ok := requests[info.InResponseTo]
+		if !ok {
+			c.Problems = append(c.Problems, fmt.Sprintf("InResponseTo %s matches no earlier AuthnRequest in the capture", info.InResponseTo))
+			continue
+		}
+		c.Request = request.Index()
+
+		acs := request.Info.AssertionConsumerServiceURL
+		if acs != "" && info.Destination != "" && !msg.checks.URLs.Equal(acs, info.Destination) {
+			c.Problems = append(c.Problems, fmt.Sprintf("destination %s does not match the AssertionConsumerServiceURL %s of request %d",
+				info.Destination, acs, c.Request))
+		}
+
+		if statement := authnStatement(info); statement != nil {
+			if ok, _, problem := saml.CheckAuthnContext(request.Info.RequestedAuthnContext, statement.AuthnContextClassRef); !ok {
+				c.Problems = append(c.Problems, fmt.Sprintf("%s (request %d)", problem, c.Request))
+			}
+		}
+	}
+}
+
+// authnStatement returns the AuthnStatement of a successful response, or
+// nil if it has none or its assertion is encrypted
+func authnStatement(info *saml.SAMLInfo) *saml.AuthnStatement {
+	if info.Status != nil && info.Status.StatusCode != "Success" {
+		return nil
+	}
+	if info.Assertion != nil && info.Assertion.AuthnStatement != nil {
+		return info.Assertion.AuthnStatement
+	}
+	return info.AuthnStatement
+}
+
+// warnings returns the correlation findings as validation warnings
+func (c *Correlation) warnings() []string {
+	if c == nil {
+		return nil
+	}
+	warnings := c.Problems
+	if c.Unsolicited {
+		warnings = append([]string{"unsolicited response (no InResponseTo, IdP-initiated SSO)"}, warnings...)
+	}
+	return warnings
+}
@@ -0,0 +1,115 @@
+package inspect
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// correlationHAR builds a HAR with one POST per SAML message
+func correlationHAR(messages ...string) string {
+	var entries []string
+	for _, m := range messages {
+		param := "SAMLResponse"
+		if strings.Contains(m, "AuthnRequest") {
+			param = "SAMLRequest"
+		}
+		entries = append(entries, `{"request": {"method": "POST", "url": "https://example.com/saml",
+			"postData": {"mimeType": "text/plain", "params": [{"name": "`+param+`", "value": "`+url.QueryEscape(base64.StdEncoding.EncodeToString([]byte(m)))+`"}]}},
+			"response": {"content": {"mimeType": "text/html", "text": ""}}}`)
+	}
+	return `{"log": {"entries": [` + strings.Join(entries, ",") + `]}}`
+}
+
+func correlationResponse(attrs string) string {
+	return `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_resp" ` + attrs + `><saml:Issuer>https://idp.example.com</saml:Issuer><samlp:Status><samlp:StatusCode Value="urn:oasis:names:tc:SAML:2.0:status:Success"/></samlp:Status></samlp:Response>`
+}
+
+func TestRun_Correlation(t *testing.T) {
+	request := `<samlp:AuthnRequest xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_req1" AssertionConsumerServiceURL="https://sp.example.com/acs"><saml:Issuer>https://sp.example.com</saml:Issuer></samlp:AuthnRequest>`
+
+	result, err := Run(context.Background(), Request{Input: correlationHAR(
+		request,
+		correlationResponse(`InResponseTo="_req1" Destination="https://SP.example.com:443/acs"`),
+		correlationResponse(`InResponseTo="_req1" Destination="https://sp.example.com/other"`),
+		correlationResponse(`InResponseTo="_unknown" Destination="https://sp.example.com/acs"`),
+		correlationResponse(`Destination="https://sp.example.com/acs"`),
+	)})
+	require.NoError(t, err)
+	require.Len(t, result.Messages, 5)
+
+	assert.Nil(t, result.Messages[0].Correlation)
+
+	assert.Equal(t, &Correlation{Request: 1}, result.Messages[1].Correlation)
+
+	assert.Equal(t, &Correlation{Request: 1, Problems: []string{
+		"destination https://sp.example.com/other does not match the AssertionConsumerServiceURL https://sp.example.com/acs of request 1",
+	}}, result.Messages[2].Correlation)
+
+	assert.Equal(t, &Correlation{Problems: []string{
+		"InResponseTo _unknown matches no earlier AuthnRequest in the capture",
+	}}, result.Messages[3].Correlation)
+
+	assert.Equal(t, &Correlation{Unsolicited: true}, result.Messages[4].Correlation)
+	assert.Contains(t, result.Messages[4].Warnings(), "unsolicited response (no InResponseTo, IdP-initiated SSO)")
+}
+
+func TestRun_CorrelationLaterRequest(t *testing.T) {
+	request := `<samlp:AuthnRequest xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_req1"/>`
+
+	// A request after the response cannot have caused it
+	result, err := Run(context.Background(), Request{Input: correlationHAR(
+		correlationResponse(`InResponseTo="_req1"`),
+		request,
+	)})
+	require.NoError(t, err)
+	require.Len(t, result.Messages, 2)
+	assert.Equal(t, 0, result.Messages[0].Correlation.Request)
+	assert.Len(t, result.Messages[0].Correlation.Problems, 1)
+}
+
+func TestRun_CorrelationAuthnContext(t *testing.T) {
+	request := `<samlp:AuthnRequest xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_req1"><saml:Issuer>https://sp.example.com</saml:Issuer>` +
+		`<samlp:RequestedAuthnContext Comparison="minimum"><saml:AuthnContextClassRef>urn:oasis:names:tc:SAML:2.0:ac:classes:X509</saml:AuthnContextClassRef></samlp:RequestedAuthnContext></samlp:AuthnRequest>`
+	response := `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_resp" InResponseTo="_req1">` +
+		`<samlp:Status><samlp:StatusCode Value="urn:oasis:names:tc:SAML:2.0:status:Success"/></samlp:Status>` +
+		`<saml:Assertion ID="_a1"><saml:AuthnStatement AuthnInstant="2024-01-15T10:00:00Z"><saml:AuthnContext><saml:AuthnContextClassRef>%s</saml:AuthnContextClassRef></saml:AuthnContext></saml:AuthnStatement></saml:Assertion></samlp:Response>`
+
+	result, err := Run(context.Background(), Request{Input: correlationHAR(
+		request,
+		fmt.Sprintf(response, "https://refeds.org/profile/mfa"),
+		fmt.Sprintf(response, "urn:oasis:names:tc:SAML:2.0:ac:classes:PasswordProtectedTransport"),
+	)})
+	require.NoError(t, err)
+	require.Len(t, result.Messages, 3)
+
+	require.NotNil(t, result.Messages[0].Info.RequestedAuthnContext)
+	assert.Equal(t, &Correlation{Request: 1}, result.Messages[1].Correlation)
+	assert.Equal(t, &Correlation{Request: 1, Problems: []string{
+		"authentication context PasswordProtectedTransport is not at least as strong as X509 (request 1)",
+	}}, result.Messages[2].Correlation)
+}
+
+func TestRun_Replay(t *testing.T) {
+	response := `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="%s"><saml:Assertion ID="_a1"/></samlp:Response>`
+
+	result, err := Run(context.Background(), Request{Input: correlationHAR(
+		fmt.Sprintf(response, "_r1"),
+		fmt.Sprintf(response, "_r1"),
+		fmt.Sprintf(response, "_r2"),
+	)})
+	require.NoError(t, err)
+	require.Len(t, result.Messages, 3)
+
+	assert.Nil(t, result.Messages[0].Replay)
+	assert.Equal(t, &saml.Replay{Index: 2, Of: 1}, result.Messages[1].Replay)
+	assert.Equal(t, &saml.Replay{Index: 3, Of: 1, AssertionID: "_a1"}, result.Messages[2].Replay)
+	assert.Contains(t, result.Messages[2].Warnings(), "replay of message 1: assertion _a1 delivered again")
+}
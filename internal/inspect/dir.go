@@ -0,0 +1,72 @@
+package inspect
+
+import (
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"github.com/gliwka/SAMLurai/internal/saml"
+)
+
+// Input kinds reported by DetectKind
+const (
+	KindHAR        = "HAR"
+	KindSAMLTracer = "SAML-tracer"
+	KindSAZ        = "Fiddler SAZ"
+	KindXML        = "XML"
+	KindBase64     = "base64"
+)
+
+// DetectKind reports what kind of input content is, or "" if it is neither
+// a capture nor a SAML message, e.g. a log or a screenshot that happens to
+// sit in the same directory
+func DetectKind(filename, content string) string {
+	content = strings.TrimSpace(content)
+	switch {
+	case saml.LooksLikeSAZ([]byte(content)):
+		return KindSAZ
+	case saml.LooksLikeSAMLTracer(content):
+		return KindSAMLTracer
+	case IsHAR(filename, content):
+		return KindHAR
+	case saml.IsBase64Encoded(content):
+		decoded, err := saml.NewDecoder().SmartDecode(content)
+		if err == nil && saml.DetectType(decoded) != "Unknown" {
+			return KindBase64
+		}
+	case strings.HasPrefix(content, "<"):
+		if saml.DetectType([]byte(content)) != "Unknown" {
+			return KindXML
+		}
+	}
+	return ""
+}
+
+// FindFiles returns the regular files in dir in lexical order, including
+// those in subdirectories if recursive. Hidden files and directories, such
+// as .git, are skipped.
+func FindFiles(dir string, recursive bool) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != dir && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			if path != dir && !recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Type().IsRegular() {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files, err
+}
@@ -0,0 +1,63 @@
+package inspect
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectKind(t *testing.T) {
+	response := fixture(t, "response.xml")
+
+	tests := []struct {
+		name     string
+		filename string
+		content  string
+		want     string
+	}{
+		{"XML", "response.xml", response, KindXML},
+		{"XML with any name", "ticket-1234.txt", "\n" + response + "\n", KindXML},
+		{"base64", "response.txt", base64.StdEncoding.EncodeToString([]byte(response)), KindBase64},
+		{"HAR by content", "capture.json", `{"log": {"version": "1.2", "entries": []}}`, KindHAR},
+		{"HAR by extension", "capture.har", `{}`, KindHAR},
+		{"Fiddler SAZ", "capture.saz", "PK\x03\x04\x14\x00", KindSAZ},
+		{"other XML", "pom.xml", `<project><modelVersion>4.0.0</modelVersion></project>`, ""},
+		{"base64 of other data", "blob.txt", base64.StdEncoding.EncodeToString([]byte("not SAML")), ""},
+		{"text", "notes.txt", "The user could not log in at 10:42.", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, DetectKind(tt.filename, tt.content))
+		})
+	}
+}
+
+func TestFindFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"b.xml", "a.har", "sub/c.txt", "sub/deeper/d.xml", ".hidden", ".git/config"} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, nil, 0o644))
+	}
+
+	files, err := FindFiles(dir, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "a.har"), filepath.Join(dir, "b.xml")}, files)
+
+	files, err = FindFiles(dir, true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "a.har"),
+		filepath.Join(dir, "b.xml"),
+		filepath.Join(dir, "sub", "c.txt"),
+		filepath.Join(dir, "sub", "deeper", "d.xml"),
+	}, files)
+
+	_, err = FindFiles(filepath.Join(dir, "missing"), true)
+	assert.Error(t, err)
+}
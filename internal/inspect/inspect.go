@@ -0,0 +1,506 @@
+// Package inspect implements the decode, decrypt and parse pipeline shared by
+// the inspect command and the server modes. All state lives in the Request,
+// so independent requests can be processed concurrently.
+package inspect
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gliwka/SAMLurai/internal/log"
+	"github.com/gliwka/SAMLurai/internal/redact"
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/gliwka/SAMLurai/internal/trace"
+)
+
+// Processing stages reported in a StageError
+const (
+	StageLoadKey = "load private key"
+	StageDecrypt = "decrypt"
+	StageParse   = "parse"
+	StageRedact  = "redact"
+)
+
+// SelectLast is the Request.Select value keeping the last HAR message
+const SelectLast = -1
+
+// ErrNoKey is reported for encrypted messages when no key was provided
+var ErrNoKey = saml.ErrNoKey
+
+// StageError describes which step of the pipeline failed for a message
+type StageError struct {
+	Stage string
+	Err   error
+}
+
+func (e *StageError) Error() string {
+	return fmt.Sprintf("failed to %s: %v", e.Stage, e.Err)
+}
+
+func (e *StageError) Unwrap() error {
+	return e.Err
+}
+
+// InputError reports input that could not be read as a HAR file or a SAML
+// message
+type InputError struct {
+	Err error
+}
+
+func (e *InputError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *InputError) Unwrap() error {
+	return e.Err
+}
+
+// Request describes a single inspection. Callers build one per input instead
+// of relying on shared flag variables.
+type Request struct {
+	// Input is the raw HAR, XML or base64-encoded SAML
+	Input string
+
+	// Filename is used to detect HAR files by extension (optional)
+	Filename string
+
+	// KeyPath is the path to a PEM private key for decryption (optional)
+	KeyPath string
+
+	// Decryptor is used instead of KeyPath when already loaded (optional)
+	Decryptor *saml.Decryptor
+
+	// KeyMap maps issuer entity IDs to private key paths, for SPs with a
+	// decryption key per tenant. Encrypted messages from other issuers use
+	// KeyPath or Decryptor. (optional)
+	KeyMap KeyMap
+
+	// MinConfidence drops HAR messages scoring below this value
+	MinConfidence float64
+
+	// Filter selects which HAR messages are processed
+	Filter saml.MessageFilter
+
+	// Dedupe collapses HAR messages recorded more than once
+	Dedupe bool
+
+	// Redactor masks sensitive values of every message before it is
+	// parsed, so no output shows them (optional)
+	Redactor *redact.Redactor
+
+	// Select keeps only the HAR message at this 1-based position, counted
+	// after filtering, or the last one for SelectLast. All messages are
+	// still processed, so correlation and replays see the whole capture.
+	Select int
+
+	// Destination is the URL a single message was delivered to. For HAR
+	// files the request URL of each entry is used instead. (optional)
+	Destination string
+
+	// Audience is the SP entity ID expected in the audience restriction (optional)
+	Audience string
+
+	// URLs controls how URLs are compared. Defaults to
+	// saml.DefaultURLNormalization when nil.
+	URLs *saml.URLNormalization
+
+	// Now is the time validity is evaluated against. When zero, HAR
+	// messages are evaluated at their capture time and others at the
+	// current time.
+	Now time.Time
+
+	// ClockSkew is tolerated on either side of validity windows
+	ClockSkew time.Duration
+
+	// Trace records each step of decoding, decrypting and parsing the
+	// messages (optional)
+	Trace *trace.Trace
+
+	// RawXML keeps the original XML of each message and assertion in
+	// their SAMLInfo.RawXML
+	RawXML bool
+
+	// WebSockets also searches the WebSocket frames of HAR entries
+	WebSockets bool
+}
+
+// parser returns the SAML parser for the messages
+func (r Request) parser() *saml.Parser {
+	return saml.NewParser().WithRawXML(r.RawXML)
+}
+
+// checks returns the validation options shared by all messages
+func (r Request) checks() saml.CheckOptions {
+	opts := saml.CheckOptions{
+		DeliveredTo:      r.Destination,
+		ExpectedAudience: r.Audience,
+		URLs:             saml.DefaultURLNormalization(),
+		Now:              r.Now,
+		ClockSkew:        r.ClockSkew,
+	}
+	if r.URLs != nil {
+		opts.URLs = *r.URLs
+	}
+	return opts
+}
+
+// Message is the outcome of inspecting one SAML message
+type Message struct {
+	// Extracted is set for messages found in a HAR file
+	Extracted *saml.ExtractedSAML
+
+	// XML is the decoded (and if possible decrypted) document
+	XML []byte
+
+	// Info is the parsed message. For encrypted messages that could not be
+	// decrypted it holds the partially parsed envelope.
+	Info *saml.SAMLInfo
+
+	// Err is set when a pipeline stage failed for this message
+	Err error
+
+	// Correlation is set for Responses in a HAR capture
+	Correlation *Correlation
+
+	// Replay is set when a HAR message repeats an earlier delivery
+	Replay *saml.Replay
+
+	checks saml.CheckOptions
+}
+
+// Index returns the position of the message in its input
+func (m Message) Index() int {
+	if m.Extracted != nil {
+		return m.Extracted.Index
+	}
+	return 1
+}
+
+// Type returns the parsed message type, falling back to the detected type
+func (m Message) Type() string {
+	if m.Info != nil {
+		return m.Info.Type
+	}
+	if m.Extracted != nil {
+		return m.Extracted.Type
+	}
+	return "Unknown"
+}
+
+// Result holds all messages found in the input
+type Result struct {
+	// IsHAR reports whether the input was a HAR file
+	IsHAR bool
+
+	// Extracted holds the raw HAR extraction results, after filtering
+	Extracted []saml.ExtractedSAML
+
+	Messages []Message
+
+	// Selected is the position of the single message kept by
+	// Request.Select, out of Total messages found; 0 if all were kept
+	Selected int
+	Total    int
+}
+
+// Run decodes, decrypts and parses the request input. Errors affecting the
+// whole input are returned; per-message problems are recorded on the
+// message so the remaining messages are still processed.
+func Run(ctx context.Context, req Request) (*Result, error) {
+	keys := &keyLoader{path: req.KeyPath, decryptor: req.Decryptor, byIssuer: req.KeyMap}
+
+	if IsHAR(req.Filename, req.Input) {
+		extractor := saml.NewHARExtractor().WithWebSockets(req.WebSockets)
+		results, err := extractor.ExtractContext(ctx, []byte(req.Input))
+		if ctxErr := context.Cause(ctx); ctxErr != nil {
+			return nil, ctxErr
+		}
+		if err != nil {
+			return nil, &InputError{Err: fmt.Errorf("failed to parse HAR file: %w", err)}
+		}
+		results = saml.FilterByConfidence(results, req.MinConfidence)
+		results = req.Filter.Apply(results)
+		if req.Dedupe {
+			results = saml.Dedupe(results)
+		}
+		if req.Redactor != nil {
+			for i := range results {
+				if results[i], err = req.Redactor.Extracted(results[i]); err != nil {
+					return nil, fmt.Errorf("failed to redact message %d: %w", results[i].Index, err)
+				}
+			}
+		}
+
+		messages, err := processExtracted(ctx, results, keys, req.parser(), req.Redactor, req.checks(), req.Trace)
+		if err != nil {
+			return nil, err
+		}
+		result := &Result{IsHAR: true, Extracted: results, Messages: messages, Total: len(messages)}
+		if req.Select != 0 && len(messages) > 0 {
+			if err := result.selectMessage(req.Select); err != nil {
+				return nil, err
+			}
+		}
+		return result, nil
+	}
+
+	if req.Select > 1 {
+		return nil, fmt.Errorf("message %d not found: the input holds a single SAML message", req.Select)
+	}
+
+	xmlData, err := saml.NewDecoder().WithTrace(req.Trace).SmartDecode(req.Input)
+	if err != nil {
+		return nil, &InputError{Err: fmt.Errorf("failed to decode input: %w", err)}
+	}
+
+	if req.Redactor != nil {
+		if xmlData, err = req.Redactor.XML(xmlData); err != nil {
+			return nil, fmt.Errorf("failed to redact input: %w", err)
+		}
+	}
+
+	msg := processXML(ctx, xmlData, keys, req.parser(), req.Redactor, req.Trace)
+	if err := context.Cause(ctx); err != nil {
+		return nil, err
+	}
+	msg.checks = req.checks()
+	return &Result{Messages: []Message{msg}}, nil
+}
+
+// selectMessage keeps only the message at the 1-based position, or the
+// last one for SelectLast
+func (r *Result) selectMessage(position int) error {
+	if position == SelectLast {
+		position = len(r.Messages)
+	}
+	if position < 1 || position > len(r.Messages) {
+		return fmt.Errorf("message %d not found: the HAR file has %d SAML message(s)", position, len(r.Messages))
+	}
+	r.Selected = position
+	r.Messages = r.Messages[position-1 : position]
+	r.Extracted = r.Extracted[position-1 : position]
+	return nil
+}
+
+// Extracted processes messages that were already extracted from a HAR file
+func Extracted(ctx context.Context, results []saml.ExtractedSAML, keyPath string) ([]Message, error) {
+	return processExtracted(ctx, results, &keyLoader{path: keyPath}, saml.NewParser(), nil, Request{}.checks(), nil)
+}
+
+func processExtracted(ctx context.Context, results []saml.ExtractedSAML, keys *keyLoader, parser *saml.Parser, redactor *redact.Redactor, checks saml.CheckOptions, tr *trace.Trace) ([]Message, error) {
+	messages := make([]Message, 0, len(results))
+	replays := saml.NewReplayDetector()
+	for i := range results {
+		if err := context.Cause(ctx); err != nil {
+			return nil, err
+		}
+		msg := processXML(ctx, results[i].DecodedXML, keys, parser, redactor, tr.ForMessage(results[i].Index))
+		if err := context.Cause(ctx); err != nil {
+			return nil, err
+		}
+		msg.Extracted = &results[i]
+		msg.checks = checks
+		msg.checks.DeliveredTo = deliveredTo(results[i])
+		msg.Replay = replays.Observe(results[i], msg.Info)
+		messages = append(messages, msg)
+	}
+	correlate(messages)
+	return messages, nil
+}
+
+// processXML decrypts (if needed) and parses a single SAML document. The
+// input is expected to be redacted already; the redactor, if any, is
+// applied to the decrypted content.
+func processXML(ctx context.Context, xmlData []byte, keys *keyLoader, parser *saml.Parser, redactor *redact.Redactor, tr *trace.Trace) Message {
+	msg := Message{XML: xmlData}
+
+	span := tr.Begin(trace.StageDetectType, len(xmlData))
+	encrypted := saml.IsEncrypted(xmlData)
+	detail := saml.DetectType(xmlData)
+	if encrypted {
+		detail += ", encrypted"
+	}
+	span.End(trace.OutcomeOK, len(xmlData), detail, nil)
+
+	if !encrypted {
+		tr.Skip(trace.StageDecrypt, len(xmlData), "not encrypted")
+	} else {
+		// The issuer of the envelope selects the tenant key
+		envelope, _ := parser.ParsePartial(xmlData)
+		issuer := ""
+		if envelope != nil {
+			issuer = envelope.Issuer
+		}
+
+		span := tr.Begin(trace.StageDecrypt, len(xmlData))
+		decryptor, err := keys.load(issuer)
+		if err != nil {
+			span.End(trace.OutcomeFailed, 0, "no usable key", err)
+			msg.Err = err
+			if errors.Is(err, ErrNoKey) {
+				// Still show what we can from the response wrapper
+				msg.Info = envelope
+			}
+			return msg
+		}
+
+		decrypted, err := decryptor.DecryptContext(ctx, xmlData)
+		if err != nil {
+			span.End(trace.OutcomeFailed, 0, "", err)
+			msg.Err = &StageError{Stage: StageDecrypt, Err: err}
+			return msg
+		}
+		span.End(trace.OutcomeOK, len(decrypted), "", nil)
+		msg.XML = decrypted
+		if redactor != nil {
+			if msg.XML, err = redactor.XML(decrypted); err != nil {
+				msg.Err = &StageError{Stage: StageRedact, Err: err}
+				return msg
+			}
+		}
+	}
+
+	span = tr.Begin(trace.StageParse, len(msg.XML))
+	info, err := parser.ParseContext(ctx, msg.XML)
+	if err != nil {
+		span.End(trace.OutcomeFailed, 0, "", err)
+		msg.Err = &StageError{Stage: StageParse, Err: err}
+		return msg
+	}
+	span.End(trace.OutcomeOK, len(msg.XML), info.Type, nil)
+
+	if info.Type == "Response" || info.Type == "Assertion" {
+		info.Vendor = saml.FingerprintVendor(msg.XML, info)
+	}
+	info.PossibleCauses = saml.PossibleCauses(info)
+	msg.Info = info
+	return msg
+}
+
+// keyLoader loads private keys on first use, so inputs without encrypted
+// content never touch the key files
+type keyLoader struct {
+	path      string
+	decryptor *saml.Decryptor
+	byIssuer  KeyMap
+	loaded    map[string]loadedKey
+}
+
+type loadedKey struct {
+	decryptor *saml.Decryptor
+	err       error
+}
+
+// load returns the decryptor for a message from issuer: its key from the
+// key map, or else the default key
+func (k *keyLoader) load(issuer string) (*saml.Decryptor, error) {
+	path, mapped := k.byIssuer[issuer]
+	switch {
+	case mapped:
+		log.Debug("using key from key map", "issuer", issuer, "key", path)
+	case k.decryptor != nil:
+		return k.decryptor, nil
+	case k.path != "":
+		path = k.path
+	case len(k.byIssuer) > 0:
+		return nil, fmt.Errorf("%w for issuer %q, which is not in the key map", ErrNoKey, issuer)
+	default:
+		return nil, ErrNoKey
+	}
+
+	if k.loaded == nil {
+		k.loaded = map[string]loadedKey{}
+	}
+	key, ok := k.loaded[path]
+	if !ok {
+		key.decryptor, key.err = saml.NewDecryptor(path)
+		if key.err != nil {
+			key.err = &StageError{Stage: StageLoadKey, Err: key.err}
+		}
+		k.loaded[path] = key
+	}
+	return key.decryptor, key.err
+}
+
+// IsHAR checks if the input is likely a HAR file, by extension or content.
+// SAML-tracer exports and Fiddler session archives are handled like HAR
+// files.
+func IsHAR(filename, content string) bool {
+	if filename != "" {
+		switch strings.ToLower(filepath.Ext(saml.TrimCompressedExt(filename))) {
+		case ".har", ".saz":
+			return true
+		}
+	}
+	return saml.LooksLikeHAR(content) || saml.LooksLikeSAMLTracer(content) || saml.LooksLikeSAZ([]byte(content))
+}
+
+// Warnings returns the validation warnings for a message, evaluated at
+// the reference time
+func (m Message) Warnings() []string {
+	if m.Info == nil || m.Err != nil {
+		return nil
+	}
+	checks := m.checks
+	checks.Now = m.ReferenceTime()
+	checks.Sent = m.checks.Now.IsZero() && m.SentAt() != nil
+	warnings := append(saml.WarningsWithOptions(m.Info, checks), m.Correlation.warnings()...)
+	if m.Replay != nil {
+		warnings = append(warnings, m.Replay.String())
+	}
+	return warnings
+}
+
+// SentAt returns when a HAR message was sent, or nil if unknown
+func (m Message) SentAt() *time.Time {
+	if m.Extracted == nil {
+		return nil
+	}
+	return m.Extracted.StartedAt
+}
+
+// ExpiredWhenSent reports whether the conditions of the message, or of
+// its assertion, had already expired when the HAR entry carrying it was
+// sent, give or take the clock skew
+func (m Message) ExpiredWhenSent() bool {
+	sent := m.SentAt()
+	if sent == nil {
+		return false
+	}
+	return saml.Expired(m.Info, *sent, m.checks.ClockSkew)
+}
+
+// ReferenceTime returns the time the message's validity is evaluated
+// against: Request.Now if set, else the capture time of a HAR message,
+// else the current time
+func (m Message) ReferenceTime() time.Time {
+	switch {
+	case !m.checks.Now.IsZero():
+		return m.checks.Now
+	case m.SentAt() != nil:
+		return *m.SentAt()
+	}
+	return time.Now()
+}
+
+// deliveredTo returns the URL a HAR message was sent to, without the query
+// string carrying the message itself. Messages found in response bodies
+// are posted onwards by the browser, so their target is unknown; redirects
+// name theirs.
+func deliveredTo(extracted saml.ExtractedSAML) string {
+	if !strings.HasPrefix(extracted.Source, "request-") && extracted.Source != "response-location" {
+		return ""
+	}
+	u, err := url.Parse(extracted.URL)
+	if err != nil {
+		return ""
+	}
+	u.RawQuery = ""
+	u.Fragment = ""
+	return u.String()
+}
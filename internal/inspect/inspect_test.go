@@ -0,0 +1,223 @@
+package inspect
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gliwka/SAMLurai/internal/redact"
+	"github.com/gliwka/SAMLurai/internal/testutil"
+	"github.com/gliwka/SAMLurai/internal/trace"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const encryptedResponse = `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_enc"><saml:Issuer>https://idp.example.com</saml:Issuer><saml:EncryptedAssertion><xenc:EncryptedData xmlns:xenc="http://www.w3.org/2001/04/xmlenc#"/></saml:EncryptedAssertion></samlp:Response>`
+
+func fixture(t *testing.T, name string) string {
+	t.Helper()
+	return testutil.LoadFixtureString(t, filepath.Join("..", "..", "testdata", "fixtures", "assertions", name))
+}
+
+func TestRun_XML(t *testing.T) {
+	result, err := Run(context.Background(), Request{Input: fixture(t, "response.xml")})
+	require.NoError(t, err)
+
+	assert.False(t, result.IsHAR)
+	require.Len(t, result.Messages, 1)
+	assert.NoError(t, result.Messages[0].Err)
+	assert.Equal(t, "Response", result.Messages[0].Type())
+	assert.Equal(t, 1, result.Messages[0].Index())
+}
+
+func TestRun_HAR(t *testing.T) {
+	encoded := url.QueryEscape(base64.StdEncoding.EncodeToString([]byte(fixture(t, "response.xml"))))
+	har := `{"log": {"entries": [{"request": {"method": "POST", "url": "https://sp.example.com/acs",
+		"postData": {"mimeType": "text/plain", "params": [{"name": "SAMLResponse", "value": "` + encoded + `"}]}},
+		"response": {"content": {"mimeType": "text/html", "text": ""}}}]}}`
+
+	result, err := Run(context.Background(), Request{Input: har})
+	require.NoError(t, err)
+
+	assert.True(t, result.IsHAR)
+	require.Len(t, result.Messages, 1)
+	assert.NoError(t, result.Messages[0].Err)
+	assert.Equal(t, "Response", result.Messages[0].Type())
+	assert.NotNil(t, result.Messages[0].Extracted)
+}
+
+func TestRun_HARSentTime(t *testing.T) {
+	encoded := url.QueryEscape(base64.StdEncoding.EncodeToString([]byte(fixture(t, "response.xml"))))
+	entry := func(started string) string {
+		return `{"startedDateTime": "` + started + `", "request": {"method": "POST", "url": "https://sp.example.com/acs",
+		"postData": {"mimeType": "text/plain", "params": [{"name": "SAMLResponse", "value": "` + encoded + `"}]}},
+		"response": {"content": {"mimeType": "text/html", "text": ""}}}`
+	}
+	har := `{"log": {"entries": [` + entry("2024-01-15T10:30:00Z") + `, ` + entry("2024-01-15T10:37:00Z") + `]}}`
+
+	result, err := Run(context.Background(), Request{Input: har})
+	require.NoError(t, err)
+	require.Len(t, result.Messages, 2)
+
+	assert.False(t, result.Messages[0].ExpiredWhenSent())
+	assert.NotContains(t, strings.Join(result.Messages[0].Warnings(), "\n"), "expired")
+
+	assert.True(t, result.Messages[1].ExpiredWhenSent())
+	assert.Contains(t, result.Messages[1].Warnings(),
+		"assertion: assertion was already expired when sent (NotOnOrAfter 2024-01-15T10:35:00Z, 2m before it was sent)")
+
+	// Within the clock skew it was still acceptable
+	result, err = Run(context.Background(), Request{Input: har, ClockSkew: 5 * time.Minute})
+	require.NoError(t, err)
+	assert.False(t, result.Messages[1].ExpiredWhenSent())
+}
+
+func TestRun_HARSelect(t *testing.T) {
+	request := url.QueryEscape(base64.StdEncoding.EncodeToString([]byte(fixture(t, "request.xml"))))
+	response := url.QueryEscape(base64.StdEncoding.EncodeToString([]byte(fixture(t, "response.xml"))))
+	har := `{"log": {"entries": [
+		{"request": {"method": "GET", "url": "https://idp.example.com/sso?SAMLRequest=` + request + `"},
+			"response": {"content": {"mimeType": "text/html", "text": ""}}},
+		{"request": {"method": "POST", "url": "https://sp.example.com/acs",
+			"postData": {"mimeType": "text/plain", "params": [{"name": "SAMLResponse", "value": "` + response + `"}]}},
+			"response": {"content": {"mimeType": "text/html", "text": ""}}}]}}`
+
+	result, err := Run(context.Background(), Request{Input: har, Select: 1})
+	require.NoError(t, err)
+	require.Len(t, result.Messages, 1)
+	assert.Equal(t, "AuthnRequest", result.Messages[0].Type())
+	assert.Equal(t, 1, result.Selected)
+	assert.Equal(t, 2, result.Total)
+
+	// The selected Response is still correlated with the whole capture
+	result, err = Run(context.Background(), Request{Input: har, Select: SelectLast})
+	require.NoError(t, err)
+	require.Len(t, result.Messages, 1)
+	assert.Equal(t, "Response", result.Messages[0].Type())
+	assert.Equal(t, 2, result.Selected)
+	require.NotNil(t, result.Messages[0].Correlation)
+	assert.NotEmpty(t, result.Messages[0].Correlation.Problems)
+
+	_, err = Run(context.Background(), Request{Input: har, Select: 3})
+	assert.EqualError(t, err, "message 3 not found: the HAR file has 2 SAML message(s)")
+}
+
+func TestRun_Redact(t *testing.T) {
+	result, err := Run(context.Background(), Request{Input: fixture(t, "response.xml"), Redactor: redact.New([]string{"groups"})})
+	require.NoError(t, err)
+	msg := result.Messages[0]
+	require.NoError(t, msg.Err)
+	assert.NotContains(t, string(msg.XML), "user@example.com")
+	assert.Equal(t, redact.Mask, msg.Info.Assertion.Subject.NameID)
+	assert.Contains(t, string(msg.XML), "admins")
+
+	encoded := url.QueryEscape(base64.StdEncoding.EncodeToString([]byte(fixture(t, "response.xml"))))
+	har := `{"log": {"entries": [{"request": {"method": "POST", "url": "https://sp.example.com/acs",
+		"postData": {"mimeType": "text/plain", "params": [{"name": "SAMLResponse", "value": "` + encoded + `"}]}},
+		"response": {"content": {"mimeType": "text/html", "text": ""}}}]}}`
+	result, err = Run(context.Background(), Request{Input: har, Redactor: redact.New(nil)})
+	require.NoError(t, err)
+	require.Len(t, result.Messages, 1)
+	assert.Equal(t, redact.Mask, result.Messages[0].Info.Assertion.Subject.NameID)
+	assert.NotContains(t, string(result.Extracted[0].DecodedXML), "user@example.com")
+}
+
+func TestRun_Trace(t *testing.T) {
+	tr := trace.New()
+	_, err := Run(context.Background(), Request{Input: encryptedResponse, Trace: tr})
+	require.NoError(t, err)
+
+	var stages []string
+	for _, step := range tr.Steps() {
+		stages = append(stages, step.Stage+" "+step.Outcome)
+	}
+	assert.Equal(t, []string{"base64 skipped", "detect-type ok", "decrypt failed"}, stages)
+	assert.Equal(t, "Response, encrypted", tr.Steps()[1].Detail)
+
+	// HAR messages are traced from type detection on, by position
+	encoded := url.QueryEscape(base64.StdEncoding.EncodeToString([]byte(fixture(t, "response.xml"))))
+	har := `{"log": {"entries": [{"request": {"method": "POST", "url": "https://sp.example.com/acs",
+		"postData": {"mimeType": "text/plain", "params": [{"name": "SAMLResponse", "value": "` + encoded + `"}]}},
+		"response": {"content": {"mimeType": "text/html", "text": ""}}}]}}`
+	tr = trace.New()
+	_, err = Run(context.Background(), Request{Input: har, Trace: tr})
+	require.NoError(t, err)
+	steps := tr.Steps()
+	require.Len(t, steps, 3)
+	assert.Equal(t, 1, steps[2].Message)
+	assert.Equal(t, "parse", steps[2].Stage)
+	assert.Equal(t, "Response", steps[2].Detail)
+}
+
+func TestRun_EncryptedWithoutKey(t *testing.T) {
+	result, err := Run(context.Background(), Request{Input: encryptedResponse})
+	require.NoError(t, err)
+
+	msg := result.Messages[0]
+	assert.ErrorIs(t, msg.Err, ErrNoKey)
+	require.NotNil(t, msg.Info, "envelope should still be parsed")
+	assert.Equal(t, "https://idp.example.com", msg.Info.Issuer)
+	assert.Nil(t, msg.Warnings())
+}
+
+func TestRun_InvalidKey(t *testing.T) {
+	result, err := Run(context.Background(), Request{
+		Input:   encryptedResponse,
+		KeyPath: filepath.Join(t.TempDir(), "missing.pem"),
+	})
+	require.NoError(t, err)
+
+	var stageErr *StageError
+	require.True(t, errors.As(result.Messages[0].Err, &stageErr))
+	assert.Equal(t, StageLoadKey, stageErr.Stage)
+}
+
+func TestRun_ParseError(t *testing.T) {
+	result, err := Run(context.Background(), Request{Input: "<samlp:Response"})
+	require.NoError(t, err)
+
+	var stageErr *StageError
+	require.True(t, errors.As(result.Messages[0].Err, &stageErr))
+	assert.Equal(t, StageParse, stageErr.Stage)
+}
+
+func TestRun_Cancelled(t *testing.T) {
+	encoded := url.QueryEscape(base64.StdEncoding.EncodeToString([]byte(fixture(t, "response.xml"))))
+	har := `{"log": {"entries": [{"request": {"method": "GET", "url": "https://sp.example.com/acs?SAMLResponse=` + encoded + `"},
+		"response": {"content": {"mimeType": "text/html", "text": ""}}}]}}`
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := Run(ctx, Request{Input: har})
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestRun_Concurrent(t *testing.T) {
+	inputs := []string{fixture(t, "response.xml"), fixture(t, "request.xml"), fixture(t, "assertion.xml")}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 30; i++ {
+		wg.Add(1)
+		go func(input string) {
+			defer wg.Done()
+			result, err := Run(context.Background(), Request{Input: input})
+			if assert.NoError(t, err) {
+				assert.NoError(t, result.Messages[0].Err)
+			}
+		}(inputs[i%len(inputs)])
+	}
+	wg.Wait()
+}
+
+func TestIsHAR(t *testing.T) {
+	assert.True(t, IsHAR("capture.HAR", ""))
+	assert.True(t, IsHAR("", `{"log": {"entries": []}}`))
+	assert.False(t, IsHAR("response.xml", "<samlp:Response/>"))
+}
@@ -0,0 +1,38 @@
+package inspect
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// KeyMap maps issuer entity IDs to private key paths
+type KeyMap map[string]string
+
+// LoadKeyMap reads a YAML key map, e.g.
+//
+//	https://idp.tenant-a.example.com: keys/tenant-a.pem
+//	https://idp.tenant-b.example.com: keys/tenant-b.pem
+//
+// Relative key paths are resolved against the directory of the file.
+func LoadKeyMap(path string) (KeyMap, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key map: %w", err)
+	}
+	var keys KeyMap
+	if err := yaml.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("failed to parse key map: %w", err)
+	}
+	for issuer, keyPath := range keys {
+		if keyPath == "" {
+			return nil, fmt.Errorf("key map: no key path for issuer %q", issuer)
+		}
+		if !filepath.IsAbs(keyPath) {
+			keys[issuer] = filepath.Join(filepath.Dir(path), keyPath)
+		}
+	}
+	return keys, nil
+}
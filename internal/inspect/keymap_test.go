@@ -0,0 +1,88 @@
+package inspect
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeKey(t *testing.T, path string) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	data := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	require.NoError(t, os.WriteFile(path, data, 0600))
+}
+
+func TestLoadKeyMap(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "keys.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`https://idp.tenant-a.example.com: keys/tenant-a.pem
+https://idp.tenant-b.example.com: /etc/samlurai/tenant-b.pem
+`), 0600))
+
+	keys, err := LoadKeyMap(path)
+	require.NoError(t, err)
+	assert.Equal(t, KeyMap{
+		"https://idp.tenant-a.example.com": filepath.Join(dir, "keys", "tenant-a.pem"),
+		"https://idp.tenant-b.example.com": "/etc/samlurai/tenant-b.pem",
+	}, keys)
+
+	require.NoError(t, os.WriteFile(path, []byte("https://idp.example.com:\n"), 0600))
+	_, err = LoadKeyMap(path)
+	assert.EqualError(t, err, `key map: no key path for issuer "https://idp.example.com"`)
+}
+
+func TestRun_KeyMap(t *testing.T) {
+	dir := t.TempDir()
+	tenantKey := filepath.Join(dir, "tenant.pem")
+	writeKey(t, tenantKey)
+
+	stage := func(err error) string {
+		var stageErr *StageError
+		if errors.As(err, &stageErr) {
+			return stageErr.Stage
+		}
+		return ""
+	}
+
+	// The issuer's key is used, even when a default key is given; the
+	// fixture has no cipher data, so decryption itself fails
+	result, err := Run(context.Background(), Request{
+		Input:   encryptedResponse,
+		KeyPath: filepath.Join(dir, "missing-default.pem"),
+		KeyMap:  KeyMap{"https://idp.example.com": tenantKey},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, StageDecrypt, stage(result.Messages[0].Err))
+
+	// Other issuers fall back to the default key
+	result, err = Run(context.Background(), Request{
+		Input:   encryptedResponse,
+		KeyPath: tenantKey,
+		KeyMap:  KeyMap{"https://other.example.com": filepath.Join(dir, "missing.pem")},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, StageDecrypt, stage(result.Messages[0].Err))
+
+	// Without a default key they are reported like a missing key
+	result, err = Run(context.Background(), Request{
+		Input:  encryptedResponse,
+		KeyMap: KeyMap{"https://other.example.com": tenantKey},
+	})
+	require.NoError(t, err)
+	msg := result.Messages[0]
+	assert.ErrorIs(t, msg.Err, ErrNoKey)
+	assert.Contains(t, msg.Err.Error(), `for issuer "https://idp.example.com", which is not in the key map`)
+	require.NotNil(t, msg.Info)
+	assert.Equal(t, "https://idp.example.com", msg.Info.Issuer)
+}
@@ -0,0 +1,203 @@
+// Package kms decrypts with private keys held in a cloud key management
+// service, so production SP keys never have to be exported. Requests go
+// through the provider's CLI (aws, gcloud or az), reusing the credentials
+// it is already logged in with.
+package kms
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// Providers
+const (
+	ProviderAWS   = "aws"
+	ProviderGCP   = "gcp"
+	ProviderAzure = "azure"
+)
+
+// Key is an asymmetric key in a cloud KMS. It implements crypto.Decrypter,
+// so it can unwrap the session keys of encrypted assertions.
+type Key struct {
+	// Provider is one of ProviderAWS, ProviderGCP or ProviderAzure
+	Provider string
+
+	// Ref is the key ARN, resource name or URI
+	Ref string
+
+	// run executes a CLI tool with args, passing stdin
+	run func(tool string, args []string, stdin []byte) ([]byte, error)
+}
+
+// New returns the key referenced by ref:
+//   - AWS KMS: a key ARN, arn:aws:kms:REGION:ACCOUNT:key/ID
+//   - GCP Cloud KMS: a key version, projects/P/locations/L/keyRings/R/cryptoKeys/K/cryptoKeyVersions/V
+//   - Azure Key Vault: a key URI, https://VAULT.vault.azure.net/keys/NAME[/VERSION]
+func New(ref string) (*Key, error) {
+	key := &Key{Ref: ref, run: runTool}
+	switch {
+	case strings.HasPrefix(ref, "arn:aws:kms:") || strings.HasPrefix(ref, "arn:aws-"):
+		key.Provider = ProviderAWS
+	case strings.HasPrefix(ref, "projects/") && strings.Contains(ref, "/cryptoKeyVersions/"):
+		key.Provider = ProviderGCP
+	case strings.HasPrefix(ref, "https://") && strings.Contains(ref, ".vault.azure.net/keys/"):
+		key.Provider = ProviderAzure
+	default:
+		return nil, fmt.Errorf("unrecognized KMS key %q: expected an AWS KMS key ARN, a GCP key version resource name or an Azure Key Vault key URI", ref)
+	}
+	return key, nil
+}
+
+// Public is not available without a separate request to the KMS, which
+// decryption does not need
+func (k *Key) Public() crypto.PublicKey {
+	return nil
+}
+
+// Decrypt sends msg to the KMS for decryption with RSA-OAEP
+// (*rsa.OAEPOptions) or, on Azure, RSA-1.5 (*rsa.PKCS1v15DecryptOptions)
+func (k *Key) Decrypt(_ io.Reader, msg []byte, opts crypto.DecrypterOpts) ([]byte, error) {
+	switch k.Provider {
+	case ProviderAWS:
+		return k.decryptAWS(msg, opts)
+	case ProviderGCP:
+		return k.decryptGCP(msg, opts)
+	case ProviderAzure:
+		return k.decryptAzure(msg, opts)
+	}
+	return nil, fmt.Errorf("unknown KMS provider %q", k.Provider)
+}
+
+// oaepHash returns the hash of OAEP options. KMS services use the same
+// hash for the digest and the mask.
+func oaepHash(provider string, opts crypto.DecrypterOpts) (crypto.Hash, error) {
+	o, ok := opts.(*rsa.OAEPOptions)
+	if !ok {
+		return 0, fmt.Errorf("%s KMS only supports RSA-OAEP key transport", provider)
+	}
+	if o.MGFHash != 0 && o.MGFHash != o.Hash {
+		return 0, fmt.Errorf("%s KMS does not support OAEP with a %v mask and %v digest", provider, o.MGFHash, o.Hash)
+	}
+	return o.Hash, nil
+}
+
+func (k *Key) decryptAWS(msg []byte, opts crypto.DecrypterOpts) ([]byte, error) {
+	hash, err := oaepHash("AWS", opts)
+	if err != nil {
+		return nil, err
+	}
+	algorithms := map[crypto.Hash]string{crypto.SHA1: "RSAES_OAEP_SHA_1", crypto.SHA256: "RSAES_OAEP_SHA_256"}
+	algorithm, ok := algorithms[hash]
+	if !ok {
+		return nil, fmt.Errorf("AWS KMS does not support OAEP with %v", hash)
+	}
+
+	out, err := k.run("aws", []string{"kms", "decrypt",
+		"--key-id", k.Ref,
+		"--encryption-algorithm", algorithm,
+		"--ciphertext-blob", "fileb:///dev/stdin",
+		"--output", "text", "--query", "Plaintext",
+	}, msg)
+	if err != nil {
+		return nil, err
+	}
+	return decodeBase64(out)
+}
+
+func (k *Key) decryptGCP(msg []byte, opts crypto.DecrypterOpts) ([]byte, error) {
+	// The algorithm is fixed by the key version; a mismatch is reported
+	// by the service
+	if _, err := oaepHash("GCP", opts); err != nil {
+		return nil, err
+	}
+
+	parts := strings.Split(k.Ref, "/")
+	if len(parts) != 10 {
+		return nil, fmt.Errorf("invalid GCP key version %q", k.Ref)
+	}
+	return k.run("gcloud", []string{"kms", "asymmetric-decrypt",
+		"--project", parts[1],
+		"--location", parts[3],
+		"--keyring", parts[5],
+		"--key", parts[7],
+		"--version", parts[9],
+		"--ciphertext-file", "-",
+		"--plaintext-file", "-",
+	}, msg)
+}
+
+func (k *Key) decryptAzure(msg []byte, opts crypto.DecrypterOpts) ([]byte, error) {
+	var algorithm string
+	if _, ok := opts.(*rsa.PKCS1v15DecryptOptions); ok {
+		algorithm = "RSA1_5"
+	} else {
+		hash, err := oaepHash("Azure", opts)
+		if err != nil {
+			return nil, err
+		}
+		algorithms := map[crypto.Hash]string{crypto.SHA1: "RSA-OAEP", crypto.SHA256: "RSA-OAEP-256"}
+		var ok bool
+		if algorithm, ok = algorithms[hash]; !ok {
+			return nil, fmt.Errorf("Azure Key Vault does not support OAEP with %v", hash)
+		}
+	}
+
+	out, err := k.run("az", []string{"keyvault", "key", "decrypt",
+		"--id", k.Ref,
+		"--algorithm", algorithm,
+		"--data-type", "base64",
+		"--value", base64.StdEncoding.EncodeToString(msg),
+		"--output", "json",
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	var result struct {
+		Result string `json:"result"`
+	}
+	if err := json.Unmarshal(out, &result); err != nil {
+		return nil, fmt.Errorf("unexpected az output: %w", err)
+	}
+	return decodeBase64([]byte(result.Result))
+}
+
+// decodeBase64 decodes CLI output, which may be base64url on Azure
+func decodeBase64(out []byte) ([]byte, error) {
+	s := strings.TrimSpace(string(out))
+	if decoded, err := base64.StdEncoding.DecodeString(s); err == nil {
+		return decoded, nil
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil {
+		return nil, fmt.Errorf("unexpected KMS output: %w", err)
+	}
+	return decoded, nil
+}
+
+// runTool runs a provider CLI, returning its output or its error message
+func runTool(tool string, args []string, stdin []byte) ([]byte, error) {
+	path, err := exec.LookPath(tool)
+	if err != nil {
+		return nil, fmt.Errorf("%s CLI not found: install it and log in", tool)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(path, args...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s: %s", tool, msg)
+		}
+		return nil, fmt.Errorf("%s: %w", tool, err)
+	}
+	return stdout.Bytes(), nil
+}
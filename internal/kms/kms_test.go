@@ -0,0 +1,120 @@
+package kms
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		ref      string
+		provider string
+	}{
+		{"arn:aws:kms:eu-central-1:111122223333:key/1234abcd-12ab-34cd-56ef-1234567890ab", ProviderAWS},
+		{"arn:aws-us-gov:kms:us-gov-west-1:111122223333:key/1234abcd", ProviderAWS},
+		{"projects/prod/locations/europe-west1/keyRings/saml/cryptoKeys/sp-encryption/cryptoKeyVersions/3", ProviderGCP},
+		{"https://prod-vault.vault.azure.net/keys/sp-encryption/0123456789abcdef", ProviderAzure},
+	}
+	for _, tt := range tests {
+		key, err := New(tt.ref)
+		require.NoError(t, err, tt.ref)
+		assert.Equal(t, tt.provider, key.Provider, tt.ref)
+	}
+
+	_, err := New("alias/sp-key")
+	assert.ErrorContains(t, err, `unrecognized KMS key "alias/sp-key"`)
+}
+
+// fakeRun records the invocation and returns out
+func fakeRun(out string, tool *string, args *[]string, stdin *[]byte) func(string, []string, []byte) ([]byte, error) {
+	return func(gotTool string, gotArgs []string, gotStdin []byte) ([]byte, error) {
+		*tool, *args, *stdin = gotTool, gotArgs, gotStdin
+		return []byte(out), nil
+	}
+}
+
+func TestKey_Decrypt(t *testing.T) {
+	sessionKey := []byte("0123456789abcdef")
+	encoded := base64.StdEncoding.EncodeToString(sessionKey)
+
+	tests := []struct {
+		name      string
+		ref       string
+		opts      crypto.DecrypterOpts
+		out       string
+		wantTool  string
+		wantArgs  []string
+		wantStdin []byte
+	}{
+		{
+			name:     "AWS",
+			ref:      "arn:aws:kms:eu-central-1:111122223333:key/abcd",
+			opts:     &rsa.OAEPOptions{Hash: crypto.SHA256},
+			out:      encoded + "\n",
+			wantTool: "aws",
+			wantArgs: []string{"kms", "decrypt", "--key-id", "arn:aws:kms:eu-central-1:111122223333:key/abcd",
+				"--encryption-algorithm", "RSAES_OAEP_SHA_256", "--ciphertext-blob", "fileb:///dev/stdin",
+				"--output", "text", "--query", "Plaintext"},
+			wantStdin: []byte("wrapped"),
+		},
+		{
+			name:     "GCP",
+			ref:      "projects/prod/locations/europe-west1/keyRings/saml/cryptoKeys/sp/cryptoKeyVersions/3",
+			opts:     &rsa.OAEPOptions{Hash: crypto.SHA256, MGFHash: crypto.SHA256},
+			out:      string(sessionKey),
+			wantTool: "gcloud",
+			wantArgs: []string{"kms", "asymmetric-decrypt", "--project", "prod", "--location", "europe-west1",
+				"--keyring", "saml", "--key", "sp", "--version", "3", "--ciphertext-file", "-", "--plaintext-file", "-"},
+			wantStdin: []byte("wrapped"),
+		},
+		{
+			name:     "Azure",
+			ref:      "https://prod.vault.azure.net/keys/sp/01",
+			opts:     &rsa.PKCS1v15DecryptOptions{},
+			out:      `{"algorithm": "RSA1_5", "kid": "https://prod.vault.azure.net/keys/sp/01", "result": "` + base64.RawURLEncoding.EncodeToString(sessionKey) + `"}`,
+			wantTool: "az",
+			wantArgs: []string{"keyvault", "key", "decrypt", "--id", "https://prod.vault.azure.net/keys/sp/01",
+				"--algorithm", "RSA1_5", "--data-type", "base64", "--value", base64.StdEncoding.EncodeToString([]byte("wrapped")),
+				"--output", "json"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := New(tt.ref)
+			require.NoError(t, err)
+
+			var tool string
+			var args []string
+			var stdin []byte
+			key.run = fakeRun(tt.out, &tool, &args, &stdin)
+
+			plaintext, err := key.Decrypt(rand.Reader, []byte("wrapped"), tt.opts)
+			require.NoError(t, err)
+			assert.Equal(t, sessionKey, plaintext)
+			assert.Equal(t, tt.wantTool, tool)
+			assert.Equal(t, tt.wantArgs, args)
+			assert.Equal(t, tt.wantStdin, stdin)
+		})
+	}
+}
+
+func TestKey_DecryptUnsupported(t *testing.T) {
+	aws, err := New("arn:aws:kms:eu-central-1:111122223333:key/abcd")
+	require.NoError(t, err)
+
+	_, err = aws.Decrypt(rand.Reader, nil, &rsa.PKCS1v15DecryptOptions{})
+	assert.EqualError(t, err, "AWS KMS only supports RSA-OAEP key transport")
+
+	_, err = aws.Decrypt(rand.Reader, nil, &rsa.OAEPOptions{Hash: crypto.SHA256, MGFHash: crypto.SHA1})
+	assert.EqualError(t, err, "AWS KMS does not support OAEP with a SHA-1 mask and SHA-256 digest")
+
+	_, err = aws.Decrypt(rand.Reader, nil, &rsa.OAEPOptions{Hash: crypto.SHA512})
+	assert.EqualError(t, err, "AWS KMS does not support OAEP with SHA-512")
+}
@@ -0,0 +1,52 @@
+// Package log is the diagnostic logger of samlurai. Packages log the
+// decisions their heuristics take, such as which base64 variant decoded a
+// message, at debug level, so --verbose can explain a silent fallback.
+package log
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync/atomic"
+)
+
+var logger atomic.Pointer[slog.Logger]
+
+func init() {
+	logger.Store(slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+// Setup writes log records at or above level to w. Until it is called,
+// nothing is logged.
+func Setup(w io.Writer, level slog.Level) {
+	logger.Store(slog.New(slog.NewTextHandler(w, &slog.HandlerOptions{
+		Level: level,
+		// Records are read by people debugging a single run
+		ReplaceAttr: func(groups []string, attr slog.Attr) slog.Attr {
+			if len(groups) == 0 && attr.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return attr
+		},
+	})))
+}
+
+// Enabled reports whether records at level are logged
+func Enabled(level slog.Level) bool {
+	return logger.Load().Enabled(context.Background(), level)
+}
+
+// Debug logs a decision or intermediate result
+func Debug(msg string, args ...any) {
+	logger.Load().Debug(msg, args...)
+}
+
+// Info logs progress
+func Info(msg string, args ...any) {
+	logger.Load().Info(msg, args...)
+}
+
+// Warn logs a problem that does not stop the command
+func Warn(msg string, args ...any) {
+	logger.Load().Warn(msg, args...)
+}
@@ -0,0 +1,27 @@
+package log
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetup(t *testing.T) {
+	defer Setup(io.Discard, slog.LevelWarn)
+
+	var buf bytes.Buffer
+	Setup(&buf, slog.LevelDebug)
+	assert.True(t, Enabled(slog.LevelDebug))
+	Debug("base64 decoded", "variant", "url-safe", "bytes", 42)
+	assert.Equal(t, "level=DEBUG msg=\"base64 decoded\" variant=url-safe bytes=42\n", buf.String())
+
+	buf.Reset()
+	Setup(&buf, slog.LevelError)
+	Debug("hidden")
+	Warn("hidden")
+	assert.Empty(t, buf.String())
+	assert.False(t, Enabled(slog.LevelWarn))
+}
@@ -0,0 +1,273 @@
+package metadata
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/beevik/etree"
+	"github.com/gliwka/SAMLurai/internal/saml"
+)
+
+// Kinds of Change
+const (
+	ChangeAdded   = "added"
+	ChangeRemoved = "removed"
+	ChangeChanged = "changed"
+)
+
+// Categories of Change
+const (
+	CategoryEntity       = "entity"
+	CategoryEndpoint     = "endpoint"
+	CategoryCertificate  = "certificate"
+	CategoryNameIDFormat = "name-id-format"
+	CategoryAttribute    = "attribute"
+	CategorySetting      = "setting"
+)
+
+// Change is one difference between two versions of an entity's metadata
+type Change struct {
+	Kind     string `json:"kind"`
+	Category string `json:"category"`
+
+	// Role is the role descriptor the change is in, e.g. IDPSSODescriptor
+	Role string `json:"role,omitempty"`
+
+	// Subject names what changed, e.g. "SingleSignOnService (HTTP-POST)"
+	Subject string `json:"subject"`
+
+	Old string `json:"old,omitempty"`
+	New string `json:"new,omitempty"`
+}
+
+// fact is a comparable property of a metadata document
+type fact struct {
+	category string
+	role     string
+	key      string
+	subject  string
+	value    string
+
+	// group pairs up certificates of the same role and use, so that a
+	// replaced certificate is reported as a rollover
+	group string
+}
+
+// roleSettings are the role descriptor attributes worth reporting
+var roleSettings = []string{"WantAuthnRequestsSigned", "AuthnRequestsSigned", "WantAssertionsSigned", "protocolSupportEnumeration"}
+
+// endpointTags are the role descriptor children describing endpoints
+var endpointTags = map[string]bool{
+	"SingleSignOnService":       true,
+	"SingleLogoutService":       true,
+	"ArtifactResolutionService": true,
+	"ManageNameIDService":       true,
+	"NameIDMappingService":      true,
+	"AssertionIDRequestService": true,
+	"AssertionConsumerService":  true,
+	"AttributeService":          true,
+	"DiscoveryResponse":         true,
+	"RequestInitiator":          true,
+	"AuthnQueryService":         true,
+	"AuthzService":              true,
+}
+
+// Diff compares two versions of an entity's metadata and returns the
+// changes to endpoints, certificates, NameID formats, attributes and
+// security settings. entityID selects the entity from aggregates, as in
+// saml.SelectEntity. validUntil and cacheDuration are ignored, since they
+// change with every refresh.
+//
+// A certificate that is replaced by exactly one other certificate of the
+// same use is reported as a single change (a rollover); while old and new
+// certificates are published side by side, the new one is reported as
+// added.
+func Diff(oldMetadata, newMetadata []byte, entityID string) ([]Change, error) {
+	oldFacts, err := entityFacts(oldMetadata, entityID)
+	if err != nil {
+		return nil, fmt.Errorf("old metadata: %w", err)
+	}
+	newFacts, err := entityFacts(newMetadata, entityID)
+	if err != nil {
+		return nil, fmt.Errorf("new metadata: %w", err)
+	}
+
+	newByKey := make(map[string]fact, len(newFacts))
+	for _, f := range newFacts {
+		newByKey[f.key] = f
+	}
+	oldKeys := make(map[string]bool, len(oldFacts))
+
+	var changes []Change
+	var groups []string
+	for _, o := range oldFacts {
+		oldKeys[o.key] = true
+		n, ok := newByKey[o.key]
+		switch {
+		case !ok:
+			changes = append(changes, Change{Kind: ChangeRemoved, Category: o.category, Role: o.role, Subject: o.subject, Old: o.value})
+			groups = append(groups, o.group)
+		case n.value != o.value:
+			changes = append(changes, Change{Kind: ChangeChanged, Category: o.category, Role: o.role, Subject: o.subject, Old: o.value, New: n.value})
+			groups = append(groups, "")
+		}
+	}
+	for _, n := range newFacts {
+		if !oldKeys[n.key] {
+			changes = append(changes, Change{Kind: ChangeAdded, Category: n.category, Role: n.role, Subject: n.subject, New: n.value})
+			groups = append(groups, n.group)
+		}
+	}
+	return mergeRollovers(changes, groups), nil
+}
+
+// mergeRollovers replaces a removed and an added certificate of the same
+// group with one changed certificate, if they are the only changes in it
+func mergeRollovers(changes []Change, groups []string) []Change {
+	removed := map[string][]int{}
+	added := map[string][]int{}
+	for i, change := range changes {
+		if groups[i] == "" {
+			continue
+		}
+		switch change.Kind {
+		case ChangeRemoved:
+			removed[groups[i]] = append(removed[groups[i]], i)
+		case ChangeAdded:
+			added[groups[i]] = append(added[groups[i]], i)
+		}
+	}
+
+	drop := map[int]bool{}
+	for group, r := range removed {
+		a := added[group]
+		if len(r) != 1 || len(a) != 1 {
+			continue
+		}
+		changes[r[0]].Kind = ChangeChanged
+		changes[r[0]].New = changes[a[0]].New
+		drop[a[0]] = true
+	}
+
+	merged := changes[:0]
+	for i, change := range changes {
+		if !drop[i] {
+			merged = append(merged, change)
+		}
+	}
+	return merged
+}
+
+func entityFacts(metadata []byte, entityID string) ([]fact, error) {
+	entity, err := saml.SelectEntity(metadata, entityID)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := saml.ReadDocument(entity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse metadata: %w", err)
+	}
+	root := doc.Root()
+
+	facts := []fact{{
+		category: CategoryEntity,
+		key:      "entityID",
+		subject:  "entityID",
+		value:    root.SelectAttrValue("entityID", ""),
+	}}
+
+	for _, role := range root.ChildElements() {
+		if !strings.HasSuffix(role.Tag, "Descriptor") {
+			continue
+		}
+		facts = append(facts, roleFacts(role)...)
+	}
+	return facts, nil
+}
+
+func roleFacts(role *etree.Element) []fact {
+	name := role.Tag
+	facts := []fact{{category: CategoryEntity, role: name, key: name, subject: "role " + name}}
+	add := func(category, key, subject, value string) *fact {
+		facts = append(facts, fact{category: category, role: name, key: name + "|" + category + "|" + key, subject: subject, value: value})
+		return &facts[len(facts)-1]
+	}
+
+	for _, setting := range roleSettings {
+		if attr := role.SelectAttr(setting); attr != nil {
+			add(CategorySetting, setting, setting, attr.Value)
+		}
+	}
+
+	// Endpoints of one service and binding are told apart by index, or by
+	// their order if they have none
+	ordinal := map[string]int{}
+	for _, el := range role.ChildElements() {
+		switch {
+		case endpointTags[el.Tag]:
+			binding := el.SelectAttrValue("Binding", "")
+			id := el.SelectAttrValue("index", "")
+			if id == "" {
+				base := el.Tag + "|" + binding
+				ordinal[base]++
+				id = fmt.Sprintf("#%d", ordinal[base])
+			}
+			subject := fmt.Sprintf("%s (%s)", el.Tag, shortURN(binding))
+			if index := el.SelectAttrValue("index", ""); index != "" {
+				subject = fmt.Sprintf("%s (%s, index %s)", el.Tag, shortURN(binding), index)
+			}
+			location := el.SelectAttrValue("Location", "")
+			if response := el.SelectAttrValue("ResponseLocation", ""); response != "" {
+				location += " (response: " + response + ")"
+			}
+			add(CategoryEndpoint, el.Tag+"|"+binding+"|"+id, subject, location)
+
+		case el.Tag == "NameIDFormat":
+			format := strings.TrimSpace(el.Text())
+			add(CategoryNameIDFormat, format, format, "")
+
+		case el.Tag == "KeyDescriptor":
+			use := el.SelectAttrValue("use", "signing and encryption")
+			for _, certEl := range el.FindElements(".//X509Certificate") {
+				cert, err := saml.ParseCertificate([]byte(certEl.Text()))
+				if err != nil {
+					continue
+				}
+				info := saml.NewCertificateInfo(cert)
+				value := fmt.Sprintf("%s (SHA-256 %s, expires %s)", info.Subject, info.SHA256Fingerprint, info.NotAfter.Format("2006-01-02"))
+				add(CategoryCertificate, use+"|"+info.SHA256Fingerprint, use+" certificate", value).group = name + "|" + use
+			}
+
+		case el.Tag == "Attribute":
+			// Attributes an IdP declares it can release
+			add(CategoryAttribute, el.SelectAttrValue("Name", ""), attributeSubject(el), "")
+
+		case el.Tag == "AttributeConsumingService":
+			for _, attr := range el.SelectElements("RequestedAttribute") {
+				requirement := "optional"
+				if v := attr.SelectAttrValue("isRequired", "false"); v == "true" || v == "1" {
+					requirement = "required"
+				}
+				add(CategoryAttribute, "requested|"+attr.SelectAttrValue("Name", ""), "requested "+attributeSubject(attr), requirement)
+			}
+		}
+	}
+	return facts
+}
+
+func attributeSubject(el *etree.Element) string {
+	name := el.SelectAttrValue("Name", "")
+	if friendly := el.SelectAttrValue("FriendlyName", ""); friendly != "" {
+		return fmt.Sprintf("%s (%s)", name, friendly)
+	}
+	return name
+}
+
+// shortURN returns the last segment of a URN, e.g. HTTP-POST for the
+// HTTP-POST binding
+func shortURN(urn string) string {
+	if i := strings.LastIndex(urn, ":"); i >= 0 {
+		return urn[i+1:]
+	}
+	return urn
+}
@@ -0,0 +1,109 @@
+package metadata
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func idpMetadata(certs []string, body string) string {
+	keys := ""
+	for _, cert := range certs {
+		keys += `<md:KeyDescriptor use="signing"><ds:KeyInfo><ds:X509Data><ds:X509Certificate>` + cert + `</ds:X509Certificate></ds:X509Data></ds:KeyInfo></md:KeyDescriptor>`
+	}
+	return `<md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" xmlns:ds="http://www.w3.org/2000/09/xmldsig#" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" entityID="https://idp.example.com">` +
+		`<md:IDPSSODescriptor WantAuthnRequestsSigned="false" protocolSupportEnumeration="urn:oasis:names:tc:SAML:2.0:protocol">` +
+		keys + body + `</md:IDPSSODescriptor></md:EntityDescriptor>`
+}
+
+func testCertBase64(t *testing.T) string {
+	t.Helper()
+	_, cert := signingKey(t)
+	return base64.StdEncoding.EncodeToString(cert.Raw)
+}
+
+func TestDiff(t *testing.T) {
+	oldCert, newCert := testCertBase64(t), testCertBase64(t)
+	oldMD := idpMetadata([]string{oldCert}, `
+		<md:SingleLogoutService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect" Location="https://idp.example.com/slo"/>
+		<md:NameIDFormat>urn:oasis:names:tc:SAML:1.1:nameid-format:emailAddress</md:NameIDFormat>
+		<md:SingleSignOnService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect" Location="https://idp.example.com/sso"/>
+		<saml:Attribute Name="mail"/>`)
+	newMD := idpMetadata([]string{newCert}, `
+		<md:NameIDFormat>urn:oasis:names:tc:SAML:2.0:nameid-format:persistent</md:NameIDFormat>
+		<md:SingleSignOnService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect" Location="https://login.example.com/sso"/>
+		<saml:Attribute Name="mail"/>
+		<saml:Attribute Name="groups" FriendlyName="memberOf"/>`)
+
+	changes, err := Diff([]byte(oldMD), []byte(newMD), "")
+	require.NoError(t, err)
+	require.Len(t, changes, 6)
+
+	// The replaced signing certificate is a single rollover
+	rollover := changes[0]
+	assert.Equal(t, ChangeChanged, rollover.Kind)
+	assert.Equal(t, CategoryCertificate, rollover.Category)
+	assert.Equal(t, "IDPSSODescriptor", rollover.Role)
+	assert.Equal(t, "signing certificate", rollover.Subject)
+	assert.Contains(t, rollover.Old, "CN=federation.example.org (SHA-256 ")
+	assert.NotEqual(t, rollover.Old, rollover.New)
+
+	assert.Equal(t, Change{Kind: ChangeRemoved, Category: CategoryEndpoint, Role: "IDPSSODescriptor", Subject: "SingleLogoutService (HTTP-Redirect)", Old: "https://idp.example.com/slo"}, changes[1])
+	assert.Equal(t, Change{Kind: ChangeRemoved, Category: CategoryNameIDFormat, Role: "IDPSSODescriptor", Subject: "urn:oasis:names:tc:SAML:1.1:nameid-format:emailAddress"}, changes[2])
+	assert.Equal(t, Change{Kind: ChangeChanged, Category: CategoryEndpoint, Role: "IDPSSODescriptor", Subject: "SingleSignOnService (HTTP-Redirect)", Old: "https://idp.example.com/sso", New: "https://login.example.com/sso"}, changes[3])
+	assert.Equal(t, Change{Kind: ChangeAdded, Category: CategoryNameIDFormat, Role: "IDPSSODescriptor", Subject: "urn:oasis:names:tc:SAML:2.0:nameid-format:persistent"}, changes[4])
+	assert.Equal(t, Change{Kind: ChangeAdded, Category: CategoryAttribute, Role: "IDPSSODescriptor", Subject: "groups (memberOf)"}, changes[5])
+}
+
+func TestDiff_CertificateOverlap(t *testing.T) {
+	oldCert, newCert := testCertBase64(t), testCertBase64(t)
+
+	// During a rollover both certificates are published
+	changes, err := Diff([]byte(idpMetadata([]string{oldCert}, "")), []byte(idpMetadata([]string{oldCert, newCert}, "")), "")
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	assert.Equal(t, ChangeAdded, changes[0].Kind)
+	assert.Equal(t, CategoryCertificate, changes[0].Category)
+
+	changes, err = Diff([]byte(idpMetadata([]string{oldCert}, "")), []byte(idpMetadata([]string{oldCert}, "")), "")
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+}
+
+func TestDiff_SPRequirements(t *testing.T) {
+	sp := func(required, signed string) string {
+		return `<md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" entityID="https://sp.example.com">` +
+			`<md:SPSSODescriptor WantAssertionsSigned="` + signed + `" protocolSupportEnumeration="urn:oasis:names:tc:SAML:2.0:protocol">` +
+			`<md:AssertionConsumerService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST" Location="https://sp.example.com/acs" index="0"/>` +
+			`<md:AttributeConsumingService index="0"><md:ServiceName xml:lang="en">SP</md:ServiceName>` +
+			`<md:RequestedAttribute Name="urn:oid:0.9.2342.19200300.100.1.3" FriendlyName="mail" isRequired="` + required + `"/>` +
+			`</md:AttributeConsumingService></md:SPSSODescriptor></md:EntityDescriptor>`
+	}
+
+	changes, err := Diff([]byte(sp("false", "false")), []byte(sp("true", "true")), "")
+	require.NoError(t, err)
+	assert.Equal(t, []Change{
+		{Kind: ChangeChanged, Category: CategorySetting, Role: "SPSSODescriptor", Subject: "WantAssertionsSigned", Old: "false", New: "true"},
+		{Kind: ChangeChanged, Category: CategoryAttribute, Role: "SPSSODescriptor", Subject: "requested urn:oid:0.9.2342.19200300.100.1.3 (mail)", Old: "optional", New: "required"},
+	}, changes)
+}
+
+func TestDiff_Aggregate(t *testing.T) {
+	aggregate := `<md:EntitiesDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata">` +
+		`<md:EntityDescriptor entityID="https://a.example.com"><md:IDPSSODescriptor/></md:EntityDescriptor>` +
+		`<md:EntityDescriptor entityID="https://b.example.com"><md:SPSSODescriptor/></md:EntityDescriptor>` +
+		`</md:EntitiesDescriptor>`
+	single := `<md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" entityID="https://b.example.com"><md:SPSSODescriptor/></md:EntityDescriptor>`
+
+	changes, err := Diff([]byte(aggregate), []byte(single), "https://b.example.com")
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+
+	_, err = Diff([]byte(aggregate), []byte(single), "")
+	assert.ErrorContains(t, err, "old metadata: metadata is an aggregate")
+
+	_, err = Diff([]byte(single), []byte(`<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol"/>`), "")
+	assert.ErrorContains(t, err, "new metadata: not a metadata document")
+}
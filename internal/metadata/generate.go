@@ -0,0 +1,322 @@
+package metadata
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/beevik/etree"
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"gopkg.in/yaml.v3"
+)
+
+// SAML 2.0 binding URIs
+const (
+	BindingHTTPPost     = saml.BindingHTTPPost
+	BindingHTTPRedirect = saml.BindingHTTPRedirect
+	BindingHTTPArtifact = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Artifact"
+	BindingSOAP         = "urn:oasis:names:tc:SAML:2.0:bindings:SOAP"
+	BindingPAOS         = "urn:oasis:names:tc:SAML:2.0:bindings:PAOS"
+)
+
+// bindings maps the short binding names accepted in configs and flags
+var bindings = map[string]string{
+	"post":       BindingHTTPPost,
+	"redirect":   BindingHTTPRedirect,
+	"artifact":   BindingHTTPArtifact,
+	"soap":       BindingSOAP,
+	"paos":       BindingPAOS,
+	"simplesign": saml.BindingSimpleSign,
+}
+
+// nameIDFormats maps the short NameID format names accepted in configs and
+// flags
+var nameIDFormats = map[string]string{
+	"email":       "urn:oasis:names:tc:SAML:1.1:nameid-format:emailAddress",
+	"unspecified": "urn:oasis:names:tc:SAML:1.1:nameid-format:unspecified",
+	"persistent":  "urn:oasis:names:tc:SAML:2.0:nameid-format:persistent",
+	"transient":   "urn:oasis:names:tc:SAML:2.0:nameid-format:transient",
+	"entity":      "urn:oasis:names:tc:SAML:2.0:nameid-format:entity",
+}
+
+// attrNameFormatURI is the NameFormat of attributes named by URI or OID
+const attrNameFormatURI = "urn:oasis:names:tc:SAML:2.0:attrname-format:uri"
+
+// SPConfig describes a service provider to generate metadata for, e.g.
+//
+//	entity_id: https://sp.example.com/saml
+//	acs:
+//	  - url: https://sp.example.com/saml/acs
+//	  - url: https://sp.example.com/saml/acs/artifact
+//	    binding: artifact
+//	slo:
+//	  - url: https://sp.example.com/saml/slo
+//	signing_cert: sp-signing.pem
+//	encryption_cert: sp-encryption.pem
+//	name_id_formats: [persistent, email]
+//	want_assertions_signed: true
+//	service_name: Example App
+//	requested_attributes:
+//	  - name: urn:oid:0.9.2342.19200300.100.1.3
+//	    friendly_name: mail
+//	    required: true
+type SPConfig struct {
+	EntityID string `yaml:"entity_id"`
+
+	// ACS are the AssertionConsumerService endpoints; the first is the default
+	ACS []Endpoint `yaml:"acs"`
+
+	// SLO are the SingleLogoutService endpoints
+	SLO []Endpoint `yaml:"slo"`
+
+	// SigningCert and EncryptionCert are paths to PEM or DER certificates,
+	// relative to the config file
+	SigningCert    string `yaml:"signing_cert"`
+	EncryptionCert string `yaml:"encryption_cert"`
+
+	// SigningCertificate and EncryptionCertificate are used instead of
+	// the certificate files when set, e.g. for generated keys
+	SigningCertificate    *x509.Certificate `yaml:"-"`
+	EncryptionCertificate *x509.Certificate `yaml:"-"`
+
+	// NameIDFormats are URIs or short names (email, persistent, transient,
+	// unspecified, entity)
+	NameIDFormats []string `yaml:"name_id_formats"`
+
+	AuthnRequestsSigned  bool `yaml:"authn_requests_signed"`
+	WantAssertionsSigned bool `yaml:"want_assertions_signed"`
+
+	// ServiceName names the AttributeConsumingService; it defaults to the
+	// entity ID when attributes are requested
+	ServiceName         string               `yaml:"service_name"`
+	RequestedAttributes []RequestedAttribute `yaml:"requested_attributes"`
+
+	// ValidFor sets validUntil relative to the generation time (optional)
+	ValidFor time.Duration `yaml:"valid_for"`
+
+	// baseDir resolves relative certificate paths
+	baseDir string
+}
+
+// Endpoint is a service location and its binding, given as a URI or a
+// short name (post, redirect, artifact, soap, paos, simplesign)
+type Endpoint struct {
+	URL     string `yaml:"url"`
+	Binding string `yaml:"binding"`
+}
+
+// RequestedAttribute is an attribute the SP asks the IdP to release
+type RequestedAttribute struct {
+	Name         string `yaml:"name"`
+	FriendlyName string `yaml:"friendly_name"`
+
+	// NameFormat defaults to the URI format for names containing a colon
+	NameFormat string `yaml:"name_format"`
+	Required   bool   `yaml:"required"`
+}
+
+// LoadSPConfig reads a YAML SP description. It is validated by GenerateSP,
+// since flags may still complete it.
+func LoadSPConfig(path string) (*SPConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+	var cfg SPConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+	cfg.baseDir = filepath.Dir(path)
+	return &cfg, nil
+}
+
+// ParseEndpoint parses an endpoint flag value: a URL, optionally prefixed
+// with a binding name, e.g. "artifact=https://sp.example.com/acs"
+func ParseEndpoint(value string) Endpoint {
+	if name, url, ok := strings.Cut(value, "="); ok {
+		if _, known := bindings[strings.ToLower(name)]; known {
+			return Endpoint{URL: url, Binding: name}
+		}
+	}
+	return Endpoint{URL: value}
+}
+
+// resolveBinding returns the URI for a binding name, or def if it is empty
+func resolveBinding(name, def string) (string, error) {
+	if name == "" {
+		return def, nil
+	}
+	if uri, ok := bindings[strings.ToLower(name)]; ok {
+		return uri, nil
+	}
+	if strings.HasPrefix(name, "urn:") {
+		return name, nil
+	}
+	return "", fmt.Errorf("unknown binding %q", name)
+}
+
+// ResolveNameIDFormat returns the URI for a NameID format name or URI
+func ResolveNameIDFormat(name string) (string, error) {
+	if uri, ok := nameIDFormats[strings.ToLower(name)]; ok {
+		return uri, nil
+	}
+	if strings.HasPrefix(name, "urn:") {
+		return name, nil
+	}
+	return "", fmt.Errorf("unknown NameID format %q", name)
+}
+
+// Signer signs generated metadata
+type Signer struct {
+	Key crypto.Signer
+
+	// SigAlg is a signature algorithm URI, e.g. saml.SigAlgRSASHA256
+	SigAlg string
+}
+
+// GenerateSP renders SP metadata for cfg, valid from now. If signer is set,
+// the metadata is signed, with the signing certificate in the KeyInfo.
+func GenerateSP(cfg *SPConfig, now time.Time, signer *Signer) ([]byte, error) {
+	if cfg.EntityID == "" {
+		return nil, errors.New("an entity ID is required")
+	}
+	if len(cfg.ACS) == 0 {
+		return nil, errors.New("at least one AssertionConsumerService URL is required")
+	}
+
+	doc := etree.NewDocument()
+	doc.CreateProcInst("xml", `version="1.0" encoding="UTF-8"`)
+	entity := doc.CreateElement("md:EntityDescriptor")
+	entity.CreateAttr("xmlns:md", saml.MetadataNamespace)
+	if cfg.SigningCert != "" || cfg.EncryptionCert != "" || cfg.SigningCertificate != nil || cfg.EncryptionCertificate != nil || signer != nil {
+		entity.CreateAttr("xmlns:ds", saml.XMLDSigNamespace)
+	}
+	entity.CreateAttr("entityID", cfg.EntityID)
+	if cfg.ValidFor > 0 {
+		entity.CreateAttr("validUntil", now.Add(cfg.ValidFor).UTC().Format(time.RFC3339))
+	}
+
+	sp := entity.CreateElement("md:SPSSODescriptor")
+	sp.CreateAttr("AuthnRequestsSigned", strconv.FormatBool(cfg.AuthnRequestsSigned))
+	sp.CreateAttr("WantAssertionsSigned", strconv.FormatBool(cfg.WantAssertionsSigned))
+	sp.CreateAttr("protocolSupportEnumeration", "urn:oasis:names:tc:SAML:2.0:protocol")
+
+	// Elements follow the order of the metadata schema
+	var signingCert *x509.Certificate
+	for _, kd := range []struct {
+		use  string
+		path string
+		cert *x509.Certificate
+	}{{"signing", cfg.SigningCert, cfg.SigningCertificate}, {"encryption", cfg.EncryptionCert, cfg.EncryptionCertificate}} {
+		cert := kd.cert
+		if cert == nil {
+			if kd.path == "" {
+				continue
+			}
+			var err error
+			if cert, err = saml.LoadCertificate(cfg.resolvePath(kd.path)); err != nil {
+				return nil, fmt.Errorf("%s certificate: %w", kd.use, err)
+			}
+		}
+		if kd.use == "signing" {
+			signingCert = cert
+		}
+		keyDescriptor := sp.CreateElement("md:KeyDescriptor")
+		keyDescriptor.CreateAttr("use", kd.use)
+		keyDescriptor.CreateElement("ds:KeyInfo").CreateElement("ds:X509Data").CreateElement("ds:X509Certificate").
+			SetText(base64.StdEncoding.EncodeToString(cert.Raw))
+	}
+
+	for _, slo := range cfg.SLO {
+		binding, err := resolveBinding(slo.Binding, BindingHTTPRedirect)
+		if err != nil {
+			return nil, fmt.Errorf("SingleLogoutService %s: %w", slo.URL, err)
+		}
+		el := sp.CreateElement("md:SingleLogoutService")
+		el.CreateAttr("Binding", binding)
+		el.CreateAttr("Location", slo.URL)
+	}
+
+	for _, name := range cfg.NameIDFormats {
+		format, err := ResolveNameIDFormat(name)
+		if err != nil {
+			return nil, err
+		}
+		sp.CreateElement("md:NameIDFormat").SetText(format)
+	}
+
+	for i, acs := range cfg.ACS {
+		binding, err := resolveBinding(acs.Binding, BindingHTTPPost)
+		if err != nil {
+			return nil, fmt.Errorf("AssertionConsumerService %s: %w", acs.URL, err)
+		}
+		el := sp.CreateElement("md:AssertionConsumerService")
+		el.CreateAttr("Binding", binding)
+		el.CreateAttr("Location", acs.URL)
+		el.CreateAttr("index", strconv.Itoa(i))
+		if i == 0 {
+			el.CreateAttr("isDefault", "true")
+		}
+	}
+
+	if len(cfg.RequestedAttributes) > 0 {
+		service := sp.CreateElement("md:AttributeConsumingService")
+		service.CreateAttr("index", "0")
+		name := cfg.ServiceName
+		if name == "" {
+			name = cfg.EntityID
+		}
+		serviceName := service.CreateElement("md:ServiceName")
+		serviceName.CreateAttr("xml:lang", "en")
+		serviceName.SetText(name)
+		for _, attr := range cfg.RequestedAttributes {
+			if attr.Name == "" {
+				return nil, errors.New("requested attributes need a name")
+			}
+			el := service.CreateElement("md:RequestedAttribute")
+			el.CreateAttr("Name", attr.Name)
+			nameFormat := attr.NameFormat
+			if nameFormat == "" && strings.Contains(attr.Name, ":") {
+				nameFormat = attrNameFormatURI
+			}
+			if nameFormat != "" {
+				el.CreateAttr("NameFormat", nameFormat)
+			}
+			if attr.FriendlyName != "" {
+				el.CreateAttr("FriendlyName", attr.FriendlyName)
+			}
+			el.CreateAttr("isRequired", strconv.FormatBool(attr.Required))
+		}
+	}
+
+	// Indentation is part of the signed content, so it comes first
+	doc.Indent(2)
+	if signer != nil {
+		if signingCert == nil {
+			return nil, errors.New("signing the metadata requires a signing certificate")
+		}
+		id, err := saml.NewID()
+		if err != nil {
+			return nil, err
+		}
+		entity.CreateAttr("ID", id)
+		if err := saml.SignEnveloped(entity, signer.Key, signingCert, signer.SigAlg); err != nil {
+			return nil, fmt.Errorf("failed to sign metadata: %w", err)
+		}
+	}
+	return doc.WriteToBytes()
+}
+
+func (c *SPConfig) resolvePath(path string) string {
+	if c.baseDir == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(c.baseDir, path)
+}
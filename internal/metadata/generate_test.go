@@ -0,0 +1,106 @@
+package metadata
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateSP(t *testing.T) {
+	cfg := &SPConfig{
+		EntityID:             "https://sp.example.com",
+		ACS:                  []Endpoint{ParseEndpoint("https://sp.example.com/acs"), ParseEndpoint("artifact=https://sp.example.com/acs/artifact")},
+		SLO:                  []Endpoint{{URL: "https://sp.example.com/slo"}},
+		NameIDFormats:        []string{"persistent", "urn:example:custom"},
+		WantAssertionsSigned: true,
+		RequestedAttributes: []RequestedAttribute{
+			{Name: "urn:oid:0.9.2342.19200300.100.1.3", FriendlyName: "mail", Required: true},
+			{Name: "department"},
+		},
+		ValidFor: 24 * time.Hour,
+	}
+
+	generated, err := GenerateSP(cfg, now, nil)
+	require.NoError(t, err)
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" entityID="https://sp.example.com" validUntil="2025-06-02T12:00:00Z">
+  <md:SPSSODescriptor AuthnRequestsSigned="false" WantAssertionsSigned="true" protocolSupportEnumeration="urn:oasis:names:tc:SAML:2.0:protocol">
+    <md:SingleLogoutService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect" Location="https://sp.example.com/slo"/>
+    <md:NameIDFormat>urn:oasis:names:tc:SAML:2.0:nameid-format:persistent</md:NameIDFormat>
+    <md:NameIDFormat>urn:example:custom</md:NameIDFormat>
+    <md:AssertionConsumerService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST" Location="https://sp.example.com/acs" index="0" isDefault="true"/>
+    <md:AssertionConsumerService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Artifact" Location="https://sp.example.com/acs/artifact" index="1"/>
+    <md:AttributeConsumingService index="0">
+      <md:ServiceName xml:lang="en">https://sp.example.com</md:ServiceName>
+      <md:RequestedAttribute Name="urn:oid:0.9.2342.19200300.100.1.3" NameFormat="urn:oasis:names:tc:SAML:2.0:attrname-format:uri" FriendlyName="mail" isRequired="true"/>
+      <md:RequestedAttribute Name="department" isRequired="false"/>
+    </md:AttributeConsumingService>
+  </md:SPSSODescriptor>
+</md:EntityDescriptor>
+`, string(generated))
+}
+
+func TestGenerateSP_SignedRoundTrip(t *testing.T) {
+	key, cert := signingKey(t)
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "sp.pem"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}), 0600))
+	configPath := filepath.Join(dir, "sp.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte(`entity_id: https://sp.example.com
+acs:
+  - url: https://sp.example.com/acs
+signing_cert: sp.pem
+encryption_cert: sp.pem
+`), 0600))
+
+	cfg, err := LoadSPConfig(configPath)
+	require.NoError(t, err)
+	generated, err := GenerateSP(cfg, now, &Signer{Key: key, SigAlg: saml.SigAlgRSASHA256})
+	require.NoError(t, err)
+
+	// The result loads as signed metadata with both key descriptors
+	path := filepath.Join(dir, "sp-metadata.xml")
+	require.NoError(t, os.WriteFile(path, generated, 0600))
+	doc, err := Load(context.Background(), path, Options{Now: now, SigningCerts: []*x509.Certificate{cert}})
+	require.NoError(t, err)
+	assert.True(t, doc.Signed)
+	certs, err := saml.MetadataSigningCertificates(doc.Data)
+	require.NoError(t, err)
+	assert.Len(t, certs, 1)
+	assert.Contains(t, string(generated), `<md:KeyDescriptor use="encryption">`)
+}
+
+func TestGenerateSP_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  SPConfig
+		err  string
+	}{
+		{"no entity ID", SPConfig{ACS: []Endpoint{{URL: "https://sp/acs"}}}, "an entity ID is required"},
+		{"no ACS", SPConfig{EntityID: "sp"}, "at least one AssertionConsumerService URL is required"},
+		{"bad binding", SPConfig{EntityID: "sp", ACS: []Endpoint{{URL: "https://sp/acs", Binding: "carrier-pigeon"}}}, `AssertionConsumerService https://sp/acs: unknown binding "carrier-pigeon"`},
+		{"bad NameID format", SPConfig{EntityID: "sp", ACS: []Endpoint{{URL: "https://sp/acs"}}, NameIDFormats: []string{"upn"}}, `unknown NameID format "upn"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := GenerateSP(&tt.cfg, now, nil)
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+
+	key, _ := signingKey(t)
+	_, err := GenerateSP(&SPConfig{EntityID: "sp", ACS: []Endpoint{{URL: "https://sp/acs"}}}, now, &Signer{Key: key, SigAlg: saml.SigAlgRSASHA256})
+	assert.EqualError(t, err, "signing the metadata requires a signing certificate")
+}
+
+func TestParseEndpoint(t *testing.T) {
+	assert.Equal(t, Endpoint{URL: "https://sp.example.com/acs?a=b"}, ParseEndpoint("https://sp.example.com/acs?a=b"))
+	assert.Equal(t, Endpoint{URL: "https://sp.example.com/acs", Binding: "POST"}, ParseEndpoint("POST=https://sp.example.com/acs"))
+}
@@ -0,0 +1,91 @@
+package metadata
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+
+	"github.com/beevik/etree"
+	"github.com/gliwka/SAMLurai/internal/saml"
+)
+
+// IdPInfo is what an SP needs from IdP metadata to send AuthnRequests and
+// AttributeQueries and verify the Responses
+type IdPInfo struct {
+	EntityID string
+
+	// SSO maps binding URIs to SingleSignOnService locations
+	SSO map[string]string
+
+	// AttributeServices maps binding URIs to the AttributeService locations
+	// of the AttributeAuthorityDescriptor
+	AttributeServices map[string]string
+
+	// SigningCerts are the certificates of the signing KeyDescriptors
+	SigningCerts []*x509.Certificate
+}
+
+// ParseIdP reads the IDPSSODescriptor and AttributeAuthorityDescriptor of
+// an IdP's metadata; at least one of them must be present. Aggregates need
+// entityID to select the entity.
+func ParseIdP(data []byte, entityID string) (*IdPInfo, error) {
+	entity, err := saml.SelectEntity(data, entityID)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := saml.ReadDocument(entity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse metadata: %w", err)
+	}
+	root := doc.Root()
+
+	var descriptor, authority *etree.Element
+	for _, child := range root.ChildElements() {
+		if child.NamespaceURI() != saml.MetadataNamespace {
+			continue
+		}
+		switch {
+		case child.Tag == "IDPSSODescriptor" && descriptor == nil:
+			descriptor = child
+		case child.Tag == "AttributeAuthorityDescriptor" && authority == nil:
+			authority = child
+		}
+	}
+	if descriptor == nil && authority == nil {
+		return nil, errors.New("metadata has no IDPSSODescriptor")
+	}
+
+	info := &IdPInfo{
+		EntityID:          root.SelectAttrValue("entityID", ""),
+		SSO:               endpoints(descriptor, "SingleSignOnService"),
+		AttributeServices: endpoints(authority, "AttributeService"),
+	}
+
+	certs, err := saml.MetadataSigningCertificates(entity)
+	if err != nil {
+		return nil, err
+	}
+	for _, cert := range certs {
+		info.SigningCerts = append(info.SigningCerts, cert.Certificate)
+	}
+	return info, nil
+}
+
+// endpoints maps the bindings of a descriptor's endpoints with the given
+// tag to their locations, keeping the first location of each binding
+func endpoints(descriptor *etree.Element, tag string) map[string]string {
+	locations := map[string]string{}
+	if descriptor == nil {
+		return locations
+	}
+	for _, el := range descriptor.ChildElements() {
+		if el.Tag != tag {
+			continue
+		}
+		binding := el.SelectAttrValue("Binding", "")
+		if _, seen := locations[binding]; !seen {
+			locations[binding] = el.SelectAttrValue("Location", "")
+		}
+	}
+	return locations
+}
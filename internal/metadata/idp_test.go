@@ -0,0 +1,56 @@
+package metadata
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseIdP(t *testing.T) {
+	_, cert, err := saml.NewSelfSignedKey("idp.example.com")
+	require.NoError(t, err)
+
+	md := `<md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" xmlns:ds="http://www.w3.org/2000/09/xmldsig#" entityID="https://idp.example.com">
+  <md:IDPSSODescriptor protocolSupportEnumeration="urn:oasis:names:tc:SAML:2.0:protocol">
+    <md:KeyDescriptor use="signing"><ds:KeyInfo><ds:X509Data><ds:X509Certificate>` + base64.StdEncoding.EncodeToString(cert.Raw) + `</ds:X509Certificate></ds:X509Data></ds:KeyInfo></md:KeyDescriptor>
+    <md:SingleSignOnService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect" Location="https://idp.example.com/sso/redirect"/>
+    <md:SingleSignOnService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST" Location="https://idp.example.com/sso/post"/>
+  </md:IDPSSODescriptor>
+</md:EntityDescriptor>`
+
+	info, err := ParseIdP([]byte(md), "")
+	require.NoError(t, err)
+	assert.Equal(t, "https://idp.example.com", info.EntityID)
+	assert.Equal(t, "https://idp.example.com/sso/redirect", info.SSO[BindingHTTPRedirect])
+	assert.Equal(t, "https://idp.example.com/sso/post", info.SSO[BindingHTTPPost])
+	require.Len(t, info.SigningCerts, 1)
+	assert.Equal(t, cert.Raw, info.SigningCerts[0].Raw)
+}
+
+func TestParseIdP_NoIdPRole(t *testing.T) {
+	md := `<md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" entityID="https://sp.example.com"><md:SPSSODescriptor protocolSupportEnumeration="urn:oasis:names:tc:SAML:2.0:protocol"/></md:EntityDescriptor>`
+
+	_, err := ParseIdP([]byte(md), "")
+	assert.ErrorContains(t, err, "no IDPSSODescriptor")
+}
+
+func TestParseIdP_AttributeAuthority(t *testing.T) {
+	_, cert, err := saml.NewSelfSignedKey("aa.example.com")
+	require.NoError(t, err)
+
+	md := `<md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" xmlns:ds="http://www.w3.org/2000/09/xmldsig#" entityID="https://aa.example.com">
+  <md:AttributeAuthorityDescriptor protocolSupportEnumeration="urn:oasis:names:tc:SAML:2.0:protocol">
+    <md:KeyDescriptor use="signing"><ds:KeyInfo><ds:X509Data><ds:X509Certificate>` + base64.StdEncoding.EncodeToString(cert.Raw) + `</ds:X509Certificate></ds:X509Data></ds:KeyInfo></md:KeyDescriptor>
+    <md:AttributeService Binding="urn:oasis:names:tc:SAML:2.0:bindings:SOAP" Location="https://aa.example.com/soap"/>
+  </md:AttributeAuthorityDescriptor>
+</md:EntityDescriptor>`
+
+	info, err := ParseIdP([]byte(md), "")
+	require.NoError(t, err)
+	assert.Empty(t, info.SSO)
+	assert.Equal(t, "https://aa.example.com/soap", info.AttributeServices[BindingSOAP])
+	require.Len(t, info.SigningCerts, 1)
+}
@@ -0,0 +1,245 @@
+// Package metadata loads SAML metadata from files or HTTPS URLs. Fetched
+// documents are cached on disk for as long as their validUntil and
+// cacheDuration allow, and signed metadata is verified on every load.
+package metadata
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/beevik/etree"
+	"github.com/gliwka/SAMLurai/internal/saml"
+)
+
+// DefaultCacheDuration is how long fetched metadata without a
+// cacheDuration attribute is reused
+const DefaultCacheDuration = time.Hour
+
+// maxMetadataSize bounds downloads; federation aggregates run to tens of MB
+const maxMetadataSize = 256 << 20
+
+// Options configures how metadata is loaded
+type Options struct {
+	// CacheDir holds fetched metadata; empty means the user cache directory
+	CacheDir string
+
+	// Client is used to fetch URLs; nil means http.DefaultClient
+	Client *http.Client
+
+	// Now is the current time for expiry checks; zero means time.Now
+	Now time.Time
+
+	// SigningCerts are trusted to sign the metadata. If set, the metadata
+	// must carry a valid signature from one of them; otherwise a signature,
+	// if present, is only checked against its own KeyInfo certificate.
+	SigningCerts []*x509.Certificate
+
+	// AllowExpired accepts metadata past its validUntil, e.g. archived
+	// versions being compared
+	AllowExpired bool
+}
+
+// Document is a loaded metadata document
+type Document struct {
+	Data      []byte
+	Source    string
+	FromCache bool
+
+	// Signed is set if the signature verified against one of
+	// Options.SigningCerts
+	Signed bool
+
+	// SelfSigned is set if, without SigningCerts, the signature verified
+	// only against its own KeyInfo certificate: the metadata was not
+	// modified after signing, but anyone may have signed it
+	SelfSigned bool
+
+	ValidUntil *time.Time
+}
+
+// cacheEntry is stored next to each cached document
+type cacheEntry struct {
+	URL       string    `json:"url"`
+	FetchedAt time.Time `json:"fetched_at"`
+	Expires   time.Time `json:"expires"`
+}
+
+// IsURL reports whether source is an http or https URL rather than a path
+func IsURL(source string) bool {
+	return strings.HasPrefix(source, "https://") || strings.HasPrefix(source, "http://")
+}
+
+// Load reads metadata from a file or URL and validates its expiry and
+// signature
+func Load(ctx context.Context, source string, opts Options) (*Document, error) {
+	if opts.Now.IsZero() {
+		opts.Now = time.Now()
+	}
+
+	doc := &Document{Source: source}
+	var err error
+	if IsURL(source) {
+		doc.Data, doc.FromCache, err = fetch(ctx, source, opts)
+	} else {
+		doc.Data, err = os.ReadFile(source)
+		if err != nil {
+			err = fmt.Errorf("failed to read metadata: %w", err)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := parseRoot(doc.Data)
+	if err != nil {
+		return nil, err
+	}
+	validUntil, _, err := saml.MetadataValidity(root)
+	if err != nil {
+		return nil, err
+	}
+	if validUntil != nil && !opts.Now.Before(*validUntil) && !opts.AllowExpired {
+		return nil, fmt.Errorf("metadata expired on %s", validUntil.Format(time.RFC3339))
+	}
+	doc.ValidUntil = validUntil
+
+	switch _, err := saml.VerifySignature(root, opts.SigningCerts); {
+	case err == nil:
+		doc.Signed = len(opts.SigningCerts) > 0
+		doc.SelfSigned = !doc.Signed
+	case errors.Is(err, saml.ErrNoSignature):
+		if len(opts.SigningCerts) > 0 {
+			return nil, fmt.Errorf("metadata is not signed")
+		}
+	default:
+		return nil, fmt.Errorf("invalid metadata signature: %w", err)
+	}
+	return doc, nil
+}
+
+func parseRoot(data []byte) (*etree.Element, error) {
+	xmlDoc, err := saml.ReadDocument(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse metadata: %w", err)
+	}
+	if xmlDoc.Root() == nil {
+		return nil, fmt.Errorf("failed to parse metadata: no root element")
+	}
+	return xmlDoc.Root(), nil
+}
+
+// fetch returns the metadata at url, from the cache if it is still fresh
+func fetch(ctx context.Context, url string, opts Options) ([]byte, bool, error) {
+	dir, err := cacheDir(opts.CacheDir)
+	if err != nil {
+		return nil, false, err
+	}
+	sum := sha256.Sum256([]byte(url))
+	base := filepath.Join(dir, hex.EncodeToString(sum[:16]))
+
+	if data, ok := readCache(base, url, opts.Now); ok {
+		return data, true, nil
+	}
+
+	client := opts.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to fetch metadata: %w", err)
+	}
+	req.Header.Set("Accept", "application/samlmetadata+xml, application/xml;q=0.9, */*;q=0.8")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to fetch metadata: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, false, fmt.Errorf("failed to fetch metadata: %s returned %s", url, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxMetadataSize+1))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to fetch metadata: %w", err)
+	}
+	if len(data) > maxMetadataSize {
+		return nil, false, fmt.Errorf("failed to fetch metadata: larger than %d MB", maxMetadataSize>>20)
+	}
+
+	// Only documents that parse are cached; validation happens in Load
+	if root, err := parseRoot(data); err == nil {
+		writeCache(base, url, data, opts.Now, cacheExpiry(root, opts.Now))
+	}
+	return data, false, nil
+}
+
+// cacheExpiry is when metadata fetched at now must be fetched again: after
+// its cacheDuration, but no later than its validUntil
+func cacheExpiry(root *etree.Element, now time.Time) time.Time {
+	validUntil, cacheDuration, err := saml.MetadataValidity(root)
+	if err != nil {
+		return now
+	}
+	if cacheDuration == 0 {
+		cacheDuration = DefaultCacheDuration
+	}
+	expires := now.Add(cacheDuration)
+	if validUntil != nil && validUntil.Before(expires) {
+		expires = *validUntil
+	}
+	return expires
+}
+
+func cacheDir(dir string) (string, error) {
+	if dir == "" {
+		userDir, err := os.UserCacheDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to locate cache directory: %w", err)
+		}
+		dir = filepath.Join(userDir, "samlurai", "metadata")
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	return dir, nil
+}
+
+func readCache(base, url string, now time.Time) ([]byte, bool) {
+	raw, err := os.ReadFile(base + ".json")
+	if err != nil {
+		return nil, false
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(raw, &entry); err != nil || entry.URL != url || !now.Before(entry.Expires) {
+		return nil, false
+	}
+	data, err := os.ReadFile(base + ".xml")
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// writeCache stores a fetched document. Failures are ignored, since the
+// cache only saves a download.
+func writeCache(base, url string, data []byte, fetchedAt, expires time.Time) {
+	entry, err := json.Marshal(cacheEntry{URL: url, FetchedAt: fetchedAt, Expires: expires})
+	if err != nil {
+		return
+	}
+	if err := os.WriteFile(base+".xml", data, 0600); err != nil {
+		return
+	}
+	_ = os.WriteFile(base+".json", entry, 0600)
+}
@@ -0,0 +1,181 @@
+package metadata
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/beevik/etree"
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var now = time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func entityDescriptor(attrs string) string {
+	return `<md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" ID="_md" entityID="https://idp.example.com"` + attrs + `><md:IDPSSODescriptor/></md:EntityDescriptor>`
+}
+
+// serve returns a server for body that counts its requests
+func serve(t *testing.T, body *string) (*httptest.Server, *int) {
+	t.Helper()
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_, _ = w.Write([]byte(*body))
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func signingKey(t *testing.T) (*rsa.PrivateKey, *x509.Certificate) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "federation.example.org"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return key, cert
+}
+
+func sign(t *testing.T, xml string, key *rsa.PrivateKey, cert *x509.Certificate) string {
+	t.Helper()
+	doc := etree.NewDocument()
+	require.NoError(t, doc.ReadFromString(xml))
+	require.NoError(t, saml.SignEnveloped(doc.Root(), key, cert, saml.SigAlgRSASHA256))
+	signed, err := doc.WriteToString()
+	require.NoError(t, err)
+	return signed
+}
+
+func TestIsURL(t *testing.T) {
+	assert.True(t, IsURL("https://mdq.example.org/entities"))
+	assert.True(t, IsURL("http://localhost:8080/metadata"))
+	assert.False(t, IsURL("idp-metadata.xml"))
+	assert.False(t, IsURL("/etc/samlurai/https.xml"))
+}
+
+func TestLoad_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metadata.xml")
+	require.NoError(t, os.WriteFile(path, []byte(entityDescriptor("")), 0600))
+
+	doc, err := Load(context.Background(), path, Options{Now: now})
+	require.NoError(t, err)
+	assert.Equal(t, entityDescriptor(""), string(doc.Data))
+	assert.False(t, doc.FromCache)
+	assert.False(t, doc.Signed)
+
+	_, err = Load(context.Background(), filepath.Join(t.TempDir(), "missing.xml"), Options{Now: now})
+	assert.ErrorContains(t, err, "failed to read metadata")
+}
+
+func TestLoad_URLCaching(t *testing.T) {
+	body := entityDescriptor(` cacheDuration="PT6H"`)
+	server, requests := serve(t, &body)
+	opts := Options{CacheDir: t.TempDir(), Now: now}
+
+	doc, err := Load(context.Background(), server.URL, opts)
+	require.NoError(t, err)
+	assert.False(t, doc.FromCache)
+
+	doc, err = Load(context.Background(), server.URL, opts)
+	require.NoError(t, err)
+	assert.True(t, doc.FromCache)
+	assert.Equal(t, body, string(doc.Data))
+	assert.Equal(t, 1, *requests)
+
+	// Refetched once the cacheDuration has passed
+	opts.Now = now.Add(6 * time.Hour)
+	doc, err = Load(context.Background(), server.URL, opts)
+	require.NoError(t, err)
+	assert.False(t, doc.FromCache)
+	assert.Equal(t, 2, *requests)
+}
+
+func TestLoad_CacheBoundedByValidUntil(t *testing.T) {
+	body := entityDescriptor(` cacheDuration="P7D" validUntil="` + now.Add(2*time.Hour).Format(time.RFC3339) + `"`)
+	server, requests := serve(t, &body)
+	opts := Options{CacheDir: t.TempDir(), Now: now}
+
+	_, err := Load(context.Background(), server.URL, opts)
+	require.NoError(t, err)
+
+	opts.Now = now.Add(time.Hour)
+	doc, err := Load(context.Background(), server.URL, opts)
+	require.NoError(t, err)
+	assert.True(t, doc.FromCache)
+	require.NotNil(t, doc.ValidUntil)
+
+	// Past validUntil the cached copy is dropped and the refetched one is
+	// still expired
+	opts.Now = now.Add(3 * time.Hour)
+	_, err = Load(context.Background(), server.URL, opts)
+	assert.EqualError(t, err, "metadata expired on "+now.Add(2*time.Hour).Format(time.RFC3339))
+	assert.Equal(t, 2, *requests)
+
+	opts.AllowExpired = true
+	doc, err = Load(context.Background(), server.URL, opts)
+	require.NoError(t, err)
+	assert.Equal(t, body, string(doc.Data))
+}
+
+func TestLoad_HTTPError(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	_, err := Load(context.Background(), server.URL, Options{CacheDir: t.TempDir(), Now: now})
+	assert.ErrorContains(t, err, "returned 404 Not Found")
+}
+
+func TestLoad_Signature(t *testing.T) {
+	key, cert := signingKey(t)
+	_, otherCert := signingKey(t)
+	signed := sign(t, entityDescriptor(""), key, cert)
+
+	body := signed
+	server, _ := serve(t, &body)
+	fetch := func(certs ...*x509.Certificate) (*Document, error) {
+		return Load(context.Background(), server.URL, Options{CacheDir: t.TempDir(), Now: now, SigningCerts: certs})
+	}
+
+	doc, err := fetch(cert)
+	require.NoError(t, err)
+	assert.True(t, doc.Signed)
+	assert.False(t, doc.SelfSigned)
+
+	// Without trusted certificates the KeyInfo certificate is used, which
+	// does not tell who signed
+	doc, err = fetch()
+	require.NoError(t, err)
+	assert.False(t, doc.Signed)
+	assert.True(t, doc.SelfSigned)
+
+	_, err = fetch(otherCert)
+	assert.ErrorContains(t, err, "invalid metadata signature")
+
+	body = strings.Replace(signed, "https://idp.example.com", "https://evil.example.com", 1)
+	_, err = fetch(cert)
+	assert.EqualError(t, err, "invalid metadata signature: digest mismatch: the signed content was modified")
+
+	body = entityDescriptor("")
+	_, err = fetch(cert)
+	assert.EqualError(t, err, "metadata is not signed")
+}
@@ -0,0 +1,58 @@
+package metadata
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/beevik/etree"
+	"github.com/gliwka/SAMLurai/internal/saml"
+)
+
+// SPInfo holds the endpoints of an SP's metadata
+type SPInfo struct {
+	EntityID string
+
+	// ACS are the AssertionConsumerService endpoints in document order,
+	// with bindings as URIs
+	ACS []Endpoint
+
+	// SLO are the SingleLogoutService endpoints in document order
+	SLO []Endpoint
+}
+
+// ParseSP reads the SPSSODescriptor of an SP's metadata. Aggregates need
+// entityID to select the entity.
+func ParseSP(data []byte, entityID string) (*SPInfo, error) {
+	entity, err := saml.SelectEntity(data, entityID)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := saml.ReadDocument(entity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse metadata: %w", err)
+	}
+	root := doc.Root()
+
+	var descriptor *etree.Element
+	for _, child := range root.ChildElements() {
+		if child.NamespaceURI() == saml.MetadataNamespace && child.Tag == "SPSSODescriptor" {
+			descriptor = child
+			break
+		}
+	}
+	if descriptor == nil {
+		return nil, errors.New("metadata has no SPSSODescriptor")
+	}
+
+	info := &SPInfo{EntityID: root.SelectAttrValue("entityID", "")}
+	for _, el := range descriptor.ChildElements() {
+		endpoint := Endpoint{URL: el.SelectAttrValue("Location", ""), Binding: el.SelectAttrValue("Binding", "")}
+		switch el.Tag {
+		case "AssertionConsumerService":
+			info.ACS = append(info.ACS, endpoint)
+		case "SingleLogoutService":
+			info.SLO = append(info.SLO, endpoint)
+		}
+	}
+	return info, nil
+}
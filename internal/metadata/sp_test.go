@@ -0,0 +1,30 @@
+package metadata
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSP(t *testing.T) {
+	md := `<md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" entityID="https://sp.example.com">
+  <md:SPSSODescriptor protocolSupportEnumeration="urn:oasis:names:tc:SAML:2.0:protocol">
+    <md:SingleLogoutService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect" Location="https://sp.example.com/slo"/>
+    <md:AssertionConsumerService index="0" Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST" Location="https://sp.example.com/acs"/>
+    <md:AssertionConsumerService index="1" Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Artifact" Location="https://sp.example.com/acs/artifact"/>
+  </md:SPSSODescriptor>
+</md:EntityDescriptor>`
+
+	info, err := ParseSP([]byte(md), "")
+	require.NoError(t, err)
+	assert.Equal(t, "https://sp.example.com", info.EntityID)
+	assert.Equal(t, []Endpoint{
+		{URL: "https://sp.example.com/acs", Binding: BindingHTTPPost},
+		{URL: "https://sp.example.com/acs/artifact", Binding: BindingHTTPArtifact},
+	}, info.ACS)
+	assert.Equal(t, []Endpoint{{URL: "https://sp.example.com/slo", Binding: BindingHTTPRedirect}}, info.SLO)
+
+	_, err = ParseSP([]byte(`<md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" entityID="https://idp.example.com"><md:IDPSSODescriptor/></md:EntityDescriptor>`), "")
+	assert.ErrorContains(t, err, "no SPSSODescriptor")
+}
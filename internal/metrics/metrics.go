@@ -0,0 +1,159 @@
+// Package metrics counts the SAML messages handled by the server modes and
+// exposes the counts for Prometheus, in the Prometheus text or the
+// OpenMetrics format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Path is where the server modes expose the metrics
+const Path = "/metrics"
+
+// Content types of the exposition formats
+const (
+	ContentTypeText        = "text/plain; version=0.0.4; charset=utf-8"
+	ContentTypeOpenMetrics = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+)
+
+// Metrics counts messages and failures. The zero value is not usable; a
+// nil *Metrics counts nothing, so servers can record unconditionally.
+type Metrics struct {
+	mu                 sync.Mutex
+	messages           map[string]uint64
+	parseFailures      uint64
+	signatureFailures  uint64
+	decryptionFailures uint64
+	expiredAssertions  uint64
+}
+
+// New creates metrics with all counts at zero
+func New() *Metrics {
+	return &Metrics{messages: map[string]uint64{}}
+}
+
+// Message counts a message of the given type, e.g. Response
+func (m *Metrics) Message(messageType string) {
+	if m == nil {
+		return
+	}
+	if messageType == "" {
+		messageType = "Unknown"
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages[messageType]++
+}
+
+// ParseFailure counts a message that could not be decoded or parsed
+func (m *Metrics) ParseFailure() {
+	m.add(func() { m.parseFailures++ })
+}
+
+// SignatureFailure counts a message whose signature did not verify
+func (m *Metrics) SignatureFailure() {
+	m.add(func() { m.signatureFailures++ })
+}
+
+// DecryptionFailure counts an encrypted assertion that could not be
+// decrypted, including for lack of a key
+func (m *Metrics) DecryptionFailure() {
+	m.add(func() { m.decryptionFailures++ })
+}
+
+// ExpiredAssertion counts a message received after its assertion expired
+func (m *Metrics) ExpiredAssertion() {
+	m.add(func() { m.expiredAssertions++ })
+}
+
+// add increments a counter while holding the lock
+func (m *Metrics) add(increment func()) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	increment()
+}
+
+// counter is a metric family in the exposition
+type counter struct {
+	name    string
+	help    string
+	label   string
+	samples map[string]uint64
+}
+
+// Write writes the metrics in the Prometheus text format, or in the
+// OpenMetrics format if openMetrics is set
+func (m *Metrics) Write(w io.Writer, openMetrics bool) error {
+	m.mu.Lock()
+	messages := make(map[string]uint64, len(m.messages))
+	for messageType, n := range m.messages {
+		messages[messageType] = n
+	}
+	counters := []counter{
+		{"samlurai_messages", "SAML messages seen, by type", "type", messages},
+		{"samlurai_parse_failures", "Messages that could not be decoded or parsed", "", map[string]uint64{"": m.parseFailures}},
+		{"samlurai_signature_failures", "Messages whose signature did not verify", "", map[string]uint64{"": m.signatureFailures}},
+		{"samlurai_decryption_failures", "Encrypted assertions that could not be decrypted", "", map[string]uint64{"": m.decryptionFailures}},
+		{"samlurai_expired_assertions", "Messages received after their assertion's NotOnOrAfter", "", map[string]uint64{"": m.expiredAssertions}},
+	}
+	m.mu.Unlock()
+
+	var b strings.Builder
+	for _, c := range counters {
+		// OpenMetrics names the family without the _total suffix of its
+		// samples; the Prometheus text format names the samples
+		family := c.name
+		if !openMetrics {
+			family += "_total"
+		}
+		fmt.Fprintf(&b, "# HELP %s %s.\n", family, c.help)
+		fmt.Fprintf(&b, "# TYPE %s counter\n", family)
+		keys := make([]string, 0, len(c.samples))
+		for key := range c.samples {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if c.label == "" {
+				fmt.Fprintf(&b, "%s_total %d\n", c.name, c.samples[key])
+			} else {
+				fmt.Fprintf(&b, "%s_total{%s=\"%s\"} %d\n", c.name, c.label, escapeLabel(key), c.samples[key])
+			}
+		}
+	}
+	if openMetrics {
+		b.WriteString("# EOF\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// ServeHTTP serves the metrics, in the OpenMetrics format if the scraper
+// accepts it
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	openMetrics := strings.Contains(r.Header.Get("Accept"), "application/openmetrics-text")
+	if openMetrics {
+		w.Header().Set("Content-Type", ContentTypeOpenMetrics)
+	} else {
+		w.Header().Set("Content-Type", ContentTypeText)
+	}
+	_ = m.Write(w, openMetrics)
+}
+
+// escapeLabel escapes a label value for the exposition formats
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
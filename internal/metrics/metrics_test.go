@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics_Write(t *testing.T) {
+	m := New()
+	m.Message("Response")
+	m.Message("Response")
+	m.Message("AuthnRequest")
+	m.Message("")
+	m.ParseFailure()
+	m.SignatureFailure()
+	m.DecryptionFailure()
+	m.DecryptionFailure()
+	m.ExpiredAssertion()
+
+	var buf bytes.Buffer
+	require.NoError(t, m.Write(&buf, false))
+	assert.Equal(t, `# HELP samlurai_messages_total SAML messages seen, by type.
+# TYPE samlurai_messages_total counter
+samlurai_messages_total{type="AuthnRequest"} 1
+samlurai_messages_total{type="Response"} 2
+samlurai_messages_total{type="Unknown"} 1
+# HELP samlurai_parse_failures_total Messages that could not be decoded or parsed.
+# TYPE samlurai_parse_failures_total counter
+samlurai_parse_failures_total 1
+# HELP samlurai_signature_failures_total Messages whose signature did not verify.
+# TYPE samlurai_signature_failures_total counter
+samlurai_signature_failures_total 1
+# HELP samlurai_decryption_failures_total Encrypted assertions that could not be decrypted.
+# TYPE samlurai_decryption_failures_total counter
+samlurai_decryption_failures_total 2
+# HELP samlurai_expired_assertions_total Messages received after their assertion's NotOnOrAfter.
+# TYPE samlurai_expired_assertions_total counter
+samlurai_expired_assertions_total 1
+`, buf.String())
+
+	buf.Reset()
+	require.NoError(t, m.Write(&buf, true))
+	assert.Contains(t, buf.String(), "# TYPE samlurai_messages counter\nsamlurai_messages_total{type=\"AuthnRequest\"} 1\n")
+	assert.True(t, bytes.HasSuffix(buf.Bytes(), []byte("samlurai_expired_assertions_total 1\n# EOF\n")))
+}
+
+func TestMetrics_Nil(t *testing.T) {
+	var m *Metrics
+	assert.NotPanics(t, func() {
+		m.Message("Response")
+		m.ParseFailure()
+		m.ExpiredAssertion()
+	})
+}
+
+func TestMetrics_ServeHTTP(t *testing.T) {
+	m := New()
+	m.Message(`Odd"Type`)
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, Path, nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, ContentTypeText, rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Body.String(), `samlurai_messages_total{type="Odd\"Type"} 1`)
+
+	req := httptest.NewRequest(http.MethodGet, Path, nil)
+	req.Header.Set("Accept", "application/openmetrics-text;version=1.0.0,text/plain;q=0.5")
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	assert.Equal(t, ContentTypeOpenMetrics, rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Body.String(), "# EOF\n")
+
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, Path, nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
@@ -15,8 +15,24 @@ import (
 
 // Formatter handles output formatting for different formats
 type Formatter struct {
-	format string
-	noColor bool
+	format    string
+	noColor   bool
+	highlight bool
+	refTime   time.Time
+	refLabel  string
+	skew      time.Duration
+	maxValue  int
+	warnings  []string
+}
+
+// attributeNames resolves attribute names without a FriendlyName, such as
+// OIDs, for pretty output
+var attributeNames = saml.NewAttributeDictionary()
+
+// SetAttributeDictionary sets the dictionary resolving attribute names in
+// pretty output. nil disables resolution.
+func SetAttributeDictionary(d *saml.AttributeDictionary) {
+	attributeNames = d
 }
 
 // NewFormatter creates a new formatter with the specified format
@@ -34,15 +50,33 @@ func NewFormatterWithOptions(format string, noColor bool) *Formatter {
 	}
 }
 
+// WithMaxValueLength truncates attribute values longer than n characters in
+// pretty output. Zero disables truncation.
+func (f *Formatter) WithMaxValueLength(n int) *Formatter {
+	f.maxValue = n
+	return f
+}
+
+// WithWarnings sets the validation warnings listed in pretty output, e.g.
+// Destination and Audience mismatches. nil omits the section.
+func (f *Formatter) WithWarnings(warnings []string) *Formatter {
+	f.warnings = warnings
+	return f
+}
+
 // FormatXML formats XML data according to the configured format
 func (f *Formatter) FormatXML(data []byte) (string, error) {
 	switch f.format {
-	case "json":
+	case "json", "psobject":
 		return f.xmlToJSON(data)
 	case "xml", "raw":
 		return f.prettyXML(data)
 	case "pretty":
-		return f.prettyXML(data)
+		formatted, err := f.prettyXML(data)
+		if err != nil || !f.highlight || f.noColor {
+			return formatted, err
+		}
+		return highlightXML(formatted), nil
 	default:
 		return f.prettyXML(data)
 	}
@@ -51,10 +85,14 @@ func (f *Formatter) FormatXML(data []byte) (string, error) {
 // FormatSAMLInfo formats SAMLInfo according to the configured format
 func (f *Formatter) FormatSAMLInfo(info *saml.SAMLInfo) (string, error) {
 	switch f.format {
-	case "json":
-		return f.toJSON(info)
+	case "json", "psobject":
+		return f.toJSON(versioned(info))
 	case "xml":
 		return f.toXML(info)
+	case "jsonl":
+		return f.FormatJSONL([]JSONLRecord{{Index: 1, Type: info.Type, Info: info}})
+	case "csv", "tsv":
+		return f.FormatAttributes([]MessageInfo{{Index: 1, Info: info}})
 	case "pretty":
 		return f.toPretty(info)
 	default:
@@ -62,27 +100,31 @@ func (f *Formatter) FormatSAMLInfo(info *saml.SAMLInfo) (string, error) {
 	}
 }
 
-func (f *Formatter) prettyXML(data []byte) (string, error) {
-	var buf bytes.Buffer
-	decoder := xml.NewDecoder(bytes.NewReader(data))
-	encoder := xml.NewEncoder(&buf)
-	encoder.Indent("", "  ")
+// FormatJSON formats an arbitrary value as indented JSON
+func (f *Formatter) FormatJSON(v interface{}) (string, error) {
+	return f.toJSON(v)
+}
 
-	for {
-		token, err := decoder.Token()
-		if err != nil {
-			break
-		}
-		if err := encoder.EncodeToken(token); err != nil {
-			return "", fmt.Errorf("failed to encode token: %w", err)
-		}
-	}
+// IsJSON reports whether the formatter produces a single JSON document,
+// i.e. the json or psobject format
+func (f *Formatter) IsJSON() bool {
+	return f.format == "json" || f.IsPSObject()
+}
 
-	if err := encoder.Flush(); err != nil {
-		return "", fmt.Errorf("failed to flush encoder: %w", err)
-	}
+// IsPSObject reports whether the formatter produces JSON for PowerShell's
+// ConvertFrom-Json: CRLF line endings and nothing but the JSON document
+func (f *Formatter) IsPSObject() bool {
+	return f.format == "psobject"
+}
 
-	return buf.String() + "\n", nil
+// prettyXML indents XML without otherwise changing it. Data that is not
+// well-formed XML, e.g. a truncated message, is returned as is.
+func (f *Formatter) prettyXML(data []byte) (string, error) {
+	indented, err := indentXML(data, "  ")
+	if err != nil {
+		return strings.TrimRight(string(data), "\n") + "\n", nil
+	}
+	return indented, nil
 }
 
 func (f *Formatter) xmlToJSON(data []byte) (string, error) {
@@ -93,7 +135,7 @@ func (f *Formatter) xmlToJSON(data []byte) (string, error) {
 		// If it fails, return a simple structure
 		return f.toJSON(map[string]string{"raw_xml": string(data)})
 	}
-	return f.toJSON(info)
+	return f.toJSON(versioned(info))
 }
 
 func (f *Formatter) toJSON(v interface{}) (string, error) {
@@ -101,6 +143,10 @@ func (f *Formatter) toJSON(v interface{}) (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("failed to marshal JSON: %w", err)
 	}
+	if f.IsPSObject() {
+		// Newlines inside strings are escaped, so this only touches layout
+		return strings.ReplaceAll(string(data), "\n", "\r\n") + "\r\n", nil
+	}
 	return string(data) + "\n", nil
 }
 
@@ -123,8 +169,12 @@ func (f *Formatter) toPretty(info *saml.SAMLInfo) (string, error) {
 	successColor := color.New(color.FgGreen)
 	warnColor := color.New(color.FgRed)
 
+	// Disable per color rather than via the color.NoColor global, so
+	// concurrent formatters don't affect each other
 	if f.noColor {
-		color.NoColor = true
+		for _, c := range []*color.Color{headerColor, labelColor, valueColor, successColor, warnColor} {
+			c.DisableColor()
+		}
 	}
 
 	// Header
@@ -155,12 +205,66 @@ func (f *Formatter) toPretty(info *saml.SAMLInfo) (string, error) {
 			statusColor = warnColor
 		}
 		statusColor.Fprintf(w, "  Status Code:\t%s\n", info.Status.StatusCode)
+		if info.Status.SubStatusCode != "" {
+			statusColor.Fprintf(w, "  Sub Status:\t%s\n", info.Status.SubStatusCode)
+		}
 		if info.Status.StatusMessage != "" {
 			f.printField(w, labelColor, valueColor, "Message", info.Status.StatusMessage)
 		}
 		fmt.Fprintln(w)
 	}
 
+	// Likely causes of a failure, with what to do next
+	if len(info.PossibleCauses) > 0 {
+		f.printSection(w, headerColor, "Possible Causes")
+		for _, cause := range info.PossibleCauses {
+			warnColor.Fprintf(w, "  • %s\n", cause.Summary)
+			valueColor.Fprintf(w, "    → %s\n", cause.NextStep)
+		}
+		fmt.Fprintln(w)
+	}
+
+	if len(f.warnings) > 0 {
+		f.printSection(w, headerColor, "Validation Warnings")
+		for _, warning := range f.warnings {
+			warnColor.Fprintf(w, "  ⚠ %s\n", warning)
+		}
+		fmt.Fprintln(w)
+	}
+
+	// WS-Trust exchange
+	if wst := info.WSTrust; wst != nil {
+		f.printSection(w, headerColor, "WS-Trust")
+		f.printField(w, labelColor, valueColor, "Version", wst.Version)
+		if wst.Context != "" {
+			f.printField(w, labelColor, valueColor, "Context", wst.Context)
+		}
+		if wst.RequestType != "" {
+			f.printField(w, labelColor, valueColor, "Request Type", shortenWSTrustURI(wst.RequestType))
+		}
+		if wst.TokenType != "" {
+			f.printField(w, labelColor, valueColor, "Token Type", shortenWSTrustURI(wst.TokenType))
+		}
+		if wst.KeyType != "" {
+			f.printField(w, labelColor, valueColor, "Key Type", shortenWSTrustURI(wst.KeyType))
+		}
+		if wst.AppliesTo != "" {
+			f.printField(w, labelColor, valueColor, "Applies To", wst.AppliesTo)
+		}
+		if wst.Created != nil {
+			f.printField(w, labelColor, valueColor, "Created", wst.Created.Format(time.RFC3339))
+		}
+		if wst.Expires != nil {
+			expiresColor := valueColor
+			if ref, _ := f.referenceTime(); !ref.Before(*wst.Expires) {
+				expiresColor = warnColor
+			}
+			labelColor.Fprintf(w, "  %s:\t", "Expires")
+			expiresColor.Fprintf(w, "%s\n", wst.Expires.Format(time.RFC3339))
+		}
+		fmt.Fprintln(w)
+	}
+
 	// AuthnRequest-specific fields
 	if info.AssertionConsumerServiceURL != "" {
 		f.printSection(w, headerColor, "Request Details")
@@ -192,11 +296,47 @@ func (f *Formatter) toPretty(info *saml.SAMLInfo) (string, error) {
 		fmt.Fprintln(w)
 	}
 
+	// Requested AuthnContext (for AuthnRequest)
+	if rac := info.RequestedAuthnContext; rac != nil {
+		f.printSection(w, headerColor, "Requested AuthnContext")
+		f.printField(w, labelColor, valueColor, "Comparison", rac.EffectiveComparison())
+		for _, ref := range rac.ClassRefs {
+			f.printField(w, labelColor, valueColor, "Class Ref", f.shortenURI(ref))
+		}
+		for _, ref := range rac.DeclRefs {
+			f.printField(w, labelColor, valueColor, "Decl Ref", f.shortenURI(ref))
+		}
+		fmt.Fprintln(w)
+	}
+
+	// Scoping (for AuthnRequest)
+	if scoping := info.Scoping; scoping != nil {
+		f.printSection(w, headerColor, "Scoping")
+		f.printField(w, labelColor, valueColor, "Proxy Count", proxyCount(scoping.ProxyCount))
+		for _, entry := range scoping.IDPList {
+			idp := entry.ProviderID
+			if entry.Name != "" {
+				idp += " (" + entry.Name + ")"
+			}
+			f.printField(w, labelColor, valueColor, "IdP", idp)
+		}
+		if scoping.GetComplete != "" {
+			f.printField(w, labelColor, valueColor, "Get Complete", scoping.GetComplete)
+		}
+		for _, requester := range scoping.RequesterIDs {
+			f.printField(w, labelColor, valueColor, "Requester ID", requester)
+		}
+		fmt.Fprintln(w)
+	}
+
 	// Requested Attributes (for AuthnRequest)
 	if len(info.RequestedAttributes) > 0 {
 		f.printSection(w, headerColor, "Requested Attributes")
 		for _, attr := range info.RequestedAttributes {
 			name := attr.FriendlyName
+			if name == "" {
+				name = attributeNames.FriendlyName(attr.Name)
+			}
 			if name == "" {
 				name = f.shortenURI(attr.Name)
 			}
@@ -219,6 +359,9 @@ func (f *Formatter) toPretty(info *saml.SAMLInfo) (string, error) {
 		if info.Subject.SPNameQualifier != "" {
 			f.printField(w, labelColor, valueColor, "SP Name Qualifier", info.Subject.SPNameQualifier)
 		}
+		if info.Subject.Recipient != "" {
+			f.printField(w, labelColor, valueColor, "Recipient", info.Subject.Recipient)
+		}
 		fmt.Fprintln(w)
 	}
 
@@ -234,6 +377,44 @@ func (f *Formatter) toPretty(info *saml.SAMLInfo) (string, error) {
 		if len(info.Conditions.AudienceRestriction) > 0 {
 			f.printField(w, labelColor, valueColor, "Audiences", strings.Join(info.Conditions.AudienceRestriction, ", "))
 		}
+		for _, cond := range info.Conditions.Other {
+			switch cond := cond.(type) {
+			case *saml.OneTimeUse:
+				f.printField(w, labelColor, valueColor, "One Time Use", "true")
+			case *saml.ProxyRestriction:
+				f.printField(w, labelColor, valueColor, "Proxy Count", proxyCount(cond.Count))
+				if len(cond.Audiences) > 0 {
+					f.printField(w, labelColor, valueColor, "Proxy Audiences", strings.Join(cond.Audiences, ", "))
+				}
+			case *saml.DelegationRestriction:
+				for _, d := range cond.Delegates {
+					delegate := d.NameID
+					if d.ConfirmationMethod != "" {
+						delegate += " via " + f.shortenURI(d.ConfirmationMethod)
+					}
+					if d.DelegationInstant != nil {
+						delegate += " at " + d.DelegationInstant.Format(time.RFC3339)
+					}
+					f.printField(w, labelColor, valueColor, "Delegate", delegate)
+				}
+			default:
+				warnColor.Fprintf(w, "  Condition:\t%s (not understood)\n", cond.ConditionType())
+			}
+		}
+		ref, refLabel := f.referenceTime()
+		if bar, legend, valid, ok := lifetimeBar(info, ref, refLabel, f.skew); ok {
+			barColor := successColor
+			if !valid {
+				barColor = warnColor
+			}
+			labelColor.Fprintf(w, "  %s:\t", "Lifetime")
+			barColor.Fprintf(w, "%s\n", bar)
+			valueColor.Fprintf(w, "  \t%s\n", legend)
+		}
+		if info.Conditions.NotBefore != nil || info.Conditions.NotOnOrAfter != nil {
+			text, warn := validity(info.Conditions.NotBefore, info.Conditions.NotOnOrAfter, ref, refLabel, f.skew)
+			f.printTimeField(w, labelColor, successColor, warnColor, "Validity", text, warn)
+		}
 		fmt.Fprintln(w)
 	}
 
@@ -246,6 +427,11 @@ func (f *Formatter) toPretty(info *saml.SAMLInfo) (string, error) {
 		if info.AuthnStatement.SessionIndex != "" {
 			f.printField(w, labelColor, valueColor, "Session Index", info.AuthnStatement.SessionIndex)
 		}
+		if end := info.AuthnStatement.SessionNotOnOrAfter; end != nil {
+			ref, refLabel := f.referenceTime()
+			text, warn := validity(nil, end, ref, refLabel, f.skew)
+			f.printTimeField(w, labelColor, valueColor, warnColor, "Session Ends", end.Format(time.RFC3339)+" ("+text+")", warn)
+		}
 		if info.AuthnStatement.AuthnContextClassRef != "" {
 			f.printField(w, labelColor, valueColor, "Auth Context", f.shortenURI(info.AuthnStatement.AuthnContextClassRef))
 		}
@@ -257,10 +443,18 @@ func (f *Formatter) toPretty(info *saml.SAMLInfo) (string, error) {
 		f.printSection(w, headerColor, "Attributes")
 		for _, attr := range info.Attributes {
 			name := attr.Name
-			if attr.FriendlyName != "" {
-				name = attr.FriendlyName + " (" + f.shortenURI(attr.Name) + ")"
+			friendly := attr.FriendlyName
+			if friendly == "" {
+				friendly = attributeNames.FriendlyName(attr.Name)
+			}
+			if friendly != "" {
+				name = friendly + " (" + f.shortenURI(attr.Name) + ")"
 			}
-			f.printField(w, labelColor, valueColor, name, strings.Join(attr.Values, ", "))
+			values := make([]string, len(attr.Values))
+			for i, v := range attr.Values {
+				values[i] = truncateValue(v, f.maxValue)
+			}
+			f.printField(w, labelColor, valueColor, name, strings.Join(values, ", "))
 		}
 		fmt.Fprintln(w)
 	}
@@ -285,10 +479,81 @@ func (f *Formatter) toPretty(info *saml.SAMLInfo) (string, error) {
 			f.printField(w, labelColor, valueColor, "Cert Issuer", info.Signature.CertificateInfo.Issuer)
 			f.printField(w, labelColor, valueColor, "Cert Valid From", info.Signature.CertificateInfo.NotBefore.Format(time.RFC3339))
 			f.printField(w, labelColor, valueColor, "Cert Valid Until", info.Signature.CertificateInfo.NotAfter.Format(time.RFC3339))
+			cert := info.Signature.CertificateInfo
+			ref, refLabel := f.referenceTime()
+			// NotAfter is the last valid instant of a certificate
+			notOnOrAfter := cert.NotAfter.Add(time.Second)
+			if text, warn := validity(&cert.NotBefore, &notOnOrAfter, ref, refLabel, f.skew); warn {
+				f.printTimeField(w, labelColor, valueColor, warnColor, "Cert Validity", text, warn)
+			}
+			if info.Signature.CertificateInfo.SHA256Fingerprint != "" {
+				f.printField(w, labelColor, valueColor, "Cert SHA-256", info.Signature.CertificateInfo.SHA256Fingerprint)
+			}
+			if n := len(info.Signature.Certificates); n > 1 {
+				f.printField(w, labelColor, valueColor, "Chain", fmt.Sprintf("%d certificates (see samlurai certs)", n))
+			}
+		}
+		fmt.Fprintln(w)
+	}
+
+	// Encryption of an assertion that was not decrypted
+	if enc := info.Encryption; enc != nil {
+		f.printSection(w, headerColor, "Encryption")
+		if enc.DataEncryption != "" {
+			f.printField(w, labelColor, valueColor, "Data Encryption", f.shortenURI(enc.DataEncryption))
+		}
+		if enc.KeyTransport != "" {
+			f.printField(w, labelColor, valueColor, "Key Transport", f.shortenURI(enc.KeyTransport))
+		}
+		if enc.DigestMethod != "" {
+			f.printField(w, labelColor, valueColor, "OAEP Digest", f.shortenURI(enc.DigestMethod))
+		}
+		if enc.MGF != "" {
+			f.printField(w, labelColor, valueColor, "OAEP MGF", f.shortenURI(enc.MGF))
+		}
+		if enc.Recipient != "" {
+			f.printField(w, labelColor, valueColor, "Recipient", enc.Recipient)
+		}
+		if enc.KeyName != "" {
+			f.printField(w, labelColor, valueColor, "Key Name", enc.KeyName)
+		}
+		if cert := enc.Certificate; cert != nil {
+			f.printField(w, labelColor, valueColor, "Cert Subject", cert.Subject)
+			f.printField(w, labelColor, valueColor, "Cert Valid Until", cert.NotAfter.Format(time.RFC3339))
+			f.printField(w, labelColor, valueColor, "Cert SHA-256", cert.SHA256Fingerprint)
+		}
+		for _, weakness := range enc.Weaknesses() {
+			warnColor.Fprintf(w, "  ⚠️  %s\n", weakness)
+		}
+		fmt.Fprintln(w)
+	}
+
+	// IdP product
+	if v := info.Vendor; v != nil {
+		f.printSection(w, headerColor, "IdP Vendor")
+		f.printField(w, labelColor, valueColor, "Product", fmt.Sprintf("%s (%s confidence)", v.Vendor, v.Confidence))
+		for i, evidence := range v.Evidence {
+			label := ""
+			if i == 0 {
+				label = "Evidence:"
+			}
+			labelColor.Fprintf(w, "  %s\t", label)
+			valueColor.Fprintf(w, "%s\n", evidence)
+		}
+		for _, hint := range v.Hints {
+			fmt.Fprintf(w, "  💡 %s\n", hint)
 		}
 		fmt.Fprintln(w)
 	}
 
+	// Original XML, written past the tabwriter so it stays byte for byte
+	if info.RawXML != "" {
+		f.printSection(w, headerColor, "Raw XML")
+		w.Flush()
+		buf.WriteString(info.RawXML)
+		buf.WriteString("\n\n")
+	}
+
 	// Nested Assertion
 	if info.Assertion != nil {
 		headerColor.Fprintf(w, "───────────────────────────────────────────────────────────────\n")
@@ -299,6 +564,16 @@ func (f *Formatter) toPretty(info *saml.SAMLInfo) (string, error) {
 		fmt.Fprint(w, nested)
 	}
 
+	// Tokens embedded in attribute values, indented to show the nesting
+	for _, token := range info.EmbeddedTokens {
+		headerColor.Fprintf(w, "───────────────────────────────────────────────────────────────\n")
+		headerColor.Fprintf(w, " Embedded %s (from attribute %s)\n", token.Info.Type, f.shortenURI(token.Attribute))
+		headerColor.Fprintf(w, "───────────────────────────────────────────────────────────────\n")
+
+		nested, _ := f.toPretty(token.Info)
+		fmt.Fprint(w, indentLines(nested, "│ "))
+	}
+
 	w.Flush()
 	return buf.String(), nil
 }
@@ -312,7 +587,65 @@ func (f *Formatter) printField(w *tabwriter.Writer, labelColor, valueColor *colo
 	valueColor.Fprintf(w, "%s\n", value)
 }
 
+// printTimeField prints a validity field in warnColor if warn is set
+func (f *Formatter) printTimeField(w *tabwriter.Writer, labelColor, okColor, warnColor *color.Color, label, value string, warn bool) {
+	if warn {
+		okColor = warnColor
+	}
+	f.printField(w, labelColor, okColor, label, value)
+}
+
+// indentLines prefixes every line of s, without trailing spaces on empty lines
+func indentLines(s, prefix string) string {
+	lines := strings.SplitAfter(s, "\n")
+	for i, line := range lines {
+		switch {
+		case line == "":
+		case strings.TrimSpace(line) == "":
+			lines[i] = strings.TrimRight(prefix, " ") + line
+		default:
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "")
+}
+
+// truncateValue shortens value to max characters, noting how many were cut
+func truncateValue(value string, max int) string {
+	runes := []rune(value)
+	if max <= 0 || len(runes) <= max {
+		return value
+	}
+	return fmt.Sprintf("%s… (%d more chars)", string(runes[:max]), len(runes)-max)
+}
+
+// proxyCount describes a ProxyCount or ProxyRestriction Count, where nil
+// means no limit and 0 forbids proxying
+func proxyCount(count *int) string {
+	switch {
+	case count == nil:
+		return "unlimited"
+	case *count == 0:
+		return "0 (no proxying)"
+	}
+	return fmt.Sprintf("%d", *count)
+}
+
+// shortenWSTrustURI reduces a WS-Trust or token profile URI to its last
+// segment, e.g. ".../trust/200512/Issue" to "Issue"
+func shortenWSTrustURI(uri string) string {
+	if i := strings.LastIndexAny(uri, "/#"); i >= 0 && i < len(uri)-1 {
+		return uri[i+1:]
+	}
+	return uri
+}
+
 func (f *Formatter) shortenURI(uri string) string {
+	return shortenURI(uri)
+}
+
+// shortenURI strips well-known SAML and XML-DSig prefixes from a URI
+func shortenURI(uri string) string {
 	// Shorten common SAML URIs for readability
 	replacements := map[string]string{
 		"urn:oasis:names:tc:SAML:2.0:nameid-format:": "",
@@ -321,6 +654,7 @@ func (f *Formatter) shortenURI(uri string) string {
 		"http://www.w3.org/2001/04/xmldsig-more#":    "",
 		"http://www.w3.org/2000/09/xmldsig#":         "",
 		"http://www.w3.org/2001/04/xmlenc#":          "",
+		"http://www.w3.org/2009/xmlenc11#":           "",
 	}
 
 	for prefix, replacement := range replacements {
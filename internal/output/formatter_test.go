@@ -295,3 +295,162 @@ func TestFormatter_MultipleAttributeValues(t *testing.T) {
 	assert.True(t, strings.Contains(result, "admin, developer, user") ||
 		strings.Contains(result, "admin") && strings.Contains(result, "developer"))
 }
+
+func TestFormatter_FormatJSONL(t *testing.T) {
+	records := []JSONLRecord{
+		{Index: 1, Type: "AuthnRequest", Source: "query"},
+		{Index: 2, Type: "Response", Info: &saml.SAMLInfo{Type: "Response", Issuer: "https://idp.example.com"}},
+		{Index: 3, Type: "Response", Error: "failed to parse: EOF"},
+	}
+
+	result, err := NewFormatter("jsonl").FormatJSONL(records)
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSuffix(result, "\n"), "\n")
+	require.Len(t, lines, 3)
+	assert.Equal(t, `{"schema_version":"`+SchemaVersion+`","index":1,"type":"AuthnRequest","source":"query"}`, lines[0])
+	assert.Contains(t, lines[1], `"issuer":"https://idp.example.com"`)
+	assert.Equal(t, `{"schema_version":"`+SchemaVersion+`","index":3,"type":"Response","error":"failed to parse: EOF"}`, lines[2])
+}
+
+func TestFormatter_MaxValueLength(t *testing.T) {
+	info := &saml.SAMLInfo{
+		Type: "Assertion",
+		Attributes: []saml.Attribute{
+			{Name: "blob", Values: []string{strings.Repeat("x", 30), "short"}},
+		},
+	}
+
+	result, err := NewFormatterWithOptions("pretty", true).WithMaxValueLength(10).FormatSAMLInfo(info)
+	require.NoError(t, err)
+	assert.Contains(t, result, "xxxxxxxxxx… (20 more chars), short")
+
+	result, err = NewFormatterWithOptions("pretty", true).FormatSAMLInfo(info)
+	require.NoError(t, err)
+	assert.Contains(t, result, strings.Repeat("x", 30))
+}
+
+func TestFormatter_EmbeddedTokens(t *testing.T) {
+	info := &saml.SAMLInfo{
+		Type:   "Assertion",
+		Issuer: "https://broker.example.com",
+		EmbeddedTokens: []saml.EmbeddedToken{
+			{Attribute: "upstreamToken", Info: &saml.SAMLInfo{Type: "Assertion", Issuer: "https://upstream.example.com"}},
+		},
+	}
+
+	result, err := NewFormatterWithOptions("pretty", true).FormatSAMLInfo(info)
+	require.NoError(t, err)
+
+	assert.Contains(t, result, " Embedded Assertion (from attribute upstreamToken)")
+	assert.Contains(t, result, "│ ▸ Basic Information\n│   ID:      \n│   Issuer:  https://upstream.example.com\n│\n")
+}
+
+func TestFormatter_WSTrust(t *testing.T) {
+	created := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	expires := created.Add(time.Hour)
+	info := &saml.SAMLInfo{
+		Type: "RequestSecurityTokenResponse",
+		WSTrust: &saml.WSTrustInfo{
+			Version:     "1.3",
+			RequestType: "http://docs.oasis-open.org/ws-sx/ws-trust/200512/Issue",
+			TokenType:   "urn:oasis:names:tc:SAML:2.0:assertion",
+			AppliesTo:   "https://rp.example.com/",
+			Created:     &created,
+			Expires:     &expires,
+		},
+		Assertion: &saml.SAMLInfo{Type: "Assertion", ID: "_a1"},
+	}
+
+	result, err := NewFormatterWithOptions("pretty", true).FormatSAMLInfo(info)
+	require.NoError(t, err)
+
+	assert.Contains(t, result, "▸ WS-Trust")
+	assert.Contains(t, result, "Request Type:  Issue")
+	assert.Contains(t, result, "Token Type:    urn:oasis:names:tc:SAML:2.0:assertion")
+	assert.Contains(t, result, "Applies To:    https://rp.example.com/")
+	assert.Contains(t, result, "Expires:       2024-01-01T11:00:00Z")
+	assert.Contains(t, result, "Embedded Assertion")
+}
+
+func TestFormatter_PSObject(t *testing.T) {
+	formatter := NewFormatter("psobject")
+	assert.True(t, formatter.IsJSON())
+	assert.True(t, formatter.IsPSObject())
+	assert.False(t, NewFormatter("json").IsPSObject())
+
+	result, err := formatter.FormatJSON(map[string]string{"value": "line1\nline2"})
+	require.NoError(t, err)
+	assert.Equal(t, "{\r\n  \"value\": \"line1\\nline2\"\r\n}\r\n", result)
+
+	info, err := formatter.FormatSAMLInfo(createTestSAMLInfo())
+	require.NoError(t, err)
+	assert.True(t, json.Valid([]byte(info)))
+	assert.NotContains(t, info, "\x1b[")
+	assert.Equal(t, strings.Count(info, "\n"), strings.Count(info, "\r\n"))
+}
+
+func TestFormatter_ResolveAttributeNames(t *testing.T) {
+	defer SetAttributeDictionary(saml.NewAttributeDictionary())
+
+	info := &saml.SAMLInfo{
+		Type: "Assertion",
+		Attributes: []saml.Attribute{
+			{Name: "urn:oid:0.9.2342.19200300.100.1.3", Values: []string{"alice@example.com"}},
+			{Name: "urn:oid:2.5.4.42", FriendlyName: "firstName", Values: []string{"Alice"}},
+			{Name: "urn:oid:1.2.3.4", Values: []string{"x"}},
+		},
+		RequestedAttributes: []saml.RequestedAttribute{
+			{Name: "http://schemas.xmlsoap.org/ws/2005/05/identity/claims/upn"},
+		},
+	}
+
+	formatter := NewFormatterWithOptions("pretty", true)
+	result, err := formatter.FormatSAMLInfo(info)
+	require.NoError(t, err)
+	assert.Contains(t, result, "mail (urn:oid:0.9.2342.19200300.100.1.3)")
+	assert.Contains(t, result, "firstName (urn:oid:2.5.4.42)")
+	assert.Contains(t, result, "urn:oid:1.2.3.4")
+	assert.Contains(t, result, "upn")
+
+	SetAttributeDictionary(nil)
+	result, err = formatter.FormatSAMLInfo(info)
+	require.NoError(t, err)
+	assert.NotContains(t, result, "mail (")
+	assert.Contains(t, result, "firstName (urn:oid:2.5.4.42)")
+}
+
+func TestFormatter_ScopingAndProxyRestriction(t *testing.T) {
+	one, zero := 1, 0
+	request := &saml.SAMLInfo{
+		Type: "AuthnRequest",
+		Scoping: &saml.Scoping{
+			ProxyCount:   &one,
+			IDPList:      []saml.IDPEntry{{ProviderID: "http://adfs.example.com/adfs/services/trust", Name: "Corporate AD FS"}},
+			RequesterIDs: []string{"https://app.example.com"},
+		},
+	}
+	result, err := NewFormatterWithOptions("pretty", true).FormatSAMLInfo(request)
+	require.NoError(t, err)
+	assert.Contains(t, result, "Scoping")
+	assert.Regexp(t, `Proxy Count:\s+1\n`, result)
+	assert.Contains(t, result, "http://adfs.example.com/adfs/services/trust (Corporate AD FS)")
+	assert.Regexp(t, `Requester ID:\s+https://app.example.com`, result)
+
+	assertion := &saml.SAMLInfo{
+		Type: "Assertion",
+		Conditions: &saml.Conditions{
+			Other: saml.ConditionList{
+				&saml.OneTimeUse{},
+				&saml.ProxyRestriction{Count: &zero, Audiences: []string{"https://app.example.com"}},
+				&saml.UnknownCondition{Name: "Condition", XSIType: "ext:CustomType"},
+			},
+		},
+	}
+	result, err = NewFormatterWithOptions("pretty", true).FormatSAMLInfo(assertion)
+	require.NoError(t, err)
+	assert.Regexp(t, `One Time Use:\s+true`, result)
+	assert.Regexp(t, `Proxy Count:\s+0 \(no proxying\)`, result)
+	assert.Regexp(t, `Proxy Audiences:\s+https://app.example.com`, result)
+	assert.Regexp(t, `Condition:\s+ext:CustomType \(not understood\)`, result)
+}
@@ -0,0 +1,127 @@
+package output
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// WithSyntaxHighlight enables colored XML in pretty output. Highlighting is
+// skipped when the formatter was created with noColor, so callers can pass
+// the result of a TTY check there.
+func (f *Formatter) WithSyntaxHighlight(enabled bool) *Formatter {
+	f.highlight = enabled
+	return f
+}
+
+// xmlAttribute matches a single attribute inside a tag
+var xmlAttribute = regexp.MustCompile(`([^\s=]+)(\s*=\s*)("[^"]*"|'[^']*')`)
+
+// xmlColors holds the colors used by highlightXML
+type xmlColors struct {
+	element   *color.Color
+	attribute *color.Color
+	value     *color.Color
+	comment   *color.Color
+}
+
+func newXMLColors() xmlColors {
+	c := xmlColors{
+		element:   color.New(color.FgCyan),
+		attribute: color.New(color.FgYellow),
+		value:     color.New(color.FgGreen),
+		comment:   color.New(color.FgHiBlack),
+	}
+	// The caller decided colors are wanted, regardless of the color.NoColor
+	// global that is derived from os.Stdout
+	for _, col := range []*color.Color{c.element, c.attribute, c.value, c.comment} {
+		col.EnableColor()
+	}
+	return c
+}
+
+// highlightXML colors element names, attribute names and attribute values
+// of already formatted XML. Text content is left unchanged.
+func highlightXML(data string) string {
+	colors := newXMLColors()
+
+	var buf strings.Builder
+	for len(data) > 0 {
+		start := strings.IndexByte(data, '<')
+		if start < 0 {
+			buf.WriteString(data)
+			break
+		}
+		buf.WriteString(data[:start])
+		data = data[start:]
+
+		end := tagEnd(data)
+		if end < 0 {
+			buf.WriteString(data)
+			break
+		}
+		buf.WriteString(highlightTag(data[:end+1], colors))
+		data = data[end+1:]
+	}
+
+	return buf.String()
+}
+
+// tagEnd returns the index of the '>' closing the tag at the start of s,
+// skipping over quoted attribute values, or -1 if the tag is not closed
+func tagEnd(s string) int {
+	if strings.HasPrefix(s, "<!--") {
+		if i := strings.Index(s, "-->"); i >= 0 {
+			return i + 2
+		}
+		return -1
+	}
+
+	var quote byte
+	for i := 1; i < len(s); i++ {
+		switch {
+		case quote != 0:
+			if s[i] == quote {
+				quote = 0
+			}
+		case s[i] == '"' || s[i] == '\'':
+			quote = s[i]
+		case s[i] == '>':
+			return i
+		}
+	}
+	return -1
+}
+
+// highlightTag colors a single tag, comment or processing instruction
+func highlightTag(tag string, colors xmlColors) string {
+	if strings.HasPrefix(tag, "<!--") || strings.HasPrefix(tag, "<?") || strings.HasPrefix(tag, "<!") {
+		return colors.comment.Sprint(tag)
+	}
+
+	open := "<"
+	if strings.HasPrefix(tag, "</") {
+		open = "</"
+	}
+	body := strings.TrimPrefix(tag, open)
+
+	closing := ">"
+	if strings.HasSuffix(body, "/>") {
+		closing = "/>"
+	}
+	body = strings.TrimSuffix(body, closing)
+
+	nameEnd := strings.IndexAny(body, " \t\r\n")
+	if nameEnd < 0 {
+		nameEnd = len(body)
+	}
+	name, attrs := body[:nameEnd], body[nameEnd:]
+
+	attrs = xmlAttribute.ReplaceAllStringFunc(attrs, func(attr string) string {
+		m := xmlAttribute.FindStringSubmatch(attr)
+		return colors.attribute.Sprint(m[1]) + m[2] + colors.value.Sprint(m[3])
+	})
+
+	return colors.element.Sprint(open+name) + attrs + colors.element.Sprint(closing)
+}
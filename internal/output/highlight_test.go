@@ -0,0 +1,46 @@
+package output
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var ansiEscape = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+func TestFormatter_FormatXML_Highlight(t *testing.T) {
+	input := []byte(`<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_a>b"><Issuer>https://idp.example.com</Issuer><!-- note --><Empty/></samlp:Response>`)
+
+	plain, err := NewFormatter("pretty").FormatXML(input)
+	require.NoError(t, err)
+
+	t.Run("enabled", func(t *testing.T) {
+		result, err := NewFormatter("pretty").WithSyntaxHighlight(true).FormatXML(input)
+		require.NoError(t, err)
+
+		assert.NotEqual(t, plain, result)
+		assert.Contains(t, result, "\x1b[36m<Issuer\x1b[0m")
+		assert.Contains(t, result, "\x1b[33mID\x1b[0m=\x1b[32m\"_a>b\"\x1b[0m")
+		assert.Equal(t, plain, ansiEscape.ReplaceAllString(result, ""), "highlighting must only add escape codes")
+	})
+
+	t.Run("noColor", func(t *testing.T) {
+		result, err := NewFormatterWithOptions("pretty", true).WithSyntaxHighlight(true).FormatXML(input)
+		require.NoError(t, err)
+		assert.Equal(t, plain, result)
+	})
+
+	t.Run("xml format stays plain", func(t *testing.T) {
+		result, err := NewFormatter("xml").WithSyntaxHighlight(true).FormatXML(input)
+		require.NoError(t, err)
+		assert.NotContains(t, result, "\x1b[")
+	})
+}
+
+func TestHighlightXML_QuotedBrackets(t *testing.T) {
+	result := highlightXML(`<a title='x > y'>text</a>`)
+	assert.Equal(t, `<a title='x > y'>text</a>`, ansiEscape.ReplaceAllString(result, ""))
+	assert.Contains(t, result, "\x1b[32m'x > y'\x1b[0m")
+}
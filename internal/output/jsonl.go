@@ -0,0 +1,50 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gliwka/SAMLurai/internal/saml"
+)
+
+// JSONLRecord is a single line of JSONL output, describing one SAML message
+type JSONLRecord struct {
+	SchemaVersion string         `json:"schema_version"`
+	File          string         `json:"file,omitempty"`
+	Index         int            `json:"index"`
+	Type          string         `json:"type"`
+	Source        string         `json:"source,omitempty"`
+	URL           string         `json:"url,omitempty"`
+	ParameterName string         `json:"parameter_name,omitempty"`
+	StartedAt     *time.Time     `json:"started_at,omitempty"`
+	InResponseTo  int            `json:"in_response_to_index,omitempty"`
+	Info          *saml.SAMLInfo `json:"info,omitempty"`
+	Warnings      []string       `json:"warnings,omitempty"`
+	Error         string         `json:"error,omitempty"`
+
+	// Set for the messages of an extract bundle
+	Confidence float64 `json:"confidence,omitempty"`
+	Signature  string  `json:"signature,omitempty"`
+	XML        string  `json:"xml,omitempty"`
+}
+
+// IsJSONL reports whether the formatter produces one JSON object per line
+func (f *Formatter) IsJSONL() bool {
+	return f.format == "jsonl"
+}
+
+// FormatJSONL renders each record as compact JSON on its own line, with
+// the schema version set
+func (f *Formatter) FormatJSONL(records []JSONLRecord) (string, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, r := range records {
+		r.SchemaVersion = SchemaVersion
+		if err := enc.Encode(r); err != nil {
+			return "", fmt.Errorf("failed to marshal JSON: %w", err)
+		}
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,197 @@
+package output
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gliwka/SAMLurai/internal/saml"
+)
+
+// OTLP span kinds and status codes used in the trace export
+const (
+	otlpSpanKindInternal = 1
+	otlpSpanKindClient   = 3
+	otlpStatusOK         = 1
+	otlpStatusError      = 2
+)
+
+// otlpTrace mirrors the OTLP/JSON ExportTraceServiceRequest structure
+type otlpTrace struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            *otlpStatus     `json:"status,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+// FormatOTLPTrace renders the SAML messages of a HAR flow as an OTLP/JSON
+// trace. The flow becomes a root span and each SAML leg a child span timed
+// from its HAR entry, so it can be imported into Jaeger or Tempo.
+func (f *Formatter) FormatOTLPTrace(results []saml.ExtractedSAML) (string, error) {
+	traceID := otlpID(results, "trace", 16)
+	rootID := otlpID(results, "root", 8)
+
+	var spans []otlpSpan
+	var flowStart, flowEnd time.Time
+	var cursor time.Time
+	parser := saml.NewParser()
+
+	for _, r := range results {
+		start := cursor
+		if r.StartedAt != nil {
+			start = *r.StartedAt
+		}
+		end := start.Add(time.Duration(r.DurationMS * float64(time.Millisecond)))
+		cursor = end
+
+		if flowStart.IsZero() || start.Before(flowStart) {
+			flowStart = start
+		}
+		if end.After(flowEnd) {
+			flowEnd = end
+		}
+
+		span := otlpSpan{
+			TraceID:           traceID,
+			SpanID:            otlpID([]saml.ExtractedSAML{r}, "span-"+strconv.Itoa(r.Index), 8),
+			ParentSpanID:      rootID,
+			Name:              "SAML " + r.Type,
+			Kind:              otlpSpanKindClient,
+			StartTimeUnixNano: unixNano(start),
+			EndTimeUnixNano:   unixNano(end),
+			Attributes: []otlpAttribute{
+				stringAttribute("saml.index", strconv.Itoa(r.Index)),
+				stringAttribute("saml.type", r.Type),
+				stringAttribute("saml.source", r.Source),
+				stringAttribute("http.url", r.URL),
+			},
+		}
+		if r.ParameterName != "" {
+			span.Attributes = append(span.Attributes, stringAttribute("saml.parameter", r.ParameterName))
+		}
+
+		if info, err := parser.ParsePartial(r.DecodedXML); err == nil {
+			span.Attributes = appendInfoAttributes(span.Attributes, info)
+			if info.Status != nil {
+				span.Status = &otlpStatus{Code: otlpStatusOK}
+				if info.Status.StatusCode != "Success" {
+					span.Status = &otlpStatus{Code: otlpStatusError, Message: info.Status.StatusCode}
+				}
+			}
+		}
+
+		spans = append(spans, span)
+	}
+
+	root := otlpSpan{
+		TraceID:           traceID,
+		SpanID:            rootID,
+		Name:              "SAML authentication flow",
+		Kind:              otlpSpanKindInternal,
+		StartTimeUnixNano: unixNano(flowStart),
+		EndTimeUnixNano:   unixNano(flowEnd),
+		Attributes: []otlpAttribute{
+			stringAttribute("saml.message_count", strconv.Itoa(len(results))),
+		},
+	}
+
+	trace := otlpTrace{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource: otlpResource{
+				Attributes: []otlpAttribute{stringAttribute("service.name", "samlurai")},
+			},
+			ScopeSpans: []otlpScopeSpans{{
+				Scope: otlpScope{Name: "github.com/gliwka/SAMLurai"},
+				Spans: append([]otlpSpan{root}, spans...),
+			}},
+		}},
+	}
+
+	return f.toJSON(trace)
+}
+
+// appendInfoAttributes adds the identifying fields of a parsed message
+func appendInfoAttributes(attrs []otlpAttribute, info *saml.SAMLInfo) []otlpAttribute {
+	if info.ID != "" {
+		attrs = append(attrs, stringAttribute("saml.id", info.ID))
+	}
+	if info.Issuer != "" {
+		attrs = append(attrs, stringAttribute("saml.issuer", info.Issuer))
+	}
+	if info.InResponseTo != "" {
+		attrs = append(attrs, stringAttribute("saml.in_response_to", info.InResponseTo))
+	}
+	if info.Destination != "" {
+		attrs = append(attrs, stringAttribute("saml.destination", info.Destination))
+	}
+	if info.Status != nil {
+		attrs = append(attrs, stringAttribute("saml.status", info.Status.StatusCode))
+	}
+	return attrs
+}
+
+// otlpID derives a stable hex ID of size bytes from the messages, so the
+// same capture always produces the same trace
+func otlpID(results []saml.ExtractedSAML, salt string, size int) string {
+	h := sha256.New()
+	h.Write([]byte(salt))
+	for _, r := range results {
+		fmt.Fprintf(h, "%d|%s|%s|", r.Index, r.URL, r.RawValue)
+	}
+	return hex.EncodeToString(h.Sum(nil)[:size])
+}
+
+// unixNano formats t as OTLP nanoseconds, using 0 for unknown times
+func unixNano(t time.Time) string {
+	if t.Before(time.Unix(0, 0)) {
+		return "0"
+	}
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+func stringAttribute(key, value string) otlpAttribute {
+	return otlpAttribute{Key: key, Value: otlpValue{StringValue: value}}
+}
@@ -0,0 +1,76 @@
+package output
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatter_FormatOTLPTrace(t *testing.T) {
+	start := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	later := start.Add(2 * time.Second)
+
+	results := []saml.ExtractedSAML{
+		{
+			Index:         1,
+			Type:          "AuthnRequest",
+			Source:        "request-query",
+			URL:           "https://idp.example.com/sso",
+			ParameterName: "SAMLRequest",
+			DecodedXML:    []byte(`<samlp:AuthnRequest xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_req1"><saml:Issuer xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion">https://sp.example.com</saml:Issuer></samlp:AuthnRequest>`),
+			StartedAt:     &start,
+			DurationMS:    150,
+		},
+		{
+			Index:         2,
+			Type:          "Response",
+			Source:        "request-body",
+			URL:           "https://sp.example.com/acs",
+			ParameterName: "SAMLResponse",
+			DecodedXML:    []byte(`<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_resp1" InResponseTo="_req1"><samlp:Status><samlp:StatusCode Value="urn:oasis:names:tc:SAML:2.0:status:Requester"/></samlp:Status></samlp:Response>`),
+			StartedAt:     &later,
+			DurationMS:    50,
+		},
+	}
+
+	formatter := NewFormatter("otlp-trace")
+	result, err := formatter.FormatOTLPTrace(results)
+	require.NoError(t, err)
+
+	var trace otlpTrace
+	require.NoError(t, json.Unmarshal([]byte(result), &trace))
+	require.Len(t, trace.ResourceSpans, 1)
+	require.Len(t, trace.ResourceSpans[0].ScopeSpans, 1)
+
+	spans := trace.ResourceSpans[0].ScopeSpans[0].Spans
+	require.Len(t, spans, 3)
+
+	root := spans[0]
+	assert.Len(t, root.TraceID, 32)
+	assert.Len(t, root.SpanID, 16)
+	assert.Empty(t, root.ParentSpanID)
+	assert.Equal(t, "1705312800000000000", root.StartTimeUnixNano)
+	assert.Equal(t, "1705312802050000000", root.EndTimeUnixNano)
+
+	request := spans[1]
+	assert.Equal(t, "SAML AuthnRequest", request.Name)
+	assert.Equal(t, root.SpanID, request.ParentSpanID)
+	assert.Equal(t, root.TraceID, request.TraceID)
+	assert.Equal(t, "1705312800150000000", request.EndTimeUnixNano)
+	assert.Contains(t, request.Attributes, stringAttribute("saml.issuer", "https://sp.example.com"))
+
+	response := spans[2]
+	assert.Contains(t, response.Attributes, stringAttribute("saml.in_response_to", "_req1"))
+	require.NotNil(t, response.Status)
+	assert.Equal(t, otlpStatusError, response.Status.Code)
+	assert.Equal(t, "Requester", response.Status.Message)
+
+	// IDs are derived from the capture so repeated exports match
+	again, err := formatter.FormatOTLPTrace(results)
+	require.NoError(t, err)
+	assert.Equal(t, result, again)
+}
@@ -0,0 +1,174 @@
+package output
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/gliwka/SAMLurai/internal/saml"
+)
+
+// ReportEntry is a single SAML message rendered in an HTML report
+type ReportEntry struct {
+	File          string
+	Index         int
+	Type          string
+	Source        string
+	URL           string
+	ParameterName string
+	StartedAt     *time.Time
+	Info          *saml.SAMLInfo
+	Warnings      []string
+	Error         string
+}
+
+// Report holds everything rendered into a self-contained HTML report
+type Report struct {
+	Title       string
+	Input       string
+	GeneratedAt time.Time
+	Entries     []ReportEntry
+}
+
+// Files reports whether the entries name the file they come from, as in
+// reports covering a directory. Message numbers then repeat, so anchors
+// also carry the position of the entry.
+func (r Report) Files() bool {
+	for _, entry := range r.Entries {
+		if entry.File != "" {
+			return true
+		}
+	}
+	return false
+}
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"shortURI": shortenURI,
+	"join":     strings.Join,
+	"rfc3339": func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.Format(time.RFC3339)
+	},
+	"date": func(t time.Time) string {
+		return t.Format(time.RFC3339)
+	},
+}).Parse(reportHTML))
+
+// WriteHTMLReport renders the report as a single HTML document with inline
+// styles and no external resources, suitable for attaching to tickets
+func WriteHTMLReport(w io.Writer, report Report) error {
+	if report.Title == "" {
+		report.Title = "SAMLurai Report"
+	}
+	if err := reportTemplate.Execute(w, report); err != nil {
+		return fmt.Errorf("failed to render report: %w", err)
+	}
+	return nil
+}
+
+const reportHTML = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+  body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", sans-serif; margin: 24px; color: #1f2328; }
+  h1 { margin-bottom: 4px; }
+  .meta { color: #57606a; font-size: 13px; }
+  table { border-collapse: collapse; margin: 8px 0 16px; }
+  th, td { border: 1px solid #d0d7de; padding: 4px 8px; text-align: left; font-size: 13px; vertical-align: top; }
+  th { background: #f6f8fa; }
+  .message { border: 1px solid #d0d7de; border-radius: 6px; padding: 12px 16px; margin: 16px 0; }
+  .warning { color: #9a6700; }
+  .error { color: #cf222e; }
+  code { font-size: 12px; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<div class="meta">{{if .Input}}Input: {{.Input}} · {{end}}Generated {{date .GeneratedAt}} · {{len .Entries}} message(s)</div>
+
+<h2>Timeline</h2>
+<table>
+  <tr>{{if .Files}}<th>File</th>{{end}}<th>#</th><th>Time</th><th>Type</th><th>Source</th><th>URL</th><th>Warnings</th></tr>
+  {{- range $i, $e := .Entries}}
+  <tr>
+    {{- if $.Files}}<td>{{.File}}</td>{{end}}
+    <td><a href="#msg-{{if $.Files}}{{$i}}-{{end}}{{.Index}}">{{.Index}}</a></td>
+    <td>{{rfc3339 .StartedAt}}</td>
+    <td>{{.Type}}</td>
+    <td>{{.Source}}{{if .ParameterName}} ({{.ParameterName}}){{end}}</td>
+    <td><code>{{.URL}}</code></td>
+    <td>{{len .Warnings}}</td>
+  </tr>
+  {{- end}}
+</table>
+
+{{range $i, $e := .Entries}}
+<div class="message" id="msg-{{if $.Files}}{{$i}}-{{end}}{{.Index}}">
+  <h2>{{if .File}}{{.File}} {{end}}[{{.Index}}] {{.Type}}</h2>
+  {{- if .URL}}<div class="meta">{{.Source}} · <code>{{.URL}}</code></div>{{end}}
+  {{- if .Error}}<p class="error">{{.Error}}</p>{{end}}
+  {{- if .Warnings}}
+  <h3>Validation Warnings</h3>
+  <ul>{{range .Warnings}}<li class="warning">{{.}}</li>{{end}}</ul>
+  {{- end}}
+  {{- with .Info}}{{template "info" .}}{{end}}
+</div>
+{{end}}
+</body>
+</html>
+
+{{define "info"}}
+  <table>
+    <tr><th>ID</th><td>{{.ID}}</td></tr>
+    <tr><th>Issuer</th><td>{{.Issuer}}</td></tr>
+    {{- if .IssueInstant}}<tr><th>Issue Instant</th><td>{{rfc3339 .IssueInstant}}</td></tr>{{end}}
+    {{- if .Destination}}<tr><th>Destination</th><td>{{.Destination}}</td></tr>{{end}}
+    {{- if .InResponseTo}}<tr><th>In Response To</th><td>{{.InResponseTo}}</td></tr>{{end}}
+    {{- with .Status}}<tr><th>Status</th><td>{{.StatusCode}}{{if .StatusMessage}}: {{.StatusMessage}}{{end}}</td></tr>{{end}}
+    {{- if .AssertionConsumerServiceURL}}<tr><th>ACS URL</th><td>{{.AssertionConsumerServiceURL}}</td></tr>{{end}}
+    {{- with .Subject}}<tr><th>NameID</th><td>{{.NameID}}{{if .NameIDFormat}} ({{shortURI .NameIDFormat}}){{end}}</td></tr>{{end}}
+    {{- with .Conditions}}
+    <tr><th>Not Before</th><td>{{rfc3339 .NotBefore}}</td></tr>
+    <tr><th>Not On Or After</th><td>{{rfc3339 .NotOnOrAfter}}</td></tr>
+    <tr><th>Audiences</th><td>{{join .AudienceRestriction ", "}}</td></tr>
+    {{- end}}
+    {{- with .AuthnStatement}}
+    <tr><th>Auth Instant</th><td>{{rfc3339 .AuthnInstant}}</td></tr>
+    <tr><th>Auth Context</th><td>{{shortURI .AuthnContextClassRef}}</td></tr>
+    {{- end}}
+  </table>
+  {{- if .Attributes}}
+  <h3>Attributes</h3>
+  <table>
+    <tr><th>Name</th><th>Friendly Name</th><th>Values</th></tr>
+    {{- range .Attributes}}
+    <tr><td>{{.Name}}</td><td>{{.FriendlyName}}</td><td>{{join .Values ", "}}</td></tr>
+    {{- end}}
+  </table>
+  {{- end}}
+  {{- with .Signature}}
+  <h3>Signature</h3>
+  <table>
+    <tr><th>Signature Method</th><td>{{shortURI .SignatureMethod}}</td></tr>
+    <tr><th>Digest Method</th><td>{{shortURI .DigestMethod}}</td></tr>
+    {{- with .CertificateInfo}}
+    <tr><th>Cert Subject</th><td>{{.Subject}}</td></tr>
+    <tr><th>Cert Issuer</th><td>{{.Issuer}}</td></tr>
+    <tr><th>Cert Serial</th><td>{{.Serial}}</td></tr>
+    <tr><th>Cert Valid From</th><td>{{date .NotBefore}}</td></tr>
+    <tr><th>Cert Valid Until</th><td>{{date .NotAfter}}</td></tr>
+    {{- end}}
+  </table>
+  {{- end}}
+  {{- with .Assertion}}
+  <h3>Embedded Assertion</h3>
+  {{template "info" .}}
+  {{- end}}
+{{end}}
+`
@@ -0,0 +1,65 @@
+package output
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteHTMLReport(t *testing.T) {
+	started := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+
+	report := Report{
+		Input:       "session.har",
+		GeneratedAt: started,
+		Entries: []ReportEntry{
+			{
+				Index:     1,
+				Type:      "Response",
+				Source:    "request-body",
+				URL:       "https://sp.example.com/acs",
+				StartedAt: &started,
+				Warnings:  []string{"assertion has expired"},
+				Info: &saml.SAMLInfo{
+					Type:   "Response",
+					ID:     "_resp1",
+					Issuer: "https://idp.example.com",
+					Status: &saml.Status{StatusCode: "Success"},
+					Assertion: &saml.SAMLInfo{
+						Type:    "Assertion",
+						Subject: &saml.Subject{NameID: "user@example.com"},
+						Attributes: []saml.Attribute{
+							{Name: "email", Values: []string{"<script>alert(1)</script>"}},
+						},
+					},
+				},
+			},
+			{
+				Index: 2,
+				Type:  "Response",
+				Error: "Encrypted assertion detected",
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteHTMLReport(&buf, report))
+	html := buf.String()
+
+	assert.Contains(t, html, "<title>SAMLurai Report</title>")
+	assert.Contains(t, html, "session.har")
+	assert.Contains(t, html, "2 message(s)")
+	assert.Contains(t, html, `href="#msg-1"`)
+	assert.Contains(t, html, "assertion has expired")
+	assert.Contains(t, html, "user@example.com")
+	assert.Contains(t, html, "Embedded Assertion")
+	assert.Contains(t, html, "Encrypted assertion detected")
+
+	// Attribute values must be escaped
+	assert.NotContains(t, html, "<script>alert(1)</script>")
+	assert.Contains(t, html, "&lt;script&gt;")
+}
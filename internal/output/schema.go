@@ -0,0 +1,27 @@
+package output
+
+import (
+	_ "embed"
+
+	"github.com/gliwka/SAMLurai/internal/saml"
+)
+
+// SchemaVersion is the version of the JSON output described by Schema. The
+// minor version is raised when fields are added, and the major version
+// when fields are removed, renamed or change their type, so parsers can
+// rely on every output of the same major version.
+const SchemaVersion = "1.0"
+
+// Schema is the JSON Schema of the json and jsonl output of messages: a
+// parsed message (SAMLInfo), one record per line of jsonl, or an array of
+// records for captures. It is generated from the Go types by the tests.
+//
+//go:embed schema.json
+var Schema []byte
+
+// versioned returns a copy of the message with its schema version set
+func versioned(info *saml.SAMLInfo) *saml.SAMLInfo {
+	v := *info
+	v.SchemaVersion = SchemaVersion
+	return &v
+}
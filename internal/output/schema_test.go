@@ -0,0 +1,177 @@
+package output
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/gliwka/SAMLurai/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// schemaNames renames types in the schema's definitions
+var schemaNames = map[string]string{"JSONLRecord": "Message"}
+
+// schemaGenerator derives a JSON Schema from the JSON encoding of Go types
+type schemaGenerator struct {
+	defs map[string]any
+}
+
+func (g *schemaGenerator) schema(t reflect.Type) map[string]any {
+	switch t {
+	case reflect.TypeOf(time.Time{}):
+		return map[string]any{"type": "string", "format": "date-time"}
+	case reflect.TypeOf([]byte(nil)):
+		return map[string]any{"type": "string", "contentEncoding": "base64"}
+	case reflect.TypeOf(saml.ConditionList(nil)):
+		// Each condition is encoded with its fields and its type
+		return map[string]any{"type": "array", "items": map[string]any{
+			"type":       "object",
+			"properties": map[string]any{"type": map[string]any{"type": "string"}},
+			"required":   []string{"type"},
+		}}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return g.schema(t.Elem())
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		name := t.Name()
+		if renamed, ok := schemaNames[name]; ok {
+			name = renamed
+		}
+		if _, ok := g.defs[name]; !ok {
+			g.defs[name] = nil // placeholder for recursive types
+			g.defs[name] = g.object(t)
+		}
+		return map[string]any{"$ref": "#/$defs/" + name}
+	}
+	return map[string]any{}
+}
+
+// object describes the fields of a struct as encoding/json encodes them.
+// Fields without omitempty are required; slices and pointers among them
+// may be null.
+func (g *schemaGenerator) object(t reflect.Type) map[string]any {
+	properties := map[string]any{}
+	var required []string
+	var add func(t reflect.Type)
+	add = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			tag := field.Tag.Get("json")
+			if field.Anonymous && tag == "" {
+				add(field.Type)
+				continue
+			}
+			if !field.IsExported() || tag == "-" {
+				continue
+			}
+			name, options, _ := strings.Cut(tag, ",")
+			if name == "" {
+				name = field.Name
+			}
+			schema := g.schema(field.Type)
+			if options == "omitempty" {
+				properties[name] = schema
+				continue
+			}
+			required = append(required, name)
+			if kind := field.Type.Kind(); kind == reflect.Slice || kind == reflect.Pointer || kind == reflect.Map {
+				schema = map[string]any{"anyOf": []any{schema, map[string]any{"type": "null"}}}
+			}
+			properties[name] = schema
+		}
+	}
+	add(t)
+
+	object := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		object["required"] = required
+	}
+	return object
+}
+
+// generateSchema generates the schema of the message output
+func generateSchema() (string, error) {
+	g := &schemaGenerator{defs: map[string]any{}}
+	info := g.schema(reflect.TypeOf(saml.SAMLInfo{}))
+	record := g.schema(reflect.TypeOf(JSONLRecord{}))
+
+	schema := map[string]any{
+		"$schema":     "https://json-schema.org/draft/2020-12/schema",
+		"title":       "samlurai JSON output",
+		"description": "Output of samlurai -o json and -o jsonl for SAML messages, schema_version " + SchemaVersion + ": a parsed message (inspect of a single message), one Message per line (jsonl), or an array of Messages (inspect of a capture, extract).",
+		"anyOf": []any{
+			info,
+			record,
+			map[string]any{"type": "array", "items": record},
+		},
+		"$defs": g.defs,
+	}
+	data, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data) + "\n", nil
+}
+
+func TestSchema(t *testing.T) {
+	generated, err := generateSchema()
+	require.NoError(t, err)
+
+	// Run with -update after changing the output types, and raise
+	// SchemaVersion as its doc comment describes
+	testutil.New(t, ".").Assert(generated, "schema.json")
+	assert.Equal(t, generated, string(Schema), "the embedded schema is schema.json")
+}
+
+func TestSchema_Definitions(t *testing.T) {
+	var schema struct {
+		Defs map[string]struct {
+			Properties map[string]json.RawMessage `json:"properties"`
+			Required   []string                   `json:"required"`
+		} `json:"$defs"`
+	}
+	require.NoError(t, json.Unmarshal(Schema, &schema))
+
+	info := schema.Defs["SAMLInfo"]
+	assert.Contains(t, info.Properties, "schema_version")
+	assert.Contains(t, info.Properties, "assertion")
+	assert.Equal(t, []string{"type"}, info.Required)
+	assert.Contains(t, schema.Defs["Message"].Required, "schema_version")
+	assert.Contains(t, schema.Defs, "Attribute")
+}
+
+func TestFormatSAMLInfo_SchemaVersion(t *testing.T) {
+	info := &saml.SAMLInfo{Type: "Response", Assertion: &saml.SAMLInfo{Type: "Assertion"}}
+
+	formatted, err := NewFormatter("json").FormatSAMLInfo(info)
+	require.NoError(t, err)
+	var decoded map[string]any
+	require.NoError(t, json.Unmarshal([]byte(formatted), &decoded))
+	assert.Equal(t, SchemaVersion, decoded["schema_version"])
+	assert.NotContains(t, decoded["assertion"], "schema_version", "only the top-level message is versioned")
+	assert.Empty(t, info.SchemaVersion, "the message is not modified")
+
+	formatted, err = NewFormatter("jsonl").FormatSAMLInfo(info)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(formatted, `{"schema_version":"`+SchemaVersion+`"`), formatted)
+}
@@ -0,0 +1,78 @@
+package output
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+
+	"github.com/gliwka/SAMLurai/internal/saml"
+)
+
+// MessageInfo pairs a parsed SAML message with its position in the input
+type MessageInfo struct {
+	Index int
+	Info  *saml.SAMLInfo
+}
+
+// attributeColumns is the header row of the tabular attribute output
+var attributeColumns = []string{"message_index", "issuer", "attribute_name", "friendly_name", "value"}
+
+// IsTabular reports whether the formatter produces CSV or TSV output
+func (f *Formatter) IsTabular() bool {
+	return f.format == "csv" || f.format == "tsv"
+}
+
+// FormatAttributes renders the attributes of all messages as CSV or TSV,
+// with one row per attribute value
+func (f *Formatter) FormatAttributes(messages []MessageInfo) (string, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if f.format == "tsv" {
+		w.Comma = '\t'
+	}
+
+	if err := w.Write(attributeColumns); err != nil {
+		return "", fmt.Errorf("failed to write header: %w", err)
+	}
+
+	for _, m := range messages {
+		if m.Info == nil {
+			continue
+		}
+		for _, row := range attributeRows(m.Index, m.Info) {
+			if err := w.Write(row); err != nil {
+				return "", fmt.Errorf("failed to write row: %w", err)
+			}
+		}
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return "", fmt.Errorf("failed to write output: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// attributeRows flattens the attributes of a message and its embedded
+// assertion into table rows
+func attributeRows(index int, info *saml.SAMLInfo) [][]string {
+	var rows [][]string
+
+	for _, source := range []*saml.SAMLInfo{info, info.Assertion} {
+		if source == nil {
+			continue
+		}
+		issuer := source.Issuer
+		if issuer == "" {
+			issuer = info.Issuer
+		}
+		for _, attr := range source.Attributes {
+			for _, value := range attr.Values {
+				rows = append(rows, []string{strconv.Itoa(index), issuer, attr.Name, attr.FriendlyName, value})
+			}
+		}
+	}
+
+	return rows
+}
@@ -0,0 +1,58 @@
+package output
+
+import (
+	"testing"
+
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatter_FormatAttributes(t *testing.T) {
+	messages := []MessageInfo{
+		{
+			Index: 1,
+			Info: &saml.SAMLInfo{
+				Type:   "Response",
+				Issuer: "https://idp.example.com",
+				Assertion: &saml.SAMLInfo{
+					Type: "Assertion",
+					Attributes: []saml.Attribute{
+						{Name: "groups", FriendlyName: "Groups", Values: []string{"admins", "users, staff"}},
+					},
+				},
+			},
+		},
+		{Index: 2, Info: nil},
+		{
+			Index: 3,
+			Info: &saml.SAMLInfo{
+				Type:       "Assertion",
+				Issuer:     "https://other-idp.example.com",
+				Attributes: []saml.Attribute{{Name: "email", Values: []string{"user@example.com"}}},
+			},
+		},
+	}
+
+	t.Run("csv", func(t *testing.T) {
+		result, err := NewFormatter("csv").FormatAttributes(messages)
+		require.NoError(t, err)
+		assert.Equal(t, "message_index,issuer,attribute_name,friendly_name,value\n"+
+			"1,https://idp.example.com,groups,Groups,admins\n"+
+			"1,https://idp.example.com,groups,Groups,\"users, staff\"\n"+
+			"3,https://other-idp.example.com,email,,user@example.com\n", result)
+	})
+
+	t.Run("tsv", func(t *testing.T) {
+		result, err := NewFormatter("tsv").FormatAttributes(messages)
+		require.NoError(t, err)
+		assert.Contains(t, result, "message_index\tissuer\tattribute_name\tfriendly_name\tvalue\n")
+		assert.Contains(t, result, "1\thttps://idp.example.com\tgroups\tGroups\tusers, staff\n")
+	})
+}
+
+func TestFormatter_IsTabular(t *testing.T) {
+	assert.True(t, NewFormatter("csv").IsTabular())
+	assert.True(t, NewFormatter("TSV").IsTabular())
+	assert.False(t, NewFormatter("json").IsTabular())
+}
@@ -0,0 +1,52 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/gliwka/SAMLurai/internal/saml"
+)
+
+// templateFuncs are available to user templates in addition to the builtins
+var templateFuncs = template.FuncMap{
+	"join":     strings.Join,
+	"shortURI": shortenURI,
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	},
+}
+
+// Template renders SAMLInfo through a user-supplied Go text/template,
+// similar to docker inspect --format
+type Template struct {
+	tmpl *template.Template
+}
+
+// NewTemplate parses a user-supplied template
+func NewTemplate(text string) (*Template, error) {
+	tmpl, err := template.New("output").Funcs(templateFuncs).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+	return &Template{tmpl: tmpl}, nil
+}
+
+// Execute applies the template to info. A trailing newline is added so
+// that each message ends up on its own line.
+func (t *Template) Execute(info *saml.SAMLInfo) (string, error) {
+	var buf bytes.Buffer
+	if err := t.tmpl.Execute(&buf, info); err != nil {
+		return "", fmt.Errorf("failed to execute template: %w", err)
+	}
+	if !strings.HasSuffix(buf.String(), "\n") {
+		buf.WriteString("\n")
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,53 @@
+package output
+
+import (
+	"testing"
+
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplate_Execute(t *testing.T) {
+	info := &saml.SAMLInfo{
+		Type:    "Assertion",
+		Issuer:  "https://idp.example.com",
+		Subject: &saml.Subject{NameID: "user@example.com", NameIDFormat: "urn:oasis:names:tc:SAML:2.0:nameid-format:persistent"},
+		Attributes: []saml.Attribute{
+			{Name: "groups", Values: []string{"admins", "users"}},
+		},
+	}
+
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"fields", "{{.Subject.NameID}} {{.Issuer}}", "user@example.com https://idp.example.com\n"},
+		{"trailing newline kept", "{{.Type}}\n", "Assertion\n"},
+		{"join", "{{range .Attributes}}{{.Name}}={{join .Values \",\"}}{{end}}", "groups=admins,users\n"},
+		{"shortURI", "{{shortURI .Subject.NameIDFormat}}", "persistent\n"},
+		{"json", "{{json .Subject}}", `{"name_id":"user@example.com","name_id_format":"urn:oasis:names:tc:SAML:2.0:nameid-format:persistent"}` + "\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := NewTemplate(tt.text)
+			require.NoError(t, err)
+
+			result, err := tmpl.Execute(info)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, result)
+		})
+	}
+}
+
+func TestTemplate_Errors(t *testing.T) {
+	_, err := NewTemplate("{{.Issuer")
+	assert.ErrorContains(t, err, "invalid template")
+
+	tmpl, err := NewTemplate("{{.Assertion.Issuer}}")
+	require.NoError(t, err)
+	_, err = tmpl.Execute(&saml.SAMLInfo{Type: "AuthnRequest"})
+	assert.ErrorContains(t, err, "failed to execute template")
+}
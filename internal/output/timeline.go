@@ -0,0 +1,148 @@
+package output
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gliwka/SAMLurai/internal/saml"
+)
+
+// timelineWidth is the number of cells in the lifetime bar
+const timelineWidth = 40
+
+// WithReferenceTime sets the time marked on the lifetime bar, e.g. the
+// capture time of a HAR entry. label is shown in the legend; by default
+// the current time is marked as "now".
+func (f *Formatter) WithReferenceTime(t time.Time, label string) *Formatter {
+	f.refTime = t
+	f.refLabel = label
+	return f
+}
+
+// WithClockSkew sets the clock skew tolerated when judging whether the
+// reference time falls within a validity window
+func (f *Formatter) WithClockSkew(skew time.Duration) *Formatter {
+	f.skew = skew
+	return f
+}
+
+// referenceTime returns the time and label marked on the lifetime bar
+func (f *Formatter) referenceTime() (time.Time, string) {
+	if f.refTime.IsZero() {
+		return time.Now(), "now"
+	}
+	return f.refTime, f.refLabel
+}
+
+type timelinePoint struct {
+	marker rune
+	label  string
+	at     *time.Time
+}
+
+// lifetimeBar renders an ASCII timeline of the assertion timestamps with
+// the validity window drawn between NotBefore and NotOnOrAfter. It returns
+// false if there are fewer than two distinct timestamps to place. valid
+// reports whether ref is within the window, give or take skew.
+func lifetimeBar(info *saml.SAMLInfo, ref time.Time, refLabel string, skew time.Duration) (bar, legend string, valid, ok bool) {
+	var notBefore, notOnOrAfter, authnInstant *time.Time
+	if info.Conditions != nil {
+		notBefore, notOnOrAfter = info.Conditions.NotBefore, info.Conditions.NotOnOrAfter
+	}
+	if info.AuthnStatement != nil {
+		authnInstant = info.AuthnStatement.AuthnInstant
+	}
+
+	// Later points win when they share a cell, so the window bounds stay visible
+	points := []timelinePoint{
+		{'I', "IssueInstant", info.IssueInstant},
+		{'A', "AuthnInstant", authnInstant},
+		{'B', "NotBefore", notBefore},
+		{'E', "NotOnOrAfter", notOnOrAfter},
+	}
+
+	var start, end time.Time
+	var present []timelinePoint
+	for _, p := range points {
+		if p.at == nil {
+			continue
+		}
+		if len(present) == 0 || p.at.Before(start) {
+			start = *p.at
+		}
+		if len(present) == 0 || p.at.After(end) {
+			end = *p.at
+		}
+		present = append(present, p)
+	}
+	if len(present) < 2 || !end.After(start) {
+		return "", "", false, false
+	}
+
+	span := end.Sub(start)
+	pos := func(t time.Time) int {
+		return int(float64(t.Sub(start))/float64(span)*float64(timelineWidth-1) + 0.5)
+	}
+
+	cells := []rune(strings.Repeat("·", timelineWidth))
+	if notBefore != nil && notOnOrAfter != nil {
+		for i := pos(*notBefore); i <= pos(*notOnOrAfter); i++ {
+			cells[i] = '═'
+		}
+	}
+
+	var labels []string
+	for _, p := range present {
+		cells[pos(*p.at)] = p.marker
+		labels = append(labels, string(p.marker)+"="+p.label)
+	}
+
+	refMarker := 'N'
+	if refLabel != "now" {
+		refMarker = 'C'
+	}
+	bar = "[" + string(cells) + "]"
+	switch {
+	case ref.Before(start):
+		bar = "◀ " + refLabel + " " + bar
+	case ref.After(end):
+		bar = bar + " " + refLabel + " ▶"
+	default:
+		cells[pos(ref)] = refMarker
+		bar = "[" + string(cells) + "]"
+		labels = append(labels, string(refMarker)+"="+refLabel)
+	}
+
+	timing, _ := saml.CheckWindow(notBefore, notOnOrAfter, ref, skew)
+	return bar, strings.Join(labels, " "), timing == saml.TimingValid, true
+}
+
+// validity describes ref relative to a validity window, e.g. "expired 10m
+// before capture" or "valid now, 4m left". warn is set outside the window.
+func validity(notBefore, notOnOrAfter *time.Time, ref time.Time, refLabel string, skew time.Duration) (text string, warn bool) {
+	at := "at " + refLabel
+	if refLabel == "now" {
+		at = "now"
+	}
+
+	timing, by := saml.CheckWindow(notBefore, notOnOrAfter, ref, skew)
+	switch timing {
+	case saml.TimingEarly:
+		return fmt.Sprintf("not yet valid %s, %s early", at, saml.FormatDuration(by)), true
+	case saml.TimingExpired:
+		return fmt.Sprintf("expired %s before %s", saml.FormatDuration(by), refLabel), true
+	}
+
+	text = "valid " + at
+	if strict, by := saml.CheckWindow(notBefore, notOnOrAfter, ref, 0); strict != saml.TimingValid {
+		if strict == saml.TimingEarly {
+			return fmt.Sprintf("%s, %s early but within the clock skew", text, saml.FormatDuration(by)), false
+		}
+		return fmt.Sprintf("%s, %s late but within the clock skew", text, saml.FormatDuration(by)), false
+	}
+	if notOnOrAfter != nil {
+		text += ", " + saml.FormatDuration(notOnOrAfter.Sub(ref)) + " left"
+	}
+	return text, false
+}
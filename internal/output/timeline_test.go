@@ -0,0 +1,131 @@
+package output
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func timelineInfo() *saml.SAMLInfo {
+	at := func(s string) *time.Time {
+		t, _ := time.Parse(time.RFC3339, s)
+		return &t
+	}
+	return &saml.SAMLInfo{
+		Type:         "Response",
+		IssueInstant: at("2024-01-15T10:30:00Z"),
+		Conditions: &saml.Conditions{
+			NotBefore:    at("2024-01-15T10:25:00Z"),
+			NotOnOrAfter: at("2024-01-15T10:35:00Z"),
+		},
+		AuthnStatement: &saml.AuthnStatement{
+			AuthnInstant: at("2024-01-15T10:29:00Z"),
+		},
+	}
+}
+
+func TestLifetimeBar(t *testing.T) {
+	info := timelineInfo()
+
+	t.Run("inside window", func(t *testing.T) {
+		ref := time.Date(2024, 1, 15, 10, 32, 0, 0, time.UTC)
+		bar, legend, valid, ok := lifetimeBar(info, ref, "capture", 0)
+		require.True(t, ok)
+
+		assert.True(t, valid)
+		assert.Equal(t, "[B═══════════════A═══I══════C═══════════E]", bar)
+		assert.Equal(t, "I=IssueInstant A=AuthnInstant B=NotBefore E=NotOnOrAfter C=capture", legend)
+	})
+
+	t.Run("after window", func(t *testing.T) {
+		ref := time.Date(2024, 1, 16, 0, 0, 0, 0, time.UTC)
+		bar, legend, valid, ok := lifetimeBar(info, ref, "now", 0)
+		require.True(t, ok)
+
+		assert.False(t, valid)
+		assert.Equal(t, "[B═══════════════A═══I══════════════════E] now ▶", bar)
+		assert.NotContains(t, legend, "N=now")
+	})
+
+	t.Run("before window", func(t *testing.T) {
+		ref := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+		bar, _, valid, ok := lifetimeBar(info, ref, "now", 0)
+		require.True(t, ok)
+
+		assert.False(t, valid)
+		assert.Equal(t, "◀ now [B═══════════════A═══I══════════════════E]", bar)
+	})
+
+	t.Run("not enough timestamps", func(t *testing.T) {
+		single := &saml.SAMLInfo{IssueInstant: info.IssueInstant}
+		_, _, _, ok := lifetimeBar(single, time.Now(), "now", 0)
+		assert.False(t, ok)
+	})
+}
+
+func TestFormatter_FormatSAMLInfo_Lifetime(t *testing.T) {
+	ref := time.Date(2024, 1, 15, 10, 32, 0, 0, time.UTC)
+	result, err := NewFormatterWithOptions("pretty", true).
+		WithReferenceTime(ref, "capture").
+		FormatSAMLInfo(timelineInfo())
+	require.NoError(t, err)
+
+	assert.Contains(t, result, "Lifetime:")
+	assert.Contains(t, result, "C=capture")
+}
+
+func TestValidity(t *testing.T) {
+	info := timelineInfo()
+	notBefore, notOnOrAfter := info.Conditions.NotBefore, info.Conditions.NotOnOrAfter
+	at := func(hour, minute int) time.Time { return time.Date(2024, 1, 15, hour, minute, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name string
+		ref  time.Time
+		skew time.Duration
+		want string
+		warn bool
+	}{
+		{"inside", at(10, 32), 0, "valid at capture, 3m left", false},
+		{"expired", at(11, 5), 0, "expired 30m before capture", true},
+		{"early", at(10, 20), 0, "not yet valid at capture, 5m early", true},
+		{"early within skew", at(10, 20), 10 * time.Minute, "valid at capture, 5m early but within the clock skew", false},
+		{"late within skew", at(10, 37), 5 * time.Minute, "valid at capture, 2m late but within the clock skew", false},
+		{"expired beyond skew", at(10, 45), 5 * time.Minute, "expired 10m before capture", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, warn := validity(notBefore, notOnOrAfter, tt.ref, "capture", tt.skew)
+			assert.Equal(t, tt.want, text)
+			assert.Equal(t, tt.warn, warn)
+		})
+	}
+
+	text, _ := validity(nil, notOnOrAfter, at(10, 40), "now", 0)
+	assert.Equal(t, "expired 5m before now", text)
+}
+
+func TestFormatter_FormatSAMLInfo_ClockSkew(t *testing.T) {
+	info := timelineInfo()
+	sessionEnd := time.Date(2024, 1, 15, 10, 36, 0, 0, time.UTC)
+	info.AuthnStatement.SessionNotOnOrAfter = &sessionEnd
+	ref := time.Date(2024, 1, 15, 10, 37, 0, 0, time.UTC)
+
+	result, err := NewFormatterWithOptions("pretty", true).
+		WithReferenceTime(ref, "now").
+		FormatSAMLInfo(info)
+	require.NoError(t, err)
+	assert.Contains(t, result, "expired 2m before now")
+	assert.Contains(t, result, "2024-01-15T10:36:00Z (expired 1m before now)")
+
+	result, err = NewFormatterWithOptions("pretty", true).
+		WithReferenceTime(ref, "now").
+		WithClockSkew(5 * time.Minute).
+		FormatSAMLInfo(info)
+	require.NoError(t, err)
+	assert.Contains(t, result, "valid now, 2m late but within the clock skew")
+	assert.Contains(t, result, "(valid now, 1m late but within the clock skew)")
+}
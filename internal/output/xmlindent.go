@@ -0,0 +1,152 @@
+package output
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// indentXML indents an XML document by copying the bytes of each tag,
+// text, comment and processing instruction unchanged and only replacing
+// the whitespace between tags. Unlike re-encoding with encoding/xml, it
+// keeps namespace prefixes and declarations, attribute order and quoting,
+// entity references and CDATA sections. Whitespace between tags is still
+// covered by XML signatures, so signed XML must be verified from its
+// original bytes rather than from the indented copy. Elements holding
+// text, including mixed content such as <p>a <b>b</b> c</p>, are copied
+// as is, since whitespace around their child elements is part of the text.
+func indentXML(data []byte, indent string) (string, error) {
+	var out strings.Builder
+	d := xml.NewDecoder(bytes.NewReader(data))
+
+	// verbatim is the depth of the element with text being copied as is,
+	// or -1. elements counts the start tags, indexing withText.
+	withText := elementsWithText(data)
+	verbatim, elements := -1, 0
+
+	// opened is set right after a start tag, and text after text, so a
+	// following end tag stays on the same line. Whitespace is held back
+	// until it turns out to be the only content of an element.
+	opened, text := false, false
+	var space []byte
+	var open []xml.Name
+	newline := func(depth int) {
+		if out.Len() > 0 {
+			out.WriteString("\n")
+			out.WriteString(strings.Repeat(indent, depth))
+		}
+	}
+
+	for {
+		start := d.InputOffset()
+		tok, err := d.RawToken()
+		if err == io.EOF {
+			if len(open) > 0 {
+				return "", fmt.Errorf("failed to parse XML: element <%s> is not closed", qualifiedName(open[len(open)-1]))
+			}
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to parse XML: %w", err)
+		}
+		raw := data[start:d.InputOffset()]
+
+		held := space
+		space = nil
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if verbatim < 0 {
+				newline(len(open))
+				if elements < len(withText) && withText[elements] {
+					verbatim = len(open)
+				}
+			}
+			out.Write(raw)
+			open = append(open, t.Name)
+			elements++
+			opened, text = true, false
+		case xml.EndElement:
+			if len(open) == 0 || open[len(open)-1] != t.Name {
+				return "", fmt.Errorf("failed to parse XML: unexpected end element </%s>", qualifiedName(t.Name))
+			}
+			open = open[:len(open)-1]
+			if verbatim >= 0 {
+				out.Write(raw)
+				if len(open) == verbatim {
+					verbatim = -1
+				}
+				opened, text = false, false
+				break
+			}
+			// The end of an empty element <a/> has no bytes of its own
+			if len(raw) > 0 {
+				switch {
+				case opened:
+					out.Write(held)
+				case !text:
+					newline(len(open))
+				}
+				out.Write(raw)
+			}
+			opened, text = false, false
+		case xml.CharData:
+			if verbatim >= 0 {
+				out.Write(raw)
+				continue
+			}
+			if len(bytes.TrimSpace(raw)) == 0 {
+				space = raw
+				continue
+			}
+			out.Write(raw)
+			opened, text = false, true
+		default:
+			// Comments, processing instructions and directives
+			if verbatim < 0 {
+				newline(len(open))
+			}
+			out.Write(raw)
+			opened, text = false, false
+		}
+	}
+
+	return out.String() + "\n", nil
+}
+
+// elementsWithText reports for each element, in document order, whether
+// text other than whitespace is among its children. Parse errors are left
+// to indentXML to report.
+func elementsWithText(data []byte) []bool {
+	var withText []bool
+	var open []int
+	d := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := d.RawToken()
+		if err != nil {
+			return withText
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			open = append(open, len(withText))
+			withText = append(withText, false)
+		case xml.EndElement:
+			if len(open) > 0 {
+				open = open[:len(open)-1]
+			}
+		case xml.CharData:
+			if len(open) > 0 && len(bytes.TrimSpace(t)) > 0 {
+				withText[open[len(open)-1]] = true
+			}
+		}
+	}
+}
+
+// qualifiedName returns a name as written, with its prefix
+func qualifiedName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
@@ -0,0 +1,63 @@
+package output
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndentXML(t *testing.T) {
+	input := `<?xml version="1.0" encoding="UTF-8"?>
+<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID='_r1' Version="2.0"><saml:Issuer xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion">a &amp; b</saml:Issuer>   <!-- comment --><ds:Signature xmlns:ds="http://www.w3.org/2000/09/xmldsig#"><ds:SignatureValue>
+abc=
+</ds:SignatureValue></ds:Signature><Empty/><Space> </Space><Data><![CDATA[<x>]]></Data></samlp:Response>`
+
+	result, err := indentXML([]byte(input), "  ")
+	require.NoError(t, err)
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID='_r1' Version="2.0">
+  <saml:Issuer xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion">a &amp; b</saml:Issuer>
+  <!-- comment -->
+  <ds:Signature xmlns:ds="http://www.w3.org/2000/09/xmldsig#">
+    <ds:SignatureValue>
+abc=
+</ds:SignatureValue>
+  </ds:Signature>
+  <Empty/>
+  <Space> </Space>
+  <Data><![CDATA[<x>]]></Data>
+</samlp:Response>
+`, result)
+
+	// Indenting is idempotent
+	again, err := indentXML([]byte(result), "  ")
+	require.NoError(t, err)
+	assert.Equal(t, result, again)
+}
+
+func TestIndentXML_MixedContent(t *testing.T) {
+	input := `<Response><Status><StatusMessage>Login <b>failed</b>  for <i>alice</i>: <br/>try again</StatusMessage></Status><Text> lead <x> <y/> </x> trail </Text></Response>`
+
+	result, err := indentXML([]byte(input), "  ")
+	require.NoError(t, err)
+	assert.Equal(t, `<Response>
+  <Status>
+    <StatusMessage>Login <b>failed</b>  for <i>alice</i>: <br/>try again</StatusMessage>
+  </Status>
+  <Text> lead <x> <y/> </x> trail </Text>
+</Response>
+`, result)
+}
+
+func TestIndentXML_Malformed(t *testing.T) {
+	for _, input := range []string{`<a><b></a>`, `<a><b></b>`, `</a>`, `<a x="1>`} {
+		_, err := indentXML([]byte(input), "  ")
+		assert.Error(t, err, input)
+	}
+
+	// Formatting falls back to the input unchanged
+	result, err := NewFormatter("xml").FormatXML([]byte(`<a><b></a>`))
+	require.NoError(t, err)
+	assert.Equal(t, "<a><b></a>\n", result)
+}
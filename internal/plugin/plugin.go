@@ -0,0 +1,369 @@
+// Package plugin runs external programs that extend samlurai with lint
+// rules, audit checks, extraction sources and attribute names, so
+// organizations can add their own without forking.
+//
+// Each plugin is a subdirectory of the plugin directory, described by a
+// YAML manifest named plugin.yaml:
+//
+//	name: acme
+//	command: ./acme-saml          # relative to the manifest, or on $PATH
+//	capabilities: [lint, audit, extract]
+//	extensions: [.acmelog]        # files the extract capability reads
+//	attribute_map: names.yaml     # further attribute names, no command needed
+//	rules:
+//	  - id: test-idp
+//	    severity: error
+//	    description: Production SPs only trust production IdPs
+//
+// For each call, the command is run with a JSON request on stdin and
+// answers with a JSON response on stdout; see Request and Response.
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"gopkg.in/yaml.v3"
+)
+
+// Capabilities a plugin can declare
+const (
+	// CapabilityLint adds lint rules, checked for each message
+	CapabilityLint = "lint"
+
+	// CapabilityAudit adds audit checks, run for each message
+	CapabilityAudit = "audit"
+
+	// CapabilityExtract reads files with the manifest's extensions and
+	// returns their HTTP exchanges as a HAR document
+	CapabilityExtract = "extract"
+)
+
+// ProtocolVersion is the version of the request and response format
+const ProtocolVersion = 1
+
+// ManifestName is the file name of plugin manifests
+const ManifestName = "plugin.yaml"
+
+// DefaultTimeout is how long a plugin may take to answer a request
+const DefaultTimeout = 30 * time.Second
+
+// validName matches plugin names, which prefix their rule and check IDs
+var validName = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// Manifest describes a plugin
+type Manifest struct {
+	Name         string   `yaml:"name"`
+	Command      string   `yaml:"command"`
+	Args         []string `yaml:"args"`
+	Capabilities []string `yaml:"capabilities"`
+
+	// Extensions are the file extensions, e.g. .pcap, the extract
+	// capability reads
+	Extensions []string `yaml:"extensions"`
+
+	// Timeout is how long a request may take, e.g. 10s
+	Timeout string `yaml:"timeout"`
+
+	// AttributeMap is a YAML file mapping attribute names to friendly
+	// names, in the format of --attribute-map
+	AttributeMap string `yaml:"attribute_map"`
+
+	// Rules are the lint rules the plugin reports
+	Rules []Rule `yaml:"rules"`
+}
+
+// Rule is a lint rule of a plugin
+type Rule struct {
+	ID          string `yaml:"id"`
+	Severity    string `yaml:"severity"`
+	Spec        string `yaml:"spec"`
+	Description string `yaml:"description"`
+}
+
+// Plugin is a plugin found in the plugin directory
+type Plugin struct {
+	Manifest
+
+	// Path is the manifest file
+	Path string
+
+	command string
+	timeout time.Duration
+}
+
+// Request is sent to the plugin command on stdin
+type Request struct {
+	Version int    `json:"version"`
+	Method  string `json:"method"`
+
+	// XML is the decoded message, for lint and audit
+	XML string `json:"xml,omitempty"`
+
+	// Filename and Data are the file to extract from
+	Filename string `json:"filename,omitempty"`
+	Data     []byte `json:"data,omitempty"`
+}
+
+// Response is read from the plugin command's stdout. Error reports a
+// failure to handle the request.
+type Response struct {
+	Issues   []Issue         `json:"issues,omitempty"`
+	Findings []Finding       `json:"findings,omitempty"`
+	HAR      json.RawMessage `json:"har,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// Issue is a lint issue reported by a plugin, for one of its rules
+type Issue struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Finding is an audit finding reported by a plugin
+type Finding struct {
+	Check    string `json:"check"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+// Discover loads the plugins in dir, each a subdirectory holding its
+// manifest as plugin.yaml, in order of name. A missing directory holds no
+// plugins.
+func Discover(dir string) ([]*Plugin, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plugin directory: %w", err)
+	}
+
+	var plugins []*Plugin
+	names := map[string]string{}
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name(), ManifestName)
+		if !entry.IsDir() {
+			continue
+		}
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		p, err := Load(path)
+		if err != nil {
+			return nil, err
+		}
+		if other, ok := names[p.Name]; ok {
+			return nil, fmt.Errorf("plugins %s and %s are both named %q", other, p.Path, p.Name)
+		}
+		names[p.Name] = p.Path
+		plugins = append(plugins, p)
+	}
+	sort.Slice(plugins, func(i, j int) bool { return plugins[i].Name < plugins[j].Name })
+	return plugins, nil
+}
+
+// Load reads the plugin manifest at path. The command and attribute map
+// are resolved against the directory of the manifest.
+func Load(path string) (*Plugin, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plugin manifest: %w", err)
+	}
+	p := &Plugin{Path: path, timeout: DefaultTimeout}
+	if err := yaml.Unmarshal(data, &p.Manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse plugin manifest %s: %w", path, err)
+	}
+	if err := p.validate(); err != nil {
+		return nil, fmt.Errorf("plugin manifest %s: %w", path, err)
+	}
+
+	dir := filepath.Dir(path)
+	if p.Command != "" {
+		p.command = p.Command
+		// Bare names are looked up on $PATH
+		if !filepath.IsAbs(p.Command) && strings.ContainsAny(p.Command, `/\`) {
+			p.command = filepath.Join(dir, p.Command)
+		}
+	}
+	if p.AttributeMap != "" && !filepath.IsAbs(p.AttributeMap) {
+		p.AttributeMap = filepath.Join(dir, p.AttributeMap)
+	}
+	if p.Manifest.Timeout != "" {
+		p.timeout, _ = time.ParseDuration(p.Manifest.Timeout)
+	}
+	return p, nil
+}
+
+// validate checks the manifest for missing and invalid fields
+func (p *Plugin) validate() error {
+	if !validName.MatchString(p.Name) {
+		return fmt.Errorf("invalid name %q: use lowercase letters, digits and hyphens", p.Name)
+	}
+	for _, capability := range p.Capabilities {
+		switch capability {
+		case CapabilityLint, CapabilityAudit, CapabilityExtract:
+		default:
+			return fmt.Errorf("unknown capability %q: expected %s, %s or %s", capability, CapabilityLint, CapabilityAudit, CapabilityExtract)
+		}
+	}
+	if len(p.Capabilities) > 0 && p.Command == "" {
+		return errors.New("a command is required for capabilities")
+	}
+	if p.Has(CapabilityExtract) && len(p.Extensions) == 0 {
+		return errors.New("the extract capability requires extensions")
+	}
+	if p.Manifest.Timeout != "" {
+		if d, err := time.ParseDuration(p.Manifest.Timeout); err != nil || d <= 0 {
+			return fmt.Errorf("invalid timeout %q: expected a duration such as 10s", p.Manifest.Timeout)
+		}
+	}
+	ids := map[string]bool{}
+	for _, rule := range p.Rules {
+		if !validName.MatchString(rule.ID) {
+			return fmt.Errorf("invalid rule ID %q: use lowercase letters, digits and hyphens", rule.ID)
+		}
+		if ids[rule.ID] {
+			return fmt.Errorf("rule %q is declared twice", rule.ID)
+		}
+		ids[rule.ID] = true
+		if rule.Severity != saml.SeverityError && rule.Severity != saml.SeverityWarning {
+			return fmt.Errorf("rule %q: invalid severity %q: expected %s or %s", rule.ID, rule.Severity, saml.SeverityError, saml.SeverityWarning)
+		}
+	}
+	return nil
+}
+
+// Has reports whether the plugin declares a capability
+func (p *Plugin) Has(capability string) bool {
+	for _, c := range p.Capabilities {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
+
+// Reads reports whether the extract capability of the plugin reads the
+// file at path
+func (p *Plugin) Reads(path string) bool {
+	if !p.Has(CapabilityExtract) {
+		return false
+	}
+	for _, ext := range p.Extensions {
+		if strings.HasSuffix(strings.ToLower(path), strings.ToLower(ext)) {
+			return true
+		}
+	}
+	return false
+}
+
+// LintRules returns the rules of the plugin, with IDs prefixed by the
+// plugin name, e.g. acme/test-idp
+func (p *Plugin) LintRules() []saml.LintRule {
+	var rules []saml.LintRule
+	for _, rule := range p.Rules {
+		rules = append(rules, saml.LintRule{ID: p.Name + "/" + rule.ID, Severity: rule.Severity, Spec: rule.Spec, Description: rule.Description})
+	}
+	return rules
+}
+
+// Lint has the plugin check a decoded message against its rules
+func (p *Plugin) Lint(ctx context.Context, xmlData []byte) ([]saml.LintIssue, error) {
+	resp, err := p.call(ctx, Request{Method: CapabilityLint, XML: string(xmlData)})
+	if err != nil {
+		return nil, err
+	}
+	rules := map[string]Rule{}
+	for _, rule := range p.Rules {
+		rules[rule.ID] = rule
+	}
+
+	var issues []saml.LintIssue
+	for _, issue := range resp.Issues {
+		rule, ok := rules[issue.Rule]
+		if !ok {
+			return nil, fmt.Errorf("plugin %s reported rule %q, which its manifest does not declare", p.Name, issue.Rule)
+		}
+		issues = append(issues, saml.LintIssue{Rule: p.Name + "/" + rule.ID, Severity: rule.Severity, Spec: rule.Spec, Message: issue.Message})
+	}
+	return issues, nil
+}
+
+// Audit has the plugin audit a decoded message. Check names are prefixed
+// by the plugin name.
+func (p *Plugin) Audit(ctx context.Context, xmlData []byte) ([]saml.AuditFinding, error) {
+	resp, err := p.call(ctx, Request{Method: CapabilityAudit, XML: string(xmlData)})
+	if err != nil {
+		return nil, err
+	}
+	var findings []saml.AuditFinding
+	for _, f := range resp.Findings {
+		switch f.Severity {
+		case saml.SeverityHigh, saml.SeverityMedium, saml.SeverityLow:
+		default:
+			return nil, fmt.Errorf("plugin %s reported severity %q for %s: expected high, medium or low", p.Name, f.Severity, f.Check)
+		}
+		findings = append(findings, saml.AuditFinding{Check: p.Name + "/" + f.Check, Severity: f.Severity, Message: f.Message})
+	}
+	return findings, nil
+}
+
+// Extract has the plugin convert a file into a HAR document, for the SAML
+// messages to be extracted from
+func (p *Plugin) Extract(ctx context.Context, filename string, data []byte) ([]byte, error) {
+	resp, err := p.call(ctx, Request{Method: CapabilityExtract, Filename: filepath.Base(filename), Data: data})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.HAR) == 0 {
+		return nil, fmt.Errorf("plugin %s returned no HAR document", p.Name)
+	}
+	return resp.HAR, nil
+}
+
+// call runs the plugin command with a request and returns its response
+func (p *Plugin) call(ctx context.Context, req Request) (*Response, error) {
+	req.Version = ProtocolVersion
+	input, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, p.command, p.Args...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		switch {
+		case ctx.Err() == context.DeadlineExceeded:
+			return nil, fmt.Errorf("plugin %s timed out after %s", p.Name, p.timeout)
+		case strings.TrimSpace(stderr.String()) != "":
+			return nil, fmt.Errorf("plugin %s: %s", p.Name, strings.TrimSpace(stderr.String()))
+		}
+		return nil, fmt.Errorf("plugin %s: %w", p.Name, err)
+	}
+
+	var resp Response
+	if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+		return nil, fmt.Errorf("plugin %s returned an invalid response: %w", p.Name, err)
+	}
+	if resp.Error != "" {
+		return nil, fmt.Errorf("plugin %s: %s", p.Name, resp.Error)
+	}
+	return &resp, nil
+}
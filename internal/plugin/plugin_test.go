@@ -0,0 +1,138 @@
+package plugin
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testScript answers lint, audit and extract requests like a plugin would
+const testScript = `#!/bin/sh
+input=$(cat)
+case "$input" in
+*'"method":"lint"'*)
+  echo '{"issues":[{"rule":"test-idp","message":"the Issuer is a test IdP"}]}' ;;
+*'"method":"audit"'*)
+  echo '{"findings":[{"check":"shared-key","severity":"high","message":"signed with a shared key"}]}' ;;
+*'"method":"extract"'*)
+  echo '{"har":{"log":{"entries":[]}}}' ;;
+*)
+  echo "unexpected request: $input" >&2; exit 1 ;;
+esac
+`
+
+func writePlugin(t *testing.T, dir, manifest, script string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ManifestName), []byte(manifest), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "acme-saml"), []byte(script), 0755))
+}
+
+const testManifest = `name: acme
+command: ./acme-saml
+capabilities: [lint, audit, extract]
+extensions: [.acmelog]
+attribute_map: names.yaml
+rules:
+  - id: test-idp
+    severity: error
+    spec: ACME SSO policy 2.1
+    description: Production SPs only trust production IdPs
+`
+
+func TestDiscover(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "acme")
+	require.NoError(t, os.Mkdir(dir, 0755))
+	writePlugin(t, dir, testManifest, testScript)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "names.yaml"), []byte("urn:oid:1.2.3: badge\n"), 0644))
+	require.NoError(t, os.Mkdir(filepath.Join(root, "not-a-plugin"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "README.md"), []byte("not a plugin"), 0644))
+
+	plugins, err := Discover(root)
+	require.NoError(t, err)
+	require.Len(t, plugins, 1)
+	p := plugins[0]
+	assert.Equal(t, "acme", p.Name)
+	assert.Equal(t, filepath.Join(dir, "names.yaml"), p.AttributeMap)
+	assert.True(t, p.Has(CapabilityLint))
+	assert.True(t, p.Reads("/tmp/capture.ACMELOG"))
+	assert.False(t, p.Reads("session.har"))
+	assert.Equal(t, []saml.LintRule{{ID: "acme/test-idp", Severity: "error", Spec: "ACME SSO policy 2.1", Description: "Production SPs only trust production IdPs"}}, p.LintRules())
+
+	plugins, err = Discover(filepath.Join(root, "missing"))
+	require.NoError(t, err)
+	assert.Empty(t, plugins)
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		wantErr  string
+	}{
+		{"no name", "command: x\n", `invalid name ""`},
+		{"unknown capability", "name: acme\ncommand: x\ncapabilities: [decrypt]\n", `unknown capability "decrypt"`},
+		{"no command", "name: acme\ncapabilities: [lint]\n", "a command is required"},
+		{"no extensions", "name: acme\ncommand: x\ncapabilities: [extract]\n", "the extract capability requires extensions"},
+		{"invalid timeout", "name: acme\ncommand: x\ntimeout: soon\n", `invalid timeout "soon"`},
+		{"invalid severity", "name: acme\nrules:\n  - id: r\n    severity: high\n", `rule "r": invalid severity "high"`},
+		{"duplicate rule", "name: acme\nrules:\n  - {id: r, severity: error}\n  - {id: r, severity: error}\n", `rule "r" is declared twice`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "plugin.yaml")
+			require.NoError(t, os.WriteFile(path, []byte(tt.manifest), 0644))
+			_, err := Load(path)
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestPlugin_Calls(t *testing.T) {
+	dir := t.TempDir()
+	writePlugin(t, dir, testManifest, testScript)
+	p, err := Load(filepath.Join(dir, ManifestName))
+	require.NoError(t, err)
+
+	issues, err := p.Lint(context.Background(), []byte("<samlp:Response/>"))
+	require.NoError(t, err)
+	assert.Equal(t, []saml.LintIssue{{Rule: "acme/test-idp", Severity: "error", Spec: "ACME SSO policy 2.1", Message: "the Issuer is a test IdP"}}, issues)
+
+	findings, err := p.Audit(context.Background(), []byte("<samlp:Response/>"))
+	require.NoError(t, err)
+	assert.Equal(t, []saml.AuditFinding{{Check: "acme/shared-key", Severity: "high", Message: "signed with a shared key"}}, findings)
+
+	har, err := p.Extract(context.Background(), "capture.acmelog", []byte("raw"))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"log":{"entries":[]}}`, string(har))
+}
+
+func TestPlugin_Failures(t *testing.T) {
+	tests := []struct {
+		name    string
+		script  string
+		wantErr string
+	}{
+		{"stderr", "#!/bin/sh\ncat >/dev/null\necho 'license expired' >&2\nexit 2\n", "plugin acme: license expired"},
+		{"error member", "#!/bin/sh\ncat >/dev/null\necho '{\"error\":\"unsupported message\"}'\n", "plugin acme: unsupported message"},
+		{"invalid JSON", "#!/bin/sh\ncat >/dev/null\necho 'OK'\n", "plugin acme returned an invalid response"},
+		{"undeclared rule", "#!/bin/sh\ncat >/dev/null\necho '{\"issues\":[{\"rule\":\"other\",\"message\":\"x\"}]}'\n", `plugin acme reported rule "other", which its manifest does not declare`},
+		{"timeout", "#!/bin/sh\nexec sleep 5\n", "plugin acme timed out after 100ms"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writePlugin(t, dir, testManifest+"timeout: 100ms\n", tt.script)
+			p, err := Load(filepath.Join(dir, ManifestName))
+			require.NoError(t, err)
+
+			_, err = p.Lint(context.Background(), []byte("<samlp:Response/>"))
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
@@ -0,0 +1,357 @@
+// Package probe checks whether SAML endpoints such as an SP's Assertion
+// Consumer Service are reachable, to rule out endpoint misconfiguration
+// during SSO triage: DNS, connectivity, the TLS certificate, HTTP status and
+// redirect behavior.
+package probe
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gliwka/SAMLurai/internal/saml"
+)
+
+// Endpoint roles
+const (
+	RoleACS = "ACS"
+	RoleSLO = "SLO"
+)
+
+// Defaults for Options
+const (
+	DefaultTimeout       = 10 * time.Second
+	DefaultMaxRedirects  = 5
+	DefaultExpiryWarning = 30 * 24 * time.Hour
+)
+
+const (
+	bindingSOAP = "urn:oasis:names:tc:SAML:2.0:bindings:SOAP"
+
+	// maxBodySize bounds how much of each answer is read before the
+	// connection is reused
+	maxBodySize = 64 << 10
+)
+
+// Target is an endpoint to probe
+type Target struct {
+	Role    string `json:"role"`
+	Binding string `json:"binding,omitempty"`
+	URL     string `json:"url"`
+}
+
+// Hop is one request of a probe; a redirect leads to the next hop
+type Hop struct {
+	Method   string `json:"method"`
+	URL      string `json:"url"`
+	Status   int    `json:"status"`
+	Location string `json:"location,omitempty"`
+}
+
+// TLSInfo describes the certificate the endpoint presented
+type TLSInfo struct {
+	Version  string    `json:"version"`
+	Subject  string    `json:"subject"`
+	Issuer   string    `json:"issuer"`
+	DNSNames []string  `json:"dns_names,omitempty"`
+	NotAfter time.Time `json:"not_after"`
+	DaysLeft int       `json:"days_left"`
+
+	// HostnameMatch reports whether the certificate is valid for the host
+	// of the endpoint
+	HostnameMatch bool `json:"hostname_match"`
+
+	// Trusted reports whether the chain leads to a trusted root; expiry is
+	// judged separately
+	Trusted     bool   `json:"trusted"`
+	VerifyError string `json:"verify_error,omitempty"`
+}
+
+// Result is the outcome of probing one endpoint
+type Result struct {
+	Target
+
+	// Hops are the requests made, the first to the endpoint itself
+	Hops []Hop `json:"hops,omitempty"`
+
+	// Status is the status of the last hop
+	Status     int      `json:"status,omitempty"`
+	DurationMS float64  `json:"duration_ms"`
+	TLS        *TLSInfo `json:"tls,omitempty"`
+
+	// Problems would break SSO; Warnings are worth a look
+	Problems []string `json:"problems,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// OK reports whether the probe found no problems
+func (r *Result) OK() bool {
+	return len(r.Problems) == 0
+}
+
+// Options configures a probe
+type Options struct {
+	// Timeout bounds each probe; 0 means DefaultTimeout
+	Timeout time.Duration
+
+	// RootCAs verify TLS certificates; nil means the system roots
+	RootCAs *x509.CertPool
+
+	// Now is the current time for certificate checks; zero means time.Now
+	Now time.Time
+
+	// MaxRedirects is how many redirects are followed; 0 means
+	// DefaultMaxRedirects
+	MaxRedirects int
+
+	// ExpiryWarning is how long before expiry a certificate is flagged;
+	// 0 means DefaultExpiryWarning
+	ExpiryWarning time.Duration
+}
+
+func (o Options) withDefaults() Options {
+	if o.Timeout == 0 {
+		o.Timeout = DefaultTimeout
+	}
+	if o.Now.IsZero() {
+		o.Now = time.Now()
+	}
+	if o.MaxRedirects == 0 {
+		o.MaxRedirects = DefaultMaxRedirects
+	}
+	if o.ExpiryWarning == 0 {
+		o.ExpiryWarning = DefaultExpiryWarning
+	}
+	return o
+}
+
+// Probe sends the endpoint a request like the browser would for its
+// binding, without a SAML message, and follows redirects like a browser.
+// The certificate is verified separately from the connection, so an
+// untrusted or mismatched certificate is reported rather than failing the
+// probe.
+func Probe(ctx context.Context, target Target, opts Options) *Result {
+	opts = opts.withDefaults()
+	result := &Result{Target: target}
+
+	u, err := url.Parse(target.URL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		result.Problems = append(result.Problems, fmt.Sprintf("invalid endpoint URL %q", target.URL))
+		return result
+	}
+	if u.Scheme == "http" {
+		result.Warnings = append(result.Warnings, "served over plain HTTP: the SAML message and session cookies travel unencrypted")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// The certificate is verified by inspectTLS, to report what is wrong
+	// with it instead of only that the handshake failed
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	defer transport.CloseIdleConnections()
+	client := &http.Client{
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	start := time.Now()
+	defer func() {
+		result.DurationMS = float64(time.Since(start).Microseconds()) / 1000
+	}()
+
+	method := probeMethod(target.Binding)
+	for hop := 0; ; hop++ {
+		if hop > opts.MaxRedirects {
+			result.Problems = append(result.Problems, fmt.Sprintf("more than %d redirects", opts.MaxRedirects))
+			return result
+		}
+
+		resp, err := send(ctx, client, method, u, target.Binding)
+		if err != nil {
+			result.Problems = append(result.Problems, "unreachable: "+describeError(err))
+			return result
+		}
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxBodySize))
+		resp.Body.Close()
+
+		if hop == 0 && resp.TLS != nil {
+			result.TLS = inspectTLS(resp.TLS, u.Hostname(), opts, result)
+		}
+		result.Status = resp.StatusCode
+		current := Hop{Method: method, URL: u.String(), Status: resp.StatusCode}
+
+		location := resp.Header.Get("Location")
+		if !isRedirect(resp.StatusCode) || location == "" {
+			result.Hops = append(result.Hops, current)
+			judgeStatus(resp, result)
+			return result
+		}
+
+		next, err := u.Parse(location)
+		if err != nil {
+			result.Hops = append(result.Hops, current)
+			result.Problems = append(result.Problems, fmt.Sprintf("invalid redirect location %q", location))
+			return result
+		}
+		current.Location = next.String()
+		result.Hops = append(result.Hops, current)
+		method = judgeRedirect(method, resp.StatusCode, u, next, target, result)
+		u = next
+	}
+}
+
+// probeMethod returns the method a browser or SOAP client uses to deliver
+// a message with the binding
+func probeMethod(binding string) string {
+	switch binding {
+	case saml.BindingHTTPPost, saml.BindingSimpleSign, bindingSOAP:
+		return http.MethodPost
+	}
+	return http.MethodGet
+}
+
+// send makes one request. POSTs carry an empty form, or an empty SOAP body
+// for the SOAP binding.
+func send(ctx context.Context, client *http.Client, method string, u *url.URL, binding string) (*http.Response, error) {
+	var body io.Reader
+	if method == http.MethodPost {
+		body = strings.NewReader("")
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "samlurai-probe")
+	if method == http.MethodPost {
+		if binding == bindingSOAP {
+			req.Header.Set("Content-Type", "text/xml; charset=utf-8")
+		} else {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+	}
+	return client.Do(req)
+}
+
+func isRedirect(status int) bool {
+	switch status {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// judgeRedirect records what a redirect means for the binding and returns
+// the method the browser uses for the next hop. 301, 302 and 303 turn a
+// POST into a GET without its body, losing a POSTed SAML message.
+func judgeRedirect(method string, status int, from, to *url.URL, target Target, result *Result) string {
+	if from.Scheme == "https" && to.Scheme == "http" {
+		result.Problems = append(result.Problems, fmt.Sprintf("redirects from HTTPS to plain HTTP (%s)", to))
+	}
+	switch {
+	case method == http.MethodPost && status != http.StatusTemporaryRedirect && status != http.StatusPermanentRedirect:
+		result.Problems = append(result.Problems, fmt.Sprintf("POST redirected with %d to %s: browsers follow it with a GET and drop the SAML message", status, to))
+		return http.MethodGet
+	case len(result.Hops) == 1:
+		result.Warnings = append(result.Warnings, fmt.Sprintf("%s redirects with %d to %s: check that the target still handles %s messages", target.Role, status, to, target.Role))
+	}
+	return method
+}
+
+// judgeStatus records what the final status means. The probe carries no
+// SAML message, so errors the SP returns for a missing message are only
+// warnings, while statuses showing the path is not served are problems.
+func judgeStatus(resp *http.Response, result *Result) {
+	status := fmt.Sprintf("%d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+	switch code := resp.StatusCode; {
+	case code == http.StatusNotFound || code == http.StatusGone:
+		result.Problems = append(result.Problems, status+": the path is not served")
+	case code == http.StatusBadGateway || code == http.StatusServiceUnavailable || code == http.StatusGatewayTimeout:
+		result.Problems = append(result.Problems, status+": the server or a proxy in front of it is failing")
+	case code >= 500:
+		result.Warnings = append(result.Warnings, status+" to a request without a SAML message: check the server log if real messages fail too")
+	case code == http.StatusUnauthorized || code == http.StatusForbidden:
+		result.Warnings = append(result.Warnings, status+": an IP allow list, WAF rule or login in front of the endpoint may block browsers")
+	case isRedirect(code):
+		result.Warnings = append(result.Warnings, status+" without a Location header")
+	}
+}
+
+// inspectTLS describes the certificate of a connection and records the
+// problems with it
+func inspectTLS(state *tls.ConnectionState, host string, opts Options, result *Result) *TLSInfo {
+	if len(state.PeerCertificates) == 0 {
+		return nil
+	}
+	leaf := state.PeerCertificates[0]
+	info := &TLSInfo{
+		Version:       tls.VersionName(state.Version),
+		Subject:       leaf.Subject.String(),
+		Issuer:        leaf.Issuer.String(),
+		DNSNames:      leaf.DNSNames,
+		NotAfter:      leaf.NotAfter,
+		DaysLeft:      int(leaf.NotAfter.Sub(opts.Now).Hours() / 24),
+		HostnameMatch: leaf.VerifyHostname(host) == nil,
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range state.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         opts.RootCAs,
+		Intermediates: intermediates,
+		CurrentTime:   opts.Now,
+	})
+	var invalid x509.CertificateInvalidError
+	switch {
+	case err == nil:
+		info.Trusted = true
+	case errors.As(err, &invalid) && invalid.Reason == x509.Expired:
+		// Reported as expiry below; the chain itself may be fine
+		info.Trusted = true
+	default:
+		info.VerifyError = err.Error()
+		result.Problems = append(result.Problems, "TLS certificate is not trusted: "+err.Error())
+	}
+
+	switch {
+	case opts.Now.After(leaf.NotAfter):
+		result.Problems = append(result.Problems, fmt.Sprintf("TLS certificate expired on %s", leaf.NotAfter.Format(time.DateOnly)))
+	case opts.Now.Before(leaf.NotBefore):
+		result.Problems = append(result.Problems, fmt.Sprintf("TLS certificate is not valid before %s", leaf.NotBefore.Format(time.DateOnly)))
+	case leaf.NotAfter.Sub(opts.Now) < opts.ExpiryWarning:
+		result.Warnings = append(result.Warnings, fmt.Sprintf("TLS certificate expires in %d days, on %s", info.DaysLeft, leaf.NotAfter.Format(time.DateOnly)))
+	}
+	if !info.HostnameMatch {
+		names := leaf.DNSNames
+		if len(names) == 0 && leaf.Subject.CommonName != "" {
+			names = []string{leaf.Subject.CommonName}
+		}
+		result.Problems = append(result.Problems, fmt.Sprintf("TLS certificate is not valid for %s (valid for: %s)", host, strings.Join(names, ", ")))
+	}
+	return info
+}
+
+// describeError strips the method and URL that net/http adds to errors
+func describeError(err error) string {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "timed out"
+	}
+	return err.Error()
+}
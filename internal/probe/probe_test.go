@@ -0,0 +1,129 @@
+package probe
+
+import (
+	"context"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func acsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/acs":
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+	case "/moved":
+		http.Redirect(w, r, "/acs", http.StatusFound)
+	case "/moved-keep-method":
+		http.Redirect(w, r, "/acs", http.StatusTemporaryRedirect)
+	case "/loop":
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	case "/down":
+		w.WriteHeader(http.StatusServiceUnavailable)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func trusting(srv *httptest.Server) Options {
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.Certificate())
+	return Options{RootCAs: roots}
+}
+
+func TestProbe_TLS(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(acsHandler))
+	defer srv.Close()
+
+	result := Probe(context.Background(), Target{Role: RoleACS, Binding: saml.BindingHTTPPost, URL: srv.URL + "/acs"}, trusting(srv))
+	assert.True(t, result.OK(), "problems: %v", result.Problems)
+	assert.Empty(t, result.Warnings)
+	assert.Equal(t, []Hop{{Method: http.MethodPost, URL: srv.URL + "/acs", Status: http.StatusBadRequest}}, result.Hops)
+	require.NotNil(t, result.TLS)
+	assert.True(t, result.TLS.Trusted)
+	assert.True(t, result.TLS.HostnameMatch)
+	assert.Positive(t, result.TLS.DaysLeft)
+
+	// Without the test CA the certificate is reported, not the connection
+	result = Probe(context.Background(), Target{Role: RoleACS, Binding: saml.BindingHTTPPost, URL: srv.URL + "/acs"}, Options{})
+	require.Len(t, result.Problems, 1)
+	assert.Contains(t, result.Problems[0], "not trusted")
+	assert.Equal(t, http.StatusBadRequest, result.Status)
+}
+
+func TestProbe_CertificateExpiry(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(acsHandler))
+	defer srv.Close()
+	notAfter := srv.Certificate().NotAfter
+
+	opts := trusting(srv)
+	opts.Now = notAfter.Add(-10 * 24 * time.Hour)
+	result := Probe(context.Background(), Target{Role: RoleACS, Binding: saml.BindingHTTPPost, URL: srv.URL + "/acs"}, opts)
+	assert.True(t, result.OK(), "problems: %v", result.Problems)
+	require.Len(t, result.Warnings, 1)
+	assert.Contains(t, result.Warnings[0], "expires in 10 days")
+
+	opts.Now = notAfter.Add(time.Hour)
+	result = Probe(context.Background(), Target{Role: RoleACS, Binding: saml.BindingHTTPPost, URL: srv.URL + "/acs"}, opts)
+	require.Len(t, result.Problems, 1)
+	assert.Contains(t, result.Problems[0], "expired on")
+}
+
+func TestProbe_Status(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(acsHandler))
+	defer srv.Close()
+
+	tests := []struct {
+		name    string
+		binding string
+		path    string
+		problem string
+		hops    int
+	}{
+		{"POST endpoint", saml.BindingHTTPPost, "/acs", "", 1},
+		{"GET of a POST-only endpoint", saml.BindingHTTPRedirect, "/acs", "", 1},
+		{"POST redirected to GET", saml.BindingHTTPPost, "/moved", "POST redirected with 302", 2},
+		{"POST redirected with 307", saml.BindingHTTPPost, "/moved-keep-method", "", 2},
+		{"not found", saml.BindingHTTPPost, "/missing", "404 Not Found", 1},
+		{"service unavailable", saml.BindingHTTPPost, "/down", "503 Service Unavailable", 1},
+		{"redirect loop", saml.BindingHTTPRedirect, "/loop", "more than 5 redirects", 6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := Probe(context.Background(), Target{Role: RoleACS, Binding: tt.binding, URL: srv.URL + tt.path}, Options{})
+			if tt.problem == "" {
+				assert.True(t, result.OK(), "problems: %v", result.Problems)
+			} else {
+				assert.Contains(t, strings.Join(result.Problems, "\n"), tt.problem)
+			}
+			assert.Len(t, result.Hops, tt.hops)
+			assert.Contains(t, result.Warnings, "served over plain HTTP: the SAML message and session cookies travel unencrypted")
+			assert.Nil(t, result.TLS)
+		})
+	}
+}
+
+func TestProbe_Unreachable(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(acsHandler))
+	url := srv.URL
+	srv.Close()
+
+	result := Probe(context.Background(), Target{Role: RoleSLO, URL: url + "/slo"}, Options{})
+	require.Len(t, result.Problems, 1)
+	assert.True(t, strings.HasPrefix(result.Problems[0], "unreachable: "), result.Problems[0])
+	assert.Empty(t, result.Hops)
+
+	result = Probe(context.Background(), Target{Role: RoleACS, URL: "ftp://sp.example.com/acs"}, Options{})
+	assert.Equal(t, []string{`invalid endpoint URL "ftp://sp.example.com/acs"`}, result.Problems)
+}
@@ -0,0 +1,230 @@
+package probe
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gliwka/SAMLurai/internal/saml"
+)
+
+// Kinds of SAML certificates compared with TLS certificates
+const (
+	// CertMetadata is a signing certificate published in IdP metadata
+	CertMetadata = "metadata"
+
+	// CertConfigured is the certificate the SP was configured to trust
+	CertConfigured = "configured"
+
+	// CertSignature is a certificate in the KeyInfo of a message signature
+	CertSignature = "signature"
+)
+
+// SAMLCertificate is a certificate in SAML use and where it was found
+type SAMLCertificate struct {
+	Certificate *x509.Certificate
+	Kind        string
+
+	// Source describes where the certificate was found, e.g. "IdP
+	// metadata" or "signature of message 2"
+	Source string
+}
+
+// TLSComparison is the comparison of the TLS certificate chain an endpoint
+// serves with the SAML certificates
+type TLSComparison struct {
+	URL   string                  `json:"url"`
+	Chain []*saml.CertificateInfo `json:"chain,omitempty"`
+
+	Problems []string `json:"problems,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// OK reports whether the comparison found no problems
+func (c *TLSComparison) OK() bool {
+	return len(c.Problems) == 0
+}
+
+// FetchTLSChain connects to the host of an https URL and returns the
+// certificate chain it serves, leaf first. The chain is not verified.
+func FetchTLSChain(ctx context.Context, rawURL string, timeout time.Duration) ([]*x509.Certificate, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid URL %q", rawURL)
+	}
+	if u.Scheme != "https" {
+		return nil, fmt.Errorf("%s is not served over HTTPS", rawURL)
+	}
+	port := u.Port()
+	if port == "" {
+		port = "443"
+	}
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+
+	dialer := &tls.Dialer{
+		NetDialer: &net.Dialer{Timeout: timeout},
+		// Only the certificates are wanted, whether or not they verify
+		Config: &tls.Config{ServerName: u.Hostname(), InsecureSkipVerify: true},
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(u.Hostname(), port))
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, errors.New("timed out")
+		}
+		return nil, err
+	}
+	defer conn.Close()
+	return conn.(*tls.Conn).ConnectionState().PeerCertificates, nil
+}
+
+// CompareTLS compares the TLS chain served at url with the SAML
+// certificates. Flagged are a TLS certificate configured or published
+// where the SAML signing certificate belongs, the CA certificate of the
+// TLS chain configured as signing certificate, signing with the TLS
+// certificate and key pairs shared between TLS and SAML.
+func CompareTLS(url string, chain []*x509.Certificate, certs []SAMLCertificate) *TLSComparison {
+	result := &TLSComparison{URL: url}
+	if len(chain) == 0 {
+		result.Problems = append(result.Problems, "no TLS certificate was served")
+		return result
+	}
+	for _, cert := range chain {
+		result.Chain = append(result.Chain, saml.NewCertificateInfo(cert))
+	}
+	leaf := chain[0]
+	host := hostOf(url)
+
+	// What the IdP actually signs with: the certificates of the message
+	// signatures if there are any, otherwise those of the metadata
+	signing := certsOfKind(certs, CertSignature)
+	if len(signing) == 0 {
+		signing = certsOfKind(certs, CertMetadata)
+	}
+
+	for _, group := range groupCertificates(certs) {
+		cert := group[0].Certificate
+		sources := describeSources(group)
+		switch {
+		case cert.Equal(leaf):
+			if len(signing) > 0 && !containsCert(signing, cert) {
+				result.Problems = append(result.Problems, fmt.Sprintf("%s is the TLS certificate of %s (%s), not the SAML signing certificate, which is %s",
+					sources, host, describeCert(cert), describeCerts(signing)))
+			} else {
+				result.Warnings = append(result.Warnings, fmt.Sprintf("%s is the TLS certificate of %s (%s): SSO breaks at every TLS certificate renewal unless each SP is updated in time; sign with a dedicated certificate",
+					sources, host, describeCert(cert)))
+			}
+		case containsCert(chain[1:], cert):
+			result.Problems = append(result.Problems, fmt.Sprintf("%s is a CA certificate of the TLS chain of %s (%s), not a SAML signing certificate",
+				sources, host, describeCert(cert)))
+		case samePublicKey(cert, leaf):
+			result.Warnings = append(result.Warnings, fmt.Sprintf("%s (%s) shares its key pair with the TLS certificate of %s: compromising either compromises both",
+				sources, describeCert(cert), host))
+		}
+	}
+	return result
+}
+
+// groupCertificates groups certificates by identity, in order of first
+// appearance
+func groupCertificates(certs []SAMLCertificate) [][]SAMLCertificate {
+	var groups [][]SAMLCertificate
+	for _, cert := range certs {
+		found := false
+		for i, group := range groups {
+			if group[0].Certificate.Equal(cert.Certificate) {
+				groups[i] = append(groups[i], cert)
+				found = true
+				break
+			}
+		}
+		if !found {
+			groups = append(groups, []SAMLCertificate{cert})
+		}
+	}
+	return groups
+}
+
+func certsOfKind(certs []SAMLCertificate, kind string) []*x509.Certificate {
+	var matching []*x509.Certificate
+	for _, cert := range certs {
+		if cert.Kind == kind && !containsCert(matching, cert.Certificate) {
+			matching = append(matching, cert.Certificate)
+		}
+	}
+	return matching
+}
+
+func containsCert(certs []*x509.Certificate, cert *x509.Certificate) bool {
+	for _, c := range certs {
+		if c.Equal(cert) {
+			return true
+		}
+	}
+	return false
+}
+
+func samePublicKey(a, b *x509.Certificate) bool {
+	return bytes.Equal(a.RawSubjectPublicKeyInfo, b.RawSubjectPublicKeyInfo)
+}
+
+// describeSources names where a certificate was found, e.g. "the
+// certificate in IdP metadata and the signature of message 1"
+func describeSources(group []SAMLCertificate) string {
+	var sources []string
+	for _, cert := range group {
+		if !containsString(sources, cert.Source) {
+			sources = append(sources, cert.Source)
+		}
+	}
+	return "the certificate in " + strings.Join(sources, " and ")
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// describeCert identifies a certificate by subject and the start of its
+// SHA-256 fingerprint
+func describeCert(cert *x509.Certificate) string {
+	name := cert.Subject.CommonName
+	if name == "" {
+		name = cert.Subject.String()
+	}
+	fingerprint := saml.Fingerprint(cert.Raw, func(data []byte) []byte {
+		sum := sha256.Sum256(data)
+		return sum[:]
+	})
+	return fmt.Sprintf("%s, SHA-256 %s…", name, fingerprint[:23])
+}
+
+func describeCerts(certs []*x509.Certificate) string {
+	descriptions := make([]string, len(certs))
+	for i, cert := range certs {
+		descriptions[i] = describeCert(cert)
+	}
+	return strings.Join(descriptions, "; ")
+}
+
+func hostOf(rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return rawURL
+}
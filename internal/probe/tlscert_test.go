@@ -0,0 +1,107 @@
+package probe
+
+import (
+	"context"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchTLSChain(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(acsHandler))
+	defer srv.Close()
+
+	chain, err := FetchTLSChain(context.Background(), srv.URL+"/sso", 0)
+	require.NoError(t, err)
+	require.NotEmpty(t, chain)
+	assert.True(t, chain[0].Equal(srv.Certificate()))
+
+	_, err = FetchTLSChain(context.Background(), "http://idp.example.com/sso", 0)
+	assert.ErrorContains(t, err, "not served over HTTPS")
+}
+
+func TestCompareTLS(t *testing.T) {
+	newCert := func(name string) *x509.Certificate {
+		_, cert, err := saml.NewSelfSignedKey(name)
+		require.NoError(t, err)
+		return cert
+	}
+	tlsLeaf, tlsCA := newCert("idp.example.com"), newCert("Example CA")
+	signing := newCert("idp-signing")
+	chain := []*x509.Certificate{tlsLeaf, tlsCA}
+
+	tests := []struct {
+		name     string
+		certs    []SAMLCertificate
+		problems int
+		warnings int
+	}{
+		{
+			name: "distinct certificates",
+			certs: []SAMLCertificate{
+				{Certificate: signing, Kind: CertMetadata, Source: "IdP metadata"},
+				{Certificate: signing, Kind: CertConfigured, Source: "--idp-cert"},
+			},
+		},
+		{
+			name: "SP configured with the TLS certificate",
+			certs: []SAMLCertificate{
+				{Certificate: signing, Kind: CertMetadata, Source: "IdP metadata"},
+				{Certificate: tlsLeaf, Kind: CertConfigured, Source: "--idp-cert"},
+			},
+			problems: 1,
+		},
+		{
+			name: "metadata lists the TLS certificate, messages signed with another",
+			certs: []SAMLCertificate{
+				{Certificate: tlsLeaf, Kind: CertMetadata, Source: "IdP metadata"},
+				{Certificate: signing, Kind: CertSignature, Source: "the signature of message 1"},
+			},
+			problems: 1,
+		},
+		{
+			name: "IdP signs with the TLS certificate",
+			certs: []SAMLCertificate{
+				{Certificate: tlsLeaf, Kind: CertMetadata, Source: "IdP metadata"},
+				{Certificate: tlsLeaf, Kind: CertSignature, Source: "the signature of message 1"},
+			},
+			warnings: 1,
+		},
+		{
+			name: "SP configured with the TLS CA",
+			certs: []SAMLCertificate{
+				{Certificate: tlsCA, Kind: CertConfigured, Source: "--idp-cert"},
+			},
+			problems: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := CompareTLS("https://idp.example.com/sso", chain, tt.certs)
+			assert.Len(t, result.Problems, tt.problems, "problems: %v", result.Problems)
+			assert.Len(t, result.Warnings, tt.warnings, "warnings: %v", result.Warnings)
+			assert.Len(t, result.Chain, 2)
+		})
+	}
+}
+
+func TestCompareTLS_Message(t *testing.T) {
+	_, tlsLeaf, err := saml.NewSelfSignedKey("idp.example.com")
+	require.NoError(t, err)
+	_, signing, err := saml.NewSelfSignedKey("idp-signing")
+	require.NoError(t, err)
+
+	result := CompareTLS("https://idp.example.com/sso", []*x509.Certificate{tlsLeaf}, []SAMLCertificate{
+		{Certificate: signing, Kind: CertMetadata, Source: "IdP metadata"},
+		{Certificate: tlsLeaf, Kind: CertConfigured, Source: "--idp-cert"},
+	})
+	require.Len(t, result.Problems, 1)
+	assert.Contains(t, result.Problems[0], "the certificate in --idp-cert is the TLS certificate of idp.example.com (idp.example.com, SHA-256 ")
+	assert.Contains(t, result.Problems[0], "not the SAML signing certificate, which is idp-signing, SHA-256 ")
+}
@@ -0,0 +1,177 @@
+package redact
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/beevik/etree"
+	"github.com/gliwka/SAMLurai/internal/saml"
+)
+
+// Mask replaces every redacted value
+const Mask = "REDACTED"
+
+// identityElements are elements whose text identifies the subject or
+// their session
+var identityElements = map[string]bool{
+	"NameID":       true,
+	"SessionIndex": true,
+}
+
+// secretElements are elements of signatures and key information, which
+// are always masked
+var secretElements = map[string]bool{
+	"SignatureValue":  true,
+	"DigestValue":     true,
+	"X509Certificate": true,
+}
+
+// identityAttributes are the XML attributes identifying the subject or
+// their session, by element
+var identityAttributes = map[string][]string{
+	"AuthnStatement":          {"SessionIndex"},
+	"SubjectConfirmationData": {"Address"},
+	"SubjectLocality":         {"Address", "DNSName"},
+}
+
+// Redactor masks the sensitive values of SAML messages while keeping their
+// structure, so captures can be shared in bug reports
+type Redactor struct {
+	keep map[string]bool
+
+	// pseudonym replaces identifying values; nil masks them
+	pseudonym func(value string) string
+}
+
+// New returns a Redactor that leaves the values of the attributes in keep,
+// matched by Name or FriendlyName, as they are
+func New(keep []string) *Redactor {
+	r := &Redactor{keep: make(map[string]bool)}
+	for _, name := range keep {
+		r.keep[strings.ToLower(name)] = true
+	}
+	return r
+}
+
+// NewAnonymizer returns a Redactor that replaces NameIDs, attribute values,
+// session indexes and addresses with pseudonyms derived from an HMAC of the
+// value under secret. Equal values get equal pseudonyms, so the same
+// subject can still be followed across messages. Signature values are
+// masked as with New.
+func NewAnonymizer(secret []byte, keep []string) *Redactor {
+	r := New(keep)
+	r.pseudonym = func(value string) string {
+		return Pseudonym(secret, value)
+	}
+	return r
+}
+
+// Pseudonym derives the pseudonym of a value under secret. Email addresses
+// keep their shape, so format checks still apply to them.
+func Pseudonym(secret []byte, value string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(strings.TrimSpace(value)))
+	pseudonym := "anon-" + hex.EncodeToString(mac.Sum(nil))[:16]
+	if strings.Contains(value, "@") {
+		pseudonym += "@anonymized.invalid"
+	}
+	return pseudonym
+}
+
+// replace returns what an identifying value is replaced with
+func (r *Redactor) replace(value string) string {
+	if r.pseudonym == nil {
+		return Mask
+	}
+	return r.pseudonym(value)
+}
+
+// XML returns the document with NameIDs, attribute values, session
+// indexes and subject addresses masked or pseudonymized, and signature and
+// certificate values masked. Signatures no longer verify afterwards.
+func (r *Redactor) XML(xmlData []byte) ([]byte, error) {
+	doc, err := saml.ReadDocument(xmlData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse XML: %w", err)
+	}
+	if doc.Root() == nil {
+		return nil, fmt.Errorf("failed to parse XML: no root element")
+	}
+	r.redactElement(doc.Root())
+	return doc.WriteToBytes()
+}
+
+// Extracted returns a copy of an extracted message with its decoded XML
+// redacted and its raw value encoded again from it. A message sent in the
+// URL, as with the HTTP-Redirect binding, is masked there.
+func (r *Redactor) Extracted(extracted saml.ExtractedSAML) (saml.ExtractedSAML, error) {
+	redacted, err := r.XML(extracted.DecodedXML)
+	if err != nil {
+		return extracted, err
+	}
+	extracted.DecodedXML = redacted
+	extracted.RawValue = base64.StdEncoding.EncodeToString(redacted)
+	extracted.WasDeflated = false
+
+	if u, err := url.Parse(extracted.URL); err == nil && extracted.ParameterName != "" {
+		if query := u.Query(); query.Has(extracted.ParameterName) {
+			query.Set(extracted.ParameterName, Mask)
+			u.RawQuery = query.Encode()
+			extracted.URL = u.String()
+		}
+	}
+	return extracted, nil
+}
+
+func (r *Redactor) redactElement(el *etree.Element) {
+	if text := el.Text(); strings.TrimSpace(text) != "" {
+		switch {
+		case identityElements[el.Tag]:
+			el.SetText(r.replace(text))
+		case secretElements[el.Tag]:
+			el.SetText(Mask)
+		}
+	}
+	for _, name := range identityAttributes[el.Tag] {
+		if attr := el.SelectAttr(name); attr != nil {
+			attr.Value = r.replace(attr.Value)
+		}
+	}
+
+	if el.Tag == "Attribute" && r.kept(el) {
+		return
+	}
+	for _, child := range el.ChildElements() {
+		if child.Tag == "AttributeValue" {
+			r.redactValue(child)
+			continue
+		}
+		r.redactElement(child)
+	}
+}
+
+// redactValue masks an attribute value, including every text of a
+// structured value such as a NameID or XML content
+func (r *Redactor) redactValue(value *etree.Element) {
+	if text := value.Text(); strings.TrimSpace(text) != "" {
+		value.SetText(r.replace(text))
+	}
+	for _, child := range value.ChildElements() {
+		r.redactValue(child)
+	}
+}
+
+// kept reports whether the values of an Attribute element are allowlisted
+func (r *Redactor) kept(attribute *etree.Element) bool {
+	for _, name := range []string{"Name", "FriendlyName"} {
+		if value := attribute.SelectAttrValue(name, ""); value != "" && r.keep[strings.ToLower(value)] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+package redact
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func loadResponse(t *testing.T) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("..", "..", "testdata", "fixtures", "assertions", "response.xml"))
+	require.NoError(t, err)
+	return data
+}
+
+func TestRedactor_XML(t *testing.T) {
+	redacted, err := New(nil).XML(loadResponse(t))
+	require.NoError(t, err)
+
+	for _, leaked := range []string{"user@example.com", "John", "Doe", "admins", "_session123"} {
+		assert.NotContains(t, string(redacted), leaked)
+	}
+
+	// The structure and non-identifying values are kept
+	info, err := saml.NewParser().Parse(redacted)
+	require.NoError(t, err)
+	assert.Equal(t, "https://idp.example.com", info.Issuer)
+	assert.Equal(t, "_response123", info.ID)
+	require.NotNil(t, info.Assertion)
+	assert.Equal(t, Mask, info.Assertion.Subject.NameID)
+	assert.Equal(t, "urn:oasis:names:tc:SAML:2.0:nameid-format:emailAddress", info.Assertion.Subject.NameIDFormat)
+	assert.Len(t, info.Assertion.Attributes, 4)
+}
+
+func TestRedactor_XML_KeepAttributes(t *testing.T) {
+	redacted, err := New([]string{"groups", "first name"}).XML(loadResponse(t))
+	require.NoError(t, err)
+
+	assert.Contains(t, string(redacted), ">admins<")
+	assert.Contains(t, string(redacted), ">John<")
+	assert.NotContains(t, string(redacted), "Doe")
+	assert.NotContains(t, string(redacted), "user@example.com")
+}
+
+func TestRedactor_XML_Signature(t *testing.T) {
+	signed := `<samlp:LogoutRequest xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion">
+		<ds:Signature xmlns:ds="http://www.w3.org/2000/09/xmldsig#">
+			<ds:SignedInfo><ds:Reference><ds:DigestValue>ZGlnZXN0</ds:DigestValue></ds:Reference></ds:SignedInfo>
+			<ds:SignatureValue>c2lnbmF0dXJl</ds:SignatureValue>
+			<ds:KeyInfo><ds:X509Data><ds:X509Certificate>Y2VydA==</ds:X509Certificate></ds:X509Data></ds:KeyInfo>
+		</ds:Signature>
+		<saml:NameID>jdoe</saml:NameID>
+		<samlp:SessionIndex>_abc</samlp:SessionIndex>
+	</samlp:LogoutRequest>`
+
+	redacted, err := New(nil).XML([]byte(signed))
+	require.NoError(t, err)
+	for _, leaked := range []string{"ZGlnZXN0", "c2lnbmF0dXJl", "Y2VydA==", "jdoe", "_abc"} {
+		assert.NotContains(t, string(redacted), leaked)
+	}
+	assert.Contains(t, string(redacted), "<ds:SignatureValue>REDACTED</ds:SignatureValue>")
+}
+
+func TestRedactor_Extracted(t *testing.T) {
+	extracted := saml.ExtractedSAML{Index: 2, Type: "Response", DecodedXML: loadResponse(t), RawValue: "original", WasDeflated: true}
+
+	redacted, err := New(nil).Extracted(extracted)
+	require.NoError(t, err)
+	assert.Equal(t, 2, redacted.Index)
+	assert.NotContains(t, string(redacted.DecodedXML), "user@example.com")
+	decoded, err := base64.StdEncoding.DecodeString(redacted.RawValue)
+	require.NoError(t, err)
+	assert.Equal(t, redacted.DecodedXML, decoded)
+	assert.False(t, redacted.WasDeflated)
+
+	// Messages sent in the URL are masked there too
+	extracted.URL = "https://idp.example.com/slo?SAMLRequest=fZBBa8&RelayState=home"
+	extracted.ParameterName = "SAMLRequest"
+	redacted, err = New(nil).Extracted(extracted)
+	require.NoError(t, err)
+	assert.Equal(t, "https://idp.example.com/slo?RelayState=home&SAMLRequest=REDACTED", redacted.URL)
+
+	_, err = New(nil).Extracted(saml.ExtractedSAML{DecodedXML: []byte("not xml")})
+	assert.Error(t, err)
+}
+
+func TestRedactor_Anonymize(t *testing.T) {
+	secret := []byte("secret")
+	anonymized, err := NewAnonymizer(secret, nil).XML(loadResponse(t))
+	require.NoError(t, err)
+
+	info, err := saml.NewParser().Parse(anonymized)
+	require.NoError(t, err)
+	nameID := info.Assertion.Subject.NameID
+	assert.Regexp(t, `^anon-[0-9a-f]{16}@anonymized\.invalid$`, nameID)
+	assert.NotContains(t, string(anonymized), "user@example.com")
+
+	// The NameID and the email attribute carry the same value, so they get
+	// the same pseudonym
+	var email, groups []string
+	for _, attr := range info.Assertion.Attributes {
+		switch attr.Name {
+		case "email":
+			email = attr.Values
+		case "groups":
+			groups = attr.Values
+		}
+	}
+	assert.Equal(t, []string{nameID}, email)
+	assert.Equal(t, []string{Pseudonym(secret, "admins"), Pseudonym(secret, "users")}, groups)
+	assert.Equal(t, Pseudonym(secret, "_session123"), info.Assertion.AuthnStatement.SessionIndex)
+
+	// Pseudonyms are stable for a secret and differ between secrets
+	again, err := NewAnonymizer(secret, nil).XML(loadResponse(t))
+	require.NoError(t, err)
+	assert.Equal(t, anonymized, again)
+	assert.NotEqual(t, Pseudonym(secret, "jdoe"), Pseudonym([]byte("other"), "jdoe"))
+	assert.Equal(t, Pseudonym(secret, "jdoe"), Pseudonym(secret, " jdoe\n"))
+}
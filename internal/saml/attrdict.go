@@ -0,0 +1,172 @@
+package saml
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// legacyAttributePrefix names attributes in the SAML 1.1 style of Shibboleth
+// and others, followed by the LDAP name
+const legacyAttributePrefix = "urn:mace:dir:attribute-def:"
+
+// knownAttributes maps attribute names that are not readable on their own,
+// OIDs and claim URIs, to their common names
+var knownAttributes = map[string]string{
+	// LDAP: inetOrgPerson, organizationalPerson and related schemas
+	"urn:oid:0.9.2342.19200300.100.1.1":  "uid",
+	"urn:oid:0.9.2342.19200300.100.1.3":  "mail",
+	"urn:oid:0.9.2342.19200300.100.1.41": "mobile",
+	"urn:oid:0.9.2342.19200300.100.1.43": "co",
+	"urn:oid:1.2.840.113549.1.9.1":       "emailAddress",
+	"urn:oid:1.3.6.1.1.1.1.0":            "uidNumber",
+	"urn:oid:1.3.6.1.1.1.1.1":            "gidNumber",
+	"urn:oid:1.3.6.1.4.1.250.1.57":       "labeledURI",
+	"urn:oid:2.16.840.1.113730.3.1.3":    "employeeNumber",
+	"urn:oid:2.16.840.1.113730.3.1.4":    "employeeType",
+	"urn:oid:2.16.840.1.113730.3.1.39":   "preferredLanguage",
+	"urn:oid:2.16.840.1.113730.3.1.241":  "displayName",
+	"urn:oid:2.5.4.3":                    "cn",
+	"urn:oid:2.5.4.4":                    "sn",
+	"urn:oid:2.5.4.5":                    "serialNumber",
+	"urn:oid:2.5.4.6":                    "c",
+	"urn:oid:2.5.4.7":                    "l",
+	"urn:oid:2.5.4.8":                    "st",
+	"urn:oid:2.5.4.9":                    "street",
+	"urn:oid:2.5.4.10":                   "o",
+	"urn:oid:2.5.4.11":                   "ou",
+	"urn:oid:2.5.4.12":                   "title",
+	"urn:oid:2.5.4.13":                   "description",
+	"urn:oid:2.5.4.16":                   "postalAddress",
+	"urn:oid:2.5.4.17":                   "postalCode",
+	"urn:oid:2.5.4.20":                   "telephoneNumber",
+	"urn:oid:2.5.4.42":                   "givenName",
+	"urn:oid:2.5.4.43":                   "initials",
+
+	// Active Directory
+	"urn:oid:1.2.840.113556.1.2.102":  "memberOf",
+	"urn:oid:1.2.840.113556.1.4.221":  "sAMAccountName",
+	"urn:oid:1.2.840.113556.1.4.656":  "userPrincipalName",
+	"urn:oid:1.2.840.113556.1.4.2":    "objectGUID",
+	"urn:oid:1.2.840.113556.1.4.146":  "objectSid",
+	"urn:oid:1.2.840.113556.1.2.13":   "displayNamePrintable",
+	"urn:oid:1.2.840.113556.1.4.1411": "msDS-PrincipalName",
+
+	// eduPerson, SCHAC and the SAML subject identifier attributes
+	"urn:oid:1.3.6.1.4.1.5923.1.1.1.1":                         "eduPersonAffiliation",
+	"urn:oid:1.3.6.1.4.1.5923.1.1.1.2":                         "eduPersonNickname",
+	"urn:oid:1.3.6.1.4.1.5923.1.1.1.3":                         "eduPersonOrgDN",
+	"urn:oid:1.3.6.1.4.1.5923.1.1.1.4":                         "eduPersonOrgUnitDN",
+	"urn:oid:1.3.6.1.4.1.5923.1.1.1.5":                         "eduPersonPrimaryAffiliation",
+	"urn:oid:1.3.6.1.4.1.5923.1.1.1.6":                         "eduPersonPrincipalName",
+	"urn:oid:1.3.6.1.4.1.5923.1.1.1.7":                         "eduPersonEntitlement",
+	"urn:oid:1.3.6.1.4.1.5923.1.1.1.8":                         "eduPersonPrimaryOrgUnitDN",
+	"urn:oid:1.3.6.1.4.1.5923.1.1.1.9":                         "eduPersonScopedAffiliation",
+	"urn:oid:1.3.6.1.4.1.5923.1.1.1.10":                        "eduPersonTargetedID",
+	"urn:oid:1.3.6.1.4.1.5923.1.1.1.11":                        "eduPersonAssurance",
+	"urn:oid:1.3.6.1.4.1.5923.1.1.1.12":                        "eduPersonPrincipalNamePrior",
+	"urn:oid:1.3.6.1.4.1.5923.1.1.1.13":                        "eduPersonUniqueId",
+	"urn:oid:1.3.6.1.4.1.5923.1.1.1.16":                        "eduPersonOrcid",
+	"urn:oid:1.3.6.1.4.1.5923.1.5.1.1":                         "isMemberOf",
+	"urn:oid:1.3.6.1.4.1.25178.1.2.9":                          "schacHomeOrganization",
+	"urn:oid:1.3.6.1.4.1.25178.1.2.10":                         "schacHomeOrganizationType",
+	"urn:oid:1.3.6.1.4.1.25178.1.2.14":                         "schacPersonalUniqueCode",
+	"urn:oasis:names:tc:SAML:attribute:subject-id":             "subject-id",
+	"urn:oasis:names:tc:SAML:attribute:pairwise-id":            "pairwise-id",
+	"urn:oasis:names:tc:SAML:2.0:profiles:attribute:DCE:realm": "dceRealm",
+
+	// WS-Federation claims, as sent by AD FS, Entra ID and Okta
+	"http://schemas.xmlsoap.org/ws/2005/05/identity/claims/emailaddress":              "email",
+	"http://schemas.xmlsoap.org/ws/2005/05/identity/claims/givenname":                 "givenName",
+	"http://schemas.xmlsoap.org/ws/2005/05/identity/claims/surname":                   "surname",
+	"http://schemas.xmlsoap.org/ws/2005/05/identity/claims/name":                      "name",
+	"http://schemas.xmlsoap.org/ws/2005/05/identity/claims/upn":                       "upn",
+	"http://schemas.xmlsoap.org/ws/2005/05/identity/claims/nameidentifier":            "nameIdentifier",
+	"http://schemas.xmlsoap.org/ws/2005/05/identity/claims/privatepersonalidentifier": "ppid",
+	"http://schemas.xmlsoap.org/ws/2005/05/identity/claims/dateofbirth":               "dateOfBirth",
+	"http://schemas.xmlsoap.org/ws/2005/05/identity/claims/country":                   "country",
+	"http://schemas.xmlsoap.org/ws/2005/05/identity/claims/mobilephone":               "mobilePhone",
+	"http://schemas.xmlsoap.org/claims/Group":                                         "group",
+	"http://schemas.xmlsoap.org/claims/CommonName":                                    "commonName",
+	"http://schemas.xmlsoap.org/claims/EmailAddress":                                  "emailAddress",
+	"http://schemas.xmlsoap.org/claims/UPN":                                           "upn",
+	"http://schemas.microsoft.com/ws/2008/06/identity/claims/role":                    "role",
+	"http://schemas.microsoft.com/ws/2008/06/identity/claims/groups":                  "groups",
+	"http://schemas.microsoft.com/ws/2008/06/identity/claims/groupsid":                "groupSid",
+	"http://schemas.microsoft.com/ws/2008/06/identity/claims/primarysid":              "primarySid",
+	"http://schemas.microsoft.com/ws/2008/06/identity/claims/windowsaccountname":      "windowsAccountName",
+	"http://schemas.microsoft.com/ws/2008/06/identity/claims/authenticationmethod":    "authenticationMethod",
+	"http://schemas.microsoft.com/ws/2008/06/identity/claims/authenticationinstant":   "authenticationInstant",
+	"http://schemas.microsoft.com/ws/2008/06/identity/claims/wids":                    "directoryRoles",
+	"http://schemas.microsoft.com/identity/claims/objectidentifier":                   "objectId",
+	"http://schemas.microsoft.com/identity/claims/tenantid":                           "tenantId",
+	"http://schemas.microsoft.com/identity/claims/displayname":                        "displayName",
+	"http://schemas.microsoft.com/identity/claims/identityprovider":                   "identityProvider",
+	"http://schemas.microsoft.com/claims/authnmethodsreferences":                      "authnMethodsReferences",
+	"http://schemas.microsoft.com/claims/groups.link":                                 "groupsOverageLink",
+}
+
+// AttributeDictionary maps attribute names, such as OIDs, to friendly
+// names for display
+type AttributeDictionary struct {
+	names map[string]string
+}
+
+// NewAttributeDictionary returns a dictionary of the attributes commonly
+// sent by IdPs: LDAP and Active Directory OIDs, eduPerson and SCHAC, and
+// the claim URIs of AD FS and Entra ID
+func NewAttributeDictionary() *AttributeDictionary {
+	d := &AttributeDictionary{names: make(map[string]string, len(knownAttributes))}
+	for name, friendly := range knownAttributes {
+		d.names[name] = friendly
+	}
+	return d
+}
+
+// FriendlyName returns the friendly name of an attribute, or "" if it is
+// unknown. Names are matched exactly, except that OIDs also match without
+// their urn:oid: prefix.
+func (d *AttributeDictionary) FriendlyName(name string) string {
+	if d == nil {
+		return ""
+	}
+	if friendly, ok := d.names[name]; ok {
+		return friendly
+	}
+	if friendly, ok := d.names["urn:oid:"+name]; ok && !strings.Contains(name, ":") {
+		return friendly
+	}
+	if strings.HasPrefix(name, legacyAttributePrefix) {
+		return strings.TrimPrefix(name, legacyAttributePrefix)
+	}
+	return ""
+}
+
+// Add maps an attribute name to a friendly name, replacing a built-in one
+func (d *AttributeDictionary) Add(name, friendly string) {
+	d.names[name] = friendly
+}
+
+// LoadFile adds the mappings of a YAML file to the dictionary, e.g.
+//
+//	urn:oid:1.3.6.1.4.1.99999.1.1: badgeNumber
+//	https://example.com/claims/department: department
+func (d *AttributeDictionary) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read attribute map: %w", err)
+	}
+	var names map[string]string
+	if err := yaml.Unmarshal(data, &names); err != nil {
+		return fmt.Errorf("failed to parse attribute map %s: %w", path, err)
+	}
+	for name, friendly := range names {
+		if friendly == "" {
+			return fmt.Errorf("attribute map %s: no friendly name for %q", path, name)
+		}
+		d.Add(name, friendly)
+	}
+	return nil
+}
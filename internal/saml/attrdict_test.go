@@ -0,0 +1,55 @@
+package saml
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAttributeDictionary_FriendlyName(t *testing.T) {
+	d := NewAttributeDictionary()
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"urn:oid:0.9.2342.19200300.100.1.3", "mail"},
+		{"0.9.2342.19200300.100.1.3", "mail"},
+		{"urn:oid:1.3.6.1.4.1.5923.1.1.1.6", "eduPersonPrincipalName"},
+		{"http://schemas.xmlsoap.org/ws/2005/05/identity/claims/emailaddress", "email"},
+		{"http://schemas.microsoft.com/identity/claims/objectidentifier", "objectId"},
+		{"urn:mace:dir:attribute-def:givenName", "givenName"},
+		{"urn:oid:1.2.3.4", ""},
+		{"mail", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, d.FriendlyName(tt.name))
+		})
+	}
+
+	var none *AttributeDictionary
+	assert.Empty(t, none.FriendlyName("urn:oid:2.5.4.3"))
+}
+
+func TestAttributeDictionary_LoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "attributes.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+urn:oid:1.3.6.1.4.1.99999.1.1: badgeNumber
+urn:oid:2.5.4.3: commonName
+`), 0644))
+
+	d := NewAttributeDictionary()
+	require.NoError(t, d.LoadFile(path))
+	assert.Equal(t, "badgeNumber", d.FriendlyName("urn:oid:1.3.6.1.4.1.99999.1.1"))
+	assert.Equal(t, "commonName", d.FriendlyName("urn:oid:2.5.4.3"))
+	assert.Equal(t, "mail", d.FriendlyName("urn:oid:0.9.2342.19200300.100.1.3"))
+
+	require.NoError(t, os.WriteFile(path, []byte("urn:oid:1.2.3: \"\"\n"), 0644))
+	assert.ErrorContains(t, d.LoadFile(path), `no friendly name for "urn:oid:1.2.3"`)
+
+	assert.Error(t, d.LoadFile(filepath.Join(t.TempDir(), "missing.yaml")))
+}
@@ -0,0 +1,141 @@
+package saml
+
+import (
+	"encoding/xml"
+	"errors"
+	"time"
+
+	"github.com/beevik/etree"
+)
+
+// AttributeQueryOptions describes an AttributeQuery to build
+type AttributeQueryOptions struct {
+	// Issuer is the entity ID of the requester, usually an SP
+	Issuer string
+
+	// Destination is the AttributeService URL of the attribute authority
+	Destination string
+
+	// NameID identifies the subject whose attributes are requested
+	NameID string
+
+	// NameIDFormat and SPNameQualifier qualify the NameID; empty omits them
+	NameIDFormat    string
+	SPNameQualifier string
+
+	// Attributes are the attributes requested, with the values the subject
+	// must have if any; none requests all attributes the requester may see
+	Attributes []Attribute
+}
+
+// BuildAttributeQuery builds an unsigned AttributeQuery issued at now. The
+// root element has a fresh ID, so it can be signed with SignEnveloped.
+func BuildAttributeQuery(opts AttributeQueryOptions, now time.Time) (*etree.Document, error) {
+	if opts.Issuer == "" {
+		return nil, errors.New("an issuer is required")
+	}
+	if opts.NameID == "" {
+		return nil, errors.New("a NameID is required")
+	}
+	id, err := NewID()
+	if err != nil {
+		return nil, err
+	}
+
+	doc := etree.NewDocument()
+	doc.CreateProcInst("xml", `version="1.0" encoding="UTF-8"`)
+	query := doc.CreateElement("samlp:AttributeQuery")
+	query.CreateAttr("xmlns:samlp", SAMLPNamespace)
+	query.CreateAttr("xmlns:saml", SAMLNamespace)
+	query.CreateAttr("ID", id)
+	query.CreateAttr("Version", "2.0")
+	query.CreateAttr("IssueInstant", now.UTC().Format(time.RFC3339))
+	if opts.Destination != "" {
+		query.CreateAttr("Destination", opts.Destination)
+	}
+
+	// Elements follow the order of the protocol schema
+	query.CreateElement("saml:Issuer").SetText(opts.Issuer)
+	nameID := query.CreateElement("saml:Subject").CreateElement("saml:NameID")
+	if opts.NameIDFormat != "" {
+		nameID.CreateAttr("Format", opts.NameIDFormat)
+	}
+	if opts.SPNameQualifier != "" {
+		nameID.CreateAttr("SPNameQualifier", opts.SPNameQualifier)
+	}
+	nameID.SetText(opts.NameID)
+	for _, attr := range opts.Attributes {
+		el := query.CreateElement("saml:Attribute")
+		el.CreateAttr("Name", attr.Name)
+		el.CreateAttr("NameFormat", attributeNameFormat(attr))
+		if attr.FriendlyName != "" {
+			el.CreateAttr("FriendlyName", attr.FriendlyName)
+		}
+		for _, value := range attr.Values {
+			el.CreateElement("saml:AttributeValue").SetText(value)
+		}
+	}
+
+	doc.Indent(2)
+	return doc, nil
+}
+
+// AttributeQuery structure for XML parsing
+type samlAttributeQuery struct {
+	XMLName      xml.Name          `xml:"AttributeQuery"`
+	ID           string            `xml:"ID,attr"`
+	IssueInstant string            `xml:"IssueInstant,attr"`
+	Destination  string            `xml:"Destination,attr"`
+	Issuer       string            `xml:"Issuer"`
+	Subject      *samlSubject      `xml:"Subject"`
+	Attributes   []samlAttribute   `xml:"Attribute"`
+	Signature    *xmldsigSignature `xml:"Signature"`
+}
+
+// parseAttributeQuery parses an AttributeQuery. The requested attributes
+// are reported as RequestedAttributes, and values they are restricted to
+// as Attributes.
+func (p *Parser) parseAttributeQuery(xmlData []byte) (*SAMLInfo, error) {
+	var query samlAttributeQuery
+	if err := p.unmarshal(xmlData, &query); err != nil {
+		return nil, &ErrParse{Stage: "SAML AttributeQuery", Err: err}
+	}
+
+	info := &SAMLInfo{
+		Type:        "AttributeQuery",
+		ID:          query.ID,
+		Destination: query.Destination,
+		Issuer:      query.Issuer,
+	}
+	if query.IssueInstant != "" {
+		if t, err := time.Parse(time.RFC3339, query.IssueInstant); err == nil {
+			info.IssueInstant = &t
+		}
+	}
+	if query.Subject != nil {
+		info.Subject = &Subject{
+			NameID:          query.Subject.NameID.Value,
+			NameIDFormat:    query.Subject.NameID.Format,
+			SPNameQualifier: query.Subject.NameID.SPNameQualifier,
+		}
+	}
+	for _, attr := range query.Attributes {
+		info.RequestedAttributes = append(info.RequestedAttributes, RequestedAttribute{
+			Name:         attr.Name,
+			FriendlyName: attr.FriendlyName,
+			NameFormat:   attr.NameFormat,
+		})
+		if len(attr.Values) > 0 {
+			info.Attributes = append(info.Attributes, Attribute{
+				Name:         attr.Name,
+				FriendlyName: attr.FriendlyName,
+				NameFormat:   attr.NameFormat,
+				Values:       attr.Values,
+			})
+		}
+	}
+	if query.Signature != nil {
+		info.Signature = p.parseSignature(query.Signature)
+	}
+	return info, nil
+}
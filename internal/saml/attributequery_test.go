@@ -0,0 +1,78 @@
+package saml
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildAttributeQuery(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	doc, err := BuildAttributeQuery(AttributeQueryOptions{
+		Issuer:       "https://sp.example.com",
+		Destination:  "https://idp.example.com/aa",
+		NameID:       "alice",
+		NameIDFormat: "urn:oasis:names:tc:SAML:2.0:nameid-format:persistent",
+		Attributes: []Attribute{
+			{Name: "mail"},
+			{Name: "urn:oid:1.3.6.1.4.1.5923.1.1.1.7", FriendlyName: "eduPersonEntitlement", Values: []string{"staff"}},
+		},
+	}, now)
+	require.NoError(t, err)
+	xmlData, err := doc.WriteToBytes()
+	require.NoError(t, err)
+
+	assert.Equal(t, "AttributeQuery", DetectType(xmlData))
+	info, err := NewParser().Parse(xmlData)
+	require.NoError(t, err)
+	assert.Equal(t, "AttributeQuery", info.Type)
+	assert.NotEmpty(t, info.ID)
+	assert.Equal(t, "https://sp.example.com", info.Issuer)
+	assert.Equal(t, "https://idp.example.com/aa", info.Destination)
+	require.NotNil(t, info.IssueInstant)
+	assert.True(t, now.Equal(*info.IssueInstant))
+	require.NotNil(t, info.Subject)
+	assert.Equal(t, "alice", info.Subject.NameID)
+	assert.Equal(t, "urn:oasis:names:tc:SAML:2.0:nameid-format:persistent", info.Subject.NameIDFormat)
+
+	require.Len(t, info.RequestedAttributes, 2)
+	assert.Equal(t, "mail", info.RequestedAttributes[0].Name)
+	assert.Equal(t, "urn:oasis:names:tc:SAML:2.0:attrname-format:unspecified", info.RequestedAttributes[0].NameFormat)
+	assert.Equal(t, "urn:oasis:names:tc:SAML:2.0:attrname-format:uri", info.RequestedAttributes[1].NameFormat)
+	require.Len(t, info.Attributes, 1)
+	assert.Equal(t, "eduPersonEntitlement", info.Attributes[0].FriendlyName)
+	assert.Equal(t, []string{"staff"}, info.Attributes[0].Values)
+}
+
+func TestBuildAttributeQuery_Required(t *testing.T) {
+	_, err := BuildAttributeQuery(AttributeQueryOptions{NameID: "alice"}, time.Now())
+	assert.ErrorContains(t, err, "issuer")
+
+	_, err = BuildAttributeQuery(AttributeQueryOptions{Issuer: "https://sp.example.com"}, time.Now())
+	assert.ErrorContains(t, err, "NameID")
+}
+
+func TestBuildAttributeQuery_Signed(t *testing.T) {
+	key, cert, err := NewSelfSignedKey("sp.example.com")
+	require.NoError(t, err)
+	doc, err := BuildAttributeQuery(AttributeQueryOptions{Issuer: "https://sp.example.com", NameID: "alice"}, time.Now())
+	require.NoError(t, err)
+	require.NoError(t, SignEnveloped(doc.Root(), key, cert, SigAlgRSASHA256))
+	query, err := doc.WriteToBytes()
+	require.NoError(t, err)
+
+	// The signature survives the trip through a SOAP envelope
+	envelope, err := WrapSOAP(query)
+	require.NoError(t, err)
+	unwrapped, err := UnwrapSOAP(envelope)
+	require.NoError(t, err)
+	v := VerifyMessage(unwrapped, nil)
+	assert.Equal(t, VerdictVerified, v.Verdict, "%v", v.Err)
+
+	info, err := NewParser().Parse(unwrapped)
+	require.NoError(t, err)
+	require.NotNil(t, info.Signature)
+	assert.Equal(t, SigAlgRSASHA256, info.Signature.SignatureMethod)
+}
@@ -0,0 +1,580 @@
+package saml
+
+import (
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/beevik/etree"
+)
+
+// Severity levels for audit findings
+const (
+	SeverityHigh   = "high"
+	SeverityMedium = "medium"
+	SeverityLow    = "low"
+)
+
+// Audit checks
+const (
+	CheckDuplicateID        = "duplicate-id"
+	CheckMultipleAssertions = "multiple-assertions"
+	CheckUnsignedAssertion  = "unsigned-assertion"
+	CheckDanglingReference  = "dangling-reference"
+	CheckDetachedSignature  = "detached-signature"
+	CheckNameIDComment      = "nameid-comment"
+	CheckWeakAlgorithm      = "weak-algorithm"
+	CheckShortKey           = "short-key"
+	CheckCertificateExpiry  = "certificate-expiry"
+	CheckMissingSignature   = "missing-signature"
+	CheckLongLifetime       = "long-lifetime"
+	CheckMissingAudience    = "missing-audience"
+	CheckUnsignedRedirect   = "unsigned-redirect"
+	CheckDTD                = "dtd"
+	CheckCertMismatch       = "certificate-mismatch"
+	CheckReplay             = "replay"
+	CheckExpired            = "expired"
+	CheckScope              = "attribute-scope"
+)
+
+// minRSAKeyBits is the smallest RSA key size not reported as short
+const minRSAKeyBits = 2048
+
+// weakAlgorithms maps deprecated algorithm URIs to their severity
+var weakAlgorithms = map[string]string{
+	SigAlgRSASHA1: SeverityMedium,
+	"http://www.w3.org/2000/09/xmldsig#dsa-sha1":        SeverityMedium,
+	"http://www.w3.org/2000/09/xmldsig#hmac-sha1":       SeverityMedium,
+	"http://www.w3.org/2000/09/xmldsig#sha1":            SeverityMedium,
+	"http://www.w3.org/2001/04/xmldsig-more#ecdsa-sha1": SeverityMedium,
+	"http://www.w3.org/2001/04/xmldsig-more#rsa-md5":    SeverityHigh,
+	"http://www.w3.org/2001/04/xmldsig-more#md5":        SeverityHigh,
+	"http://www.w3.org/2001/04/xmlenc#rsa-1_5":          SeverityHigh,
+}
+
+// AuditOptions configures the checks that depend on context outside the
+// document itself
+type AuditOptions struct {
+	// Now is the time certificates are checked against
+	Now time.Time
+
+	// MaxLifetime is the longest acceptable assertion validity window;
+	// zero disables the check
+	MaxLifetime time.Duration
+
+	// CertExpiryWarning reports certificates that expire within this period
+	CertExpiryWarning time.Duration
+
+	// RedirectQuery holds the query parameters of a message sent with the
+	// HTTP-Redirect binding, whose signature is carried in the URL
+	RedirectQuery url.Values
+
+	// TrustedCertificates are the signing certificates the relying party
+	// has configured, e.g. from IdP metadata. If set, signature
+	// certificates that match none of them are reported.
+	TrustedCertificates []ExtractedCertificate
+
+	// Scopes are the shibmd:Scope values the IdP's metadata permits in
+	// scoped attributes such as eduPersonPrincipalName. nil disables the
+	// check; an empty list permits no scope.
+	Scopes []Scope
+}
+
+// DefaultAuditOptions returns the options used by the audit command
+func DefaultAuditOptions() AuditOptions {
+	return AuditOptions{
+		Now:               time.Now(),
+		MaxLifetime:       time.Hour,
+		CertExpiryWarning: 30 * 24 * time.Hour,
+	}
+}
+
+// AuditFinding is a security issue found in a SAML message
+type AuditFinding struct {
+	Check    string `json:"check"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+// auditor collects findings for a single document
+type auditor struct {
+	root     *etree.Element
+	opts     AuditOptions
+	findings []AuditFinding
+
+	ids        map[string][]*etree.Element
+	idOrder    []string
+	assertions []*etree.Element
+	signatures []*etree.Element
+}
+
+func (a *auditor) add(check, severity, format string, args ...interface{}) {
+	a.findings = append(a.findings, AuditFinding{Check: check, Severity: severity, Message: fmt.Sprintf(format, args...)})
+}
+
+// Audit checks a SAML document for security issues: XML Signature Wrapping
+// (XSW) patterns such as duplicated IDs, extra Assertion elements,
+// signatures that do not cover the consumed assertion and comments
+// splitting a NameID value, as well as weak algorithms and keys, expiring
+// certificates, missing signatures, long lifetimes and missing audience
+// restrictions.
+func Audit(xmlData []byte, opts AuditOptions) ([]AuditFinding, error) {
+	a := &auditor{opts: opts, ids: map[string][]*etree.Element{}}
+	a.checkDTD(xmlData)
+
+	doc := etree.NewDocument()
+	// Declared entities are left unexpanded, so the rest of the document
+	// can still be audited
+	doc.ReadSettings.Permissive = len(a.findings) > 0
+	if err := doc.ReadFromBytes(xmlData); err != nil {
+		return nil, &ErrParse{Stage: "XML", Err: err}
+	}
+	a.root = doc.Root()
+	if a.root == nil {
+		return nil, &ErrParse{Stage: "XML", Err: errors.New("no root element")}
+	}
+
+	walkElements(a.root, func(el *etree.Element) {
+		if id := el.SelectAttrValue("ID", ""); id != "" {
+			if _, seen := a.ids[id]; !seen {
+				a.idOrder = append(a.idOrder, id)
+			}
+			a.ids[id] = append(a.ids[id], el)
+		}
+		switch {
+		case isElement(el, SAMLNamespace, "Assertion"):
+			a.assertions = append(a.assertions, el)
+		case isElement(el, XMLDSigNamespace, "Signature"):
+			a.signatures = append(a.signatures, el)
+		}
+	})
+
+	a.checkWrapping()
+	a.checkNameIDComments()
+	a.checkAlgorithms()
+	a.checkCertificates()
+	a.checkTrustedCertificates()
+	a.checkScopes()
+	a.checkSignaturePresence()
+	a.checkConditions()
+	a.checkRedirect()
+	return a.findings, nil
+}
+
+// checkDTD reports DOCTYPE declarations. Entities are never expanded, but
+// their presence suggests an XXE or entity expansion attempt.
+func (a *auditor) checkDTD(xmlData []byte) {
+	dtd, ok := FindDTD(xmlData)
+	if !ok {
+		return
+	}
+	switch {
+	case dtd.External:
+		a.add(CheckDTD, SeverityHigh, "document has a DOCTYPE referencing external resources (possible XXE attack); entities: %s", entityList(dtd.Entities))
+	case len(dtd.Entities) > 0:
+		a.add(CheckDTD, SeverityHigh, "document has a DOCTYPE declaring entities (possible entity expansion attack): %s", entityList(dtd.Entities))
+	default:
+		a.add(CheckDTD, SeverityMedium, "document has a DOCTYPE declaration, which SAML messages must not have")
+	}
+}
+
+// entityList formats entity names for audit messages
+func entityList(entities []string) string {
+	if len(entities) == 0 {
+		return "none"
+	}
+	return strings.Join(entities, ", ")
+}
+
+// checkWrapping looks for the structures used by signature wrapping attacks
+func (a *auditor) checkWrapping() {
+	for _, id := range a.idOrder {
+		if elements := a.ids[id]; len(elements) > 1 {
+			var tags []string
+			for _, el := range elements {
+				tags = append(tags, "<"+el.FullTag()+">")
+			}
+			a.add(CheckDuplicateID, SeverityHigh, "ID %q is used by %d elements (%s); a signature may be verified against a different element than the one consumed",
+				id, len(elements), strings.Join(tags, ", "))
+		}
+	}
+
+	if len(a.assertions) > 1 {
+		a.add(CheckMultipleAssertions, SeverityHigh, "message contains %d Assertion elements; a single assertion is expected", len(a.assertions))
+	}
+
+	// Elements protected by a signature, in document order
+	var signed []*etree.Element
+	for _, sig := range a.signatures {
+		ref := sig.FindElement("./SignedInfo/Reference")
+		if ref == nil {
+			continue
+		}
+		uri := ref.SelectAttrValue("URI", "")
+		target := a.root
+		if uri != "" {
+			id := strings.TrimPrefix(uri, "#")
+			if elements := a.ids[id]; len(elements) > 0 {
+				target = elements[0]
+			} else {
+				a.add(CheckDanglingReference, SeverityHigh, "signature references %s, but no element has that ID", uri)
+				continue
+			}
+		}
+		if target != sig.Parent() {
+			a.add(CheckDetachedSignature, SeverityMedium, "signature inside <%s> covers %s, which does not enclose it",
+				sig.Parent().FullTag(), describeElement(target))
+		}
+		signed = append(signed, target)
+	}
+
+	consumed := a.consumedElement()
+	if consumed == nil || len(signed) == 0 {
+		return
+	}
+	for _, el := range signed {
+		if isAncestorOrSelf(el, consumed) {
+			return
+		}
+	}
+	var covered []string
+	for _, el := range signed {
+		covered = append(covered, describeElement(el))
+	}
+	a.add(CheckUnsignedAssertion, SeverityHigh, "%s, which a service provider would consume, is not covered by any signature (signed: %s)",
+		describeElement(consumed), strings.Join(covered, ", "))
+}
+
+// consumedElement returns the element a typical service provider reads:
+// the first Assertion directly below a Response, or the root itself
+func (a *auditor) consumedElement() *etree.Element {
+	if !isElement(a.root, SAMLPNamespace, "Response") {
+		return a.root
+	}
+	for _, el := range a.assertions {
+		if el.Parent() == a.root {
+			return el
+		}
+	}
+	return nil
+}
+
+// checkNameIDComments flags comments inside NameID values, which make
+// parsers that drop comments read only part of the value
+func (a *auditor) checkNameIDComments() {
+	walkElements(a.root, func(el *etree.Element) {
+		if !isElement(el, SAMLNamespace, "NameID") {
+			return
+		}
+
+		var full, truncated strings.Builder
+		hasComment := false
+		for _, token := range el.Child {
+			switch t := token.(type) {
+			case *etree.Comment:
+				hasComment = true
+			case *etree.CharData:
+				full.WriteString(t.Data)
+				if !hasComment {
+					truncated.WriteString(t.Data)
+				}
+			}
+		}
+		if hasComment {
+			a.add(CheckNameIDComment, SeverityHigh, "NameID %q contains an XML comment; parsers that drop comments may read %q",
+				strings.TrimSpace(full.String()), strings.TrimSpace(truncated.String()))
+		}
+	})
+}
+
+// checkAlgorithms reports deprecated signature, digest and key transport
+// algorithms
+func (a *auditor) checkAlgorithms() {
+	seen := map[string]bool{}
+	walkElements(a.root, func(el *etree.Element) {
+		switch el.Tag {
+		case "SignatureMethod", "DigestMethod", "EncryptionMethod":
+		default:
+			return
+		}
+		alg := el.SelectAttrValue("Algorithm", "")
+		if key := el.Tag + " " + alg; !seen[key] {
+			seen[key] = true
+			a.checkAlgorithm(el.Tag, alg)
+		}
+	})
+}
+
+// checkAlgorithm reports alg if it is deprecated; where names its use
+func (a *auditor) checkAlgorithm(where, alg string) {
+	if severity, weak := weakAlgorithms[alg]; weak {
+		a.add(CheckWeakAlgorithm, severity, "%s uses the weak algorithm %s", where, shortSigAlg(alg))
+	}
+}
+
+// checkCertificates reports short RSA keys and certificates that are
+// expired, not yet valid or about to expire
+func (a *auditor) checkCertificates() {
+	seen := map[string]bool{}
+	for _, sig := range a.signatures {
+		for _, el := range sig.FindElements(".//X509Certificate") {
+			data := strings.Join(strings.Fields(el.Text()), "")
+			if data == "" || seen[data] {
+				continue
+			}
+			seen[data] = true
+
+			cert, err := ParseCertificate([]byte(data))
+			if err != nil {
+				continue
+			}
+			name := cert.Subject.String()
+
+			if key, ok := cert.PublicKey.(*rsa.PublicKey); ok && key.N.BitLen() < minRSAKeyBits {
+				a.add(CheckShortKey, SeverityHigh, "certificate %q has a %d-bit RSA key; at least %d bits are recommended", name, key.N.BitLen(), minRSAKeyBits)
+			}
+
+			now := a.opts.Now
+			if now.IsZero() {
+				continue
+			}
+			switch {
+			case now.After(cert.NotAfter):
+				a.add(CheckCertificateExpiry, SeverityMedium, "certificate %q expired on %s", name, cert.NotAfter.Format(time.RFC3339))
+			case now.Before(cert.NotBefore):
+				a.add(CheckCertificateExpiry, SeverityMedium, "certificate %q is not valid before %s", name, cert.NotBefore.Format(time.RFC3339))
+			case cert.NotAfter.Sub(now) < a.opts.CertExpiryWarning:
+				days := int(math.Ceil(cert.NotAfter.Sub(now).Hours() / 24))
+				a.add(CheckCertificateExpiry, SeverityLow, "certificate %q expires on %s (in %d day(s))", name, cert.NotAfter.Format(time.RFC3339), days)
+			}
+		}
+	}
+}
+
+// checkTrustedCertificates compares the certificate in each signature's
+// KeyInfo with the trusted signing certificates. A mismatch is the most
+// common cause of "signature validation failed" after a certificate
+// rollover.
+func (a *auditor) checkTrustedCertificates() {
+	if len(a.opts.TrustedCertificates) == 0 {
+		return
+	}
+	trusted := map[string]bool{}
+	var fingerprints []string
+	for _, cert := range a.opts.TrustedCertificates {
+		trusted[cert.SHA256Fingerprint] = true
+		fingerprints = append(fingerprints, cert.SHA256Fingerprint)
+	}
+
+	for _, sig := range a.signatures {
+		where := "signature in " + describeElement(sig.Parent())
+		el := sig.FindElement("./KeyInfo/X509Data/X509Certificate")
+		if el == nil {
+			a.add(CheckCertMismatch, SeverityLow, "%s has no KeyInfo certificate to compare with the trusted certificates", where)
+			continue
+		}
+		cert, err := ParseCertificate([]byte(el.Text()))
+		if err != nil {
+			continue
+		}
+		if fingerprint := Fingerprint(cert.Raw, sha256Sum); !trusted[fingerprint] {
+			a.add(CheckCertMismatch, SeverityHigh, "%s uses certificate %q (SHA-256 %s), which matches none of the trusted certificates (%s)",
+				where, cert.Subject.String(), fingerprint, strings.Join(fingerprints, ", "))
+		}
+	}
+}
+
+// checkScopes reports scoped attribute values whose scope the IdP's
+// metadata does not permit, by which an IdP could assert identities of
+// another organization
+func (a *auditor) checkScopes() {
+	if a.opts.Scopes == nil {
+		return
+	}
+	var permitted []string
+	for _, scope := range a.opts.Scopes {
+		permitted = append(permitted, scope.String())
+	}
+	allowed := "the metadata declares no shibmd:Scope"
+	if len(permitted) > 0 {
+		allowed = "permitted: " + strings.Join(permitted, ", ")
+	}
+
+	for _, attr := range findElements(a.root, SAMLNamespace, "Attribute") {
+		name := scopedAttributeName(attr)
+		if name == "" {
+			continue
+		}
+		for _, el := range attr.ChildElements() {
+			if !isElement(el, SAMLNamespace, "AttributeValue") {
+				continue
+			}
+			value := strings.TrimSpace(el.Text())
+			scope := el.SelectAttrValue("Scope", "")
+			if scope == "" {
+				switch strings.Count(value, "@") {
+				case 0:
+					a.add(CheckScope, SeverityMedium, "%s value %q has no scope", name, value)
+					continue
+				case 1:
+					scope = value[strings.Index(value, "@")+1:]
+				default:
+					a.add(CheckScope, SeverityHigh, "%s value %q has several @ delimiters, so SPs may disagree on its scope (possible scope injection)", name, value)
+					continue
+				}
+			}
+			if !scopePermitted(a.opts.Scopes, scope) {
+				a.add(CheckScope, SeverityHigh, "%s value %q has scope %q, which the IdP may not assert (possible scope injection; %s)", name, value, scope, allowed)
+			}
+		}
+	}
+}
+
+// scopePermitted reports whether any of scopes matches scope
+func scopePermitted(scopes []Scope, scope string) bool {
+	for _, s := range scopes {
+		if s.Matches(scope) {
+			return true
+		}
+	}
+	return false
+}
+
+// MetadataSigningCertificates returns the certificates of the signing
+// KeyDescriptors in a metadata document. KeyDescriptors without a use
+// attribute apply to signing as well.
+func MetadataSigningCertificates(metadata []byte) ([]ExtractedCertificate, error) {
+	certs, err := ExtractCertificates(metadata)
+	if err != nil {
+		return nil, err
+	}
+	var signing []ExtractedCertificate
+	for _, cert := range certs {
+		if cert.Location == "KeyDescriptor (signing)" || cert.Location == "KeyDescriptor" {
+			signing = append(signing, cert)
+		}
+	}
+	if len(signing) == 0 {
+		return nil, fmt.Errorf("metadata has no signing certificates")
+	}
+	return signing, nil
+}
+
+// checkSignaturePresence reports Responses and Assertions without an
+// enveloped signature
+func (a *auditor) checkSignaturePresence() {
+	switch {
+	case isElement(a.root, SAMLNamespace, "Assertion"):
+		if !hasSignature(a.root) {
+			a.add(CheckMissingSignature, SeverityHigh, "Assertion is not signed")
+		}
+
+	case isElement(a.root, SAMLPNamespace, "Response"):
+		assertion := a.consumedElement()
+		responseSigned := hasSignature(a.root)
+		if assertion == nil {
+			// Encrypted assertions can only be checked after decryption
+			if !responseSigned && a.root.FindElement("./EncryptedAssertion") != nil {
+				a.add(CheckMissingSignature, SeverityLow, "Response is not signed and its encrypted assertion could not be checked")
+			}
+			return
+		}
+
+		assertionSigned := hasSignature(assertion)
+		switch {
+		case !responseSigned && !assertionSigned:
+			a.add(CheckMissingSignature, SeverityHigh, "neither the Response nor the Assertion is signed")
+		case !assertionSigned:
+			a.add(CheckMissingSignature, SeverityLow, "Assertion is not signed; it is only protected by the Response signature")
+		case !responseSigned:
+			a.add(CheckMissingSignature, SeverityLow, "Response is not signed; Status, Destination and InResponseTo are not protected")
+		}
+	}
+}
+
+// checkConditions reports a missing AudienceRestriction and validity
+// windows longer than the configured maximum
+func (a *auditor) checkConditions() {
+	assertion := a.consumedElement()
+	if assertion == nil || !isElement(assertion, SAMLNamespace, "Assertion") {
+		return
+	}
+
+	conditions := assertion.FindElement("./Conditions")
+	if conditions == nil || conditions.FindElement("./AudienceRestriction/Audience") == nil {
+		a.add(CheckMissingAudience, SeverityMedium, "Assertion has no AudienceRestriction and is accepted by any service provider")
+	}
+
+	if conditions == nil || a.opts.MaxLifetime <= 0 {
+		return
+	}
+	start := conditions.SelectAttrValue("NotBefore", assertion.SelectAttrValue("IssueInstant", ""))
+	end := conditions.SelectAttrValue("NotOnOrAfter", "")
+	startTime, err1 := time.Parse(time.RFC3339Nano, start)
+	endTime, err2 := time.Parse(time.RFC3339Nano, end)
+	if err1 != nil || err2 != nil {
+		return
+	}
+	if lifetime := endTime.Sub(startTime); lifetime > a.opts.MaxLifetime {
+		a.add(CheckLongLifetime, SeverityLow, "Assertion is valid for %s, longer than %s", lifetime, a.opts.MaxLifetime)
+	}
+}
+
+// checkRedirect reports HTTP-Redirect binding messages without a signature
+// in the query string, or with a weak SigAlg
+func (a *auditor) checkRedirect() {
+	query := a.opts.RedirectQuery
+	if query == nil {
+		return
+	}
+	if query.Get("Signature") == "" {
+		a.add(CheckUnsignedRedirect, SeverityMedium, "%s sent with the HTTP-Redirect binding is not signed", a.root.Tag)
+		return
+	}
+	a.checkAlgorithm("SigAlg", query.Get("SigAlg"))
+}
+
+// hasSignature reports whether el has an enveloped XML signature
+func hasSignature(el *etree.Element) bool {
+	for _, child := range el.ChildElements() {
+		if isElement(child, XMLDSigNamespace, "Signature") {
+			return true
+		}
+	}
+	return false
+}
+
+// walkElements calls fn for el and all of its descendants in document order
+func walkElements(el *etree.Element, fn func(*etree.Element)) {
+	fn(el)
+	for _, child := range el.ChildElements() {
+		walkElements(child, fn)
+	}
+}
+
+// isElement reports whether el has the given namespace and local name
+func isElement(el *etree.Element, namespace, local string) bool {
+	return el.Tag == local && el.NamespaceURI() == namespace
+}
+
+// isAncestorOrSelf reports whether ancestor is el or one of its ancestors
+func isAncestorOrSelf(ancestor, el *etree.Element) bool {
+	for ; el != nil; el = el.Parent() {
+		if el == ancestor {
+			return true
+		}
+	}
+	return false
+}
+
+// describeElement returns the tag and ID of el for use in messages
+func describeElement(el *etree.Element) string {
+	if id := el.SelectAttrValue("ID", ""); id != "" {
+		return fmt.Sprintf("<%s ID=%q>", el.FullTag(), id)
+	}
+	return "<" + el.FullTag() + ">"
+}
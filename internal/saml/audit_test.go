@@ -0,0 +1,341 @@
+package saml
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// auditChecks returns the check names of findings, in order
+func auditChecks(findings []AuditFinding) []string {
+	checks := make([]string, 0, len(findings))
+	for _, f := range findings {
+		checks = append(checks, f.Check)
+	}
+	return checks
+}
+
+// wrappingChecks are the signature wrapping checks, which the XSW tests
+// look at in isolation
+var wrappingChecks = map[string]bool{
+	CheckDuplicateID:        true,
+	CheckMultipleAssertions: true,
+	CheckUnsignedAssertion:  true,
+	CheckDanglingReference:  true,
+	CheckDetachedSignature:  true,
+	CheckNameIDComment:      true,
+}
+
+// wrappingFindings returns the findings of the signature wrapping checks
+func wrappingFindings(findings []AuditFinding) []AuditFinding {
+	var filtered []AuditFinding
+	for _, f := range findings {
+		if wrappingChecks[f.Check] {
+			filtered = append(filtered, f)
+		}
+	}
+	return filtered
+}
+
+func TestAudit_Clean(t *testing.T) {
+	xml := `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_r">
+  <ds:Signature xmlns:ds="http://www.w3.org/2000/09/xmldsig#"><ds:SignedInfo><ds:Reference URI="#_r"/></ds:SignedInfo></ds:Signature>
+  <saml:Assertion ID="_a">
+    <ds:Signature xmlns:ds="http://www.w3.org/2000/09/xmldsig#"><ds:SignedInfo><ds:Reference URI="#_a"/></ds:SignedInfo></ds:Signature>
+    <saml:Subject><saml:NameID>user@example.com</saml:NameID></saml:Subject>
+    <saml:Conditions NotBefore="2024-01-15T10:25:00Z" NotOnOrAfter="2024-01-15T10:35:00Z">
+      <saml:AudienceRestriction><saml:Audience>https://sp.example.com</saml:Audience></saml:AudienceRestriction>
+    </saml:Conditions>
+  </saml:Assertion>
+</samlp:Response>`
+
+	findings, err := Audit([]byte(xml), AuditOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, findings)
+}
+
+func TestAudit_SignatureWrapping(t *testing.T) {
+	// XSW: the signed original assertion is moved into Extensions and an
+	// evil assertion with the same ID takes its place
+	xml := `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_r">
+  <samlp:Extensions>
+    <saml:Assertion ID="_a">
+      <ds:Signature xmlns:ds="http://www.w3.org/2000/09/xmldsig#"><ds:SignedInfo><ds:Reference URI="#_a"/></ds:SignedInfo></ds:Signature>
+      <saml:Subject><saml:NameID>user@example.com</saml:NameID></saml:Subject>
+    </saml:Assertion>
+  </samlp:Extensions>
+  <saml:Assertion ID="_a">
+    <saml:Subject><saml:NameID>admin@example.com</saml:NameID></saml:Subject>
+  </saml:Assertion>
+</samlp:Response>`
+
+	findings, err := Audit([]byte(xml), AuditOptions{})
+	require.NoError(t, err)
+	findings = wrappingFindings(findings)
+
+	assert.Equal(t, []string{CheckDuplicateID, CheckMultipleAssertions, CheckUnsignedAssertion}, auditChecks(findings))
+	assert.Contains(t, findings[0].Message, `"_a" is used by 2 elements`)
+	for _, f := range findings {
+		assert.Equal(t, SeverityHigh, f.Severity)
+	}
+}
+
+func TestAudit_UnsignedAssertionWithDetachedSignature(t *testing.T) {
+	// The signature covers an unrelated element instead of the assertion
+	xml := `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_r">
+  <samlp:Extensions ID="_ext"/>
+  <saml:Assertion ID="_a">
+    <ds:Signature xmlns:ds="http://www.w3.org/2000/09/xmldsig#"><ds:SignedInfo><ds:Reference URI="#_ext"/></ds:SignedInfo></ds:Signature>
+  </saml:Assertion>
+</samlp:Response>`
+
+	findings, err := Audit([]byte(xml), AuditOptions{})
+	require.NoError(t, err)
+	findings = wrappingFindings(findings)
+
+	assert.Equal(t, []string{CheckDetachedSignature, CheckUnsignedAssertion}, auditChecks(findings))
+	assert.Equal(t, SeverityMedium, findings[0].Severity)
+	assert.Contains(t, findings[1].Message, `<saml:Assertion ID="_a">`)
+	assert.Contains(t, findings[1].Message, `<samlp:Extensions ID="_ext">`)
+}
+
+func TestAudit_SignedResponseCoversAssertion(t *testing.T) {
+	xml := `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_r">
+  <ds:Signature xmlns:ds="http://www.w3.org/2000/09/xmldsig#"><ds:SignedInfo><ds:Reference URI="#_r"/></ds:SignedInfo></ds:Signature>
+  <saml:Assertion ID="_a"/>
+</samlp:Response>`
+
+	findings, err := Audit([]byte(xml), AuditOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, wrappingFindings(findings))
+}
+
+func TestAudit_DanglingReference(t *testing.T) {
+	xml := `<saml:Assertion xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_a">
+  <ds:Signature xmlns:ds="http://www.w3.org/2000/09/xmldsig#"><ds:SignedInfo><ds:Reference URI="#_missing"/></ds:SignedInfo></ds:Signature>
+</saml:Assertion>`
+
+	findings, err := Audit([]byte(xml), AuditOptions{})
+	require.NoError(t, err)
+	findings = wrappingFindings(findings)
+
+	assert.Equal(t, []string{CheckDanglingReference}, auditChecks(findings))
+}
+
+func TestAudit_NameIDComment(t *testing.T) {
+	xml := `<saml:Assertion xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_a">
+  <saml:Subject><saml:NameID>admin@example.com<!---->.evil.com</saml:NameID></saml:Subject>
+</saml:Assertion>`
+
+	findings, err := Audit([]byte(xml), AuditOptions{})
+	require.NoError(t, err)
+	findings = wrappingFindings(findings)
+
+	require.Equal(t, []string{CheckNameIDComment}, auditChecks(findings))
+	assert.Contains(t, findings[0].Message, `"admin@example.com.evil.com"`)
+	assert.Contains(t, findings[0].Message, `may read "admin@example.com"`)
+}
+
+func TestAudit_InvalidXML(t *testing.T) {
+	_, err := Audit([]byte("not xml"), AuditOptions{})
+	assert.Error(t, err)
+}
+
+func TestAudit_WeakCrypto(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	cert := selfSignedCert(t, key)
+
+	xml := `<saml:Assertion xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_a">
+  <ds:Signature xmlns:ds="http://www.w3.org/2000/09/xmldsig#">
+    <ds:SignedInfo>
+      <ds:SignatureMethod Algorithm="http://www.w3.org/2000/09/xmldsig#rsa-sha1"/>
+      <ds:Reference URI="#_a"><ds:DigestMethod Algorithm="http://www.w3.org/2000/09/xmldsig#sha1"/></ds:Reference>
+    </ds:SignedInfo>
+    <ds:KeyInfo><ds:X509Data><ds:X509Certificate>` + base64.StdEncoding.EncodeToString(cert.Raw) + `</ds:X509Certificate></ds:X509Data></ds:KeyInfo>
+  </ds:Signature>
+  <saml:Conditions NotBefore="2024-01-15T10:00:00Z" NotOnOrAfter="2024-01-15T18:00:00Z">
+    <saml:AudienceRestriction><saml:Audience>https://sp.example.com</saml:Audience></saml:AudienceRestriction>
+  </saml:Conditions>
+</saml:Assertion>`
+
+	findings, err := Audit([]byte(xml), DefaultAuditOptions())
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{CheckWeakAlgorithm, CheckWeakAlgorithm, CheckShortKey, CheckCertificateExpiry, CheckLongLifetime}, auditChecks(findings))
+	assert.Equal(t, "SignatureMethod uses the weak algorithm rsa-sha1", findings[0].Message)
+	assert.Equal(t, "DigestMethod uses the weak algorithm sha1", findings[1].Message)
+	assert.Contains(t, findings[2].Message, "1024-bit RSA key")
+	assert.Equal(t, SeverityHigh, findings[2].Severity)
+	assert.Contains(t, findings[3].Message, "(in 1 day(s))")
+	assert.Equal(t, SeverityLow, findings[3].Severity)
+	assert.Equal(t, "Assertion is valid for 8h0m0s, longer than 1h0m0s", findings[4].Message)
+
+	t.Run("expired certificate", func(t *testing.T) {
+		opts := DefaultAuditOptions()
+		opts.Now = time.Now().Add(2 * time.Hour)
+		opts.MaxLifetime = 0
+
+		findings, err := Audit([]byte(xml), opts)
+		require.NoError(t, err)
+		require.Equal(t, CheckCertificateExpiry, findings[3].Check)
+		assert.Contains(t, findings[3].Message, "expired on")
+		assert.Len(t, findings, 4)
+	})
+}
+
+func TestAudit_KeyTransport(t *testing.T) {
+	xml := `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_r">
+  <saml:EncryptedAssertion>
+    <xenc:EncryptedData xmlns:xenc="http://www.w3.org/2001/04/xmlenc#">
+      <ds:KeyInfo xmlns:ds="http://www.w3.org/2000/09/xmldsig#">
+        <xenc:EncryptedKey><xenc:EncryptionMethod Algorithm="http://www.w3.org/2001/04/xmlenc#rsa-1_5"/></xenc:EncryptedKey>
+      </ds:KeyInfo>
+    </xenc:EncryptedData>
+  </saml:EncryptedAssertion>
+</samlp:Response>`
+
+	findings, err := Audit([]byte(xml), AuditOptions{})
+	require.NoError(t, err)
+
+	assert.Equal(t, []AuditFinding{
+		{Check: CheckWeakAlgorithm, Severity: SeverityHigh, Message: "EncryptionMethod uses the weak algorithm rsa-1_5"},
+		{Check: CheckMissingSignature, Severity: SeverityLow, Message: "Response is not signed and its encrypted assertion could not be checked"},
+	}, findings)
+}
+
+func TestAudit_MissingSignatures(t *testing.T) {
+	tests := []struct {
+		name     string
+		xml      string
+		severity string
+		message  string
+	}{
+		{
+			name:     "neither signed",
+			xml:      `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_r"><saml:Assertion ID="_a"/></samlp:Response>`,
+			severity: SeverityHigh,
+			message:  "neither the Response nor the Assertion is signed",
+		},
+		{
+			name:     "assertion unsigned",
+			xml:      `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_r"><ds:Signature xmlns:ds="http://www.w3.org/2000/09/xmldsig#"/><saml:Assertion ID="_a"/></samlp:Response>`,
+			severity: SeverityLow,
+			message:  "Assertion is not signed; it is only protected by the Response signature",
+		},
+		{
+			name:     "response unsigned",
+			xml:      `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_r"><saml:Assertion ID="_a"><ds:Signature xmlns:ds="http://www.w3.org/2000/09/xmldsig#"/></saml:Assertion></samlp:Response>`,
+			severity: SeverityLow,
+			message:  "Response is not signed; Status, Destination and InResponseTo are not protected",
+		},
+		{
+			name:     "bare assertion",
+			xml:      `<saml:Assertion xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_a"/>`,
+			severity: SeverityHigh,
+			message:  "Assertion is not signed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			findings, err := Audit([]byte(tt.xml), AuditOptions{})
+			require.NoError(t, err)
+
+			assert.Contains(t, findings, AuditFinding{Check: CheckMissingSignature, Severity: tt.severity, Message: tt.message})
+			assert.Contains(t, auditChecks(findings), CheckMissingAudience)
+		})
+	}
+}
+
+func TestAudit_Redirect(t *testing.T) {
+	xml := []byte(`<samlp:AuthnRequest xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_req"/>`)
+
+	findings, err := Audit(xml, AuditOptions{RedirectQuery: url.Values{"SAMLRequest": {"x"}}})
+	require.NoError(t, err)
+	assert.Equal(t, []AuditFinding{
+		{Check: CheckUnsignedRedirect, Severity: SeverityMedium, Message: "AuthnRequest sent with the HTTP-Redirect binding is not signed"},
+	}, findings)
+
+	findings, err = Audit(xml, AuditOptions{RedirectQuery: url.Values{"SAMLRequest": {"x"}, "SigAlg": {SigAlgRSASHA1}, "Signature": {"sig"}}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{CheckWeakAlgorithm}, auditChecks(findings))
+
+	findings, err = Audit(xml, AuditOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, findings)
+}
+
+func TestAudit_TrustedCertificates(t *testing.T) {
+	signing, rolledOver := testCertificates(t)
+	b64 := func(c *x509.Certificate) string { return base64.StdEncoding.EncodeToString(c.Raw) }
+
+	metadata := `<md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" xmlns:ds="http://www.w3.org/2000/09/xmldsig#" entityID="https://idp.example.com">
+  <md:IDPSSODescriptor>
+    <md:KeyDescriptor use="signing"><ds:KeyInfo><ds:X509Data><ds:X509Certificate>` + b64(signing) + `</ds:X509Certificate></ds:X509Data></ds:KeyInfo></md:KeyDescriptor>
+    <md:KeyDescriptor use="encryption"><ds:KeyInfo><ds:X509Data><ds:X509Certificate>` + b64(rolledOver) + `</ds:X509Certificate></ds:X509Data></ds:KeyInfo></md:KeyDescriptor>
+  </md:IDPSSODescriptor>
+</md:EntityDescriptor>`
+	trusted, err := MetadataSigningCertificates([]byte(metadata))
+	require.NoError(t, err)
+	require.Len(t, trusted, 1)
+
+	assertion := func(keyInfo string) string {
+		return `<saml:Assertion xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_a">
+  <ds:Signature xmlns:ds="http://www.w3.org/2000/09/xmldsig#">
+    <ds:SignedInfo><ds:Reference URI="#_a"/></ds:SignedInfo>` + keyInfo + `
+  </ds:Signature>
+</saml:Assertion>`
+	}
+	keyInfo := func(c *x509.Certificate) string {
+		return `<ds:KeyInfo><ds:X509Data><ds:X509Certificate>` + b64(c) + `</ds:X509Certificate></ds:X509Data></ds:KeyInfo>`
+	}
+	mismatches := func(findings []AuditFinding) []AuditFinding {
+		var result []AuditFinding
+		for _, f := range findings {
+			if f.Check == CheckCertMismatch {
+				result = append(result, f)
+			}
+		}
+		return result
+	}
+
+	opts := DefaultAuditOptions()
+	opts.TrustedCertificates = trusted
+
+	findings, err := Audit([]byte(assertion(keyInfo(signing))), opts)
+	require.NoError(t, err)
+	assert.Empty(t, mismatches(findings))
+
+	findings, err = Audit([]byte(assertion(keyInfo(rolledOver))), opts)
+	require.NoError(t, err)
+	require.Len(t, mismatches(findings), 1)
+	f := mismatches(findings)[0]
+	assert.Equal(t, SeverityHigh, f.Severity)
+	assert.Contains(t, f.Message, `signature in <saml:Assertion ID="_a"> uses certificate "CN=idp.example.com"`)
+	assert.Contains(t, f.Message, Fingerprint(rolledOver.Raw, sha256Sum))
+	assert.Contains(t, f.Message, "matches none of the trusted certificates ("+Fingerprint(signing.Raw, sha256Sum)+")")
+
+	findings, err = Audit([]byte(assertion("")), opts)
+	require.NoError(t, err)
+	require.Len(t, mismatches(findings), 1)
+	assert.Equal(t, SeverityLow, mismatches(findings)[0].Severity)
+
+	// Without trusted certificates the check is skipped
+	findings, err = Audit([]byte(assertion(keyInfo(rolledOver))), DefaultAuditOptions())
+	require.NoError(t, err)
+	assert.Empty(t, mismatches(findings))
+}
+
+func TestMetadataSigningCertificates_None(t *testing.T) {
+	_, err := MetadataSigningCertificates([]byte(`<md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata"/>`))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "metadata has no signing certificates")
+}
@@ -0,0 +1,158 @@
+package saml
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Comparison methods of a RequestedAuthnContext
+const (
+	ComparisonExact   = "exact"
+	ComparisonMinimum = "minimum"
+	ComparisonBetter  = "better"
+	ComparisonMaximum = "maximum"
+)
+
+const authnContextClassPrefix = "urn:oasis:names:tc:SAML:2.0:ac:classes:"
+
+// authnContextStrength ranks well-known authentication context classes by
+// the assurance they give. SAML leaves the order to the IdP; this ranking
+// follows common IdP practice: passwords below Kerberos and client
+// certificates, below multi-factor authentication.
+var authnContextStrength = map[string]int{
+	authnContextClassPrefix + "unspecified":                 0,
+	authnContextClassPrefix + "InternetProtocol":            1,
+	authnContextClassPrefix + "InternetProtocolPassword":    2,
+	authnContextClassPrefix + "Password":                    2,
+	authnContextClassPrefix + "PreviousSession":             2,
+	authnContextClassPrefix + "PasswordProtectedTransport":  3,
+	authnContextClassPrefix + "SecureRemotePassword":        3,
+	"https://refeds.org/profile/sfa":                        3,
+	authnContextClassPrefix + "Kerberos":                    4,
+	authnContextClassPrefix + "TLSClient":                   4,
+	authnContextClassPrefix + "X509":                        4,
+	authnContextClassPrefix + "PGP":                         4,
+	authnContextClassPrefix + "SPKI":                        4,
+	authnContextClassPrefix + "XMLDSig":                     4,
+	authnContextClassPrefix + "SoftwarePKI":                 4,
+	authnContextClassPrefix + "MobileOneFactorContract":     4,
+	authnContextClassPrefix + "MobileOneFactorUnregistered": 4,
+	authnContextClassPrefix + "Smartcard":                   5,
+	authnContextClassPrefix + "SmartcardPKI":                5,
+	authnContextClassPrefix + "TimeSyncToken":               5,
+	authnContextClassPrefix + "MobileTwoFactorContract":     5,
+	authnContextClassPrefix + "MobileTwoFactorUnregistered": 5,
+	"https://refeds.org/profile/mfa":                        5,
+	"http://schemas.microsoft.com/claims/multipleauthn":     5,
+}
+
+// EffectiveComparison returns the comparison method, exact if none is given
+func (r *RequestedAuthnContext) EffectiveComparison() string {
+	if r.Comparison == "" {
+		return ComparisonExact
+	}
+	return r.Comparison
+}
+
+// CheckAuthnContext reports whether the authentication context class of a
+// response satisfies the requested context, as the comparison method
+// defines it: exact requires one of the requested classes, minimum one at
+// least as strong as one of them, better one stronger than one of them,
+// and maximum one no stronger than one of them. Strength is known only for
+// well-known classes; when it decides but is unknown, the result is not
+// determined and the problem says so. Requests naming only declarations
+// are not checked.
+func CheckAuthnContext(requested *RequestedAuthnContext, classRef string) (satisfied, determined bool, problem string) {
+	if requested == nil || len(requested.ClassRefs) == 0 {
+		return true, true, ""
+	}
+	comparison := requested.EffectiveComparison()
+	if classRef == "" {
+		return false, true, fmt.Sprintf("no AuthnContextClassRef, but the request asks for %s (%s comparison)", shortAuthnContexts(requested.ClassRefs), comparison)
+	}
+
+	exactMatch := false
+	for _, ref := range requested.ClassRefs {
+		if ref == classRef {
+			exactMatch = true
+		}
+	}
+
+	switch comparison {
+	case ComparisonExact:
+		if exactMatch {
+			return true, true, ""
+		}
+		return false, true, fmt.Sprintf("authentication context %s was not requested; the request asks for %s", shortAuthnContext(classRef), shortAuthnContexts(requested.ClassRefs))
+	case ComparisonMinimum, ComparisonMaximum:
+		if exactMatch {
+			return true, true, ""
+		}
+	case ComparisonBetter:
+	default:
+		return false, true, fmt.Sprintf("the request has the unknown comparison %q", comparison)
+	}
+
+	strength, known := authnContextStrength[classRef]
+	if !known {
+		return false, false, fmt.Sprintf("cannot tell whether authentication context %s is %s %s: its strength is unknown",
+			shortAuthnContext(classRef), comparisonPhrase(comparison), shortAuthnContexts(requested.ClassRefs))
+	}
+	allKnown := true
+	for _, ref := range requested.ClassRefs {
+		requestedStrength, ok := authnContextStrength[ref]
+		if !ok {
+			allKnown = false
+			continue
+		}
+		if comparison == ComparisonMinimum && strength >= requestedStrength ||
+			comparison == ComparisonBetter && strength > requestedStrength ||
+			comparison == ComparisonMaximum && strength <= requestedStrength {
+			return true, true, ""
+		}
+	}
+	if !allKnown {
+		return false, false, fmt.Sprintf("cannot tell whether authentication context %s is %s %s: the strength of a requested context is unknown",
+			shortAuthnContext(classRef), comparisonPhrase(comparison), shortAuthnContexts(requested.ClassRefs))
+	}
+	return false, true, fmt.Sprintf("authentication context %s is not %s %s", shortAuthnContext(classRef), comparisonPhrase(comparison), shortAuthnContexts(requested.ClassRefs))
+}
+
+// comparisonPhrase describes a comparison method for messages
+func comparisonPhrase(comparison string) string {
+	switch comparison {
+	case ComparisonMinimum:
+		return "at least as strong as"
+	case ComparisonBetter:
+		return "stronger than"
+	}
+	return "at most as strong as"
+}
+
+func shortAuthnContext(ref string) string {
+	return strings.TrimPrefix(ref, authnContextClassPrefix)
+}
+
+func shortAuthnContexts(refs []string) string {
+	short := make([]string, len(refs))
+	for i, ref := range refs {
+		short[i] = shortAuthnContext(ref)
+	}
+	if len(short) == 1 {
+		return short[0]
+	}
+	return "any of " + strings.Join(short, ", ")
+}
+
+// trimAll returns values with surrounding whitespace removed, or nil if
+// there are none
+func trimAll(values []string) []string {
+	if len(values) == 0 {
+		return nil
+	}
+	trimmed := make([]string, len(values))
+	for i, v := range values {
+		trimmed[i] = strings.TrimSpace(v)
+	}
+	return trimmed
+}
@@ -0,0 +1,59 @@
+package saml
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckAuthnContext(t *testing.T) {
+	const (
+		password = "urn:oasis:names:tc:SAML:2.0:ac:classes:PasswordProtectedTransport"
+		x509     = "urn:oasis:names:tc:SAML:2.0:ac:classes:X509"
+		mfa      = "https://refeds.org/profile/mfa"
+		custom   = "https://idp.example.com/ac/custom"
+	)
+
+	tests := []struct {
+		name       string
+		requested  *RequestedAuthnContext
+		classRef   string
+		satisfied  bool
+		determined bool
+		problem    string
+	}{
+		{"nothing requested", nil, password, true, true, ""},
+		{"only declarations requested", &RequestedAuthnContext{DeclRefs: []string{"urn:decl"}}, password, true, true, ""},
+		{"exact by default", &RequestedAuthnContext{ClassRefs: []string{password}}, password, true, true, ""},
+		{"exact mismatch", &RequestedAuthnContext{ClassRefs: []string{password}}, x509, false, true,
+			"authentication context X509 was not requested; the request asks for PasswordProtectedTransport"},
+		{"exact of several", &RequestedAuthnContext{Comparison: "exact", ClassRefs: []string{password, x509}}, x509, true, true, ""},
+		{"missing class", &RequestedAuthnContext{ClassRefs: []string{password, x509}}, "", false, true,
+			"no AuthnContextClassRef, but the request asks for any of PasswordProtectedTransport, X509 (exact comparison)"},
+		{"minimum stronger", &RequestedAuthnContext{Comparison: "minimum", ClassRefs: []string{password}}, mfa, true, true, ""},
+		{"minimum weaker", &RequestedAuthnContext{Comparison: "minimum", ClassRefs: []string{x509}}, password, false, true,
+			"authentication context PasswordProtectedTransport is not at least as strong as X509"},
+		{"minimum unknown class", &RequestedAuthnContext{Comparison: "minimum", ClassRefs: []string{password}}, custom, false, false,
+			"cannot tell whether authentication context https://idp.example.com/ac/custom is at least as strong as PasswordProtectedTransport: its strength is unknown"},
+		{"minimum equal", &RequestedAuthnContext{Comparison: "minimum", ClassRefs: []string{custom}}, custom, true, true, ""},
+		{"better equal", &RequestedAuthnContext{Comparison: "better", ClassRefs: []string{x509}}, x509, false, true,
+			"authentication context X509 is not stronger than X509"},
+		{"better stronger", &RequestedAuthnContext{Comparison: "better", ClassRefs: []string{password}}, x509, true, true, ""},
+		{"better unknown request", &RequestedAuthnContext{Comparison: "better", ClassRefs: []string{custom}}, mfa, false, false,
+			"cannot tell whether authentication context https://refeds.org/profile/mfa is stronger than https://idp.example.com/ac/custom: the strength of a requested context is unknown"},
+		{"maximum weaker", &RequestedAuthnContext{Comparison: "maximum", ClassRefs: []string{x509}}, password, true, true, ""},
+		{"maximum stronger", &RequestedAuthnContext{Comparison: "maximum", ClassRefs: []string{password}}, mfa, false, true,
+			"authentication context https://refeds.org/profile/mfa is not at most as strong as PasswordProtectedTransport"},
+		{"unknown comparison", &RequestedAuthnContext{Comparison: "stronger", ClassRefs: []string{password}}, password, false, true,
+			`the request has the unknown comparison "stronger"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			satisfied, determined, problem := CheckAuthnContext(tt.requested, tt.classRef)
+			assert.Equal(t, tt.satisfied, satisfied)
+			assert.Equal(t, tt.determined, determined)
+			assert.Equal(t, tt.problem, problem)
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package saml
+
+import (
+	"errors"
+	"time"
+
+	"github.com/beevik/etree"
+)
+
+// AuthnRequestOptions describes an AuthnRequest to build
+type AuthnRequestOptions struct {
+	// Issuer is the entity ID of the SP
+	Issuer string
+
+	// Destination is the SingleSignOnService URL of the IdP
+	Destination string
+
+	// ACS is the AssertionConsumerService URL the Response is posted to
+	ACS string
+
+	// NameIDFormat is requested in the NameIDPolicy; empty omits the
+	// Format attribute
+	NameIDFormat string
+
+	// ForceAuthn and IsPassive are sent when true
+	ForceAuthn bool
+	IsPassive  bool
+}
+
+// BuildAuthnRequest builds an unsigned AuthnRequest issued at now, asking
+// for a Response with the HTTP-POST binding. The root element has a fresh
+// ID, so it can be signed with SignEnveloped.
+func BuildAuthnRequest(opts AuthnRequestOptions, now time.Time) (*etree.Document, error) {
+	if opts.Issuer == "" {
+		return nil, errors.New("an issuer is required")
+	}
+	if opts.ACS == "" {
+		return nil, errors.New("an AssertionConsumerService URL is required")
+	}
+	id, err := NewID()
+	if err != nil {
+		return nil, err
+	}
+
+	doc := etree.NewDocument()
+	doc.CreateProcInst("xml", `version="1.0" encoding="UTF-8"`)
+	req := doc.CreateElement("samlp:AuthnRequest")
+	req.CreateAttr("xmlns:samlp", SAMLPNamespace)
+	req.CreateAttr("xmlns:saml", SAMLNamespace)
+	req.CreateAttr("ID", id)
+	req.CreateAttr("Version", "2.0")
+	req.CreateAttr("IssueInstant", now.UTC().Format(time.RFC3339))
+	if opts.Destination != "" {
+		req.CreateAttr("Destination", opts.Destination)
+	}
+	req.CreateAttr("AssertionConsumerServiceURL", opts.ACS)
+	req.CreateAttr("ProtocolBinding", BindingHTTPPost)
+	if opts.ForceAuthn {
+		req.CreateAttr("ForceAuthn", "true")
+	}
+	if opts.IsPassive {
+		req.CreateAttr("IsPassive", "true")
+	}
+
+	// Elements follow the order of the protocol schema
+	req.CreateElement("saml:Issuer").SetText(opts.Issuer)
+	policy := req.CreateElement("samlp:NameIDPolicy")
+	if opts.NameIDFormat != "" {
+		policy.CreateAttr("Format", opts.NameIDFormat)
+	}
+	policy.CreateAttr("AllowCreate", "true")
+
+	doc.Indent(2)
+	return doc, nil
+}
@@ -0,0 +1,46 @@
+package saml
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildAuthnRequest(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	doc, err := BuildAuthnRequest(AuthnRequestOptions{
+		Issuer:       "https://sp.example.com",
+		Destination:  "https://idp.example.com/sso",
+		ACS:          "https://sp.example.com/acs",
+		NameIDFormat: "urn:oasis:names:tc:SAML:2.0:nameid-format:persistent",
+		ForceAuthn:   true,
+	}, now)
+	require.NoError(t, err)
+	xmlData, err := doc.WriteToBytes()
+	require.NoError(t, err)
+
+	info, err := NewParser().Parse(xmlData)
+	require.NoError(t, err)
+	assert.Equal(t, "AuthnRequest", info.Type)
+	assert.Equal(t, doc.Root().SelectAttrValue("ID", ""), info.ID)
+	assert.Equal(t, "https://sp.example.com", info.Issuer)
+	assert.Equal(t, "https://idp.example.com/sso", info.Destination)
+	assert.Equal(t, "https://sp.example.com/acs", info.AssertionConsumerServiceURL)
+	assert.Equal(t, BindingHTTPPost, info.ProtocolBinding)
+	assert.Equal(t, now, *info.IssueInstant)
+	require.NotNil(t, info.ForceAuthn)
+	assert.True(t, *info.ForceAuthn)
+	assert.Nil(t, info.IsPassive)
+	require.NotNil(t, info.NameIDPolicy)
+	assert.Equal(t, "urn:oasis:names:tc:SAML:2.0:nameid-format:persistent", info.NameIDPolicy.Format)
+}
+
+func TestBuildAuthnRequest_Invalid(t *testing.T) {
+	_, err := BuildAuthnRequest(AuthnRequestOptions{ACS: "https://sp.example.com/acs"}, time.Now())
+	assert.ErrorContains(t, err, "issuer is required")
+
+	_, err = BuildAuthnRequest(AuthnRequestOptions{Issuer: "https://sp.example.com"}, time.Now())
+	assert.ErrorContains(t, err, "AssertionConsumerService URL is required")
+}
@@ -0,0 +1,89 @@
+package saml
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"net/url"
+	"strings"
+)
+
+// SAML 2.0 binding URIs of the bindings messages are built for
+const (
+	BindingHTTPRedirect = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect"
+	BindingHTTPPost     = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST"
+)
+
+// RedirectURL returns the URL that sends xmlData to destination with the
+// HTTP-Redirect binding: deflated, base64-encoded and added to the query
+// as paramName, followed by RelayState. If key is set, the query is signed
+// with sigAlg as the binding specifies.
+func RedirectURL(destination string, xmlData []byte, paramName, relayState string, key crypto.Signer, sigAlg string) (string, error) {
+	encoded, err := NewDecoder().EncodeDeflate(xmlData)
+	if err != nil {
+		return "", fmt.Errorf("failed to deflate message: %w", err)
+	}
+
+	// The signature covers the parameters exactly as they appear in the URL
+	query := paramName + "=" + url.QueryEscape(encoded)
+	if relayState != "" {
+		query += "&RelayState=" + url.QueryEscape(relayState)
+	}
+	if key != nil {
+		hash, ok := sigAlgHashes[sigAlg]
+		if !ok {
+			return "", fmt.Errorf("unsupported signature algorithm: %s", sigAlg)
+		}
+		query += "&SigAlg=" + url.QueryEscape(sigAlg)
+		signature, err := signContent(key, sigAlg, hash, []byte(query))
+		if err != nil {
+			return "", err
+		}
+		query += "&Signature=" + url.QueryEscape(base64.StdEncoding.EncodeToString(signature))
+	}
+
+	separator := "?"
+	if strings.Contains(destination, "?") {
+		separator = "&"
+	}
+	return destination + separator + query, nil
+}
+
+var postFormTemplate = template.Must(template.New("post").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>SAML HTTP-POST binding</title>
+</head>
+<body onload="document.forms[0].submit()">
+<form method="post" action="{{.Destination}}">
+<input type="hidden" name="{{.ParamName}}" value="{{.Message}}">
+{{- if .RelayState}}
+<input type="hidden" name="RelayState" value="{{.RelayState}}">
+{{- end}}
+<noscript><button type="submit">Continue</button></noscript>
+</form>
+</body>
+</html>
+`))
+
+// PostForm returns an HTML page that posts xmlData, base64-encoded as
+// paramName, and relayState to destination with the HTTP-POST binding as
+// soon as it loads
+func PostForm(destination string, xmlData []byte, paramName, relayState string) (string, error) {
+	var buf bytes.Buffer
+	err := postFormTemplate.Execute(&buf, struct {
+		Destination, ParamName, Message, RelayState string
+	}{
+		Destination: destination,
+		ParamName:   paramName,
+		Message:     base64.StdEncoding.EncodeToString(xmlData),
+		RelayState:  relayState,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to render form: %w", err)
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,268 @@
+package saml
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Cause is a likely reason for a failure reported by a message, with the
+// next step to confirm or fix it
+type Cause struct {
+	Summary  string `json:"summary"`
+	NextStep string `json:"next_step"`
+}
+
+// causeRule recognizes a failure signature. Empty fields match anything;
+// check, if set, must hold too. A fallback rule applies only if no other
+// rule of the product matched.
+type causeRule struct {
+	vendor    string
+	status    string
+	subStatus string
+	message   *regexp.Regexp
+	check     func(info *SAMLInfo) bool
+	fallback  bool
+	cause     Cause
+}
+
+// vendorCauseRules are failure signatures of particular IdP products. They
+// take precedence over genericCauseRules.
+var vendorCauseRules = []causeRule{
+	{
+		vendor:    VendorADFS,
+		subStatus: "InvalidNameIDPolicy",
+		cause: Cause{
+			Summary:  "No issuance transform rule of the relying party trust emits a Name ID in the format the AuthnRequest's NameIDPolicy asks for",
+			NextStep: "Add a rule transforming a claim to Name ID with that format, or drop the Format from the SP's NameIDPolicy",
+		},
+	},
+	{
+		vendor:    VendorADFS,
+		subStatus: "NoAuthnContext",
+		cause: Cause{
+			Summary:  "AD FS cannot satisfy the RequestedAuthnContext, e.g. PasswordProtectedTransport for a user signed in with Windows integrated authentication",
+			NextStep: "Have the SP send no RequestedAuthnContext, or enable the authentication method it asks for on AD FS",
+		},
+	},
+	{
+		vendor: VendorADFS,
+		status: "Responder",
+		cause: Cause{
+			Summary:  "AD FS hides the reason for the failure behind the Responder status",
+			NextStep: "Look up event 364 in the AD FS/Admin event log at the time of the request; it names the error, e.g. MSIS7007 when no relying party trust has the AuthnRequest's Issuer as identifier",
+		},
+	},
+	{
+		vendor:  VendorEntraID,
+		message: regexp.MustCompile(`AADSTS50011\b`),
+		cause: Cause{
+			Summary:  "The ACS URL of the AuthnRequest is not a Reply URL of the enterprise application",
+			NextStep: "Add the AssertionConsumerServiceURL of the AuthnRequest to the Reply URLs of the enterprise application",
+		},
+	},
+	{
+		vendor:  VendorEntraID,
+		message: regexp.MustCompile(`AADSTS700016\b`),
+		cause: Cause{
+			Summary:  "No enterprise application in the tenant has the AuthnRequest's Issuer as Identifier (Entity ID)",
+			NextStep: "Set the Identifier of the enterprise application to the SP's entity ID, or send the right tenant's SSO URL",
+		},
+	},
+	{
+		vendor:  VendorEntraID,
+		message: regexp.MustCompile(`AADSTS50105\b`),
+		cause: Cause{
+			Summary:  "The user is not assigned to the enterprise application, which requires assignment",
+			NextStep: "Assign the user or one of their groups to the application, or turn off \"Assignment required\"",
+		},
+	},
+	{
+		vendor:  VendorEntraID,
+		message: regexp.MustCompile(`AADSTS75011\b`),
+		cause: Cause{
+			Summary:  "The user's session was authenticated with another method than the RequestedAuthnContext asks for",
+			NextStep: "Have the SP send no RequestedAuthnContext, or send ForceAuthn=\"true\" to make the user sign in again",
+		},
+	},
+	{
+		vendor:  VendorEntraID,
+		message: regexp.MustCompile(`AADSTS5007[69]\b`),
+		cause: Cause{
+			Summary:  "A Conditional Access policy requires multi-factor authentication",
+			NextStep: "Check the sign-in logs for the policy; passive requests cannot satisfy it",
+		},
+	},
+	{
+		vendor:  VendorEntraID,
+		message: regexp.MustCompile(`AADSTS750054\b`),
+		cause: Cause{
+			Summary:  "The AuthnRequest was not sent as SAMLRequest in the query string of the HTTP-Redirect binding",
+			NextStep: "Send the AuthnRequest with the HTTP-Redirect binding; check the SP's IdP SSO URL and binding",
+		},
+	},
+	{
+		vendor:   VendorEntraID,
+		message:  regexp.MustCompile(`AADSTS\d+`),
+		fallback: true,
+		cause: Cause{
+			Summary:  "Entra ID rejected the request with the AADSTS error in the status message",
+			NextStep: "Look up the error and its correlation ID in the sign-in logs of the enterprise application",
+		},
+	},
+	{
+		vendor: VendorOkta,
+		status: "Success",
+		check:  lacksAudience,
+		cause: Cause{
+			Summary:  "The assertion has no audience: the Okta app's Audience URI (SP Entity ID) is empty",
+			NextStep: "Set Audience URI in the app's SAML settings to the SP's entity ID",
+		},
+	},
+	{
+		vendor:    VendorShibboleth,
+		subStatus: "InvalidNameIDPolicy",
+		cause: Cause{
+			Summary:  "No NameID generator of the IdP produces the format the AuthnRequest's NameIDPolicy asks for",
+			NextStep: "Configure a generator for the format in saml-nameid.xml and allow it with nameIDFormatPrecedence in relying-party.xml",
+		},
+	},
+	{
+		vendor:  VendorShibboleth,
+		message: regexp.MustCompile(`(?i)encrypt`),
+		cause: Cause{
+			Summary:  "The IdP found no encryption certificate for the SP",
+			NextStep: "Add a KeyDescriptor with use=\"encryption\" to the SP's metadata loaded by the IdP, or turn off encryption for the SP",
+		},
+	},
+}
+
+// genericCauseRules are failure signatures common to all IdPs
+var genericCauseRules = []causeRule{
+	{
+		subStatus: "InvalidNameIDPolicy",
+		cause: Cause{
+			Summary:  "The IdP cannot issue a NameID in the format the AuthnRequest's NameIDPolicy asks for",
+			NextStep: "Compare the NameIDPolicy Format with the formats in the IdP's metadata, or drop the Format",
+		},
+	},
+	{
+		subStatus: "NoAuthnContext",
+		cause: Cause{
+			Summary:  "The IdP cannot satisfy the RequestedAuthnContext of the AuthnRequest",
+			NextStep: "Have the SP request a context the IdP supports, or none",
+		},
+	},
+	{
+		subStatus: "NoPassive",
+		cause: Cause{
+			Summary:  "The AuthnRequest was passive (IsPassive) but the user had no session at the IdP",
+			NextStep: "Treat NoPassive as not signed in, or retry without IsPassive",
+		},
+	},
+	{
+		subStatus: "RequestDenied",
+		cause: Cause{
+			Summary:  "The IdP refused the request, often because it does not know the SP or the user may not use it",
+			NextStep: "Check that the IdP has the SP's current metadata and that the user is permitted",
+		},
+	},
+	{
+		subStatus: "AuthnFailed",
+		cause: Cause{
+			Summary:  "The user could not be authenticated",
+			NextStep: "Check the IdP's logs for the user's sign-in attempt",
+		},
+	},
+	{
+		subStatus: "UnknownPrincipal",
+		cause: Cause{
+			Summary:  "The IdP does not know the user named in the request",
+			NextStep: "Check the Subject of the request against the IdP's user store",
+		},
+	},
+	{
+		subStatus: "UnsupportedBinding",
+		cause: Cause{
+			Summary:  "The IdP does not support the binding the response should be sent with",
+			NextStep: "Compare the AuthnRequest's ProtocolBinding with the SP's ACS bindings in the IdP's copy of its metadata",
+		},
+	},
+	{
+		status: "VersionMismatch",
+		cause: Cause{
+			Summary:  "The IdP does not support the SAML version of the request",
+			NextStep: "Check that the SP sends SAML 2.0 messages to a SAML 2.0 endpoint",
+		},
+	},
+}
+
+// PossibleCauses returns the likely causes of the failure a message
+// reports, recognized by the status and the IdP product that issued it.
+// Causes specific to the product come first; generic ones are returned
+// only if no specific one matched.
+func PossibleCauses(info *SAMLInfo) []Cause {
+	vendor := DetectVendor(info)
+	if info.Vendor != nil {
+		vendor = info.Vendor.Vendor
+	}
+
+	var causes, fallbacks []Cause
+	for _, rule := range vendorCauseRules {
+		switch {
+		case rule.vendor != vendor || !rule.matches(info):
+		case rule.fallback:
+			fallbacks = append(fallbacks, rule.cause)
+		default:
+			causes = append(causes, rule.cause)
+		}
+	}
+	if len(causes) > 0 {
+		return causes
+	}
+	if len(fallbacks) > 0 {
+		return fallbacks
+	}
+	for _, rule := range genericCauseRules {
+		if rule.matches(info) {
+			causes = append(causes, rule.cause)
+		}
+	}
+	return causes
+}
+
+func (r causeRule) matches(info *SAMLInfo) bool {
+	status := info.Status
+	if status == nil {
+		return false
+	}
+	if r.status != "" && r.status != status.StatusCode {
+		return false
+	}
+	if r.status == "" && status.StatusCode == "Success" {
+		return false
+	}
+	if r.subStatus != "" && r.subStatus != status.SubStatusCode {
+		return false
+	}
+	if r.message != nil && !r.message.MatchString(status.StatusMessage) {
+		return false
+	}
+	return r.check == nil || r.check(info)
+}
+
+// lacksAudience reports whether a response's assertion has no audience
+func lacksAudience(info *SAMLInfo) bool {
+	assertion := info.Assertion
+	if assertion == nil {
+		return false
+	}
+	if assertion.Conditions == nil {
+		return true
+	}
+	for _, audience := range assertion.Conditions.AudienceRestriction {
+		if strings.TrimSpace(audience) != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,77 @@
+package saml
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPossibleCauses(t *testing.T) {
+	tests := []struct {
+		name string
+		info *SAMLInfo
+		want []string
+	}{
+		{
+			name: "AD FS Responder",
+			info: &SAMLInfo{Issuer: "http://adfs.example.com/adfs/services/trust", Status: &Status{StatusCode: "Responder"}},
+			want: []string{"AD FS hides the reason for the failure behind the Responder status"},
+		},
+		{
+			name: "AD FS InvalidNameIDPolicy",
+			info: &SAMLInfo{Issuer: "http://adfs.example.com/adfs/services/trust", Status: &Status{StatusCode: "Responder", SubStatusCode: "InvalidNameIDPolicy"}},
+			want: []string{
+				"No issuance transform rule of the relying party trust emits a Name ID in the format the AuthnRequest's NameIDPolicy asks for",
+				"AD FS hides the reason for the failure behind the Responder status",
+			},
+		},
+		{
+			name: "Entra ID reply URL",
+			info: &SAMLInfo{Issuer: "https://sts.windows.net/tenant/", Status: &Status{StatusCode: "Requester", StatusMessage: "AADSTS50011: The redirect URI specified in the request does not match."}},
+			want: []string{"The ACS URL of the AuthnRequest is not a Reply URL of the enterprise application"},
+		},
+		{
+			name: "Entra ID unknown AADSTS code",
+			info: &SAMLInfo{Issuer: "https://sts.windows.net/tenant/", Status: &Status{StatusCode: "Requester", StatusMessage: "AADSTS90072: User account does not exist in tenant."}},
+			want: []string{"Entra ID rejected the request with the AADSTS error in the status message"},
+		},
+		{
+			name: "Okta without audience",
+			info: &SAMLInfo{Issuer: "http://www.okta.com/exk1", Status: &Status{StatusCode: "Success"}, Assertion: &SAMLInfo{Conditions: &Conditions{}}},
+			want: []string{"The assertion has no audience: the Okta app's Audience URI (SP Entity ID) is empty"},
+		},
+		{
+			name: "generic NoPassive",
+			info: &SAMLInfo{Issuer: "https://idp.example.com", Status: &Status{StatusCode: "Responder", SubStatusCode: "NoPassive"}},
+			want: []string{"The AuthnRequest was passive (IsPassive) but the user had no session at the IdP"},
+		},
+		{
+			name: "fingerprinted vendor",
+			info: &SAMLInfo{
+				Issuer: "https://idp.example.com",
+				Status: &Status{StatusCode: "Responder", SubStatusCode: "InvalidNameIDPolicy"},
+				Vendor: &VendorFingerprint{Vendor: VendorShibboleth},
+			},
+			want: []string{"No NameID generator of the IdP produces the format the AuthnRequest's NameIDPolicy asks for"},
+		},
+		{
+			name: "success",
+			info: &SAMLInfo{Issuer: "http://adfs.example.com/adfs/services/trust", Status: &Status{StatusCode: "Success"}},
+		},
+		{
+			name: "no status",
+			info: &SAMLInfo{Type: "Assertion", Issuer: "http://www.okta.com/exk1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var summaries []string
+			for _, cause := range PossibleCauses(tt.info) {
+				assert.NotEmpty(t, cause.NextStep)
+				summaries = append(summaries, cause.Summary)
+			}
+			assert.Equal(t, tt.want, summaries)
+		})
+	}
+}
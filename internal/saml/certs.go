@@ -0,0 +1,105 @@
+package saml
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"strings"
+
+	"github.com/beevik/etree"
+)
+
+// NewCertificateInfo summarizes cert, including its fingerprints
+func NewCertificateInfo(cert *x509.Certificate) *CertificateInfo {
+	return &CertificateInfo{
+		Subject:           cert.Subject.String(),
+		Issuer:            cert.Issuer.String(),
+		NotBefore:         cert.NotBefore,
+		NotAfter:          cert.NotAfter,
+		Serial:            cert.SerialNumber.String(),
+		SHA1Fingerprint:   Fingerprint(cert.Raw, sha1Sum),
+		SHA256Fingerprint: Fingerprint(cert.Raw, sha256Sum),
+	}
+}
+
+func sha1Sum(data []byte) []byte {
+	sum := sha1.Sum(data)
+	return sum[:]
+}
+
+func sha256Sum(data []byte) []byte {
+	sum := sha256.Sum256(data)
+	return sum[:]
+}
+
+// Fingerprint returns the colon-separated hex digest of der, as shown by
+// openssl x509 -fingerprint
+func Fingerprint(der []byte, sum func([]byte) []byte) string {
+	digest := sum(der)
+	parts := make([]string, len(digest))
+	for i, b := range digest {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(parts, ":")
+}
+
+// ExtractedCertificate is an X.509 certificate found in a SAML document
+type ExtractedCertificate struct {
+	*CertificateInfo
+
+	// Location describes where the certificate was found, e.g.
+	// "Signature" or "KeyDescriptor (signing)"
+	Location string `json:"location"`
+
+	Certificate *x509.Certificate `json:"-"`
+}
+
+// PEM returns the certificate in PEM encoding
+func (c ExtractedCertificate) PEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.Certificate.Raw})
+}
+
+// ExtractCertificates returns every X509Certificate in a SAML message or
+// metadata document, in document order. Certificates that cannot be
+// parsed are skipped.
+func ExtractCertificates(xmlData []byte) ([]ExtractedCertificate, error) {
+	doc, err := ReadDocument(xmlData)
+	if err != nil {
+		return nil, &ErrParse{Stage: "XML", Err: err}
+	}
+
+	var certs []ExtractedCertificate
+	for _, el := range doc.FindElements("//X509Certificate") {
+		cert, err := ParseCertificate([]byte(el.Text()))
+		if err != nil {
+			continue
+		}
+		certs = append(certs, ExtractedCertificate{
+			CertificateInfo: NewCertificateInfo(cert),
+			Location:        certificateLocation(el),
+			Certificate:     cert,
+		})
+	}
+	return certs, nil
+}
+
+// certificateLocation names the element carrying a certificate: the
+// enclosing KeyDescriptor (with its use) or Signature
+func certificateLocation(el *etree.Element) string {
+	for p := el.Parent(); p != nil; p = p.Parent() {
+		switch p.Tag {
+		case "KeyDescriptor":
+			if use := p.SelectAttrValue("use", ""); use != "" {
+				return "KeyDescriptor (" + use + ")"
+			}
+			return "KeyDescriptor"
+		case "Signature":
+			return "Signature"
+		case "EncryptedKey", "EncryptedData":
+			return "Encryption KeyInfo"
+		}
+	}
+	return "KeyInfo"
+}
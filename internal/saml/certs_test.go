@@ -0,0 +1,87 @@
+package saml
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testCertificates returns two distinct self-signed certificates
+func testCertificates(t *testing.T) (*x509.Certificate, *x509.Certificate) {
+	t.Helper()
+	var certs []*x509.Certificate
+	for i := 0; i < 2; i++ {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		certs = append(certs, selfSignedCert(t, key))
+	}
+	return certs[0], certs[1]
+}
+
+func TestFingerprint(t *testing.T) {
+	assert.Equal(t, "A9:99:3E:36:47:06:81:6A:BA:3E:25:71:78:50:C2:6C:9C:D0:D8:9D", Fingerprint([]byte("abc"), sha1Sum))
+	assert.Len(t, Fingerprint([]byte("abc"), sha256Sum), 32*3-1)
+}
+
+func TestExtractCertificates(t *testing.T) {
+	signing, encryption := testCertificates(t)
+	b64 := func(c *x509.Certificate) string { return base64.StdEncoding.EncodeToString(c.Raw) }
+
+	metadata := `<md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" xmlns:ds="http://www.w3.org/2000/09/xmldsig#" entityID="https://idp.example.com">
+  <md:IDPSSODescriptor>
+    <md:KeyDescriptor use="signing"><ds:KeyInfo><ds:X509Data><ds:X509Certificate>
+      ` + b64(signing) + `
+    </ds:X509Certificate></ds:X509Data></ds:KeyInfo></md:KeyDescriptor>
+    <md:KeyDescriptor use="encryption"><ds:KeyInfo><ds:X509Data><ds:X509Certificate>` + b64(encryption) + `</ds:X509Certificate></ds:X509Data></ds:KeyInfo></md:KeyDescriptor>
+    <md:KeyDescriptor><ds:KeyInfo><ds:X509Data><ds:X509Certificate>not a certificate</ds:X509Certificate></ds:X509Data></ds:KeyInfo></md:KeyDescriptor>
+  </md:IDPSSODescriptor>
+</md:EntityDescriptor>`
+
+	certs, err := ExtractCertificates([]byte(metadata))
+	require.NoError(t, err)
+	require.Len(t, certs, 2)
+
+	assert.Equal(t, "KeyDescriptor (signing)", certs[0].Location)
+	assert.Equal(t, "KeyDescriptor (encryption)", certs[1].Location)
+	assert.Equal(t, signing.Raw, certs[0].Certificate.Raw)
+	assert.Equal(t, Fingerprint(signing.Raw, sha256Sum), certs[0].SHA256Fingerprint)
+	assert.Equal(t, "CN=idp.example.com", certs[0].Subject)
+
+	block, _ := pem.Decode(certs[1].PEM())
+	require.NotNil(t, block)
+	assert.Equal(t, encryption.Raw, block.Bytes)
+}
+
+func TestParser_SignatureCertificateChain(t *testing.T) {
+	leaf, intermediate := testCertificates(t)
+
+	xml := `<saml:Assertion xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_a">
+  <ds:Signature xmlns:ds="http://www.w3.org/2000/09/xmldsig#">
+    <ds:SignedInfo><ds:Reference URI="#_a"/></ds:SignedInfo>
+    <ds:KeyInfo><ds:X509Data>
+      <ds:X509Certificate>` + base64.StdEncoding.EncodeToString(leaf.Raw) + `</ds:X509Certificate>
+      <ds:X509Certificate>` + base64.StdEncoding.EncodeToString(intermediate.Raw) + `</ds:X509Certificate>
+    </ds:X509Data></ds:KeyInfo>
+  </ds:Signature>
+</saml:Assertion>`
+
+	info, err := NewParser().Parse([]byte(xml))
+	require.NoError(t, err)
+	require.NotNil(t, info.Signature)
+
+	require.Len(t, info.Signature.Certificates, 2)
+	assert.Same(t, info.Signature.Certificates[0], info.Signature.CertificateInfo)
+	assert.Equal(t, Fingerprint(intermediate.Raw, sha1Sum), info.Signature.Certificates[1].SHA1Fingerprint)
+
+	certs, err := ExtractCertificates([]byte(xml))
+	require.NoError(t, err)
+	require.Len(t, certs, 2)
+	assert.Equal(t, "Signature", certs[0].Location)
+}
@@ -0,0 +1,85 @@
+package saml
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Magic numbers of the compression formats read transparently
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// compressedExtensions are stripped to find the type of a compressed file
+var compressedExtensions = []string{".gz", ".gzip", ".zst", ".zstd"}
+
+// IsCompressed reports whether data starts like a gzip or zstd stream
+func IsCompressed(data []byte) bool {
+	return bytes.HasPrefix(data, gzipMagic) || bytes.HasPrefix(data, zstdMagic)
+}
+
+// TrimCompressedExt removes a compression extension from a filename, so
+// capture.har.gz is recognized as a HAR file
+func TrimCompressedExt(filename string) string {
+	lower := strings.ToLower(filename)
+	for _, ext := range compressedExtensions {
+		if strings.HasSuffix(lower, ext) {
+			return filename[:len(filename)-len(ext)]
+		}
+	}
+	return filename
+}
+
+// decompressLimited returns data decompressed if it is gzip or zstd
+// compressed, and unchanged otherwise. It fails with an ErrTooLarge once the
+// decompressed data exceeds limit bytes; a limit of 0 disables it.
+func decompressLimited(data []byte, limit int) ([]byte, error) {
+	if !IsCompressed(data) {
+		return data, nil
+	}
+	r, err := DecompressReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return readLimited(r, limit, &ErrTooLarge{What: "decompressed body", MaxSize: limit})
+}
+
+// DecompressReader returns a reader of r decompressed if it is gzip or zstd
+// compressed, and of r unchanged otherwise
+func DecompressReader(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(len(zstdMagic))
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read gzip data: %w", err)
+		}
+		return gz, nil
+	case bytes.HasPrefix(magic, zstdMagic):
+		return zstdReader(br)
+	}
+	return io.NopCloser(br), nil
+}
+
+// zstdMaxWindow bounds the memory a zstd frame may ask the decoder for.
+// Compressors use 8 MiB windows unless told otherwise; the input may come
+// from anyone uploading to serve.
+const zstdMaxWindow = 64 << 20
+
+// zstdReader decompresses r while it is read
+func zstdReader(r io.Reader) (io.ReadCloser, error) {
+	d, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxWindow(zstdMaxWindow))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read zstd data: %w", err)
+	}
+	return d.IOReadCloser(), nil
+}
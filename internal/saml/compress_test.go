@@ -0,0 +1,76 @@
+package saml
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func gzipped(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	_, err := w.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestDecompressLimited(t *testing.T) {
+	data := []byte(`{"log": {"entries": []}}`)
+
+	out, err := decompressLimited(gzipped(t, data), 0)
+	require.NoError(t, err)
+	assert.Equal(t, data, out)
+
+	out, err = decompressLimited(data, 0)
+	require.NoError(t, err)
+	assert.Equal(t, data, out)
+
+	_, err = decompressLimited(gzipped(t, data)[:12], 0)
+	assert.Error(t, err)
+}
+
+func TestDecompressReader_Zstd(t *testing.T) {
+	data := bytes.Repeat([]byte("<samlp:Response/>"), 1000)
+	enc, err := zstd.NewWriter(nil)
+	require.NoError(t, err)
+	compressed := enc.EncodeAll(data, nil)
+	require.True(t, IsCompressed(compressed))
+
+	r, err := DecompressReader(bytes.NewReader(compressed))
+	require.NoError(t, err)
+	out, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	assert.Equal(t, data, out)
+
+	r, err = DecompressReader(bytes.NewReader(compressed[:len(compressed)/2]))
+	require.NoError(t, err)
+	_, err = io.ReadAll(r)
+	assert.Error(t, err)
+	r.Close()
+
+	// The output is capped like gzip
+	_, err = decompressLimited(compressed, 100)
+	var tooLarge *ErrTooLarge
+	assert.ErrorAs(t, err, &tooLarge)
+}
+
+func TestTrimCompressedExt(t *testing.T) {
+	tests := map[string]string{
+		"capture.har.gz":  "capture.har",
+		"capture.HAR.GZ":  "capture.HAR",
+		"capture.har.zst": "capture.har",
+		"response.xml":    "response.xml",
+		"archive.tgz":     "archive.tgz",
+	}
+	for in, want := range tests {
+		assert.Equal(t, want, TrimCompressedExt(in), in)
+	}
+}
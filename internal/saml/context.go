@@ -0,0 +1,47 @@
+package saml
+
+import (
+	"context"
+	"io"
+)
+
+// contextReader fails reads once its context is done, so a decoder reading
+// through it stops at its next read
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := context.Cause(r.ctx); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+// withContext returns r reading through ctx, or r itself if ctx is nil
+func withContext(ctx context.Context, r io.Reader) io.Reader {
+	if ctx == nil {
+		return r
+	}
+	return &contextReader{ctx: ctx, r: r}
+}
+
+// contextErr returns the cause of ctx being done, or nil if it is not or
+// ctx is nil
+func contextErr(ctx context.Context) error {
+	if ctx == nil {
+		return nil
+	}
+	return context.Cause(ctx)
+}
+
+// contextResult returns the result of work done under ctx, or the cause of
+// ctx being done if it was cut short
+func contextResult[T any](ctx context.Context, v T, err error) (T, error) {
+	if ctxErr := contextErr(ctx); ctxErr != nil {
+		var zero T
+		return zero, ctxErr
+	}
+	return v, err
+}
@@ -0,0 +1,81 @@
+package saml
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseContext(t *testing.T) {
+	response := []byte(`<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_r"><saml:Issuer xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion">https://idp.example.com</saml:Issuer></samlp:Response>`)
+
+	info, err := NewParser().ParseContext(context.Background(), response)
+	require.NoError(t, err)
+	assert.Equal(t, "https://idp.example.com", info.Issuer)
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	cancel(errors.New("request aborted"))
+	_, err = NewParser().ParseContext(ctx, response)
+	assert.EqualError(t, err, "request aborted")
+}
+
+func TestContextReader(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := withContext(ctx, strings.NewReader(strings.Repeat("x", 100)))
+
+	buf := make([]byte, 10)
+	_, err := r.Read(buf)
+	require.NoError(t, err)
+
+	cancel()
+	_, err = r.Read(buf)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestDecryptContext(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	nameID := `<saml:NameID xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion">u-123</saml:NameID>`
+	logout := `<samlp:LogoutRequest xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_lr1">` +
+		encryptElement(t, key, "saml:EncryptedID", nameID) + `</samlp:LogoutRequest>`
+	d := &Decryptor{privateKey: key}
+
+	decrypted, err := d.DecryptContext(context.Background(), []byte(logout))
+	require.NoError(t, err)
+	assert.Contains(t, string(decrypted), ">u-123</saml:NameID>")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = d.DecryptContext(ctx, []byte(logout))
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestHARExtractor_ExtractContext(t *testing.T) {
+	authnRequest := NewDecoder().Encode([]byte(`<samlp:AuthnRequest xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_q"/>`))
+	har := []byte(`{"log": {"entries": [
+		{"request": {"method": "POST", "url": "https://idp.example.com/sso", "postData": {"params": [{"name": "SAMLRequest", "value": "` + authnRequest + `"}]}},
+		 "response": {"status": 200, "content": {"text": ""}}}
+	]}}`)
+
+	results, err := NewHARExtractor().ExtractFromHARContext(context.Background(), har)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "AuthnRequest", results[0].Type)
+
+	results, err = NewHARExtractor().ExtractContext(context.Background(), har)
+	require.NoError(t, err)
+	assert.Len(t, results, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = NewHARExtractor().ExtractFromHARContext(ctx, har)
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = NewHARExtractor().ExtractContext(ctx, har)
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -4,19 +4,37 @@ import (
 	"bytes"
 	"compress/flate"
 	"encoding/base64"
+	"errors"
 	"fmt"
-	"io"
 	"net/url"
 	"strings"
 	"unicode/utf8"
+
+	"github.com/gliwka/SAMLurai/internal/log"
+	"github.com/gliwka/SAMLurai/internal/trace"
 )
 
 // Decoder handles base64 and deflate decoding of SAML messages
-type Decoder struct{}
+type Decoder struct {
+	trace  *trace.Trace
+	limits Limits
+}
 
-// NewDecoder creates a new SAML decoder
+// NewDecoder creates a new SAML decoder with the DefaultLimits
 func NewDecoder() *Decoder {
-	return &Decoder{}
+	return &Decoder{limits: DefaultLimits()}
+}
+
+// WithLimits sets the limits on decoded and inflated messages
+func (d *Decoder) WithLimits(l Limits) *Decoder {
+	d.limits = l
+	return d
+}
+
+// WithTrace records the decoding steps in t
+func (d *Decoder) WithTrace(t *trace.Trace) *Decoder {
+	d.trace = t
+	return d
 }
 
 // Decode decodes a base64-encoded SAML message
@@ -27,38 +45,59 @@ func (d *Decoder) Decode(input string) ([]byte, error) {
 	cleaned = strings.ReplaceAll(cleaned, " ", "")
 	cleaned = strings.TrimSpace(cleaned)
 
+	span := d.trace.Begin(trace.StageBase64, len(cleaned))
+	if limit := d.limits.MaxDecodedSize; limit > 0 && base64.StdEncoding.DecodedLen(len(cleaned)) > limit {
+		err := &ErrTooLarge{What: "decoded message", MaxSize: limit}
+		span.End(trace.OutcomeFailed, 0, "over the size limit", err)
+		return nil, fmt.Errorf("%w: %w", ErrBase64, err)
+	}
+	decoded, variant, err := d.decodeBase64(cleaned)
+	if err != nil {
+		log.Debug("base64 decoding failed for every variant", "error", err)
+		span.End(trace.OutcomeFailed, 0, "no variant matched", err)
+		return nil, fmt.Errorf("%w: %w", ErrBase64, err)
+	}
+	log.Debug("base64 decoded", "variant", variant, "bytes", len(decoded))
+	span.End(trace.OutcomeOK, len(decoded), variant, nil)
+	return decoded, nil
+}
+
+// decodeBase64 tries the base64 variants seen in the wild in turn,
+// returning the one that matched
+func (d *Decoder) decodeBase64(cleaned string) ([]byte, string, error) {
 	// Try standard base64 first (before URL decoding to preserve + characters)
 	decoded, err := base64.StdEncoding.DecodeString(cleaned)
 	if err == nil {
-		return decoded, nil
+		return decoded, "standard", nil
 	}
 
 	// Try URL-safe base64
 	decoded, err = base64.URLEncoding.DecodeString(cleaned)
 	if err == nil {
-		return decoded, nil
+		return decoded, "url-safe", nil
 	}
 
 	// Try with padding adjustment
 	decoded, err = d.decodeWithPaddingFix(cleaned)
 	if err == nil {
-		return decoded, nil
+		return decoded, "padding fixed", nil
 	}
 
 	// Try URL decoding first (in case it's URL-encoded, e.g., from query params)
 	urlDecoded, urlErr := url.QueryUnescape(cleaned)
 	if urlErr == nil && urlDecoded != cleaned {
+		d.trace.Begin(trace.StageURLDecode, len(cleaned)).End(trace.OutcomeOK, len(urlDecoded), "", nil)
 		decoded, err = base64.StdEncoding.DecodeString(urlDecoded)
 		if err == nil {
-			return decoded, nil
+			return decoded, "url-encoded standard", nil
 		}
 		decoded, err = d.decodeWithPaddingFix(urlDecoded)
 		if err == nil {
-			return decoded, nil
+			return decoded, "url-encoded, padding fixed", nil
 		}
 	}
 
-	return nil, fmt.Errorf("base64 decode failed: %w", err)
+	return nil, "", err
 }
 
 // DecodeDeflate decodes a base64-encoded, deflate-compressed SAML message
@@ -73,7 +112,7 @@ func (d *Decoder) DecodeDeflate(input string) ([]byte, error) {
 	// Then, inflate (decompress)
 	inflated, err := d.inflate(decoded)
 	if err != nil {
-		return nil, fmt.Errorf("deflate decompression failed: %w", err)
+		return nil, fmt.Errorf("%w: %w", ErrDeflate, err)
 	}
 
 	return inflated, nil
@@ -102,18 +141,14 @@ func (d *Decoder) decodeWithPaddingFix(input string) ([]byte, error) {
 	return decoded, nil
 }
 
-// inflate decompresses deflate-compressed data
+// inflate decompresses deflate-compressed data, within the limits on the
+// inflated size and ratio
 func (d *Decoder) inflate(data []byte) ([]byte, error) {
 	reader := flate.NewReader(bytes.NewReader(data))
 	defer reader.Close()
 
-	var buf bytes.Buffer
-	_, err := io.Copy(&buf, reader)
-	if err != nil {
-		return nil, err
-	}
-
-	return buf.Bytes(), nil
+	limit, tooLarge := d.limits.inflateLimit(len(data))
+	return readLimited(reader, limit, tooLarge)
 }
 
 // Deflate compresses data using deflate (useful for testing)
@@ -194,6 +229,8 @@ func (d *Decoder) SmartDecode(input string) ([]byte, error) {
 
 	// If it looks like XML, return as-is
 	if !IsBase64Encoded(trimmed) {
+		log.Debug("input is not base64; reading it as XML")
+		d.trace.Skip(trace.StageBase64, len(trimmed), "input is not base64")
 		return []byte(trimmed), nil
 	}
 
@@ -205,16 +242,28 @@ func (d *Decoder) SmartDecode(input string) ([]byte, error) {
 
 	// Check if the decoded content is valid UTF-8 and looks like XML
 	if utf8.Valid(decoded) && len(decoded) > 0 && decoded[0] == '<' {
+		log.Debug("decoded content is XML; deflate not applied")
+		d.trace.Skip(trace.StageInflate, len(decoded), "decoded content is XML")
 		return decoded, nil
 	}
 
 	// If not valid UTF-8 or not XML, try deflate decompression
+	span := d.trace.Begin(trace.StageInflate, len(decoded))
 	inflated, err := d.inflate(decoded)
+	var tooLarge *ErrTooLarge
+	if errors.As(err, &tooLarge) {
+		span.End(trace.OutcomeFailed, 0, "over the size limit", err)
+		return nil, fmt.Errorf("%w: %w", ErrDeflate, err)
+	}
 	if err == nil && utf8.Valid(inflated) && len(inflated) > 0 && inflated[0] == '<' {
+		log.Debug("decoded content is not XML; deflate applied", "bytes", len(inflated))
+		span.End(trace.OutcomeOK, len(inflated), "decoded content is not XML", nil)
 		return inflated, nil
 	}
 
 	// Return the base64-decoded content even if it doesn't look like XML
 	// (could be binary or other format)
+	log.Debug("decoded content is neither XML nor deflated XML; keeping it as decoded", "inflate_error", err)
+	span.End(trace.OutcomeFailed, len(decoded), "inflated content is not XML either; keeping the decoded bytes", err)
 	return decoded, nil
 }
@@ -4,6 +4,7 @@ import (
 	"encoding/base64"
 	"testing"
 
+	"github.com/gliwka/SAMLurai/internal/trace"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -269,3 +270,28 @@ func TestDecoder_SmartDecode_Deflate(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, original, string(result))
 }
+
+func TestDecoder_Trace(t *testing.T) {
+	original := "<saml>deflated content</saml>"
+	encoded, err := NewDecoder().EncodeDeflate([]byte(original))
+	require.NoError(t, err)
+
+	tr := trace.New()
+	_, err = NewDecoder().WithTrace(tr).SmartDecode(encoded)
+	require.NoError(t, err)
+	steps := tr.Steps()
+	require.Len(t, steps, 2)
+	assert.Equal(t, trace.Step{Stage: trace.StageBase64, Outcome: trace.OutcomeOK, Detail: "standard", BytesIn: len(encoded), BytesOut: steps[1].BytesIn, DurationMS: steps[0].DurationMS}, steps[0])
+	assert.Equal(t, trace.StageInflate, steps[1].Stage)
+	assert.Equal(t, trace.OutcomeOK, steps[1].Outcome)
+	assert.Equal(t, len(original), steps[1].BytesOut)
+
+	// base64 of "<saml>test</saml>" taken from a query string
+	tr = trace.New()
+	_, err = NewDecoder().WithTrace(tr).Decode("PHNhbWw%2BdGVzdDwvc2FtbD4%3D")
+	require.NoError(t, err)
+	steps = tr.Steps()
+	require.Len(t, steps, 2)
+	assert.Equal(t, trace.StageURLDecode, steps[0].Stage)
+	assert.Equal(t, "url-encoded standard", steps[1].Detail)
+}
@@ -1,19 +1,28 @@
 package saml
 
 import (
+	"bytes"
+	"context"
+	"crypto"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
 	"fmt"
+	"io"
 	"os"
 
 	"github.com/beevik/etree"
 	"github.com/crewjam/saml/xmlenc"
+	"github.com/gliwka/SAMLurai/internal/log"
 )
 
 // Decryptor handles decryption of encrypted SAML assertions
 type Decryptor struct {
 	privateKey *rsa.PrivateKey
+
+	// external is used instead of privateKey for keys that never leave
+	// an HSM or KMS
+	external crypto.Decrypter
 }
 
 // NewDecryptor creates a new Decryptor with the given private key file
@@ -26,6 +35,17 @@ func NewDecryptor(keyPath string) (*Decryptor, error) {
 	return NewDecryptorFromPEM(keyData)
 }
 
+// NewDecryptorFromReader creates a new Decryptor from a PEM-encoded key
+// read from r, such as stdin or a secret mounted as a stream
+func NewDecryptorFromReader(r io.Reader) (*Decryptor, error) {
+	keyData, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key: %w", err)
+	}
+
+	return NewDecryptorFromPEM(keyData)
+}
+
 // NewDecryptorFromPEM creates a new Decryptor from PEM-encoded key data
 func NewDecryptorFromPEM(pemData []byte) (*Decryptor, error) {
 	block, _ := pem.Decode(pemData)
@@ -62,35 +82,130 @@ func NewDecryptorFromPEM(pemData []byte) (*Decryptor, error) {
 	}, nil
 }
 
-// Decrypt decrypts an encrypted SAML assertion
+// nestedEncryptedTags are the elements that encrypt part of a message,
+// decrypted in place rather than replacing the whole document
+var nestedEncryptedTags = map[string]bool{
+	"EncryptedID":        true,
+	"NewEncryptedID":     true,
+	"EncryptedAttribute": true,
+}
+
+// Decrypt decrypts an encrypted SAML assertion. EncryptedID and
+// EncryptedAttribute elements, in the decrypted assertion or in a message
+// without an encrypted assertion such as a LogoutRequest, are decrypted in
+// place.
 func (d *Decryptor) Decrypt(encryptedXML []byte) ([]byte, error) {
+	return d.decrypt(context.Background(), encryptedXML)
+}
+
+// DecryptContext is Decrypt, stopping with the cause of ctx being done once
+// it is canceled or its deadline passes
+func (d *Decryptor) DecryptContext(ctx context.Context, encryptedXML []byte) ([]byte, error) {
+	if err := context.Cause(ctx); err != nil {
+		return nil, err
+	}
+	decrypted, err := d.decrypt(ctx, encryptedXML)
+	return contextResult(ctx, decrypted, err)
+}
+
+// decrypt decrypts encryptedXML, reading the document through ctx and
+// checking it before each element is decrypted
+func (d *Decryptor) decrypt(ctx context.Context, encryptedXML []byte) ([]byte, error) {
 	// Parse the XML document
-	doc := etree.NewDocument()
-	if err := doc.ReadFromBytes(encryptedXML); err != nil {
-		return nil, fmt.Errorf("failed to parse XML: %w", err)
+	doc, err := readDocument(ctx, encryptedXML)
+	if err != nil {
+		return nil, &ErrParse{Stage: "XML", Err: err}
 	}
 
-	// Find the EncryptedData element
-	encryptedDataEl := doc.FindElement("//EncryptedData")
-	if encryptedDataEl == nil {
-		// Try to find EncryptedAssertion containing EncryptedData
-		encryptedAssertionEl := doc.FindElement("//EncryptedAssertion")
-		if encryptedAssertionEl != nil {
-			encryptedDataEl = encryptedAssertionEl.FindElement("EncryptedData")
+	// Find the EncryptedData element of an EncryptedAssertion or a bare
+	// EncryptedData document
+	var encryptedDataEl *etree.Element
+	for _, el := range doc.FindElements("//EncryptedData") {
+		if parent := el.Parent(); parent == nil || !nestedEncryptedTags[parent.Tag] {
+			encryptedDataEl = el
+			break
 		}
 	}
 
 	if encryptedDataEl == nil {
-		return nil, fmt.Errorf("no EncryptedData element found in XML")
+		log.Debug("no encrypted assertion found; looking for encrypted identifiers and attributes")
+		// Only identifiers or attributes may be encrypted
+		n, err := d.decryptNested(ctx, doc)
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			return nil, fmt.Errorf("no EncryptedData element found in XML")
+		}
+		return doc.WriteToBytes()
 	}
 
 	// Decrypt the element
-	decrypted, err := xmlenc.Decrypt(d.privateKey, encryptedDataEl)
+	if err := contextErr(ctx); err != nil {
+		return nil, err
+	}
+	log.Debug("decrypting", "xpath", "//EncryptedData", "element", encryptedDataEl.GetPath())
+	decrypted, err := d.decryptElement(encryptedDataEl)
 	if err != nil {
-		return nil, fmt.Errorf("decryption failed: %w", err)
+		return nil, &ErrEncrypted{Algorithm: algorithmOf(encryptedDataEl, "./EncryptionMethod"), Err: err}
 	}
 
-	return decrypted, nil
+	if !bytes.Contains(decrypted, []byte("EncryptedData")) {
+		return decrypted, nil
+	}
+	assertion, err := ReadDocument(decrypted)
+	if err != nil {
+		return nil, &ErrParse{Stage: "decrypted XML", Err: err}
+	}
+	if _, err := d.decryptNested(ctx, assertion); err != nil {
+		return nil, err
+	}
+	return assertion.WriteToBytes()
+}
+
+// decryptNested replaces the EncryptedID and EncryptedAttribute elements
+// of doc with their decrypted content, returning how many were replaced
+func (d *Decryptor) decryptNested(ctx context.Context, doc *etree.Document) (int, error) {
+	var encrypted []*etree.Element
+	for tag := range nestedEncryptedTags {
+		encrypted = append(encrypted, doc.FindElements("//"+tag)...)
+	}
+
+	for _, el := range encrypted {
+		if err := contextErr(ctx); err != nil {
+			return 0, err
+		}
+		encryptedDataEl := el.FindElement("./EncryptedData")
+		if encryptedDataEl == nil {
+			return 0, fmt.Errorf("%s has no EncryptedData element", el.Tag)
+		}
+		log.Debug("decrypting", "xpath", "//"+el.Tag, "element", encryptedDataEl.GetPath())
+		decrypted, err := d.decryptElement(encryptedDataEl)
+		if err != nil {
+			return 0, &ErrEncrypted{Element: el.Tag, Algorithm: algorithmOf(encryptedDataEl, "./EncryptionMethod"), Err: err}
+		}
+
+		// The plaintext is a NameID, BaseID or Attribute element
+		fragment, err := ReadDocument(decrypted)
+		if err != nil {
+			return 0, &ErrParse{Stage: "decrypted " + el.Tag, Err: err}
+		}
+		if fragment.Root() == nil {
+			return 0, fmt.Errorf("decrypted %s is empty", el.Tag)
+		}
+		parent := el.Parent()
+		parent.InsertChildAt(el.Index(), fragment.Root())
+		parent.RemoveChild(el)
+	}
+	return len(encrypted), nil
+}
+
+// decryptElement decrypts a single EncryptedData element
+func (d *Decryptor) decryptElement(encryptedDataEl *etree.Element) ([]byte, error) {
+	if d.external != nil {
+		return d.decryptExternal(encryptedDataEl)
+	}
+	return xmlenc.Decrypt(d.privateKey, encryptedDataEl)
 }
 
 // DecryptString decrypts an encrypted SAML assertion from a string
@@ -100,8 +215,8 @@ func (d *Decryptor) DecryptString(encryptedXML string) ([]byte, error) {
 
 // IsEncrypted checks if the given XML contains encrypted SAML data
 func IsEncrypted(xmlData []byte) bool {
-	doc := etree.NewDocument()
-	if err := doc.ReadFromBytes(xmlData); err != nil {
+	doc, err := ReadDocument(xmlData)
+	if err != nil {
 		return false
 	}
 
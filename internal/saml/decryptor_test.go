@@ -1,14 +1,18 @@
 package saml
 
 import (
+	"bytes"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/beevik/etree"
+	"github.com/crewjam/saml/xmlenc"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -193,3 +197,97 @@ func TestIsEncryptedString(t *testing.T) {
 	unencrypted := `<saml:Assertion xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion"/>`
 	assert.False(t, IsEncryptedString(unencrypted))
 }
+
+func TestNewDecryptorFromReader(t *testing.T) {
+	pemData, err := os.ReadFile(createTestKeyPKCS8(t))
+	require.NoError(t, err)
+
+	decryptor, err := NewDecryptorFromReader(bytes.NewReader(pemData))
+	require.NoError(t, err)
+	assert.NotNil(t, decryptor)
+
+	_, err = NewDecryptorFromReader(strings.NewReader("not a key"))
+	assert.EqualError(t, err, "failed to parse PEM block")
+}
+
+// encryptElement returns the EncryptedData of plaintext for key, serialized
+// inside a wrapper element such as saml:EncryptedID
+func encryptElement(t *testing.T, key *rsa.PrivateKey, wrapper, plaintext string) string {
+	t.Helper()
+
+	encryptedData, err := xmlenc.OAEP().Encrypt(selfSignedCert(t, key), []byte(plaintext), nil)
+	require.NoError(t, err)
+	doc := etree.NewDocument()
+	doc.CreateElement(wrapper).AddChild(encryptedData)
+	doc.WriteSettings.CanonicalEndTags = true
+	out, err := doc.WriteToString()
+	require.NoError(t, err)
+	return out
+}
+
+func TestDecrypt_EncryptedID(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	nameID := `<saml:NameID xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" Format="urn:oasis:names:tc:SAML:2.0:nameid-format:persistent">u-123</saml:NameID>`
+	logout := `<samlp:LogoutRequest xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_lr1">` +
+		`<saml:Issuer>https://sp.example.com</saml:Issuer>` +
+		encryptElement(t, key, "saml:EncryptedID", nameID) +
+		`<samlp:SessionIndex>_s1</samlp:SessionIndex></samlp:LogoutRequest>`
+
+	decrypted, err := (&Decryptor{privateKey: key}).Decrypt([]byte(logout))
+	require.NoError(t, err)
+	out := string(decrypted)
+	assert.Contains(t, out, `<samlp:LogoutRequest`)
+	assert.Contains(t, out, `>u-123</saml:NameID><samlp:SessionIndex>`)
+	assert.NotContains(t, out, "EncryptedID")
+}
+
+func TestDecrypt_NestedInAssertion(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	nameID := `<saml:NameID xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion">user@example.com</saml:NameID>`
+	attribute := `<saml:Attribute xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" Name="role"><saml:AttributeValue>admin</saml:AttributeValue></saml:Attribute>`
+	assertion := `<saml:Assertion xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_a1">` +
+		`<saml:Issuer>https://idp.example.com</saml:Issuer>` +
+		`<saml:Subject>` + encryptElement(t, key, "saml:EncryptedID", nameID) + `</saml:Subject>` +
+		`<saml:AttributeStatement>` + encryptElement(t, key, "saml:EncryptedAttribute", attribute) + `</saml:AttributeStatement>` +
+		`</saml:Assertion>`
+	decryptor := &Decryptor{privateKey: key}
+
+	t.Run("plaintext assertion", func(t *testing.T) {
+		decrypted, err := decryptor.Decrypt([]byte(assertion))
+		require.NoError(t, err)
+
+		info, err := NewParser().Parse(decrypted)
+		require.NoError(t, err)
+		require.NotNil(t, info.Subject)
+		assert.Equal(t, "user@example.com", info.Subject.NameID)
+		require.Len(t, info.Attributes, 1)
+		assert.Equal(t, "role", info.Attributes[0].Name)
+		assert.Equal(t, []string{"admin"}, info.Attributes[0].Values)
+	})
+
+	t.Run("inside an EncryptedAssertion", func(t *testing.T) {
+		encrypted := encryptElement(t, key, "saml:EncryptedAssertion", assertion)
+
+		decrypted, err := NewDecryptorWithKey(&opaqueKey{key: key}).Decrypt([]byte(encrypted))
+		require.NoError(t, err)
+
+		info, err := NewParser().Parse(decrypted)
+		require.NoError(t, err)
+		require.NotNil(t, info.Subject)
+		assert.Equal(t, "user@example.com", info.Subject.NameID)
+		require.Len(t, info.Attributes, 1)
+		assert.Equal(t, "role", info.Attributes[0].Name)
+	})
+}
+
+func TestDecrypt_NoEncryptedData(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	_, err = (&Decryptor{privateKey: key}).Decrypt([]byte(`<saml:Assertion xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion"/>`))
+	assert.EqualError(t, err, "no EncryptedData element found in XML")
+}
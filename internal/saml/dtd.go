@@ -0,0 +1,86 @@
+package saml
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/beevik/etree"
+)
+
+// ErrDTD is returned when a document has a DOCTYPE declaration. SAML
+// messages must not contain DTDs, and entity declarations are the vehicle
+// for XXE and entity expansion attacks.
+var ErrDTD = errors.New("document contains a DTD (DOCTYPE declaration), which SAML messages must not have")
+
+// DTDInfo describes the DOCTYPE declaration of a document
+type DTDInfo struct {
+	// Entities are the names of declared entities
+	Entities []string `json:"entities,omitempty"`
+
+	// External is set if the DTD or an entity refers to a SYSTEM or PUBLIC resource
+	External bool `json:"external"`
+}
+
+var (
+	entityDeclaration = regexp.MustCompile(`<!ENTITY\s+(?:%\s+)?([^\s>]+)`)
+	externalID        = regexp.MustCompile(`\b(SYSTEM|PUBLIC)\s+["']`)
+)
+
+// FindDTD returns the DOCTYPE declaration of data, if there is one. Only
+// the prolog is scanned; input that is not XML has no DTD.
+func FindDTD(data []byte) (*DTDInfo, bool) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = false
+
+	for {
+		token, err := decoder.RawToken()
+		if err != nil {
+			return nil, false
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			return nil, false
+		case xml.Directive:
+			directive := string(t)
+			if !strings.HasPrefix(strings.TrimSpace(directive), "DOCTYPE") {
+				continue
+			}
+			info := &DTDInfo{External: externalID.MatchString(directive)}
+			for _, m := range entityDeclaration.FindAllStringSubmatch(directive, -1) {
+				info.Entities = append(info.Entities, m[1])
+			}
+			return info, true
+		}
+	}
+}
+
+// rejectDTD returns ErrDTD if data has a DOCTYPE declaration
+func rejectDTD(data []byte) error {
+	if _, ok := FindDTD(data); ok {
+		return ErrDTD
+	}
+	return nil
+}
+
+// ReadDocument parses an XML document, returning ErrDTD without parsing it
+// if it has a DOCTYPE declaration. Documents from untrusted input are read
+// with it rather than with etree directly.
+func ReadDocument(data []byte) (*etree.Document, error) {
+	return readDocument(context.Background(), data)
+}
+
+// readDocument is ReadDocument, stopping when ctx is done
+func readDocument(ctx context.Context, data []byte) (*etree.Document, error) {
+	if err := rejectDTD(data); err != nil {
+		return nil, err
+	}
+	doc := etree.NewDocument()
+	if _, err := doc.ReadFrom(withContext(ctx, bytes.NewReader(data))); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
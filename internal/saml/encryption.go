@@ -0,0 +1,104 @@
+package saml
+
+import (
+	"strings"
+
+	"github.com/beevik/etree"
+)
+
+// EncryptionInfo describes how an encrypted assertion was encrypted. It is
+// readable without the private key and tells which key is needed.
+type EncryptionInfo struct {
+	// DataEncryption is the algorithm encrypting the assertion itself
+	DataEncryption string `json:"data_encryption,omitempty"`
+
+	// KeyTransport is the algorithm wrapping the session key
+	KeyTransport string `json:"key_transport,omitempty"`
+
+	// DigestMethod and MGF are the OAEP parameters of the key transport
+	DigestMethod string `json:"digest_method,omitempty"`
+	MGF          string `json:"mgf,omitempty"`
+
+	// Recipient is the entity the session key was encrypted for
+	Recipient string `json:"recipient,omitempty"`
+
+	// KeyName names the key the session key was encrypted with
+	KeyName string `json:"key_name,omitempty"`
+
+	// Certificate is the recipient certificate from the EncryptedKey
+	Certificate *CertificateInfo `json:"certificate,omitempty"`
+}
+
+// Data encryption algorithms with known weaknesses
+const (
+	dataEncryptionTripleDES = "http://www.w3.org/2001/04/xmlenc#tripledes-cbc"
+	dataEncryptionCBCPrefix = "http://www.w3.org/2001/04/xmlenc#aes"
+)
+
+// Weaknesses lists the problems of the chosen algorithms
+func (e *EncryptionInfo) Weaknesses() []string {
+	var weaknesses []string
+	if e.KeyTransport == KeyTransportRSA15 {
+		weaknesses = append(weaknesses, "RSA-1.5 key transport is vulnerable to padding oracle attacks; use RSA-OAEP")
+	}
+	switch {
+	case e.DataEncryption == dataEncryptionTripleDES:
+		weaknesses = append(weaknesses, "TripleDES data encryption is deprecated; use AES-GCM")
+	case strings.HasPrefix(e.DataEncryption, dataEncryptionCBCPrefix) && strings.HasSuffix(e.DataEncryption, "-cbc"):
+		weaknesses = append(weaknesses, "AES-CBC data encryption is vulnerable to padding oracle attacks; use AES-GCM")
+	}
+	return weaknesses
+}
+
+// ParseEncryptionInfo returns the encryption metadata of the first
+// encrypted assertion (or other EncryptedData) in xmlData, or nil if there
+// is none
+func ParseEncryptionInfo(xmlData []byte) *EncryptionInfo {
+	doc, err := ReadDocument(xmlData)
+	if err != nil {
+		return nil
+	}
+
+	encryptedData := doc.FindElement("//EncryptedAssertion/EncryptedData")
+	if encryptedData == nil {
+		encryptedData = doc.FindElement("//EncryptedData")
+	}
+	if encryptedData == nil {
+		return nil
+	}
+
+	info := &EncryptionInfo{
+		DataEncryption: algorithmOf(encryptedData, "./EncryptionMethod"),
+	}
+
+	encryptedKey := findEncryptedKey(encryptedData)
+	if encryptedKey == nil {
+		return info
+	}
+	info.KeyTransport = algorithmOf(encryptedKey, "./EncryptionMethod")
+	info.DigestMethod = algorithmOf(encryptedKey, "./EncryptionMethod/DigestMethod")
+	info.MGF = algorithmOf(encryptedKey, "./EncryptionMethod/MGF")
+	info.Recipient = encryptedKey.SelectAttrValue("Recipient", "")
+	if keyName := encryptedKey.FindElement("./KeyInfo/KeyName"); keyName != nil {
+		info.KeyName = strings.TrimSpace(keyName.Text())
+	}
+	if certEl := encryptedKey.FindElement("./KeyInfo/X509Data/X509Certificate"); certEl != nil {
+		if cert, err := ParseCertificate([]byte(certEl.Text())); err == nil {
+			info.Certificate = NewCertificateInfo(cert)
+		}
+	}
+
+	// The OAEP digest defaults to SHA-1
+	if info.DigestMethod == "" && (info.KeyTransport == KeyTransportRSAOAEPMGF1 || info.KeyTransport == KeyTransportRSAOAEP) {
+		info.DigestMethod = "http://www.w3.org/2000/09/xmldsig#sha1"
+	}
+	return info
+}
+
+// algorithmOf returns the Algorithm attribute of the element at path
+func algorithmOf(el *etree.Element, path string) string {
+	if found := el.FindElement(path); found != nil {
+		return found.SelectAttrValue("Algorithm", "")
+	}
+	return ""
+}
@@ -0,0 +1,61 @@
+package saml
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+
+	"github.com/crewjam/saml/xmlenc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEncryptionInfo(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	t.Run("rsa-oaep", func(t *testing.T) {
+		info := ParseEncryptionInfo(encryptedAssertion(t, key, xmlenc.OAEP()))
+		require.NotNil(t, info)
+		assert.Equal(t, "http://www.w3.org/2001/04/xmlenc#aes256-cbc", info.DataEncryption)
+		assert.Equal(t, KeyTransportRSAOAEPMGF1, info.KeyTransport)
+		assert.NotEmpty(t, info.DigestMethod)
+		require.NotNil(t, info.Certificate)
+		assert.Equal(t, "CN=idp.example.com", info.Certificate.Subject)
+		assert.Equal(t, []string{"AES-CBC data encryption is vulnerable to padding oracle attacks; use AES-GCM"}, info.Weaknesses())
+	})
+
+	t.Run("rsa-1_5", func(t *testing.T) {
+		info := ParseEncryptionInfo(encryptedAssertion(t, key, xmlenc.PKCS1v15()))
+		require.NotNil(t, info)
+		assert.Equal(t, KeyTransportRSA15, info.KeyTransport)
+		assert.Empty(t, info.DigestMethod)
+		assert.Contains(t, info.Weaknesses(), "RSA-1.5 key transport is vulnerable to padding oracle attacks; use RSA-OAEP")
+	})
+
+	t.Run("not encrypted", func(t *testing.T) {
+		assert.Nil(t, ParseEncryptionInfo([]byte(`<saml:Assertion xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion"/>`)))
+	})
+}
+
+func TestEncryptionInfo_Weaknesses(t *testing.T) {
+	gcm := &EncryptionInfo{
+		DataEncryption: "http://www.w3.org/2009/xmlenc11#aes256-gcm",
+		KeyTransport:   KeyTransportRSAOAEP,
+	}
+	assert.Empty(t, gcm.Weaknesses())
+
+	tripleDES := &EncryptionInfo{DataEncryption: "http://www.w3.org/2001/04/xmlenc#tripledes-cbc"}
+	assert.Equal(t, []string{"TripleDES data encryption is deprecated; use AES-GCM"}, tripleDES.Weaknesses())
+}
+
+func TestParsePartial_EncryptedAssertion(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	info, err := NewParser().ParsePartial(encryptedAssertion(t, key, xmlenc.OAEP()))
+	require.NoError(t, err)
+	assert.Equal(t, "EncryptedAssertion", info.Type)
+	require.NotNil(t, info.Encryption)
+	assert.Equal(t, KeyTransportRSAOAEPMGF1, info.Encryption.KeyTransport)
+}
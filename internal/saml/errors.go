@@ -0,0 +1,95 @@
+package saml
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors of the decode, decrypt and parse steps, so callers can tell
+// failures apart with errors.Is rather than by their messages
+var (
+	// ErrNotSAML is returned for input that is not a SAML message the
+	// parser knows
+	ErrNotSAML = errors.New("not a valid SAML document")
+
+	// ErrBase64 is returned when no base64 variant decodes the input
+	ErrBase64 = errors.New("base64 decode failed")
+
+	// ErrDeflate is returned when decoded input does not inflate
+	ErrDeflate = errors.New("deflate decompression failed")
+
+	// ErrNoKey is returned for encrypted content when no private key was
+	// provided
+	ErrNoKey = errors.New("encrypted assertion detected but no private key provided")
+)
+
+// ErrEncrypted is returned when encrypted content could not be decrypted
+type ErrEncrypted struct {
+	// Element is the encrypted element, e.g. EncryptedID, or empty for an
+	// encrypted assertion
+	Element string
+
+	// Algorithm is the data encryption algorithm URI, if the element
+	// names one
+	Algorithm string
+
+	Err error
+}
+
+func (e *ErrEncrypted) Error() string {
+	if e.Element != "" {
+		return fmt.Sprintf("decryption of %s failed: %v", e.Element, e.Err)
+	}
+	return fmt.Sprintf("decryption failed: %v", e.Err)
+}
+
+func (e *ErrEncrypted) Unwrap() error {
+	return e.Err
+}
+
+// ErrParse is returned when a document could not be parsed
+type ErrParse struct {
+	// Stage is what was being parsed, e.g. "XML", "SAML response" or
+	// "decrypted XML"
+	Stage string
+
+	Err error
+}
+
+func (e *ErrParse) Error() string {
+	return fmt.Sprintf("failed to parse %s: %v", e.Stage, e.Err)
+}
+
+func (e *ErrParse) Unwrap() error {
+	return e.Err
+}
+
+// ErrTooLarge is returned when input exceeds one of the Limits
+type ErrTooLarge struct {
+	// What exceeded the limit, e.g. "decoded message"
+	What string
+
+	// MaxSize is the size limit in bytes that was exceeded, if any
+	MaxSize int
+
+	// MaxRatio is the inflation ratio limit that was exceeded, if any
+	MaxRatio int
+}
+
+func (e *ErrTooLarge) Error() string {
+	if e.MaxRatio > 0 {
+		return fmt.Sprintf("%s is more than %d times the size of its compressed data, likely a decompression bomb", e.What, e.MaxRatio)
+	}
+	return fmt.Sprintf("%s exceeds the limit of %s", e.What, formatSize(e.MaxSize))
+}
+
+// formatSize formats a size in bytes, in MiB or KiB if it is a multiple
+func formatSize(n int) string {
+	switch {
+	case n >= 1<<20 && n%(1<<20) == 0:
+		return fmt.Sprintf("%d MiB", n>>20)
+	case n >= 1<<10 && n%(1<<10) == 0:
+		return fmt.Sprintf("%d KiB", n>>10)
+	}
+	return fmt.Sprintf("%d bytes", n)
+}
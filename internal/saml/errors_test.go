@@ -0,0 +1,56 @@
+package saml
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrors_Decode(t *testing.T) {
+	_, err := NewDecoder().Decode("%%% not base64 %%%")
+	assert.ErrorIs(t, err, ErrBase64)
+
+	_, err = NewDecoder().DecodeDeflate(NewDecoder().Encode([]byte("not deflated")))
+	assert.ErrorIs(t, err, ErrDeflate)
+	assert.NotErrorIs(t, err, ErrBase64)
+}
+
+func TestErrors_Parse(t *testing.T) {
+	_, err := NewParser().Parse([]byte(`<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol"`))
+	var parseErr *ErrParse
+	require.ErrorAs(t, err, &parseErr)
+	assert.Equal(t, "SAML response", parseErr.Stage)
+	assert.NotErrorIs(t, err, ErrNotSAML)
+
+	_, err = NewParser().Parse([]byte(`<html><body>Sign in</body></html>`))
+	assert.ErrorIs(t, err, ErrNotSAML)
+	assert.ErrorAs(t, err, &parseErr, "the last parse attempt is kept")
+	assert.ErrorContains(t, err, "not a valid SAML document: failed to parse SAML assertion")
+}
+
+func TestErrors_Decrypt(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	d := &Decryptor{privateKey: key}
+
+	_, err = d.Decrypt([]byte(`<saml:EncryptedAssertion xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion"><xenc:EncryptedData xmlns:xenc="http://www.w3.org/2001/04/xmlenc#"><xenc:EncryptionMethod Algorithm="http://www.w3.org/2001/04/xmlenc#aes128-cbc"/></xenc:EncryptedData></saml:EncryptedAssertion>`))
+	var encryptedErr *ErrEncrypted
+	require.ErrorAs(t, err, &encryptedErr)
+	assert.Equal(t, "http://www.w3.org/2001/04/xmlenc#aes128-cbc", encryptedErr.Algorithm)
+	assert.Empty(t, encryptedErr.Element)
+	assert.ErrorContains(t, err, "decryption failed: ")
+
+	_, err = d.Decrypt([]byte(`<saml:Assertion`))
+	var parseErr *ErrParse
+	require.ErrorAs(t, err, &parseErr)
+	assert.Equal(t, "XML", parseErr.Stage)
+}
+
+func TestErrEncrypted_Element(t *testing.T) {
+	err := &ErrEncrypted{Element: "EncryptedID", Err: errors.New("bad padding")}
+	assert.EqualError(t, err, "decryption of EncryptedID failed: bad padding")
+}
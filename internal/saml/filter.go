@@ -0,0 +1,82 @@
+package saml
+
+import (
+	"strings"
+)
+
+// MessageFilter selects extracted messages, e.g. only the Responses of one
+// IdP in a busy capture. Empty fields match every message.
+type MessageFilter struct {
+	// Types are the message types to keep, such as Response or
+	// AuthnRequest, compared case-insensitively
+	Types []string
+
+	// Issuer is the entity ID the message must be issued by
+	Issuer string
+
+	// URLContains must be a substring of the URL the message was found at
+	URLContains string
+
+	// Params are the parameter names to keep, such as SAMLResponse,
+	// compared case-insensitively
+	Params []string
+}
+
+// IsZero reports whether the filter matches every message
+func (f MessageFilter) IsZero() bool {
+	return len(f.Types) == 0 && f.Issuer == "" && f.URLContains == "" && len(f.Params) == 0
+}
+
+// Match reports whether an extracted message passes the filter
+func (f MessageFilter) Match(r ExtractedSAML) bool {
+	if len(f.Types) > 0 && !containsFold(f.Types, r.Type) {
+		return false
+	}
+	if len(f.Params) > 0 && !containsFold(f.Params, r.ParameterName) {
+		return false
+	}
+	if f.URLContains != "" && !strings.Contains(r.URL, f.URLContains) {
+		return false
+	}
+	return f.Issuer == "" || MessageIssuer(r.DecodedXML) == f.Issuer
+}
+
+// Apply returns only the extracted messages that pass the filter
+func (f MessageFilter) Apply(results []ExtractedSAML) []ExtractedSAML {
+	if f.IsZero() {
+		return results
+	}
+
+	var filtered []ExtractedSAML
+	for _, r := range results {
+		if f.Match(r) {
+			filtered = append(filtered, r)
+		}
+	}
+	return filtered
+}
+
+// MessageIssuer returns the issuer of a SAML message: the first Issuer
+// element in the document, which is the message's own rather than that
+// of an assertion it carries. Encrypted assertions do not hide it.
+func MessageIssuer(xmlData []byte) string {
+	doc, err := ReadDocument(xmlData)
+	if err != nil {
+		return ""
+	}
+	issuer := doc.FindElement("//Issuer")
+	if issuer == nil {
+		return ""
+	}
+	return strings.TrimSpace(issuer.Text())
+}
+
+// containsFold reports whether values contains s, ignoring case
+func containsFold(values []string, s string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,55 @@
+package saml
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessageIssuer(t *testing.T) {
+	response := `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion">
+		<saml:Issuer> https://idp.example.com </saml:Issuer>
+		<saml:Assertion><saml:Issuer>https://other.example.com</saml:Issuer></saml:Assertion>
+	</samlp:Response>`
+	assert.Equal(t, "https://idp.example.com", MessageIssuer([]byte(response)))
+	assert.Empty(t, MessageIssuer([]byte(`<samlp:AuthnRequest xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol"/>`)))
+	assert.Empty(t, MessageIssuer([]byte("not xml")))
+}
+
+func TestMessageFilter_Apply(t *testing.T) {
+	fromIdP := []byte(`<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol"><saml:Issuer xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion">https://idp.example.com</saml:Issuer></samlp:Response>`)
+	fromOther := []byte(`<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol"><saml:Issuer xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion">https://other.example.com</saml:Issuer></samlp:Response>`)
+	results := []ExtractedSAML{
+		{Index: 1, Type: "AuthnRequest", ParameterName: "SAMLRequest", URL: "https://idp.example.com/sso?SAMLRequest=x"},
+		{Index: 2, Type: "Response", ParameterName: "SAMLResponse", URL: "https://sp.example.com/acs", DecodedXML: fromIdP},
+		{Index: 3, Type: "Response", ParameterName: "SAMLResponse", URL: "https://app.example.com/acs", DecodedXML: fromOther},
+	}
+
+	indexes := func(filtered []ExtractedSAML) []int {
+		var got []int
+		for _, r := range filtered {
+			got = append(got, r.Index)
+		}
+		return got
+	}
+
+	tests := []struct {
+		name   string
+		filter MessageFilter
+		want   []int
+	}{
+		{"empty filter", MessageFilter{}, []int{1, 2, 3}},
+		{"type", MessageFilter{Types: []string{"response"}}, []int{2, 3}},
+		{"several types", MessageFilter{Types: []string{"AuthnRequest", "LogoutRequest"}}, []int{1}},
+		{"issuer", MessageFilter{Issuer: "https://idp.example.com"}, []int{2}},
+		{"url", MessageFilter{URLContains: "sp.example.com"}, []int{2}},
+		{"param", MessageFilter{Params: []string{"samlrequest"}}, []int{1}},
+		{"combined", MessageFilter{Types: []string{"Response"}, URLContains: "/acs", Issuer: "https://other.example.com"}, []int{3}},
+		{"no match", MessageFilter{Types: []string{"LogoutResponse"}}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, indexes(tt.filter.Apply(results)))
+		})
+	}
+}
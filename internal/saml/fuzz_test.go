@@ -0,0 +1,102 @@
+package saml
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Fuzz targets of the decode, parse and HAR extraction steps. Besides the
+// seeds added here, go test runs the samples in testdata/fuzz, which
+// samlurai corpus add stores; run a target with e.g.
+//
+//	go test ./internal/saml -run '^$' -fuzz FuzzParse -fuzztime 1m
+
+// fuzzFixtures adds the XML fixtures of the repository as seeds
+func fuzzFixtures(f *testing.F, add func(data []byte)) {
+	f.Helper()
+	paths, err := filepath.Glob(filepath.Join("..", "..", "testdata", "fixtures", "*", "*.xml"))
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			f.Fatal(err)
+		}
+		add(data)
+	}
+}
+
+func FuzzSmartDecode(f *testing.F) {
+	d := NewDecoder()
+	fuzzFixtures(f, func(data []byte) {
+		f.Add(string(data))
+		f.Add(d.Encode(data))
+		if deflated, err := d.EncodeDeflate(data); err == nil {
+			f.Add(deflated)
+		}
+	})
+	for _, seed := range []string{"", "=", "PD94", "PHNhbWw6", "%3D%3D", "a+b/c==\n", "-_-_", "\x00\xff"} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, input string) {
+		decoded, err := NewDecoder().SmartDecode(input)
+		if err == nil && decoded == nil && IsBase64Encoded(input) {
+			t.Errorf("SmartDecode(%q) returned neither data nor an error", input)
+		}
+	})
+}
+
+func FuzzParse(f *testing.F) {
+	fuzzFixtures(f, func(data []byte) { f.Add(data) })
+	for _, seed := range []string{
+		"",
+		"<",
+		"<Response/>",
+		`<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol"><saml:EncryptedAssertion xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion"/></samlp:Response>`,
+		`<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body/></soap:Envelope>`,
+		`<wst:RequestSecurityTokenResponse xmlns:wst="http://docs.oasis-open.org/ws-sx/ws-trust/200512"/>`,
+		`<!DOCTYPE x [<!ENTITY a "b">]><Response/>`,
+	} {
+		f.Add([]byte(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		for _, parse := range []func([]byte) (*SAMLInfo, error){NewParser().Parse, NewParser().ParsePartial, NewParser().WithRawXML(true).Parse} {
+			info, err := parse(data)
+			if err == nil && info == nil {
+				t.Errorf("parse(%q) returned neither a message nor an error", data)
+			}
+		}
+	})
+}
+
+func FuzzExtractFromHAR(f *testing.F) {
+	d := NewDecoder()
+	fuzzFixtures(f, func(data []byte) {
+		f.Add([]byte(`{"log": {"entries": [{"request": {"method": "POST", "url": "https://sp.example.com/acs", "postData": {"params": [{"name": "SAMLResponse", "value": "` + d.Encode(data) + `"}]}}, "response": {"status": 302, "content": {}}}]}}`))
+	})
+	for _, seed := range []string{
+		`{}`,
+		`{"log": {"entries": []}}`,
+		`{"log": {"entries": [{"request": {"url": "https://idp.example.com/sso?SAMLRequest=%"}, "response": {"headers": [{"name": "Location", "value": "://"}]}}]}}`,
+		`{"log": {"entries": [{"request": {}, "response": {"content": {"text": "H4sI", "encoding": "base64"}}}]}}`,
+		`{"requests": [{"url": "https://sp.example.com/acs", "saml": "<samlp:Response/>"}]}`,
+	} {
+		f.Add([]byte(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		results, err := NewHARExtractor().WithWebSockets(true).ExtractFromHAR(data)
+		if err != nil {
+			return
+		}
+		for i, r := range results {
+			if r.Index != i+1 {
+				t.Errorf("result %d has index %d", i+1, r.Index)
+			}
+		}
+	})
+}
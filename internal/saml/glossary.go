@@ -0,0 +1,172 @@
+package saml
+
+import "strings"
+
+// Kinds of glossary terms
+const (
+	TermStatusCode   = "status code"
+	TermSignature    = "signature algorithm"
+	TermDigest       = "digest algorithm"
+	TermNameIDFormat = "NameID format"
+	TermBinding      = "binding"
+)
+
+// Term is a URI used in SAML messages, with a plain-language explanation
+type Term struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Kind        string `json:"kind"`
+	Description string `json:"description"`
+}
+
+const statusPrefix = "urn:oasis:names:tc:SAML:2.0:status:"
+
+// glossary holds the status codes, algorithms and bindings explained by
+// LookupTerm. NameID formats are added from nameIDFormats.
+var glossary = []Term{
+	// Top-level status codes
+	{StatusSuccess, "Success", TermStatusCode,
+		"The request succeeded. A successful Response still needs its signature, audience and validity checked"},
+	{statusPrefix + "Requester", "Requester", TermStatusCode,
+		"The request could not be performed due to an error on the part of the requester, usually the SP: an unknown issuer, a bad signature or an unsupported ACS URL. A second-level code may tell more"},
+	{statusPrefix + "Responder", "Responder", TermStatusCode,
+		"The request could not be performed due to an error on the part of the responder, usually the IdP. Many IdPs send it for any failure and log the reason only on their side"},
+	{statusPrefix + "VersionMismatch", "VersionMismatch", TermStatusCode,
+		"The responder does not support the SAML version of the request"},
+
+	// Second-level status codes
+	{statusPrefix + "AuthnFailed", "AuthnFailed", TermStatusCode,
+		"The IdP could not authenticate the user, e.g. after a wrong password or a cancelled sign-in"},
+	{statusPrefix + "InvalidAttrNameOrValue", "InvalidAttrNameOrValue", TermStatusCode,
+		"An attribute name or value in the request, e.g. of an AttributeQuery, was not understood"},
+	{statusPrefix + "InvalidNameIDPolicy", "InvalidNameIDPolicy", TermStatusCode,
+		"The IdP cannot issue a NameID in the Format of the AuthnRequest's NameIDPolicy, or may not create one (AllowCreate=\"false\")"},
+	{statusPrefix + "NoAuthnContext", "NoAuthnContext", TermStatusCode,
+		"The IdP cannot authenticate the user as the RequestedAuthnContext of the AuthnRequest demands"},
+	{statusPrefix + "NoAvailableIDP", "NoAvailableIDP", TermStatusCode,
+		"A proxying IdP found none of the IdPs in the request's IDPList available"},
+	{statusPrefix + "NoPassive", "NoPassive", TermStatusCode,
+		"The AuthnRequest was passive (IsPassive=\"true\") but the IdP would have had to interact with the user, who had no session"},
+	{statusPrefix + "NoSupportedIDP", "NoSupportedIDP", TermStatusCode,
+		"A proxying IdP supports none of the IdPs in the request's IDPList"},
+	{statusPrefix + "PartialLogout", "PartialLogout", TermStatusCode,
+		"Single logout did not reach all session participants; the user may still be signed in at some SPs"},
+	{statusPrefix + "ProxyCountExceeded", "ProxyCountExceeded", TermStatusCode,
+		"The IdP would have to proxy the request to another IdP, but the request's ProxyCount forbids it"},
+	{statusPrefix + "RequestDenied", "RequestDenied", TermStatusCode,
+		"The responder chose not to answer, e.g. because the SP is unknown or the user may not use it"},
+	{statusPrefix + "RequestUnsupported", "RequestUnsupported", TermStatusCode,
+		"The responder does not support the request, e.g. a protocol feature it does not implement"},
+	{statusPrefix + "RequestVersionDeprecated", "RequestVersionDeprecated", TermStatusCode,
+		"The responder no longer accepts the SAML version of the request"},
+	{statusPrefix + "RequestVersionTooHigh", "RequestVersionTooHigh", TermStatusCode,
+		"The SAML version of the request is newer than the responder supports"},
+	{statusPrefix + "RequestVersionTooLow", "RequestVersionTooLow", TermStatusCode,
+		"The SAML version of the request is older than the responder supports"},
+	{statusPrefix + "ResourceNotRecognized", "ResourceNotRecognized", TermStatusCode,
+		"The resource named in the request, e.g. of an AuthzDecisionQuery, is unknown"},
+	{statusPrefix + "TooManyResponses", "TooManyResponses", TermStatusCode,
+		"The response would hold more elements than the responder is able to return"},
+	{statusPrefix + "UnknownAttrProfile", "UnknownAttrProfile", TermStatusCode,
+		"The responder does not know the attribute profile of the request"},
+	{statusPrefix + "UnknownPrincipal", "UnknownPrincipal", TermStatusCode,
+		"The responder does not know the user named in the request's Subject"},
+	{statusPrefix + "UnsupportedBinding", "UnsupportedBinding", TermStatusCode,
+		"The responder cannot send its answer with the binding the request asks for, e.g. its ProtocolBinding"},
+
+	// Signature algorithms
+	{SigAlgRSASHA1, "rsa-sha1", TermSignature,
+		"RSA PKCS#1 v1.5 with SHA-1. SHA-1 is broken for collision resistance; many SPs and IdPs reject it"},
+	{SigAlgRSASHA256, "rsa-sha256", TermSignature,
+		"RSA PKCS#1 v1.5 with SHA-256, the common choice for SAML today"},
+	{"http://www.w3.org/2001/04/xmldsig-more#rsa-sha384", "rsa-sha384", TermSignature,
+		"RSA PKCS#1 v1.5 with SHA-384"},
+	{SigAlgRSASHA512, "rsa-sha512", TermSignature,
+		"RSA PKCS#1 v1.5 with SHA-512"},
+	{"http://www.w3.org/2007/05/xmldsig-more#sha256-rsa-MGF1", "sha256-rsa-MGF1", TermSignature,
+		"RSASSA-PSS with SHA-256; sound, but not supported by every SAML library"},
+	{"http://www.w3.org/2001/04/xmldsig-more#ecdsa-sha1", "ecdsa-sha1", TermSignature,
+		"ECDSA with SHA-1. SHA-1 is broken for collision resistance; avoid it"},
+	{SigAlgECDSASHA256, "ecdsa-sha256", TermSignature,
+		"ECDSA with SHA-256, for EC keys"},
+	{"http://www.w3.org/2001/04/xmldsig-more#ecdsa-sha384", "ecdsa-sha384", TermSignature,
+		"ECDSA with SHA-384, for EC keys"},
+	{SigAlgECDSASHA512, "ecdsa-sha512", TermSignature,
+		"ECDSA with SHA-512, for EC keys"},
+	{"http://www.w3.org/2000/09/xmldsig#dsa-sha1", "dsa-sha1", TermSignature,
+		"DSA with SHA-1, obsolete; avoid it"},
+	{"http://www.w3.org/2000/09/xmldsig#hmac-sha1", "hmac-sha1", TermSignature,
+		"HMAC with SHA-1, a shared-secret MAC. SAML signatures should use public keys; an HMAC signature may indicate an attack"},
+
+	// Digest algorithms
+	{DigestSHA1, "sha1", TermDigest,
+		"SHA-1 digest of the signed element. SHA-1 is broken for collision resistance; many SPs and IdPs reject it"},
+	{"http://www.w3.org/2001/04/xmldsig-more#sha224", "sha224", TermDigest,
+		"SHA-224 digest of the signed element"},
+	{DigestSHA256, "sha256", TermDigest,
+		"SHA-256 digest of the signed element, the common choice for SAML today"},
+	{DigestSHA384, "sha384", TermDigest,
+		"SHA-384 digest of the signed element"},
+	{DigestSHA512, "sha512", TermDigest,
+		"SHA-512 digest of the signed element"},
+
+	// Bindings
+	{BindingHTTPRedirect, "HTTP-Redirect", TermBinding,
+		"The message is deflated, base64-encoded and sent in the SAMLRequest or SAMLResponse query parameter of a redirect. Its signature, if any, is in the Signature and SigAlg parameters rather than the XML. Used for AuthnRequests; too small for most Responses"},
+	{BindingHTTPPost, "HTTP-POST", TermBinding,
+		"The message is base64-encoded in the SAMLRequest or SAMLResponse field of an HTML form the browser posts. Its signature is inside the XML. The usual binding for Responses"},
+	{BindingSimpleSign, "HTTP-POST-SimpleSign", TermBinding,
+		"Like HTTP-POST, but signed like HTTP-Redirect: the signature is in the Signature and SigAlg form fields"},
+	{"urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Artifact", "HTTP-Artifact", TermBinding,
+		"The browser carries only a short SAMLart reference; the receiver resolves it to the message with an ArtifactResolve over SOAP"},
+	{"urn:oasis:names:tc:SAML:2.0:bindings:SOAP", "SOAP", TermBinding,
+		"The message is sent directly between the parties in a SOAP 1.1 envelope, without the browser: for artifact resolution, attribute queries and back-channel logout"},
+	{"urn:oasis:names:tc:SAML:2.0:bindings:PAOS", "PAOS", TermBinding,
+		"Reverse SOAP, used by the Enhanced Client or Proxy (ECP) profile for non-browser clients"},
+	{"urn:oasis:names:tc:SAML:2.0:bindings:URI", "URI", TermBinding,
+		"The message is fetched from a URI; used to retrieve a single assertion by reference"},
+}
+
+// Terms returns the glossary: status codes, signature and digest
+// algorithms, bindings and NameID formats
+func Terms() []Term {
+	terms := append([]Term(nil), glossary...)
+	for _, f := range nameIDFormats {
+		terms = append(terms, Term{URI: f.URI, Name: f.Name, Kind: TermNameIDFormat, Description: f.Description})
+	}
+	return terms
+}
+
+// LookupTerm returns the glossary terms for a URI or a short name such as
+// "Responder", "rsa-sha256" or "HTTP-POST". Short names, and the last
+// segment of URIs, are matched case-insensitively, so a name may match
+// terms of several kinds.
+func LookupTerm(query string) []Term {
+	query = strings.TrimSpace(query)
+	terms := Terms()
+	for _, term := range terms {
+		if term.URI == query {
+			return []Term{term}
+		}
+	}
+	// A SAML 2.0 spelling of a SAML 1.1 NameID format
+	if f, ok := DescribeNameIDFormat(query); ok {
+		return []Term{{URI: f.URI, Name: f.Name, Kind: TermNameIDFormat, Description: f.Description}}
+	}
+
+	var found []Term
+	for _, term := range terms {
+		if strings.EqualFold(term.Name, query) || strings.EqualFold(lastURISegment(term.URI), query) {
+			found = append(found, term)
+		}
+	}
+	return found
+}
+
+// lastURISegment returns the part of a URI after its last ':', '#' or '/'
+func lastURISegment(uri string) string {
+	if i := strings.LastIndexAny(uri, ":#/"); i >= 0 {
+		return uri[i+1:]
+	}
+	return uri
+}
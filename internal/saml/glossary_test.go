@@ -0,0 +1,50 @@
+package saml
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookupTerm(t *testing.T) {
+	tests := []struct {
+		query string
+		uri   string
+		kind  string
+	}{
+		{"Responder", "urn:oasis:names:tc:SAML:2.0:status:Responder", TermStatusCode},
+		{"invalidnameidpolicy", "urn:oasis:names:tc:SAML:2.0:status:InvalidNameIDPolicy", TermStatusCode},
+		{"urn:oasis:names:tc:SAML:2.0:status:NoPassive", "urn:oasis:names:tc:SAML:2.0:status:NoPassive", TermStatusCode},
+		{"RSA-SHA256", SigAlgRSASHA256, TermSignature},
+		{"http://www.w3.org/2000/09/xmldsig#sha1", DigestSHA1, TermDigest},
+		{"HTTP-POST", BindingHTTPPost, TermBinding},
+		{"persistent", "urn:oasis:names:tc:SAML:2.0:nameid-format:persistent", TermNameIDFormat},
+		{"emailAddress", "urn:oasis:names:tc:SAML:1.1:nameid-format:emailAddress", TermNameIDFormat},
+		// The common misspelling of the SAML 1.1 format
+		{"urn:oasis:names:tc:SAML:2.0:nameid-format:emailAddress", "urn:oasis:names:tc:SAML:1.1:nameid-format:emailAddress", TermNameIDFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			terms := LookupTerm(tt.query)
+			require.Len(t, terms, 1)
+			assert.Equal(t, tt.uri, terms[0].URI)
+			assert.Equal(t, tt.kind, terms[0].Kind)
+			assert.NotEmpty(t, terms[0].Description)
+		})
+	}
+
+	assert.Empty(t, LookupTerm("nonsense"))
+}
+
+func TestTerms(t *testing.T) {
+	seen := map[string]bool{}
+	for _, term := range Terms() {
+		assert.False(t, seen[term.URI], "duplicate term %s", term.URI)
+		seen[term.URI] = true
+		assert.NotEmpty(t, term.Name)
+		assert.NotEmpty(t, term.Description)
+	}
+	assert.True(t, seen["urn:oasis:names:tc:SAML:2.0:nameid-format:transient"])
+}
@@ -1,11 +1,21 @@
 package saml
 
 import (
-	"encoding/json"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/xml"
 	"fmt"
+	"html"
+	"io"
+	"math"
 	"net/url"
 	"regexp"
 	"strings"
+	"time"
+
+	"github.com/gliwka/SAMLurai/internal/log"
 )
 
 // HAR represents the root structure of a HAR file
@@ -20,21 +30,35 @@ type HARLog struct {
 
 // HAREntry represents a single HTTP request/response entry
 type HAREntry struct {
-	Request  HARRequest  `json:"request"`
-	Response HARResponse `json:"response"`
+	StartedDateTime string      `json:"startedDateTime,omitempty"`
+	Time            float64     `json:"time,omitempty"`
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+
+	// WebSocketMessages holds the frames of a WebSocket connection, as
+	// recorded by Chrome
+	WebSocketMessages []HARWebSocketMessage `json:"_webSocketMessages,omitempty"`
 }
 
 // HARRequest represents an HTTP request
 type HARRequest struct {
 	Method      string         `json:"method"`
 	URL         string         `json:"url"`
+	Headers     []HARNameValue `json:"headers,omitempty"`
+	Cookies     []HARCookie    `json:"cookies,omitempty"`
 	PostData    *HARPostData   `json:"postData,omitempty"`
 	QueryString []HARNameValue `json:"queryString,omitempty"`
 }
 
 // HARResponse represents an HTTP response
 type HARResponse struct {
-	Content HARContent `json:"content"`
+	Status  int            `json:"status,omitempty"`
+	Headers []HARNameValue `json:"headers,omitempty"`
+	Cookies []HARCookie    `json:"cookies,omitempty"`
+	Content HARContent     `json:"content"`
+
+	// RedirectURL is the target of a redirect, as recorded by the browser
+	RedirectURL string `json:"redirectURL,omitempty"`
 }
 
 // HARPostData represents POST data
@@ -48,6 +72,47 @@ type HARPostData struct {
 type HARContent struct {
 	MimeType string `json:"mimeType"`
 	Text     string `json:"text"`
+
+	// Encoding is "base64" for bodies recorded in base64, such as binary
+	// or still compressed responses. Browsers also use it for text bodies
+	// whose charset they could not tell.
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// isBase64 reports whether the body is recorded in base64
+func (c HARContent) isBase64() bool {
+	return strings.EqualFold(c.Encoding, "base64")
+}
+
+// size returns the size of the body as sent
+func (c HARContent) size() int {
+	if c.isBase64() {
+		return base64.StdEncoding.DecodedLen(len(c.Text))
+	}
+	return len(c.Text)
+}
+
+// body returns the response body as text. Base64 bodies are decoded, and
+// decompressed up to limit bytes if the response was recorded before its
+// gzip or zstd Content-Encoding was undone. Bodies that are not valid
+// base64 despite their encoding are returned as recorded.
+func (c HARContent) body(limit int) string {
+	if !c.isBase64() {
+		return c.Text
+	}
+	// Some tools wrap long base64 bodies into lines or drop the padding
+	data, err := NewDecoder().Decode(c.Text)
+	if err != nil {
+		log.Debug("response body marked as base64 is not valid base64; reading it as text", "error", err)
+		return c.Text
+	}
+	if IsCompressed(data) {
+		if data, err = decompressLimited(data, limit); err != nil {
+			log.Debug("skipping response body that could not be decompressed", "error", err)
+			return ""
+		}
+	}
+	return string(data)
 }
 
 // HARNameValue represents a name-value pair (query params, form params)
@@ -64,7 +129,8 @@ type ExtractedSAML struct {
 	// Type indicates the SAML message type (Response, Request, Assertion, etc.)
 	Type string `json:"type"`
 
-	// Source indicates where the SAML was found (request-body, request-query, response-body)
+	// Source indicates where the SAML was found (request-body, request-query,
+	// response-body, response-location)
 	Source string `json:"source"`
 
 	// URL is the request URL where this SAML was found
@@ -81,47 +147,163 @@ type ExtractedSAML struct {
 
 	// WasDeflated indicates if deflate decompression was applied
 	WasDeflated bool `json:"was_deflated"`
+
+	// Confidence is a score between 0 and 1 indicating how likely this is
+	// a genuine SAML message rather than a false positive
+	Confidence float64 `json:"confidence"`
+
+	// StartedAt is when the HAR entry carrying this message was started
+	StartedAt *time.Time `json:"started_at,omitempty"`
+
+	// DurationMS is the total elapsed time of the HAR entry in milliseconds
+	DurationMS float64 `json:"duration_ms,omitempty"`
+
+	// SimpleSign is set for messages sent with the HTTP-POST-SimpleSign binding
+	SimpleSign *SimpleSignMessage `json:"simple_sign,omitempty"`
+
+	// Line is the line of a log file the message was found on
+	Line int `json:"line,omitempty"`
+
+	// Occurrences is how many times the message was seen when duplicates
+	// were collapsed with Dedupe
+	Occurrences int `json:"occurrences,omitempty"`
 }
 
+// Confidence weights for the extraction heuristics
+const (
+	confidenceParamMatch = 0.4
+	confidenceValidXML   = 0.3
+	confidenceNamespace  = 0.3
+)
+
 // HARExtractor extracts SAML assertions from HAR files
 type HARExtractor struct {
 	decoder *Decoder
+
+	// maxBodySize is the size above which bodies are skipped
+	maxBodySize int
+
+	// webSockets enables searching WebSocket frames
+	webSockets bool
+
+	// ctx, if set, stops extraction once it is done
+	ctx context.Context
 }
 
 // NewHARExtractor creates a new HAR extractor
 func NewHARExtractor() *HARExtractor {
 	return &HARExtractor{
-		decoder: NewDecoder(),
+		decoder:     NewDecoder(),
+		maxBodySize: DefaultMaxBodySize,
+	}
+}
+
+// LooksLikeHAR checks if the content has the JSON structure of a HAR file
+func LooksLikeHAR(content string) bool {
+	trimmed := strings.TrimSpace(content)
+	return strings.HasPrefix(trimmed, "{") && strings.Contains(trimmed, `"log"`) && strings.Contains(trimmed, `"entries"`)
+}
+
+// Extract extracts all SAML assertions from a capture, which may be a HAR
+// file, a SAML-tracer export or a Fiddler session archive
+func (e *HARExtractor) Extract(data []byte) ([]ExtractedSAML, error) {
+	if LooksLikeSAZ(data) {
+		return e.ExtractFromSAZ(data)
 	}
+	if LooksLikeSAMLTracer(string(data)) {
+		return e.ExtractFromSAMLTracer(data)
+	}
+	return e.ExtractFromHAR(data)
+}
+
+// ExtractContext is Extract, stopping with the cause of ctx being done
+// once it is canceled or its deadline passes
+func (e *HARExtractor) ExtractContext(ctx context.Context, data []byte) ([]ExtractedSAML, error) {
+	if err := context.Cause(ctx); err != nil {
+		return nil, err
+	}
+	c := *e
+	c.ctx = ctx
+	results, err := c.Extract(data)
+	return contextResult(ctx, results, err)
 }
 
 // ExtractFromHAR extracts all SAML assertions from a HAR file
 func (e *HARExtractor) ExtractFromHAR(data []byte) ([]ExtractedSAML, error) {
-	var har HAR
-	if err := json.Unmarshal(data, &har); err != nil {
-		return nil, fmt.Errorf("failed to parse HAR file: %w", err)
+	return e.ExtractReader(bytes.NewReader(data))
+}
+
+// ExtractFromHARContext is ExtractFromHAR, stopping with the cause of ctx
+// being done once it is canceled or its deadline passes
+func (e *HARExtractor) ExtractFromHARContext(ctx context.Context, data []byte) ([]ExtractedSAML, error) {
+	if err := context.Cause(ctx); err != nil {
+		return nil, err
 	}
+	c := *e
+	c.ctx = ctx
+	results, err := c.ExtractFromHAR(data)
+	return contextResult(ctx, results, err)
+}
 
-	var results []ExtractedSAML
+// ExtractFromEntry extracts the SAML messages carried by a single HAR entry
+func (e *HARExtractor) ExtractFromEntry(entry HAREntry) []ExtractedSAML {
 	index := 1
+	return e.extractFromEntries([]HAREntry{entry}, &index)
+}
+
+// extractFromEntries extracts SAML from each HAR entry in order
+func (e *HARExtractor) extractFromEntries(entries []HAREntry, index *int) []ExtractedSAML {
+	var results []ExtractedSAML
+
+	for _, entry := range entries {
+		entryStart := len(results)
+		e.trimBodies(&entry)
 
-	for _, entry := range har.Log.Entries {
 		// Check request query parameters
-		extracted := e.extractFromQueryParams(entry.Request.QueryString, entry.Request.URL, &index)
+		extracted := e.extractFromQueryParams(entry.Request.QueryString, entry.Request.URL, index)
 		results = append(results, extracted...)
 
 		// Check request POST data
 		if entry.Request.PostData != nil {
-			extracted = e.extractFromPostData(entry.Request.PostData, entry.Request.URL, &index)
+			extracted = e.extractFromPostData(entry.Request.PostData, entry.Request.URL, index)
 			results = append(results, extracted...)
 		}
 
 		// Check response body for SAML content
-		extracted = e.extractFromResponseBody(entry.Response.Content, entry.Request.URL, &index)
+		extracted = e.extractFromResponseBody(entry.Response.Content, entry.Request.URL, index)
+		results = append(results, extracted...)
+
+		// Check redirects, which may be all that is left of a Redirect
+		// binding message in a truncated capture
+		extracted = e.extractFromLocation(entry.Response, entry.Request.URL, index)
 		results = append(results, extracted...)
+
+		// Attach entry timing to everything found in this entry
+		startedAt := parseHARTime(entry.StartedDateTime)
+		for i := entryStart; i < len(results); i++ {
+			results[i].StartedAt = startedAt
+			results[i].DurationMS = entry.Time
+		}
+
+		if e.webSockets && len(entry.WebSocketMessages) > 0 {
+			results = append(results, e.extractFromWebSocket(entry.WebSocketMessages, entry.Request.URL, index)...)
+		}
 	}
 
-	return results, nil
+	return results
+}
+
+// parseHARTime parses a HAR startedDateTime value, returning nil if it is
+// missing or malformed
+func parseHARTime(value string) *time.Time {
+	if value == "" {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return nil
+	}
+	return &t
 }
 
 // extractFromQueryParams extracts SAML from URL query parameters
@@ -152,6 +334,73 @@ func (e *HARExtractor) extractFromQueryParams(params []HARNameValue, requestURL
 	return results
 }
 
+// extractFromLocation extracts SAML from the query of a redirect target,
+// taken from the Location header or the recorded redirect URL
+func (e *HARExtractor) extractFromLocation(response HARResponse, requestURL string, index *int) []ExtractedSAML {
+	var results []ExtractedSAML
+
+	var targets []string
+	for _, header := range response.Headers {
+		if strings.EqualFold(header.Name, "Location") {
+			targets = append(targets, header.Value)
+		}
+	}
+	targets = append(targets, response.RedirectURL)
+
+	seen := make(map[string]bool)
+	for _, target := range targets {
+		if target == "" {
+			continue
+		}
+		location, err := url.Parse(target)
+		if err != nil {
+			continue
+		}
+		// Relative redirects are resolved against the request
+		if base, err := url.Parse(requestURL); err == nil {
+			location = base.ResolveReference(location)
+		}
+		if seen[location.String()] {
+			continue
+		}
+		seen[location.String()] = true
+
+		for key, values := range location.Query() {
+			if !e.isSAMLParameter(key) {
+				continue
+			}
+			for _, value := range values {
+				if extracted := e.tryExtractSAML(value, key, location.String(), "response-location", index); extracted != nil {
+					results = append(results, *extracted)
+				}
+			}
+		}
+	}
+
+	return results
+}
+
+// dropFollowedRedirects removes messages found in a redirect target that
+// the capture also shows being requested, so each message is listed once
+func dropFollowedRedirects(results []ExtractedSAML) []ExtractedSAML {
+	var kept []ExtractedSAML
+	for i, r := range results {
+		followed := false
+		if r.Source == "response-location" {
+			for _, later := range results[i+1:] {
+				if later.Source == "request-query" && bytes.Equal(later.DecodedXML, r.DecodedXML) {
+					followed = true
+					break
+				}
+			}
+		}
+		if !followed {
+			kept = append(kept, r)
+		}
+	}
+	return kept
+}
+
 // extractFromPostData extracts SAML from POST body
 func (e *HARExtractor) extractFromPostData(postData *HARPostData, requestURL string, index *int) []ExtractedSAML {
 	var results []ExtractedSAML
@@ -186,27 +435,74 @@ func (e *HARExtractor) extractFromPostData(postData *HARPostData, requestURL str
 		results = append(results, *extracted)
 	}
 
+	// Mark messages sent with the HTTP-POST-SimpleSign binding
+	if simpleSign, ok := SimpleSignFromValues(postDataValues(postData)); ok {
+		for i := range results {
+			if results[i].ParameterName == simpleSign.ParameterName {
+				results[i].SimpleSign = simpleSign
+			}
+		}
+	}
+
 	return results
 }
 
+// postDataValues collects the form fields of a POST body. Values are only
+// path-unescaped, since '+' is significant in base64 signatures.
+func postDataValues(postData *HARPostData) url.Values {
+	values := url.Values{}
+	for _, param := range postData.Params {
+		value := param.Value
+		if unescaped, err := url.PathUnescape(value); err == nil {
+			value = unescaped
+		}
+		values.Add(param.Name, value)
+	}
+	if strings.Contains(postData.MimeType, "application/x-www-form-urlencoded") {
+		if parsed, err := url.ParseQuery(postData.Text); err == nil {
+			for key, vals := range parsed {
+				if _, seen := values[key]; !seen {
+					values[key] = vals
+				}
+			}
+		}
+	}
+	return values
+}
+
 // extractFromResponseBody extracts SAML from response body
 func (e *HARExtractor) extractFromResponseBody(content HARContent, requestURL string, index *int) []ExtractedSAML {
 	var results []ExtractedSAML
 
-	if content.Text == "" {
+	body := content.body(e.maxBodySize)
+	if body == "" {
 		return results
 	}
 
 	// Check for SAML in HTML form (common for POST binding)
-	samlMatches := e.extractSAMLFromHTML(content.Text)
+	samlMatches := e.extractSAMLFromHTML(body)
+	found := make(map[string]bool)
 	for paramName, value := range samlMatches {
+		found[value] = true
 		if extracted := e.tryExtractSAML(value, paramName, requestURL, "response-body", index); extracted != nil {
 			results = append(results, *extracted)
 		}
 	}
 
+	// Check for SAML in JSON payloads and JavaScript variables
+	if isScriptMimeType(content.MimeType) {
+		for _, candidate := range e.extractSAMLFromScript(body) {
+			if found[candidate.Value] {
+				continue
+			}
+			if extracted := e.tryExtractSAML(candidate.Value, candidate.Name, requestURL, "response-body", index); extracted != nil {
+				results = append(results, *extracted)
+			}
+		}
+	}
+
 	// Try direct extraction if content looks like SAML or base64
-	if extracted := e.tryExtractSAML(content.Text, "", requestURL, "response-body", index); extracted != nil {
+	if extracted := e.tryExtractSAML(body, "", requestURL, "response-body", index); extracted != nil {
 		results = append(results, *extracted)
 	}
 
@@ -220,8 +516,8 @@ func (e *HARExtractor) extractSAMLFromHTML(html string) map[string]string {
 	// Pattern to match hidden input fields with SAML data
 	// Matches: <input type="hidden" name="SAMLResponse" value="..."/>
 	patterns := []string{
-		`<input[^>]*name=["']?(SAMLResponse|SAMLRequest|SAMLAssertion)["']?[^>]*value=["']([^"']+)["']`,
-		`<input[^>]*value=["']([^"']+)["'][^>]*name=["']?(SAMLResponse|SAMLRequest|SAMLAssertion)["']?`,
+		`<input[^>]*name=["']?(SAMLResponse|SAMLRequest|SAMLAssertion|wresult)["']?[^>]*value=["']([^"']+)["']`,
+		`<input[^>]*value=["']([^"']+)["'][^>]*name=["']?(SAMLResponse|SAMLRequest|SAMLAssertion|wresult)["']?`,
 	}
 
 	for _, pattern := range patterns {
@@ -230,7 +526,7 @@ func (e *HARExtractor) extractSAMLFromHTML(html string) map[string]string {
 		for _, match := range matches {
 			if len(match) >= 3 {
 				// Order depends on which pattern matched
-				if strings.HasPrefix(match[1], "SAML") {
+				if strings.HasPrefix(match[1], "SAML") || match[1] == "wresult" {
 					results[match[1]] = match[2]
 				} else {
 					results[match[2]] = match[1]
@@ -252,6 +548,7 @@ func (e *HARExtractor) isSAMLParameter(name string) bool {
 		"samlart",
 		"logoutrequest",
 		"logoutresponse",
+		"wresult",
 	}
 
 	for _, param := range samlParams {
@@ -268,8 +565,9 @@ func (e *HARExtractor) tryExtractSAML(value, paramName, requestURL, source strin
 		return nil
 	}
 
-	// URL decode first if necessary
-	decoded, err := url.QueryUnescape(value)
+	// URL decode first if necessary. Only %XX escapes are decoded, since
+	// '+' is a base64 character rather than an encoded space.
+	decoded, err := url.PathUnescape(value)
 	if err == nil && decoded != value {
 		value = decoded
 	}
@@ -277,9 +575,12 @@ func (e *HARExtractor) tryExtractSAML(value, paramName, requestURL, source strin
 	var xmlData []byte
 	var wasDeflated bool
 
-	// Try regular base64 decode first
-	xmlData, err = e.decoder.Decode(value)
-	if err != nil {
+	// WS-Trust messages travel as plain XML: in SOAP bodies of active
+	// requestors and in the wresult field of WS-Federation passive ones.
+	// Everything else is base64, possibly deflated.
+	if raw := []byte(html.UnescapeString(strings.TrimSpace(value))); e.looksLikeXML(raw) && IsWSTrust(raw) {
+		xmlData = raw
+	} else if xmlData, err = e.decoder.Decode(value); err != nil {
 		return nil
 	}
 
@@ -309,12 +610,92 @@ func (e *HARExtractor) tryExtractSAML(value, paramName, requestURL, source strin
 		RawValue:      value,
 		DecodedXML:    xmlData,
 		WasDeflated:   wasDeflated,
+		Confidence:    e.scoreConfidence(paramName, xmlData),
 	}
 
 	*index++
 	return result
 }
 
+// scoreConfidence rates how likely the extracted value is a real SAML message.
+// A known SAML parameter name, well-formed XML and the presence of a SAML
+// namespace each contribute to the score.
+func (e *HARExtractor) scoreConfidence(paramName string, xmlData []byte) float64 {
+	score := 0.0
+
+	if e.isSAMLParameter(paramName) {
+		score += confidenceParamMatch
+	}
+
+	if isWellFormedXML(xmlData) {
+		score += confidenceValidXML
+	}
+
+	if bytes.Contains(xmlData, []byte("urn:oasis:names:tc:SAML:2.0:")) {
+		score += confidenceNamespace
+	}
+
+	return math.Round(score*100) / 100
+}
+
+// isWellFormedXML checks if data can be tokenized as XML without errors
+func isWellFormedXML(data []byte) bool {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	sawElement := false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return sawElement
+		}
+		if err != nil {
+			return false
+		}
+		if _, ok := token.(xml.StartElement); ok {
+			sawElement = true
+		}
+	}
+}
+
+// FilterByConfidence returns only the extracted messages whose confidence
+// is at least minConfidence
+func FilterByConfidence(results []ExtractedSAML, minConfidence float64) []ExtractedSAML {
+	if minConfidence <= 0 {
+		return results
+	}
+
+	var filtered []ExtractedSAML
+	for _, r := range results {
+		if r.Confidence >= minConfidence {
+			filtered = append(filtered, r)
+		}
+	}
+	return filtered
+}
+
+// Dedupe collapses messages with the same decoded XML found at the same
+// source, as recorded by browsers that preload or retry requests. The
+// first of each is kept, counting how many times it appeared.
+func Dedupe(results []ExtractedSAML) []ExtractedSAML {
+	type key struct {
+		source string
+		sum    [sha256.Size]byte
+	}
+
+	seen := make(map[key]int)
+	var deduped []ExtractedSAML
+	for _, r := range results {
+		k := key{source: r.Source, sum: sha256.Sum256(r.DecodedXML)}
+		if i, ok := seen[k]; ok {
+			deduped[i].Occurrences++
+			continue
+		}
+		seen[k] = len(deduped)
+		r.Occurrences = 1
+		deduped = append(deduped, r)
+	}
+	return deduped
+}
+
 // looksLikeXML checks if data appears to be XML
 func (e *HARExtractor) looksLikeXML(data []byte) bool {
 	trimmed := strings.TrimSpace(string(data))
@@ -337,6 +718,8 @@ func (e *HARExtractor) isSAMLXML(data []byte) bool {
 		"<Assertion",
 		"<LogoutRequest",
 		"<LogoutResponse",
+		"<AttributeQuery",
+		"RequestSecurityToken",
 	}
 
 	for _, indicator := range samlIndicators {
@@ -347,6 +730,12 @@ func (e *HARExtractor) isSAMLXML(data []byte) bool {
 	return false
 }
 
+// DetectType returns the type of a SAML message from its root element
+// name, without parsing it, or "Unknown"
+func DetectType(data []byte) string {
+	return (&HARExtractor{}).detectSAMLType(data)
+}
+
 // detectSAMLType determines the type of SAML message
 // Order matters: check Response/Request types before Assertion since
 // responses contain assertions
@@ -359,6 +748,14 @@ func (e *HARExtractor) detectSAMLType(data []byte) string {
 		typeName   string
 		indicators []string
 	}{
+		{
+			"RequestSecurityTokenResponse",
+			[]string{"RequestSecurityTokenResponse"},
+		},
+		{
+			"RequestSecurityToken",
+			[]string{"RequestSecurityToken"},
+		},
 		{
 			"Response",
 			[]string{"samlp:Response", "saml2p:Response", "<Response "},
@@ -375,6 +772,10 @@ func (e *HARExtractor) detectSAMLType(data []byte) string {
 			"LogoutResponse",
 			[]string{"samlp:LogoutResponse", "saml2p:LogoutResponse", "<LogoutResponse "},
 		},
+		{
+			"AttributeQuery",
+			[]string{"samlp:AttributeQuery", "saml2p:AttributeQuery", "<AttributeQuery "},
+		},
 		{
 			"Assertion",
 			[]string{"saml:Assertion", "saml2:Assertion", "<Assertion "},
@@ -400,6 +801,13 @@ func (e *HARExtractor) GenerateFilename(extracted ExtractedSAML) string {
 	return fmt.Sprintf("saml_%03d_%s_%s.xml", extracted.Index, safeType, safeSource)
 }
 
+// GenerateVerifiedFilename is like GenerateFilename, with the signature
+// verification verdict appended so a directory listing shows which
+// messages were tampered with
+func (e *HARExtractor) GenerateVerifiedFilename(extracted ExtractedSAML, verdict string) string {
+	return strings.TrimSuffix(e.GenerateFilename(extracted), ".xml") + "_" + verdict + ".xml"
+}
+
 // ExtractFromBase64 extracts SAML from a raw base64 string (for direct input)
 func (e *HARExtractor) ExtractFromBase64(value string) (*ExtractedSAML, error) {
 	var xmlData []byte
@@ -434,5 +842,6 @@ func (e *HARExtractor) ExtractFromBase64(value string) (*ExtractedSAML, error) {
 		RawValue:    value,
 		DecodedXML:  xmlData,
 		WasDeflated: wasDeflated,
+		Confidence:  e.scoreConfidence("", xmlData),
 	}, nil
 }
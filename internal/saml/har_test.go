@@ -2,7 +2,12 @@ package saml
 
 import (
 	"encoding/base64"
+	"net/url"
+	"strings"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestHARExtractor_ExtractFromHAR(t *testing.T) {
@@ -308,3 +313,265 @@ func TestHARExtractor_ExtractFromBase64(t *testing.T) {
 		t.Errorf("Source = %q, want direct-input", result.Source)
 	}
 }
+
+func TestHARExtractor_ScoreConfidence(t *testing.T) {
+	extractor := NewHARExtractor()
+
+	samlXML := []byte(`<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_r1"></samlp:Response>`)
+	noNamespace := []byte(`<Response ID="_r1"></Response>`)
+	malformed := []byte(`<Response ID="_r1"><Assertion></Response>`)
+
+	tests := []struct {
+		name      string
+		paramName string
+		xmlData   []byte
+		want      float64
+	}{
+		{"param, valid XML and namespace", "SAMLResponse", samlXML, 1.0},
+		{"no param name", "", samlXML, 0.6},
+		{"no namespace", "SAMLResponse", noNamespace, 0.7},
+		{"malformed XML without param", "", malformed, 0.0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := extractor.scoreConfidence(tt.paramName, tt.xmlData)
+			if got != tt.want {
+				t.Errorf("scoreConfidence() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterByConfidence(t *testing.T) {
+	results := []ExtractedSAML{
+		{Index: 1, Confidence: 1.0},
+		{Index: 2, Confidence: 0.3},
+		{Index: 3, Confidence: 0.7},
+	}
+
+	if got := FilterByConfidence(results, 0); len(got) != 3 {
+		t.Errorf("FilterByConfidence(0) returned %d results, want 3", len(got))
+	}
+
+	got := FilterByConfidence(results, 0.7)
+	if len(got) != 2 {
+		t.Fatalf("FilterByConfidence(0.7) returned %d results, want 2", len(got))
+	}
+	if got[0].Index != 1 || got[1].Index != 3 {
+		t.Errorf("FilterByConfidence(0.7) kept indexes %d and %d, want 1 and 3", got[0].Index, got[1].Index)
+	}
+}
+
+func TestDedupe(t *testing.T) {
+	response := []byte(`<samlp:Response ID="_a"/>`)
+	results := []ExtractedSAML{
+		{Index: 1, Source: "response-body", DecodedXML: response},
+		{Index: 2, Source: "request-body", DecodedXML: response},
+		{Index: 3, Source: "request-body", DecodedXML: response},
+		{Index: 4, Source: "request-body", DecodedXML: []byte(`<samlp:Response ID="_b"/>`)},
+		{Index: 5, Source: "request-body", DecodedXML: response},
+	}
+
+	got := Dedupe(results)
+	require.Len(t, got, 3)
+	assert.Equal(t, []int{1, 2, 4}, []int{got[0].Index, got[1].Index, got[2].Index})
+	assert.Equal(t, []int{1, 3, 1}, []int{got[0].Occurrences, got[1].Occurrences, got[2].Occurrences})
+	assert.Zero(t, results[1].Occurrences, "input must not be modified")
+}
+
+func TestHARExtractor_EntryTiming(t *testing.T) {
+	extractor := NewHARExtractor()
+
+	samlResponse := `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_resp1"></samlp:Response>`
+	encoded := base64.StdEncoding.EncodeToString([]byte(samlResponse))
+
+	har := `{
+		"log": {
+			"entries": [{
+				"startedDateTime": "2024-01-15T10:00:00.123Z",
+				"time": 245.5,
+				"request": {
+					"method": "GET",
+					"url": "https://sp.example.com/acs",
+					"queryString": [{"name": "SAMLResponse", "value": "` + encoded + `"}]
+				},
+				"response": {"content": {"mimeType": "text/html", "text": ""}}
+			}]
+		}
+	}`
+
+	results, err := extractor.ExtractFromHAR([]byte(har))
+	if err != nil {
+		t.Fatalf("ExtractFromHAR() error = %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("Expected 1 result, got %d", len(results))
+	}
+
+	if results[0].StartedAt == nil {
+		t.Fatal("StartedAt = nil, want entry start time")
+	}
+	if got := results[0].StartedAt.UnixMilli(); got != 1705312800123 {
+		t.Errorf("StartedAt = %d ms, want 1705312800123", got)
+	}
+	if results[0].DurationMS != 245.5 {
+		t.Errorf("DurationMS = %v, want 245.5", results[0].DurationMS)
+	}
+}
+
+func TestHARExtractor_ExtractFromEntry_PlusInBase64(t *testing.T) {
+	// Encodes to base64 containing '+', which must not be read as a space
+	xml := `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_r"><samlp:Status><samlp:StatusCode Value="urn:oasis:names:tc:SAML:2.0:status:Success"/></samlp:Status></samlp:Response>`
+	encoded := base64.StdEncoding.EncodeToString([]byte(xml))
+	if !strings.Contains(encoded, "+") {
+		t.Fatal("test value must contain '+'")
+	}
+
+	entry := HAREntry{
+		Request: HARRequest{Method: "GET", URL: "https://idp.example.com/login"},
+		Response: HARResponse{Status: 200, Content: HARContent{
+			MimeType: "text/html",
+			Text:     `<input type="hidden" name="SAMLResponse" value="` + encoded + `"/>`,
+		}},
+	}
+
+	results := NewHARExtractor().ExtractFromEntry(entry)
+	if len(results) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(results))
+	}
+	if string(results[0].DecodedXML) != xml {
+		t.Errorf("decoded XML mismatch: %s", results[0].DecodedXML)
+	}
+}
+
+func TestGenerateVerifiedFilename(t *testing.T) {
+	e := NewHARExtractor()
+	extracted := ExtractedSAML{Index: 3, Type: "Response", Source: "request-body"}
+	if got, want := e.GenerateVerifiedFilename(extracted, VerdictSigFail), "saml_003_response_request_body_sigfail.xml"; got != want {
+		t.Errorf("GenerateVerifiedFilename() = %q, want %q", got, want)
+	}
+}
+
+func TestHARExtractor_LocationHeader(t *testing.T) {
+	authnRequest := `<samlp:AuthnRequest xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_loc1"/>`
+	encoded := url.QueryEscape(base64.StdEncoding.EncodeToString([]byte(authnRequest)))
+	redirect := `{
+		"request": {"method": "GET", "url": "https://sp.example.com/login"},
+		"response": {"status": 302, "headers": [{"name": "location", "value": "https://idp.example.com/sso?SAMLRequest=` + encoded + `&RelayState=x"}], "content": {}}
+	}`
+	followed := `{
+		"request": {"method": "GET", "url": "https://idp.example.com/sso?SAMLRequest=` + encoded + `&RelayState=x"},
+		"response": {"status": 200, "content": {}}
+	}`
+
+	t.Run("truncated capture", func(t *testing.T) {
+		results, err := NewHARExtractor().ExtractFromHAR([]byte(`{"log": {"entries": [` + redirect + `]}}`))
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, "AuthnRequest", results[0].Type)
+		assert.Equal(t, "response-location", results[0].Source)
+		assert.Equal(t, "SAMLRequest", results[0].ParameterName)
+		assert.True(t, strings.HasPrefix(results[0].URL, "https://idp.example.com/sso?"))
+	})
+
+	t.Run("relative redirect URL", func(t *testing.T) {
+		har := `{"log": {"entries": [{
+			"request": {"method": "GET", "url": "https://idp.example.com/start"},
+			"response": {"status": 302, "redirectURL": "/sso?SAMLRequest=` + encoded + `", "content": {}}
+		}]}}`
+		results, err := NewHARExtractor().ExtractFromHAR([]byte(har))
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, "https://idp.example.com/sso?SAMLRequest="+encoded, results[0].URL)
+	})
+
+	t.Run("followed redirect", func(t *testing.T) {
+		results, err := NewHARExtractor().ExtractFromHAR([]byte(`{"log": {"entries": [` + redirect + `,` + followed + `]}}`))
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, "request-query", results[0].Source)
+		assert.Equal(t, 1, results[0].Index)
+	})
+}
+
+func TestHARExtractor_Base64CompressedBody(t *testing.T) {
+	samlResponse := `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_gz"/>`
+	page := `<html><body><form><input type="hidden" name="SAMLResponse" value="` + base64.StdEncoding.EncodeToString([]byte(samlResponse)) + `"/></form></body></html>`
+	body := base64.StdEncoding.EncodeToString(gzipped(t, []byte(page)))
+
+	har := `{"log": {"entries": [{
+		"request": {"method": "GET", "url": "https://idp.example.com/sso"},
+		"response": {"content": {"mimeType": "text/html", "encoding": "base64", "text": "` + body + `"}}
+	}]}}`
+
+	results, err := NewHARExtractor().ExtractFromHAR([]byte(har))
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "SAMLResponse", results[0].ParameterName)
+	assert.Contains(t, string(results[0].DecodedXML), `ID="_gz"`)
+}
+
+func TestHARExtractor_Base64Body(t *testing.T) {
+	samlResponse := `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_b64"/>`
+	form := `<html><body><form><input type="hidden" name="SAMLResponse" value="` + base64.StdEncoding.EncodeToString([]byte(samlResponse)) + `"/></form></body></html>`
+	encoded := base64.StdEncoding.EncodeToString([]byte(form))
+
+	// Wrapped at 76 characters, as MIME tools write base64
+	var wrapped strings.Builder
+	for i := 0; i < len(encoded); i += 76 {
+		wrapped.WriteString(encoded[i:min(i+76, len(encoded))])
+		wrapped.WriteString(`\r\n`)
+	}
+
+	har := func(mimeType, encoding, text string) string {
+		return `{"log": {"entries": [{
+			"request": {"method": "GET", "url": "https://idp.example.com/sso"},
+			"response": {"content": {"mimeType": "` + mimeType + `", "encoding": "` + encoding + `", "text": "` + text + `"}}
+		}]}}`
+	}
+
+	tests := []struct {
+		name      string
+		har       string
+		wantCount int
+	}{
+		{"binary-marked HTML", har("application/octet-stream", "base64", encoded), 1},
+		{"encoding in upper case", har("text/html", "BASE64", encoded), 1},
+		{"wrapped lines", har("text/html", "base64", wrapped.String()), 1},
+		{"without padding", har("text/html", "base64", strings.TrimRight(encoded, "=")), 1},
+		{"base64 is not scanned as text", har("text/html", "", encoded), 0},
+		{"invalid base64 read as text", har("text/html", "base64", strings.ReplaceAll(form, `"`, `\"`)), 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := NewHARExtractor().ExtractReader(strings.NewReader(tt.har))
+			require.NoError(t, err)
+			require.Len(t, results, tt.wantCount)
+			if tt.wantCount > 0 {
+				assert.Equal(t, "SAMLResponse", results[0].ParameterName)
+				assert.Contains(t, string(results[0].DecodedXML), `ID="_b64"`)
+			}
+		})
+	}
+}
+
+func TestHARExtractor_Base64BodySizeLimit(t *testing.T) {
+	samlResponse := `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_big"/>`
+	body := base64.StdEncoding.EncodeToString([]byte(base64.StdEncoding.EncodeToString([]byte(samlResponse))))
+	har := `{"log": {"entries": [{
+		"request": {"method": "GET", "url": "https://idp.example.com/sso"},
+		"response": {"content": {"mimeType": "text/plain", "encoding": "base64", "text": "` + body + `"}}
+	}]}}`
+
+	// The limit applies to the decoded body, not its larger base64 form
+	decoded := base64.StdEncoding.DecodedLen(len(body))
+	results, err := NewHARExtractor().WithMaxBodySize(decoded).ExtractReader(strings.NewReader(har))
+	require.NoError(t, err)
+	assert.Len(t, results, 1)
+
+	results, err = NewHARExtractor().WithMaxBodySize(decoded - 1).ExtractReader(strings.NewReader(har))
+	require.NoError(t, err)
+	assert.Empty(t, results)
+}
@@ -0,0 +1,74 @@
+package saml
+
+import (
+	"net/http"
+	"strings"
+)
+
+// HARCookie represents a cookie sent with a request or set by a response
+type HARCookie struct {
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Path     string `json:"path,omitempty"`
+	Domain   string `json:"domain,omitempty"`
+	Expires  string `json:"expires,omitempty"`
+	HTTPOnly bool   `json:"httpOnly,omitempty"`
+	Secure   bool   `json:"secure,omitempty"`
+}
+
+// SentCookies returns the cookies sent with the request, from the cookies
+// array or, if the browser did not fill it in, the Cookie header
+func (r HARRequest) SentCookies() []HARCookie {
+	if len(r.Cookies) > 0 {
+		return r.Cookies
+	}
+	var cookies []HARCookie
+	for _, header := range r.Headers {
+		if !strings.EqualFold(header.Name, "Cookie") {
+			continue
+		}
+		parsed, err := http.ParseCookie(header.Value)
+		if err != nil {
+			continue
+		}
+		for _, c := range parsed {
+			cookies = append(cookies, HARCookie{Name: c.Name, Value: c.Value})
+		}
+	}
+	return cookies
+}
+
+// SetCookies returns the cookies set by the response, from the cookies array
+// or, if the browser did not fill it in, the Set-Cookie headers. Cookies
+// being deleted are left out.
+func (r HARResponse) SetCookies() []HARCookie {
+	cookies := r.Cookies
+	if len(cookies) == 0 {
+		for _, header := range r.Headers {
+			if !strings.EqualFold(header.Name, "Set-Cookie") {
+				continue
+			}
+			c, err := http.ParseSetCookie(header.Value)
+			if err != nil {
+				continue
+			}
+			cookies = append(cookies, HARCookie{
+				Name:     c.Name,
+				Value:    c.Value,
+				Path:     c.Path,
+				Domain:   c.Domain,
+				Expires:  c.RawExpires,
+				HTTPOnly: c.HttpOnly,
+				Secure:   c.Secure,
+			})
+		}
+	}
+
+	var set []HARCookie
+	for _, c := range cookies {
+		if c.Value != "" {
+			set = append(set, c)
+		}
+	}
+	return set
+}
@@ -0,0 +1,25 @@
+package saml
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHARRequest_SentCookies(t *testing.T) {
+	fromHeader := HARRequest{Headers: []HARNameValue{{Name: "cookie", Value: "a=1; sid=xyz"}}}
+	assert.Equal(t, []HARCookie{{Name: "a", Value: "1"}, {Name: "sid", Value: "xyz"}}, fromHeader.SentCookies())
+
+	// The cookies array takes precedence over the header
+	fromArray := HARRequest{Cookies: []HARCookie{{Name: "sid", Value: "xyz"}}, Headers: fromHeader.Headers}
+	assert.Equal(t, []HARCookie{{Name: "sid", Value: "xyz"}}, fromArray.SentCookies())
+}
+
+func TestHARResponse_SetCookies(t *testing.T) {
+	resp := HARResponse{Headers: []HARNameValue{
+		{Name: "Set-Cookie", Value: "sid=xyz; Path=/; Domain=sp.example.com; HttpOnly; Secure"},
+		{Name: "Set-Cookie", Value: "old=; Expires=Thu, 01 Jan 1970 00:00:00 GMT"},
+		{Name: "Content-Type", Value: "text/html"},
+	}}
+	assert.Equal(t, []HARCookie{{Name: "sid", Value: "xyz", Path: "/", Domain: "sp.example.com", HTTPOnly: true, Secure: true}}, resp.SetCookies())
+}
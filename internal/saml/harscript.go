@@ -0,0 +1,109 @@
+package saml
+
+import (
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// scriptValue is a candidate SAML value found in a JSON or JavaScript body.
+// Name is the key or variable it was assigned to, if any.
+type scriptValue struct {
+	Name  string
+	Value string
+}
+
+// minBlobLength is the shortest unnamed string considered a base64 SAML
+// message; even a minimal deflated AuthnRequest is longer
+const minBlobLength = 100
+
+var (
+	// scriptAssignment matches SAML values assigned to a known name in
+	// JavaScript, e.g. var SAMLResponse = "..." or {SAMLResponse: '...'}
+	scriptAssignment = regexp.MustCompile(`(?i)["']?\b(SAMLResponse|SAMLRequest|SAMLAssertion|wresult)["']?\s*[:=]\s*["']([^"']+)["']`)
+
+	// scriptBlob matches long quoted strings of base64 characters, allowing
+	// for escaped slashes
+	scriptBlob = regexp.MustCompile(`["']((?:[A-Za-z0-9+]|\\?/){100,}={0,2})["']`)
+)
+
+// isScriptMimeType reports whether a response body is JSON or JavaScript
+func isScriptMimeType(mimeType string) bool {
+	mimeType = strings.ToLower(mimeType)
+	return strings.Contains(mimeType, "json") || strings.Contains(mimeType, "javascript") || strings.Contains(mimeType, "ecmascript")
+}
+
+// extractSAMLFromScript finds candidate SAML values in a JSON or JavaScript
+// body: values of known SAML keys first, then any other long base64 string
+func (e *HARExtractor) extractSAMLFromScript(body string) []scriptValue {
+	var values []scriptValue
+	if trimmed := strings.TrimSpace(body); strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+		var doc interface{}
+		if err := json.Unmarshal([]byte(body), &doc); err == nil {
+			e.collectJSONStrings(doc, "", &values)
+			return orderScriptValues(values)
+		}
+	}
+
+	for _, match := range scriptAssignment.FindAllStringSubmatch(body, -1) {
+		values = append(values, scriptValue{Name: match[1], Value: unescapeScript(match[2])})
+	}
+	for _, match := range scriptBlob.FindAllStringSubmatch(body, -1) {
+		values = append(values, scriptValue{Value: unescapeScript(match[1])})
+	}
+	return orderScriptValues(values)
+}
+
+// collectJSONStrings collects the string values of a decoded JSON document
+// that are either assigned to a SAML key or long enough to be a message
+func (e *HARExtractor) collectJSONStrings(v interface{}, key string, values *[]scriptValue) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		// Sorted, so messages are numbered the same on every run
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			e.collectJSONStrings(v[k], k, values)
+		}
+	case []interface{}:
+		for _, child := range v {
+			e.collectJSONStrings(child, key, values)
+		}
+	case string:
+		if e.isSAMLParameter(key) {
+			*values = append(*values, scriptValue{Name: key, Value: v})
+		} else if len(v) >= minBlobLength {
+			*values = append(*values, scriptValue{Value: v})
+		}
+	}
+}
+
+// orderScriptValues puts named values first and drops duplicates, such as
+// a named value also matched as a blob
+func orderScriptValues(values []scriptValue) []scriptValue {
+	seen := make(map[string]bool)
+	var named, unnamed []scriptValue
+	for _, v := range values {
+		if v.Name != "" && !seen[v.Value] {
+			seen[v.Value] = true
+			named = append(named, v)
+		}
+	}
+	for _, v := range values {
+		if v.Name == "" && !seen[v.Value] {
+			seen[v.Value] = true
+			unnamed = append(unnamed, v)
+		}
+	}
+	return append(named, unnamed...)
+}
+
+// unescapeScript undoes the escaping of slashes common in JSON and
+// JavaScript string literals
+func unescapeScript(s string) string {
+	return strings.ReplaceAll(s, `\/`, "/")
+}
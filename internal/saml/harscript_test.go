@@ -0,0 +1,86 @@
+package saml
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scriptEntryHAR returns a HAR whose only response body is body
+func scriptEntryHAR(t *testing.T, mimeType, body string) []byte {
+	t.Helper()
+
+	har := HAR{Log: HARLog{Entries: []HAREntry{{
+		Request:  HARRequest{Method: "POST", URL: "https://idp.example.com/api/login"},
+		Response: HARResponse{Content: HARContent{MimeType: mimeType, Text: body}},
+	}}}}
+	data, err := json.Marshal(har)
+	require.NoError(t, err)
+	return data
+}
+
+func TestHARExtractor_ScriptResponses(t *testing.T) {
+	response := `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_js1"><saml:Issuer xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion">https://idp.example.com</saml:Issuer></samlp:Response>`
+	encoded := base64.StdEncoding.EncodeToString([]byte(response))
+	escaped := strings.ReplaceAll(encoded, "/", `\/`)
+
+	tests := []struct {
+		name      string
+		mimeType  string
+		body      string
+		wantParam string
+	}{
+		{
+			name:      "JSON field",
+			mimeType:  "application/json",
+			body:      `{"status": "ok", "saml": {"SAMLResponse": "` + escaped + `", "action": "https://sp.example.com/acs"}}`,
+			wantParam: "SAMLResponse",
+		},
+		{
+			name:     "JSON field with another name",
+			mimeType: "application/json; charset=utf-8",
+			body:     `{"redirect": {"url": "https://sp.example.com/acs", "payload": "` + encoded + `"}}`,
+		},
+		{
+			name:      "JavaScript variable",
+			mimeType:  "application/javascript",
+			body:      `var RelayState = "x"; var SAMLResponse = '` + encoded + `'; document.forms[0].submit();`,
+			wantParam: "SAMLResponse",
+		},
+		{
+			name:      "JavaScript object literal",
+			mimeType:  "text/javascript",
+			body:      `postToSP({samlResponse: "` + escaped + `", target: "https://sp.example.com/acs"});`,
+			wantParam: "samlResponse",
+		},
+		{
+			name:     "JavaScript string",
+			mimeType: "text/javascript",
+			body:     `sso.post("https://sp.example.com/acs", "` + encoded + `");`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := NewHARExtractor().ExtractFromHAR(scriptEntryHAR(t, tt.mimeType, tt.body))
+			require.NoError(t, err)
+			require.Len(t, results, 1)
+			assert.Equal(t, "Response", results[0].Type)
+			assert.Equal(t, "response-body", results[0].Source)
+			assert.Equal(t, tt.wantParam, results[0].ParameterName)
+			assert.Contains(t, string(results[0].DecodedXML), `ID="_js1"`)
+		})
+	}
+}
+
+func TestHARExtractor_ScriptResponses_NoSAML(t *testing.T) {
+	body := `{"token": "` + strings.Repeat("QUJD", 40) + `", "user": "alice"}`
+
+	results, err := NewHARExtractor().ExtractFromHAR(scriptEntryHAR(t, "application/json", body))
+	require.NoError(t, err)
+	assert.Empty(t, results)
+}
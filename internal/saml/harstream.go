@@ -0,0 +1,369 @@
+package saml
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/gliwka/SAMLurai/internal/log"
+)
+
+// DefaultMaxBodySize caps the size of a single HAR request or response body.
+// SAML messages are far smaller; larger bodies are downloads or bundles.
+const DefaultMaxBodySize = 16 << 20
+
+// skippedMimeTypes are response content types that never carry SAML
+var skippedMimeTypes = []string{"image/", "font/", "audio/", "video/", "text/css", "application/wasm"}
+
+// WithMaxBodySize sets the size in bytes above which HAR bodies are skipped;
+// 0 disables the cap
+func (e *HARExtractor) WithMaxBodySize(n int) *HARExtractor {
+	e.maxBodySize = n
+	return e
+}
+
+// WithLimits sets the size above which HAR bodies are skipped and the
+// limits on the messages decoded from them
+func (e *HARExtractor) WithLimits(l Limits) *HARExtractor {
+	e.maxBodySize = l.MaxBodySize
+	e.decoder = NewDecoder().WithLimits(l)
+	return e
+}
+
+// ExtractReader extracts all SAML messages from a HAR file, SAML-tracer
+// export or Fiddler session archive read from r. HAR entries are decoded one
+// at a time, and JSON strings more than twice the body size cap are dropped
+// before they are decoded, so memory use is bounded by the cap rather than
+// by the largest entry or the whole capture. Session archives are zip files
+// and read whole.
+func (e *HARExtractor) ExtractReader(r io.Reader) ([]ExtractedSAML, error) {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(len(zipMagic)); LooksLikeSAZ(magic) {
+		data, err := io.ReadAll(br)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read SAZ archive: %w", err)
+		}
+		return e.ExtractFromSAZ(data)
+	}
+
+	var results []ExtractedSAML
+	index := 1
+
+	src := withContext(e.ctx, br)
+	if e.maxBodySize > 0 {
+		// Escaping and base64 make a body's string longer than the body;
+		// trimBodies applies the exact cap after decoding
+		src = newStringCapReader(src, 2*e.maxBodySize)
+	}
+	dec := json.NewDecoder(src)
+	err := walkObject(dec, func(key string) (bool, error) {
+		switch key {
+		case "log":
+			return true, walkObject(dec, func(key string) (bool, error) {
+				if key != "entries" {
+					return false, nil
+				}
+				extracted, err := e.extractConcurrently(func(emit func(HAREntry) error) error {
+					return walkArray(dec, emit)
+				}, &index)
+				results = append(results, extracted...)
+				return true, err
+			})
+		case "requests":
+			extracted, err := e.extractFromTracerRequests(func(emit func(SAMLTracerRequest) error) error {
+				return walkArray(dec, emit)
+			}, &index)
+			results = append(results, extracted...)
+			return true, err
+		}
+		return false, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse HAR file: %w", err)
+	}
+	return results, nil
+}
+
+// extractConcurrently extracts SAML from the entries produced by walk in a
+// pool of workers, one per CPU. Results keep the order of the entries and
+// are numbered from *index, after dropping redirects that were followed.
+func (e *HARExtractor) extractConcurrently(walk func(emit func(HAREntry) error) error, index *int) ([]ExtractedSAML, error) {
+	type job struct {
+		seq   int
+		entry HAREntry
+	}
+	type done struct {
+		seq     int
+		results []ExtractedSAML
+	}
+
+	workers := runtime.GOMAXPROCS(0)
+	jobs := make(chan job, workers)
+	out := make(chan done, workers)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				local := 1
+				out <- done{seq: j.seq, results: e.extractFromEntries([]HAREntry{j.entry}, &local)}
+			}
+		}()
+	}
+
+	// Collect while the entries are still being read, so workers never
+	// block on out
+	var byEntry [][]ExtractedSAML
+	collected := make(chan struct{})
+	go func() {
+		defer close(collected)
+		for d := range out {
+			for len(byEntry) <= d.seq {
+				byEntry = append(byEntry, nil)
+			}
+			byEntry[d.seq] = d.results
+		}
+	}()
+
+	seq := 0
+	err := walk(func(entry HAREntry) error {
+		if err := contextErr(e.ctx); err != nil {
+			return err
+		}
+		jobs <- job{seq: seq, entry: entry}
+		seq++
+		return nil
+	})
+	close(jobs)
+	wg.Wait()
+	close(out)
+	<-collected
+	if err != nil {
+		return nil, err
+	}
+
+	var results []ExtractedSAML
+	for _, entryResults := range byEntry {
+		results = append(results, entryResults...)
+	}
+	results = dropFollowedRedirects(results)
+	for i := range results {
+		results[i].Index = *index
+		*index++
+	}
+	return results, nil
+}
+
+// WalkHAR calls fn for each entry of the HAR file read from r, decoding one
+// entry at a time
+func WalkHAR(r io.Reader, fn func(HAREntry) error) error {
+	dec := json.NewDecoder(r)
+	return walkObject(dec, func(key string) (bool, error) {
+		if key != "log" {
+			return false, nil
+		}
+		return true, walkObject(dec, func(key string) (bool, error) {
+			if key != "entries" {
+				return false, nil
+			}
+			return true, walkArray(dec, fn)
+		})
+	})
+}
+
+// trimBodies drops the bodies of entry that cannot carry SAML: those over
+// the size cap and responses of media and style types
+func (e *HARExtractor) trimBodies(entry *HAREntry) {
+	if post := entry.Request.PostData; post != nil && e.maxBodySize > 0 && len(post.Text) > e.maxBodySize {
+		log.Warn("skipping HAR request body over the size limit", "url", entry.Request.URL, "limit", formatSize(e.maxBodySize))
+		post.Text = ""
+	}
+
+	content := &entry.Response.Content
+	if e.maxBodySize > 0 && content.size() > e.maxBodySize {
+		log.Warn("skipping HAR response body over the size limit", "url", entry.Request.URL, "limit", formatSize(e.maxBodySize))
+		content.Text = ""
+	}
+	mimeType := strings.ToLower(content.MimeType)
+	for _, skipped := range skippedMimeTypes {
+		if strings.Contains(mimeType, skipped) {
+			content.Text = ""
+			break
+		}
+	}
+}
+
+// stringCapReader passes JSON through, replacing strings longer than max
+// bytes with empty ones while reading, so a decoder never holds them whole
+type stringCapReader struct {
+	r   io.Reader
+	max int
+	buf []byte
+	out bytes.Buffer
+	err error
+
+	inString bool
+	escaped  bool
+	dropping bool
+	str      []byte
+}
+
+func newStringCapReader(r io.Reader, max int) *stringCapReader {
+	return &stringCapReader{r: r, max: max, buf: make([]byte, 32<<10)}
+}
+
+func (c *stringCapReader) Read(p []byte) (int, error) {
+	for c.out.Len() == 0 {
+		if c.err != nil {
+			return 0, c.err
+		}
+		n, err := c.r.Read(c.buf)
+		c.process(c.buf[:n])
+		c.err = err
+	}
+	return c.out.Read(p)
+}
+
+// process passes chunk through, holding back the string being read until
+// it ends or turns out to be too long
+func (c *stringCapReader) process(chunk []byte) {
+	for len(chunk) > 0 {
+		if !c.inString {
+			i := bytes.IndexByte(chunk, '"')
+			if i < 0 {
+				c.out.Write(chunk)
+				return
+			}
+			c.out.Write(chunk[:i])
+			chunk = chunk[i+1:]
+			c.inString, c.dropping, c.str = true, false, c.str[:0]
+			continue
+		}
+
+		i, end := 0, false
+		for !end && i < len(chunk) {
+			if c.escaped {
+				c.escaped = false
+				i++
+				continue
+			}
+			j := bytes.IndexAny(chunk[i:], `"\`)
+			if j < 0 {
+				i = len(chunk)
+				break
+			}
+			i += j
+			if chunk[i] == '\\' {
+				c.escaped = true
+				i++
+			} else {
+				end = true
+			}
+		}
+		c.appendString(chunk[:i])
+		if !end {
+			return
+		}
+		c.out.WriteByte('"')
+		c.out.Write(c.str)
+		c.out.WriteByte('"')
+		c.inString = false
+		chunk = chunk[i+1:]
+	}
+}
+
+func (c *stringCapReader) appendString(content []byte) {
+	if c.dropping {
+		return
+	}
+	if len(c.str)+len(content) > c.max {
+		log.Warn("skipping oversized HAR value while reading", "max", formatSize(c.max))
+		c.dropping, c.str = true, nil
+		return
+	}
+	c.str = append(c.str, content...)
+}
+
+// walkObject calls fn with each key of the JSON object at the decoder's
+// position. fn either consumes the value and returns true, or returns false
+// to have it skipped.
+func walkObject(dec *json.Decoder, fn func(key string) (bool, error)) error {
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key, _ := tok.(string)
+		handled, err := fn(key)
+		if err != nil {
+			return err
+		}
+		if !handled {
+			if err := skipValue(dec); err != nil {
+				return err
+			}
+		}
+	}
+	return expectDelim(dec, '}')
+}
+
+// walkArray decodes the elements of the JSON array at the decoder's
+// position one at a time and passes them to fn
+func walkArray[T any](dec *json.Decoder, fn func(T) error) error {
+	if err := expectDelim(dec, '['); err != nil {
+		return err
+	}
+	for dec.More() {
+		var element T
+		if err := dec.Decode(&element); err != nil {
+			return err
+		}
+		if err := fn(element); err != nil {
+			return err
+		}
+	}
+	return expectDelim(dec, ']')
+}
+
+// skipValue consumes the JSON value at the decoder's position token by
+// token, without buffering it as a whole
+func skipValue(dec *json.Decoder) error {
+	depth := 0
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
+
+// expectDelim consumes the next token, which must be delim
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok != delim {
+		return fmt.Errorf("expected %v, found %v", delim, tok)
+	}
+	return nil
+}
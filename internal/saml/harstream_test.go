@@ -0,0 +1,144 @@
+package saml
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// streamHAR builds a HAR with pages before the entries and a response body
+// carrying SAML with the given MIME type and padding
+func streamHAR(mimeType string, padding int) string {
+	response := `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_r1"/>`
+	form := `<form><input type="hidden" name="SAMLResponse" value="` + base64.StdEncoding.EncodeToString([]byte(response)) + `"/></form>`
+	request := base64.StdEncoding.EncodeToString([]byte(`<samlp:AuthnRequest xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_q1"/>`))
+
+	return fmt.Sprintf(`{"log": {
+		"version": "1.2",
+		"creator": {"name": "test", "nested": [1, {"a": [true, null]}]},
+		"pages": [{"id": "page_1", "title": "Login"}],
+		"entries": [
+			{"request": {"method": "GET", "url": "https://idp.example.com/sso?SAMLRequest=%s"}, "response": {"content": {}}},
+			{"request": {"method": "GET", "url": "https://idp.example.com/login"}, "response": {"content": {"mimeType": %q, "text": %q}}}
+		]
+	}, "trailer": "ignored"}`, request, mimeType, form+strings.Repeat(" ", padding))
+}
+
+func TestHARExtractor_ExtractReader(t *testing.T) {
+	results, err := NewHARExtractor().ExtractReader(strings.NewReader(streamHAR("text/html", 0)))
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "AuthnRequest", results[0].Type)
+	assert.Equal(t, 1, results[0].Index)
+	assert.Equal(t, "Response", results[1].Type)
+	assert.Equal(t, 2, results[1].Index)
+	assert.Equal(t, "response-body", results[1].Source)
+}
+
+func TestHARExtractor_ExtractReader_SkipsBodies(t *testing.T) {
+	t.Run("over the size cap", func(t *testing.T) {
+		results, err := NewHARExtractor().WithMaxBodySize(1024).ExtractReader(strings.NewReader(streamHAR("text/html", 2048)))
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, "AuthnRequest", results[0].Type)
+
+		results, err = NewHARExtractor().WithMaxBodySize(0).ExtractReader(strings.NewReader(streamHAR("text/html", 2048)))
+		require.NoError(t, err)
+		assert.Len(t, results, 2)
+	})
+
+	t.Run("far over the size cap", func(t *testing.T) {
+		// Dropped while reading, before the entry is decoded
+		results, err := NewHARExtractor().WithMaxBodySize(1024).ExtractReader(strings.NewReader(streamHAR("text/html", 1<<20)))
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, "AuthnRequest", results[0].Type)
+	})
+
+	t.Run("media type", func(t *testing.T) {
+		results, err := NewHARExtractor().ExtractReader(strings.NewReader(streamHAR("text/css", 0)))
+		require.NoError(t, err)
+		assert.Len(t, results, 1)
+	})
+}
+
+func TestStringCapReader(t *testing.T) {
+	input := `{"short": "a\\\"b", "long": "` + strings.Repeat("x", 100) + `\"", "n": [1, "ok"]}`
+	want := `{"short": "a\\\"b", "long": "", "n": [1, "ok"]}`
+
+	out, err := io.ReadAll(newStringCapReader(strings.NewReader(input), 10))
+	require.NoError(t, err)
+	assert.Equal(t, want, string(out))
+
+	// Strings and escapes split across reads
+	out, err = io.ReadAll(newStringCapReader(iotest.OneByteReader(strings.NewReader(input)), 10))
+	require.NoError(t, err)
+	assert.Equal(t, want, string(out))
+
+	var v map[string]any
+	require.NoError(t, json.Unmarshal(out, &v))
+	assert.Equal(t, `a\"b`, v["short"])
+}
+
+func TestHARExtractor_ExtractReader_SAMLTracer(t *testing.T) {
+	encoded := base64.StdEncoding.EncodeToString([]byte(`<samlp:AuthnRequest xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_req1"/>`))
+	export := `{"requests": [{"method": "GET", "url": "https://idp.example.com/sso?SAMLRequest=` + encoded + `"}]}`
+
+	results, err := NewHARExtractor().ExtractReader(strings.NewReader(export))
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "AuthnRequest", results[0].Type)
+}
+
+func TestHARExtractor_ExtractReader_Invalid(t *testing.T) {
+	for _, input := range []string{"not valid json", `{"log": {"entries": [{"request": `, `["log"]`} {
+		_, err := NewHARExtractor().ExtractReader(strings.NewReader(input))
+		require.Error(t, err, input)
+		assert.Contains(t, err.Error(), "failed to parse HAR file")
+	}
+}
+
+func TestWalkHAR(t *testing.T) {
+	var urls []string
+	err := WalkHAR(strings.NewReader(streamHAR("text/html", 0)), func(entry HAREntry) error {
+		urls = append(urls, entry.Request.URL)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, urls, 2)
+	assert.Equal(t, "https://idp.example.com/login", urls[1])
+
+	stop := fmt.Errorf("stop")
+	calls := 0
+	err = WalkHAR(strings.NewReader(streamHAR("text/html", 0)), func(HAREntry) error {
+		calls++
+		return stop
+	})
+	assert.ErrorIs(t, err, stop)
+	assert.Equal(t, 1, calls)
+}
+
+func TestHARExtractor_ExtractReader_KeepsEntryOrder(t *testing.T) {
+	var entries []string
+	for i := 0; i < 200; i++ {
+		xml := fmt.Sprintf(`<samlp:AuthnRequest xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_q%d"/>`, i)
+		entries = append(entries, fmt.Sprintf(`{"request": {"method": "GET", "url": "https://idp.example.com/sso/%d?SAMLRequest=%s"}, "response": {"content": {}}}`,
+			i, base64.StdEncoding.EncodeToString([]byte(xml))))
+	}
+	har := `{"log": {"entries": [` + strings.Join(entries, ",") + `]}}`
+
+	results, err := NewHARExtractor().ExtractReader(strings.NewReader(har))
+	require.NoError(t, err)
+	require.Len(t, results, 200)
+	for i, r := range results {
+		assert.Equal(t, i+1, r.Index)
+		assert.Equal(t, fmt.Sprintf("https://idp.example.com/sso/%d", i), strings.Split(r.URL, "?")[0])
+	}
+}
@@ -0,0 +1,128 @@
+package saml
+
+import (
+	"encoding/base64"
+	"math"
+	"strings"
+	"time"
+)
+
+// WebSocket frame opcodes, as recorded by Chrome
+const (
+	wsOpcodeText   = 1
+	wsOpcodeBinary = 2
+)
+
+// HARWebSocketMessage is a WebSocket frame recorded by Chrome in the
+// non-standard _webSocketMessages field of an entry
+type HARWebSocketMessage struct {
+	// Type is "send" for frames sent by the browser and "receive" otherwise
+	Type string `json:"type"`
+
+	// Time is when the frame was sent or received, in seconds since the epoch
+	Time float64 `json:"time"`
+
+	Opcode int `json:"opcode"`
+
+	// Data is the payload; base64 for binary frames
+	Data string `json:"data"`
+}
+
+// WithWebSockets makes the extractor also search the WebSocket frames of
+// HAR entries, for single-page apps that hand SAML messages over a socket
+func (e *HARExtractor) WithWebSockets(scan bool) *HARExtractor {
+	e.webSockets = scan
+	return e
+}
+
+// extractFromWebSocket extracts SAML from the frames of a WebSocket
+// connection: from SAML parameters in form-encoded, JSON or JavaScript
+// payloads, and from frames that are a message themselves. Each message is
+// timed by its frame rather than the entry, which spans the connection.
+func (e *HARExtractor) extractFromWebSocket(messages []HARWebSocketMessage, requestURL string, index *int) []ExtractedSAML {
+	var results []ExtractedSAML
+
+	for _, msg := range messages {
+		if e.maxBodySize > 0 && len(msg.Data) > e.maxBodySize {
+			continue
+		}
+		payload := msg.Data
+		if msg.Opcode == wsOpcodeBinary {
+			data, err := base64.StdEncoding.DecodeString(payload)
+			if err != nil {
+				continue
+			}
+			payload = string(data)
+		} else if msg.Opcode != wsOpcodeText {
+			continue
+		}
+
+		source := "websocket-receive"
+		if msg.Type == "send" {
+			source = "websocket-send"
+		}
+
+		frameStart := len(results)
+		found := make(map[string]bool)
+		for name, value := range e.formParameters(payload) {
+			found[value] = true
+			if extracted := e.tryExtractSAML(value, name, requestURL, source, index); extracted != nil {
+				results = append(results, *extracted)
+			}
+		}
+		for _, candidate := range e.extractSAMLFromScript(payload) {
+			if found[candidate.Value] {
+				continue
+			}
+			found[candidate.Value] = true
+			if extracted := e.tryExtractSAML(candidate.Value, candidate.Name, requestURL, source, index); extracted != nil {
+				results = append(results, *extracted)
+			}
+		}
+		if !found[payload] {
+			// A frame may be a message itself, in base64 or as plain XML
+			bare := payload
+			if trimmed := strings.TrimSpace(payload); e.looksLikeXML([]byte(trimmed)) {
+				bare = base64.StdEncoding.EncodeToString([]byte(trimmed))
+			}
+			if extracted := e.tryExtractSAML(bare, "", requestURL, source, index); extracted != nil {
+				results = append(results, *extracted)
+			}
+		}
+
+		sentAt := webSocketTime(msg.Time)
+		for i := frameStart; i < len(results); i++ {
+			results[i].StartedAt = sentAt
+		}
+	}
+
+	return results
+}
+
+// formParameters returns the SAML parameters of a form-encoded payload,
+// such as SAMLResponse=...&RelayState=.... Values are left escaped, as
+// '+' is a base64 character rather than an encoded space.
+func (e *HARExtractor) formParameters(payload string) map[string]string {
+	params := make(map[string]string)
+	if strings.ContainsAny(payload, "{[<") {
+		return params
+	}
+	for _, part := range strings.Split(strings.TrimSpace(payload), "&") {
+		name, value, ok := strings.Cut(part, "=")
+		if ok && e.isSAMLParameter(name) {
+			params[name] = value
+		}
+	}
+	return params
+}
+
+// webSocketTime converts a frame time in seconds since the epoch, returning
+// nil if it is missing
+func webSocketTime(seconds float64) *time.Time {
+	if seconds <= 0 {
+		return nil
+	}
+	sec, frac := math.Modf(seconds)
+	t := time.Unix(int64(sec), int64(frac*1e9)).UTC()
+	return &t
+}
@@ -0,0 +1,92 @@
+package saml
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func webSocketHAR(frames string) string {
+	return `{"log": {"entries": [{
+		"startedDateTime": "2024-01-15T10:00:00Z",
+		"request": {"method": "GET", "url": "wss://app.example.com/socket"},
+		"response": {"status": 101, "content": {"mimeType": "", "text": ""}},
+		"_webSocketMessages": [` + frames + `]
+	}]}}`
+}
+
+func TestHARExtractor_WebSocket(t *testing.T) {
+	response := `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_ws"/>`
+	request := `<samlp:AuthnRequest xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_wsreq"/>`
+	encoded := base64.StdEncoding.EncodeToString([]byte(response))
+
+	tests := []struct {
+		name      string
+		frame     string
+		wantType  string
+		wantParam string
+	}{
+		{
+			name:      "JSON payload",
+			frame:     `{"type": "receive", "time": 1705312800.5, "opcode": 1, "data": "{\"event\": \"login\", \"SAMLResponse\": \"` + encoded + `\"}"}`,
+			wantType:  "Response",
+			wantParam: "SAMLResponse",
+		},
+		{
+			name:      "form-encoded payload",
+			frame:     `{"type": "send", "time": 1705312800.5, "opcode": 1, "data": "SAMLRequest=` + base64.StdEncoding.EncodeToString([]byte(request)) + `&RelayState=abc"}`,
+			wantType:  "AuthnRequest",
+			wantParam: "SAMLRequest",
+		},
+		{
+			name:     "bare base64 message",
+			frame:    `{"type": "receive", "time": 1705312800.5, "opcode": 1, "data": "` + encoded + `"}`,
+			wantType: "Response",
+		},
+		{
+			name:     "XML payload",
+			frame:    `{"type": "receive", "time": 1705312800.5, "opcode": 1, "data": "` + strings.ReplaceAll(response, `"`, `\"`) + `"}`,
+			wantType: "Response",
+		},
+		{
+			name:     "binary frame",
+			frame:    `{"type": "receive", "time": 1705312800.5, "opcode": 2, "data": "` + base64.StdEncoding.EncodeToString([]byte(response)) + `"}`,
+			wantType: "Response",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			har := webSocketHAR(`{"type": "receive", "time": 1705312799, "opcode": 1, "data": "{\"event\": \"ping\"}"}, ` + tt.frame)
+
+			results, err := NewHARExtractor().WithWebSockets(true).ExtractReader(strings.NewReader(har))
+			require.NoError(t, err)
+			require.Len(t, results, 1)
+			assert.Equal(t, tt.wantType, results[0].Type)
+			assert.Equal(t, tt.wantParam, results[0].ParameterName)
+			assert.True(t, strings.HasPrefix(results[0].Source, "websocket-"))
+			assert.Equal(t, "wss://app.example.com/socket", results[0].URL)
+			require.NotNil(t, results[0].StartedAt)
+			assert.Equal(t, time.Date(2024, 1, 15, 10, 0, 0, 500000000, time.UTC), *results[0].StartedAt)
+
+			// Frames are only searched on request
+			results, err = NewHARExtractor().ExtractReader(strings.NewReader(har))
+			require.NoError(t, err)
+			assert.Empty(t, results)
+		})
+	}
+}
+
+func TestHARExtractor_WebSocketSource(t *testing.T) {
+	request := `<samlp:AuthnRequest xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_wsreq"/>`
+	har := webSocketHAR(`{"type": "send", "time": 1705312800, "opcode": 1, "data": "SAMLRequest=` + base64.StdEncoding.EncodeToString([]byte(request)) + `"}`)
+
+	results, err := NewHARExtractor().WithWebSockets(true).ExtractReader(strings.NewReader(har))
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "websocket-send", results[0].Source)
+}
@@ -0,0 +1,82 @@
+package saml
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/beevik/etree"
+	"github.com/crewjam/saml/xmlenc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// opaqueKey hides the private key behind crypto.Decrypter, like an HSM
+type opaqueKey struct {
+	key   *rsa.PrivateKey
+	calls int
+}
+
+func (k *opaqueKey) Public() crypto.PublicKey { return &k.key.PublicKey }
+
+func (k *opaqueKey) Decrypt(rand io.Reader, msg []byte, opts crypto.DecrypterOpts) ([]byte, error) {
+	k.calls++
+	return k.key.Decrypt(rand, msg, opts)
+}
+
+// encryptedAssertion encrypts an assertion for key with the given key
+// transport
+func encryptedAssertion(t *testing.T, key *rsa.PrivateKey, transport xmlenc.RSA) []byte {
+	t.Helper()
+
+	assertion := `<saml:Assertion xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_a1"><saml:Issuer>https://idp.example.com</saml:Issuer></saml:Assertion>`
+	encryptedData, err := transport.Encrypt(selfSignedCert(t, key), []byte(assertion), nil)
+	require.NoError(t, err)
+
+	doc := etree.NewDocument()
+	wrapper := doc.CreateElement("saml:EncryptedAssertion")
+	wrapper.CreateAttr("xmlns:saml", "urn:oasis:names:tc:SAML:2.0:assertion")
+	wrapper.AddChild(encryptedData)
+	out, err := doc.WriteToBytes()
+	require.NoError(t, err)
+	return out
+}
+
+func TestNewDecryptorWithKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	for name, transport := range map[string]xmlenc.RSA{
+		"rsa-oaep-mgf1p": xmlenc.OAEP(),
+		"rsa-1_5":        xmlenc.PKCS1v15(),
+	} {
+		t.Run(name, func(t *testing.T) {
+			encrypted := encryptedAssertion(t, key, transport)
+			hsm := &opaqueKey{key: key}
+
+			decrypted, err := NewDecryptorWithKey(hsm).Decrypt(encrypted)
+			require.NoError(t, err)
+			assert.Contains(t, string(decrypted), `ID="_a1"`)
+			assert.Equal(t, 1, hsm.calls)
+		})
+	}
+}
+
+func TestNewDecryptorWithKey_KeyError(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	_, err = NewDecryptorWithKey(failingKey{}).Decrypt(encryptedAssertion(t, key, xmlenc.OAEP()))
+	assert.EqualError(t, err, "decryption failed: failed to decrypt session key: token removed")
+}
+
+type failingKey struct{}
+
+func (failingKey) Public() crypto.PublicKey { return nil }
+
+func (failingKey) Decrypt(io.Reader, []byte, crypto.DecrypterOpts) ([]byte, error) {
+	return nil, errors.New("token removed")
+}
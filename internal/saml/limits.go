@@ -0,0 +1,75 @@
+package saml
+
+import (
+	"bytes"
+	"io"
+)
+
+// Defaults of Limits. Decoded messages may be as large as HAR bodies,
+// which are base64-decoded too.
+const (
+	DefaultMaxDecodedSize    = DefaultMaxBodySize
+	DefaultMaxInflationRatio = 100
+)
+
+// inflationRatioFloor is the inflated size below which the inflation
+// ratio is not checked, so small, repetitive messages always inflate
+const inflationRatioFloor = 1 << 20
+
+// Limits bound the memory a single input can make the Decoder and the
+// HARExtractor allocate, protecting server modes from malicious payloads
+// such as deflate bombs. A zero limit is disabled.
+type Limits struct {
+	// MaxDecodedSize is the largest base64-decoded or inflated message, in
+	// bytes
+	MaxDecodedSize int
+
+	// MaxInflationRatio is the largest ratio of inflated to compressed
+	// size, for messages inflating to more than 1 MiB
+	MaxInflationRatio int
+
+	// MaxBodySize is the size of HAR request and response bodies above
+	// which they are skipped, in bytes
+	MaxBodySize int
+}
+
+// DefaultLimits returns the limits decoders and extractors start with
+func DefaultLimits() Limits {
+	return Limits{
+		MaxDecodedSize:    DefaultMaxDecodedSize,
+		MaxInflationRatio: DefaultMaxInflationRatio,
+		MaxBodySize:       DefaultMaxBodySize,
+	}
+}
+
+// inflateLimit returns the most data may inflate to, and the error for
+// inflating to more; 0 if there is no limit
+func (l Limits) inflateLimit(compressed int) (int, error) {
+	limit := l.MaxDecodedSize
+	tooLarge := &ErrTooLarge{What: "inflated message", MaxSize: l.MaxDecodedSize}
+	if l.MaxInflationRatio > 0 {
+		byRatio := max(compressed*l.MaxInflationRatio, inflationRatioFloor)
+		if limit == 0 || byRatio < limit {
+			limit = byRatio
+			tooLarge = &ErrTooLarge{What: "inflated message", MaxRatio: l.MaxInflationRatio}
+		}
+	}
+	return limit, tooLarge
+}
+
+// readLimited reads all of r, failing with tooLarge once more than limit
+// bytes were read; a limit of 0 reads everything
+func readLimited(r io.Reader, limit int, tooLarge error) ([]byte, error) {
+	if limit <= 0 {
+		return io.ReadAll(r)
+	}
+	var buf bytes.Buffer
+	n, err := io.Copy(&buf, io.LimitReader(r, int64(limit)+1))
+	if err != nil {
+		return nil, err
+	}
+	if n > int64(limit) {
+		return nil, tooLarge
+	}
+	return buf.Bytes(), nil
+}
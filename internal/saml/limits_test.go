@@ -0,0 +1,77 @@
+package saml
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// deflateBomb returns n zero bytes, deflated and base64-encoded
+func deflateBomb(t *testing.T, n int) string {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, flate.BestCompression)
+	require.NoError(t, err)
+	chunk := make([]byte, 1<<20)
+	for ; n > 0; n -= len(chunk) {
+		_, err = w.Write(chunk[:min(n, len(chunk))])
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func TestDecoder_DeflateBomb(t *testing.T) {
+	bomb := deflateBomb(t, 64<<20)
+
+	_, err := NewDecoder().DecodeDeflate(bomb)
+	assert.ErrorIs(t, err, ErrDeflate)
+	var tooLarge *ErrTooLarge
+	require.ErrorAs(t, err, &tooLarge)
+	assert.Equal(t, DefaultMaxInflationRatio, tooLarge.MaxRatio)
+	assert.EqualError(t, err, "deflate decompression failed: inflated message is more than 100 times the size of its compressed data, likely a decompression bomb")
+
+	// SmartDecode reports the bomb rather than keeping the compressed bytes
+	_, err = NewDecoder().SmartDecode(bomb)
+	assert.ErrorAs(t, err, &tooLarge)
+}
+
+func TestDecoder_MaxDecodedSize(t *testing.T) {
+	bomb := deflateBomb(t, 2<<20)
+
+	_, err := NewDecoder().WithLimits(Limits{MaxDecodedSize: 1 << 20}).DecodeDeflate(bomb)
+	assert.EqualError(t, err, "deflate decompression failed: inflated message exceeds the limit of 1 MiB")
+
+	encoded := NewDecoder().Encode(bytes.Repeat([]byte("<a/>"), 100))
+	_, err = NewDecoder().WithLimits(Limits{MaxDecodedSize: 100}).Decode(encoded)
+	assert.ErrorIs(t, err, ErrBase64)
+	assert.EqualError(t, err, "base64 decode failed: decoded message exceeds the limit of 100 bytes")
+
+	// Zero limits are disabled
+	inflated, err := NewDecoder().WithLimits(Limits{}).DecodeDeflate(bomb)
+	require.NoError(t, err)
+	assert.Len(t, inflated, 2<<20)
+}
+
+func TestHARExtractor_GzipBombBody(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	_, err := gz.Write(make([]byte, 4<<20))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+
+	content := HARContent{Text: base64.StdEncoding.EncodeToString(buf.Bytes()), Encoding: "base64"}
+	assert.Empty(t, content.body(1<<20), "the body decompressing past the limit is skipped")
+	assert.Len(t, content.body(0), 4<<20)
+}
+
+func TestFormatSize(t *testing.T) {
+	assert.Equal(t, "16 MiB", formatSize(16<<20))
+	assert.Equal(t, "64 KiB", formatSize(64<<10))
+	assert.Equal(t, "1000 bytes", formatSize(1000))
+}
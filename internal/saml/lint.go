@@ -0,0 +1,519 @@
+package saml
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/beevik/etree"
+)
+
+// Conformance rules checked by Lint
+const (
+	RuleVersion             = "version"
+	RuleID                  = "id"
+	RuleIDLength            = "id-length"
+	RuleIssueInstant        = "issue-instant"
+	RuleUTCTime             = "utc-time"
+	RuleIssuer              = "issuer"
+	RuleIssuerFormat        = "issuer-format"
+	RuleStatus              = "status"
+	RuleSignedDestination   = "signed-destination"
+	RuleACSExclusive        = "acs-exclusive"
+	RuleSubject             = "subject"
+	RuleAuthnStatement      = "authn-statement"
+	RuleBearerConfirmation  = "bearer-confirmation"
+	RuleBearerRecipient     = "bearer-recipient"
+	RuleBearerNotOnOrAfter  = "bearer-not-on-or-after"
+	RuleBearerNotBefore     = "bearer-not-before"
+	RuleBearerInResponseTo  = "bearer-in-response-to"
+	RuleAudienceRestriction = "audience-restriction"
+	RuleConditionsWindow    = "conditions-window"
+)
+
+// lintDisableDirective starts an XML comment disabling rules
+const lintDisableDirective = "samlurai-lint-disable"
+
+// minIDLength is the shortest ID not reported by the id-length rule
+const minIDLength = 16
+
+// nameIDFormatEntity is the only Format an Issuer may have in Web Browser SSO
+const nameIDFormatEntity = "urn:oasis:names:tc:SAML:2.0:nameid-format:entity"
+
+// LintRule is a conformance rule derived from the SAML 2.0 specifications
+type LintRule struct {
+	ID string `json:"id"`
+
+	// Severity is the severity of a violation; a few rules report lesser
+	// forms of the violation as warnings
+	Severity string `json:"severity"`
+
+	// Spec is the section of the specification the rule is derived from
+	Spec        string `json:"spec"`
+	Description string `json:"description"`
+}
+
+// LintRules lists the rules checked by Lint
+var LintRules = []LintRule{
+	{RuleVersion, SeverityError, "SAML Core 2.3.3, 3.2.1, 3.2.2", "Assertions and protocol messages have Version=\"2.0\""},
+	{RuleID, SeverityError, "SAML Core 1.3.4", "Assertions and protocol messages have an ID that is a valid xs:ID (NCName)"},
+	{RuleIDLength, SeverityWarning, "SAML Core 1.3.4", "IDs are long enough to carry 128 bits of randomness"},
+	{RuleIssueInstant, SeverityError, "SAML Core 2.3.3, 3.2.1, 3.2.2", "Assertions and protocol messages have an IssueInstant"},
+	{RuleUTCTime, SeverityError, "SAML Core 1.3.3", "Times are valid xs:dateTime values in UTC, ending in Z"},
+	{RuleIssuer, SeverityError, "SAML Core 2.3.3, Profiles 4.1.4.1, 4.1.4.2", "Assertions, AuthnRequests and signed Responses have an Issuer"},
+	{RuleIssuerFormat, SeverityError, "SAML Profiles 4.1.4.1, 4.1.4.2", "The Issuer of AuthnRequests, Responses and assertions has the entity format, if any"},
+	{RuleStatus, SeverityError, "SAML Core 3.2.2, 3.2.2.2", "Responses have a Status whose top-level StatusCode is a top-level status code"},
+	{RuleSignedDestination, SeverityError, "SAML Bindings 3.4.5.2, 3.5.5.2", "Signed protocol messages have a Destination"},
+	{RuleACSExclusive, SeverityError, "SAML Core 3.4.1", "AuthnRequests do not combine AssertionConsumerServiceIndex with AssertionConsumerServiceURL or ProtocolBinding"},
+	{RuleSubject, SeverityError, "SAML Core 2.7.2", "Assertions with an AuthnStatement have a Subject"},
+	{RuleAuthnStatement, SeverityError, "SAML Profiles 4.1.4.2", "Successful Responses hold an assertion with an AuthnStatement"},
+	{RuleBearerConfirmation, SeverityError, "SAML Profiles 4.1.4.2", "Assertions with an AuthnStatement have a bearer SubjectConfirmation"},
+	{RuleBearerRecipient, SeverityError, "SAML Profiles 4.1.4.2", "Bearer SubjectConfirmationData has a Recipient"},
+	{RuleBearerNotOnOrAfter, SeverityError, "SAML Profiles 4.1.4.2", "Bearer SubjectConfirmationData has a NotOnOrAfter"},
+	{RuleBearerNotBefore, SeverityError, "SAML Profiles 4.1.4.2", "Bearer SubjectConfirmationData has no NotBefore"},
+	{RuleBearerInResponseTo, SeverityError, "SAML Profiles 4.1.4.2", "Bearer SubjectConfirmationData has the InResponseTo of the Response"},
+	{RuleAudienceRestriction, SeverityError, "SAML Profiles 4.1.4.2", "Bearer assertions have an AudienceRestriction"},
+	{RuleConditionsWindow, SeverityError, "SAML Core 2.5.1", "NotBefore is earlier than NotOnOrAfter"},
+}
+
+// LookupLintRule returns the rule with the given ID
+func LookupLintRule(id string) (LintRule, bool) {
+	for _, rule := range LintRules {
+		if rule.ID == id {
+			return rule, true
+		}
+	}
+	return LintRule{}, false
+}
+
+// LintIssue is a violation of a conformance rule
+type LintIssue struct {
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	Spec     string `json:"spec"`
+	Message  string `json:"message"`
+}
+
+// LintOptions configures Lint
+type LintOptions struct {
+	// Disabled are the IDs of rules not to check
+	Disabled []string
+}
+
+// protocolMessages are the local names of the SAML 2.0 requests and
+// responses, which carry ID, Version and IssueInstant
+var protocolMessages = map[string]bool{
+	"AuthnRequest":          true,
+	"Response":              true,
+	"LogoutRequest":         true,
+	"LogoutResponse":        true,
+	"ArtifactResolve":       true,
+	"ArtifactResponse":      true,
+	"AttributeQuery":        true,
+	"AuthnQuery":            true,
+	"AuthzDecisionQuery":    true,
+	"AssertionIDRequest":    true,
+	"ManageNameIDRequest":   true,
+	"ManageNameIDResponse":  true,
+	"NameIDMappingRequest":  true,
+	"NameIDMappingResponse": true,
+}
+
+// topLevelStatusCodes are the status codes permitted as the top-level
+// StatusCode of a response
+var topLevelStatusCodes = map[string]bool{
+	"Success":         true,
+	"Requester":       true,
+	"Responder":       true,
+	"VersionMismatch": true,
+}
+
+// linter collects the issues of a single document
+type linter struct {
+	disabled map[string]bool
+	issues   []LintIssue
+}
+
+func (l *linter) add(rule string, el *etree.Element, format string, args ...interface{}) {
+	l.addWithSeverity(rule, "", el, format, args...)
+}
+
+// addWithSeverity records an issue with a severity other than the rule's;
+// an empty severity is the rule's
+func (l *linter) addWithSeverity(rule, severity string, el *etree.Element, format string, args ...interface{}) {
+	if l.disabled[rule] || suppressed(rule, el) {
+		return
+	}
+	r, _ := LookupLintRule(rule)
+	if severity == "" {
+		severity = r.Severity
+	}
+	l.issues = append(l.issues, LintIssue{Rule: rule, Severity: severity, Spec: r.Spec, Message: fmt.Sprintf(format, args...)})
+}
+
+// Lint checks a SAML 2.0 document against rules derived from SAML Core,
+// Bindings and the Web Browser SSO profile, such as the Version, ID and
+// IssueInstant of messages and assertions, Issuers and the bearer
+// SubjectConfirmation and AudienceRestriction of assertions.
+//
+// Rules are disabled with opts.Disabled, or within the document by an XML
+// comment such as <!-- samlurai-lint-disable issuer version -->, which
+// applies to the element it is in and that element's descendants; before
+// the root element, it applies to the whole document. Without rule IDs it
+// disables all rules.
+func Lint(xmlData []byte, opts LintOptions) ([]LintIssue, error) {
+	doc, err := ReadDocument(xmlData)
+	if err != nil {
+		return nil, &ErrParse{Stage: "XML", Err: err}
+	}
+	root := doc.Root()
+	if root == nil {
+		return nil, &ErrParse{Stage: "XML", Err: errors.New("no root element")}
+	}
+
+	l := &linter{disabled: map[string]bool{}}
+	for _, rule := range opts.Disabled {
+		l.disabled[rule] = true
+	}
+	for _, token := range doc.Child {
+		if c, ok := token.(*etree.Comment); ok {
+			if rules, ok := disableDirective(c.Data); ok {
+				if len(rules) == 0 {
+					return nil, nil
+				}
+				for _, rule := range rules {
+					l.disabled[rule] = true
+				}
+			}
+		}
+	}
+
+	walkElements(root, func(el *etree.Element) {
+		switch {
+		case el.NamespaceURI() == SAMLPNamespace && protocolMessages[el.Tag]:
+			l.checkCommon(el)
+			l.checkMessage(el)
+		case isElement(el, SAMLNamespace, "Assertion"):
+			l.checkCommon(el)
+			l.checkAssertion(el)
+		case isElement(el, SAMLNamespace, "Conditions"):
+			l.checkWindow(el)
+		}
+		l.checkTimes(el)
+	})
+	return l.issues, nil
+}
+
+// checkCommon checks the Version, ID and IssueInstant shared by assertions
+// and protocol messages
+func (l *linter) checkCommon(el *etree.Element) {
+	desc := describeElement(el)
+	switch version := el.SelectAttr("Version"); {
+	case version == nil:
+		l.add(RuleVersion, el, "%s has no Version attribute", desc)
+	case version.Value != "2.0":
+		l.add(RuleVersion, el, "%s has Version %q, not \"2.0\"", desc, version.Value)
+	}
+
+	switch id := el.SelectAttr("ID"); {
+	case id == nil || id.Value == "":
+		l.add(RuleID, el, "%s has no ID attribute", desc)
+	case !isNCName(id.Value):
+		l.add(RuleID, el, "%s has an ID that is not a valid xs:ID: it must start with a letter or underscore and contain no colons or spaces", desc)
+	case len(id.Value) < minIDLength:
+		l.add(RuleIDLength, el, "%s has an ID of %d characters, too short to carry the 128 bits of randomness that make IDs unique", desc, len(id.Value))
+	}
+
+	if el.SelectAttr("IssueInstant") == nil {
+		l.add(RuleIssueInstant, el, "%s has no IssueInstant attribute", desc)
+	}
+}
+
+// checkMessage checks the rules specific to protocol messages
+func (l *linter) checkMessage(el *etree.Element) {
+	desc := describeElement(el)
+	issuer := childElement(el, SAMLNamespace, "Issuer")
+	signed := hasSignature(el)
+
+	if signed && el.SelectAttrValue("Destination", "") == "" {
+		l.add(RuleSignedDestination, el, "%s is signed but has no Destination, which lets it be redirected to another endpoint", desc)
+	}
+
+	switch el.Tag {
+	case "AuthnRequest":
+		if issuer == nil {
+			l.add(RuleIssuer, el, "%s has no Issuer", desc)
+		}
+		l.checkIssuerFormat(issuer)
+		if el.SelectAttr("AssertionConsumerServiceIndex") != nil &&
+			(el.SelectAttr("AssertionConsumerServiceURL") != nil || el.SelectAttr("ProtocolBinding") != nil) {
+			l.add(RuleACSExclusive, el, "%s has an AssertionConsumerServiceIndex as well as an AssertionConsumerServiceURL or ProtocolBinding", desc)
+		}
+
+	case "Response":
+		encrypted := el.FindElement(".//EncryptedAssertion") != nil
+		switch {
+		case issuer == nil && (signed || encrypted):
+			l.add(RuleIssuer, el, "%s is signed or holds an encrypted assertion but has no Issuer", desc)
+		case issuer == nil:
+			l.addWithSeverity(RuleIssuer, SeverityWarning, el, "%s has no Issuer, so the SP cannot tell the IdP from the Response alone", desc)
+		}
+		l.checkIssuerFormat(issuer)
+		l.checkAuthnStatement(el, encrypted)
+	}
+
+	if strings.HasSuffix(el.Tag, "Response") {
+		l.checkStatus(el)
+	}
+}
+
+// checkIssuerFormat reports an Issuer with a Format other than entity
+func (l *linter) checkIssuerFormat(issuer *etree.Element) {
+	if issuer == nil {
+		return
+	}
+	if format := issuer.SelectAttrValue("Format", nameIDFormatEntity); format != nameIDFormatEntity {
+		l.add(RuleIssuerFormat, issuer, "Issuer %q has Format %q; it must be omitted or %s", strings.TrimSpace(issuer.Text()), format, nameIDFormatEntity)
+	}
+}
+
+// checkStatus reports a response without a valid top-level StatusCode
+func (l *linter) checkStatus(el *etree.Element) {
+	desc := describeElement(el)
+	status := childElement(el, SAMLPNamespace, "Status")
+	if status == nil {
+		l.add(RuleStatus, el, "%s has no Status", desc)
+		return
+	}
+	code := childElement(status, SAMLPNamespace, "StatusCode")
+	if code == nil {
+		l.add(RuleStatus, el, "the Status of %s has no StatusCode", desc)
+		return
+	}
+	value := code.SelectAttrValue("Value", "")
+	if !strings.HasPrefix(value, statusPrefix) || !topLevelStatusCodes[strings.TrimPrefix(value, statusPrefix)] {
+		l.add(RuleStatus, el, "the top-level StatusCode of %s is %q, not Success, Requester, Responder or VersionMismatch; second-level codes belong in a nested StatusCode", desc, value)
+	}
+}
+
+// checkAuthnStatement reports a successful Response whose assertions hold
+// no AuthnStatement. Encrypted assertions cannot be checked.
+func (l *linter) checkAuthnStatement(response *etree.Element, encrypted bool) {
+	status := response.FindElement("./Status/StatusCode")
+	if status == nil || status.SelectAttrValue("Value", "") != StatusSuccess || encrypted {
+		return
+	}
+	assertions := childElements(response, SAMLNamespace, "Assertion")
+	if len(assertions) == 0 {
+		return
+	}
+	for _, assertion := range assertions {
+		if childElement(assertion, SAMLNamespace, "AuthnStatement") != nil {
+			return
+		}
+	}
+	l.add(RuleAuthnStatement, response, "%s is successful but none of its assertions has an AuthnStatement", describeElement(response))
+}
+
+// checkAssertion checks the Issuer, Subject and bearer confirmation of an
+// assertion
+func (l *linter) checkAssertion(assertion *etree.Element) {
+	desc := describeElement(assertion)
+	issuer := childElement(assertion, SAMLNamespace, "Issuer")
+	if issuer == nil {
+		l.add(RuleIssuer, assertion, "%s has no Issuer", desc)
+	}
+	l.checkIssuerFormat(issuer)
+
+	authn := childElement(assertion, SAMLNamespace, "AuthnStatement") != nil
+	subject := childElement(assertion, SAMLNamespace, "Subject")
+	if subject == nil {
+		if authn {
+			l.add(RuleSubject, assertion, "%s has an AuthnStatement but no Subject", desc)
+		}
+		return
+	}
+
+	var bearer []*etree.Element
+	for _, confirmation := range childElements(subject, SAMLNamespace, "SubjectConfirmation") {
+		if confirmation.SelectAttrValue("Method", "") == SubjectConfirmationBearer {
+			bearer = append(bearer, confirmation)
+		}
+	}
+	if len(bearer) == 0 {
+		if authn {
+			l.add(RuleBearerConfirmation, assertion, "%s has an AuthnStatement but no bearer SubjectConfirmation", desc)
+		}
+		return
+	}
+
+	for _, confirmation := range bearer {
+		l.checkBearer(assertion, confirmation)
+	}
+
+	conditions := childElement(assertion, SAMLNamespace, "Conditions")
+	if conditions == nil || childElement(conditions, SAMLNamespace, "AudienceRestriction") == nil {
+		l.add(RuleAudienceRestriction, assertion, "%s is a bearer assertion without an AudienceRestriction", desc)
+	}
+}
+
+// checkBearer checks the SubjectConfirmationData of a bearer
+// SubjectConfirmation
+func (l *linter) checkBearer(assertion, confirmation *etree.Element) {
+	desc := describeElement(assertion)
+	data := childElement(confirmation, SAMLNamespace, "SubjectConfirmationData")
+	if data == nil {
+		l.add(RuleBearerRecipient, confirmation, "the bearer SubjectConfirmation of %s has no SubjectConfirmationData with a Recipient", desc)
+		l.add(RuleBearerNotOnOrAfter, confirmation, "the bearer SubjectConfirmation of %s has no SubjectConfirmationData with a NotOnOrAfter", desc)
+		return
+	}
+	if data.SelectAttrValue("Recipient", "") == "" {
+		l.add(RuleBearerRecipient, data, "the bearer SubjectConfirmationData of %s has no Recipient", desc)
+	}
+	if data.SelectAttr("NotOnOrAfter") == nil {
+		l.add(RuleBearerNotOnOrAfter, data, "the bearer SubjectConfirmationData of %s has no NotOnOrAfter", desc)
+	}
+	if data.SelectAttr("NotBefore") != nil {
+		l.add(RuleBearerNotBefore, data, "the bearer SubjectConfirmationData of %s has a NotBefore", desc)
+	}
+
+	response := assertion.Parent()
+	if response == nil || !isElement(response, SAMLPNamespace, "Response") {
+		return
+	}
+	expected := response.SelectAttrValue("InResponseTo", "")
+	if actual := data.SelectAttrValue("InResponseTo", ""); actual != expected {
+		switch {
+		case expected == "":
+			l.add(RuleBearerInResponseTo, data, "the bearer SubjectConfirmationData of %s has InResponseTo %q, but the unsolicited Response has none", desc, actual)
+		case actual == "":
+			l.add(RuleBearerInResponseTo, data, "the bearer SubjectConfirmationData of %s has no InResponseTo, but the Response answers %q", desc, expected)
+		default:
+			l.add(RuleBearerInResponseTo, data, "the bearer SubjectConfirmationData of %s has InResponseTo %q, but the Response answers %q", desc, actual, expected)
+		}
+	}
+}
+
+// checkWindow reports Conditions whose NotBefore is not earlier than their
+// NotOnOrAfter
+func (l *linter) checkWindow(conditions *etree.Element) {
+	notBefore, err1 := time.Parse(time.RFC3339Nano, conditions.SelectAttrValue("NotBefore", ""))
+	notOnOrAfter, err2 := time.Parse(time.RFC3339Nano, conditions.SelectAttrValue("NotOnOrAfter", ""))
+	if err1 != nil || err2 != nil || notBefore.Before(notOnOrAfter) {
+		return
+	}
+	l.add(RuleConditionsWindow, conditions, "Conditions NotBefore %s is not earlier than NotOnOrAfter %s, so the assertion is never valid",
+		conditions.SelectAttrValue("NotBefore", ""), conditions.SelectAttrValue("NotOnOrAfter", ""))
+}
+
+// checkTimes reports xs:dateTime attributes of SAML elements that are
+// invalid or not in UTC
+func (l *linter) checkTimes(el *etree.Element) {
+	if ns := el.NamespaceURI(); ns != SAMLNamespace && ns != SAMLPNamespace {
+		return
+	}
+	for _, attr := range el.Attr {
+		if attr.Space != "" || !dateTimeAttributes[attr.Key] {
+			continue
+		}
+		switch t, err := time.Parse(time.RFC3339Nano, attr.Value); {
+		case err != nil && !hasTimeZone(attr.Value):
+			if _, err := time.Parse("2006-01-02T15:04:05.999999999", attr.Value); err == nil {
+				l.add(RuleUTCTime, el, "%s %q on %s has no time zone; SAML times are in UTC and end in Z", attr.Key, attr.Value, describeElement(el))
+				continue
+			}
+			l.add(RuleUTCTime, el, "%s %q on %s is not a valid xs:dateTime", attr.Key, attr.Value, describeElement(el))
+		case err != nil:
+			l.add(RuleUTCTime, el, "%s %q on %s is not a valid xs:dateTime", attr.Key, attr.Value, describeElement(el))
+		case !strings.HasSuffix(attr.Value, "Z"):
+			l.add(RuleUTCTime, el, "%s %q on %s is not in UTC: use %s", attr.Key, attr.Value, describeElement(el), t.UTC().Format(time.RFC3339Nano))
+		}
+	}
+}
+
+// hasTimeZone reports whether an xs:dateTime value ends in Z or an offset
+func hasTimeZone(value string) bool {
+	if strings.HasSuffix(value, "Z") {
+		return true
+	}
+	i := strings.IndexByte(value, 'T')
+	return i >= 0 && strings.ContainsAny(value[i:], "+-")
+}
+
+// isNCName reports whether s is a valid XML NCName, the type of xs:ID
+func isNCName(s string) bool {
+	for i, r := range s {
+		switch {
+		case r == '_' || unicode.IsLetter(r):
+		case i > 0 && (r == '-' || r == '.' || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Mc, r)):
+		default:
+			return false
+		}
+	}
+	return s != ""
+}
+
+// suppressed reports whether a samlurai-lint-disable comment in el or one
+// of its ancestors disables rule
+func suppressed(rule string, el *etree.Element) bool {
+	for ; el != nil; el = el.Parent() {
+		for _, token := range el.Child {
+			c, ok := token.(*etree.Comment)
+			if !ok {
+				continue
+			}
+			rules, ok := disableDirective(c.Data)
+			if !ok {
+				continue
+			}
+			if len(rules) == 0 {
+				return true
+			}
+			for _, r := range rules {
+				if r == rule {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// disableDirective parses a samlurai-lint-disable comment into the rules it
+// disables, none meaning all
+func disableDirective(comment string) ([]string, bool) {
+	fields := strings.Fields(comment)
+	if len(fields) == 0 || fields[0] != lintDisableDirective {
+		return nil, false
+	}
+	var rules []string
+	for _, field := range fields[1:] {
+		rules = append(rules, strings.Split(strings.Trim(field, ","), ",")...)
+	}
+	return rules, true
+}
+
+// childElement returns the first child of el with the given namespace and
+// local name
+func childElement(el *etree.Element, namespace, local string) *etree.Element {
+	for _, child := range el.ChildElements() {
+		if isElement(child, namespace, local) {
+			return child
+		}
+	}
+	return nil
+}
+
+// childElements returns the children of el with the given namespace and
+// local name
+func childElements(el *etree.Element, namespace, local string) []*etree.Element {
+	var children []*etree.Element
+	for _, child := range el.ChildElements() {
+		if isElement(child, namespace, local) {
+			children = append(children, child)
+		}
+	}
+	return children
+}
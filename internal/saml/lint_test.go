@@ -0,0 +1,268 @@
+package saml
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// conformantResponse passes all lint rules
+const conformantResponse = `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion"
+    ID="_response0123456789" Version="2.0" IssueInstant="2024-01-15T10:00:00Z" InResponseTo="_request0123456789"
+    Destination="https://sp.example.com/acs">
+  <saml:Issuer>https://idp.example.com</saml:Issuer>
+  <samlp:Status><samlp:StatusCode Value="urn:oasis:names:tc:SAML:2.0:status:Success"/></samlp:Status>
+  <saml:Assertion ID="_assertion0123456789" Version="2.0" IssueInstant="2024-01-15T10:00:00Z">
+    <saml:Issuer>https://idp.example.com</saml:Issuer>
+    <saml:Subject>
+      <saml:NameID>user@example.com</saml:NameID>
+      <saml:SubjectConfirmation Method="urn:oasis:names:tc:SAML:2.0:cm:bearer">
+        <saml:SubjectConfirmationData Recipient="https://sp.example.com/acs" NotOnOrAfter="2024-01-15T10:05:00Z" InResponseTo="_request0123456789"/>
+      </saml:SubjectConfirmation>
+    </saml:Subject>
+    <saml:Conditions NotBefore="2024-01-15T09:59:00Z" NotOnOrAfter="2024-01-15T10:05:00Z">
+      <saml:AudienceRestriction><saml:Audience>https://sp.example.com</saml:Audience></saml:AudienceRestriction>
+    </saml:Conditions>
+    <saml:AuthnStatement AuthnInstant="2024-01-15T10:00:00Z"/>
+  </saml:Assertion>
+</samlp:Response>`
+
+func lintRules(issues []LintIssue) []string {
+	var rules []string
+	for _, issue := range issues {
+		rules = append(rules, issue.Rule)
+	}
+	return rules
+}
+
+func TestLint_Conformant(t *testing.T) {
+	issues, err := Lint([]byte(conformantResponse), LintOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, issues)
+}
+
+func TestLint_Rules(t *testing.T) {
+	tests := []struct {
+		name    string
+		old     string
+		new     string
+		rule    string
+		message string
+	}{
+		{
+			name:    "missing version",
+			old:     `ID="_response0123456789" Version="2.0"`,
+			new:     `ID="_response0123456789"`,
+			rule:    RuleVersion,
+			message: `<samlp:Response ID="_response0123456789"> has no Version attribute`,
+		},
+		{
+			name:    "SAML 1.1 version",
+			old:     `ID="_assertion0123456789" Version="2.0"`,
+			new:     `ID="_assertion0123456789" Version="1.1"`,
+			rule:    RuleVersion,
+			message: `has Version "1.1", not "2.0"`,
+		},
+		{
+			name:    "ID starting with a digit",
+			old:     `ID="_response0123456789"`,
+			new:     `ID="0123456789abcdef0123"`,
+			rule:    RuleID,
+			message: "is not a valid xs:ID",
+		},
+		{
+			name:    "short ID",
+			old:     `ID="_assertion0123456789"`,
+			new:     `ID="_a1"`,
+			rule:    RuleIDLength,
+			message: "has an ID of 3 characters",
+		},
+		{
+			name:    "missing IssueInstant",
+			old:     `Version="2.0" IssueInstant="2024-01-15T10:00:00Z" InResponseTo`,
+			new:     `Version="2.0" InResponseTo`,
+			rule:    RuleIssueInstant,
+			message: "has no IssueInstant attribute",
+		},
+		{
+			name:    "offset IssueInstant",
+			old:     `Version="2.0" IssueInstant="2024-01-15T10:00:00Z" InResponseTo`,
+			new:     `Version="2.0" IssueInstant="2024-01-15T11:00:00+01:00" InResponseTo`,
+			rule:    RuleUTCTime,
+			message: `is not in UTC: use 2024-01-15T10:00:00Z`,
+		},
+		{
+			name:    "local time",
+			old:     `AuthnInstant="2024-01-15T10:00:00Z"`,
+			new:     `AuthnInstant="2024-01-15T10:00:00"`,
+			rule:    RuleUTCTime,
+			message: "has no time zone",
+		},
+		{
+			name:    "assertion without Issuer",
+			old:     "<saml:Issuer>https://idp.example.com</saml:Issuer>\n    <saml:Subject>",
+			new:     "<saml:Subject>",
+			rule:    RuleIssuer,
+			message: `<saml:Assertion ID="_assertion0123456789"> has no Issuer`,
+		},
+		{
+			name:    "Issuer with a non-entity Format",
+			old:     "<saml:Issuer>https://idp.example.com</saml:Issuer>\n  <samlp:Status>",
+			new:     `<saml:Issuer Format="urn:oasis:names:tc:SAML:1.1:nameid-format:unspecified">https://idp.example.com</saml:Issuer><samlp:Status>`,
+			rule:    RuleIssuerFormat,
+			message: `Issuer "https://idp.example.com" has Format`,
+		},
+		{
+			name:    "second-level status code at the top",
+			old:     `<samlp:StatusCode Value="urn:oasis:names:tc:SAML:2.0:status:Success"/>`,
+			new:     `<samlp:StatusCode Value="urn:oasis:names:tc:SAML:2.0:status:AuthnFailed"/>`,
+			rule:    RuleStatus,
+			message: "second-level codes belong in a nested StatusCode",
+		},
+		{
+			name:    "bearer without Recipient",
+			old:     `Recipient="https://sp.example.com/acs" `,
+			new:     ``,
+			rule:    RuleBearerRecipient,
+			message: "has no Recipient",
+		},
+		{
+			name:    "bearer without NotOnOrAfter",
+			old:     `Recipient="https://sp.example.com/acs" NotOnOrAfter="2024-01-15T10:05:00Z"`,
+			new:     `Recipient="https://sp.example.com/acs"`,
+			rule:    RuleBearerNotOnOrAfter,
+			message: "has no NotOnOrAfter",
+		},
+		{
+			name:    "bearer with NotBefore",
+			old:     `Recipient="https://sp.example.com/acs" `,
+			new:     `Recipient="https://sp.example.com/acs" NotBefore="2024-01-15T10:00:00Z" `,
+			rule:    RuleBearerNotBefore,
+			message: "has a NotBefore",
+		},
+		{
+			name:    "bearer answering another request",
+			old:     `InResponseTo="_request0123456789"/>`,
+			new:     `InResponseTo="_other0123456789"/>`,
+			rule:    RuleBearerInResponseTo,
+			message: `has InResponseTo "_other0123456789", but the Response answers "_request0123456789"`,
+		},
+		{
+			name:    "no bearer confirmation",
+			old:     `Method="urn:oasis:names:tc:SAML:2.0:cm:bearer"`,
+			new:     `Method="urn:oasis:names:tc:SAML:2.0:cm:holder-of-key"`,
+			rule:    RuleBearerConfirmation,
+			message: "has an AuthnStatement but no bearer SubjectConfirmation",
+		},
+		{
+			name:    "no AudienceRestriction",
+			old:     `<saml:AudienceRestriction><saml:Audience>https://sp.example.com</saml:Audience></saml:AudienceRestriction>`,
+			new:     ``,
+			rule:    RuleAudienceRestriction,
+			message: "is a bearer assertion without an AudienceRestriction",
+		},
+		{
+			name:    "no AuthnStatement",
+			old:     `<saml:AuthnStatement AuthnInstant="2024-01-15T10:00:00Z"/>`,
+			new:     ``,
+			rule:    RuleAuthnStatement,
+			message: "none of its assertions has an AuthnStatement",
+		},
+		{
+			name:    "inverted conditions",
+			old:     `NotBefore="2024-01-15T09:59:00Z"`,
+			new:     `NotBefore="2024-01-15T10:05:00Z"`,
+			rule:    RuleConditionsWindow,
+			message: "is never valid",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Contains(t, conformantResponse, tt.old)
+			issues, err := Lint([]byte(strings.Replace(conformantResponse, tt.old, tt.new, 1)), LintOptions{})
+			require.NoError(t, err)
+			require.Equal(t, []string{tt.rule}, lintRules(issues), "issues: %v", issues)
+			assert.Contains(t, issues[0].Message, tt.message)
+			assert.NotEmpty(t, issues[0].Spec)
+		})
+	}
+}
+
+func TestLint_ResponseIssuer(t *testing.T) {
+	unsigned := strings.Replace(conformantResponse, "<saml:Issuer>https://idp.example.com</saml:Issuer>\n  <samlp:Status>", "<samlp:Status>", 1)
+	issues, err := Lint([]byte(unsigned), LintOptions{})
+	require.NoError(t, err)
+	require.Len(t, issues, 1)
+	assert.Equal(t, RuleIssuer, issues[0].Rule)
+	assert.Equal(t, SeverityWarning, issues[0].Severity)
+
+	// Signed, the Response must name its Issuer
+	signed := strings.Replace(unsigned, "<samlp:Status>", `<ds:Signature xmlns:ds="http://www.w3.org/2000/09/xmldsig#"/><samlp:Status>`, 1)
+	issues, err = Lint([]byte(signed), LintOptions{})
+	require.NoError(t, err)
+	require.Len(t, issues, 1)
+	assert.Equal(t, SeverityError, issues[0].Severity)
+
+	// ... and its Destination
+	signed = strings.Replace(signed, `Destination="https://sp.example.com/acs"`, "", 1)
+	issues, err = Lint([]byte(signed), LintOptions{})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{RuleIssuer, RuleSignedDestination}, lintRules(issues))
+}
+
+func TestLint_AuthnRequest(t *testing.T) {
+	request := `<samlp:AuthnRequest xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="1" Version="2.0"
+    IssueInstant="2024-01-15T10:00:00Z" AssertionConsumerServiceIndex="0" AssertionConsumerServiceURL="https://sp.example.com/acs"/>`
+
+	issues, err := Lint([]byte(request), LintOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{RuleID, RuleIssuer, RuleACSExclusive}, lintRules(issues))
+}
+
+func TestLint_Suppressions(t *testing.T) {
+	broken := strings.Replace(conformantResponse, `<saml:AudienceRestriction><saml:Audience>https://sp.example.com</saml:Audience></saml:AudienceRestriction>`, "", 1)
+	broken = strings.Replace(broken, `ID="_assertion0123456789"`, `ID="_a1"`, 1)
+
+	issues, err := Lint([]byte(broken), LintOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{RuleIDLength, RuleAudienceRestriction}, lintRules(issues))
+
+	issues, err = Lint([]byte(broken), LintOptions{Disabled: []string{RuleIDLength}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{RuleAudienceRestriction}, lintRules(issues))
+
+	// A comment in the assertion disables rules for the assertion
+	inline := strings.Replace(broken, "<saml:Subject>", "<!-- samlurai-lint-disable audience-restriction -->\n    <saml:Subject>", 1)
+	issues, err = Lint([]byte(inline), LintOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{RuleIDLength}, lintRules(issues))
+
+	// A comment before the root element disables rules for the document
+	issues, err = Lint([]byte("<!-- samlurai-lint-disable id-length,audience-restriction -->\n"+broken), LintOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, issues)
+
+	// Without rule IDs, all rules are disabled
+	issues, err = Lint([]byte("<!-- samlurai-lint-disable -->\n"+broken), LintOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, issues)
+}
+
+func TestLint_InvalidXML(t *testing.T) {
+	_, err := Lint([]byte("<samlp:Response"), LintOptions{})
+	assert.ErrorContains(t, err, "failed to parse XML")
+}
+
+func TestIsNCName(t *testing.T) {
+	assert.True(t, isNCName("_abc"))
+	assert.True(t, isNCName("id-1.2_3"))
+	assert.True(t, isNCName("ñandú"))
+	assert.False(t, isNCName(""))
+	assert.False(t, isNCName("1abc"))
+	assert.False(t, isNCName("-abc"))
+	assert.False(t, isNCName("a:b"))
+	assert.False(t, isNCName("a b"))
+}
@@ -0,0 +1,136 @@
+package saml
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/beevik/etree"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildLogoutRequest(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	doc, err := BuildLogoutRequest(LogoutRequestOptions{
+		Issuer:         "https://sp.example.com",
+		Destination:    "https://idp.example.com/slo",
+		NameID:         "alice@example.com",
+		NameIDFormat:   "urn:oasis:names:tc:SAML:1.1:nameid-format:emailAddress",
+		SessionIndexes: []string{"_s1", "_s2"},
+		Reason:         "user",
+		NotOnOrAfter:   now.Add(5 * time.Minute),
+	}, now)
+	require.NoError(t, err)
+
+	req := doc.Root()
+	assert.Equal(t, "LogoutRequest", req.Tag)
+	assert.Equal(t, SAMLPNamespace, req.NamespaceURI())
+	assert.True(t, strings.HasPrefix(req.SelectAttrValue("ID", ""), "_"))
+	assert.Equal(t, "2024-05-01T12:00:00Z", req.SelectAttrValue("IssueInstant", ""))
+	assert.Equal(t, "2024-05-01T12:05:00Z", req.SelectAttrValue("NotOnOrAfter", ""))
+	assert.Equal(t, "https://idp.example.com/slo", req.SelectAttrValue("Destination", ""))
+	assert.Equal(t, LogoutReasonUser, req.SelectAttrValue("Reason", ""))
+
+	children := req.ChildElements()
+	require.Len(t, children, 4)
+	assert.Equal(t, "https://sp.example.com", children[0].Text())
+	assert.Equal(t, "NameID", children[1].Tag)
+	assert.Equal(t, "alice@example.com", children[1].Text())
+	assert.Equal(t, "urn:oasis:names:tc:SAML:1.1:nameid-format:emailAddress", children[1].SelectAttrValue("Format", ""))
+	assert.Equal(t, "_s1", children[2].Text())
+	assert.Equal(t, "_s2", children[3].Text())
+
+	xmlData, err := doc.WriteToBytes()
+	require.NoError(t, err)
+	assert.Equal(t, "LogoutRequest", NewHARExtractor().detectSAMLType(xmlData))
+}
+
+func TestBuildLogoutRequest_Invalid(t *testing.T) {
+	_, err := BuildLogoutRequest(LogoutRequestOptions{NameID: "alice"}, time.Now())
+	assert.ErrorContains(t, err, "issuer is required")
+
+	_, err = BuildLogoutRequest(LogoutRequestOptions{Issuer: "https://sp.example.com"}, time.Now())
+	assert.ErrorContains(t, err, "NameID is required")
+
+	_, err = BuildLogoutRequest(LogoutRequestOptions{Issuer: "https://sp.example.com", NameID: "alice", Reason: "bored"}, time.Now())
+	assert.ErrorContains(t, err, `unknown logout reason "bored"`)
+}
+
+func TestBuildLogoutRequest_SignEnveloped(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	cert := selfSignedCert(t, key)
+
+	doc, err := BuildLogoutRequest(LogoutRequestOptions{Issuer: "https://sp.example.com", NameID: "alice"}, time.Now())
+	require.NoError(t, err)
+	require.NoError(t, SignEnveloped(doc.Root(), key, cert, SigAlgRSASHA256))
+
+	// The signature survives serialization
+	xmlData, err := doc.WriteToBytes()
+	require.NoError(t, err)
+	parsed := etree.NewDocument()
+	require.NoError(t, parsed.ReadFromBytes(xmlData))
+	signer, err := VerifySignature(parsed.Root(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, cert.Raw, signer.Raw)
+}
+
+func TestRedirectURL(t *testing.T) {
+	xmlData := []byte(`<samlp:LogoutRequest xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_r1"/>`)
+
+	raw, err := RedirectURL("https://idp.example.com/slo?tenant=a", xmlData, "SAMLRequest", "/home", nil, "")
+	require.NoError(t, err)
+	u, err := url.Parse(raw)
+	require.NoError(t, err)
+	assert.Equal(t, "a", u.Query().Get("tenant"))
+	assert.Equal(t, "/home", u.Query().Get("RelayState"))
+	assert.Empty(t, u.Query().Get("Signature"))
+	inflated, err := NewDecoder().DecodeDeflate(u.Query().Get("SAMLRequest"))
+	require.NoError(t, err)
+	assert.Equal(t, xmlData, inflated)
+}
+
+func TestRedirectURL_Signed(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	cert := selfSignedCert(t, key)
+	xmlData := []byte(`<samlp:LogoutRequest xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_r1"/>`)
+
+	raw, err := RedirectURL("https://idp.example.com/slo", xmlData, "SAMLRequest", "", key, SigAlgRSASHA256)
+	require.NoError(t, err)
+
+	// The signature covers the query up to, not including, Signature
+	query := strings.SplitN(raw, "?", 2)[1]
+	signed, encodedSig, found := strings.Cut(query, "&Signature=")
+	require.True(t, found)
+	assert.NotContains(t, signed, "RelayState")
+	sigB64, err := url.QueryUnescape(encodedSig)
+	require.NoError(t, err)
+	signature, err := base64.StdEncoding.DecodeString(sigB64)
+	require.NoError(t, err)
+	assert.NoError(t, verifySignatureValue(cert, crypto.SHA256, []byte(signed), signature))
+
+	_, err = RedirectURL("https://idp.example.com/slo", xmlData, "SAMLRequest", "", key, "md5")
+	assert.ErrorContains(t, err, "unsupported signature algorithm")
+}
+
+func TestPostForm(t *testing.T) {
+	xmlData := []byte(`<samlp:LogoutRequest xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_r1"/>`)
+
+	form, err := PostForm("https://idp.example.com/slo", xmlData, "SAMLRequest", `"><script>`)
+	require.NoError(t, err)
+	assert.Contains(t, form, `<form method="post" action="https://idp.example.com/slo">`)
+	assert.Contains(t, form, `name="SAMLRequest" value="`+base64.StdEncoding.EncodeToString(xmlData)+`"`)
+	assert.Contains(t, form, `document.forms[0].submit()`)
+	assert.NotContains(t, form, `"><script>`)
+
+	form, err = PostForm("https://idp.example.com/slo", xmlData, "SAMLRequest", "")
+	require.NoError(t, err)
+	assert.NotContains(t, form, "RelayState")
+}
@@ -0,0 +1,176 @@
+package saml
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Log formats understood by ParseLogLine
+const (
+	// LogFormatCombined is the Apache/NGINX combined (and common) log format
+	LogFormatCombined = "combined"
+
+	// LogFormatRaw finds SAML parameters anywhere in a line, for logs
+	// without a known structure
+	LogFormatRaw = "raw"
+)
+
+// logParsers turn one log line into a HAR entry, or nil for lines that do
+// not describe a request
+var logParsers = map[string]func(line string) (*HAREntry, error){
+	LogFormatCombined: parseCombinedLine,
+	LogFormatRaw:      parseRawLine,
+}
+
+// LogFormats returns the names of the supported log formats
+func LogFormats() []string {
+	formats := make([]string, 0, len(logParsers))
+	for name := range logParsers {
+		formats = append(formats, name)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// ParseLogLine converts a log line into a HAR entry so the SAML parameters
+// in its request URL can be extracted like those of a captured request. Nil
+// is returned for lines that do not describe a request.
+func ParseLogLine(format, line string) (*HAREntry, error) {
+	parse, ok := logParsers[format]
+	if !ok {
+		return nil, fmt.Errorf("unknown log format %q: must be one of %s", format, strings.Join(LogFormats(), ", "))
+	}
+	return parse(strings.TrimRight(line, "\r\n"))
+}
+
+// combinedLinePattern matches
+//
+//	host ident user [10/Oct/2000:13:55:36 -0700] "GET /path HTTP/1.1" 200 2326 "referer" "agent"
+//
+// with the referer and user agent being optional (common log format)
+var combinedLinePattern = regexp.MustCompile(`^\S+ \S+ \S+ \[([^\]]+)\] "(\S+) (\S+)(?: [^"]*)?" (\d{3}) \S+`)
+
+const combinedTimeLayout = "02/Jan/2006:15:04:05 -0700"
+
+func parseCombinedLine(line string) (*HAREntry, error) {
+	m := combinedLinePattern.FindStringSubmatch(line)
+	if m == nil {
+		return nil, nil
+	}
+
+	entry := &HAREntry{
+		Request: HARRequest{Method: m[2], URL: m[3]},
+	}
+	if t, err := time.Parse(combinedTimeLayout, m[1]); err == nil {
+		entry.StartedDateTime = t.Format(time.RFC3339Nano)
+	}
+	entry.Response.Status, _ = strconv.Atoi(m[4])
+	return entry, nil
+}
+
+// rawParamPattern matches SAML parameters in query strings
+// (SAMLResponse=...), key-value logs (SAMLResponse: ...) and JSON
+// ("SAMLResponse": "..."), with values ending at characters that delimit
+// them in URLs, quoted strings and JSON
+var rawParamPattern = regexp.MustCompile(`(SAMLRequest|SAMLResponse|SAMLart|wresult)"?\s*[=:]\s*"?([^&\s"'<>,;]+)`)
+
+// jsonEscapes undoes the escaping JSON loggers apply to URLs and base64
+var jsonEscapes = strings.NewReplacer(`\/`, `/`, `\u0026`, `&`, `\u002b`, `+`, `\u002B`, `+`, `\u003d`, `=`, `\u003D`, `=`)
+
+func parseRawLine(line string) (*HAREntry, error) {
+	matches := rawParamPattern.FindAllStringSubmatch(jsonEscapes.Replace(line), -1)
+	if matches == nil {
+		return nil, nil
+	}
+
+	// The values were taken from the line verbatim, so they are encoded
+	// exactly as in a query string
+	return &HAREntry{
+		Request: HARRequest{Method: "GET", URL: "?" + encodeRawQuery(matches)},
+	}, nil
+}
+
+// encodeRawQuery rebuilds a query string without re-escaping the values
+func encodeRawQuery(matches [][]string) string {
+	pairs := make([]string, len(matches))
+	for i, m := range matches {
+		pairs[i] = m[1] + "=" + m[2]
+	}
+	return strings.Join(pairs, "&")
+}
+
+// bareSAMLPattern matches base64 blobs that decode to XML: "PD94" is
+// "<?xml" and "PH" the "<" of a root element. Applications often log the
+// SAMLResponse value without its parameter name.
+var bareSAMLPattern = regexp.MustCompile(`(?:^|[^A-Za-z0-9+/])((?:PD94|PH)[A-Za-z0-9+/]{60,}={0,2})`)
+
+// ExtractFromLog extracts the SAML messages from each line of a log in the
+// given format. Lines may be arbitrarily long, as with access logs of
+// HTTP-Redirect requests.
+func (e *HARExtractor) ExtractFromLog(data []byte, format string) ([]ExtractedSAML, error) {
+	if _, err := ParseLogLine(format, ""); err != nil {
+		return nil, err
+	}
+
+	var results []ExtractedSAML
+	index := 1
+	reader := bufio.NewReader(bytes.NewReader(data))
+	for lineNo := 1; ; lineNo++ {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			found, perr := e.extractFromLogLine(format, line, &index)
+			if perr != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, perr)
+			}
+			for i := range found {
+				found[i].Line = lineNo
+			}
+			results = append(results, found...)
+		}
+		if err != nil {
+			break
+		}
+	}
+	return results, nil
+}
+
+// ExtractFromLogLine extracts the SAML messages from a single log line
+func (e *HARExtractor) ExtractFromLogLine(format, line string) ([]ExtractedSAML, error) {
+	index := 1
+	return e.extractFromLogLine(format, line, &index)
+}
+
+func (e *HARExtractor) extractFromLogLine(format, line string, index *int) ([]ExtractedSAML, error) {
+	entry, err := ParseLogLine(format, line)
+	if err != nil {
+		return nil, err
+	}
+	var results []ExtractedSAML
+	if entry != nil {
+		results = e.extractFromEntries([]HAREntry{*entry}, index)
+	}
+	if format == LogFormatRaw {
+		// The query string was synthesized from the parameters in the line
+		for i := range results {
+			results[i].Source = "log"
+			results[i].URL = ""
+		}
+	}
+
+	// Unlabeled blobs are only looked for in unstructured logs, where no
+	// parameter was found
+	if format == LogFormatRaw && len(results) == 0 {
+		for _, m := range bareSAMLPattern.FindAllStringSubmatch(jsonEscapes.Replace(line), -1) {
+			if extracted := e.tryExtractSAML(m[1], "", "", "log", index); extracted != nil {
+				results = append(results, *extracted)
+			}
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,118 @@
+package saml
+
+import (
+	"encoding/base64"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// redirectParam encodes xml like the HTTP-Redirect binding does
+func redirectParam(t *testing.T, xml string) string {
+	t.Helper()
+	deflated, err := NewDecoder().Deflate([]byte(xml))
+	require.NoError(t, err)
+	return url.QueryEscape(base64.StdEncoding.EncodeToString(deflated))
+}
+
+func TestParseLogLine_Combined(t *testing.T) {
+	param := redirectParam(t, `<samlp:AuthnRequest xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_req"/>`)
+	line := `203.0.113.7 - - [10/Oct/2025:13:55:36 +0200] "GET /sso?SAMLRequest=` + param + `&RelayState=abc HTTP/1.1" 302 512 "https://sp.example.com/" "Mozilla/5.0"` + "\n"
+
+	entry, err := ParseLogLine(LogFormatCombined, line)
+	require.NoError(t, err)
+	require.NotNil(t, entry)
+	assert.Equal(t, "GET", entry.Request.Method)
+	assert.Equal(t, 302, entry.Response.Status)
+	assert.Equal(t, "2025-10-10T13:55:36+02:00", entry.StartedDateTime)
+
+	messages := NewHARExtractor().ExtractFromEntry(*entry)
+	require.Len(t, messages, 1)
+	assert.Equal(t, "AuthnRequest", messages[0].Type)
+	assert.True(t, messages[0].WasDeflated)
+
+	// Common log format lacks referer and user agent
+	entry, err = ParseLogLine(LogFormatCombined, `::1 - alice [10/Oct/2025:13:55:36 +0000] "POST /acs HTTP/2.0" 200 -`)
+	require.NoError(t, err)
+	require.NotNil(t, entry)
+	assert.Equal(t, "/acs", entry.Request.URL)
+
+	entry, err = ParseLogLine(LogFormatCombined, "nginx: worker process started")
+	require.NoError(t, err)
+	assert.Nil(t, entry)
+}
+
+func TestParseLogLine_Raw(t *testing.T) {
+	param := redirectParam(t, `<samlp:LogoutRequest xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_lo"/>`)
+	line := `{"level":"info","msg":"redirecting","location":"https://idp.example.com/slo?SAMLRequest=` + param + `"}`
+
+	entry, err := ParseLogLine(LogFormatRaw, line)
+	require.NoError(t, err)
+	require.NotNil(t, entry)
+
+	messages := NewHARExtractor().ExtractFromEntry(*entry)
+	require.Len(t, messages, 1)
+	assert.Equal(t, "LogoutRequest", messages[0].Type)
+
+	entry, err = ParseLogLine(LogFormatRaw, "GET /healthz 200")
+	require.NoError(t, err)
+	assert.Nil(t, entry)
+}
+
+func TestParseLogLine_UnknownFormat(t *testing.T) {
+	_, err := ParseLogLine("w3c", "")
+	assert.EqualError(t, err, `unknown log format "w3c": must be one of combined, raw`)
+}
+
+func TestExtractFromLog_Raw(t *testing.T) {
+	response := base64.StdEncoding.EncodeToString([]byte(`<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_r1"><saml:Issuer xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion">https://idp.example.com</saml:Issuer></samlp:Response>`))
+	jsonEscaped := strings.ReplaceAll(response, "/", `\/`)
+	log := strings.Join([]string{
+		`2025-10-10 13:55:36 INFO starting`,
+		`2025-10-10 13:55:37 DEBUG SAMLResponse: ` + response,
+		`{"ts":"2025-10-10T13:55:38Z","SAMLResponse":"` + jsonEscaped + `"}`,
+		`2025-10-10 13:55:39 DEBUG received assertion ` + response + ` from IdP`,
+		`2025-10-10 13:55:40 DEBUG token=PHRoaXMgaXMgbm90IFNBTUw+`,
+	}, "\n")
+
+	results, err := NewHARExtractor().ExtractFromLog([]byte(log), LogFormatRaw)
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+
+	for i, want := range []struct {
+		line  int
+		param string
+	}{{2, "SAMLResponse"}, {3, "SAMLResponse"}, {4, ""}} {
+		assert.Equal(t, i+1, results[i].Index)
+		assert.Equal(t, want.line, results[i].Line)
+		assert.Equal(t, want.param, results[i].ParameterName)
+		assert.Equal(t, "Response", results[i].Type)
+		assert.Equal(t, "log", results[i].Source)
+		assert.Empty(t, results[i].URL)
+	}
+	assert.Less(t, results[2].Confidence, results[0].Confidence, "unlabeled blobs score lower")
+}
+
+func TestExtractFromLog_LongLines(t *testing.T) {
+	// Padding pushes the line past bufio.Scanner's default 64 KiB limit
+	value := redirectParam(t, `<samlp:AuthnRequest xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_long">`+strings.Repeat(`<!-- padding -->`, 10000)+`</samlp:AuthnRequest>`)
+	param := redirectParam(t, `<samlp:AuthnRequest xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_short"/>`)
+	log := `10.0.0.1 - - [10/Oct/2025:13:55:36 +0000] "GET /sso?SAMLRequest=` + value + `&pad=` + strings.Repeat("x", 70000) + ` HTTP/1.1" 302 0` + "\n" +
+		`10.0.0.1 - - [10/Oct/2025:13:55:37 +0000] "GET /sso?SAMLRequest=` + param + ` HTTP/1.1" 302 0`
+
+	results, err := NewHARExtractor().ExtractFromLog([]byte(log), LogFormatCombined)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, 1, results[0].Line)
+	assert.Equal(t, 2, results[1].Line)
+	assert.Equal(t, "request-query", results[1].Source)
+	assert.Equal(t, "/sso?SAMLRequest="+param, results[1].URL)
+}
+
+func TestExtractFromLog_UnknownFormat(t *testing.T) {
+	_, err := NewHARExtractor().ExtractFromLog([]byte("x"), "w3c")
+	assert.EqualError(t, err, `unknown log format "w3c": must be one of combined, raw`)
+}
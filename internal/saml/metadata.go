@@ -0,0 +1,112 @@
+package saml
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/beevik/etree"
+)
+
+// MetadataNamespace is the namespace of SAML 2.0 metadata
+const MetadataNamespace = "urn:oasis:names:tc:SAML:2.0:metadata"
+
+// ErrEntityIDRequired is returned when an entity must be selected from a
+// metadata aggregate but no entity ID was given
+var ErrEntityIDRequired = errors.New("metadata is an aggregate of several entities; an entity ID is required")
+
+// SelectEntity returns the EntityDescriptor for entityID from a metadata
+// document. A single EntityDescriptor is returned as is; from an
+// EntitiesDescriptor aggregate, such as a federation feed, the matching
+// entity is extracted. Without an entityID an aggregate must contain
+// exactly one entity.
+func SelectEntity(metadata []byte, entityID string) ([]byte, error) {
+	doc, err := ReadDocument(metadata)
+	if err != nil {
+		return nil, &ErrParse{Stage: "metadata", Err: err}
+	}
+
+	root := doc.Root()
+	switch {
+	case root == nil:
+		return nil, &ErrParse{Stage: "metadata", Err: errors.New("no root element")}
+	case isElement(root, MetadataNamespace, "EntityDescriptor"):
+		return metadata, nil
+	case !isElement(root, MetadataNamespace, "EntitiesDescriptor"):
+		return nil, fmt.Errorf("not a metadata document: root element is <%s>", root.FullTag())
+	}
+
+	var entities []*etree.Element
+	walkElements(root, func(el *etree.Element) {
+		if isElement(el, MetadataNamespace, "EntityDescriptor") {
+			entities = append(entities, el)
+		}
+	})
+
+	var entity *etree.Element
+	switch {
+	case entityID != "":
+		for _, el := range entities {
+			if el.SelectAttrValue("entityID", "") == entityID {
+				entity = el
+				break
+			}
+		}
+		if entity == nil {
+			return nil, fmt.Errorf("entity %q not found in metadata aggregate of %d entities", entityID, len(entities))
+		}
+	case len(entities) == 1:
+		entity = entities[0]
+	default:
+		return nil, ErrEntityIDRequired
+	}
+
+	entityDoc := etree.NewDocument()
+	entityDoc.SetRoot(detachElement(entity))
+	return entityDoc.WriteToBytes()
+}
+
+// MetadataValidity returns the validUntil and cacheDuration of the root
+// element of a metadata document, if present
+func MetadataValidity(root *etree.Element) (validUntil *time.Time, cacheDuration time.Duration, err error) {
+	if v := root.SelectAttrValue("validUntil", ""); v != "" {
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid validUntil %q: %w", v, err)
+		}
+		validUntil = &t
+	}
+	if v := root.SelectAttrValue("cacheDuration", ""); v != "" {
+		if cacheDuration, err = ParseXSDuration(v); err != nil {
+			return nil, 0, err
+		}
+	}
+	return validUntil, cacheDuration, nil
+}
+
+var xsDurationPattern = regexp.MustCompile(`^P(?:(\d+)Y)?(?:(\d+)M)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+(?:\.\d+)?)S)?)?$`)
+
+// ParseXSDuration parses a non-negative xs:duration such as PT6H or P1D.
+// Years and months count as 365 and 30 days.
+func ParseXSDuration(value string) (time.Duration, error) {
+	m := xsDurationPattern.FindStringSubmatch(value)
+	if m == nil || value == "P" || value[len(value)-1] == 'T' {
+		return 0, fmt.Errorf("invalid xs:duration %q", value)
+	}
+
+	units := []time.Duration{365 * 24 * time.Hour, 30 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute}
+	var d time.Duration
+	for i, unit := range units {
+		if m[i+1] != "" {
+			n, _ := strconv.Atoi(m[i+1])
+			d += time.Duration(n) * unit
+		}
+	}
+	if m[6] != "" {
+		seconds, _ := strconv.ParseFloat(m[6], 64)
+		d += time.Duration(seconds * float64(time.Second))
+	}
+	return d, nil
+}
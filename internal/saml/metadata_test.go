@@ -0,0 +1,59 @@
+package saml
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const metadataAggregate = `<md:EntitiesDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" xmlns:ds="http://www.w3.org/2000/09/xmldsig#">
+  <md:EntitiesDescriptor Name="nested">
+    <md:EntityDescriptor entityID="https://a.example.com"><md:IDPSSODescriptor/></md:EntityDescriptor>
+  </md:EntitiesDescriptor>
+  <md:EntityDescriptor entityID="https://b.example.com"><md:SPSSODescriptor/></md:EntityDescriptor>
+</md:EntitiesDescriptor>`
+
+func TestSelectEntity(t *testing.T) {
+	entity, err := SelectEntity([]byte(metadataAggregate), "https://a.example.com")
+	require.NoError(t, err)
+	assert.Equal(t, `<md:EntityDescriptor entityID="https://a.example.com" xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" xmlns:ds="http://www.w3.org/2000/09/xmldsig#"><md:IDPSSODescriptor/></md:EntityDescriptor>`, string(entity))
+
+	_, err = SelectEntity([]byte(metadataAggregate), "https://c.example.com")
+	assert.EqualError(t, err, `entity "https://c.example.com" not found in metadata aggregate of 2 entities`)
+
+	_, err = SelectEntity([]byte(metadataAggregate), "")
+	assert.ErrorIs(t, err, ErrEntityIDRequired)
+
+	single := `<md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" entityID="https://a.example.com"/>`
+	entity, err = SelectEntity([]byte(single), "https://ignored.example.com")
+	require.NoError(t, err)
+	assert.Equal(t, single, string(entity))
+
+	_, err = SelectEntity([]byte(`<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol"/>`), "")
+	assert.EqualError(t, err, "not a metadata document: root element is <samlp:Response>")
+}
+
+func TestParseXSDuration(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"PT6H", 6 * time.Hour},
+		{"P1D", 24 * time.Hour},
+		{"P1DT30M", 24*time.Hour + 30*time.Minute},
+		{"PT1.5S", 1500 * time.Millisecond},
+		{"P1Y2M", (365 + 60) * 24 * time.Hour},
+	}
+	for _, tt := range tests {
+		got, err := ParseXSDuration(tt.value)
+		require.NoError(t, err, tt.value)
+		assert.Equal(t, tt.want, got, tt.value)
+	}
+
+	for _, invalid := range []string{"", "P", "PT", "6H", "-PT1H", "P1H"} {
+		_, err := ParseXSDuration(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
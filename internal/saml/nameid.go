@@ -0,0 +1,353 @@
+package saml
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/beevik/etree"
+)
+
+// NameIDFormat describes a NameID format URI
+type NameIDFormat struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// nameIDFormats are the NameID formats defined by SAML 1.1 and 2.0
+var nameIDFormats = []NameIDFormat{
+	{"urn:oasis:names:tc:SAML:1.1:nameid-format:unspecified", "unspecified",
+		"No format is implied; the IdP and SP agree on how the value is interpreted"},
+	{"urn:oasis:names:tc:SAML:1.1:nameid-format:emailAddress", "email",
+		"An email address (addr-spec of RFC 2822), usually the user's mail attribute"},
+	{"urn:oasis:names:tc:SAML:1.1:nameid-format:X509SubjectName", "X509SubjectName",
+		"The subject DN of an X.509 certificate, as in RFC 2253"},
+	{"urn:oasis:names:tc:SAML:1.1:nameid-format:WindowsDomainQualifiedName", "WindowsDomainQualifiedName",
+		`A Windows account name, DomainName\UserName or just UserName`},
+	{"urn:oasis:names:tc:SAML:2.0:nameid-format:kerberos", "kerberos",
+		"A Kerberos principal, name[/instance]@REALM"},
+	{"urn:oasis:names:tc:SAML:2.0:nameid-format:entity", "entity",
+		"The entity ID of a SAML provider, used for issuers rather than users"},
+	{"urn:oasis:names:tc:SAML:2.0:nameid-format:persistent", "persistent",
+		"An opaque identifier that stays the same for the user at this SP across sessions and differs between SPs (pairwise); at most 256 characters"},
+	{"urn:oasis:names:tc:SAML:2.0:nameid-format:transient", "transient",
+		"An opaque identifier valid for this session only; the SP cannot use it to recognize the user later"},
+	{"urn:oasis:names:tc:SAML:2.0:nameid-format:encrypted", "encrypted",
+		"Marks an EncryptedID; never the format of a NameID an SP receives in clear"},
+}
+
+// DescribeNameIDFormat returns the NameID format with the given URI. SAML
+// 2.0 spellings of SAML 1.1 formats, a common mistake, return the SAML 1.1
+// format, so its URI differs from uri.
+func DescribeNameIDFormat(uri string) (NameIDFormat, bool) {
+	for _, f := range nameIDFormats {
+		if f.URI == uri {
+			return f, true
+		}
+	}
+	for _, f := range nameIDFormats {
+		if strings.Replace(f.URI, ":1.1:", ":2.0:", 1) == uri {
+			return f, true
+		}
+	}
+	return NameIDFormat{}, false
+}
+
+// NameIDFormats returns the NameID formats defined by SAML 1.1 and 2.0
+func NameIDFormats() []NameIDFormat {
+	return append([]NameIDFormat(nil), nameIDFormats...)
+}
+
+// NameIDAnalysis describes a NameID value: its format and what its
+// structure shows about how it was generated
+type NameIDAnalysis struct {
+	// Location says where a NameID found in a message appears
+	Location string `json:"location,omitempty"`
+
+	Value           string        `json:"value"`
+	Format          *NameIDFormat `json:"format,omitempty"`
+	NameQualifier   string        `json:"name_qualifier,omitempty"`
+	SPNameQualifier string        `json:"sp_name_qualifier,omitempty"`
+
+	// Kind names what the value looks like, e.g. a computed persistent ID
+	Kind    string   `json:"kind"`
+	Details []string `json:"details,omitempty"`
+
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+var (
+	uuidPattern       = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	hexPattern        = regexp.MustCompile(`^[0-9a-fA-F]+$`)
+	scopedPattern     = regexp.MustCompile(`^([a-zA-Z0-9][a-zA-Z0-9=-]{0,126})@([a-zA-Z0-9][a-zA-Z0-9.-]{0,126})$`)
+	emailPattern      = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+	base32Pattern     = regexp.MustCompile(`^[A-Z2-7]+=*$`)
+	underscoreIDRegex = regexp.MustCompile(`^_[0-9a-f]{32,}$`)
+)
+
+// AnalyzeNameID describes a NameID value and, if set, its format URI.
+// eduPersonTargetedID values in their legacy string form,
+// IdP!SP!value, are split into their parts.
+func AnalyzeNameID(value, format string) NameIDAnalysis {
+	a := NameIDAnalysis{Value: value}
+	if format != "" {
+		if f, ok := DescribeNameIDFormat(format); ok {
+			a.Format = &f
+			if f.URI != format {
+				a.Warnings = append(a.Warnings, fmt.Sprintf("%s is not defined, the %s format is %s", format, f.Name, f.URI))
+			}
+		} else {
+			a.Format = &NameIDFormat{URI: format, Description: "Not a format defined by SAML; its meaning is agreed between IdP and SP"}
+		}
+	}
+
+	if parts := strings.Split(value, "!"); len(parts) == 3 && parts[0] != "" && parts[1] != "" && parts[2] != "" {
+		a.NameQualifier, a.SPNameQualifier = parts[0], parts[1]
+		inner := AnalyzeNameID(parts[2], "")
+		a.Kind = "eduPersonTargetedID in string form (IdP!SP!value)"
+		a.Details = append([]string{
+			"IdP: " + parts[0],
+			"SP: " + parts[1],
+			"Value: " + parts[2] + " (" + inner.Kind + ")",
+		}, inner.Details...)
+		return a
+	}
+
+	a.Kind, a.Details = classifyNameID(value)
+	a.Warnings = append(a.Warnings, formatWarnings(value, a.Format, a.Kind)...)
+	return a
+}
+
+// classifyNameID guesses how a value was generated from its structure
+func classifyNameID(value string) (string, []string) {
+	switch {
+	case value == "":
+		return "empty", nil
+
+	case underscoreIDRegex.MatchString(value):
+		return "random identifier", []string{"Hex with a leading underscore, as Shibboleth and other IdPs generate for transient IDs stored server-side"}
+
+	case uuidPattern.MatchString(value):
+		return "UUID", []string{"Often a directory object ID (e.g. Entra ID or AD objectGUID) or a stored random persistent ID"}
+
+	case hexPattern.MatchString(value) && (len(value) == 40 || len(value) == 64):
+		alg := "SHA-1"
+		if len(value) == 64 {
+			alg = "SHA-256"
+		}
+		return "hex digest", []string{fmt.Sprintf("A hex-encoded %s digest, as SimpleSAMLphp's core:TargetedID computes", alg)}
+	}
+
+	if m := scopedPattern.FindStringSubmatch(value); m != nil {
+		if raw, err := base32.StdEncoding.DecodeString(strings.ToUpper(m[1])); err == nil && base32Pattern.MatchString(strings.ToUpper(m[1])) {
+			if alg := digestAlgorithm(len(raw)); alg != "" {
+				return "computed pairwise-id", []string{
+					fmt.Sprintf("Base32 of a %s digest scoped to %s, as Shibboleth computes pairwise-id from a source attribute and salt", alg, m[2]),
+				}
+			}
+		}
+		if emailPattern.MatchString(value) && strings.Contains(m[2], ".") && !strings.Contains(m[1], "=") {
+			return "email address", nil
+		}
+		return "scoped identifier", []string{"Matches the syntax of the subject-id and pairwise-id attributes, value@scope, with scope " + m[2]}
+	}
+	if emailPattern.MatchString(value) {
+		return "email address", nil
+	}
+
+	if raw, err := base64.StdEncoding.DecodeString(value); err == nil {
+		if alias, ok := dataSealerAlias(raw); ok {
+			return "encrypted transient ID", []string{
+				fmt.Sprintf("Sealed by Shibboleth's DataSealer with key %q; only the IdP can decrypt it", alias),
+			}
+		}
+		if alg := digestAlgorithm(len(raw)); alg != "" {
+			return "computed persistent ID", []string{
+				fmt.Sprintf("Base64 of a %s digest, as Shibboleth computes persistent IDs from a source attribute and salt", alg),
+			}
+		}
+	}
+
+	if strings.Contains(value, `\`) {
+		return "Windows account name", nil
+	}
+	if strings.Contains(value, "=") && strings.Contains(value, ",") {
+		return "distinguished name", nil
+	}
+	return "plain identifier", nil
+}
+
+// digestAlgorithm names the digest with the given length, or ""
+func digestAlgorithm(n int) string {
+	switch n {
+	case sha1.Size:
+		return "SHA-1"
+	case sha256.Size:
+		return "SHA-256"
+	}
+	return ""
+}
+
+// dataSealerAlias returns the key alias at the start of data sealed by
+// Shibboleth's DataSealer, a Java modified-UTF-8 string with a two byte
+// length, such as "secret1"
+func dataSealerAlias(raw []byte) (string, bool) {
+	if len(raw) < 3 {
+		return "", false
+	}
+	n := int(raw[0])<<8 | int(raw[1])
+	if n == 0 || n > 64 || len(raw) < 2+n+16 {
+		return "", false
+	}
+	alias := string(raw[2 : 2+n])
+	if !utf8.ValidString(alias) {
+		return "", false
+	}
+	for _, r := range alias {
+		if r < 0x20 || r > 0x7e {
+			return "", false
+		}
+	}
+	return alias, true
+}
+
+// formatWarnings checks a value against what its format requires
+func formatWarnings(value string, format *NameIDFormat, kind string) []string {
+	if format == nil {
+		return nil
+	}
+	var warnings []string
+	switch format.Name {
+	case "email":
+		if !emailPattern.MatchString(value) {
+			warnings = append(warnings, "the value is not an email address")
+		}
+	case "persistent":
+		if len(value) > 256 {
+			warnings = append(warnings, fmt.Sprintf("persistent identifiers are limited to 256 characters, this one has %d", len(value)))
+		}
+		if kind == "email address" {
+			warnings = append(warnings, "an email address is not opaque and can change; SPs keying accounts on it break when it does")
+		}
+		if kind == "encrypted transient ID" {
+			warnings = append(warnings, "the value looks like a transient ID, which changes every session")
+		}
+	case "transient":
+		if kind == "email address" || kind == "plain identifier" {
+			warnings = append(warnings, "transient identifiers should be random and opaque, this one identifies the user")
+		}
+	}
+	return warnings
+}
+
+// AnalyzeNameIDs describes every NameID in a SAML message: subjects,
+// eduPersonTargetedID attribute values and the NameIDs of logout requests.
+// Encrypted assertions and EncryptedIDs must be decrypted first.
+func AnalyzeNameIDs(xmlData []byte) ([]NameIDAnalysis, error) {
+	doc, err := ReadDocument(xmlData)
+	if err != nil {
+		return nil, &ErrParse{Stage: "XML", Err: err}
+	}
+	if doc.Root() == nil {
+		return nil, errors.New("no root element")
+	}
+
+	var analyses []NameIDAnalysis
+	for _, el := range findElements(doc.Root(), SAMLNamespace, "NameID") {
+		a := AnalyzeNameID(strings.TrimSpace(el.Text()), el.SelectAttrValue("Format", ""))
+		if a.NameQualifier == "" {
+			a.NameQualifier = el.SelectAttrValue("NameQualifier", "")
+		}
+		if a.SPNameQualifier == "" {
+			a.SPNameQualifier = el.SelectAttrValue("SPNameQualifier", "")
+		}
+		a.Location = nameIDLocation(el)
+		analyses = append(analyses, a)
+	}
+	return analyses, nil
+}
+
+// nameIDLocation describes where a NameID appears, by its parent and the
+// attribute it is a value of
+func nameIDLocation(el *etree.Element) string {
+	for p := el.Parent(); p != nil; p = p.Parent() {
+		if isElement(p, SAMLNamespace, "Attribute") {
+			name := p.SelectAttrValue("Name", "")
+			if friendly := p.SelectAttrValue("FriendlyName", ""); friendly != "" {
+				name = fmt.Sprintf("%s (%s)", friendly, name)
+			}
+			return "Attribute " + name
+		}
+	}
+	if p := el.Parent(); p != nil {
+		return p.Tag
+	}
+	return el.Tag
+}
+
+// ComputedIDOptions are the inputs of a computed identifier
+type ComputedIDOptions struct {
+	// RelyingParty is the entity ID of the SP the identifier is for
+	RelyingParty string
+
+	// SourceValue is the value of the source attribute, e.g. a uid
+	SourceValue string
+
+	// Salt is the secret salt configured at the IdP
+	Salt string
+
+	// Algorithm is SHA-1 (the default) or SHA-256
+	Algorithm string
+}
+
+// digest computes SHA(relyingParty!sourceValue!salt), the digest
+// Shibboleth's ComputedPersistentIdGenerationStrategy is based on
+func (o ComputedIDOptions) digest() ([]byte, error) {
+	if o.RelyingParty == "" || o.SourceValue == "" || o.Salt == "" {
+		return nil, errors.New("relying party, source value and salt are required")
+	}
+	var h hash.Hash
+	switch strings.ToUpper(strings.ReplaceAll(o.Algorithm, "-", "")) {
+	case "", "SHA", "SHA1":
+		h = sha1.New()
+	case "SHA256":
+		h = sha256.New()
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q, expected SHA-1 or SHA-256", o.Algorithm)
+	}
+	h.Write([]byte(o.RelyingParty + "!" + o.SourceValue + "!" + o.Salt))
+	return h.Sum(nil), nil
+}
+
+// ComputePersistentID computes a persistent NameID as the Shibboleth IdP
+// does by default: the Base64 digest of the relying party, source value
+// and salt
+func ComputePersistentID(opts ComputedIDOptions) (string, error) {
+	sum, err := opts.digest()
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(sum), nil
+}
+
+// ComputePairwiseID computes a pairwise-id as the Shibboleth IdP does with
+// Base32 encoding, which keeps the value case-insensitive as the attribute
+// requires, followed by @scope
+func ComputePairwiseID(opts ComputedIDOptions, scope string) (string, error) {
+	if scope == "" {
+		return "", errors.New("a scope is required")
+	}
+	sum, err := opts.digest()
+	if err != nil {
+		return "", err
+	}
+	return base32.StdEncoding.EncodeToString(sum) + "@" + scope, nil
+}
@@ -0,0 +1,111 @@
+package saml
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComputePersistentID(t *testing.T) {
+	opts := ComputedIDOptions{RelyingParty: "https://sp.example.com", SourceValue: "jdoe", Salt: "s3cr3tsalt"}
+	id, err := ComputePersistentID(opts)
+	require.NoError(t, err)
+	assert.Equal(t, "6aP4RNZUCAd+FGrXHVuJ6/BfZXM=", id)
+
+	opts.Algorithm = "SHA-256"
+	id, err = ComputePersistentID(opts)
+	require.NoError(t, err)
+	assert.Equal(t, "Rv/7gFG09LZd0E/Pdl34GFX95y7NsbokB4TBS/8mst4=", id)
+
+	opts.Algorithm = "MD5"
+	_, err = ComputePersistentID(opts)
+	assert.ErrorContains(t, err, "unsupported algorithm")
+
+	_, err = ComputePersistentID(ComputedIDOptions{RelyingParty: "https://sp.example.com", SourceValue: "jdoe"})
+	assert.ErrorContains(t, err, "salt are required")
+}
+
+func TestComputePairwiseID(t *testing.T) {
+	opts := ComputedIDOptions{RelyingParty: "https://sp.example.com", SourceValue: "jdoe", Salt: "s3cr3tsalt"}
+	id, err := ComputePairwiseID(opts, "example.org")
+	require.NoError(t, err)
+	assert.Equal(t, "5GR7QRGWKQEAO7QUNLLR2W4J5PYF6ZLT@example.org", id)
+
+	_, err = ComputePairwiseID(opts, "")
+	assert.ErrorContains(t, err, "scope")
+}
+
+func TestAnalyzeNameID(t *testing.T) {
+	tests := []struct {
+		value string
+		kind  string
+	}{
+		{"6aP4RNZUCAd+FGrXHVuJ6/BfZXM=", "computed persistent ID"},
+		{"5GR7QRGWKQEAO7QUNLLR2W4J5PYF6ZLT@example.org", "computed pairwise-id"},
+		{"AAdzZWNyZXQxAAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8gISIjJCUmJw==", "encrypted transient ID"},
+		{"_8c2b0f3e5d9a4e7f8b1c2d3e4f5a6b7c", "random identifier"},
+		{"3f2504e0-4f89-11d3-9a0c-0305e82c3301", "UUID"},
+		{"alice@example.com", "email address"},
+		{"jdoe", "plain identifier"},
+		{`CORP\jdoe`, "Windows account name"},
+		{"CN=jdoe,OU=Users,DC=example,DC=com", "distinguished name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			assert.Equal(t, tt.kind, AnalyzeNameID(tt.value, "").Kind)
+		})
+	}
+}
+
+func TestAnalyzeNameID_TargetedID(t *testing.T) {
+	a := AnalyzeNameID("https://idp.example.org/idp/shibboleth!https://sp.example.com!6aP4RNZUCAd+FGrXHVuJ6/BfZXM=", "")
+	assert.Equal(t, "eduPersonTargetedID in string form (IdP!SP!value)", a.Kind)
+	assert.Equal(t, "https://idp.example.org/idp/shibboleth", a.NameQualifier)
+	assert.Equal(t, "https://sp.example.com", a.SPNameQualifier)
+	assert.Contains(t, a.Details, "Value: 6aP4RNZUCAd+FGrXHVuJ6/BfZXM= (computed persistent ID)")
+}
+
+func TestAnalyzeNameID_Format(t *testing.T) {
+	a := AnalyzeNameID("alice@example.com", "urn:oasis:names:tc:SAML:2.0:nameid-format:persistent")
+	require.NotNil(t, a.Format)
+	assert.Equal(t, "persistent", a.Format.Name)
+	assert.Len(t, a.Warnings, 1)
+	assert.Contains(t, a.Warnings[0], "not opaque")
+
+	a = AnalyzeNameID("jdoe", "urn:oasis:names:tc:SAML:2.0:nameid-format:emailAddress")
+	require.NotNil(t, a.Format)
+	assert.Equal(t, "urn:oasis:names:tc:SAML:1.1:nameid-format:emailAddress", a.Format.URI)
+	assert.Equal(t, []string{
+		"urn:oasis:names:tc:SAML:2.0:nameid-format:emailAddress is not defined, the email format is urn:oasis:names:tc:SAML:1.1:nameid-format:emailAddress",
+		"the value is not an email address",
+	}, a.Warnings)
+
+	a = AnalyzeNameID("jdoe", "urn:example:custom")
+	require.NotNil(t, a.Format)
+	assert.Empty(t, a.Format.Name)
+	assert.Empty(t, a.Warnings)
+}
+
+func TestAnalyzeNameIDs(t *testing.T) {
+	response := `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion">
+  <saml:Assertion>
+    <saml:Subject><saml:NameID Format="urn:oasis:names:tc:SAML:2.0:nameid-format:transient" NameQualifier="https://idp.example.org">_8c2b0f3e5d9a4e7f8b1c2d3e4f5a6b7c</saml:NameID></saml:Subject>
+    <saml:AttributeStatement>
+      <saml:Attribute Name="urn:oid:1.3.6.1.4.1.5923.1.1.1.10" FriendlyName="eduPersonTargetedID">
+        <saml:AttributeValue><saml:NameID Format="urn:oasis:names:tc:SAML:2.0:nameid-format:persistent" NameQualifier="https://idp.example.org" SPNameQualifier="https://sp.example.com">6aP4RNZUCAd+FGrXHVuJ6/BfZXM=</saml:NameID></saml:AttributeValue>
+      </saml:Attribute>
+    </saml:AttributeStatement>
+  </saml:Assertion>
+</samlp:Response>`
+
+	analyses, err := AnalyzeNameIDs([]byte(response))
+	require.NoError(t, err)
+	require.Len(t, analyses, 2)
+	assert.Equal(t, "Subject", analyses[0].Location)
+	assert.Equal(t, "random identifier", analyses[0].Kind)
+	assert.Equal(t, "https://idp.example.org", analyses[0].NameQualifier)
+	assert.Equal(t, "Attribute eduPersonTargetedID (urn:oid:1.3.6.1.4.1.5923.1.1.1.10)", analyses[1].Location)
+	assert.Equal(t, "computed persistent ID", analyses[1].Kind)
+	assert.Equal(t, "https://sp.example.com", analyses[1].SPNameQualifier)
+}
@@ -2,16 +2,22 @@ package saml
 
 import (
 	"bytes"
-	"crypto/x509"
+	"context"
 	"encoding/base64"
 	"encoding/xml"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 )
 
 // Parser handles parsing of SAML XML documents
-type Parser struct{}
+type Parser struct {
+	rawXML bool
+
+	// ctx, if set, stops decoding once it is done
+	ctx context.Context
+}
 
 // NewParser creates a new SAML parser
 func NewParser() *Parser {
@@ -40,7 +46,10 @@ type samlResponse struct {
 
 type samlStatus struct {
 	StatusCode struct {
-		Value string `xml:"Value,attr"`
+		Value      string `xml:"Value,attr"`
+		StatusCode struct {
+			Value string `xml:"Value,attr"`
+		} `xml:"StatusCode"`
 	} `xml:"StatusCode"`
 	StatusMessage string `xml:"StatusMessage"`
 }
@@ -63,6 +72,12 @@ type samlSubject struct {
 		Format          string `xml:"Format,attr"`
 		SPNameQualifier string `xml:"SPNameQualifier,attr"`
 	} `xml:"NameID"`
+	SubjectConfirmation struct {
+		SubjectConfirmationData struct {
+			Recipient    string `xml:"Recipient,attr"`
+			InResponseTo string `xml:"InResponseTo,attr"`
+		} `xml:"SubjectConfirmationData"`
+	} `xml:"SubjectConfirmation"`
 }
 
 type samlConditions struct {
@@ -71,6 +86,26 @@ type samlConditions struct {
 	AudienceRestriction struct {
 		Audiences []string `xml:"Audience"`
 	} `xml:"AudienceRestriction"`
+	Other []samlCondition `xml:",any"`
+}
+
+// samlCondition captures any condition other than AudienceRestriction,
+// with the content of the condition types the parser understands
+type samlCondition struct {
+	XMLName   xml.Name
+	XSIType   string         `xml:"http://www.w3.org/2001/XMLSchema-instance type,attr"`
+	Count     string         `xml:"Count,attr"`
+	Audiences []string       `xml:"Audience"`
+	Delegates []samlDelegate `xml:"Delegate"`
+}
+
+type samlDelegate struct {
+	DelegationInstant  string `xml:"DelegationInstant,attr"`
+	ConfirmationMethod string `xml:"ConfirmationMethod,attr"`
+	NameID             struct {
+		Format string `xml:"Format,attr"`
+		Value  string `xml:",chardata"`
+	} `xml:"NameID"`
 }
 
 type samlAuthnStatement struct {
@@ -99,33 +134,53 @@ type xmldsigSignature struct {
 			Algorithm string `xml:"Algorithm,attr"`
 		} `xml:"SignatureMethod"`
 		Reference struct {
+			URI          string `xml:"URI,attr"`
 			DigestMethod struct {
 				Algorithm string `xml:"Algorithm,attr"`
 			} `xml:"DigestMethod"`
 		} `xml:"Reference"`
 	} `xml:"SignedInfo"`
 	KeyInfo struct {
-		X509Data struct {
-			X509Certificate string `xml:"X509Certificate"`
-		} `xml:"X509Data"`
+		X509Certificates []string `xml:"X509Data>X509Certificate"`
 	} `xml:"KeyInfo"`
 }
 
 // AuthnRequest structure for XML parsing
 type samlAuthnRequest struct {
-	XMLName                     xml.Name          `xml:"AuthnRequest"`
-	ID                          string            `xml:"ID,attr"`
-	Version                     string            `xml:"Version,attr"`
-	IssueInstant                string            `xml:"IssueInstant,attr"`
-	Destination                 string            `xml:"Destination,attr"`
-	AssertionConsumerServiceURL string            `xml:"AssertionConsumerServiceURL,attr"`
-	ProtocolBinding             string            `xml:"ProtocolBinding,attr"`
-	ForceAuthn                  string            `xml:"ForceAuthn,attr"`
-	IsPassive                   string            `xml:"IsPassive,attr"`
-	Issuer                      string            `xml:"Issuer"`
-	NameIDPolicy                *samlNameIDPolicy `xml:"NameIDPolicy"`
-	Signature                   *xmldsigSignature `xml:"Signature"`
-	Extensions                  *samlExtensions   `xml:"Extensions"`
+	XMLName                     xml.Name                   `xml:"AuthnRequest"`
+	ID                          string                     `xml:"ID,attr"`
+	Version                     string                     `xml:"Version,attr"`
+	IssueInstant                string                     `xml:"IssueInstant,attr"`
+	Destination                 string                     `xml:"Destination,attr"`
+	AssertionConsumerServiceURL string                     `xml:"AssertionConsumerServiceURL,attr"`
+	ProtocolBinding             string                     `xml:"ProtocolBinding,attr"`
+	ForceAuthn                  string                     `xml:"ForceAuthn,attr"`
+	IsPassive                   string                     `xml:"IsPassive,attr"`
+	Issuer                      string                     `xml:"Issuer"`
+	NameIDPolicy                *samlNameIDPolicy          `xml:"NameIDPolicy"`
+	RequestedAuthnContext       *samlRequestedAuthnContext `xml:"RequestedAuthnContext"`
+	Scoping                     *samlScoping               `xml:"Scoping"`
+	Signature                   *xmldsigSignature          `xml:"Signature"`
+	Extensions                  *samlExtensions            `xml:"Extensions"`
+}
+
+type samlRequestedAuthnContext struct {
+	Comparison string   `xml:"Comparison,attr"`
+	ClassRefs  []string `xml:"AuthnContextClassRef"`
+	DeclRefs   []string `xml:"AuthnContextDeclRef"`
+}
+
+type samlScoping struct {
+	ProxyCount string `xml:"ProxyCount,attr"`
+	IDPList    *struct {
+		IDPEntries []struct {
+			ProviderID string `xml:"ProviderID,attr"`
+			Name       string `xml:"Name,attr"`
+			Loc        string `xml:"Loc,attr"`
+		} `xml:"IDPEntry"`
+		GetComplete string `xml:"GetComplete"`
+	} `xml:"IDPList"`
+	RequesterIDs []string `xml:"RequesterID"`
 }
 
 type samlNameIDPolicy struct {
@@ -147,9 +202,53 @@ type samlRequestedAttribute struct {
 
 // Parse parses a SAML XML document and returns structured information
 func (p *Parser) Parse(xmlData []byte) (*SAMLInfo, error) {
+	info, err := p.parse(xmlData)
+	if err != nil {
+		return nil, err
+	}
+	p.attachRawXML(info, xmlData)
+	return info, nil
+}
+
+// ParseContext is Parse, stopping with the cause of ctx being done once it
+// is canceled or its deadline passes
+func (p *Parser) ParseContext(ctx context.Context, xmlData []byte) (*SAMLInfo, error) {
+	if err := context.Cause(ctx); err != nil {
+		return nil, err
+	}
+	c := *p
+	c.ctx = ctx
+	info, err := c.Parse(xmlData)
+	return contextResult(ctx, info, err)
+}
+
+// unmarshal decodes xmlData into v, reading through the parser's context
+func (p *Parser) unmarshal(xmlData []byte, v any) error {
+	return xml.NewDecoder(withContext(p.ctx, bytes.NewReader(xmlData))).Decode(v)
+}
+
+func (p *Parser) parse(xmlData []byte) (*SAMLInfo, error) {
+	if err := rejectDTD(xmlData); err != nil {
+		return nil, err
+	}
+
 	// Try to detect the SAML message type
 	trimmed := bytes.TrimSpace(xmlData)
 
+	// WS-Trust messages carry an assertion, so check them first
+	if IsWSTrust(trimmed) {
+		return p.parseWSTrust(xmlData)
+	}
+
+	// Messages sent with the SOAP binding are parsed from the envelope body
+	if IsSOAP(trimmed) {
+		msg, err := UnwrapSOAP(trimmed)
+		if err != nil {
+			return nil, err
+		}
+		return p.Parse(msg)
+	}
+
 	if bytes.Contains(trimmed, []byte("<samlp:Response")) || bytes.Contains(trimmed, []byte("<Response")) {
 		return p.parseResponse(xmlData)
 	}
@@ -158,6 +257,10 @@ func (p *Parser) Parse(xmlData []byte) (*SAMLInfo, error) {
 		return p.parseAuthnRequest(xmlData)
 	}
 
+	if bytes.Contains(trimmed, []byte("<samlp:AttributeQuery")) || bytes.Contains(trimmed, []byte("<AttributeQuery")) {
+		return p.parseAttributeQuery(xmlData)
+	}
+
 	if bytes.Contains(trimmed, []byte("<saml:Assertion")) || bytes.Contains(trimmed, []byte("<Assertion")) {
 		return p.parseAssertion(xmlData)
 	}
@@ -173,13 +276,17 @@ func (p *Parser) Parse(xmlData []byte) (*SAMLInfo, error) {
 		return info, nil
 	}
 
-	return p.parseAssertion(xmlData)
+	info, err = p.parseAssertion(xmlData)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrNotSAML, err)
+	}
+	return info, nil
 }
 
 func (p *Parser) parseAuthnRequest(xmlData []byte) (*SAMLInfo, error) {
 	var req samlAuthnRequest
-	if err := xml.Unmarshal(xmlData, &req); err != nil {
-		return nil, fmt.Errorf("failed to parse SAML AuthnRequest: %w", err)
+	if err := p.unmarshal(xmlData, &req); err != nil {
+		return nil, &ErrParse{Stage: "SAML AuthnRequest", Err: err}
 	}
 
 	info := &SAMLInfo{
@@ -222,6 +329,29 @@ func (p *Parser) parseAuthnRequest(xmlData []byte) (*SAMLInfo, error) {
 		}
 	}
 
+	// Parse RequestedAuthnContext
+	if rac := req.RequestedAuthnContext; rac != nil {
+		info.RequestedAuthnContext = &RequestedAuthnContext{
+			Comparison: rac.Comparison,
+			ClassRefs:  trimAll(rac.ClassRefs),
+			DeclRefs:   trimAll(rac.DeclRefs),
+		}
+	}
+
+	// Parse Scoping
+	if scoping := req.Scoping; scoping != nil {
+		info.Scoping = &Scoping{
+			ProxyCount:   parseCount(scoping.ProxyCount),
+			RequesterIDs: trimAll(scoping.RequesterIDs),
+		}
+		if scoping.IDPList != nil {
+			for _, entry := range scoping.IDPList.IDPEntries {
+				info.Scoping.IDPList = append(info.Scoping.IDPList, IDPEntry{ProviderID: entry.ProviderID, Name: entry.Name, Loc: entry.Loc})
+			}
+			info.Scoping.GetComplete = strings.TrimSpace(scoping.IDPList.GetComplete)
+		}
+	}
+
 	// Parse Signature
 	if req.Signature != nil {
 		info.Signature = p.parseSignature(req.Signature)
@@ -248,8 +378,8 @@ func (p *Parser) parseAuthnRequest(xmlData []byte) (*SAMLInfo, error) {
 
 func (p *Parser) parseResponse(xmlData []byte) (*SAMLInfo, error) {
 	var resp samlResponse
-	if err := xml.Unmarshal(xmlData, &resp); err != nil {
-		return nil, fmt.Errorf("failed to parse SAML response: %w", err)
+	if err := p.unmarshal(xmlData, &resp); err != nil {
+		return nil, &ErrParse{Stage: "SAML response", Err: err}
 	}
 
 	info := &SAMLInfo{
@@ -273,6 +403,9 @@ func (p *Parser) parseResponse(xmlData []byte) (*SAMLInfo, error) {
 			StatusCode:    p.extractStatusCode(resp.Status.StatusCode.Value),
 			StatusMessage: resp.Status.StatusMessage,
 		}
+		if sub := resp.Status.StatusCode.StatusCode.Value; sub != "" {
+			info.Status.SubStatusCode = p.extractStatusCode(sub)
+		}
 	}
 
 	// Parse Signature
@@ -296,13 +429,39 @@ func (p *Parser) parseResponse(xmlData []byte) (*SAMLInfo, error) {
 // even if some parts (like encrypted assertions) cannot be fully parsed.
 // This is useful for showing partial information when decryption is not possible.
 func (p *Parser) ParsePartial(xmlData []byte) (*SAMLInfo, error) {
+	info, err := p.parsePartial(xmlData)
+	if err != nil {
+		return nil, err
+	}
+	p.attachRawXML(info, xmlData)
+	return info, nil
+}
+
+func (p *Parser) parsePartial(xmlData []byte) (*SAMLInfo, error) {
+	if err := rejectDTD(xmlData); err != nil {
+		return nil, err
+	}
+
 	trimmed := bytes.TrimSpace(xmlData)
 
+	if IsSOAP(trimmed) && !IsWSTrust(trimmed) {
+		msg, err := UnwrapSOAP(trimmed)
+		if err != nil {
+			return nil, err
+		}
+		return p.ParsePartial(msg)
+	}
+
 	// For responses with encrypted assertions, we can still show the response-level info
 	if bytes.Contains(trimmed, []byte("<samlp:Response")) || bytes.Contains(trimmed, []byte("<Response")) {
 		return p.parseResponsePartial(xmlData)
 	}
 
+	// A bare encrypted assertion only has its encryption metadata
+	if bytes.Contains(trimmed, []byte("EncryptedAssertion")) {
+		return &SAMLInfo{Type: "EncryptedAssertion", Encryption: ParseEncryptionInfo(xmlData)}, nil
+	}
+
 	// For other types, use regular parsing
 	return p.Parse(xmlData)
 }
@@ -311,8 +470,8 @@ func (p *Parser) ParsePartial(xmlData []byte) (*SAMLInfo, error) {
 // even if the assertion is encrypted
 func (p *Parser) parseResponsePartial(xmlData []byte) (*SAMLInfo, error) {
 	var resp samlResponse
-	if err := xml.Unmarshal(xmlData, &resp); err != nil {
-		return nil, fmt.Errorf("failed to parse SAML response: %w", err)
+	if err := p.unmarshal(xmlData, &resp); err != nil {
+		return nil, &ErrParse{Stage: "SAML response", Err: err}
 	}
 
 	info := &SAMLInfo{
@@ -336,6 +495,9 @@ func (p *Parser) parseResponsePartial(xmlData []byte) (*SAMLInfo, error) {
 			StatusCode:    p.extractStatusCode(resp.Status.StatusCode.Value),
 			StatusMessage: resp.Status.StatusMessage,
 		}
+		if sub := resp.Status.StatusCode.StatusCode.Value; sub != "" {
+			info.Status.SubStatusCode = p.extractStatusCode(sub)
+		}
 	}
 
 	// Parse Signature
@@ -343,13 +505,15 @@ func (p *Parser) parseResponsePartial(xmlData []byte) (*SAMLInfo, error) {
 		info.Signature = p.parseSignature(resp.Signature)
 	}
 
+	info.Encryption = ParseEncryptionInfo(xmlData)
+
 	return info, nil
 }
 
 func (p *Parser) parseAssertion(xmlData []byte) (*SAMLInfo, error) {
 	var assertion samlAssertion
-	if err := xml.Unmarshal(xmlData, &assertion); err != nil {
-		return nil, fmt.Errorf("failed to parse SAML assertion: %w", err)
+	if err := p.unmarshal(xmlData, &assertion); err != nil {
+		return nil, &ErrParse{Stage: "SAML assertion", Err: err}
 	}
 
 	return p.parseAssertionStruct(&assertion)
@@ -375,6 +539,8 @@ func (p *Parser) parseAssertionStruct(assertion *samlAssertion) (*SAMLInfo, erro
 			NameID:          assertion.Subject.NameID.Value,
 			NameIDFormat:    assertion.Subject.NameID.Format,
 			SPNameQualifier: assertion.Subject.NameID.SPNameQualifier,
+			Recipient:       assertion.Subject.SubjectConfirmation.SubjectConfirmationData.Recipient,
+			InResponseTo:    assertion.Subject.SubjectConfirmation.SubjectConfirmationData.InResponseTo,
 		}
 	}
 
@@ -392,6 +558,9 @@ func (p *Parser) parseAssertionStruct(assertion *samlAssertion) (*SAMLInfo, erro
 			}
 		}
 		info.Conditions.AudienceRestriction = assertion.Conditions.AudienceRestriction.Audiences
+		for _, cond := range assertion.Conditions.Other {
+			info.Conditions.Other = append(info.Conditions.Other, parseCondition(cond))
+		}
 	}
 
 	// Parse AuthnStatement
@@ -419,6 +588,19 @@ func (p *Parser) parseAssertionStruct(assertion *samlAssertion) (*SAMLInfo, erro
 		}
 	}
 
+	// Brokers may forward the upstream token as an attribute value
+	for _, attr := range info.Attributes {
+		for _, value := range attr.Values {
+			data, ok := embeddedToken(value)
+			if !ok {
+				continue
+			}
+			if nested, err := p.Parse(data); err == nil {
+				info.EmbeddedTokens = append(info.EmbeddedTokens, EmbeddedToken{Attribute: attr.Name, Info: nested})
+			}
+		}
+	}
+
 	// Parse Signature
 	if assertion.Signature != nil {
 		info.Signature = p.parseSignature(assertion.Signature)
@@ -427,33 +609,42 @@ func (p *Parser) parseAssertionStruct(assertion *samlAssertion) (*SAMLInfo, erro
 	return info, nil
 }
 
+// embeddedToken returns the SAML document in an attribute value, which may
+// be base64-encoded or escaped XML
+func embeddedToken(value string) ([]byte, bool) {
+	trimmed := strings.TrimSpace(value)
+	data := []byte(trimmed)
+	if !strings.HasPrefix(trimmed, "<") {
+		decoded, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(trimmed), ""))
+		if err != nil {
+			return nil, false
+		}
+		data = decoded
+	}
+
+	if !bytes.Contains(data, []byte(SAMLNamespace)) && !bytes.Contains(data, []byte(SAMLPNamespace)) {
+		return nil, false
+	}
+	return data, true
+}
+
 func (p *Parser) parseSignature(sig *xmldsigSignature) *SignatureInfo {
 	sigInfo := &SignatureInfo{
 		Signed:          true,
 		SignatureMethod: sig.SignedInfo.SignatureMethod.Algorithm,
 		DigestMethod:    sig.SignedInfo.Reference.DigestMethod.Algorithm,
+		ReferenceURI:    sig.SignedInfo.Reference.URI,
 	}
 
-	// Try to parse certificate info
-	if sig.KeyInfo.X509Data.X509Certificate != "" {
-		certData := sig.KeyInfo.X509Data.X509Certificate
-		// Clean up whitespace
-		certData = strings.ReplaceAll(certData, "\n", "")
-		certData = strings.ReplaceAll(certData, "\r", "")
-		certData = strings.ReplaceAll(certData, " ", "")
-
-		if certBytes, err := base64.StdEncoding.DecodeString(certData); err == nil {
-			if cert, err := x509.ParseCertificate(certBytes); err == nil {
-				sigInfo.CertificateInfo = &CertificateInfo{
-					Subject:   cert.Subject.String(),
-					Issuer:    cert.Issuer.String(),
-					NotBefore: cert.NotBefore,
-					NotAfter:  cert.NotAfter,
-					Serial:    cert.SerialNumber.String(),
-				}
-			}
+	// Collect the whole chain; the first certificate is the signer's
+	for _, certData := range sig.KeyInfo.X509Certificates {
+		if cert, err := ParseCertificate([]byte(certData)); err == nil {
+			sigInfo.Certificates = append(sigInfo.Certificates, NewCertificateInfo(cert))
 		}
 	}
+	if len(sigInfo.Certificates) > 0 {
+		sigInfo.CertificateInfo = sigInfo.Certificates[0]
+	}
 
 	return sigInfo
 }
@@ -466,3 +657,47 @@ func (p *Parser) extractStatusCode(fullCode string) string {
 	}
 	return fullCode
 }
+
+// parseCondition converts a captured condition to its Condition type.
+// Custom conditions are told apart by the local part of their xsi:type, as
+// the prefix depends on the document.
+func parseCondition(cond samlCondition) Condition {
+	xsiType := cond.XSIType
+	if i := strings.LastIndex(xsiType, ":"); i >= 0 {
+		xsiType = xsiType[i+1:]
+	}
+	switch {
+	case cond.XMLName.Local == "OneTimeUse":
+		return &OneTimeUse{}
+	case cond.XMLName.Local == "ProxyRestriction":
+		return &ProxyRestriction{
+			Count:     parseCount(cond.Count),
+			Audiences: trimAll(cond.Audiences),
+		}
+	case cond.XMLName.Local == "Condition" && xsiType == "DelegationRestrictionType":
+		dr := &DelegationRestriction{}
+		for _, d := range cond.Delegates {
+			delegate := Delegate{
+				NameID:             strings.TrimSpace(d.NameID.Value),
+				NameIDFormat:       d.NameID.Format,
+				ConfirmationMethod: d.ConfirmationMethod,
+			}
+			if t, err := time.Parse(time.RFC3339, d.DelegationInstant); err == nil {
+				delegate.DelegationInstant = &t
+			}
+			dr.Delegates = append(dr.Delegates, delegate)
+		}
+		return dr
+	}
+	return &UnknownCondition{Name: cond.XMLName.Local, XSIType: cond.XSIType}
+}
+
+// parseCount parses a non-negative count attribute such as ProxyCount, or
+// returns nil if it is absent or invalid
+func parseCount(value string) *int {
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || n < 0 {
+		return nil
+	}
+	return &n
+}
@@ -1,9 +1,13 @@
 package saml
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -250,3 +254,187 @@ func findAttribute(attrs []Attribute, name string) *Attribute {
 	}
 	return nil
 }
+
+func TestParser_ParseSignatureReference(t *testing.T) {
+	parser := NewParser()
+
+	detached := `<?xml version="1.0"?>
+<saml:Assertion xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" xmlns:ds="http://www.w3.org/2000/09/xmldsig#" ID="_det123">
+	<saml:Issuer>https://idp.example.com</saml:Issuer>
+	<ds:Signature>
+		<ds:SignedInfo>
+			<ds:SignatureMethod Algorithm="http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"/>
+			<ds:Reference URI="https://idp.example.com/artifact/1">
+				<ds:DigestMethod Algorithm="http://www.w3.org/2001/04/xmlenc#sha256"/>
+			</ds:Reference>
+		</ds:SignedInfo>
+	</ds:Signature>
+	<saml:Subject>
+		<saml:NameID>user@example.com</saml:NameID>
+		<saml:SubjectConfirmation Method="urn:oasis:names:tc:SAML:2.0:cm:bearer">
+			<saml:SubjectConfirmationData Recipient="https://sp.example.com/acs"/>
+		</saml:SubjectConfirmation>
+	</saml:Subject>
+</saml:Assertion>`
+
+	info, err := parser.Parse([]byte(detached))
+	require.NoError(t, err)
+
+	require.NotNil(t, info.Signature)
+	assert.Equal(t, "https://idp.example.com/artifact/1", info.Signature.ReferenceURI)
+	assert.True(t, info.Signature.IsDetached())
+	assert.Equal(t, "https://sp.example.com/acs", info.Subject.Recipient)
+
+	assert.False(t, (&SignatureInfo{ReferenceURI: "#_det123"}).IsDetached())
+	assert.False(t, (&SignatureInfo{}).IsDetached())
+}
+
+func TestParser_ParseEmbeddedTokens(t *testing.T) {
+	upstream, err := os.ReadFile(filepath.Join("..", "..", "testdata", "fixtures", "assertions", "assertion.xml"))
+	require.NoError(t, err)
+
+	xml := `<saml:Assertion xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_broker">
+  <saml:Issuer>https://broker.example.com</saml:Issuer>
+  <saml:AttributeStatement>
+    <saml:Attribute Name="upstreamToken"><saml:AttributeValue>` + base64.StdEncoding.EncodeToString(upstream) + `</saml:AttributeValue></saml:Attribute>
+    <saml:Attribute Name="escapedToken"><saml:AttributeValue>&lt;saml:Assertion xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_escaped"/&gt;</saml:AttributeValue></saml:Attribute>
+    <saml:Attribute Name="role"><saml:AttributeValue>admin</saml:AttributeValue></saml:Attribute>
+    <saml:Attribute Name="blob"><saml:AttributeValue>` + base64.StdEncoding.EncodeToString([]byte("<other/>")) + `</saml:AttributeValue></saml:Attribute>
+  </saml:AttributeStatement>
+</saml:Assertion>`
+
+	info, err := NewParser().Parse([]byte(xml))
+	require.NoError(t, err)
+
+	require.Len(t, info.EmbeddedTokens, 2)
+	assert.Equal(t, "upstreamToken", info.EmbeddedTokens[0].Attribute)
+	assert.Equal(t, "Assertion", info.EmbeddedTokens[0].Info.Type)
+	assert.NotEmpty(t, info.EmbeddedTokens[0].Info.Issuer)
+	assert.Equal(t, "escapedToken", info.EmbeddedTokens[1].Attribute)
+	assert.Equal(t, "_escaped", info.EmbeddedTokens[1].Info.ID)
+}
+
+func TestParser_SubStatusCode(t *testing.T) {
+	xml := `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_r" Version="2.0">
+  <saml:Issuer>https://idp.example.com</saml:Issuer>
+  <samlp:Status>
+    <samlp:StatusCode Value="urn:oasis:names:tc:SAML:2.0:status:Responder">
+      <samlp:StatusCode Value="urn:oasis:names:tc:SAML:2.0:status:InvalidNameIDPolicy"/>
+    </samlp:StatusCode>
+  </samlp:Status>
+</samlp:Response>`
+
+	info, err := NewParser().Parse([]byte(xml))
+	require.NoError(t, err)
+	require.NotNil(t, info.Status)
+	assert.Equal(t, "Responder", info.Status.StatusCode)
+	assert.Equal(t, "InvalidNameIDPolicy", info.Status.SubStatusCode)
+	assert.Contains(t, Warnings(info, time.Now()), "status is Responder/InvalidNameIDPolicy")
+}
+
+func TestParser_RequestedAuthnContext(t *testing.T) {
+	xml := `<samlp:AuthnRequest xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_req" Version="2.0">
+  <saml:Issuer>https://sp.example.com</saml:Issuer>
+  <samlp:RequestedAuthnContext Comparison="minimum">
+    <saml:AuthnContextClassRef>
+      urn:oasis:names:tc:SAML:2.0:ac:classes:PasswordProtectedTransport
+    </saml:AuthnContextClassRef>
+    <saml:AuthnContextClassRef>urn:oasis:names:tc:SAML:2.0:ac:classes:X509</saml:AuthnContextClassRef>
+  </samlp:RequestedAuthnContext>
+</samlp:AuthnRequest>`
+
+	info, err := NewParser().Parse([]byte(xml))
+	require.NoError(t, err)
+	require.NotNil(t, info.RequestedAuthnContext)
+	assert.Equal(t, "minimum", info.RequestedAuthnContext.Comparison)
+	assert.Equal(t, []string{
+		"urn:oasis:names:tc:SAML:2.0:ac:classes:PasswordProtectedTransport",
+		"urn:oasis:names:tc:SAML:2.0:ac:classes:X509",
+	}, info.RequestedAuthnContext.ClassRefs)
+	assert.Empty(t, info.RequestedAuthnContext.DeclRefs)
+}
+
+func TestParser_Scoping(t *testing.T) {
+	xml := `<samlp:AuthnRequest xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_req" Version="2.0">
+  <saml:Issuer>https://keycloak.example.com/realms/corp</saml:Issuer>
+  <samlp:Scoping ProxyCount="1">
+    <samlp:IDPList>
+      <samlp:IDPEntry ProviderID="http://adfs.example.com/adfs/services/trust" Name="Corporate AD FS"/>
+      <samlp:IDPEntry ProviderID="https://idp.partner.example.org"/>
+      <samlp:GetComplete>https://keycloak.example.com/idplist</samlp:GetComplete>
+    </samlp:IDPList>
+    <samlp:RequesterID>https://app.example.com</samlp:RequesterID>
+  </samlp:Scoping>
+</samlp:AuthnRequest>`
+
+	info, err := NewParser().Parse([]byte(xml))
+	require.NoError(t, err)
+	require.NotNil(t, info.Scoping)
+	require.NotNil(t, info.Scoping.ProxyCount)
+	assert.Equal(t, 1, *info.Scoping.ProxyCount)
+	assert.Equal(t, []IDPEntry{
+		{ProviderID: "http://adfs.example.com/adfs/services/trust", Name: "Corporate AD FS"},
+		{ProviderID: "https://idp.partner.example.org"},
+	}, info.Scoping.IDPList)
+	assert.Equal(t, "https://keycloak.example.com/idplist", info.Scoping.GetComplete)
+	assert.Equal(t, []string{"https://app.example.com"}, info.Scoping.RequesterIDs)
+}
+
+func TestParser_Conditions(t *testing.T) {
+	xml := `<saml:Assertion xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xmlns:del="urn:oasis:names:tc:SAML:2.0:conditions:delegation" ID="_a" Version="2.0" IssueInstant="2024-01-15T10:00:00Z">
+  <saml:Issuer>https://idp.example.com</saml:Issuer>
+  <saml:Conditions NotBefore="2024-01-15T10:00:00Z" NotOnOrAfter="2024-01-15T10:05:00Z">
+    <saml:AudienceRestriction><saml:Audience>https://keycloak.example.com/realms/corp</saml:Audience></saml:AudienceRestriction>
+    <saml:OneTimeUse/>
+    <saml:ProxyRestriction Count="0">
+      <saml:Audience>https://app.example.com</saml:Audience>
+    </saml:ProxyRestriction>
+    <saml:Condition xsi:type="del:DelegationRestrictionType">
+      <del:Delegate DelegationInstant="2024-01-15T09:59:58Z" ConfirmationMethod="urn:oasis:names:tc:SAML:2.0:cm:sender-vouches">
+        <saml:NameID Format="urn:oasis:names:tc:SAML:2.0:nameid-format:entity">https://gateway.example.com</saml:NameID>
+      </del:Delegate>
+    </saml:Condition>
+    <saml:Condition xsi:type="ext:CustomConditionType"/>
+  </saml:Conditions>
+</saml:Assertion>`
+
+	info, err := NewParser().Parse([]byte(xml))
+	require.NoError(t, err)
+	require.NotNil(t, info.Conditions)
+	assert.Equal(t, []string{"https://keycloak.example.com/realms/corp"}, info.Conditions.AudienceRestriction)
+	require.Len(t, info.Conditions.Other, 4)
+	assert.True(t, info.Conditions.OneTimeUse())
+
+	pr := info.Conditions.ProxyRestriction()
+	require.NotNil(t, pr)
+	require.NotNil(t, pr.Count)
+	assert.Equal(t, 0, *pr.Count)
+	assert.Equal(t, []string{"https://app.example.com"}, pr.Audiences)
+
+	dr := info.Conditions.DelegationRestriction()
+	require.NotNil(t, dr)
+	require.Len(t, dr.Delegates, 1)
+	assert.Equal(t, "https://gateway.example.com", dr.Delegates[0].NameID)
+	assert.Equal(t, "urn:oasis:names:tc:SAML:2.0:nameid-format:entity", dr.Delegates[0].NameIDFormat)
+	assert.Equal(t, "urn:oasis:names:tc:SAML:2.0:cm:sender-vouches", dr.Delegates[0].ConfirmationMethod)
+	require.NotNil(t, dr.Delegates[0].DelegationInstant)
+
+	assert.Equal(t, &UnknownCondition{Name: "Condition", XSIType: "ext:CustomConditionType"}, info.Conditions.Other[3])
+	assert.Contains(t, Warnings(info, time.Date(2024, 1, 15, 10, 1, 0, 0, time.UTC)),
+		"assertion has the condition ext:CustomConditionType, which a relying party that does not understand it must treat as indeterminate")
+
+	data, err := json.Marshal(info.Conditions)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `{"type":"OneTimeUse"}`)
+	assert.Contains(t, string(data), `{"audiences":["https://app.example.com"],"count":0,"type":"ProxyRestriction"}`)
+	var decoded Conditions
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, info.Conditions.Other, decoded.Other)
+
+	// Without the conditions, and with an unlimited proxy count
+	info, err = NewParser().Parse([]byte(strings.Replace(strings.Replace(xml, "<saml:OneTimeUse/>", "", 1), ` Count="0"`, "", 1)))
+	require.NoError(t, err)
+	assert.False(t, info.Conditions.OneTimeUse())
+	require.NotNil(t, info.Conditions.ProxyRestriction())
+	assert.Nil(t, info.Conditions.ProxyRestriction().Count)
+}
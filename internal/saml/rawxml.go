@@ -0,0 +1,63 @@
+package saml
+
+import (
+	"bytes"
+	"encoding/xml"
+)
+
+// WithRawXML makes the parser keep the original bytes of the parsed
+// message and of its assertion in SAMLInfo.RawXML, e.g. to verify their
+// signatures later or to extract them unchanged
+func (p *Parser) WithRawXML(keep bool) *Parser {
+	p.rawXML = keep
+	return p
+}
+
+// attachRawXML sets RawXML of info and its assertion from the document
+// they were parsed from, unless already set by a nested parse
+func (p *Parser) attachRawXML(info *SAMLInfo, xmlData []byte) {
+	if !p.rawXML || info == nil || info.RawXML != "" {
+		return
+	}
+	root, assertion := rawElements(xmlData)
+	info.RawXML = string(root)
+	if info.Assertion != nil && info.Assertion.RawXML == "" {
+		info.Assertion.RawXML = string(assertion)
+	}
+}
+
+// rawElements returns the bytes of the root element of an XML document and
+// of its first SAML assertion, exactly as they appear in the document.
+// Namespace declarations of ancestors are not copied into the assertion.
+func rawElements(data []byte) (root, assertion []byte) {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	depth := 0
+	rootStart, assertionStart, assertionDepth := int64(-1), int64(-1), 0
+	for {
+		offset := d.InputOffset()
+		tok, err := d.Token()
+		if err != nil {
+			return root, assertion
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+			if rootStart < 0 {
+				rootStart = offset
+			}
+			if assertionStart < 0 && assertion == nil && t.Name.Space == SAMLNamespace && t.Name.Local == "Assertion" {
+				assertionStart, assertionDepth = offset, depth
+			}
+		case xml.EndElement:
+			end := d.InputOffset()
+			if depth == assertionDepth && assertionStart >= 0 {
+				assertion = data[assertionStart:end]
+				assertionStart = -1
+			}
+			depth--
+			if depth == 0 {
+				return data[rootStart:end], assertion
+			}
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package saml
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParser_WithRawXML(t *testing.T) {
+	assertion := `<saml:Assertion ID="_a1" Version="2.0">
+	<saml:Issuer>https://idp.example.com</saml:Issuer>
+	<saml:Subject><saml:NameID>alice</saml:NameID></saml:Subject>
+</saml:Assertion>`
+	response := `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol"   xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_r1" Version="2.0"><saml:Issuer>https://idp.example.com</saml:Issuer><samlp:Status><samlp:StatusCode Value="urn:oasis:names:tc:SAML:2.0:status:Success"/></samlp:Status>` + assertion + `</samlp:Response>`
+	doc := "<?xml version=\"1.0\"?>\n" + response + "\n"
+
+	info, err := NewParser().Parse([]byte(doc))
+	require.NoError(t, err)
+	assert.Empty(t, info.RawXML)
+	assert.Empty(t, info.Assertion.RawXML)
+
+	info, err = NewParser().WithRawXML(true).Parse([]byte(doc))
+	require.NoError(t, err)
+	assert.Equal(t, response, info.RawXML)
+	require.NotNil(t, info.Assertion)
+	assert.Equal(t, assertion, info.Assertion.RawXML)
+}
+
+func TestParser_WithRawXMLSOAP(t *testing.T) {
+	request := `<samlp:AuthnRequest xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_req" Version="2.0"/>`
+	envelope := `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body>` + request + `</soap:Body></soap:Envelope>`
+
+	info, err := NewParser().WithRawXML(true).Parse([]byte(envelope))
+	require.NoError(t, err)
+	assert.Equal(t, "AuthnRequest", info.Type)
+	assert.Contains(t, info.RawXML, `ID="_req"`)
+	assert.NotContains(t, info.RawXML, "Envelope")
+}
+
+func TestRawElements(t *testing.T) {
+	root, assertion := rawElements([]byte(`<x:Root xmlns:x="urn:x"><x:Child/><Assertion xmlns="urn:oasis:names:tc:SAML:2.0:assertion"><Assertion/></Assertion></x:Root>`))
+	assert.Equal(t, `<x:Root xmlns:x="urn:x"><x:Child/><Assertion xmlns="urn:oasis:names:tc:SAML:2.0:assertion"><Assertion/></Assertion></x:Root>`, string(root))
+	assert.Equal(t, `<Assertion xmlns="urn:oasis:names:tc:SAML:2.0:assertion"><Assertion/></Assertion>`, string(assertion))
+
+	root, assertion = rawElements([]byte(`<Assertion xmlns="urn:oasis:names:tc:SAML:2.0:assertion" ID="_a"/>`))
+	assert.Equal(t, `<Assertion xmlns="urn:oasis:names:tc:SAML:2.0:assertion" ID="_a"/>`, string(root))
+	assert.Equal(t, string(root), string(assertion))
+
+	root, assertion = rawElements([]byte(`<unclosed>`))
+	assert.Nil(t, root)
+	assert.Nil(t, assertion)
+}
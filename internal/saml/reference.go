@@ -0,0 +1,90 @@
+package saml
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/beevik/etree"
+)
+
+// ReferenceResolver returns the content a signature Reference URI outside
+// the signed document points at, for detached signatures
+type ReferenceResolver func(uri string) ([]byte, error)
+
+// DirResolver resolves references to the files in dir named like the last
+// path segment of the URI: https://example.com/docs/policy.xml and
+// cid:policy.xml both resolve to dir/policy.xml. Nothing outside dir is
+// read.
+func DirResolver(dir string) ReferenceResolver {
+	return func(uri string) ([]byte, error) {
+		name := uri
+		if u, err := url.Parse(uri); err == nil {
+			name = u.Path
+			if u.Opaque != "" {
+				name = u.Opaque
+			}
+		}
+		name = path.Base(name)
+		if name == "." || name == "/" || name == ".." || strings.ContainsRune(name, filepath.Separator) {
+			return nil, fmt.Errorf("cannot map reference %s to a file", uri)
+		}
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve reference %s: %w", uri, err)
+		}
+		return data, nil
+	}
+}
+
+// isExternalReference reports whether a Reference URI points outside the
+// signed document
+func isExternalReference(uri string) bool {
+	return uri != "" && !strings.HasPrefix(uri, "#")
+}
+
+// externalReference returns the URI of the signature of el if it covers an
+// external reference, or ""
+func externalReference(el *etree.Element) string {
+	for _, child := range el.ChildElements() {
+		if !isElement(child, XMLDSigNamespace, "Signature") {
+			continue
+		}
+		if signedInfo := dsigChild(child, "SignedInfo"); signedInfo != nil {
+			for _, ref := range dsigChildren(signedInfo, "Reference") {
+				if uri := ref.SelectAttrValue("URI", ""); isExternalReference(uri) {
+					return uri
+				}
+			}
+		}
+		return ""
+	}
+	return ""
+}
+
+// referenceContent returns the octets digested for an external reference:
+// the resolved content as is, or canonicalized by c14n if the reference
+// has a canonicalization transform
+func referenceContent(uri string, c14n *canonicalizer, resolve ReferenceResolver) ([]byte, error) {
+	if resolve == nil {
+		return nil, fmt.Errorf("signature references external %s, which is not resolved", uri)
+	}
+	data, err := resolve(uri)
+	if err != nil {
+		return nil, err
+	}
+	if c14n == nil {
+		return data, nil
+	}
+	doc, err := ReadDocument(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse reference %s: %w", uri, err)
+	}
+	if doc.Root() == nil {
+		return nil, fmt.Errorf("reference %s has no root element", uri)
+	}
+	return c14n.canonicalize(doc.Root()), nil
+}
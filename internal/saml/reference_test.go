@@ -0,0 +1,157 @@
+package saml
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/beevik/etree"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// signDetached adds a signature to el over the external content at uri,
+// digested as is, or with Exclusive Canonical XML if c14n is set
+func signDetached(t *testing.T, el *etree.Element, uri string, content []byte, key crypto.Signer, c14n bool) {
+	t.Helper()
+	if c14n {
+		doc, err := ReadDocument(content)
+		require.NoError(t, err)
+		content = (&canonicalizer{exclusive: true}).canonicalize(doc.Root())
+	}
+	h := crypto.SHA256.New()
+	h.Write(content)
+
+	sig := el.CreateElement("ds:Signature")
+	sig.CreateAttr("xmlns:ds", XMLDSigNamespace)
+	signedInfo := sig.CreateElement("ds:SignedInfo")
+	signedInfo.CreateElement("ds:CanonicalizationMethod").CreateAttr("Algorithm", ExcC14N10Algorithm)
+	signedInfo.CreateElement("ds:SignatureMethod").CreateAttr("Algorithm", SigAlgRSASHA256)
+	ref := signedInfo.CreateElement("ds:Reference")
+	ref.CreateAttr("URI", uri)
+	if c14n {
+		ref.CreateElement("ds:Transforms").CreateElement("ds:Transform").CreateAttr("Algorithm", ExcC14N10Algorithm)
+	}
+	ref.CreateElement("ds:DigestMethod").CreateAttr("Algorithm", DigestSHA256)
+	ref.CreateElement("ds:DigestValue").SetText(base64.StdEncoding.EncodeToString(h.Sum(nil)))
+
+	signature, err := signContent(key, SigAlgRSASHA256, crypto.SHA256, (&canonicalizer{exclusive: true}).canonicalize(signedInfo))
+	require.NoError(t, err)
+	sig.CreateElement("ds:SignatureValue").SetText(base64.StdEncoding.EncodeToString(signature))
+}
+
+func TestDirResolver(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "policy.xml"), []byte("<policy/>"), 0o644))
+	resolve := DirResolver(dir)
+
+	for _, uri := range []string{"https://example.com/docs/policy.xml", "cid:policy.xml", "policy.xml"} {
+		data, err := resolve(uri)
+		require.NoError(t, err, uri)
+		assert.Equal(t, "<policy/>", string(data))
+	}
+
+	_, err := resolve("https://example.com/")
+	assert.EqualError(t, err, "cannot map reference https://example.com/ to a file")
+	_, err = resolve("https://example.com/other.xml")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestVerifySignature_DetachedReference(t *testing.T) {
+	key, cert, err := NewSelfSignedKey("idp.example.com")
+	require.NoError(t, err)
+	certs := []*x509.Certificate{cert}
+	dir := t.TempDir()
+	policy := []byte("<policy xmlns=\"urn:example\"  version='1'>\n  <rule/>\n</policy>")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "policy.xml"), policy, 0o644))
+
+	tests := []struct {
+		name string
+		c14n bool
+	}{
+		{"octets", false},
+		{"exc-c14n", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			el := readElement(t, `<r ID="_r"/>`)
+			signDetached(t, el, "cid:policy.xml", policy, key, tt.c14n)
+
+			_, err := VerifySignature(el, certs)
+			assert.EqualError(t, err, "signature references external cid:policy.xml, which is not resolved")
+
+			_, err = VerifySignatureWithResolver(el, certs, DirResolver(dir))
+			require.NoError(t, err)
+
+			tampered := t.TempDir()
+			require.NoError(t, os.WriteFile(filepath.Join(tampered, "policy.xml"), []byte("<policy xmlns=\"urn:example\" version='2'/>"), 0o644))
+			_, err = VerifySignatureWithResolver(el, certs, DirResolver(tampered))
+			assert.EqualError(t, err, "digest mismatch: the signed content was modified")
+		})
+	}
+
+	// Canonicalized content verifies however it is serialized
+	el := readElement(t, `<r ID="_r"/>`)
+	signDetached(t, el, "cid:policy.xml", policy, key, true)
+	reformatted := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(reformatted, "policy.xml"), []byte("<policy version=\"1\" xmlns=\"urn:example\">\n  <rule></rule>\n</policy>"), 0o644))
+	_, err = VerifySignatureWithResolver(el, certs, DirResolver(reformatted))
+	assert.NoError(t, err)
+}
+
+func TestVerifyMessage_DetachedReference(t *testing.T) {
+	key, cert, err := NewSelfSignedKey("idp.example.com")
+	require.NoError(t, err)
+	certs := []*x509.Certificate{cert}
+	dir := t.TempDir()
+	policy := []byte("<policy/>")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "policy.xml"), policy, 0o644))
+
+	doc := etree.NewDocument()
+	doc.SetRoot(readElement(t, `<samlp:LogoutRequest xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_l"/>`))
+	signDetached(t, doc.Root(), "cid:policy.xml", policy, key, false)
+	request, err := doc.WriteToBytes()
+	require.NoError(t, err)
+
+	result := VerifyMessage(request, certs)
+	assert.Equal(t, VerdictSigFail, result.Verdict)
+	assert.ErrorContains(t, result.Err, "which is not resolved")
+
+	// The detached signature verifies, but leaves the request itself
+	// unsigned
+	result = VerifyMessageWithResolver(request, certs, DirResolver(dir))
+	assert.Equal(t, VerdictUnsigned, result.Verdict)
+	assert.NoError(t, result.Err)
+	assert.Equal(t, []string{"LogoutRequest (cid:policy.xml)"}, result.Verified)
+
+	// Nor does it cover the assertions of the message
+	response, err := BuildResponse(testResponseOptions(), time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	signDetached(t, response.Root(), "cid:policy.xml", policy, key, false)
+	data, err := response.WriteToBytes()
+	require.NoError(t, err)
+	result = VerifyMessageWithResolver(data, certs, DirResolver(dir))
+	assert.Equal(t, VerdictUnsigned, result.Verdict)
+	assert.Equal(t, []string{"Response (cid:policy.xml)"}, result.Verified)
+
+	// Next to an enveloped assertion signature, an assertion covered only
+	// by the detached one may have been wrapped
+	response, err = BuildResponse(testResponseOptions(), time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assertion := response.Root().FindElement("./Assertion")
+	require.NotNil(t, assertion)
+	wrapped := assertion.Copy()
+	wrapped.CreateAttr("ID", "_wrapped")
+	require.NoError(t, SignEnveloped(assertion, key, cert, SigAlgRSASHA256))
+	response.Root().AddChild(wrapped)
+	signDetached(t, response.Root(), "cid:policy.xml", policy, key, false)
+	data, err = response.WriteToBytes()
+	require.NoError(t, err)
+	result = VerifyMessageWithResolver(data, certs, DirResolver(dir))
+	assert.Equal(t, VerdictSigFail, result.Verdict)
+	assert.ErrorIs(t, result.Err, ErrUnsignedAssertion)
+}
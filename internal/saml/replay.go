@@ -0,0 +1,83 @@
+package saml
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+)
+
+// Replay describes a SAML message delivered again within a capture
+type Replay struct {
+	// Index is the message that repeats an earlier one
+	Index int `json:"index"`
+
+	// Of is the earlier message it repeats
+	Of int `json:"of"`
+
+	// AssertionID is set when only the assertion ID repeats, rather than
+	// the whole payload
+	AssertionID string `json:"assertion_id,omitempty"`
+}
+
+func (r Replay) String() string {
+	if r.AssertionID != "" {
+		return fmt.Sprintf("replay of message %d: assertion %s delivered again", r.Of, r.AssertionID)
+	}
+	return fmt.Sprintf("replay of message %d: identical payload delivered again", r.Of)
+}
+
+// ReplayDetector finds Responses and Assertions that are delivered more
+// than once, whether by an attacker replaying a captured response or by an
+// SP resubmitting one. Only messages sent in requests count: a response
+// that is first seen in the IdP's HTML form and then posted to the ACS is
+// delivered once.
+type ReplayDetector struct {
+	payloads   map[[sha256.Size]byte]int
+	assertions map[string]int
+}
+
+// NewReplayDetector creates a detector with no messages seen
+func NewReplayDetector() *ReplayDetector {
+	return &ReplayDetector{
+		payloads:   map[[sha256.Size]byte]int{},
+		assertions: map[string]int{},
+	}
+}
+
+// Observe records an extracted message and returns the replay it
+// constitutes, if any. info is the parsed message, which may be a partially
+// parsed envelope or nil; without it only identical payloads are detected.
+func (d *ReplayDetector) Observe(msg ExtractedSAML, info *SAMLInfo) *Replay {
+	if !strings.HasPrefix(msg.Source, "request-") || (msg.Type != "Response" && msg.Type != "Assertion") {
+		return nil
+	}
+
+	sum := sha256.Sum256(msg.DecodedXML)
+	if of, ok := d.payloads[sum]; ok {
+		return &Replay{Index: msg.Index, Of: of}
+	}
+	d.payloads[sum] = msg.Index
+
+	id := assertionID(info)
+	if id == "" {
+		return nil
+	}
+	if of, ok := d.assertions[id]; ok {
+		return &Replay{Index: msg.Index, Of: of, AssertionID: id}
+	}
+	d.assertions[id] = msg.Index
+	return nil
+}
+
+// assertionID returns the ID of the assertion a message carries
+func assertionID(info *SAMLInfo) string {
+	switch {
+	case info == nil:
+		return ""
+	case info.Type == "Assertion":
+		return info.ID
+	case info.Assertion != nil:
+		return info.Assertion.ID
+	}
+	return ""
+}
@@ -0,0 +1,40 @@
+package saml
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplayDetector(t *testing.T) {
+	response := func(index int, source, xml string) ExtractedSAML {
+		return ExtractedSAML{Index: index, Type: "Response", Source: source, DecodedXML: []byte(xml)}
+	}
+	withAssertion := func(id string) *SAMLInfo {
+		return &SAMLInfo{Type: "Response", Assertion: &SAMLInfo{Type: "Assertion", ID: id}}
+	}
+
+	d := NewReplayDetector()
+
+	// The IdP's form and the browser's POST carry the same response once
+	assert.Nil(t, d.Observe(response(1, "response-body", "<r1/>"), withAssertion("_a1")))
+	assert.Nil(t, d.Observe(response(2, "request-body", "<r1/>"), withAssertion("_a1")))
+
+	assert.Equal(t, &Replay{Index: 3, Of: 2}, d.Observe(response(3, "request-body", "<r1/>"), withAssertion("_a1")))
+
+	// A fresh response wrapping an old assertion
+	assert.Equal(t, &Replay{Index: 4, Of: 2, AssertionID: "_a1"}, d.Observe(response(4, "request-body", "<r2/>"), withAssertion("_a1")))
+
+	assert.Nil(t, d.Observe(response(5, "request-body", "<r3/>"), withAssertion("_a2")))
+
+	// Requests are resent legitimately, e.g. on retries
+	request := ExtractedSAML{Index: 6, Type: "AuthnRequest", Source: "request-query", DecodedXML: []byte("<q/>")}
+	assert.Nil(t, d.Observe(request, nil))
+	request.Index = 7
+	assert.Nil(t, d.Observe(request, nil))
+}
+
+func TestReplay_String(t *testing.T) {
+	assert.Equal(t, "replay of message 2: identical payload delivered again", Replay{Index: 3, Of: 2}.String())
+	assert.Equal(t, "replay of message 2: assertion _a1 delivered again", Replay{Index: 4, Of: 2, AssertionID: "_a1"}.String())
+}
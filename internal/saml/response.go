@@ -0,0 +1,228 @@
+package saml
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/beevik/etree"
+	"github.com/crewjam/saml/xmlenc"
+)
+
+// URIs used in the Responses built by BuildResponse
+const (
+	StatusSuccess                 = "urn:oasis:names:tc:SAML:2.0:status:Success"
+	SubjectConfirmationBearer     = "urn:oasis:names:tc:SAML:2.0:cm:bearer"
+	AuthnContextPasswordProtected = "urn:oasis:names:tc:SAML:2.0:ac:classes:PasswordProtectedTransport"
+)
+
+// Attribute NameFormats, chosen by whether the name looks like a URI
+const (
+	attrNameFormatURI         = "urn:oasis:names:tc:SAML:2.0:attrname-format:uri"
+	attrNameFormatUnspecified = "urn:oasis:names:tc:SAML:2.0:attrname-format:unspecified"
+)
+
+// defaultAssertionLifetime is how long built assertions are valid by default
+const defaultAssertionLifetime = 5 * time.Minute
+
+// ResponseOptions describes a Response with a single assertion to build
+type ResponseOptions struct {
+	// Issuer is the entity ID of the IdP
+	Issuer string
+
+	// Destination is the AssertionConsumerService URL of the SP
+	Destination string
+
+	// InResponseTo is the ID of the AuthnRequest; empty for an
+	// IdP-initiated Response
+	InResponseTo string
+
+	// Audience is the entity ID of the SP
+	Audience string
+
+	// NameID identifies the principal, in NameIDFormat if set
+	NameID       string
+	NameIDFormat string
+
+	// Attributes are sent in an AttributeStatement; names containing a
+	// colon are marked as URIs
+	Attributes []Attribute
+
+	// SessionIndex names the session; empty generates one
+	SessionIndex string
+
+	// AuthnContextClassRef defaults to PasswordProtectedTransport
+	AuthnContextClassRef string
+
+	// Lifetime is how long the assertion is valid; zero means 5 minutes
+	Lifetime time.Duration
+}
+
+// BuildResponse builds an unsigned, successful Response issued at now. The
+// Response and its Assertion have fresh IDs, so either can be signed with
+// SignEnveloped; the Assertion declares its own namespace, so it can be
+// signed and encrypted on its own.
+func BuildResponse(opts ResponseOptions, now time.Time) (*etree.Document, error) {
+	if opts.Issuer == "" {
+		return nil, errors.New("an issuer is required")
+	}
+	if opts.Destination == "" {
+		return nil, errors.New("a destination is required")
+	}
+	if opts.NameID == "" {
+		return nil, errors.New("a NameID is required")
+	}
+	responseID, err := NewID()
+	if err != nil {
+		return nil, err
+	}
+	assertionID, err := NewID()
+	if err != nil {
+		return nil, err
+	}
+	sessionIndex := opts.SessionIndex
+	if sessionIndex == "" {
+		if sessionIndex, err = NewID(); err != nil {
+			return nil, err
+		}
+	}
+	lifetime := opts.Lifetime
+	if lifetime == 0 {
+		lifetime = defaultAssertionLifetime
+	}
+	contextClass := opts.AuthnContextClassRef
+	if contextClass == "" {
+		contextClass = AuthnContextPasswordProtected
+	}
+	issueInstant := now.UTC().Format(time.RFC3339)
+	notOnOrAfter := now.Add(lifetime).UTC().Format(time.RFC3339)
+
+	doc := etree.NewDocument()
+	doc.CreateProcInst("xml", `version="1.0" encoding="UTF-8"`)
+	resp := doc.CreateElement("samlp:Response")
+	resp.CreateAttr("xmlns:samlp", SAMLPNamespace)
+	resp.CreateAttr("xmlns:saml", SAMLNamespace)
+	resp.CreateAttr("ID", responseID)
+	resp.CreateAttr("Version", "2.0")
+	resp.CreateAttr("IssueInstant", issueInstant)
+	resp.CreateAttr("Destination", opts.Destination)
+	if opts.InResponseTo != "" {
+		resp.CreateAttr("InResponseTo", opts.InResponseTo)
+	}
+	resp.CreateElement("saml:Issuer").SetText(opts.Issuer)
+	resp.CreateElement("samlp:Status").CreateElement("samlp:StatusCode").CreateAttr("Value", StatusSuccess)
+
+	// Elements follow the order of the assertion schema
+	assertion := resp.CreateElement("saml:Assertion")
+	assertion.CreateAttr("xmlns:saml", SAMLNamespace)
+	assertion.CreateAttr("ID", assertionID)
+	assertion.CreateAttr("Version", "2.0")
+	assertion.CreateAttr("IssueInstant", issueInstant)
+	assertion.CreateElement("saml:Issuer").SetText(opts.Issuer)
+
+	subject := assertion.CreateElement("saml:Subject")
+	nameID := subject.CreateElement("saml:NameID")
+	if opts.NameIDFormat != "" {
+		nameID.CreateAttr("Format", opts.NameIDFormat)
+	}
+	if opts.Audience != "" {
+		nameID.CreateAttr("SPNameQualifier", opts.Audience)
+	}
+	nameID.SetText(opts.NameID)
+	confirmation := subject.CreateElement("saml:SubjectConfirmation")
+	confirmation.CreateAttr("Method", SubjectConfirmationBearer)
+	data := confirmation.CreateElement("saml:SubjectConfirmationData")
+	if opts.InResponseTo != "" {
+		data.CreateAttr("InResponseTo", opts.InResponseTo)
+	}
+	data.CreateAttr("NotOnOrAfter", notOnOrAfter)
+	data.CreateAttr("Recipient", opts.Destination)
+
+	conditions := assertion.CreateElement("saml:Conditions")
+	conditions.CreateAttr("NotBefore", issueInstant)
+	conditions.CreateAttr("NotOnOrAfter", notOnOrAfter)
+	if opts.Audience != "" {
+		conditions.CreateElement("saml:AudienceRestriction").CreateElement("saml:Audience").SetText(opts.Audience)
+	}
+
+	authn := assertion.CreateElement("saml:AuthnStatement")
+	authn.CreateAttr("AuthnInstant", issueInstant)
+	authn.CreateAttr("SessionIndex", sessionIndex)
+	authn.CreateElement("saml:AuthnContext").CreateElement("saml:AuthnContextClassRef").SetText(contextClass)
+
+	if len(opts.Attributes) > 0 {
+		statement := assertion.CreateElement("saml:AttributeStatement")
+		for _, attr := range opts.Attributes {
+			el := statement.CreateElement("saml:Attribute")
+			el.CreateAttr("Name", attr.Name)
+			el.CreateAttr("NameFormat", attributeNameFormat(attr))
+			if attr.FriendlyName != "" {
+				el.CreateAttr("FriendlyName", attr.FriendlyName)
+			}
+			for _, value := range attr.Values {
+				el.CreateElement("saml:AttributeValue").SetText(value)
+			}
+		}
+	}
+
+	doc.Indent(2)
+	return doc, nil
+}
+
+// attributeNameFormat returns the NameFormat of attr: its own, or uri for
+// names such as OIDs and URNs and unspecified otherwise
+func attributeNameFormat(attr Attribute) string {
+	switch {
+	case attr.NameFormat != "":
+		return attr.NameFormat
+	case strings.Contains(attr.Name, ":"):
+		return attrNameFormatURI
+	}
+	return attrNameFormatUnspecified
+}
+
+// ResponseAssertion returns the Assertion of a Response built by
+// BuildResponse
+func ResponseAssertion(resp *etree.Element) *etree.Element {
+	for _, child := range resp.ChildElements() {
+		if isElement(child, SAMLNamespace, "Assertion") {
+			return child
+		}
+	}
+	return nil
+}
+
+// EncryptAssertion replaces the Assertion of resp with an
+// EncryptedAssertion for the holder of cert, using RSA-OAEP and AES-256-CBC.
+// To send a signed and encrypted assertion, sign the assertion first.
+func EncryptAssertion(resp *etree.Element, cert *x509.Certificate) error {
+	assertion := ResponseAssertion(resp)
+	if assertion == nil {
+		return errors.New("response has no assertion to encrypt")
+	}
+
+	plain := etree.NewDocument()
+	plain.SetRoot(assertion.Copy())
+	plaintext, err := plain.WriteToBytes()
+	if err != nil {
+		return fmt.Errorf("failed to serialize assertion: %w", err)
+	}
+
+	// xmlenc's GCM mode does not encrypt correctly, so CBC it is
+	encrypter := xmlenc.OAEP()
+	encrypter.BlockCipher = xmlenc.AES256CBC
+	encryptedData, err := encrypter.Encrypt(cert, plaintext, nil)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt assertion: %w", err)
+	}
+	encryptedData.CreateAttr("Type", "http://www.w3.org/2001/04/xmlenc#Element")
+
+	encrypted := etree.NewElement("saml:EncryptedAssertion")
+	encrypted.AddChild(encryptedData)
+	index := assertion.Index()
+	resp.RemoveChildAt(index)
+	resp.InsertChildAt(index, encrypted)
+	return nil
+}
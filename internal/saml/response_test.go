@@ -0,0 +1,96 @@
+package saml
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/beevik/etree"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testResponseOptions() ResponseOptions {
+	return ResponseOptions{
+		Issuer:       "https://idp.example.com",
+		Destination:  "https://sp.example.com/acs",
+		InResponseTo: "_req1",
+		Audience:     "https://sp.example.com",
+		NameID:       "alice@example.com",
+		NameIDFormat: "urn:oasis:names:tc:SAML:1.1:nameid-format:emailAddress",
+		Attributes: []Attribute{
+			{Name: "mail", Values: []string{"alice@example.com"}},
+			{Name: "urn:oid:1.3.6.1.4.1.5923.1.1.1.7", Values: []string{"staff", "admin"}},
+		},
+	}
+}
+
+func TestBuildResponse(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	doc, err := BuildResponse(testResponseOptions(), now)
+	require.NoError(t, err)
+	xmlData, err := doc.WriteToBytes()
+	require.NoError(t, err)
+
+	info, err := NewParser().Parse(xmlData)
+	require.NoError(t, err)
+	assert.Equal(t, "Response", info.Type)
+	assert.Equal(t, "https://sp.example.com/acs", info.Destination)
+	assert.Equal(t, "_req1", info.InResponseTo)
+	assert.Equal(t, "Success", info.Status.StatusCode)
+	require.NotNil(t, info.Assertion)
+	assert.Equal(t, "alice@example.com", info.Assertion.Subject.NameID)
+	assert.Equal(t, "https://sp.example.com/acs", info.Assertion.Subject.Recipient)
+	assert.Equal(t, []string{"https://sp.example.com"}, info.Assertion.Conditions.AudienceRestriction)
+	assert.Equal(t, now.Add(5*time.Minute), *info.Assertion.Conditions.NotOnOrAfter)
+	assert.Equal(t, AuthnContextPasswordProtected, info.Assertion.AuthnStatement.AuthnContextClassRef)
+	require.Len(t, info.Assertion.Attributes, 2)
+	assert.Equal(t, attrNameFormatUnspecified, info.Assertion.Attributes[0].NameFormat)
+	assert.Equal(t, attrNameFormatURI, info.Assertion.Attributes[1].NameFormat)
+	assert.Equal(t, []string{"staff", "admin"}, info.Assertion.Attributes[1].Values)
+}
+
+func TestBuildResponse_Invalid(t *testing.T) {
+	opts := testResponseOptions()
+	opts.Destination = ""
+	_, err := BuildResponse(opts, time.Now())
+	assert.ErrorContains(t, err, "destination is required")
+}
+
+func TestBuildResponse_SignAndEncrypt(t *testing.T) {
+	idpKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	idpCert := selfSignedCert(t, idpKey)
+	spKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	doc, err := BuildResponse(testResponseOptions(), time.Now())
+	require.NoError(t, err)
+	resp := doc.Root()
+	require.NoError(t, SignEnveloped(ResponseAssertion(resp), idpKey, idpCert, SigAlgRSASHA256))
+	require.NoError(t, EncryptAssertion(resp, selfSignedCert(t, spKey)))
+	require.NoError(t, SignEnveloped(resp, idpKey, idpCert, SigAlgRSASHA256))
+	assert.Nil(t, ResponseAssertion(resp))
+
+	xmlData, err := doc.WriteToBytes()
+	require.NoError(t, err)
+	assert.True(t, IsEncrypted(xmlData))
+
+	parsed := etree.NewDocument()
+	require.NoError(t, parsed.ReadFromBytes(xmlData))
+	_, err = VerifySignature(parsed.Root(), []*x509.Certificate{idpCert})
+	require.NoError(t, err)
+
+	decryptor, err := NewDecryptorFromPEM(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(spKey)}))
+	require.NoError(t, err)
+	decrypted, err := decryptor.Decrypt(xmlData)
+	require.NoError(t, err)
+
+	assertion := etree.NewDocument()
+	require.NoError(t, assertion.ReadFromBytes(decrypted))
+	_, err = VerifySignature(assertion.Root(), []*x509.Certificate{idpCert})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,109 @@
+package saml
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/beevik/etree"
+)
+
+// RewriteOptions selects what Rewrite changes; zero values leave a field
+// as it is
+type RewriteOptions struct {
+	// IssueInstant replaces the IssueInstant of the message and its
+	// assertions
+	IssueInstant time.Time
+
+	// NotOnOrAfter replaces the end of the validity window in Conditions
+	// and SubjectConfirmationData
+	NotOnOrAfter time.Time
+
+	// NewIDs gives the message and its assertions fresh IDs, so an SP that
+	// remembers IDs does not recognise them
+	NewIDs bool
+}
+
+// rewriteIDElements are the elements whose ID Rewrite replaces
+var rewriteIDElements = map[string]bool{
+	"Response":  true,
+	"Assertion": true,
+}
+
+// Rewrite changes the IssueInstant, NotOnOrAfter and IDs of a captured
+// message and its unencrypted assertions, for replaying it against an SP.
+// Signatures over changed elements no longer verify; Reference URIs are
+// updated so they still point at the renamed elements. It returns the
+// rewritten XML and a description of each change.
+func Rewrite(xmlData []byte, opts RewriteOptions) ([]byte, []string, error) {
+	doc, err := ReadDocument(xmlData)
+	if err != nil {
+		return nil, nil, &ErrParse{Stage: "XML", Err: err}
+	}
+	root := doc.Root()
+	if root == nil {
+		return nil, nil, errors.New("no root element")
+	}
+
+	var changes []string
+	set := func(el *etree.Element, attr, value string) {
+		old := el.SelectAttrValue(attr, "")
+		if old == value {
+			return
+		}
+		el.CreateAttr(attr, value)
+		changes = append(changes, fmt.Sprintf("%s %s: %s → %s", el.Tag, attr, old, value))
+	}
+
+	renamed := map[string]string{}
+	var walk func(el *etree.Element) error
+	walk = func(el *etree.Element) error {
+		switch ns := el.NamespaceURI(); {
+		case ns == XMLDSigNamespace || el.Tag == "EncryptedAssertion":
+			return nil
+		case el.Tag == "Conditions" || el.Tag == "SubjectConfirmationData":
+			if !opts.NotOnOrAfter.IsZero() && el.SelectAttr("NotOnOrAfter") != nil {
+				set(el, "NotOnOrAfter", opts.NotOnOrAfter.UTC().Format(time.RFC3339))
+			}
+		}
+		if !opts.IssueInstant.IsZero() && el.SelectAttr("IssueInstant") != nil {
+			set(el, "IssueInstant", opts.IssueInstant.UTC().Format(time.RFC3339))
+		}
+		if opts.NewIDs && rewriteIDElements[el.Tag] {
+			if old := el.SelectAttrValue("ID", ""); old != "" {
+				id, err := NewID()
+				if err != nil {
+					return err
+				}
+				set(el, "ID", id)
+				renamed["#"+old] = "#" + id
+			}
+		}
+		for _, child := range el.ChildElements() {
+			if err := walk(child); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(root); err != nil {
+		return nil, nil, err
+	}
+	// Serializing reformats attributes, so an unchanged message is returned
+	// as captured
+	if len(changes) == 0 {
+		return xmlData, nil, nil
+	}
+
+	for _, ref := range root.FindElements("//Reference") {
+		if id, ok := renamed[ref.SelectAttrValue("URI", "")]; ok {
+			ref.CreateAttr("URI", id)
+		}
+	}
+
+	rewritten, err := doc.WriteToBytes()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to serialize XML: %w", err)
+	}
+	return rewritten, changes, nil
+}
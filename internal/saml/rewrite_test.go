@@ -0,0 +1,67 @@
+package saml
+
+import (
+	"testing"
+	"time"
+
+	"github.com/beevik/etree"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRewrite(t *testing.T) {
+	key, cert, err := NewSelfSignedKey("idp.example.com")
+	require.NoError(t, err)
+	issued := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	doc, err := BuildResponse(testResponseOptions(), issued)
+	require.NoError(t, err)
+	assertion := ResponseAssertion(doc.Root())
+	require.NoError(t, SignEnveloped(assertion, key, cert, SigAlgRSASHA256))
+	original, err := doc.WriteToBytes()
+	require.NoError(t, err)
+	before, err := NewParser().Parse(original)
+	require.NoError(t, err)
+
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	rewritten, changes, err := Rewrite(original, RewriteOptions{
+		IssueInstant: now,
+		NotOnOrAfter: now.Add(10 * time.Minute),
+		NewIDs:       true,
+	})
+	require.NoError(t, err)
+	assert.Contains(t, changes, "Response IssueInstant: 2024-05-01T12:00:00Z → 2025-01-02T03:04:05Z")
+	assert.Contains(t, changes, "Conditions NotOnOrAfter: 2024-05-01T12:05:00Z → 2025-01-02T03:14:05Z")
+	assert.Len(t, changes, 6)
+
+	after, err := NewParser().Parse(rewritten)
+	require.NoError(t, err)
+	require.NotNil(t, after.IssueInstant)
+	assert.True(t, now.Equal(*after.IssueInstant))
+	require.NotNil(t, after.Assertion.Conditions.NotOnOrAfter)
+	assert.True(t, now.Add(10*time.Minute).Equal(*after.Assertion.Conditions.NotOnOrAfter))
+	assert.NotEqual(t, before.ID, after.ID)
+	assert.NotEqual(t, before.Assertion.ID, after.Assertion.ID)
+
+	// The signature still references the assertion, but no longer verifies
+	parsed := etree.NewDocument()
+	require.NoError(t, parsed.ReadFromBytes(rewritten))
+	ref := parsed.Root().FindElement("//Reference")
+	require.NotNil(t, ref)
+	assert.Equal(t, "#"+after.Assertion.ID, ref.SelectAttrValue("URI", ""))
+	assert.Equal(t, VerdictSigFail, VerifyMessage(rewritten, nil).Verdict)
+}
+
+func TestRewrite_Unchanged(t *testing.T) {
+	doc, err := BuildResponse(testResponseOptions(), time.Now())
+	require.NoError(t, err)
+	original, err := doc.WriteToBytes()
+	require.NoError(t, err)
+
+	rewritten, changes, err := Rewrite(original, RewriteOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+	assert.Equal(t, string(original), string(rewritten))
+
+	_, _, err = Rewrite([]byte("not xml"), RewriteOptions{})
+	assert.Error(t, err)
+}
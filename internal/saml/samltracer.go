@@ -0,0 +1,145 @@
+package saml
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// SAMLTracerExport represents the JSON export of the SAML-tracer browser
+// extension
+type SAMLTracerExport struct {
+	Requests []SAMLTracerRequest `json:"requests"`
+}
+
+// SAMLTracerRequest represents a single captured request. GET and POST
+// parameters are exported as [name, value] pairs with decoded values.
+type SAMLTracerRequest struct {
+	Method   string     `json:"method"`
+	URL      string     `json:"url"`
+	Protocol string     `json:"protocol,omitempty"`
+	Get      [][]string `json:"get,omitempty"`
+	Post     [][]string `json:"post,omitempty"`
+
+	// SAML is the decoded message as shown by the extension
+	SAML string `json:"saml,omitempty"`
+
+	// Headers and status as reported to the extension by the browser
+	RequestHeaders  []HARNameValue `json:"requestHeaders,omitempty"`
+	ResponseStatus  int            `json:"responseStatus,omitempty"`
+	ResponseHeaders []HARNameValue `json:"responseHeaders,omitempty"`
+}
+
+// LooksLikeSAMLTracer checks if the content has the JSON structure of a
+// SAML-tracer export
+func LooksLikeSAMLTracer(content string) bool {
+	trimmed := strings.TrimSpace(content)
+	return strings.HasPrefix(trimmed, "{") && strings.Contains(trimmed, `"requests"`) && !LooksLikeHAR(trimmed)
+}
+
+// ExtractFromSAMLTracer extracts all SAML assertions from a SAML-tracer
+// export. Requests are mapped onto HAR entries so the same extraction rules
+// apply to both formats.
+func (e *HARExtractor) ExtractFromSAMLTracer(data []byte) ([]ExtractedSAML, error) {
+	var export SAMLTracerExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, fmt.Errorf("failed to parse SAML-tracer export: %w", err)
+	}
+
+	index := 1
+	return e.extractFromTracerRequests(func(emit func(SAMLTracerRequest) error) error {
+		for _, req := range export.Requests {
+			if err := emit(req); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, &index)
+}
+
+// extractFromTracerRequests extracts SAML from the requests passed to emit
+// by walk, in order. As with HAR entries, a message in a redirect that the
+// export also shows being followed is listed once.
+func (e *HARExtractor) extractFromTracerRequests(walk func(emit func(SAMLTracerRequest) error) error, index *int) ([]ExtractedSAML, error) {
+	var results []ExtractedSAML
+	local := 1
+	err := walk(func(req SAMLTracerRequest) error {
+		if err := contextErr(e.ctx); err != nil {
+			return err
+		}
+		results = append(results, e.extractFromTracerRequest(req, &local)...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	results = dropFollowedRedirects(results)
+	for i := range results {
+		results[i].Index = *index
+		*index++
+	}
+	return results, nil
+}
+
+// extractFromTracerRequest extracts the SAML messages of a single request
+func (e *HARExtractor) extractFromTracerRequest(req SAMLTracerRequest, index *int) []ExtractedSAML {
+	extracted := e.extractFromEntries([]HAREntry{req.toHAREntry()}, index)
+
+	// Fall back to the decoded message, e.g. for SOAP bindings where
+	// nothing was carried in a parameter
+	if len(extracted) == 0 && req.SAML != "" {
+		xmlData := []byte(strings.TrimSpace(req.SAML))
+		if e.isSAMLXML(xmlData) {
+			extracted = append(extracted, ExtractedSAML{
+				Index:      *index,
+				Type:       e.detectSAMLType(xmlData),
+				Source:     "saml-tracer",
+				URL:        req.URL,
+				DecodedXML: xmlData,
+				Confidence: e.scoreConfidence("", xmlData),
+			})
+			*index++
+		}
+	}
+	return extracted
+}
+
+// toHAREntry converts the request into an equivalent HAR entry. Parameter
+// values are escaped again, as the HAR extraction unescapes them.
+func (r SAMLTracerRequest) toHAREntry() HAREntry {
+	entry := HAREntry{
+		Request: HARRequest{
+			Method:  r.Method,
+			URL:     r.URL,
+			Headers: r.RequestHeaders,
+		},
+		Response: HARResponse{
+			Status:  r.ResponseStatus,
+			Headers: r.ResponseHeaders,
+		},
+	}
+
+	// GET parameters still in the URL are already picked up from there
+	var inURL url.Values
+	if u, err := url.Parse(r.URL); err == nil {
+		inURL = u.Query()
+	}
+
+	for _, pair := range r.Get {
+		if len(pair) == 2 && !inURL.Has(pair[0]) {
+			entry.Request.QueryString = append(entry.Request.QueryString, HARNameValue{Name: pair[0], Value: url.QueryEscape(pair[1])})
+		}
+	}
+
+	if len(r.Post) > 0 {
+		entry.Request.PostData = &HARPostData{}
+		for _, pair := range r.Post {
+			if len(pair) == 2 {
+				entry.Request.PostData.Params = append(entry.Request.PostData.Params, HARNameValue{Name: pair[0], Value: url.QueryEscape(pair[1])})
+			}
+		}
+	}
+
+	return entry
+}
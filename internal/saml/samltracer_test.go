@@ -0,0 +1,131 @@
+package saml
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestHARExtractor_ExtractFromSAMLTracer(t *testing.T) {
+	extractor := NewHARExtractor()
+
+	samlRequest := `<samlp:AuthnRequest xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_req1"/>`
+	samlResponse := `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_resp1"><saml:Issuer xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion">https://idp.example.com/?a=b&c=d</saml:Issuer></samlp:Response>`
+	logoutRequest := `<samlp:LogoutRequest xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_logout1"/>`
+
+	encodedRequest := base64.StdEncoding.EncodeToString([]byte(samlRequest))
+	encodedResponse := base64.StdEncoding.EncodeToString([]byte(samlResponse))
+
+	export := SAMLTracerExport{Requests: []SAMLTracerRequest{
+		{Method: "GET", URL: "https://idp.example.com/sso", Get: [][]string{{"SAMLRequest", encodedRequest}, {"RelayState", "abc"}}},
+		{Method: "POST", URL: "https://sp.example.com/acs", Post: [][]string{{"SAMLResponse", encodedResponse}}},
+		{Method: "GET", URL: "https://sp.example.com/"},
+		{Method: "POST", URL: "https://idp.example.com/slo", SAML: logoutRequest},
+	}}
+	data, err := json.Marshal(export)
+	if err != nil {
+		t.Fatalf("failed to marshal export: %v", err)
+	}
+
+	if !LooksLikeSAMLTracer(string(data)) {
+		t.Fatal("export not detected as SAML-tracer")
+	}
+	if LooksLikeHAR(string(data)) {
+		t.Fatal("export detected as HAR")
+	}
+
+	results, err := extractor.Extract(data)
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(results))
+	}
+
+	want := []struct {
+		index  int
+		typ    string
+		source string
+		xml    string
+	}{
+		{1, "AuthnRequest", "request-query", samlRequest},
+		{2, "Response", "request-body", samlResponse},
+		{3, "LogoutRequest", "saml-tracer", logoutRequest},
+	}
+	for i, w := range want {
+		r := results[i]
+		if r.Index != w.index || r.Source != w.source {
+			t.Errorf("result %d: got index %d source %q, want %d %q", i, r.Index, r.Source, w.index, w.source)
+		}
+		if r.Type != w.typ {
+			t.Errorf("result %d: got type %q, want %q", i, r.Type, w.typ)
+		}
+		if string(r.DecodedXML) != w.xml {
+			t.Errorf("result %d: decoded XML mismatch: %s", i, r.DecodedXML)
+		}
+	}
+}
+
+func TestHARExtractor_ExtractFromSAMLTracer_GetInURL(t *testing.T) {
+	extractor := NewHARExtractor()
+
+	encoded := base64.StdEncoding.EncodeToString([]byte(`<samlp:AuthnRequest xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_req1"/>`))
+	data := `{"requests": [{"method": "GET", "url": "https://idp.example.com/sso?SAMLRequest=` + encoded + `", "get": [["SAMLRequest", "` + encoded + `"]]}]}`
+
+	results, err := extractor.ExtractFromSAMLTracer([]byte(data))
+	if err != nil {
+		t.Fatalf("ExtractFromSAMLTracer failed: %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("expected parameter in URL to be extracted once, got %d", len(results))
+	}
+}
+
+func TestHARExtractor_ExtractFromSAMLTracer_Invalid(t *testing.T) {
+	if _, err := NewHARExtractor().ExtractFromSAMLTracer([]byte(`{"requests": `)); err == nil {
+		t.Error("expected error for invalid JSON")
+	}
+}
+
+func TestHARExtractor_ExtractFromSAMLTracer_Redirects(t *testing.T) {
+	samlRequest := `<samlp:AuthnRequest xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_req1"/>`
+	query := url.Values{"SAMLRequest": {base64.StdEncoding.EncodeToString([]byte(samlRequest))}}.Encode()
+
+	// The SP redirect is exported with its response headers, as the
+	// extension records them
+	redirect := `{"method": "GET", "url": "https://sp.example.com/login", "requestHeaders": [{"name": "Host", "value": "sp.example.com"}],
+		"responseStatus": 302, "responseStatusText": "Found", "responseHeaders": [{"name": "Location", "value": "https://idp.example.com/sso?` + query + `"}]}`
+	followed := `{"method": "GET", "url": "https://idp.example.com/sso?` + query + `"}`
+
+	tests := []struct {
+		name     string
+		requests string
+		source   string
+	}{
+		{"redirect not captured", redirect, "response-location"},
+		{"redirect followed", redirect + ", " + followed, "request-query"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := `{"requests": [` + tt.requests + `], "timestamp": "2024-01-15T10:00:00.000Z", "options": {}}`
+			for _, extract := range []func() ([]ExtractedSAML, error){
+				func() ([]ExtractedSAML, error) { return NewHARExtractor().ExtractFromSAMLTracer([]byte(data)) },
+				func() ([]ExtractedSAML, error) { return NewHARExtractor().ExtractReader(strings.NewReader(data)) },
+			} {
+				results, err := extract()
+				if err != nil {
+					t.Fatalf("extraction failed: %v", err)
+				}
+				if len(results) != 1 {
+					t.Fatalf("expected the AuthnRequest once, got %d results", len(results))
+				}
+				if results[0].Source != tt.source || results[0].Index != 1 {
+					t.Errorf("got source %q index %d, want %q 1", results[0].Source, results[0].Index, tt.source)
+				}
+			}
+		})
+	}
+}
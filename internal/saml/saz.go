@@ -0,0 +1,211 @@
+package saml
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// zipMagic starts every zip archive, and so every Fiddler SAZ file
+var zipMagic = []byte("PK\x03\x04")
+
+// LooksLikeSAZ checks if data is a zip archive, as Fiddler session archives
+// (.saz) are
+func LooksLikeSAZ(data []byte) bool {
+	return bytes.HasPrefix(data, zipMagic)
+}
+
+// sazSession holds the files of one session of a SAZ archive: the raw
+// request (NN_c.txt), the raw response (NN_s.txt) and the metadata with
+// its timers (NN_m.xml)
+type sazSession struct {
+	number                      int
+	request, response, metadata *zip.File
+}
+
+// sazMetadata is the part of a session's NN_m.xml that is used
+type sazMetadata struct {
+	Timers struct {
+		ClientBeginRequest string `xml:"ClientBeginRequest,attr"`
+		ClientDoneResponse string `xml:"ClientDoneResponse,attr"`
+	} `xml:"SessionTimers"`
+}
+
+// ExtractFromSAZ extracts all SAML messages from a Fiddler session archive.
+// Sessions are mapped onto HAR entries, so the same extraction rules apply
+// to both formats. Tunnels (CONNECT) and sessions whose request cannot be
+// parsed are skipped.
+func (e *HARExtractor) ExtractFromSAZ(data []byte) ([]ExtractedSAML, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SAZ archive: %w", err)
+	}
+	sessions := sazSessions(archive)
+	if len(sessions) == 0 {
+		return nil, errors.New("failed to read SAZ archive: the zip archive holds no Fiddler sessions")
+	}
+
+	index := 1
+	return e.extractConcurrently(func(emit func(HAREntry) error) error {
+		for _, session := range sessions {
+			entry, ok := session.toHAREntry()
+			if !ok {
+				continue
+			}
+			if err := emit(entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, &index)
+}
+
+// sazSessions collects the sessions of an archive in capture order
+func sazSessions(archive *zip.Reader) []*sazSession {
+	byNumber := make(map[int]*sazSession)
+	for _, f := range archive.File {
+		dir, name := path.Split(f.Name)
+		if !strings.EqualFold(dir, "raw/") {
+			continue
+		}
+		prefix, kind, ok := strings.Cut(name, "_")
+		number, err := strconv.Atoi(prefix)
+		if !ok || err != nil {
+			continue
+		}
+		session := byNumber[number]
+		if session == nil {
+			session = &sazSession{number: number}
+			byNumber[number] = session
+		}
+		switch strings.ToLower(kind) {
+		case "c.txt":
+			session.request = f
+		case "s.txt":
+			session.response = f
+		case "m.xml":
+			session.metadata = f
+		}
+	}
+
+	sessions := make([]*sazSession, 0, len(byNumber))
+	for _, session := range byNumber {
+		if session.request != nil {
+			sessions = append(sessions, session)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].number < sessions[j].number })
+	return sessions
+}
+
+// toHAREntry converts the session into an equivalent HAR entry
+func (s *sazSession) toHAREntry() (HAREntry, bool) {
+	raw, err := readZipFile(s.request)
+	if err != nil {
+		return HAREntry{}, false
+	}
+	req, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(raw)))
+	if err != nil || req.Method == http.MethodConnect {
+		return HAREntry{}, false
+	}
+	// Fiddler records bodies as sent; one cut short is still searched
+	body, _ := io.ReadAll(req.Body)
+
+	entry := HAREntry{
+		Request: HARRequest{
+			Method:  req.Method,
+			URL:     sazRequestURL(req),
+			Headers: harHeaders(req.Header),
+		},
+	}
+	if len(body) > 0 {
+		entry.Request.PostData = &HARPostData{MimeType: req.Header.Get("Content-Type"), Text: string(body)}
+	}
+
+	if s.response != nil {
+		if raw, err := readZipFile(s.response); err == nil {
+			entry.Response = sazResponse(raw, req)
+		}
+	}
+
+	if s.metadata != nil {
+		if raw, err := readZipFile(s.metadata); err == nil {
+			var meta sazMetadata
+			if xml.Unmarshal(raw, &meta) == nil {
+				entry.StartedDateTime = meta.Timers.ClientBeginRequest
+				if begin, done := parseHARTime(meta.Timers.ClientBeginRequest), parseHARTime(meta.Timers.ClientDoneResponse); begin != nil && done != nil {
+					entry.Time = float64(done.Sub(*begin).Microseconds()) / 1000
+				}
+			}
+		}
+	}
+	return entry, true
+}
+
+// sazRequestURL returns the absolute URL of a request. Fiddler records the
+// absolute form for proxied requests, and the path for others.
+func sazRequestURL(req *http.Request) string {
+	if req.URL.IsAbs() {
+		return req.URL.String()
+	}
+	u := *req.URL
+	u.Scheme = "https"
+	u.Host = req.Host
+	return u.String()
+}
+
+// sazResponse converts a raw HTTP response. Chunked bodies are reassembled
+// and gzip or zstd compressed ones decompressed.
+func sazResponse(raw []byte, req *http.Request) HARResponse {
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(raw)), req)
+	if err != nil {
+		return HARResponse{}
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if IsCompressed(body) {
+		if decompressed, err := decompressLimited(body, DefaultMaxBodySize); err == nil {
+			body = decompressed
+		}
+	}
+	return HARResponse{
+		Status:  resp.StatusCode,
+		Headers: harHeaders(resp.Header),
+		Content: HARContent{MimeType: resp.Header.Get("Content-Type"), Text: string(body)},
+	}
+}
+
+// harHeaders converts HTTP headers into HAR name-value pairs
+func harHeaders(header http.Header) []HARNameValue {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var headers []HARNameValue
+	for _, name := range names {
+		for _, value := range header[name] {
+			headers = append(headers, HARNameValue{Name: name, Value: value})
+		}
+	}
+	return headers
+}
+
+func readZipFile(f *zip.File) ([]byte, error) {
+	r, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
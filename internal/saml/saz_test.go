@@ -0,0 +1,107 @@
+package saml
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sazArchive builds a Fiddler session archive from raw files keyed by name
+func sazArchive(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, err := w.Create(name)
+		require.NoError(t, err)
+		_, err = f.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestHARExtractor_ExtractFromSAZ(t *testing.T) {
+	response := `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_saz"/>`
+	form := "SAMLResponse=" + url.QueryEscape(base64.StdEncoding.EncodeToString([]byte(response))) + "&RelayState=abc"
+	request := `<samlp:AuthnRequest xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_sazreq"/>`
+	html := `<html><body><form method="post" action="https://idp.example.com/sso"><input type="hidden" name="SAMLRequest" value="` +
+		base64.StdEncoding.EncodeToString([]byte(request)) + `"/></form></body></html>`
+
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	_, err := zw.Write([]byte(html))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	chunked := fmt.Sprintf("%x\r\n%s\r\n0\r\n\r\n", gz.Len(), gz.String())
+
+	archive := sazArchive(t, map[string]string{
+		"[Content_Types].xml": `<?xml version="1.0"?><Types/>`,
+		"raw/01_c.txt":        "CONNECT sp.example.com:443 HTTP/1.1\r\nHost: sp.example.com:443\r\n\r\n",
+		"raw/01_s.txt":        "HTTP/1.1 200 Connection Established\r\n\r\n",
+		"raw/2_c.txt":         "GET https://sp.example.com/login HTTP/1.1\r\nHost: sp.example.com\r\n\r\n",
+		"raw/2_s.txt": "HTTP/1.1 200 OK\r\nContent-Type: text/html\r\nContent-Encoding: gzip\r\nTransfer-Encoding: chunked\r\n\r\n" +
+			chunked,
+		"raw/10_c.txt": "POST https://sp.example.com/acs HTTP/1.1\r\nHost: sp.example.com\r\nContent-Type: application/x-www-form-urlencoded\r\n" +
+			fmt.Sprintf("Content-Length: %d\r\n\r\n", len(form)) + form,
+		"raw/10_s.txt": "HTTP/1.1 302 Found\r\nLocation: https://sp.example.com/app\r\nContent-Length: 0\r\n\r\n",
+		"raw/10_m.xml": `<?xml version="1.0" encoding="utf-8"?><Session SID="10"><SessionTimers ClientConnected="2024-01-15T10:00:00.0000000+00:00" ` +
+			`ClientBeginRequest="2024-01-15T10:00:01.2500000+00:00" ClientDoneResponse="2024-01-15T10:00:01.5000000+00:00"/></Session>`,
+	})
+	require.True(t, LooksLikeSAZ(archive))
+
+	results, err := NewHARExtractor().Extract(archive)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+
+	// Sessions are read in capture order, not in the order of the zip
+	assert.Equal(t, "AuthnRequest", results[0].Type)
+	assert.Equal(t, "response-body", results[0].Source)
+	assert.Equal(t, "https://sp.example.com/login", results[0].URL)
+	assert.Nil(t, results[0].StartedAt)
+
+	assert.Equal(t, "Response", results[1].Type)
+	assert.Equal(t, "request-body", results[1].Source)
+	assert.Equal(t, "SAMLResponse", results[1].ParameterName)
+	assert.Equal(t, "https://sp.example.com/acs", results[1].URL)
+	require.NotNil(t, results[1].StartedAt)
+	assert.True(t, time.Date(2024, 1, 15, 10, 0, 1, 250000000, time.UTC).Equal(*results[1].StartedAt))
+
+	// Archives are detected when streamed, too
+	streamed, err := NewHARExtractor().ExtractReader(bytes.NewReader(archive))
+	require.NoError(t, err)
+	assert.Len(t, streamed, 2)
+}
+
+func TestHARExtractor_ExtractFromSAZ_OriginForm(t *testing.T) {
+	response := `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_saz"/>`
+	query := url.Values{"SAMLResponse": {base64.StdEncoding.EncodeToString([]byte(response))}}.Encode()
+
+	archive := sazArchive(t, map[string]string{
+		"raw/1_c.txt": "GET /acs?" + query + " HTTP/1.1\r\nHost: sp.example.com\r\n\r\n",
+	})
+
+	results, err := NewHARExtractor().ExtractFromSAZ(archive)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "request-query", results[0].Source)
+	assert.True(t, strings.HasPrefix(results[0].URL, "https://sp.example.com/acs?"))
+}
+
+func TestHARExtractor_ExtractFromSAZ_Invalid(t *testing.T) {
+	_, err := NewHARExtractor().ExtractFromSAZ([]byte("PK\x03\x04 truncated"))
+	assert.Error(t, err)
+
+	_, err = NewHARExtractor().ExtractFromSAZ(sazArchive(t, map[string]string{"readme.txt": "not a session"}))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no Fiddler sessions")
+}
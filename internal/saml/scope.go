@@ -0,0 +1,114 @@
+package saml
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/beevik/etree"
+)
+
+// ShibMDNamespace is the namespace of the Shibboleth metadata extensions,
+// which include the Scope an IdP may assert
+const ShibMDNamespace = "urn:mace:shibboleth:metadata:1.0"
+
+// Scope is a shibmd:Scope of IdP metadata: a domain, or a regular
+// expression if Regexp is set, that scoped attribute values from the IdP
+// may end in
+type Scope struct {
+	Value  string `json:"value"`
+	Regexp bool   `json:"regexp,omitempty"`
+}
+
+// Matches reports whether scope is permitted. Domains are compared
+// case-insensitively; regular expressions must match the whole scope.
+func (s Scope) Matches(scope string) bool {
+	if !s.Regexp {
+		return strings.EqualFold(s.Value, scope)
+	}
+	re, err := regexp.Compile("^(?:" + s.Value + ")$")
+	if err != nil {
+		return false
+	}
+	return re.MatchString(scope)
+}
+
+func (s Scope) String() string {
+	if s.Regexp {
+		return "/" + s.Value + "/"
+	}
+	return s.Value
+}
+
+// MetadataScopes returns the shibmd:Scope extensions of an entity's
+// metadata, from the EntityDescriptor and its IdP and attribute authority
+// roles. Metadata without them yields no scopes, permitting none.
+func MetadataScopes(entity []byte) ([]Scope, error) {
+	doc, err := ReadDocument(entity)
+	if err != nil {
+		return nil, &ErrParse{Stage: "metadata", Err: err}
+	}
+	root := doc.Root()
+	if root == nil || !isElement(root, MetadataNamespace, "EntityDescriptor") {
+		return nil, fmt.Errorf("metadata is not an EntityDescriptor")
+	}
+
+	holders := []*etree.Element{root}
+	for _, child := range root.ChildElements() {
+		if isElement(child, MetadataNamespace, "IDPSSODescriptor") || isElement(child, MetadataNamespace, "AttributeAuthorityDescriptor") {
+			holders = append(holders, child)
+		}
+	}
+	scopes := []Scope{}
+	seen := map[Scope]bool{}
+	for _, holder := range holders {
+		for _, ext := range holder.ChildElements() {
+			if !isElement(ext, MetadataNamespace, "Extensions") {
+				continue
+			}
+			for _, el := range ext.ChildElements() {
+				if !isElement(el, ShibMDNamespace, "Scope") {
+					continue
+				}
+				scope := Scope{
+					Value:  strings.TrimSpace(el.Text()),
+					Regexp: el.SelectAttrValue("regexp", "false") == "true",
+				}
+				if !seen[scope] {
+					seen[scope] = true
+					scopes = append(scopes, scope)
+				}
+			}
+		}
+	}
+	return scopes, nil
+}
+
+// scopedAttributes are the attributes whose values are scoped, value@scope,
+// by their URI names and the legacy names used with SAML 1.1
+var scopedAttributes = map[string]string{
+	"urn:oid:1.3.6.1.4.1.5923.1.1.1.6":                      "eduPersonPrincipalName",
+	"urn:oid:1.3.6.1.4.1.5923.1.1.1.9":                      "eduPersonScopedAffiliation",
+	"urn:oid:1.3.6.1.4.1.5923.1.1.1.13":                     "eduPersonUniqueId",
+	"urn:oasis:names:tc:SAML:attribute:subject-id":          "subject-id",
+	"urn:oasis:names:tc:SAML:attribute:pairwise-id":         "pairwise-id",
+	"urn:mace:dir:attribute-def:eduPersonPrincipalName":     "eduPersonPrincipalName",
+	"urn:mace:dir:attribute-def:eduPersonScopedAffiliation": "eduPersonScopedAffiliation",
+	"urn:mace:dir:attribute-def:eduPersonUniqueId":          "eduPersonUniqueId",
+}
+
+// scopedAttributeName returns the friendly name of a scoped attribute
+// element, or "" if its values are not scoped. Attributes named by their
+// friendly name with the basic name format are recognized too.
+func scopedAttributeName(attr *etree.Element) string {
+	name := attr.SelectAttrValue("Name", "")
+	if friendly, ok := scopedAttributes[name]; ok {
+		return friendly
+	}
+	for _, friendly := range scopedAttributes {
+		if name == friendly {
+			return friendly
+		}
+	}
+	return ""
+}
@@ -0,0 +1,93 @@
+package saml
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetadataScopes(t *testing.T) {
+	md := `<md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" xmlns:shibmd="urn:mace:shibboleth:metadata:1.0" entityID="https://idp.example.org">
+  <md:Extensions><shibmd:Scope regexp="false">example.org</shibmd:Scope></md:Extensions>
+  <md:IDPSSODescriptor protocolSupportEnumeration="urn:oasis:names:tc:SAML:2.0:protocol">
+    <md:Extensions>
+      <shibmd:Scope regexp="false">example.org</shibmd:Scope>
+      <shibmd:Scope regexp="true">^.+\.example\.org$</shibmd:Scope>
+    </md:Extensions>
+  </md:IDPSSODescriptor>
+  <md:SPSSODescriptor protocolSupportEnumeration="urn:oasis:names:tc:SAML:2.0:protocol">
+    <md:Extensions><shibmd:Scope>sp.example.org</shibmd:Scope></md:Extensions>
+  </md:SPSSODescriptor>
+</md:EntityDescriptor>`
+
+	scopes, err := MetadataScopes([]byte(md))
+	require.NoError(t, err)
+	assert.Equal(t, []Scope{{Value: "example.org"}, {Value: `^.+\.example\.org$`, Regexp: true}}, scopes)
+
+	scopes, err = MetadataScopes([]byte(`<md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" entityID="https://idp.example.org"/>`))
+	require.NoError(t, err)
+	assert.NotNil(t, scopes)
+	assert.Empty(t, scopes)
+}
+
+func TestScopeMatches(t *testing.T) {
+	assert.True(t, Scope{Value: "example.org"}.Matches("Example.ORG"))
+	assert.False(t, Scope{Value: "example.org"}.Matches("evil.example.org"))
+
+	re := Scope{Value: `^.+\.example\.org$`, Regexp: true}
+	assert.True(t, re.Matches("math.example.org"))
+	assert.False(t, re.Matches("example.org"))
+	assert.False(t, re.Matches("math.example.org.evil.com"))
+	assert.False(t, Scope{Value: "(", Regexp: true}.Matches("("))
+}
+
+func TestAudit_Scopes(t *testing.T) {
+	assertion := `<saml:Assertion xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_a">
+  <saml:AttributeStatement>
+    <saml:Attribute Name="urn:oid:1.3.6.1.4.1.5923.1.1.1.6" FriendlyName="eduPersonPrincipalName">
+      <saml:AttributeValue>alice@example.org</saml:AttributeValue>
+      <saml:AttributeValue>mallory@other.edu</saml:AttributeValue>
+      <saml:AttributeValue>bob@other.edu@example.org</saml:AttributeValue>
+    </saml:Attribute>
+    <saml:Attribute Name="urn:oid:1.3.6.1.4.1.5923.1.1.1.9">
+      <saml:AttributeValue>staff@math.example.org</saml:AttributeValue>
+      <saml:AttributeValue>member</saml:AttributeValue>
+    </saml:Attribute>
+    <saml:Attribute Name="urn:mace:dir:attribute-def:eduPersonPrincipalName">
+      <saml:AttributeValue Scope="other.edu">carol</saml:AttributeValue>
+    </saml:Attribute>
+    <saml:Attribute Name="mail"><saml:AttributeValue>dave@other.edu</saml:AttributeValue></saml:Attribute>
+  </saml:AttributeStatement>
+</saml:Assertion>`
+
+	opts := AuditOptions{Scopes: []Scope{{Value: "example.org"}, {Value: `.+\.example\.org`, Regexp: true}}}
+	findings, err := Audit([]byte(assertion), opts)
+	require.NoError(t, err)
+	var scopeFindings []AuditFinding
+	for _, f := range findings {
+		if f.Check == CheckScope {
+			scopeFindings = append(scopeFindings, f)
+		}
+	}
+	require.Len(t, scopeFindings, 4)
+	assert.Equal(t, SeverityHigh, scopeFindings[0].Severity)
+	assert.Equal(t, `eduPersonPrincipalName value "mallory@other.edu" has scope "other.edu", which the IdP may not assert (possible scope injection; permitted: example.org, /.+\.example\.org/)`, scopeFindings[0].Message)
+	assert.Contains(t, scopeFindings[1].Message, `"bob@other.edu@example.org" has several @ delimiters`)
+	assert.Equal(t, SeverityMedium, scopeFindings[2].Severity)
+	assert.Contains(t, scopeFindings[2].Message, `eduPersonScopedAffiliation value "member" has no scope`)
+	assert.Contains(t, scopeFindings[3].Message, `value "carol" has scope "other.edu"`)
+
+	// Without scopes from metadata, nothing is checked
+	findings, err = Audit([]byte(assertion), AuditOptions{})
+	require.NoError(t, err)
+	for _, f := range findings {
+		assert.NotEqual(t, CheckScope, f.Check)
+	}
+
+	// Metadata without shibmd:Scope permits no scope
+	findings, err = Audit([]byte(assertion), AuditOptions{Scopes: []Scope{}})
+	require.NoError(t, err)
+	assert.Contains(t, findings, AuditFinding{Check: CheckScope, Severity: SeverityHigh,
+		Message: `eduPersonPrincipalName value "alice@example.org" has scope "example.org", which the IdP may not assert (possible scope injection; the metadata declares no shibmd:Scope)`})
+}
@@ -0,0 +1,342 @@
+package saml
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	// Register the hash implementations used by the signature algorithms
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+)
+
+// BindingSimpleSign is the URI of the HTTP-POST-SimpleSign binding
+const BindingSimpleSign = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST-SimpleSign"
+
+// Signature algorithms usable in the SigAlg parameter
+const (
+	SigAlgRSASHA1     = "http://www.w3.org/2000/09/xmldsig#rsa-sha1"
+	SigAlgRSASHA256   = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"
+	SigAlgRSASHA512   = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha512"
+	SigAlgECDSASHA256 = "http://www.w3.org/2001/04/xmldsig-more#ecdsa-sha256"
+	SigAlgECDSASHA512 = "http://www.w3.org/2001/04/xmldsig-more#ecdsa-sha512"
+)
+
+// sigAlgHashes maps each supported SigAlg to its digest
+var sigAlgHashes = map[string]crypto.Hash{
+	SigAlgRSASHA1:     crypto.SHA1,
+	SigAlgRSASHA256:   crypto.SHA256,
+	SigAlgRSASHA512:   crypto.SHA512,
+	SigAlgECDSASHA256: crypto.SHA256,
+	SigAlgECDSASHA512: crypto.SHA512,
+}
+
+// ResolveSigAlg accepts a SigAlg URI or its short name (e.g. "rsa-sha256")
+// and returns the URI
+func ResolveSigAlg(name string) (string, error) {
+	for uri := range sigAlgHashes {
+		if name == uri || name == shortSigAlg(uri) {
+			return uri, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported signature algorithm: %s", name)
+}
+
+// shortSigAlg returns the fragment of a SigAlg URI, e.g. "rsa-sha256"
+func shortSigAlg(uri string) string {
+	if i := strings.LastIndex(uri, "#"); i >= 0 {
+		return uri[i+1:]
+	}
+	return uri
+}
+
+// SimpleSignMessage holds the form fields of an HTTP-POST-SimpleSign
+// message. Message and Signature are base64-encoded as sent.
+type SimpleSignMessage struct {
+	ParameterName string `json:"parameter_name"`
+	Message       string `json:"-"`
+	RelayState    string `json:"relay_state,omitempty"`
+	SigAlg        string `json:"sig_alg"`
+	Signature     string `json:"signature"`
+}
+
+// SimpleSignFromValues returns the SimpleSign message carried in form
+// values, or false if the form has no SigAlg/Signature fields
+func SimpleSignFromValues(values url.Values) (*SimpleSignMessage, bool) {
+	msg := &SimpleSignMessage{
+		RelayState: values.Get("RelayState"),
+		SigAlg:     values.Get("SigAlg"),
+		Signature:  values.Get("Signature"),
+	}
+	for _, name := range []string{"SAMLResponse", "SAMLRequest"} {
+		if v := values.Get(name); v != "" {
+			msg.ParameterName = name
+			msg.Message = v
+			break
+		}
+	}
+
+	if msg.ParameterName == "" || msg.SigAlg == "" || msg.Signature == "" {
+		return nil, false
+	}
+	return msg, true
+}
+
+// ParseSimpleSignForm parses a URL-encoded form body of a SimpleSign POST
+func ParseSimpleSignForm(body string) (*SimpleSignMessage, error) {
+	values, err := url.ParseQuery(strings.TrimSpace(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse form: %w", err)
+	}
+	msg, ok := SimpleSignFromValues(values)
+	if !ok {
+		return nil, fmt.Errorf("form has no SimpleSign signature (SAMLRequest/SAMLResponse, SigAlg and Signature are required)")
+	}
+	return msg, nil
+}
+
+// SignedContent returns the octet string covered by the signature. Unlike
+// the Redirect binding, the values are not URL-encoded.
+func (m *SimpleSignMessage) SignedContent() []byte {
+	content := m.ParameterName + "=" + m.Message
+	if m.RelayState != "" {
+		content += "&RelayState=" + m.RelayState
+	}
+	content += "&SigAlg=" + m.SigAlg
+	return []byte(content)
+}
+
+// Encode returns the URL-encoded form body, with fields in binding order
+func (m *SimpleSignMessage) Encode() string {
+	fields := []string{m.ParameterName + "=" + url.QueryEscape(m.Message)}
+	if m.RelayState != "" {
+		fields = append(fields, "RelayState="+url.QueryEscape(m.RelayState))
+	}
+	fields = append(fields,
+		"SigAlg="+url.QueryEscape(m.SigAlg),
+		"Signature="+url.QueryEscape(m.Signature),
+	)
+	return strings.Join(fields, "&")
+}
+
+// Decode returns the XML carried in the message
+func (m *SimpleSignMessage) Decode() ([]byte, error) {
+	return NewDecoder().Decode(m.Message)
+}
+
+// Verify checks the signature against the public key of cert
+func (m *SimpleSignMessage) Verify(cert *x509.Certificate) error {
+	hash, ok := sigAlgHashes[m.SigAlg]
+	if !ok {
+		return fmt.Errorf("unsupported signature algorithm: %s", m.SigAlg)
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(m.Signature)
+	if err != nil {
+		return fmt.Errorf("failed to decode signature: %w", err)
+	}
+
+	return verifySignatureValue(cert, hash, m.SignedContent(), signature)
+}
+
+// verifySignatureValue checks an RSA PKCS#1 v1.5 or ECDSA signature over
+// content, as used by XML-DSig and the SAML bindings
+func verifySignatureValue(cert *x509.Certificate, hash crypto.Hash, content, signature []byte) error {
+	h := hash.New()
+	h.Write(content)
+	digest := h.Sum(nil)
+
+	switch pub := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(pub, hash, digest, signature); err != nil {
+			return fmt.Errorf("signature verification failed: %w", err)
+		}
+	case *ecdsa.PublicKey:
+		// XML-DSig encodes ECDSA signatures as the concatenation r || s
+		if len(signature)%2 != 0 {
+			return fmt.Errorf("signature verification failed: malformed ECDSA signature")
+		}
+		half := len(signature) / 2
+		r := new(big.Int).SetBytes(signature[:half])
+		s := new(big.Int).SetBytes(signature[half:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return fmt.Errorf("signature verification failed")
+		}
+	default:
+		return fmt.Errorf("unsupported public key type %T", cert.PublicKey)
+	}
+	return nil
+}
+
+// ParameterNameFor returns the form parameter that carries xmlData:
+// SAMLRequest for requests, SAMLResponse otherwise
+func ParameterNameFor(xmlData []byte) string {
+	if strings.HasSuffix(NewHARExtractor().detectSAMLType(xmlData), "Request") {
+		return "SAMLRequest"
+	}
+	return "SAMLResponse"
+}
+
+// SignSimpleSign creates a SimpleSign message for xmlData, signed with key
+// using sigAlg
+func SignSimpleSign(xmlData []byte, paramName, relayState, sigAlg string, key crypto.Signer) (*SimpleSignMessage, error) {
+	hash, ok := sigAlgHashes[sigAlg]
+	if !ok {
+		return nil, fmt.Errorf("unsupported signature algorithm: %s", sigAlg)
+	}
+
+	msg := &SimpleSignMessage{
+		ParameterName: paramName,
+		Message:       base64.StdEncoding.EncodeToString(xmlData),
+		RelayState:    relayState,
+		SigAlg:        sigAlg,
+	}
+
+	signature, err := signContent(key, sigAlg, hash, msg.SignedContent())
+	if err != nil {
+		return nil, err
+	}
+	msg.Signature = base64.StdEncoding.EncodeToString(signature)
+	return msg, nil
+}
+
+// signContent signs content with key, producing an RSA PKCS#1 v1.5 or
+// XML-DSig style (r || s) ECDSA signature
+func signContent(key crypto.Signer, sigAlg string, hash crypto.Hash, content []byte) ([]byte, error) {
+	h := hash.New()
+	h.Write(content)
+	digest := h.Sum(nil)
+
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		if strings.Contains(sigAlg, "ecdsa") {
+			return nil, fmt.Errorf("%s requires an EC key", shortSigAlg(sigAlg))
+		}
+		sig, err := rsa.SignPKCS1v15(rand.Reader, k, hash, digest)
+		if err != nil {
+			return nil, fmt.Errorf("failed to sign: %w", err)
+		}
+		return sig, nil
+	case *ecdsa.PrivateKey:
+		if !strings.Contains(sigAlg, "ecdsa") {
+			return nil, fmt.Errorf("%s requires an RSA key", shortSigAlg(sigAlg))
+		}
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest)
+		if err != nil {
+			return nil, fmt.Errorf("failed to sign: %w", err)
+		}
+		size := (k.Curve.Params().BitSize + 7) / 8
+		signature := make([]byte, 2*size)
+		r.FillBytes(signature[:size])
+		s.FillBytes(signature[size:])
+		return signature, nil
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+}
+
+// LoadSigningKey reads an RSA or EC private key from a PEM file
+func LoadSigningKey(path string) (crypto.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key file: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("failed to parse PEM block")
+	}
+
+	var key interface{}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported key type: %s", block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+	return signer, nil
+}
+
+// LoadCertificate reads an X.509 certificate from a PEM file. A bare
+// base64 DER value, as found in metadata, is accepted as well.
+func LoadCertificate(path string) (*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate file: %w", err)
+	}
+	return ParseCertificate(data)
+}
+
+// ParseCertificate parses a PEM or bare base64 DER certificate
+func ParseCertificate(data []byte) (*x509.Certificate, error) {
+	der := data
+	if block, _ := pem.Decode(data); block != nil {
+		der = block.Bytes
+	} else {
+		cleaned := strings.Join(strings.Fields(string(data)), "")
+		decoded, err := base64.StdEncoding.DecodeString(cleaned)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate: not PEM or base64")
+		}
+		der = decoded
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate: %w", err)
+	}
+	return cert, nil
+}
+
+// NewSelfSignedKey generates an RSA key with a self-signed certificate for
+// commonName, valid for a year, for test IdPs and SPs run without a key
+func NewSelfSignedKey(commonName string) (*rsa.PrivateKey, *x509.Certificate, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate serial number: %w", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().AddDate(1, 0, 0),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create certificate: %w", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse certificate: %w", err)
+	}
+	return key, cert, nil
+}
@@ -0,0 +1,172 @@
+package saml
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const simpleSignResponse = `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_resp1"/>`
+
+// selfSignedCert creates a certificate for the public half of key
+func selfSignedCert(t *testing.T, key crypto.Signer) *x509.Certificate {
+	t.Helper()
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "idp.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert
+}
+
+func TestSimpleSign_RoundTrip(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		key    crypto.Signer
+		sigAlg string
+	}{
+		{"rsa-sha1", rsaKey, SigAlgRSASHA1},
+		{"rsa-sha256", rsaKey, SigAlgRSASHA256},
+		{"rsa-sha512", rsaKey, SigAlgRSASHA512},
+		{"ecdsa-sha256", ecKey, SigAlgECDSASHA256},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cert := selfSignedCert(t, tt.key)
+
+			msg, err := SignSimpleSign([]byte(simpleSignResponse), "SAMLResponse", "https://sp.example.com/home?a=b", tt.sigAlg, tt.key)
+			require.NoError(t, err)
+			require.NoError(t, msg.Verify(cert))
+
+			// The encoded form body must survive a round trip
+			parsed, err := ParseSimpleSignForm(msg.Encode())
+			require.NoError(t, err)
+			assert.Equal(t, msg, parsed)
+			require.NoError(t, parsed.Verify(cert))
+
+			decoded, err := parsed.Decode()
+			require.NoError(t, err)
+			assert.Equal(t, simpleSignResponse, string(decoded))
+
+			// Any change to the signed fields invalidates the signature
+			tampered := *parsed
+			tampered.RelayState = "https://evil.example.com"
+			assert.Error(t, tampered.Verify(cert))
+		})
+	}
+}
+
+func TestSimpleSign_SignedContent(t *testing.T) {
+	msg := &SimpleSignMessage{ParameterName: "SAMLRequest", Message: "PHg+", SigAlg: SigAlgRSASHA256}
+	assert.Equal(t, "SAMLRequest=PHg+&SigAlg=http://www.w3.org/2001/04/xmldsig-more#rsa-sha256", string(msg.SignedContent()))
+
+	msg.RelayState = "state"
+	assert.Equal(t, "SAMLRequest=PHg+&RelayState=state&SigAlg=http://www.w3.org/2001/04/xmldsig-more#rsa-sha256", string(msg.SignedContent()))
+}
+
+func TestSimpleSign_Errors(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	_, err = SignSimpleSign([]byte(simpleSignResponse), "SAMLResponse", "", SigAlgECDSASHA256, rsaKey)
+	assert.ErrorContains(t, err, "requires an EC key")
+
+	_, err = SignSimpleSign([]byte(simpleSignResponse), "SAMLResponse", "", "urn:unknown", rsaKey)
+	assert.ErrorContains(t, err, "unsupported signature algorithm")
+
+	_, err = ParseSimpleSignForm("SAMLResponse=PHg%2B")
+	assert.ErrorContains(t, err, "no SimpleSign signature")
+}
+
+func TestResolveSigAlg(t *testing.T) {
+	uri, err := ResolveSigAlg("rsa-sha256")
+	require.NoError(t, err)
+	assert.Equal(t, SigAlgRSASHA256, uri)
+
+	uri, err = ResolveSigAlg(SigAlgECDSASHA256)
+	require.NoError(t, err)
+	assert.Equal(t, SigAlgECDSASHA256, uri)
+
+	_, err = ResolveSigAlg("dsa-sha1")
+	assert.Error(t, err)
+}
+
+func TestParameterNameFor(t *testing.T) {
+	assert.Equal(t, "SAMLResponse", ParameterNameFor([]byte(simpleSignResponse)))
+	assert.Equal(t, "SAMLRequest", ParameterNameFor([]byte(`<samlp:AuthnRequest xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol"/>`)))
+}
+
+func TestLoadSigningKeyAndCertificate(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalECPrivateKey(ecKey)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600))
+
+	key, err := LoadSigningKey(keyPath)
+	require.NoError(t, err)
+	assert.IsType(t, &ecdsa.PrivateKey{}, key)
+
+	cert := selfSignedCert(t, ecKey)
+	pemPath := filepath.Join(dir, "cert.pem")
+	require.NoError(t, os.WriteFile(pemPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}), 0644))
+	barePath := filepath.Join(dir, "cert.b64")
+	require.NoError(t, os.WriteFile(barePath, []byte(base64.StdEncoding.EncodeToString(cert.Raw)), 0644))
+
+	for _, path := range []string{pemPath, barePath} {
+		loaded, err := LoadCertificate(path)
+		require.NoError(t, err)
+		assert.Equal(t, cert.Raw, loaded.Raw)
+	}
+}
+
+func TestHARExtractor_DetectsSimpleSign(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	msg, err := SignSimpleSign([]byte(simpleSignResponse), "SAMLResponse", "", SigAlgRSASHA256, rsaKey)
+	require.NoError(t, err)
+
+	har := `{"log": {"entries": [{"request": {"method": "POST", "url": "https://sp.example.com/acs",
+		"postData": {"mimeType": "application/x-www-form-urlencoded", "params": [
+			{"name": "SAMLResponse", "value": "` + url.QueryEscape(msg.Message) + `"},
+			{"name": "SigAlg", "value": "` + url.QueryEscape(msg.SigAlg) + `"},
+			{"name": "Signature", "value": "` + url.QueryEscape(msg.Signature) + `"}]}},
+		"response": {"content": {"mimeType": "text/html", "text": ""}}}]}}`
+
+	results, err := NewHARExtractor().ExtractFromHAR([]byte(har))
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	require.NotNil(t, results[0].SimpleSign)
+	assert.Equal(t, msg, results[0].SimpleSign)
+	require.NoError(t, results[0].SimpleSign.Verify(selfSignedCert(t, rsaKey)))
+}
@@ -0,0 +1,82 @@
+package saml
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/beevik/etree"
+)
+
+// SOAP11Namespace is the namespace of SOAP 1.1 envelopes, used by the SAML
+// SOAP binding
+const SOAP11Namespace = "http://schemas.xmlsoap.org/soap/envelope/"
+
+// SOAPFault is returned by UnwrapSOAP for an envelope carrying a fault
+// rather than a SAML message
+type SOAPFault struct {
+	Code   string
+	String string
+}
+
+func (f *SOAPFault) Error() string {
+	if f.Code == "" {
+		return "SOAP fault: " + f.String
+	}
+	return fmt.Sprintf("SOAP fault %s: %s", f.Code, f.String)
+}
+
+// IsSOAP reports whether data is a SOAP 1.1 envelope
+func IsSOAP(data []byte) bool {
+	return bytes.Contains(data, []byte(SOAP11Namespace)) && bytes.Contains(data, []byte("Envelope"))
+}
+
+// WrapSOAP puts a SAML message into the Body of a SOAP 1.1 envelope, as
+// sent with the SOAP binding
+func WrapSOAP(message []byte) ([]byte, error) {
+	msgDoc, err := ReadDocument(message)
+	if err != nil {
+		return nil, &ErrParse{Stage: "message", Err: err}
+	}
+	if msgDoc.Root() == nil {
+		return nil, errors.New("no root element")
+	}
+
+	doc := etree.NewDocument()
+	doc.CreateProcInst("xml", `version="1.0" encoding="UTF-8"`)
+	envelope := doc.CreateElement("soap11:Envelope")
+	envelope.CreateAttr("xmlns:soap11", SOAP11Namespace)
+	envelope.CreateElement("soap11:Body").AddChild(msgDoc.Root())
+	return doc.WriteToBytes()
+}
+
+// UnwrapSOAP returns the SAML message in the Body of a SOAP 1.1 envelope,
+// with the namespace declarations it inherits, or a *SOAPFault
+func UnwrapSOAP(data []byte) ([]byte, error) {
+	doc, err := ReadDocument(data)
+	if err != nil {
+		return nil, &ErrParse{Stage: "SOAP envelope", Err: err}
+	}
+	root := doc.Root()
+	if root == nil || !isElement(root, SOAP11Namespace, "Envelope") {
+		return nil, errors.New("not a SOAP envelope")
+	}
+	var body *etree.Element
+	for _, child := range root.ChildElements() {
+		if isElement(child, SOAP11Namespace, "Body") {
+			body = child
+			break
+		}
+	}
+	if body == nil || len(body.ChildElements()) == 0 {
+		return nil, errors.New("SOAP envelope has an empty Body")
+	}
+
+	msg := body.ChildElements()[0]
+	if isElement(msg, SOAP11Namespace, "Fault") {
+		return nil, &SOAPFault{Code: childText(msg, "faultcode"), String: childText(msg, "faultstring")}
+	}
+	msgDoc := etree.NewDocument()
+	msgDoc.SetRoot(detachElement(msg))
+	return msgDoc.WriteToBytes()
+}
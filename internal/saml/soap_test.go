@@ -0,0 +1,63 @@
+package saml
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrapSOAP(t *testing.T) {
+	response, err := os.ReadFile(filepath.Join("..", "..", "testdata", "fixtures", "assertions", "response.xml"))
+	require.NoError(t, err)
+
+	envelope, err := WrapSOAP(response)
+	require.NoError(t, err)
+	assert.True(t, IsSOAP(envelope))
+	assert.False(t, IsSOAP(response))
+
+	// Parse reads the message in the Body
+	info, err := NewParser().Parse(envelope)
+	require.NoError(t, err)
+	assert.Equal(t, "Response", info.Type)
+	require.NotNil(t, info.Assertion)
+	assert.NotEmpty(t, info.Assertion.Subject.NameID)
+}
+
+func TestUnwrapSOAP_InheritedNamespaces(t *testing.T) {
+	envelope := `<S:Envelope xmlns:S="http://schemas.xmlsoap.org/soap/envelope/" xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion">
+  <S:Body><samlp:Response ID="_r1" Version="2.0" InResponseTo="_q1"><saml:Issuer>https://idp.example.com</saml:Issuer><samlp:Status><samlp:StatusCode Value="urn:oasis:names:tc:SAML:2.0:status:Success"/></samlp:Status></samlp:Response></S:Body>
+</S:Envelope>`
+
+	msg, err := UnwrapSOAP([]byte(envelope))
+	require.NoError(t, err)
+	info, err := NewParser().Parse(msg)
+	require.NoError(t, err)
+	assert.Equal(t, "Response", info.Type)
+	assert.Equal(t, "_q1", info.InResponseTo)
+	assert.Equal(t, "https://idp.example.com", info.Issuer)
+}
+
+func TestUnwrapSOAP_Fault(t *testing.T) {
+	envelope := `<soap11:Envelope xmlns:soap11="http://schemas.xmlsoap.org/soap/envelope/"><soap11:Body><soap11:Fault>
+  <faultcode>soap11:Client</faultcode>
+  <faultstring>Message did not meet security requirements</faultstring>
+</soap11:Fault></soap11:Body></soap11:Envelope>`
+
+	_, err := UnwrapSOAP([]byte(envelope))
+	var fault *SOAPFault
+	require.True(t, errors.As(err, &fault))
+	assert.Equal(t, "soap11:Client", fault.Code)
+	assert.Equal(t, "SOAP fault soap11:Client: Message did not meet security requirements", err.Error())
+}
+
+func TestUnwrapSOAP_Invalid(t *testing.T) {
+	_, err := UnwrapSOAP([]byte(`<Response/>`))
+	assert.ErrorContains(t, err, "not a SOAP envelope")
+
+	_, err = UnwrapSOAP([]byte(`<soap11:Envelope xmlns:soap11="http://schemas.xmlsoap.org/soap/envelope/"><soap11:Body/></soap11:Envelope>`))
+	assert.ErrorContains(t, err, "empty Body")
+}
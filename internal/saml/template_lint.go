@@ -0,0 +1,224 @@
+package saml
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Severity levels for lint issues
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// TemplateIssue is a problem found while linting a response template
+type TemplateIssue struct {
+	Line     int    `json:"line"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+// dateTimeAttributes are SAML attributes typed as xs:dateTime
+var dateTimeAttributes = map[string]bool{
+	"IssueInstant":        true,
+	"NotBefore":           true,
+	"NotOnOrAfter":        true,
+	"AuthnInstant":        true,
+	"SessionNotOnOrAfter": true,
+}
+
+// namespaceRequired lists the SAML elements that must be namespace-qualified
+var namespaceRequired = map[string]bool{
+	"Response":           true,
+	"Assertion":          true,
+	"Issuer":             true,
+	"Status":             true,
+	"StatusCode":         true,
+	"Subject":            true,
+	"NameID":             true,
+	"Conditions":         true,
+	"AuthnStatement":     true,
+	"AttributeStatement": true,
+	"Attribute":          true,
+	"AttributeValue":     true,
+}
+
+var (
+	// Velocity comments and directives, removed before parsing
+	velocityBlockComment = regexp.MustCompile(`(?s)#\*.*?\*#`)
+	velocityLineComment  = regexp.MustCompile(`##[^\n]*`)
+	velocityDirective    = regexp.MustCompile(`#\{?(if|elseif|else|end|foreach|set|macro|parse|include|break|stop)\}?(\s*\([^)\n]*\))?`)
+
+	// Placeholders in Velocity (${x}, $!{x}, $x.y) and mustache-style ({{x}}) templates
+	templatePlaceholder = regexp.MustCompile(`\$!?\{[^}]*\}|\$!?[A-Za-z][\w.]*(\([^)]*\))?|\{\{[^}]*\}\}`)
+
+	placeholderMarker = regexp.MustCompile(`__SAMLURAI_PH_\d+__`)
+
+	// Characters that can legitimately surround placeholders in an xs:dateTime
+	dateTimeLiteral = regexp.MustCompile(`^[0-9TZ:.+\-]*$`)
+)
+
+// LintTemplate checks an XML or Velocity response template for structural
+// issues: unclosed or mismatched elements, undeclared namespace prefixes,
+// unqualified SAML elements and xs:dateTime attributes that cannot produce
+// a valid value.
+func LintTemplate(data []byte) []TemplateIssue {
+	source, placeholders := prepareTemplate(data)
+
+	var issues []TemplateIssue
+	addIssue := func(line int, severity, format string, args ...interface{}) {
+		issues = append(issues, TemplateIssue{Line: line, Severity: severity, Message: fmt.Sprintf(format, args...)})
+	}
+
+	type openElement struct {
+		name string
+		line int
+	}
+	var stack []openElement
+	namespaces := []map[string]bool{{"xml": true}}
+	unqualified := map[string]bool{}
+
+	decoder := xml.NewDecoder(bytes.NewReader(source))
+	decoder.Strict = false
+	decoder.AutoClose = nil
+
+	for {
+		token, err := decoder.RawToken()
+		line, _ := decoder.InputPos()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			addIssue(line, SeverityError, "malformed XML: %v", err)
+			return issues
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			name := qualifiedName(t.Name)
+			stack = append(stack, openElement{name: name, line: line})
+
+			scope := map[string]bool{}
+			for k := range namespaces[len(namespaces)-1] {
+				scope[k] = true
+			}
+			for _, attr := range t.Attr {
+				if attr.Name.Space == "xmlns" {
+					scope[attr.Name.Local] = true
+				} else if attr.Name.Space == "" && attr.Name.Local == "xmlns" && attr.Value != "" {
+					scope[""] = true
+				}
+			}
+			namespaces = append(namespaces, scope)
+
+			if t.Name.Space != "" && !scope[t.Name.Space] {
+				addIssue(line, SeverityError, "namespace prefix %q on <%s> is not declared", t.Name.Space, name)
+			}
+			if t.Name.Space == "" && !scope[""] && namespaceRequired[t.Name.Local] && !unqualified[t.Name.Local] {
+				unqualified[t.Name.Local] = true
+				addIssue(line, SeverityError, "<%s> has no SAML namespace (missing prefix or xmlns declaration)", t.Name.Local)
+			}
+
+			for _, attr := range t.Attr {
+				if attr.Name.Space != "" && attr.Name.Space != "xmlns" && !scope[attr.Name.Space] {
+					addIssue(line, SeverityError, "namespace prefix %q on attribute %s is not declared", attr.Name.Space, qualifiedName(attr.Name))
+				}
+				if attr.Name.Space == "" && dateTimeAttributes[attr.Name.Local] {
+					if msg := checkDateTimeValue(attr.Value, placeholders); msg != "" {
+						addIssue(line, SeverityError, "%s on <%s> %s", attr.Name.Local, name, msg)
+					}
+				}
+			}
+
+		case xml.EndElement:
+			name := qualifiedName(t.Name)
+			if len(stack) == 0 {
+				addIssue(line, SeverityError, "closing tag </%s> has no matching opening tag", name)
+				continue
+			}
+			// Find the matching open element so a single unclosed child
+			// does not cascade into errors for all of its ancestors
+			match := len(stack) - 1
+			for match >= 0 && stack[match].name != name {
+				match--
+			}
+			if match < 0 {
+				addIssue(line, SeverityError, "closing tag </%s> has no matching opening tag", name)
+				continue
+			}
+			for i := len(stack) - 1; i > match; i-- {
+				addIssue(stack[i].line, SeverityError, "element <%s> is never closed (before </%s> on line %d)", stack[i].name, name, line)
+			}
+			stack = stack[:match]
+			namespaces = namespaces[:match+1]
+		}
+	}
+
+	for i := len(stack) - 1; i >= 0; i-- {
+		addIssue(stack[i].line, SeverityError, "element <%s> is never closed", stack[i].name)
+	}
+
+	return issues
+}
+
+// prepareTemplate strips Velocity directives and replaces placeholders with
+// markers so the template can be parsed as XML. Newlines are preserved so
+// reported line numbers match the original file.
+func prepareTemplate(data []byte) ([]byte, []string) {
+	keepNewlines := func(match []byte) []byte {
+		return bytes.Repeat([]byte("\n"), bytes.Count(match, []byte("\n")))
+	}
+
+	source := velocityBlockComment.ReplaceAllFunc(data, keepNewlines)
+	source = velocityLineComment.ReplaceAll(source, nil)
+	source = velocityDirective.ReplaceAll(source, nil)
+
+	var placeholders []string
+	source = templatePlaceholder.ReplaceAllFunc(source, func(match []byte) []byte {
+		placeholders = append(placeholders, string(match))
+		return []byte(fmt.Sprintf("__SAMLURAI_PH_%d__", len(placeholders)-1))
+	})
+
+	return source, placeholders
+}
+
+// checkDateTimeValue returns a description of why value cannot be a valid
+// xs:dateTime, or an empty string if it looks fine
+func checkDateTimeValue(value string, placeholders []string) string {
+	if !placeholderMarker.MatchString(value) {
+		if _, err := time.Parse(time.RFC3339Nano, value); err != nil {
+			return fmt.Sprintf("is not a valid xs:dateTime: %q", value)
+		}
+		return ""
+	}
+
+	literal := placeholderMarker.ReplaceAllString(value, "")
+	if !dateTimeLiteral.MatchString(literal) {
+		return fmt.Sprintf("will not produce a valid xs:dateTime: %q", restorePlaceholders(value, placeholders))
+	}
+	return ""
+}
+
+// restorePlaceholders puts the original placeholder text back into value
+func restorePlaceholders(value string, placeholders []string) string {
+	return placeholderMarker.ReplaceAllStringFunc(value, func(marker string) string {
+		var i int
+		fmt.Sscanf(strings.Trim(marker, "_"), "SAMLURAI_PH_%d", &i)
+		if i < len(placeholders) {
+			return placeholders[i]
+		}
+		return marker
+	})
+}
+
+func qualifiedName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
@@ -0,0 +1,80 @@
+package saml
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLintTemplate_Clean(t *testing.T) {
+	template := `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol"
+    xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion"
+    ID="${responseId}" IssueInstant="$issueInstant">
+  ## Velocity comment
+  <saml:Issuer>$issuer</saml:Issuer>
+  #if($includeAssertion)
+  <saml:Assertion ID="{{assertionId}}" IssueInstant="${date}T${time}Z">
+    <saml:Conditions NotBefore="2024-01-15T10:00:00Z" NotOnOrAfter="$!{expiry}"/>
+  </saml:Assertion>
+  #end
+</samlp:Response>`
+
+	assert.Empty(t, LintTemplate([]byte(template)))
+}
+
+func TestLintTemplate_Issues(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		wantLine int
+		wantMsg  string
+	}{
+		{
+			name:     "unclosed element",
+			template: "<samlp:Response xmlns:samlp=\"urn:oasis:names:tc:SAML:2.0:protocol\">\n<samlp:Status>\n</samlp:Response>",
+			wantLine: 2,
+			wantMsg:  "element <samlp:Status> is never closed",
+		},
+		{
+			name:     "unmatched closing tag",
+			template: "<samlp:Response xmlns:samlp=\"urn:oasis:names:tc:SAML:2.0:protocol\">\n</samlp:Status>\n</samlp:Response>",
+			wantLine: 2,
+			wantMsg:  "closing tag </samlp:Status> has no matching opening tag",
+		},
+		{
+			name:     "undeclared prefix",
+			template: "<samlp:Response xmlns:samlp=\"urn:oasis:names:tc:SAML:2.0:protocol\">\n<saml:Issuer>x</saml:Issuer>\n</samlp:Response>",
+			wantLine: 2,
+			wantMsg:  `namespace prefix "saml" on <saml:Issuer> is not declared`,
+		},
+		{
+			name:     "missing namespace",
+			template: "<Response ID=\"_1\"></Response>",
+			wantLine: 1,
+			wantMsg:  "<Response> has no SAML namespace",
+		},
+		{
+			name:     "invalid literal dateTime",
+			template: "<samlp:Response xmlns:samlp=\"urn:oasis:names:tc:SAML:2.0:protocol\" IssueInstant=\"2024-01-15 10:00\"/>",
+			wantLine: 1,
+			wantMsg:  `IssueInstant on <samlp:Response> is not a valid xs:dateTime: "2024-01-15 10:00"`,
+		},
+		{
+			name:     "placeholder producing invalid dateTime",
+			template: "<samlp:Response xmlns:samlp=\"urn:oasis:names:tc:SAML:2.0:protocol\" IssueInstant=\"$now UTC\"/>",
+			wantLine: 1,
+			wantMsg:  `IssueInstant on <samlp:Response> will not produce a valid xs:dateTime: "$now UTC"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issues := LintTemplate([]byte(tt.template))
+			require.Len(t, issues, 1, "issues: %+v", issues)
+			assert.Equal(t, tt.wantLine, issues[0].Line)
+			assert.Equal(t, SeverityError, issues[0].Severity)
+			assert.Contains(t, issues[0].Message, tt.wantMsg)
+		})
+	}
+}
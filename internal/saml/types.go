@@ -1,9 +1,17 @@
 package saml
 
-import "time"
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
 
 // SAMLInfo contains parsed information from a SAML assertion or response
 type SAMLInfo struct {
+	// SchemaVersion is the version of the JSON output, set on the message
+	// at the top of -o json output
+	SchemaVersion string `json:"schema_version,omitempty" xml:"-"`
+
 	// Type indicates if this is a Response, Assertion, or AuthnRequest
 	Type string `json:"type"`
 
@@ -34,9 +42,29 @@ type SAMLInfo struct {
 	// Signature info
 	Signature *SignatureInfo `json:"signature,omitempty"`
 
+	// Encryption describes an assertion that was not decrypted
+	Encryption *EncryptionInfo `json:"encryption,omitempty"`
+
 	// Raw assertion (for responses containing assertions)
 	Assertion *SAMLInfo `json:"assertion,omitempty"`
 
+	// RawXML is the original XML of the message or assertion, kept when
+	// the parser was created WithRawXML
+	RawXML string `json:"raw_xml,omitempty"`
+
+	// SAML documents embedded in attribute values, e.g. upstream assertions
+	// forwarded by a broker
+	EmbeddedTokens []EmbeddedToken `json:"embedded_tokens,omitempty"`
+
+	// WS-Trust RST/RSTR details; the issued token is in Assertion
+	WSTrust *WSTrustInfo `json:"ws_trust,omitempty"`
+
+	// Vendor is the IdP product that likely produced the message
+	Vendor *VendorFingerprint `json:"vendor,omitempty"`
+
+	// PossibleCauses explain the failure the message reports
+	PossibleCauses []Cause `json:"possible_causes,omitempty"`
+
 	// AuthnRequest-specific fields
 	AssertionConsumerServiceURL string `json:"assertion_consumer_service_url,omitempty"`
 	ProtocolBinding             string `json:"protocol_binding,omitempty"`
@@ -44,6 +72,14 @@ type SAMLInfo struct {
 	IsPassive                   *bool  `json:"is_passive,omitempty"`
 	NameIDPolicy                *NameIDPolicy `json:"name_id_policy,omitempty"`
 	RequestedAttributes         []RequestedAttribute `json:"requested_attributes,omitempty"`
+	RequestedAuthnContext       *RequestedAuthnContext `json:"requested_authn_context,omitempty"`
+	Scoping                     *Scoping `json:"scoping,omitempty"`
+}
+
+// EmbeddedToken is a SAML document carried in an attribute value
+type EmbeddedToken struct {
+	Attribute string    `json:"attribute"`
+	Info      *SAMLInfo `json:"info"`
 }
 
 // NameIDPolicy contains the NameID policy for AuthnRequests
@@ -65,6 +101,10 @@ type RequestedAttribute struct {
 type Status struct {
 	StatusCode    string `json:"status_code"`
 	StatusMessage string `json:"status_message,omitempty"`
+
+	// SubStatusCode is the second-level code telling why, e.g.
+	// InvalidNameIDPolicy
+	SubStatusCode string `json:"sub_status_code,omitempty"`
 }
 
 // Subject contains the subject information
@@ -72,6 +112,8 @@ type Subject struct {
 	NameID          string `json:"name_id,omitempty"`
 	NameIDFormat    string `json:"name_id_format,omitempty"`
 	SPNameQualifier string `json:"sp_name_qualifier,omitempty"`
+	Recipient       string `json:"recipient,omitempty"`
+	InResponseTo    string `json:"in_response_to,omitempty"`
 }
 
 // Conditions contains the assertion conditions
@@ -79,6 +121,187 @@ type Conditions struct {
 	NotBefore           *time.Time `json:"not_before,omitempty"`
 	NotOnOrAfter        *time.Time `json:"not_on_or_after,omitempty"`
 	AudienceRestriction []string   `json:"audience_restriction,omitempty"`
+
+	// Other holds the remaining conditions in document order, such as
+	// OneTimeUse, ProxyRestriction and DelegationRestriction
+	Other ConditionList `json:"other,omitempty"`
+}
+
+// Condition is a condition of an assertion other than its validity period
+// and audience restriction. Further condition types implement it to be
+// captured by the parser.
+type Condition interface {
+	// ConditionType returns the name of the condition, e.g. OneTimeUse
+	ConditionType() string
+}
+
+// ConditionList is a list of conditions. Its JSON form names the type of
+// each condition.
+type ConditionList []Condition
+
+// MarshalJSON encodes each condition as an object with its fields and a
+// "type" field
+func (l ConditionList) MarshalJSON() ([]byte, error) {
+	out := make([]map[string]interface{}, len(l))
+	for i, c := range l {
+		data, err := json.Marshal(c)
+		if err != nil {
+			return nil, err
+		}
+		fields := map[string]interface{}{}
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return nil, err
+		}
+		fields["type"] = c.ConditionType()
+		out[i] = fields
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON decodes conditions encoded by MarshalJSON, by their type
+func (l *ConditionList) UnmarshalJSON(data []byte) error {
+	var items []json.RawMessage
+	if err := json.Unmarshal(data, &items); err != nil {
+		return err
+	}
+	list := make(ConditionList, len(items))
+	for i, item := range items {
+		var typed struct {
+			Type string `json:"type"`
+		}
+		if err := json.Unmarshal(item, &typed); err != nil {
+			return err
+		}
+		var c Condition
+		switch typed.Type {
+		case "OneTimeUse":
+			c = &OneTimeUse{}
+		case "ProxyRestriction":
+			c = &ProxyRestriction{}
+		case "DelegationRestriction":
+			c = &DelegationRestriction{}
+		default:
+			c = &UnknownCondition{}
+		}
+		if err := json.Unmarshal(item, c); err != nil {
+			return err
+		}
+		list[i] = c
+	}
+	*l = list
+	return nil
+}
+
+// OneTimeUse forbids the relying party to keep the assertion for reuse
+type OneTimeUse struct{}
+
+// ConditionType implements Condition
+func (*OneTimeUse) ConditionType() string { return "OneTimeUse" }
+
+// ProxyRestriction limits the assertions a relying party acting as IdP
+// may issue based on this one
+type ProxyRestriction struct {
+	// Count is the number of further proxy steps allowed; nil means no
+	// limit
+	Count     *int     `json:"count,omitempty"`
+	Audiences []string `json:"audiences,omitempty"`
+}
+
+// ConditionType implements Condition
+func (*ProxyRestriction) ConditionType() string { return "ProxyRestriction" }
+
+// DelegationRestriction lists the intermediaries acting on behalf of the
+// subject, as defined by the SAML condition for delegation restriction
+type DelegationRestriction struct {
+	Delegates []Delegate `json:"delegates"`
+}
+
+// ConditionType implements Condition
+func (*DelegationRestriction) ConditionType() string { return "DelegationRestriction" }
+
+// Delegate is an intermediary of a DelegationRestriction
+type Delegate struct {
+	NameID             string     `json:"name_id,omitempty"`
+	NameIDFormat       string     `json:"name_id_format,omitempty"`
+	DelegationInstant  *time.Time `json:"delegation_instant,omitempty"`
+	ConfirmationMethod string     `json:"confirmation_method,omitempty"`
+}
+
+// UnknownCondition is a condition the parser does not understand. A relying
+// party that does not understand a condition must treat the assertion as
+// indeterminate.
+type UnknownCondition struct {
+	// Name is the element name, e.g. Condition for custom conditions
+	Name string `json:"name"`
+
+	// XSIType is the xsi:type of a custom Condition element
+	XSIType string `json:"xsi_type,omitempty"`
+}
+
+// ConditionType implements Condition
+func (c *UnknownCondition) ConditionType() string {
+	if c.XSIType != "" {
+		return c.XSIType
+	}
+	return c.Name
+}
+
+// OneTimeUse reports whether the assertion has a OneTimeUse condition
+func (c *Conditions) OneTimeUse() bool {
+	for _, cond := range c.Other {
+		if _, ok := cond.(*OneTimeUse); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ProxyRestriction returns the ProxyRestriction condition, or nil if there
+// is none
+func (c *Conditions) ProxyRestriction() *ProxyRestriction {
+	for _, cond := range c.Other {
+		if pr, ok := cond.(*ProxyRestriction); ok {
+			return pr
+		}
+	}
+	return nil
+}
+
+// DelegationRestriction returns the DelegationRestriction condition, or nil
+// if there is none
+func (c *Conditions) DelegationRestriction() *DelegationRestriction {
+	for _, cond := range c.Other {
+		if dr, ok := cond.(*DelegationRestriction); ok {
+			return dr
+		}
+	}
+	return nil
+}
+
+// Scoping contains the IdPs an AuthnRequest may be proxied to, and the
+// requesters it was proxied for
+type Scoping struct {
+	// ProxyCount is the number of proxy steps allowed; nil means no limit
+	ProxyCount   *int       `json:"proxy_count,omitempty"`
+	IDPList      []IDPEntry `json:"idp_list,omitempty"`
+	GetComplete  string     `json:"get_complete,omitempty"`
+	RequesterIDs []string   `json:"requester_ids,omitempty"`
+}
+
+// IDPEntry is an IdP the requester would accept to authenticate the user
+type IDPEntry struct {
+	ProviderID string `json:"provider_id"`
+	Name       string `json:"name,omitempty"`
+	Loc        string `json:"loc,omitempty"`
+}
+
+// RequestedAuthnContext contains the authentication contexts an
+// AuthnRequest asks for
+type RequestedAuthnContext struct {
+	// Comparison is exact, minimum, better or maximum; empty means exact
+	Comparison string   `json:"comparison,omitempty"`
+	ClassRefs  []string `json:"class_refs,omitempty"`
+	DeclRefs   []string `json:"decl_refs,omitempty"`
 }
 
 // AuthnStatement contains authentication statement information
@@ -102,7 +325,19 @@ type SignatureInfo struct {
 	Signed          bool   `json:"signed"`
 	SignatureMethod string `json:"signature_method,omitempty"`
 	DigestMethod    string `json:"digest_method,omitempty"`
+	// ReferenceURI is the URI of the signed reference. Enveloped signatures
+	// use a same-document fragment ("#id"); anything else is detached.
+	ReferenceURI    string           `json:"reference_uri,omitempty"`
 	CertificateInfo *CertificateInfo `json:"certificate_info,omitempty"`
+	// Certificates holds every certificate in KeyInfo, starting with the
+	// one described by CertificateInfo
+	Certificates []*CertificateInfo `json:"certificates,omitempty"`
+}
+
+// IsDetached reports whether the signature covers an external reference
+// rather than an element of the same document
+func (s *SignatureInfo) IsDetached() bool {
+	return s.ReferenceURI != "" && !strings.HasPrefix(s.ReferenceURI, "#")
 }
 
 // CertificateInfo contains information about the signing certificate
@@ -112,4 +347,7 @@ type CertificateInfo struct {
 	NotBefore  time.Time `json:"not_before,omitempty"`
 	NotAfter   time.Time `json:"not_after,omitempty"`
 	Serial     string    `json:"serial,omitempty"`
+
+	SHA1Fingerprint   string `json:"sha1_fingerprint,omitempty"`
+	SHA256Fingerprint string `json:"sha256_fingerprint,omitempty"`
 }
@@ -0,0 +1,110 @@
+package saml
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// URLNormalization controls how URLs are normalized before Destination,
+// Recipient and Audience values are compared. Strict string comparison
+// reports mismatches for URLs that every SAML stack treats as equal.
+type URLNormalization struct {
+	// TrimTrailingSlash ignores a trailing slash on the path
+	TrimTrailingSlash bool `json:"trim_trailing_slash"`
+
+	// StripDefaultPort ignores :443 for https and :80 for http
+	StripDefaultPort bool `json:"strip_default_port"`
+
+	// IgnoreHostCase compares scheme and host case-insensitively
+	IgnoreHostCase bool `json:"ignore_host_case"`
+
+	// IgnorePathCase also compares the path case-insensitively
+	IgnorePathCase bool `json:"ignore_path_case"`
+}
+
+// DefaultURLNormalization returns the normalization used unless overridden.
+// Path case is significant by default, as most web servers treat it so.
+func DefaultURLNormalization() URLNormalization {
+	return URLNormalization{
+		TrimTrailingSlash: true,
+		StripDefaultPort:  true,
+		IgnoreHostCase:    true,
+	}
+}
+
+// StrictURLNormalization returns a normalization that compares URLs as-is
+func StrictURLNormalization() URLNormalization {
+	return URLNormalization{}
+}
+
+// urlRules are the names of the URLNormalization rules in
+// ParseURLNormalization, matching their JSON field names
+var urlRules = []string{"trim_trailing_slash", "strip_default_port", "ignore_host_case", "ignore_path_case"}
+
+// ParseURLNormalization parses a comma-separated list of the rules to
+// apply, e.g. "trim_trailing_slash,ignore_host_case". "none" compares URLs
+// as-is.
+func ParseURLNormalization(s string) (URLNormalization, error) {
+	var n URLNormalization
+	if strings.TrimSpace(s) == "none" {
+		return n, nil
+	}
+	for _, rule := range strings.Split(s, ",") {
+		switch strings.TrimSpace(rule) {
+		case "trim_trailing_slash":
+			n.TrimTrailingSlash = true
+		case "strip_default_port":
+			n.StripDefaultPort = true
+		case "ignore_host_case":
+			n.IgnoreHostCase = true
+		case "ignore_path_case":
+			n.IgnorePathCase = true
+		default:
+			return n, fmt.Errorf("unknown URL normalization rule %q: expected none or %s", strings.TrimSpace(rule), strings.Join(urlRules, ", "))
+		}
+	}
+	return n, nil
+}
+
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// Normalize applies the normalization rules to raw. Values that are not
+// absolute URLs, such as URN entity IDs, are only trimmed.
+func (n URLNormalization) Normalize(raw string) string {
+	raw = strings.TrimSpace(raw)
+
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		if n.TrimTrailingSlash {
+			raw = strings.TrimSuffix(raw, "/")
+		}
+		return raw
+	}
+
+	if n.IgnoreHostCase {
+		u.Scheme = strings.ToLower(u.Scheme)
+		u.Host = strings.ToLower(u.Host)
+	}
+	if n.StripDefaultPort && u.Port() != "" && defaultPorts[strings.ToLower(u.Scheme)] == u.Port() {
+		u.Host = u.Hostname()
+	}
+	if n.TrimTrailingSlash {
+		u.Path = strings.TrimSuffix(u.Path, "/")
+		u.RawPath = strings.TrimSuffix(u.RawPath, "/")
+	}
+	if n.IgnorePathCase {
+		u.Path = strings.ToLower(u.Path)
+		u.RawPath = strings.ToLower(u.RawPath)
+	}
+
+	return u.String()
+}
+
+// Equal reports whether a and b are the same URL after normalization
+func (n URLNormalization) Equal(a, b string) bool {
+	return n.Normalize(a) == n.Normalize(b)
+}
@@ -0,0 +1,48 @@
+package saml
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestURLNormalization_Equal(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b    string
+		norm    URLNormalization
+		isEqual bool
+	}{
+		{"identical", "https://sp.example.com/acs", "https://sp.example.com/acs", StrictURLNormalization(), true},
+		{"trailing slash", "https://sp.example.com/acs/", "https://sp.example.com/acs", DefaultURLNormalization(), true},
+		{"trailing slash strict", "https://sp.example.com/acs/", "https://sp.example.com/acs", StrictURLNormalization(), false},
+		{"default https port", "https://sp.example.com:443/acs", "https://sp.example.com/acs", DefaultURLNormalization(), true},
+		{"default http port", "http://sp.example.com:80/acs", "http://sp.example.com/acs", DefaultURLNormalization(), true},
+		{"non-default port", "https://sp.example.com:8443/acs", "https://sp.example.com/acs", DefaultURLNormalization(), false},
+		{"host case", "HTTPS://SP.Example.com/acs", "https://sp.example.com/acs", DefaultURLNormalization(), true},
+		{"path case", "https://sp.example.com/ACS", "https://sp.example.com/acs", DefaultURLNormalization(), false},
+		{"path case ignored", "https://sp.example.com/ACS", "https://sp.example.com/acs", URLNormalization{IgnorePathCase: true}, true},
+		{"urn entity id", "urn:example:sp", "urn:example:sp", DefaultURLNormalization(), true},
+		{"urn entity id differs", "urn:example:sp", "urn:example:other", DefaultURLNormalization(), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.isEqual, tt.norm.Equal(tt.a, tt.b))
+		})
+	}
+}
+
+func TestParseURLNormalization(t *testing.T) {
+	n, err := ParseURLNormalization("trim_trailing_slash, ignore_path_case")
+	require.NoError(t, err)
+	assert.Equal(t, URLNormalization{TrimTrailingSlash: true, IgnorePathCase: true}, n)
+
+	n, err = ParseURLNormalization("none")
+	require.NoError(t, err)
+	assert.Equal(t, StrictURLNormalization(), n)
+
+	_, err = ParseURLNormalization("ignore_query")
+	assert.EqualError(t, err, `unknown URL normalization rule "ignore_query": expected none or trim_trailing_slash, strip_default_port, ignore_host_case, ignore_path_case`)
+}
@@ -0,0 +1,231 @@
+package saml
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CheckOptions configures the checks performed by WarningsWithOptions
+type CheckOptions struct {
+	// Now is the time conditions and certificates are evaluated against
+	Now time.Time
+
+	// Sent reports that Now is when the message was sent, such as the
+	// start of a HAR entry, rather than when it is inspected
+	Sent bool
+
+	// ClockSkew is tolerated on either side of validity windows, as SPs
+	// allow for clocks that are slightly off
+	ClockSkew time.Duration
+
+	// DeliveredTo is the URL the message was sent to. Destination and
+	// Recipient are compared against it when set.
+	DeliveredTo string
+
+	// ExpectedAudience is the SP entity ID that must be among the
+	// audiences when set
+	ExpectedAudience string
+
+	// URLs controls how URLs are normalized before they are compared
+	URLs URLNormalization
+}
+
+// Warnings returns human-readable warnings about common problems in a parsed
+// SAML message, such as failed status, expired conditions or missing
+// signatures. Times are evaluated against now.
+func Warnings(info *SAMLInfo, now time.Time) []string {
+	return WarningsWithOptions(info, CheckOptions{Now: now, URLs: DefaultURLNormalization()})
+}
+
+// WarningsWithOptions is like Warnings, but also compares Destination,
+// Recipient and Audience values against the expectations in opts
+func WarningsWithOptions(info *SAMLInfo, opts CheckOptions) []string {
+	return collectWarnings(info, opts, false)
+}
+
+// collectWarnings gathers the warnings for info. Signature presence of an embedded
+// assertion is judged together with its enclosing response.
+func collectWarnings(info *SAMLInfo, opts CheckOptions, embedded bool) []string {
+	var warnings []string
+	now := opts.Now
+
+	if info.Status != nil && info.Status.StatusCode != "Success" {
+		msg := fmt.Sprintf("status is %s", info.Status.StatusCode)
+		if info.Status.SubStatusCode != "" {
+			msg += "/" + info.Status.SubStatusCode
+		}
+		if info.Status.StatusMessage != "" {
+			msg += ": " + info.Status.StatusMessage
+		}
+		warnings = append(warnings, msg)
+	}
+
+	if info.Conditions != nil {
+		switch timing, by := CheckWindow(info.Conditions.NotBefore, info.Conditions.NotOnOrAfter, now, opts.ClockSkew); timing {
+		case TimingEarly:
+			state := "is not yet valid"
+			if opts.Sent {
+				state = "was not yet valid when sent"
+			}
+			warnings = append(warnings, fmt.Sprintf("assertion %s (NotBefore %s, %s early%s)",
+				state, info.Conditions.NotBefore.Format(time.RFC3339), FormatDuration(by), skewNote(opts.ClockSkew)))
+		case TimingExpired:
+			if opts.Sent {
+				warnings = append(warnings, fmt.Sprintf("assertion was already expired when sent (NotOnOrAfter %s, %s before it was sent%s)",
+					info.Conditions.NotOnOrAfter.Format(time.RFC3339), FormatDuration(by), skewNote(opts.ClockSkew)))
+			} else {
+				warnings = append(warnings, fmt.Sprintf("assertion has expired (NotOnOrAfter %s, %s ago%s)",
+					info.Conditions.NotOnOrAfter.Format(time.RFC3339), FormatDuration(by), skewNote(opts.ClockSkew)))
+			}
+		}
+		if len(info.Conditions.AudienceRestriction) == 0 {
+			warnings = append(warnings, "no audience restriction")
+		} else if opts.ExpectedAudience != "" && !containsURL(opts.URLs, info.Conditions.AudienceRestriction, opts.ExpectedAudience) {
+			warnings = append(warnings, fmt.Sprintf("audience restriction does not include %s", opts.ExpectedAudience))
+		}
+		for _, cond := range info.Conditions.Other {
+			if _, ok := cond.(*UnknownCondition); ok {
+				warnings = append(warnings, fmt.Sprintf("assertion has the condition %s, which a relying party that does not understand it must treat as indeterminate", cond.ConditionType()))
+			}
+		}
+	}
+
+	if info.AuthnStatement != nil && info.AuthnStatement.SessionNotOnOrAfter != nil {
+		if timing, by := CheckWindow(nil, info.AuthnStatement.SessionNotOnOrAfter, now, opts.ClockSkew); timing == TimingExpired {
+			state, ago := "has expired", "ago"
+			if opts.Sent {
+				state, ago = "had already expired when sent", "before it was sent"
+			}
+			warnings = append(warnings, fmt.Sprintf("session %s (SessionNotOnOrAfter %s, %s %s%s)",
+				state, info.AuthnStatement.SessionNotOnOrAfter.Format(time.RFC3339), FormatDuration(by), ago, skewNote(opts.ClockSkew)))
+		}
+	}
+
+	if opts.DeliveredTo != "" {
+		if info.Destination != "" && !opts.URLs.Equal(info.Destination, opts.DeliveredTo) {
+			warnings = append(warnings, fmt.Sprintf("destination %s does not match %s", info.Destination, opts.DeliveredTo))
+		}
+		if info.Subject != nil && info.Subject.Recipient != "" && !opts.URLs.Equal(info.Subject.Recipient, opts.DeliveredTo) {
+			warnings = append(warnings, fmt.Sprintf("recipient %s does not match %s", info.Subject.Recipient, opts.DeliveredTo))
+		}
+	}
+
+	if info.Signature != nil && info.Signature.IsDetached() {
+		warnings = append(warnings, fmt.Sprintf("signature covers external reference %s, which is not resolved or verified", info.Signature.ReferenceURI))
+	}
+
+	if info.Signature != nil && info.Signature.CertificateInfo != nil {
+		cert := info.Signature.CertificateInfo
+		// NotAfter is the last valid instant of a certificate
+		notOnOrAfter := cert.NotAfter.Add(time.Second)
+		switch timing, by := CheckWindow(&cert.NotBefore, &notOnOrAfter, now, opts.ClockSkew); timing {
+		case TimingEarly:
+			warnings = append(warnings, fmt.Sprintf("signing certificate is not yet valid (valid from %s, %s early%s)",
+				cert.NotBefore.Format(time.RFC3339), FormatDuration(by), skewNote(opts.ClockSkew)))
+		case TimingExpired:
+			warnings = append(warnings, fmt.Sprintf("signing certificate expired on %s (%s ago%s)",
+				cert.NotAfter.Format(time.RFC3339), FormatDuration(now.Sub(cert.NotAfter)), skewNote(opts.ClockSkew)))
+		}
+	}
+
+	if !embedded && info.Type == "Assertion" && (info.Signature == nil || !info.Signature.Signed) {
+		warnings = append(warnings, "assertion is not signed")
+	}
+
+	if info.Assertion != nil {
+		for _, w := range collectWarnings(info.Assertion, opts, true) {
+			warnings = append(warnings, "assertion: "+w)
+		}
+		if info.Signature == nil && info.Assertion.Signature == nil {
+			warnings = append(warnings, "neither response nor assertion is signed")
+		}
+		// Without a delivery URL, at least the two should agree
+		if opts.DeliveredTo == "" && info.Destination != "" && info.Assertion.Subject != nil &&
+			info.Assertion.Subject.Recipient != "" && !opts.URLs.Equal(info.Destination, info.Assertion.Subject.Recipient) {
+			warnings = append(warnings, fmt.Sprintf("recipient %s does not match destination %s", info.Assertion.Subject.Recipient, info.Destination))
+		}
+	}
+
+	return warnings
+}
+
+// Timing is the position of a reference time relative to a validity window
+type Timing int
+
+const (
+	TimingValid Timing = iota
+	TimingEarly
+	TimingExpired
+)
+
+// CheckWindow reports whether now falls within [notBefore, notOnOrAfter),
+// tolerating skew on both ends, and how far before the start or after the
+// end of the window now is. A nil bound leaves the window open on that side.
+func CheckWindow(notBefore, notOnOrAfter *time.Time, now time.Time, skew time.Duration) (Timing, time.Duration) {
+	if notBefore != nil && now.Add(skew).Before(*notBefore) {
+		return TimingEarly, notBefore.Sub(now)
+	}
+	if notOnOrAfter != nil && !now.Add(-skew).Before(*notOnOrAfter) {
+		return TimingExpired, now.Sub(*notOnOrAfter)
+	}
+	return TimingValid, 0
+}
+
+// Expired reports whether the Conditions of a message or of its assertion
+// had expired at now, tolerating skew
+func Expired(info *SAMLInfo, now time.Time, skew time.Duration) bool {
+	for ; info != nil; info = info.Assertion {
+		if info.Conditions == nil {
+			continue
+		}
+		if timing, _ := CheckWindow(nil, info.Conditions.NotOnOrAfter, now, skew); timing == TimingExpired {
+			return true
+		}
+	}
+	return false
+}
+
+// FormatDuration renders d to the second without zero units, e.g. "1h",
+// "4m30s" or "400d3h"
+func FormatDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	if d < 0 {
+		return "-" + FormatDuration(-d)
+	}
+	days := d / (24 * time.Hour)
+	d -= days * 24 * time.Hour
+
+	s := ""
+	if d > 0 || days == 0 {
+		s = d.String()
+		if strings.HasSuffix(s, "m0s") {
+			s = strings.TrimSuffix(s, "0s")
+		}
+		if strings.HasSuffix(s, "h0m") {
+			s = strings.TrimSuffix(s, "0m")
+		}
+	}
+	if days > 0 {
+		s = fmt.Sprintf("%dd", days) + s
+	}
+	return s
+}
+
+// skewNote mentions the tolerated clock skew in warnings
+func skewNote(skew time.Duration) string {
+	if skew <= 0 {
+		return ""
+	}
+	return ", beyond the " + FormatDuration(skew) + " clock skew"
+}
+
+// containsURL reports whether target is among values after normalization
+func containsURL(n URLNormalization, values []string, target string) bool {
+	for _, v := range values {
+		if n.Equal(v, target) {
+			return true
+		}
+	}
+	return false
+}
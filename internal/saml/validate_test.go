@@ -0,0 +1,211 @@
+package saml
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWarnings(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	past := now.Add(-time.Hour)
+	future := now.Add(time.Hour)
+
+	tests := []struct {
+		name string
+		info *SAMLInfo
+		want []string
+	}{
+		{
+			name: "valid signed assertion",
+			info: &SAMLInfo{
+				Type:       "Assertion",
+				Conditions: &Conditions{NotBefore: &past, NotOnOrAfter: &future, AudienceRestriction: []string{"https://sp.example.com"}},
+				Signature:  &SignatureInfo{Signed: true},
+			},
+			want: nil,
+		},
+		{
+			name: "failed status",
+			info: &SAMLInfo{
+				Type:      "Response",
+				Status:    &Status{StatusCode: "Requester", StatusMessage: "Invalid request"},
+				Signature: &SignatureInfo{Signed: true},
+			},
+			want: []string{"status is Requester: Invalid request"},
+		},
+		{
+			name: "expired unsigned assertion",
+			info: &SAMLInfo{
+				Type:       "Assertion",
+				Conditions: &Conditions{NotOnOrAfter: &past, AudienceRestriction: []string{"sp"}},
+			},
+			want: []string{
+				"assertion has expired (NotOnOrAfter 2024-01-15T11:00:00Z, 1h ago)",
+				"assertion is not signed",
+			},
+		},
+		{
+			name: "not yet valid without audience",
+			info: &SAMLInfo{
+				Type:       "Assertion",
+				Conditions: &Conditions{NotBefore: &future},
+				Signature:  &SignatureInfo{Signed: true},
+			},
+			want: []string{
+				"assertion is not yet valid (NotBefore 2024-01-15T13:00:00Z, 1h early)",
+				"no audience restriction",
+			},
+		},
+		{
+			name: "unsigned response with embedded assertion",
+			info: &SAMLInfo{
+				Type:      "Response",
+				Status:    &Status{StatusCode: "Success"},
+				Assertion: &SAMLInfo{Type: "Assertion"},
+			},
+			want: []string{"neither response nor assertion is signed"},
+		},
+		{
+			name: "expired signing certificate",
+			info: &SAMLInfo{
+				Type:      "Assertion",
+				Signature: &SignatureInfo{Signed: true, CertificateInfo: &CertificateInfo{NotAfter: past}},
+			},
+			want: []string{"signing certificate expired on 2024-01-15T11:00:00Z (1h ago)"},
+		},
+		{
+			name: "detached signature",
+			info: &SAMLInfo{
+				Type:      "Assertion",
+				Signature: &SignatureInfo{Signed: true, ReferenceURI: "https://idp.example.com/artifact/1"},
+			},
+			want: []string{"signature covers external reference https://idp.example.com/artifact/1, which is not resolved or verified"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Warnings(tt.info, now))
+		})
+	}
+}
+
+func TestWarningsWithOptions_ClockSkew(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	notBefore := now.Add(2 * time.Minute)
+	notOnOrAfter := now.Add(-10 * time.Minute)
+	sessionEnd := now.Add(-90 * time.Second)
+	info := &SAMLInfo{
+		Type:           "Assertion",
+		Conditions:     &Conditions{NotBefore: &notBefore, AudienceRestriction: []string{"sp"}},
+		AuthnStatement: &AuthnStatement{SessionNotOnOrAfter: &sessionEnd},
+		Signature:      &SignatureInfo{Signed: true, CertificateInfo: &CertificateInfo{NotBefore: now.Add(-time.Hour), NotAfter: now.Add(400 * 24 * time.Hour)}},
+	}
+
+	assert.Equal(t, []string{
+		"assertion is not yet valid (NotBefore 2024-01-15T12:02:00Z, 2m early)",
+		"session has expired (SessionNotOnOrAfter 2024-01-15T11:58:30Z, 1m30s ago)",
+	}, WarningsWithOptions(info, CheckOptions{Now: now}))
+
+	// Within the tolerated skew both are accepted
+	assert.Empty(t, WarningsWithOptions(info, CheckOptions{Now: now, ClockSkew: 5 * time.Minute}))
+
+	info.Conditions.NotBefore = nil
+	info.Conditions.NotOnOrAfter = &notOnOrAfter
+	assert.Equal(t, []string{
+		"assertion has expired (NotOnOrAfter 2024-01-15T11:50:00Z, 10m ago, beyond the 5m clock skew)",
+	}, WarningsWithOptions(info, CheckOptions{Now: now, ClockSkew: 5 * time.Minute}))
+
+	// Evaluated at the time the message was sent
+	assert.Equal(t, []string{
+		"assertion was already expired when sent (NotOnOrAfter 2024-01-15T11:50:00Z, 10m before it was sent)",
+		"session had already expired when sent (SessionNotOnOrAfter 2024-01-15T11:58:30Z, 1m30s before it was sent)",
+	}, WarningsWithOptions(info, CheckOptions{Now: now, Sent: true}))
+}
+
+func TestCheckWindow(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	end := now
+
+	// NotOnOrAfter is exclusive
+	timing, by := CheckWindow(nil, &end, now, 0)
+	assert.Equal(t, TimingExpired, timing)
+	assert.Zero(t, by)
+
+	timing, _ = CheckWindow(nil, &end, now, time.Second)
+	assert.Equal(t, TimingValid, timing)
+
+	timing, by = CheckWindow(nil, nil, now, 0)
+	assert.Equal(t, TimingValid, timing)
+	assert.Zero(t, by)
+}
+
+func TestExpired(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	past := now.Add(-time.Minute)
+	future := now.Add(time.Minute)
+
+	assert.False(t, Expired(&SAMLInfo{}, now, 0))
+	assert.False(t, Expired(&SAMLInfo{Conditions: &Conditions{NotOnOrAfter: &future}}, now, 0))
+	assert.True(t, Expired(&SAMLInfo{Assertion: &SAMLInfo{Conditions: &Conditions{NotOnOrAfter: &past}}}, now, 0), "assertion conditions count")
+	assert.False(t, Expired(&SAMLInfo{Conditions: &Conditions{NotOnOrAfter: &past}}, now, 2*time.Minute), "skew is tolerated")
+}
+
+func TestFormatDuration(t *testing.T) {
+	assert.Equal(t, "0s", FormatDuration(0))
+	assert.Equal(t, "45s", FormatDuration(45*time.Second+300*time.Millisecond))
+	assert.Equal(t, "4m30s", FormatDuration(270*time.Second))
+	assert.Equal(t, "5m", FormatDuration(5*time.Minute))
+	assert.Equal(t, "1h", FormatDuration(time.Hour))
+	assert.Equal(t, "1h5m", FormatDuration(65*time.Minute))
+	assert.Equal(t, "2d", FormatDuration(48*time.Hour))
+	assert.Equal(t, "400d3h", FormatDuration(400*24*time.Hour+3*time.Hour))
+	assert.Equal(t, "-2m", FormatDuration(-2*time.Minute))
+}
+
+func TestWarningsWithOptions_URLs(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	response := &SAMLInfo{
+		Type:        "Response",
+		Destination: "https://SP.example.com:443/acs/",
+		Signature:   &SignatureInfo{Signed: true},
+		Assertion: &SAMLInfo{
+			Type:       "Assertion",
+			Subject:    &Subject{Recipient: "https://sp.example.com/acs"},
+			Conditions: &Conditions{AudienceRestriction: []string{"https://sp.example.com/"}},
+		},
+	}
+
+	t.Run("normalized match", func(t *testing.T) {
+		opts := CheckOptions{
+			Now:              now,
+			DeliveredTo:      "https://sp.example.com/acs",
+			ExpectedAudience: "https://sp.example.com",
+			URLs:             DefaultURLNormalization(),
+		}
+		assert.Empty(t, WarningsWithOptions(response, opts))
+	})
+
+	t.Run("strict comparison", func(t *testing.T) {
+		opts := CheckOptions{
+			Now:              now,
+			DeliveredTo:      "https://sp.example.com/acs",
+			ExpectedAudience: "https://sp.example.com",
+			URLs:             StrictURLNormalization(),
+		}
+		assert.Equal(t, []string{
+			"destination https://SP.example.com:443/acs/ does not match https://sp.example.com/acs",
+			"assertion: audience restriction does not include https://sp.example.com",
+		}, WarningsWithOptions(response, opts))
+	})
+
+	t.Run("recipient differs from destination", func(t *testing.T) {
+		info := *response
+		info.Destination = "https://sp.example.com/other"
+		assert.Equal(t, []string{
+			"recipient https://sp.example.com/acs does not match destination https://sp.example.com/other",
+		}, Warnings(&info, now))
+	})
+}
@@ -0,0 +1,288 @@
+package saml
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/beevik/etree"
+)
+
+// Vendor names returned by DetectVendor and FingerprintVendor
+const (
+	VendorUnknown       = "Unknown"
+	VendorEntraID       = "Microsoft Entra ID"
+	VendorADFS          = "Microsoft AD FS"
+	VendorOkta          = "Okta"
+	VendorKeycloak      = "Keycloak"
+	VendorShibboleth    = "Shibboleth"
+	VendorPingFederate  = "PingFederate"
+	VendorOneLogin      = "OneLogin"
+	VendorSimpleSAMLphp = "SimpleSAMLphp"
+)
+
+// vendorIssuerPatterns maps substrings of issuer URIs to IdP products.
+// Patterns are checked in order, so more specific ones come first.
+var vendorIssuerPatterns = []struct {
+	pattern string
+	vendor  string
+}{
+	{"sts.windows.net", VendorEntraID},
+	{"login.microsoftonline.com", VendorEntraID},
+	{"/adfs/", VendorADFS},
+	{"okta.com", VendorOkta},
+	{"oktapreview.com", VendorOkta},
+	{"auth0.com", "Auth0"},
+	{"onelogin.com", VendorOneLogin},
+	{"pingone.com", "PingOne"},
+	{"pingidentity.com", VendorPingFederate},
+	{"pingfederate", VendorPingFederate},
+	{"accounts.google.com", "Google Workspace"},
+	{"/realms/", VendorKeycloak},
+	{"/idp/shibboleth", VendorShibboleth},
+	{"jumpcloud.com", "JumpCloud"},
+	{"duosecurity.com", "Duo"},
+	{"/simplesaml/", VendorSimpleSAMLphp},
+}
+
+// DetectVendor guesses the IdP product that issued a message from its
+// issuer URI. It returns VendorUnknown if no pattern matches.
+func DetectVendor(info *SAMLInfo) string {
+	issuer := info.Issuer
+	if issuer == "" && info.Assertion != nil {
+		issuer = info.Assertion.Issuer
+	}
+	issuer = strings.ToLower(issuer)
+
+	for _, p := range vendorIssuerPatterns {
+		if strings.Contains(issuer, p.pattern) {
+			return p.vendor
+		}
+	}
+	return VendorUnknown
+}
+
+// Confidence levels of a VendorFingerprint
+const (
+	ConfidenceHigh   = "high"
+	ConfidenceMedium = "medium"
+	ConfidenceLow    = "low"
+)
+
+// VendorFingerprint is the IdP product that most likely produced a
+// message, with the evidence found and hints for troubleshooting it
+type VendorFingerprint struct {
+	Vendor     string   `json:"vendor"`
+	Confidence string   `json:"confidence"`
+	Evidence   []string `json:"evidence"`
+	Hints      []string `json:"hints,omitempty"`
+}
+
+// vendorHints are troubleshooting hints for each IdP product, about the
+// settings that most often break SSO with it
+var vendorHints = map[string][]string{
+	VendorEntraID: {
+		"Identifier (Entity ID) and Reply URL of the enterprise application must match the SP's entity ID and ACS URL exactly",
+		"Only the assertion is signed by default; set the signing option to \"Sign SAML response and assertion\" if the SP requires a signed Response",
+		"Users in more than 150 groups get a groups.link claim instead of their groups",
+		"Errors shown to the user carry an AADSTS code, e.g. AADSTS50011 for a Reply URL mismatch",
+	},
+	VendorADFS: {
+		"Claims come from the issuance transform rules of the relying party trust; a missing attribute usually has no rule emitting it",
+		"A NameID needs a rule transforming a claim to Name ID with the requested format, or AD FS answers InvalidNameIDPolicy",
+		"Only the assertion is signed by default; Set-AdfsRelyingPartyTrust -SamlResponseSignature MessageAndAssertion signs the Response too",
+		"Failures are logged as event 364 in the AD FS/Admin event log, with the reason the Responder status hides",
+	},
+	VendorOkta: {
+		"Audience URI (SP Entity ID) and Single sign-on URL of the app must match the SP exactly",
+		"Attributes come from the app's attribute statements; groups need a group attribute statement with a filter",
+		"The signing certificate is per app; download it from the app's Sign On tab, not the org",
+	},
+	VendorKeycloak: {
+		"The client ID must equal the SP's entity ID",
+		"\"Sign documents\" and \"Sign assertions\" are separate client settings; the SP may require either",
+		"Attributes are added by the client's mappers; without a mapper nothing but the NameID is sent",
+	},
+	VendorShibboleth: {
+		"Attributes are released by attribute-filter.xml; a missing attribute usually has no release rule for the SP",
+		"Assertions are encrypted by default; the SP's metadata must hold its encryption certificate",
+		"Persistent NameIDs are configured in saml-nameid.xml; see nameid compute to check computed IDs",
+	},
+	VendorPingFederate: {
+		"The SP connection's entity ID and ACS URLs must match the SP's metadata",
+		"Attributes come from the attribute contract of the SP connection and its data store lookups",
+		"Failures are logged to the server log with the SP connection's partner entity ID",
+	},
+	VendorOneLogin: {
+		"The app's Audience, Recipient and ACS URL validator must match the SP; the validator is a regular expression",
+		"The SAML signature element setting chooses whether the Response, the Assertion or both are signed",
+	},
+	VendorSimpleSAMLphp: {
+		"The SP must be listed in metadata/saml20-sp-remote.php with its ACS URL",
+		"Attributes are released by authproc filters; check the filters of the SP and the IdP",
+	},
+}
+
+// vendorEvidence accumulates scores for IdP products with the reasons
+type vendorEvidence struct {
+	scores   map[string]int
+	evidence map[string][]string
+}
+
+func (e *vendorEvidence) add(weight int, reason string, vendors ...string) {
+	for _, vendor := range vendors {
+		e.scores[vendor] += weight
+		e.evidence[vendor] = append(e.evidence[vendor], reason)
+	}
+}
+
+var (
+	guidIDPattern       = regexp.MustCompile(`^_[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+	oktaIDPattern       = regexp.MustCompile(`^id[0-9]{15,}$`)
+	keycloakIDPattern   = regexp.MustCompile(`^ID_[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+	shibbolethIDPattern = regexp.MustCompile(`^_[0-9a-f]{32}$`)
+	simpleSAMLIDPattern = regexp.MustCompile(`^_[0-9a-f]{42}$`)
+	oneLoginIDPattern   = regexp.MustCompile(`^(pfx[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}|[RA][0-9a-f]{40})$`)
+)
+
+// FingerprintVendor identifies the IdP product that produced a message
+// from its issuer, the namespace prefixes and ID style of its elements,
+// the claim URIs of its attributes and the style of its signatures. It
+// returns nil if the evidence points to no product.
+func FingerprintVendor(xmlData []byte, info *SAMLInfo) *VendorFingerprint {
+	e := &vendorEvidence{scores: map[string]int{}, evidence: map[string][]string{}}
+
+	if vendor := DetectVendor(info); vendor != VendorUnknown {
+		e.add(4, "issuer matches the product's entity ID pattern", vendor)
+	}
+
+	if doc, err := ReadDocument(xmlData); err == nil && doc.Root() != nil {
+		fingerprintXML(doc.Root(), e)
+	}
+	fingerprintAttributes(info, e)
+
+	// Products are ranked by score, then by name for a stable result
+	var best string
+	for vendor, score := range e.scores {
+		if best == "" || score > e.scores[best] || score == e.scores[best] && vendor < best {
+			best = vendor
+		}
+	}
+	if best == "" || e.scores[best] < 2 {
+		return nil
+	}
+
+	fp := &VendorFingerprint{Vendor: best, Evidence: e.evidence[best], Hints: vendorHints[best]}
+	switch score := e.scores[best]; {
+	case score >= 5:
+		fp.Confidence = ConfidenceHigh
+	case score >= 3:
+		fp.Confidence = ConfidenceMedium
+	default:
+		fp.Confidence = ConfidenceLow
+	}
+	// Evidence shared by products, such as the ID style of AD FS and
+	// Entra ID, cannot tell them apart
+	for vendor, score := range e.scores {
+		if vendor != best && score == e.scores[best] {
+			fp.Confidence = ConfidenceLow
+			fp.Evidence = append(fp.Evidence, "the evidence fits "+vendor+" equally well")
+		}
+	}
+	return fp
+}
+
+// fingerprintXML scores the namespace prefixes, IDs and signatures of a
+// message and its assertions
+func fingerprintXML(root *etree.Element, e *vendorEvidence) {
+	elements := findElements(root, SAMLNamespace, "Assertion")
+	if len(elements) == 0 || elements[0] != root {
+		elements = append([]*etree.Element{root}, elements...)
+	}
+
+	seenIDs := map[string]bool{}
+	for _, el := range elements {
+		id := el.SelectAttrValue("ID", "")
+		if id == "" || seenIDs[id] {
+			continue
+		}
+		seenIDs[id] = true
+		switch {
+		case guidIDPattern.MatchString(id):
+			e.add(2, fmt.Sprintf("ID %s is an underscore followed by a GUID", id), VendorEntraID, VendorADFS)
+		case oktaIDPattern.MatchString(id):
+			e.add(2, fmt.Sprintf("ID %s is \"id\" followed by digits", id), VendorOkta)
+		case keycloakIDPattern.MatchString(id):
+			e.add(2, fmt.Sprintf("ID %s is \"ID_\" followed by a UUID", id), VendorKeycloak)
+		case shibbolethIDPattern.MatchString(id):
+			e.add(1, fmt.Sprintf("ID %s is an underscore followed by 32 hex digits", id), VendorShibboleth)
+		case simpleSAMLIDPattern.MatchString(id):
+			e.add(2, fmt.Sprintf("ID %s is an underscore followed by 42 hex digits", id), VendorSimpleSAMLphp)
+		case oneLoginIDPattern.MatchString(id):
+			e.add(2, fmt.Sprintf("ID %s has the OneLogin style", id), VendorOneLogin)
+		}
+	}
+
+	prefixes := map[string]bool{}
+	for _, el := range elements {
+		if ns := el.NamespaceURI(); ns == SAMLNamespace || ns == SAMLPNamespace {
+			prefixes[el.Space] = true
+		}
+	}
+	switch {
+	case prefixes["saml2p"] || prefixes["saml2"]:
+		e.add(1, "elements use the saml2p: and saml2: prefixes of OpenSAML", VendorShibboleth, VendorOkta)
+	case prefixes[""]:
+		e.add(1, "elements use a default namespace without prefix", VendorEntraID, VendorADFS)
+	}
+
+	// The first signature tells the style; IdPs sign all elements alike
+	signatures := findElements(root, XMLDSigNamespace, "Signature")
+	if len(signatures) == 0 {
+		return
+	}
+	if signatures[0].Space == "dsig" {
+		e.add(2, "signatures use the dsig: prefix", VendorKeycloak)
+	}
+	for _, inclusive := range findElements(signatures[0], ExcC14N10Algorithm, "InclusiveNamespaces") {
+		switch inclusive.SelectAttrValue("PrefixList", "") {
+		case "xs":
+			e.add(1, `canonicalization includes the "xs" prefix`, VendorOkta)
+		case "xsd":
+			e.add(1, `canonicalization includes the "xsd" prefix`, VendorShibboleth)
+		}
+	}
+}
+
+// fingerprintAttributes scores the claim URIs and authentication context
+// of a message
+func fingerprintAttributes(info *SAMLInfo, e *vendorEvidence) {
+	var attributes []Attribute
+	authnContext := ""
+	for i := info; i != nil; i = i.Assertion {
+		attributes = append(attributes, i.Attributes...)
+		if i.AuthnStatement != nil && authnContext == "" {
+			authnContext = i.AuthnStatement.AuthnContextClassRef
+		}
+	}
+
+	claims, entraClaims := 0, 0
+	for _, attr := range attributes {
+		switch {
+		case strings.HasPrefix(attr.Name, "http://schemas.microsoft.com/identity/claims/"):
+			entraClaims++
+		case strings.HasPrefix(attr.Name, "http://schemas.xmlsoap.org/"), strings.HasPrefix(attr.Name, "http://schemas.microsoft.com/ws/2008/06/identity/claims/"):
+			claims++
+		}
+	}
+	switch {
+	case entraClaims > 0:
+		e.add(3, "attributes include Entra ID claims such as tenantid and objectidentifier", VendorEntraID)
+	case claims > 0:
+		e.add(2, "attributes are WS-Federation claim URIs without the Entra ID tenant claims", VendorADFS)
+	}
+
+	if strings.HasPrefix(authnContext, "http://schemas.microsoft.com/ws/2008/06/identity/authenticationmethod/") {
+		e.add(2, "the authentication context is an AD FS authentication method", VendorADFS)
+	}
+}
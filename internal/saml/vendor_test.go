@@ -0,0 +1,106 @@
+package saml
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectVendor(t *testing.T) {
+	tests := []struct {
+		issuer string
+		want   string
+	}{
+		{"https://sts.windows.net/0000-1111/", "Microsoft Entra ID"},
+		{"http://adfs.example.com/adfs/services/trust", "Microsoft AD FS"},
+		{"http://www.okta.com/exk123", "Okta"},
+		{"https://sso.example.com/realms/main", "Keycloak"},
+		{"https://idp.example.edu/idp/shibboleth", "Shibboleth"},
+		{"https://idp.example.com", VendorUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.issuer, func(t *testing.T) {
+			assert.Equal(t, tt.want, DetectVendor(&SAMLInfo{Issuer: tt.issuer}))
+		})
+	}
+}
+
+func TestDetectVendor_AssertionIssuer(t *testing.T) {
+	info := &SAMLInfo{Type: "Response", Assertion: &SAMLInfo{Issuer: "http://www.okta.com/exk123"}}
+	assert.Equal(t, "Okta", DetectVendor(info))
+}
+
+func TestFingerprintVendor(t *testing.T) {
+	tests := []struct {
+		name       string
+		xml        string
+		info       *SAMLInfo
+		vendor     string
+		confidence string
+	}{
+		{
+			name: "Entra ID claims",
+			xml: `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_8e8dc5f6-9c3a-4f5a-8c5e-1e2f3a4b5c6d">
+  <Assertion xmlns="urn:oasis:names:tc:SAML:2.0:assertion" ID="_0f1e2d3c-4b5a-6978-8796-a5b4c3d2e1f0"/>
+</samlp:Response>`,
+			info: &SAMLInfo{Type: "Response", Issuer: "https://idp.example.com", Assertion: &SAMLInfo{
+				Attributes: []Attribute{{Name: "http://schemas.microsoft.com/identity/claims/tenantid"}},
+			}},
+			vendor:     VendorEntraID,
+			confidence: ConfidenceHigh,
+		},
+		{
+			name: "AD FS authentication method",
+			xml:  `<Assertion xmlns="urn:oasis:names:tc:SAML:2.0:assertion" ID="_0f1e2d3c-4b5a-6978-8796-a5b4c3d2e1f0"/>`,
+			info: &SAMLInfo{Type: "Assertion", AuthnStatement: &AuthnStatement{
+				AuthnContextClassRef: "http://schemas.microsoft.com/ws/2008/06/identity/authenticationmethod/windows",
+			}},
+			vendor:     VendorADFS,
+			confidence: ConfidenceHigh,
+		},
+		{
+			name: "Microsoft ID style only",
+			xml:  `<Assertion xmlns="urn:oasis:names:tc:SAML:2.0:assertion" ID="_0f1e2d3c-4b5a-6978-8796-a5b4c3d2e1f0"/>`,
+			info: &SAMLInfo{Type: "Assertion"},
+			// Both Microsoft products fit; the first by name is reported
+			vendor:     VendorADFS,
+			confidence: ConfidenceLow,
+		},
+		{
+			name: "Okta",
+			xml: `<saml2p:Response xmlns:saml2p="urn:oasis:names:tc:SAML:2.0:protocol" ID="id41829512036713761457937845">
+  <ds:Signature xmlns:ds="http://www.w3.org/2000/09/xmldsig#"><ds:SignedInfo><ds:Reference><ds:Transforms><ds:Transform><ec:InclusiveNamespaces xmlns:ec="http://www.w3.org/2001/10/xml-exc-c14n#" PrefixList="xs"/></ds:Transform></ds:Transforms></ds:Reference></ds:SignedInfo></ds:Signature>
+</saml2p:Response>`,
+			info:       &SAMLInfo{Type: "Response", Issuer: "http://www.okta.com/exk123"},
+			vendor:     VendorOkta,
+			confidence: ConfidenceHigh,
+		},
+		{
+			name: "Keycloak",
+			xml: `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="ID_3b0f5a3c-7b8e-4a4e-9d2f-0c1b2a3d4e5f">
+  <dsig:Signature xmlns:dsig="http://www.w3.org/2000/09/xmldsig#"/>
+</samlp:Response>`,
+			info:       &SAMLInfo{Type: "Response", Issuer: "https://sso.example.com/auth"},
+			vendor:     VendorKeycloak,
+			confidence: ConfidenceMedium,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fp := FingerprintVendor([]byte(tt.xml), tt.info)
+			require.NotNil(t, fp)
+			assert.Equal(t, tt.vendor, fp.Vendor)
+			assert.Equal(t, tt.confidence, fp.Confidence)
+			assert.NotEmpty(t, fp.Evidence)
+			assert.NotEmpty(t, fp.Hints)
+		})
+	}
+}
+
+func TestFingerprintVendor_NoEvidence(t *testing.T) {
+	xml := `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_abc123"/>`
+	assert.Nil(t, FingerprintVendor([]byte(xml), &SAMLInfo{Type: "Response", Issuer: "https://idp.example.com"}))
+}
@@ -0,0 +1,135 @@
+package saml
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+
+	"github.com/beevik/etree"
+)
+
+// ErrUnsignedAssertion is returned for an assertion that is neither signed
+// nor covered by a signature of the message, next to signed ones
+var ErrUnsignedAssertion = errors.New("assertion is neither signed nor covered by a signed message; a signed assertion next to it may have been wrapped (XML Signature Wrapping)")
+
+// Verification verdicts
+const (
+	// VerdictVerified means every signature in the message verified
+	VerdictVerified = "verified"
+
+	// VerdictSigFail means a signature did not verify, e.g. because the
+	// signed content was modified
+	VerdictSigFail = "sigfail"
+
+	// VerdictUnsigned means no signature covers the message: it carries
+	// none, or only detached signatures over external content
+	VerdictUnsigned = "unsigned"
+
+	// VerdictSelfSigned means every signature verified, but only against
+	// the certificates in their own KeyInfo: the message was not modified
+	// after signing, but anyone may have signed it
+	VerdictSelfSigned = "selfsigned"
+)
+
+// MessageVerification is the outcome of verifying the enveloped
+// signatures of a SAML message and its assertions
+type MessageVerification struct {
+	Verdict string
+
+	// Verified lists the elements whose signature verified, e.g.
+	// "Response" and "Assertion", followed by the reference URI for a
+	// detached signature, e.g. "Response (cid:policy.xml)"
+	Verified []string
+
+	// Err explains a sigfail verdict
+	Err error
+}
+
+// VerifyMessage verifies the enveloped signature of the message root and
+// of each assertion directly inside it. Encrypted assertions are not
+// looked into. If certs is empty, the KeyInfo certificates are used,
+// which proves integrity but not who signed.
+//
+// An assertion that is neither signed itself nor covered by a verified
+// signature of the root fails verification, even next to signed ones, as
+// a parser may read it instead of the signed assertion (XML Signature
+// Wrapping).
+func VerifyMessage(xmlData []byte, certs []*x509.Certificate) MessageVerification {
+	return VerifyMessageWithResolver(xmlData, certs, nil)
+}
+
+// VerifyMessageWithResolver is VerifyMessage resolving the external
+// references of detached signatures with resolve. A detached signature
+// proves the content it references, not the element it sits in: it is
+// listed in Verified, but a message whose only signatures are detached is
+// unsigned.
+func VerifyMessageWithResolver(xmlData []byte, certs []*x509.Certificate, resolve ReferenceResolver) MessageVerification {
+	doc, err := ReadDocument(xmlData)
+	if errors.Is(err, ErrDTD) {
+		return MessageVerification{Verdict: VerdictSigFail, Err: err}
+	}
+	if err != nil {
+		return MessageVerification{Verdict: VerdictSigFail, Err: &ErrParse{Stage: "XML", Err: err}}
+	}
+	root := doc.Root()
+	if root == nil {
+		return MessageVerification{Verdict: VerdictSigFail, Err: fmt.Errorf("document has no root element")}
+	}
+
+	var result MessageVerification
+	rootSigned := false
+	switch _, err := VerifySignatureWithResolver(root, certs, resolve); {
+	case err == nil:
+		uri := externalReference(root)
+		result.Verified = append(result.Verified, verifiedName(root, uri))
+		rootSigned = uri == ""
+	case !errors.Is(err, ErrNoSignature):
+		result.Verdict = VerdictSigFail
+		result.Err = fmt.Errorf("%s: %w", root.Tag, err)
+		return result
+	}
+
+	covered := rootSigned
+	var unsigned int
+	for _, child := range root.ChildElements() {
+		if !isElement(child, SAMLNamespace, "Assertion") {
+			continue
+		}
+		_, err := VerifySignatureWithResolver(child, certs, resolve)
+		switch {
+		case err == nil:
+			uri := externalReference(child)
+			result.Verified = append(result.Verified, verifiedName(child, uri))
+			if uri != "" {
+				unsigned++
+			} else {
+				covered = true
+			}
+		case errors.Is(err, ErrNoSignature):
+			unsigned++
+		default:
+			result.Verdict = VerdictSigFail
+			result.Err = fmt.Errorf("%s: %w", child.Tag, err)
+			return result
+		}
+	}
+
+	switch {
+	case !covered:
+		result.Verdict = VerdictUnsigned
+	case unsigned > 0 && !rootSigned:
+		result.Verdict = VerdictSigFail
+		result.Err = ErrUnsignedAssertion
+	default:
+		result.Verdict = VerdictVerified
+	}
+	return result
+}
+
+// verifiedName names el in MessageVerification.Verified
+func verifiedName(el *etree.Element, uri string) string {
+	if uri == "" {
+		return el.Tag
+	}
+	return fmt.Sprintf("%s (%s)", el.Tag, uri)
+}
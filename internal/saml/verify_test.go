@@ -0,0 +1,77 @@
+package saml
+
+import (
+	"crypto/x509"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gliwka/SAMLurai/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyMessage(t *testing.T) {
+	response := testutil.LoadFixture(t, filepath.Join(signedFixtures, "onelogin_response.xml"))
+	cert, err := ParseCertificate(testutil.LoadFixture(t, filepath.Join(signedFixtures, "onelogin_cert.pem")))
+	require.NoError(t, err)
+
+	result := VerifyMessage(response, []*x509.Certificate{cert})
+	assert.Equal(t, VerdictVerified, result.Verdict)
+	assert.NotEmpty(t, result.Verified)
+	assert.NoError(t, result.Err)
+
+	tampered := strings.Replace(string(response), ">Ross<", ">Eve<", 1)
+	result = VerifyMessage([]byte(tampered), []*x509.Certificate{cert})
+	assert.Equal(t, VerdictSigFail, result.Verdict)
+	assert.ErrorContains(t, result.Err, "digest mismatch")
+
+	other, _ := testCertificates(t)
+	result = VerifyMessage(response, []*x509.Certificate{other})
+	assert.Equal(t, VerdictSigFail, result.Verdict)
+
+	unsigned := testutil.LoadFixture(t, filepath.Join("..", "..", "testdata", "fixtures", "assertions", "request.xml"))
+	result = VerifyMessage(unsigned, nil)
+	assert.Equal(t, VerdictUnsigned, result.Verdict)
+	assert.NoError(t, result.Err)
+
+	result = VerifyMessage([]byte("<not xml"), nil)
+	assert.Equal(t, VerdictSigFail, result.Verdict)
+}
+
+func TestVerifyMessage_SignatureWrapping(t *testing.T) {
+	signed, cert := signedTestResponse(t)
+	certs := []*x509.Certificate{cert}
+	require.Equal(t, VerdictVerified, VerifyMessage(signed, certs).Verdict)
+
+	// An unsigned assertion after the signed one, which the parser reads
+	doc, err := ReadDocument(signed)
+	require.NoError(t, err)
+	forged := ResponseAssertion(doc.Root()).Copy()
+	forged.RemoveChild(forged.SelectElement("Signature"))
+	forged.CreateAttr("ID", "_forged")
+	forged.FindElement(".//NameID").SetText("eve@example.com")
+	doc.Root().AddChild(forged)
+	wrapped, err := doc.WriteToBytes()
+	require.NoError(t, err)
+
+	info, err := NewParser().Parse(wrapped)
+	require.NoError(t, err)
+	require.Equal(t, "eve@example.com", info.Assertion.Subject.NameID)
+
+	result := VerifyMessage(wrapped, certs)
+	assert.Equal(t, VerdictSigFail, result.Verdict)
+	assert.ErrorIs(t, result.Err, ErrUnsignedAssertion)
+	assert.Equal(t, []string{"Assertion"}, result.Verified)
+
+	// A signature over the whole Response covers unsigned assertions
+	key, cert, err := NewSelfSignedKey("idp.example.com")
+	require.NoError(t, err)
+	doc, err = BuildResponse(testResponseOptions(), time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	require.NoError(t, SignEnveloped(doc.Root(), key, cert, SigAlgRSASHA256))
+	responseSigned, err := doc.WriteToBytes()
+	require.NoError(t, err)
+	assert.Equal(t, VerdictVerified, VerifyMessage(responseSigned, []*x509.Certificate{cert}).Verdict)
+}
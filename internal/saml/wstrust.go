@@ -0,0 +1,145 @@
+package saml
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/beevik/etree"
+)
+
+// WS-Trust namespaces: the OASIS 1.3 standard and the 2005/02 draft still
+// used by ADFS and WS-Federation passive requestors
+const (
+	WSTrust13Namespace   = "http://docs.oasis-open.org/ws-sx/ws-trust/200512"
+	WSTrust2005Namespace = "http://schemas.xmlsoap.org/ws/2005/02/trust"
+)
+
+// WSTrustInfo describes a WS-Trust RequestSecurityToken (RST) or
+// RequestSecurityTokenResponse (RSTR)
+type WSTrustInfo struct {
+	// Version is "1.3" or "2005/02"
+	Version     string     `json:"version"`
+	Context     string     `json:"context,omitempty"`
+	RequestType string     `json:"request_type,omitempty"`
+	TokenType   string     `json:"token_type,omitempty"`
+	KeyType     string     `json:"key_type,omitempty"`
+	AppliesTo   string     `json:"applies_to,omitempty"`
+	Created     *time.Time `json:"created,omitempty"`
+	Expires     *time.Time `json:"expires,omitempty"`
+}
+
+// IsWSTrust reports whether data is a WS-Trust message, possibly wrapped in
+// a SOAP envelope
+func IsWSTrust(data []byte) bool {
+	return (bytes.Contains(data, []byte(WSTrust13Namespace)) || bytes.Contains(data, []byte(WSTrust2005Namespace))) &&
+		bytes.Contains(data, []byte("RequestSecurityToken"))
+}
+
+// parseWSTrust parses an RST or RSTR. The SAML assertion carried in
+// RequestedSecurityToken, if any, is parsed into info.Assertion.
+func (p *Parser) parseWSTrust(xmlData []byte) (*SAMLInfo, error) {
+	doc, err := ReadDocument(xmlData)
+	if err != nil {
+		return nil, &ErrParse{Stage: "WS-Trust message", Err: err}
+	}
+
+	// An RSTR may be wrapped in a RequestSecurityTokenResponseCollection
+	// and a SOAP envelope; only the first response is shown
+	var msg *etree.Element
+	for _, tag := range []string{"RequestSecurityTokenResponse", "RequestSecurityToken"} {
+		for _, el := range doc.FindElements("//" + tag) {
+			if ns := el.NamespaceURI(); ns == WSTrust13Namespace || ns == WSTrust2005Namespace {
+				msg = el
+				break
+			}
+		}
+		if msg != nil {
+			break
+		}
+	}
+	if msg == nil {
+		return nil, &ErrParse{Stage: "WS-Trust message", Err: errors.New("no RequestSecurityToken or RequestSecurityTokenResponse element")}
+	}
+
+	wst := &WSTrustInfo{Version: "1.3", Context: msg.SelectAttrValue("Context", "")}
+	if msg.NamespaceURI() == WSTrust2005Namespace {
+		wst.Version = "2005/02"
+	}
+	wst.RequestType = childText(msg, "RequestType")
+	wst.TokenType = childText(msg, "TokenType")
+	wst.KeyType = childText(msg, "KeyType")
+	if appliesTo := msg.FindElement("AppliesTo"); appliesTo != nil {
+		if addr := appliesTo.FindElement(".//Address"); addr != nil {
+			wst.AppliesTo = strings.TrimSpace(addr.Text())
+		}
+	}
+	if lifetime := msg.FindElement("Lifetime"); lifetime != nil {
+		wst.Created = parseWSTrustTime(childText(lifetime, "Created"))
+		wst.Expires = parseWSTrustTime(childText(lifetime, "Expires"))
+	}
+
+	info := &SAMLInfo{
+		Type:    msg.Tag,
+		WSTrust: wst,
+	}
+
+	if token := msg.FindElement("RequestedSecurityToken"); token != nil {
+		if assertion := token.FindElement("Assertion"); assertion != nil {
+			tokenDoc := etree.NewDocument()
+			tokenDoc.SetRoot(detachElement(assertion))
+			tokenXML, err := tokenDoc.WriteToBytes()
+			if err != nil {
+				return nil, fmt.Errorf("failed to serialize requested token: %w", err)
+			}
+			nested, err := p.parseAssertion(tokenXML)
+			if err != nil {
+				return nil, err
+			}
+			info.Assertion = nested
+			info.Issuer = nested.Issuer
+		}
+	}
+
+	return info, nil
+}
+
+// childText returns the trimmed text of the first child element of el
+// with the given local name
+func childText(el *etree.Element, tag string) string {
+	if child := el.SelectElement(tag); child != nil {
+		return strings.TrimSpace(child.Text())
+	}
+	return ""
+}
+
+// detachElement copies el, adding the namespace declarations it inherits
+// from its ancestors so the copy can be parsed on its own
+func detachElement(el *etree.Element) *etree.Element {
+	copied := el.Copy()
+	for p := el.Parent(); p != nil; p = p.Parent() {
+		for _, attr := range p.Attr {
+			if attr.Space != "xmlns" && !(attr.Space == "" && attr.Key == "xmlns") {
+				continue
+			}
+			if copied.SelectAttr(attr.FullKey()) == nil {
+				copied.CreateAttr(attr.FullKey(), attr.Value)
+			}
+		}
+	}
+	return copied
+}
+
+// parseWSTrustTime parses a wsu:Created or wsu:Expires value
+func parseWSTrustTime(value string) *time.Time {
+	if value == "" {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,115 @@
+package saml
+
+import (
+	"encoding/json"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const wsTrustRSTR = `<s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion">
+  <s:Body>
+    <trust:RequestSecurityTokenResponseCollection xmlns:trust="http://docs.oasis-open.org/ws-sx/ws-trust/200512">
+      <trust:RequestSecurityTokenResponse Context="ctx-42">
+        <trust:Lifetime>
+          <wsu:Created xmlns:wsu="http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-utility-1.0.xsd">2024-01-01T10:00:00.000Z</wsu:Created>
+          <wsu:Expires xmlns:wsu="http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-utility-1.0.xsd">2024-01-01T11:00:00.000Z</wsu:Expires>
+        </trust:Lifetime>
+        <wsp:AppliesTo xmlns:wsp="http://schemas.xmlsoap.org/ws/2004/09/policy">
+          <wsa:EndpointReference xmlns:wsa="http://www.w3.org/2005/08/addressing">
+            <wsa:Address>https://rp.example.com/</wsa:Address>
+          </wsa:EndpointReference>
+        </wsp:AppliesTo>
+        <trust:RequestedSecurityToken>
+          <saml:Assertion ID="_a1" IssueInstant="2024-01-01T10:00:00Z" Version="2.0">
+            <saml:Issuer>https://sts.example.com/</saml:Issuer>
+            <saml:Subject><saml:NameID>alice@example.com</saml:NameID></saml:Subject>
+          </saml:Assertion>
+        </trust:RequestedSecurityToken>
+        <trust:TokenType>urn:oasis:names:tc:SAML:2.0:assertion</trust:TokenType>
+        <trust:RequestType>http://docs.oasis-open.org/ws-sx/ws-trust/200512/Issue</trust:RequestType>
+        <trust:KeyType>http://docs.oasis-open.org/ws-sx/ws-trust/200512/Bearer</trust:KeyType>
+      </trust:RequestSecurityTokenResponse>
+    </trust:RequestSecurityTokenResponseCollection>
+  </s:Body>
+</s:Envelope>`
+
+const wsTrustRST = `<t:RequestSecurityToken xmlns:t="http://schemas.xmlsoap.org/ws/2005/02/trust">
+  <wsp:AppliesTo xmlns:wsp="http://schemas.xmlsoap.org/ws/2004/09/policy">
+    <wsa:EndpointReference xmlns:wsa="http://www.w3.org/2005/08/addressing"><wsa:Address>urn:federation:rp</wsa:Address></wsa:EndpointReference>
+  </wsp:AppliesTo>
+  <t:RequestType>http://schemas.xmlsoap.org/ws/2005/02/trust/Issue</t:RequestType>
+  <t:TokenType>urn:oasis:names:tc:SAML:2.0:assertion</t:TokenType>
+</t:RequestSecurityToken>`
+
+func TestParser_WSTrustResponse(t *testing.T) {
+	info, err := NewParser().Parse([]byte(wsTrustRSTR))
+	require.NoError(t, err)
+
+	assert.Equal(t, "RequestSecurityTokenResponse", info.Type)
+	assert.Equal(t, "https://sts.example.com/", info.Issuer)
+	require.NotNil(t, info.WSTrust)
+	assert.Equal(t, "1.3", info.WSTrust.Version)
+	assert.Equal(t, "ctx-42", info.WSTrust.Context)
+	assert.Equal(t, "https://rp.example.com/", info.WSTrust.AppliesTo)
+	assert.Equal(t, "urn:oasis:names:tc:SAML:2.0:assertion", info.WSTrust.TokenType)
+	assert.Equal(t, "http://docs.oasis-open.org/ws-sx/ws-trust/200512/Issue", info.WSTrust.RequestType)
+	assert.Equal(t, "http://docs.oasis-open.org/ws-sx/ws-trust/200512/Bearer", info.WSTrust.KeyType)
+	require.NotNil(t, info.WSTrust.Created)
+	require.NotNil(t, info.WSTrust.Expires)
+	assert.Equal(t, time.Hour, info.WSTrust.Expires.Sub(*info.WSTrust.Created))
+
+	// The issued assertion is parsed although its prefix is declared on the envelope
+	require.NotNil(t, info.Assertion)
+	assert.Equal(t, "_a1", info.Assertion.ID)
+	require.NotNil(t, info.Assertion.Subject)
+	assert.Equal(t, "alice@example.com", info.Assertion.Subject.NameID)
+}
+
+func TestParser_WSTrustRequest(t *testing.T) {
+	info, err := NewParser().Parse([]byte(wsTrustRST))
+	require.NoError(t, err)
+
+	assert.Equal(t, "RequestSecurityToken", info.Type)
+	require.NotNil(t, info.WSTrust)
+	assert.Equal(t, "2005/02", info.WSTrust.Version)
+	assert.Equal(t, "urn:federation:rp", info.WSTrust.AppliesTo)
+	assert.Nil(t, info.WSTrust.Expires)
+	assert.Nil(t, info.Assertion)
+}
+
+func TestHARExtractor_WSTrust(t *testing.T) {
+	rst, err := json.Marshal(wsTrustRST)
+	require.NoError(t, err)
+	rstr, err := json.Marshal(wsTrustRSTR)
+	require.NoError(t, err)
+	wresult, err := json.Marshal("wa=wsignin1.0&wresult=" + url.QueryEscape(wsTrustRSTR))
+	require.NoError(t, err)
+
+	har := `{"log": {"entries": [
+		{
+			"request": {"method": "POST", "url": "https://sts.example.com/trust/13/usernamemixed",
+				"postData": {"mimeType": "application/soap+xml", "text": ` + string(rst) + `}},
+			"response": {"content": {"mimeType": "application/soap+xml", "text": ` + string(rstr) + `}}
+		},
+		{
+			"request": {"method": "POST", "url": "https://rp.example.com/",
+				"postData": {"mimeType": "application/x-www-form-urlencoded", "text": ` + string(wresult) + `}},
+			"response": {"content": {"mimeType": "text/html", "text": ""}}
+		}
+	]}}`
+
+	results, err := NewHARExtractor().ExtractFromHAR([]byte(har))
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+
+	assert.Equal(t, "RequestSecurityToken", results[0].Type)
+	assert.Equal(t, "request-body", results[0].Source)
+	assert.Equal(t, "RequestSecurityTokenResponse", results[1].Type)
+	assert.Equal(t, "response-body", results[1].Source)
+	assert.Equal(t, "RequestSecurityTokenResponse", results[2].Type)
+	assert.Equal(t, "wresult", results[2].ParameterName)
+}
@@ -0,0 +1,512 @@
+package saml
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/beevik/etree"
+)
+
+// Canonicalization and transform algorithms
+const (
+	C14N10Algorithm             = "http://www.w3.org/TR/2001/REC-xml-c14n-20010315"
+	ExcC14N10Algorithm          = "http://www.w3.org/2001/10/xml-exc-c14n#"
+	EnvelopedSignatureAlgorithm = "http://www.w3.org/2000/09/xmldsig#enveloped-signature"
+)
+
+// Digest algorithms
+const (
+	DigestSHA1   = "http://www.w3.org/2000/09/xmldsig#sha1"
+	DigestSHA256 = "http://www.w3.org/2001/04/xmlenc#sha256"
+	DigestSHA384 = "http://www.w3.org/2001/04/xmldsig-more#sha384"
+	DigestSHA512 = "http://www.w3.org/2001/04/xmlenc#sha512"
+)
+
+var digestHashes = map[string]crypto.Hash{
+	DigestSHA1:   crypto.SHA1,
+	DigestSHA256: crypto.SHA256,
+	DigestSHA384: crypto.SHA384,
+	DigestSHA512: crypto.SHA512,
+}
+
+// xmlNamespace is bound to the xml prefix by definition
+const xmlNamespace = "http://www.w3.org/XML/1998/namespace"
+
+// ErrNoSignature is returned when an element has no enveloped signature
+var ErrNoSignature = errors.New("element is not signed")
+
+// VerifySignature checks the enveloped XML signature of el: the reference
+// digest over el and the signature over SignedInfo. If certs is empty,
+// the certificate in the signature's KeyInfo is used, which proves
+// integrity but not who signed. It returns the certificate that verified
+// the signature.
+func VerifySignature(el *etree.Element, certs []*x509.Certificate) (*x509.Certificate, error) {
+	return VerifySignatureWithResolver(el, certs, nil)
+}
+
+// VerifySignatureWithResolver is VerifySignature for signatures that may be
+// detached: a Reference URI outside the document is resolved with resolve
+// and its content digested instead of el. Such a signature proves the
+// referenced content, not el. Without a resolver, external references fail.
+func VerifySignatureWithResolver(el *etree.Element, certs []*x509.Certificate, resolve ReferenceResolver) (*x509.Certificate, error) {
+	var sig *etree.Element
+	for _, child := range el.ChildElements() {
+		if isElement(child, XMLDSigNamespace, "Signature") {
+			sig = child
+			break
+		}
+	}
+	if sig == nil {
+		return nil, ErrNoSignature
+	}
+
+	signedInfo := dsigChild(sig, "SignedInfo")
+	if signedInfo == nil {
+		return nil, fmt.Errorf("signature has no SignedInfo")
+	}
+	references := dsigChildren(signedInfo, "Reference")
+	if len(references) != 1 {
+		return nil, fmt.Errorf("signature has %d references, expected 1", len(references))
+	}
+	if err := verifyReference(el, sig, references[0], resolve); err != nil {
+		return nil, err
+	}
+
+	c14nMethod := dsigChild(signedInfo, "CanonicalizationMethod")
+	if c14nMethod == nil {
+		return nil, fmt.Errorf("SignedInfo has no CanonicalizationMethod")
+	}
+	canonical, err := canonicalizeWith(signedInfo, c14nMethod, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	sigMethod := dsigChild(signedInfo, "SignatureMethod")
+	if sigMethod == nil {
+		return nil, fmt.Errorf("SignedInfo has no SignatureMethod")
+	}
+	sigAlg := sigMethod.SelectAttrValue("Algorithm", "")
+	hash, ok := sigAlgHashes[sigAlg]
+	if !ok {
+		return nil, fmt.Errorf("unsupported signature algorithm: %s", sigAlg)
+	}
+
+	sigValue := dsigChild(sig, "SignatureValue")
+	if sigValue == nil {
+		return nil, fmt.Errorf("signature has no SignatureValue")
+	}
+	signature, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(sigValue.Text()), ""))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode SignatureValue: %w", err)
+	}
+
+	if len(certs) == 0 {
+		certEl := sig.FindElement("./KeyInfo/X509Data/X509Certificate")
+		if certEl == nil {
+			return nil, fmt.Errorf("signature has no KeyInfo certificate and no trusted certificate was given")
+		}
+		cert, err := ParseCertificate([]byte(certEl.Text()))
+		if err != nil {
+			return nil, err
+		}
+		certs = []*x509.Certificate{cert}
+	}
+
+	for _, cert := range certs {
+		if err = verifySignatureValue(cert, hash, canonical, signature); err == nil {
+			return cert, nil
+		}
+	}
+	if len(certs) > 1 {
+		return nil, fmt.Errorf("signature verification failed with all %d certificates", len(certs))
+	}
+	return nil, err
+}
+
+// verifyReference checks that the reference points at el, or at external
+// content resolve returns, and that its digest matches
+func verifyReference(el, sig, ref *etree.Element, resolve ReferenceResolver) error {
+	uri := ref.SelectAttrValue("URI", "")
+	external := isExternalReference(uri)
+	if id := el.SelectAttrValue("ID", ""); !external && uri != "" && uri != "#"+id {
+		return fmt.Errorf("signature references %s, not the enclosing element (ID %q)", uri, id)
+	}
+
+	digestMethod := dsigChild(ref, "DigestMethod")
+	if digestMethod == nil {
+		return fmt.Errorf("reference has no DigestMethod")
+	}
+	alg := digestMethod.SelectAttrValue("Algorithm", "")
+	hash, ok := digestHashes[alg]
+	if !ok {
+		return fmt.Errorf("unsupported digest algorithm: %s", alg)
+	}
+
+	// Without a canonicalization transform the node-set is serialized
+	// with inclusive Canonical XML, and external content is digested as is
+	var exclude *etree.Element
+	var c14n *canonicalizer
+	if transforms := dsigChild(ref, "Transforms"); transforms != nil {
+		for _, t := range dsigChildren(transforms, "Transform") {
+			switch alg := t.SelectAttrValue("Algorithm", ""); alg {
+			case EnvelopedSignatureAlgorithm:
+				if external {
+					return fmt.Errorf("enveloped-signature transform on external reference %s", uri)
+				}
+				exclude = sig
+			case ExcC14N10Algorithm, C14N10Algorithm:
+				c14n = newCanonicalizer(t)
+			default:
+				return fmt.Errorf("unsupported transform: %s", alg)
+			}
+		}
+	}
+
+	var content []byte
+	if external {
+		var err error
+		if content, err = referenceContent(uri, c14n, resolve); err != nil {
+			return err
+		}
+	} else {
+		if c14n == nil {
+			c14n = &canonicalizer{}
+		}
+		c14n.exclude = exclude
+		content = c14n.canonicalize(el)
+	}
+
+	digestValue := dsigChild(ref, "DigestValue")
+	if digestValue == nil {
+		return fmt.Errorf("reference has no DigestValue")
+	}
+	expected, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(digestValue.Text()), ""))
+	if err != nil {
+		return fmt.Errorf("failed to decode DigestValue: %w", err)
+	}
+
+	h := hash.New()
+	h.Write(content)
+	if subtle.ConstantTimeCompare(h.Sum(nil), expected) != 1 {
+		return fmt.Errorf("digest mismatch: the signed content was modified")
+	}
+	return nil
+}
+
+// SignEnveloped adds an enveloped signature over el, which must have an ID
+// attribute. The signature uses Exclusive Canonical XML and is placed after
+// the Issuer, where the SAML schema expects it.
+func SignEnveloped(el *etree.Element, key crypto.Signer, cert *x509.Certificate, sigAlg string) error {
+	hash, ok := sigAlgHashes[sigAlg]
+	if !ok {
+		return fmt.Errorf("unsupported signature algorithm: %s", sigAlg)
+	}
+	id := el.SelectAttrValue("ID", "")
+	if id == "" {
+		return fmt.Errorf("element <%s> has no ID to reference", el.FullTag())
+	}
+	var digestAlg string
+	for alg, h := range digestHashes {
+		if h == hash {
+			digestAlg = alg
+		}
+	}
+
+	h := hash.New()
+	h.Write((&canonicalizer{exclusive: true}).canonicalize(el))
+	digest := h.Sum(nil)
+
+	sig := etree.NewElement("ds:Signature")
+	sig.CreateAttr("xmlns:ds", XMLDSigNamespace)
+	signedInfo := sig.CreateElement("ds:SignedInfo")
+	signedInfo.CreateElement("ds:CanonicalizationMethod").CreateAttr("Algorithm", ExcC14N10Algorithm)
+	signedInfo.CreateElement("ds:SignatureMethod").CreateAttr("Algorithm", sigAlg)
+	ref := signedInfo.CreateElement("ds:Reference")
+	ref.CreateAttr("URI", "#"+id)
+	transforms := ref.CreateElement("ds:Transforms")
+	transforms.CreateElement("ds:Transform").CreateAttr("Algorithm", EnvelopedSignatureAlgorithm)
+	transforms.CreateElement("ds:Transform").CreateAttr("Algorithm", ExcC14N10Algorithm)
+	ref.CreateElement("ds:DigestMethod").CreateAttr("Algorithm", digestAlg)
+	ref.CreateElement("ds:DigestValue").SetText(base64.StdEncoding.EncodeToString(digest))
+
+	// SignedInfo is canonicalized in place so inherited namespaces resolve
+	index := 0
+	for i, child := range el.ChildElements() {
+		if child.Tag == "Issuer" {
+			index = i + 1
+		}
+	}
+	el.InsertChildAt(childTokenIndex(el, index), sig)
+
+	signature, err := signContent(key, sigAlg, hash, (&canonicalizer{exclusive: true}).canonicalize(signedInfo))
+	if err != nil {
+		el.RemoveChild(sig)
+		return err
+	}
+	sig.CreateElement("ds:SignatureValue").SetText(base64.StdEncoding.EncodeToString(signature))
+	if cert != nil {
+		sig.CreateElement("ds:KeyInfo").CreateElement("ds:X509Data").CreateElement("ds:X509Certificate").
+			SetText(base64.StdEncoding.EncodeToString(cert.Raw))
+	}
+	return nil
+}
+
+// childTokenIndex converts an index among el's child elements to an index
+// among all its child tokens
+func childTokenIndex(el *etree.Element, elementIndex int) int {
+	n := 0
+	for i, token := range el.Child {
+		if _, ok := token.(*etree.Element); ok {
+			if n == elementIndex {
+				return i
+			}
+			n++
+		}
+	}
+	return len(el.Child)
+}
+
+func dsigChild(el *etree.Element, local string) *etree.Element {
+	for _, child := range el.ChildElements() {
+		if isElement(child, XMLDSigNamespace, local) {
+			return child
+		}
+	}
+	return nil
+}
+
+func dsigChildren(el *etree.Element, local string) []*etree.Element {
+	var result []*etree.Element
+	for _, child := range el.ChildElements() {
+		if isElement(child, XMLDSigNamespace, local) {
+			result = append(result, child)
+		}
+	}
+	return result
+}
+
+// canonicalizeWith renders el with the algorithm of a CanonicalizationMethod
+// or Transform element
+func canonicalizeWith(el, method, exclude *etree.Element) ([]byte, error) {
+	switch alg := method.SelectAttrValue("Algorithm", ""); alg {
+	case ExcC14N10Algorithm, C14N10Algorithm:
+		c := newCanonicalizer(method)
+		c.exclude = exclude
+		return c.canonicalize(el), nil
+	default:
+		return nil, fmt.Errorf("unsupported canonicalization algorithm: %s", alg)
+	}
+}
+
+// canonicalizer renders an element in Canonical XML 1.0 or Exclusive
+// Canonical XML 1.0, both without comments
+type canonicalizer struct {
+	exclusive bool
+
+	// inclusivePrefixes is the InclusiveNamespaces PrefixList of exclusive
+	// canonicalization; the default namespace is ""
+	inclusivePrefixes map[string]bool
+
+	// exclude is omitted from the output, e.g. an enveloped signature
+	exclude *etree.Element
+
+	buf bytes.Buffer
+}
+
+func newCanonicalizer(method *etree.Element) *canonicalizer {
+	c := &canonicalizer{exclusive: method.SelectAttrValue("Algorithm", "") == ExcC14N10Algorithm}
+	if c.exclusive {
+		c.inclusivePrefixes = map[string]bool{}
+		for _, child := range method.ChildElements() {
+			if child.Tag != "InclusiveNamespaces" {
+				continue
+			}
+			for _, prefix := range strings.Fields(child.SelectAttrValue("PrefixList", "")) {
+				if prefix == "#default" {
+					prefix = ""
+				}
+				c.inclusivePrefixes[prefix] = true
+			}
+		}
+	}
+	return c
+}
+
+func (c *canonicalizer) canonicalize(el *etree.Element) []byte {
+	c.buf.Reset()
+	c.element(el, namespacesInScope(el.Parent()), map[string]string{})
+	return bytes.Clone(c.buf.Bytes())
+}
+
+// namespacesInScope returns the prefix to namespace bindings declared on el
+// and its ancestors
+func namespacesInScope(el *etree.Element) map[string]string {
+	var chain []*etree.Element
+	for ; el != nil; el = el.Parent() {
+		chain = append(chain, el)
+	}
+	scope := map[string]string{}
+	for i := len(chain) - 1; i >= 0; i-- {
+		declareNamespaces(chain[i], scope)
+	}
+	return scope
+}
+
+func declareNamespaces(el *etree.Element, scope map[string]string) {
+	for _, attr := range el.Attr {
+		switch {
+		case attr.Space == "xmlns":
+			scope[attr.Key] = attr.Value
+		case attr.Space == "" && attr.Key == "xmlns":
+			scope[""] = attr.Value
+		}
+	}
+}
+
+func isNamespaceDeclaration(attr etree.Attr) bool {
+	return attr.Space == "xmlns" || (attr.Space == "" && attr.Key == "xmlns")
+}
+
+type canonicalAttr struct {
+	namespace string
+	name      string
+	value     string
+}
+
+// element renders el given the bindings in scope at its parent and the
+// namespace declarations already rendered by output ancestors
+func (c *canonicalizer) element(el *etree.Element, parentScope, rendered map[string]string) {
+	scope := make(map[string]string, len(parentScope))
+	for prefix, uri := range parentScope {
+		scope[prefix] = uri
+	}
+	declareNamespaces(el, scope)
+
+	// Namespaces to consider: visibly utilized ones for exclusive
+	// canonicalization, all in scope otherwise
+	candidates := map[string]bool{el.Space: true}
+	for _, attr := range el.Attr {
+		if !isNamespaceDeclaration(attr) && attr.Space != "" && attr.Space != "xml" {
+			candidates[attr.Space] = true
+		}
+	}
+	if c.exclusive {
+		for prefix := range c.inclusivePrefixes {
+			if _, ok := scope[prefix]; ok {
+				candidates[prefix] = true
+			}
+		}
+	} else {
+		for prefix := range scope {
+			candidates[prefix] = true
+		}
+	}
+
+	var prefixes []string
+	for prefix := range candidates {
+		uri, declared := scope[prefix]
+		if prefix == "xml" || (!declared && prefix != "") {
+			continue
+		}
+		if previous, ok := rendered[prefix]; ok && previous == uri {
+			continue
+		}
+		if prefix == "" && uri == "" && rendered[""] == "" {
+			continue
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+
+	childRendered := rendered
+	if len(prefixes) > 0 {
+		childRendered = make(map[string]string, len(rendered)+len(prefixes))
+		for prefix, uri := range rendered {
+			childRendered[prefix] = uri
+		}
+		for _, prefix := range prefixes {
+			childRendered[prefix] = scope[prefix]
+		}
+	}
+
+	var attrs []canonicalAttr
+	for _, attr := range el.Attr {
+		if isNamespaceDeclaration(attr) {
+			continue
+		}
+		a := canonicalAttr{name: attr.FullKey(), value: attr.Value}
+		switch attr.Space {
+		case "":
+		case "xml":
+			a.namespace = xmlNamespace
+		default:
+			a.namespace = scope[attr.Space]
+		}
+		attrs = append(attrs, a)
+	}
+	sort.SliceStable(attrs, func(i, j int) bool {
+		if attrs[i].namespace != attrs[j].namespace {
+			return attrs[i].namespace < attrs[j].namespace
+		}
+		return localName(attrs[i].name) < localName(attrs[j].name)
+	})
+
+	c.buf.WriteString("<" + el.FullTag())
+	for _, prefix := range prefixes {
+		if prefix == "" {
+			c.buf.WriteString(` xmlns="`)
+		} else {
+			c.buf.WriteString(" xmlns:" + prefix + `="`)
+		}
+		c.buf.WriteString(escapeCanonicalAttr(scope[prefix]) + `"`)
+	}
+	for _, attr := range attrs {
+		c.buf.WriteString(" " + attr.name + `="` + escapeCanonicalAttr(attr.value) + `"`)
+	}
+	c.buf.WriteString(">")
+
+	for _, token := range el.Child {
+		switch t := token.(type) {
+		case *etree.Element:
+			if t != c.exclude {
+				c.element(t, scope, childRendered)
+			}
+		case *etree.CharData:
+			c.buf.WriteString(escapeCanonicalText(t.Data))
+		case *etree.ProcInst:
+			c.buf.WriteString("<?" + t.Target)
+			if t.Inst != "" {
+				c.buf.WriteString(" " + t.Inst)
+			}
+			c.buf.WriteString("?>")
+		}
+	}
+	c.buf.WriteString("</" + el.FullTag() + ">")
+}
+
+func localName(name string) string {
+	if i := strings.IndexByte(name, ':'); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
+
+var (
+	canonicalTextEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\r", "&#xD;")
+	canonicalAttrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", `"`, "&quot;", "\t", "&#x9;", "\n", "&#xA;", "\r", "&#xD;")
+)
+
+func escapeCanonicalText(s string) string {
+	return canonicalTextEscaper.Replace(s)
+}
+
+func escapeCanonicalAttr(s string) string {
+	return canonicalAttrEscaper.Replace(s)
+}
@@ -0,0 +1,126 @@
+package saml
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/beevik/etree"
+	"github.com/gliwka/SAMLurai/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var signedFixtures = filepath.Join("..", "..", "testdata", "fixtures", "signed")
+
+func readElement(t *testing.T, xml string) *etree.Element {
+	t.Helper()
+	doc := etree.NewDocument()
+	require.NoError(t, doc.ReadFromString(xml))
+	return doc.Root()
+}
+
+func TestVerifySignature_OneLogin(t *testing.T) {
+	response := testutil.LoadFixtureString(t, filepath.Join(signedFixtures, "onelogin_response.xml"))
+	cert, err := ParseCertificate(testutil.LoadFixture(t, filepath.Join(signedFixtures, "onelogin_cert.pem")))
+	require.NoError(t, err)
+
+	verified, err := VerifySignature(readElement(t, response), []*x509.Certificate{cert})
+	require.NoError(t, err)
+	assert.Equal(t, cert, verified)
+
+	// The KeyInfo certificate is used when no trusted certificate is given
+	_, err = VerifySignature(readElement(t, response), nil)
+	require.NoError(t, err)
+
+	tampered := strings.Replace(response, ">Ross<", ">Eve<", 1)
+	_, err = VerifySignature(readElement(t, tampered), []*x509.Certificate{cert})
+	assert.EqualError(t, err, "digest mismatch: the signed content was modified")
+
+	other, _ := testCertificates(t)
+	_, err = VerifySignature(readElement(t, response), []*x509.Certificate{other})
+	assert.ErrorContains(t, err, "signature verification failed")
+}
+
+func TestSignEnveloped_RoundTrip(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		key    crypto.Signer
+		sigAlg string
+	}{
+		{"rsa-sha256", rsaKey, SigAlgRSASHA256},
+		{"ecdsa-sha256", ecKey, SigAlgECDSASHA256},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cert := selfSignedCert(t, tt.key)
+			el := readElement(t, `<saml:Assertion xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_a"><saml:Issuer>https://idp.example.com</saml:Issuer><saml:Subject><saml:NameID>alice</saml:NameID></saml:Subject></saml:Assertion>`)
+
+			require.NoError(t, SignEnveloped(el, tt.key, cert, tt.sigAlg))
+			children := el.ChildElements()
+			require.Len(t, children, 3)
+			assert.Equal(t, "Signature", children[1].Tag, "signature must follow the Issuer")
+
+			// Verify after a round trip through serialization
+			doc := etree.NewDocument()
+			doc.SetRoot(el)
+			signed, err := doc.WriteToString()
+			require.NoError(t, err)
+
+			_, err = VerifySignature(readElement(t, signed), []*x509.Certificate{cert})
+			require.NoError(t, err)
+
+			_, err = VerifySignature(readElement(t, strings.Replace(signed, "alice", "admin", 1)), []*x509.Certificate{cert})
+			assert.EqualError(t, err, "digest mismatch: the signed content was modified")
+		})
+	}
+}
+
+func TestSignEnveloped_Errors(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	err = SignEnveloped(readElement(t, `<r/>`), key, nil, SigAlgRSASHA256)
+	assert.EqualError(t, err, "element <r> has no ID to reference")
+
+	err = SignEnveloped(readElement(t, `<r ID="_r"/>`), key, nil, SigAlgECDSASHA256)
+	assert.EqualError(t, err, "ecdsa-sha256 requires an EC key")
+}
+
+func TestVerifySignature_Errors(t *testing.T) {
+	_, err := VerifySignature(readElement(t, `<r ID="_r"/>`), nil)
+	assert.ErrorIs(t, err, ErrNoSignature)
+
+	_, err = VerifySignature(readElement(t, `<r ID="_r"><ds:Signature xmlns:ds="http://www.w3.org/2000/09/xmldsig#"><ds:SignedInfo><ds:Reference URI="#_other"/></ds:SignedInfo></ds:Signature></r>`), nil)
+	assert.EqualError(t, err, `signature references #_other, not the enclosing element (ID "_r")`)
+}
+
+func TestCanonicalize(t *testing.T) {
+	const doc = `<root xmlns="urn:default" xmlns:a="urn:a" xmlns:unused="urn:unused">
+  <a:child z="1" a:y="2" b="&quot;&#9;" xmlns:b="urn:b">x &amp; y &gt; z<!-- comment --><empty/></a:child>
+</root>`
+
+	el := readElement(t, doc)
+	child := el.ChildElements()[0]
+
+	exclusive := (&canonicalizer{exclusive: true}).canonicalize(child)
+	assert.Equal(t, `<a:child xmlns:a="urn:a" b="&quot;&#x9;" z="1" a:y="2">x &amp; y &gt; z<empty xmlns="urn:default"></empty></a:child>`, string(exclusive))
+
+	inclusive := (&canonicalizer{}).canonicalize(child)
+	assert.Equal(t, `<a:child xmlns="urn:default" xmlns:a="urn:a" xmlns:b="urn:b" xmlns:unused="urn:unused" b="&quot;&#x9;" z="1" a:y="2">x &amp; y &gt; z<empty></empty></a:child>`, string(inclusive))
+
+	withPrefixList := newCanonicalizer(readElement(t, `<ds:Transform xmlns:ds="http://www.w3.org/2000/09/xmldsig#" Algorithm="http://www.w3.org/2001/10/xml-exc-c14n#"><ec:InclusiveNamespaces xmlns:ec="http://www.w3.org/2001/10/xml-exc-c14n#" PrefixList="unused #default"/></ds:Transform>`))
+	assert.Equal(t, `<a:child xmlns="urn:default" xmlns:a="urn:a" xmlns:unused="urn:unused" b="&quot;&#x9;" z="1" a:y="2">x &amp; y &gt; z<empty></empty></a:child>`, string(withPrefixList.canonicalize(child)))
+}
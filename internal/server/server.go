@@ -0,0 +1,275 @@
+package server
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gliwka/SAMLurai/internal/inspect"
+	"github.com/gliwka/SAMLurai/internal/metrics"
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/gliwka/SAMLurai/internal/store"
+)
+
+//go:embed static/index.html
+var staticFiles embed.FS
+
+// DefaultMaxUploadSize is the largest upload accepted by the inspect endpoint
+const DefaultMaxUploadSize = 32 << 20
+
+// Message is a single parsed SAML message returned to the web UI
+type Message struct {
+	Index         int            `json:"index"`
+	Type          string         `json:"type"`
+	Source        string         `json:"source,omitempty"`
+	URL           string         `json:"url,omitempty"`
+	ParameterName string         `json:"parameter_name,omitempty"`
+	Info          *saml.SAMLInfo `json:"info,omitempty"`
+	XML           string         `json:"xml"`
+	Error         string         `json:"error,omitempty"`
+}
+
+// InspectResponse is the JSON body returned by the inspect endpoint
+type InspectResponse struct {
+	Messages []Message `json:"messages"`
+}
+
+// Server serves the SAMLurai web UI and its JSON API
+type Server struct {
+	mux           *http.ServeMux
+	maxUploadSize int64
+	store         store.Store
+	metrics       *metrics.Metrics
+}
+
+// NewServer creates a new web UI server
+func NewServer() *Server {
+	s := &Server{
+		mux:           http.NewServeMux(),
+		maxUploadSize: DefaultMaxUploadSize,
+	}
+
+	s.mux.HandleFunc("/", s.handleIndex)
+	s.mux.HandleFunc("/api/inspect", s.handleInspect)
+
+	return s
+}
+
+// WithStore persists every inspected message to st
+func (s *Server) WithStore(st store.Store) *Server {
+	s.store = st
+	return s
+}
+
+// WithMetrics counts the inspected messages in m and serves them at
+// metrics.Path
+func (s *Server) WithMetrics(m *metrics.Metrics) *Server {
+	s.metrics = m
+	s.mux.Handle(metrics.Path, m)
+	return s
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	page, err := staticFiles.ReadFile("static/index.html")
+	if err != nil {
+		http.Error(w, "failed to load page", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write(page)
+}
+
+// handleInspect accepts a multipart upload with an "input" file (or "data"
+// text field) and an optional "key" file, and returns the parsed messages
+func (s *Server) handleInspect(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxUploadSize)
+	if err := r.ParseMultipartForm(s.maxUploadSize); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("failed to parse upload: %v", err))
+		return
+	}
+
+	input, err := readFormValue(r, "input", "data")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if strings.TrimSpace(input) == "" {
+		writeError(w, http.StatusBadRequest, "no input provided")
+		return
+	}
+
+	var decryptor *saml.Decryptor
+	keyData, err := readFormValue(r, "key", "")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if keyData != "" {
+		decryptor, err = saml.NewDecryptorFromPEM([]byte(keyData))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("failed to load private key: %v", err))
+			return
+		}
+	}
+
+	result, err := inspect.Run(r.Context(), inspect.Request{
+		Input:     input,
+		Decryptor: decryptor,
+	})
+	if err != nil {
+		s.metrics.Message("")
+		s.metrics.ParseFailure()
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	s.observe(result)
+	resp := response(result)
+
+	if s.store != nil {
+		if err := s.store.Save(r.Context(), records(resp.Messages, time.Now())); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// observe counts the messages of result and their failures in the metrics
+func (s *Server) observe(result *inspect.Result) {
+	if s.metrics == nil {
+		return
+	}
+	for _, msg := range result.Messages {
+		s.metrics.Message(msg.Type())
+		var stageErr *inspect.StageError
+		switch {
+		case errors.Is(msg.Err, inspect.ErrNoKey):
+			s.metrics.DecryptionFailure()
+		case errors.As(msg.Err, &stageErr) && (stageErr.Stage == inspect.StageDecrypt || stageErr.Stage == inspect.StageLoadKey):
+			s.metrics.DecryptionFailure()
+		case msg.Err != nil:
+			s.metrics.ParseFailure()
+		}
+		if msg.Info == nil || msg.Err != nil {
+			continue
+		}
+		// Without the IdP's certificate, only integrity can be verified
+		if saml.VerifyMessage(msg.XML, nil).Verdict == saml.VerdictSigFail {
+			s.metrics.SignatureFailure()
+		}
+		if saml.Expired(msg.Info, msg.ReferenceTime(), 0) {
+			s.metrics.ExpiredAssertion()
+		}
+	}
+}
+
+// records converts inspected messages for the store
+func records(messages []Message, capturedAt time.Time) []store.Record {
+	records := make([]store.Record, 0, len(messages))
+	for _, msg := range messages {
+		r := store.NewRecord(msg.Info, []byte(msg.XML), capturedAt)
+		r.Origin = "serve"
+		if r.Type == "" {
+			r.Type = msg.Type
+		}
+		r.Source = msg.Source
+		r.URL = msg.URL
+		r.Error = msg.Error
+		records = append(records, r)
+	}
+	return records
+}
+
+// Inspect decodes, decrypts and parses the given input, which may be a HAR
+// file, raw XML or base64-encoded SAML. It is safe for concurrent use.
+func Inspect(ctx context.Context, input string, decryptor *saml.Decryptor) (*InspectResponse, error) {
+	result, err := inspect.Run(ctx, inspect.Request{
+		Input:     input,
+		Decryptor: decryptor,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return response(result), nil
+}
+
+// response converts the messages of result for the web UI
+func response(result *inspect.Result) *InspectResponse {
+	resp := &InspectResponse{Messages: []Message{}}
+	for _, m := range result.Messages {
+		msg := Message{
+			Index: m.Index(),
+			Type:  m.Type(),
+			Info:  m.Info,
+			XML:   string(m.XML),
+		}
+		if m.Extracted != nil {
+			msg.Source = m.Extracted.Source
+			msg.URL = m.Extracted.URL
+			msg.ParameterName = m.Extracted.ParameterName
+		}
+		if errors.Is(m.Err, inspect.ErrNoKey) {
+			msg.Error = "encrypted assertion detected - upload a private key to decrypt"
+		} else if m.Err != nil {
+			msg.Error = m.Err.Error()
+		}
+		resp.Messages = append(resp.Messages, msg)
+	}
+	return resp
+}
+
+// readFormValue reads a multipart file field, falling back to a plain
+// text field when the file is absent
+func readFormValue(r *http.Request, fileField, textField string) (string, error) {
+	file, _, err := r.FormFile(fileField)
+	if err == nil {
+		defer file.Close()
+		data, err := io.ReadAll(file)
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s: %w", fileField, err)
+		}
+		return string(data), nil
+	}
+	if err != http.ErrMissingFile {
+		return "", fmt.Errorf("failed to read %s: %w", fileField, err)
+	}
+
+	if textField == "" {
+		return "", nil
+	}
+	return r.FormValue(textField), nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
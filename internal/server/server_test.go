@@ -0,0 +1,175 @@
+package server
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+
+	"github.com/gliwka/SAMLurai/internal/metrics"
+	"github.com/gliwka/SAMLurai/internal/store"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testResponse = `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_resp1"><saml:Issuer xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion">https://idp.example.com</saml:Issuer><samlp:Status><samlp:StatusCode Value="urn:oasis:names:tc:SAML:2.0:status:Success"/></samlp:Status></samlp:Response>`
+
+func newUpload(t *testing.T, fields map[string]string) (*bytes.Buffer, string) {
+	t.Helper()
+
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+	for name, value := range fields {
+		if name == "data" {
+			require.NoError(t, writer.WriteField(name, value))
+			continue
+		}
+		part, err := writer.CreateFormFile(name, name+".txt")
+		require.NoError(t, err)
+		_, err = part.Write([]byte(value))
+		require.NoError(t, err)
+	}
+	require.NoError(t, writer.Close())
+
+	return body, writer.FormDataContentType()
+}
+
+func TestServer_Index(t *testing.T) {
+	rec := httptest.NewRecorder()
+	NewServer().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Header().Get("Content-Type"), "text/html")
+	assert.Contains(t, rec.Body.String(), "SAMLurai")
+}
+
+func TestServer_NotFound(t *testing.T) {
+	rec := httptest.NewRecorder()
+	NewServer().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/missing", nil))
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestServer_InspectMethodNotAllowed(t *testing.T) {
+	rec := httptest.NewRecorder()
+	NewServer().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/inspect", nil))
+
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+func TestServer_InspectBase64Text(t *testing.T) {
+	body, contentType := newUpload(t, map[string]string{
+		"data": base64.StdEncoding.EncodeToString([]byte(testResponse)),
+	})
+	req := httptest.NewRequest(http.MethodPost, "/api/inspect", body)
+	req.Header.Set("Content-Type", contentType)
+
+	rec := httptest.NewRecorder()
+	NewServer().ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var resp InspectResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Len(t, resp.Messages, 1)
+	assert.Equal(t, "Response", resp.Messages[0].Type)
+	require.NotNil(t, resp.Messages[0].Info)
+	assert.Equal(t, "https://idp.example.com", resp.Messages[0].Info.Issuer)
+}
+
+func TestServer_InspectHARUpload(t *testing.T) {
+	// Browsers record form parameter values URL-encoded
+	encoded := url.QueryEscape(base64.StdEncoding.EncodeToString([]byte(testResponse)))
+	har := `{"log": {"entries": [{"request": {"method": "POST", "url": "https://sp.example.com/acs",
+		"postData": {"mimeType": "text/plain", "params": [{"name": "SAMLResponse", "value": "` + encoded + `"}]}},
+		"response": {"content": {"mimeType": "text/html", "text": ""}}}]}}`
+
+	body, contentType := newUpload(t, map[string]string{"input": har})
+	req := httptest.NewRequest(http.MethodPost, "/api/inspect", body)
+	req.Header.Set("Content-Type", contentType)
+
+	rec := httptest.NewRecorder()
+	NewServer().ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var resp InspectResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Len(t, resp.Messages, 1)
+	assert.Equal(t, "request-body", resp.Messages[0].Source)
+	assert.Equal(t, "SAMLResponse", resp.Messages[0].ParameterName)
+}
+
+func TestServer_InspectInvalidKey(t *testing.T) {
+	body, contentType := newUpload(t, map[string]string{
+		"data": testResponse,
+		"key":  "not a key",
+	})
+	req := httptest.NewRequest(http.MethodPost, "/api/inspect", body)
+	req.Header.Set("Content-Type", contentType)
+
+	rec := httptest.NewRecorder()
+	NewServer().ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "failed to load private key")
+}
+
+func TestServer_InspectNoInput(t *testing.T) {
+	body, contentType := newUpload(t, map[string]string{"data": ""})
+	req := httptest.NewRequest(http.MethodPost, "/api/inspect", body)
+	req.Header.Set("Content-Type", contentType)
+
+	rec := httptest.NewRecorder()
+	NewServer().ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "no input provided")
+}
+
+func TestServer_InspectStoresMessages(t *testing.T) {
+	st, err := store.OpenJSONL(filepath.Join(t.TempDir(), "captures.jsonl"))
+	require.NoError(t, err)
+	defer st.Close()
+
+	body, contentType := newUpload(t, map[string]string{"data": testResponse})
+	req := httptest.NewRequest(http.MethodPost, "/api/inspect", body)
+	req.Header.Set("Content-Type", contentType)
+
+	rec := httptest.NewRecorder()
+	NewServer().WithStore(st).ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	records, err := st.List(req.Context(), store.Filter{})
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, "serve", records[0].Origin)
+	assert.Equal(t, "Response", records[0].Type)
+	assert.Equal(t, "https://idp.example.com", records[0].Issuer)
+	assert.Equal(t, testResponse, records[0].XML)
+}
+
+func TestServer_Metrics(t *testing.T) {
+	s := NewServer().WithMetrics(metrics.New())
+	for _, input := range []string{testResponse, "not SAML"} {
+		body, contentType := newUpload(t, map[string]string{"data": input})
+		req := httptest.NewRequest(http.MethodPost, "/api/inspect", body)
+		req.Header.Set("Content-Type", contentType)
+		s.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, metrics.Path, nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `samlurai_messages_total{type="Response"} 1`)
+	assert.Contains(t, rec.Body.String(), "samlurai_parse_failures_total 1\n")
+	assert.Contains(t, rec.Body.String(), "samlurai_signature_failures_total 0\n")
+
+	rec = httptest.NewRecorder()
+	NewServer().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, metrics.Path, nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code, "metrics are only served when enabled")
+}
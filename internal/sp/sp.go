@@ -0,0 +1,526 @@
+// Package sp implements a minimal SAML service provider for debugging
+// identity providers. It sends AuthnRequests and decodes, verifies and
+// decrypts the Responses posted to its ACS.
+package sp
+
+import (
+	"crypto"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gliwka/SAMLurai/internal/log"
+	"github.com/gliwka/SAMLurai/internal/metadata"
+	"github.com/gliwka/SAMLurai/internal/metrics"
+	"github.com/gliwka/SAMLurai/internal/saml"
+)
+
+// Endpoint paths, relative to the base URL
+const (
+	MetadataPath = "/metadata"
+	LoginPath    = "/login"
+	ACSPath      = "/acs"
+)
+
+// pendingRequestLimit bounds the AuthnRequest IDs remembered to match
+// InResponseTo against
+const pendingRequestLimit = 1000
+
+// Config describes the test SP
+type Config struct {
+	// EntityID is the entity ID of the SP
+	EntityID string
+
+	// BaseURL is the URL the SP is reachable at, used for the ACS URL
+	BaseURL string
+
+	// IdPEntityID is the expected Issuer of Responses (optional)
+	IdPEntityID string
+
+	// IdPSSO is the SingleSignOnService URL AuthnRequests are sent to,
+	// with Binding (saml.BindingHTTPRedirect or saml.BindingHTTPPost)
+	IdPSSO  string
+	Binding string
+
+	// IdPCerts verify the signatures of Responses. If empty, the KeyInfo
+	// certificates are used, which proves integrity but not who signed,
+	// and Responses that verify are reported as self-signed.
+	IdPCerts []*x509.Certificate
+
+	// NameIDFormat is requested in AuthnRequests (optional)
+	NameIDFormat string
+
+	// Key and Cert sign AuthnRequests with SigAlg if SignRequests is set.
+	// Cert is published in the metadata for signing and encryption.
+	Key          crypto.Signer
+	Cert         *x509.Certificate
+	SigAlg       string
+	SignRequests bool
+
+	// Decryptor decrypts encrypted assertions (optional)
+	Decryptor *saml.Decryptor
+
+	// ClockSkew is tolerated when checking validity windows
+	ClockSkew time.Duration
+}
+
+// Sent describes an AuthnRequest sent by the SP
+type Sent struct {
+	ID          string `json:"id"`
+	Binding     string `json:"binding"`
+	Destination string `json:"destination"`
+	Signed      bool   `json:"signed"`
+	RelayState  string `json:"relay_state,omitempty"`
+	XML         string `json:"xml"`
+}
+
+// Received describes a Response posted to the ACS
+type Received struct {
+	RelayState string `json:"relay_state,omitempty"`
+
+	// Info is the parsed Response; for an encrypted assertion it holds
+	// the envelope and, once decrypted, the assertion
+	Info *saml.SAMLInfo `json:"info,omitempty"`
+
+	// Verdict is saml.VerdictVerified, VerdictSelfSigned, VerdictSigFail
+	// or VerdictUnsigned, with the elements that verified or the reason
+	// verification failed
+	Verdict     string   `json:"verdict,omitempty"`
+	Verified    []string `json:"verified,omitempty"`
+	VerifyError string   `json:"verify_error,omitempty"`
+
+	Encrypted bool `json:"encrypted"`
+	Decrypted bool `json:"decrypted"`
+
+	Warnings []string `json:"warnings,omitempty"`
+
+	// Error is set when the Response could not be decoded, parsed or
+	// decrypted
+	Error string `json:"error,omitempty"`
+
+	// XML is the Response as received, AssertionXML the decrypted
+	// assertion
+	XML          string `json:"xml,omitempty"`
+	AssertionXML string `json:"assertion_xml,omitempty"`
+}
+
+// Server serves the SP endpoints
+type Server struct {
+	cfg       Config
+	mux       *http.ServeMux
+	onSend    func(Sent)
+	onReceive func(Received)
+	metrics   *metrics.Metrics
+
+	mu      sync.Mutex
+	pending map[string]time.Time
+}
+
+// NewServer creates an SP for cfg
+func NewServer(cfg Config) (*Server, error) {
+	if cfg.EntityID == "" {
+		return nil, errors.New("an entity ID is required")
+	}
+	if cfg.SignRequests && cfg.Key == nil {
+		return nil, errors.New("signing AuthnRequests requires a key")
+	}
+	if cfg.Binding == "" {
+		cfg.Binding = saml.BindingHTTPRedirect
+	}
+	if cfg.Binding != saml.BindingHTTPRedirect && cfg.Binding != saml.BindingHTTPPost {
+		return nil, fmt.Errorf("unsupported binding %s", cfg.Binding)
+	}
+	cfg.BaseURL = strings.TrimSuffix(cfg.BaseURL, "/")
+
+	s := &Server{cfg: cfg, mux: http.NewServeMux(), pending: map[string]time.Time{}}
+	s.mux.HandleFunc(MetadataPath, s.handleMetadata)
+	s.mux.HandleFunc(LoginPath, s.handleLogin)
+	s.mux.HandleFunc(ACSPath, s.handleACS)
+	return s, nil
+}
+
+// OnSend calls fn for every AuthnRequest sent
+func (s *Server) OnSend(fn func(Sent)) *Server {
+	s.onSend = fn
+	return s
+}
+
+// OnReceive calls fn for every Response posted to the ACS
+func (s *Server) OnReceive(fn func(Received)) *Server {
+	s.onReceive = fn
+	return s
+}
+
+// WithMetrics counts the Responses posted to the ACS in m and serves them
+// at metrics.Path
+func (s *Server) WithMetrics(m *metrics.Metrics) *Server {
+	s.metrics = m
+	s.mux.Handle(metrics.Path, m)
+	return s
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// ACSURL returns the URL Responses are posted to
+func (s *Server) ACSURL() string {
+	return s.cfg.BaseURL + ACSPath
+}
+
+// Metadata returns the SP's EntityDescriptor
+func (s *Server) Metadata() ([]byte, error) {
+	cfg := &metadata.SPConfig{
+		EntityID:             s.cfg.EntityID,
+		ACS:                  []metadata.Endpoint{{URL: s.ACSURL()}},
+		SigningCertificate:   s.cfg.Cert,
+		AuthnRequestsSigned:  s.cfg.SignRequests,
+		WantAssertionsSigned: true,
+	}
+	if s.cfg.Decryptor != nil {
+		cfg.EncryptionCertificate = s.cfg.Cert
+	}
+	if s.cfg.NameIDFormat != "" {
+		cfg.NameIDFormats = []string{s.cfg.NameIDFormat}
+	}
+	return metadata.GenerateSP(cfg, time.Now(), nil)
+}
+
+func (s *Server) handleMetadata(w http.ResponseWriter, r *http.Request) {
+	data, err := s.Metadata()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/samlmetadata+xml")
+	_, _ = w.Write(data)
+}
+
+// handleLogin sends an AuthnRequest to the IdP
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	if s.cfg.IdPSSO == "" {
+		http.Error(w, "no IdP SingleSignOnService URL is configured", http.StatusBadRequest)
+		return
+	}
+	doc, err := saml.BuildAuthnRequest(saml.AuthnRequestOptions{
+		Issuer:       s.cfg.EntityID,
+		Destination:  s.cfg.IdPSSO,
+		ACS:          s.ACSURL(),
+		NameIDFormat: s.cfg.NameIDFormat,
+	}, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	req := doc.Root()
+	sent := Sent{
+		ID:          req.SelectAttrValue("ID", ""),
+		Binding:     s.cfg.Binding,
+		Destination: s.cfg.IdPSSO,
+		Signed:      s.cfg.SignRequests,
+		RelayState:  r.URL.Query().Get("RelayState"),
+	}
+
+	// The POST binding signs the XML; the Redirect binding signs the query
+	if s.cfg.Binding == saml.BindingHTTPPost && s.cfg.SignRequests {
+		if err := saml.SignEnveloped(req, s.cfg.Key, s.cfg.Cert, s.cfg.SigAlg); err != nil {
+			http.Error(w, fmt.Sprintf("failed to sign AuthnRequest: %v", err), http.StatusInternalServerError)
+			return
+		}
+	}
+	xmlData, err := doc.WriteToBytes()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sent.XML = string(xmlData)
+
+	var location, form string
+	if s.cfg.Binding == saml.BindingHTTPPost {
+		form, err = saml.PostForm(s.cfg.IdPSSO, xmlData, "SAMLRequest", sent.RelayState)
+	} else {
+		var key crypto.Signer
+		if s.cfg.SignRequests {
+			key = s.cfg.Key
+		}
+		location, err = saml.RedirectURL(s.cfg.IdPSSO, xmlData, "SAMLRequest", sent.RelayState, key, s.cfg.SigAlg)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	s.remember(sent.ID)
+	if s.onSend != nil {
+		s.onSend(sent)
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	if location != "" {
+		http.Redirect(w, r, location, http.StatusFound)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write([]byte(form))
+}
+
+// remember records the ID of a sent AuthnRequest, forgetting the oldest
+// once the limit is reached
+func (s *Server) remember(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.pending) >= pendingRequestLimit {
+		var oldest string
+		for pendingID, sentAt := range s.pending {
+			if oldest == "" || sentAt.Before(s.pending[oldest]) {
+				oldest = pendingID
+			}
+		}
+		delete(s.pending, oldest)
+	}
+	s.pending[id] = time.Now()
+}
+
+// answered reports whether id is a pending AuthnRequest, which it no
+// longer is afterwards
+func (s *Server) answered(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.pending[id]
+	delete(s.pending, id)
+	return ok
+}
+
+// handleACS receives a Response with the HTTP-POST binding
+func (s *Server) handleACS(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, fmt.Sprintf("failed to parse form: %v", err), http.StatusBadRequest)
+		return
+	}
+	encoded := r.PostForm.Get("SAMLResponse")
+	if encoded == "" {
+		http.Error(w, "no SAMLResponse parameter", http.StatusBadRequest)
+		return
+	}
+
+	received := s.Receive(encoded, r.PostForm.Get("RelayState"))
+	if s.onReceive != nil {
+		s.onReceive(received)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := resultTemplate.Execute(w, received); err != nil {
+		log.Warn("failed to render result page", "error", err)
+	}
+}
+
+// Receive decodes, verifies, decrypts and checks a base64-encoded
+// Response as posted to the ACS
+func (s *Server) Receive(encoded, relayState string) Received {
+	raw, err := saml.NewDecoder().Decode(encoded)
+	if err != nil {
+		s.metrics.Message("")
+		s.metrics.ParseFailure()
+		return Received{RelayState: relayState, Error: fmt.Sprintf("failed to decode SAMLResponse: %v", err)}
+	}
+	log.Debug("received Response", "bytes", len(raw))
+
+	received := Inspect(raw, s.cfg.IdPCerts, s.cfg.Decryptor)
+	received.RelayState = relayState
+	received.Warnings = s.warnings(received.Info)
+	s.observe(received)
+	return received
+}
+
+// observe counts a received Response and its failures in the metrics
+func (s *Server) observe(received Received) {
+	if s.metrics == nil {
+		return
+	}
+	if received.Info == nil {
+		s.metrics.Message("")
+		s.metrics.ParseFailure()
+		return
+	}
+	s.metrics.Message(received.Info.Type)
+	switch {
+	case received.Encrypted && !received.Decrypted:
+		s.metrics.DecryptionFailure()
+	case received.Error != "":
+		s.metrics.ParseFailure()
+	}
+	if received.Verdict == saml.VerdictSigFail {
+		s.metrics.SignatureFailure()
+	}
+	if saml.Expired(received.Info, time.Now(), s.cfg.ClockSkew) {
+		s.metrics.ExpiredAssertion()
+	}
+}
+
+// Inspect parses a Response, verifies its signatures against certs and
+// decrypts its assertion with decryptor, if set. Without certs, signatures
+// are only checked against their own KeyInfo and a Response that verifies
+// is saml.VerdictSelfSigned. It is used for Responses
+// an SP obtains other than at its ACS, e.g. over the SOAP binding.
+func Inspect(raw []byte, certs []*x509.Certificate, decryptor *saml.Decryptor) Received {
+	received := Received{XML: string(raw)}
+	var err error
+	verification := saml.VerifyMessage(raw, certs)
+	parser := saml.NewParser()
+	received.Encrypted = saml.IsEncrypted(raw)
+	if !received.Encrypted {
+		if received.Info, err = parser.Parse(raw); err != nil {
+			received.Error = err.Error()
+			return received
+		}
+	} else {
+		if received.Info, err = parser.ParsePartial(raw); err != nil {
+			received.Error = err.Error()
+			return received
+		}
+		if decryptor == nil {
+			received.Error = "the assertion is encrypted and no decryption key is configured"
+		} else if decrypted, err := decryptor.Decrypt(raw); err != nil {
+			received.Error = fmt.Sprintf("decryption failed: %v", err)
+		} else {
+			received.Decrypted = true
+			received.AssertionXML = string(decrypted)
+			if received.Info.Assertion, err = parser.Parse(decrypted); err != nil {
+				received.Error = err.Error()
+			}
+			verifyDecrypted(&verification, decrypted, certs)
+		}
+	}
+
+	if len(certs) == 0 && verification.Verdict == saml.VerdictVerified {
+		verification.Verdict = saml.VerdictSelfSigned
+	}
+	received.Verdict = verification.Verdict
+	received.Verified = verification.Verified
+	if verification.Err != nil {
+		received.VerifyError = verification.Err.Error()
+	}
+	return received
+}
+
+// verifyDecrypted adds the signature of a decrypted assertion to v, which
+// VerifyMessage could not look into. An unsigned assertion fails v if
+// other signatures verified but not one over the whole Response, as with
+// a wrapped plain assertion.
+func verifyDecrypted(v *saml.MessageVerification, assertion []byte, certs []*x509.Certificate) {
+	if v.Verdict == saml.VerdictSigFail {
+		return
+	}
+	doc, err := saml.ReadDocument(assertion)
+	if err != nil || doc.Root() == nil {
+		return
+	}
+	_, err = saml.VerifySignature(doc.Root(), certs)
+	switch {
+	case err == nil:
+		v.Verdict = saml.VerdictVerified
+		v.Verified = append(v.Verified, doc.Root().Tag)
+	case errors.Is(err, saml.ErrNoSignature):
+		if v.Verdict == saml.VerdictVerified && !slices.Contains(v.Verified, "Response") {
+			v.Verdict = saml.VerdictSigFail
+			v.Err = saml.ErrUnsignedAssertion
+		}
+	default:
+		v.Verdict = saml.VerdictSigFail
+		v.Err = fmt.Errorf("%s: %w", doc.Root().Tag, err)
+	}
+}
+
+// warnings checks a Response against this SP: its validity, recipient,
+// audience and issuer, and whether it answers a request sent by the SP
+func (s *Server) warnings(info *saml.SAMLInfo) []string {
+	if info == nil {
+		return nil
+	}
+	warnings := saml.WarningsWithOptions(info, saml.CheckOptions{
+		Now:              time.Now(),
+		ClockSkew:        s.cfg.ClockSkew,
+		DeliveredTo:      s.ACSURL(),
+		ExpectedAudience: s.cfg.EntityID,
+		URLs:             saml.DefaultURLNormalization(),
+	})
+	if s.cfg.IdPEntityID != "" && info.Issuer != "" && info.Issuer != s.cfg.IdPEntityID {
+		warnings = append(warnings, fmt.Sprintf("issuer %s is not the configured IdP %s", info.Issuer, s.cfg.IdPEntityID))
+	}
+	switch {
+	case info.InResponseTo == "":
+		warnings = append(warnings, "unsolicited Response (IdP-initiated SSO)")
+	case !s.answered(info.InResponseTo):
+		warnings = append(warnings, fmt.Sprintf("InResponseTo %s does not match a pending AuthnRequest of this SP", info.InResponseTo))
+	}
+	return warnings
+}
+
+var resultTemplate = template.Must(template.New("result").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>SAMLurai test SP</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+.ok { color: #1a7f37; } .bad { color: #cf222e; } .warn { color: #9a6700; }
+table { border-collapse: collapse; } td, th { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+pre { background: #f6f8fa; padding: 1em; overflow: auto; }
+</style>
+</head>
+<body>
+<h1>SAML Response received</h1>
+{{- if .Error}}
+<p class="bad">{{.Error}}</p>
+{{- end}}
+{{- if eq .Verdict "verified"}}
+<p class="ok">Signature verified ({{range $i, $e := .Verified}}{{if $i}}, {{end}}{{$e}}{{end}})</p>
+{{- else if eq .Verdict "selfsigned"}}
+<p class="warn">Signature unmodified, but checked only against its own certificate ({{range $i, $e := .Verified}}{{if $i}}, {{end}}{{$e}}{{end}}); configure the IdP certificate to verify the signer</p>
+{{- else if eq .Verdict "sigfail"}}
+<p class="bad">Signature verification failed: {{.VerifyError}}</p>
+{{- else if eq .Verdict "unsigned"}}
+<p class="warn">Not signed</p>
+{{- end}}
+{{- with .Info}}{{with .Assertion}}{{with .Subject}}
+<p>NameID: <strong>{{.NameID}}</strong>{{if .NameIDFormat}} ({{.NameIDFormat}}){{end}}</p>
+{{- end}}
+{{- if .Attributes}}
+<table>
+<tr><th>Attribute</th><th>Values</th></tr>
+{{- range .Attributes}}
+<tr><td>{{if .FriendlyName}}{{.FriendlyName}} ({{.Name}}){{else}}{{.Name}}{{end}}</td><td>{{range $i, $v := .Values}}{{if $i}}<br>{{end}}{{$v}}{{end}}</td></tr>
+{{- end}}
+</table>
+{{- end}}{{end}}{{end}}
+{{- if .Warnings}}
+<h2>Warnings</h2>
+<ul>{{range .Warnings}}<li class="warn">{{.}}</li>{{end}}</ul>
+{{- end}}
+{{- if .RelayState}}
+<p>RelayState: {{.RelayState}}</p>
+{{- end}}
+<p><a href="` + LoginPath + `">Log in again</a></p>
+{{- if .AssertionXML}}
+<h2>Decrypted assertion</h2>
+<pre>{{.AssertionXML}}</pre>
+{{- end}}
+{{- if .XML}}
+<h2>Response</h2>
+<pre>{{.XML}}</pre>
+{{- end}}
+</body>
+</html>
+`))
@@ -0,0 +1,329 @@
+package sp
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/beevik/etree"
+	"github.com/gliwka/SAMLurai/internal/idp"
+	"github.com/gliwka/SAMLurai/internal/metrics"
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestPair creates an SP and a test IdP that trust each other
+func newTestPair(t *testing.T, configure func(*Config, *idp.Config)) (*Server, *idp.Server) {
+	t.Helper()
+
+	idpKey, idpCert, err := saml.NewSelfSignedKey("idp.example.com")
+	require.NoError(t, err)
+	spKey, spCert, err := saml.NewSelfSignedKey("sp.example.com")
+	require.NoError(t, err)
+
+	idpCfg := idp.Config{
+		EntityID:      "https://idp.example.com",
+		BaseURL:       "https://idp.example.com",
+		Key:           idpKey,
+		Cert:          idpCert,
+		SigAlg:        saml.SigAlgRSASHA256,
+		NameID:        "alice@example.com",
+		Attributes:    []saml.Attribute{{Name: "mail", Values: []string{"alice@example.com"}}},
+		SignAssertion: true,
+	}
+	spCfg := Config{
+		EntityID:    "http://127.0.0.1:8082/metadata",
+		BaseURL:     "http://127.0.0.1:8082",
+		IdPEntityID: idpCfg.EntityID,
+		IdPSSO:      idpCfg.BaseURL + idp.SSOPath,
+		IdPCerts:    []*x509.Certificate{idpCert},
+		Key:         spKey,
+		Cert:        spCert,
+		SigAlg:      saml.SigAlgRSASHA256,
+		Decryptor:   saml.NewDecryptorWithKey(spKey),
+	}
+	if configure != nil {
+		configure(&spCfg, &idpCfg)
+	}
+
+	s, err := NewServer(spCfg)
+	require.NoError(t, err)
+	i, err := idp.NewServer(idpCfg)
+	require.NoError(t, err)
+	return s, i
+}
+
+// postedResponse returns the SAMLResponse of the auto-posting form in body
+func postedResponse(t *testing.T, body string) string {
+	t.Helper()
+
+	value := regexp.MustCompile(`name="SAMLResponse" value="([^"]+)"`).FindStringSubmatch(body)
+	require.Len(t, value, 2)
+	return html.UnescapeString(value[1])
+}
+
+// roundTrip runs an SP-initiated login against the test IdP and posts the
+// Response to the ACS
+func roundTrip(t *testing.T, s *Server, i *idp.Server) (Received, *httptest.ResponseRecorder) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, LoginPath+"?RelayState=%2Fapp", nil))
+	require.Equal(t, http.StatusFound, rec.Code, rec.Body.String())
+
+	idpRec := httptest.NewRecorder()
+	i.ServeHTTP(idpRec, httptest.NewRequest(http.MethodGet, rec.Header().Get("Location"), nil))
+	require.Equal(t, http.StatusOK, idpRec.Code, idpRec.Body.String())
+
+	var received []Received
+	s.OnReceive(func(r Received) { received = append(received, r) })
+	form := url.Values{"SAMLResponse": {postedResponse(t, idpRec.Body.String())}, "RelayState": {"/app"}}
+	req := httptest.NewRequest(http.MethodPost, ACSPath, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	acsRec := httptest.NewRecorder()
+	s.ServeHTTP(acsRec, req)
+	require.Len(t, received, 1)
+	return received[0], acsRec
+}
+
+func TestServer_Metadata(t *testing.T) {
+	s, _ := newTestPair(t, nil)
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, MetadataPath, nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `entityID="http://127.0.0.1:8082/metadata"`)
+	assert.Contains(t, rec.Body.String(), `Location="http://127.0.0.1:8082/acs"`)
+	assert.Contains(t, rec.Body.String(), `use="encryption"`)
+}
+
+func TestServer_LoginRedirect(t *testing.T) {
+	var sent []Sent
+	s, _ := newTestPair(t, func(cfg *Config, _ *idp.Config) { cfg.SignRequests = true })
+	s.OnSend(func(r Sent) { sent = append(sent, r) })
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, LoginPath, nil))
+	require.Equal(t, http.StatusFound, rec.Code)
+
+	location, err := url.Parse(rec.Header().Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, "https://idp.example.com/sso", location.Scheme+"://"+location.Host+location.Path)
+	assert.NotEmpty(t, location.Query().Get("Signature"))
+
+	require.Len(t, sent, 1)
+	assert.Equal(t, saml.BindingHTTPRedirect, sent[0].Binding)
+	assert.True(t, sent[0].Signed)
+	assert.Contains(t, sent[0].XML, `AssertionConsumerServiceURL="http://127.0.0.1:8082/acs"`)
+}
+
+func TestServer_LoginPost(t *testing.T) {
+	s, _ := newTestPair(t, func(cfg *Config, _ *idp.Config) {
+		cfg.Binding = saml.BindingHTTPPost
+		cfg.SignRequests = true
+	})
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, LoginPath, nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	value := regexp.MustCompile(`name="SAMLRequest" value="([^"]+)"`).FindStringSubmatch(rec.Body.String())
+	require.Len(t, value, 2)
+	xmlData, err := base64.StdEncoding.DecodeString(html.UnescapeString(value[1]))
+	require.NoError(t, err)
+	assert.Equal(t, saml.VerdictVerified, saml.VerifyMessage(xmlData, nil).Verdict)
+}
+
+func TestServer_ACS(t *testing.T) {
+	s, i := newTestPair(t, nil)
+
+	received, rec := roundTrip(t, s, i)
+	assert.Empty(t, received.Error)
+	assert.Equal(t, saml.VerdictVerified, received.Verdict)
+	assert.False(t, received.Encrypted)
+	assert.Equal(t, "/app", received.RelayState)
+	require.NotNil(t, received.Info.Assertion)
+	assert.Equal(t, "alice@example.com", received.Info.Assertion.Subject.NameID)
+	assert.Empty(t, received.Warnings)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Signature verified")
+	assert.Contains(t, rec.Body.String(), "alice@example.com")
+}
+
+func TestServer_ACSSelfSigned(t *testing.T) {
+	// Without an IdP certificate anyone's signature verifies against its
+	// own KeyInfo
+	s, i := newTestPair(t, func(cfg *Config, _ *idp.Config) {
+		cfg.IdPCerts = nil
+	})
+
+	received, rec := roundTrip(t, s, i)
+	assert.Equal(t, saml.VerdictSelfSigned, received.Verdict)
+	assert.NotEmpty(t, received.Verified)
+	assert.Contains(t, rec.Body.String(), `<p class="warn">Signature unmodified, but checked only against its own certificate`)
+	assert.NotContains(t, rec.Body.String(), "Signature verified")
+}
+
+func TestServer_ACSEncrypted(t *testing.T) {
+	s, i := newTestPair(t, func(cfg *Config, idpCfg *idp.Config) {
+		idpCfg.EncryptionCert = cfg.Cert
+	})
+
+	received, _ := roundTrip(t, s, i)
+	assert.Empty(t, received.Error)
+	assert.True(t, received.Encrypted)
+	assert.True(t, received.Decrypted)
+	assert.Equal(t, saml.VerdictVerified, received.Verdict)
+	assert.Contains(t, received.Verified, "Assertion")
+	require.NotNil(t, received.Info.Assertion)
+	assert.Equal(t, "alice@example.com", received.Info.Assertion.Subject.NameID)
+	assert.Contains(t, received.AssertionXML, "alice@example.com")
+}
+
+func TestServer_ACSWarnings(t *testing.T) {
+	_, otherCert, err := saml.NewSelfSignedKey("other.example.com")
+	require.NoError(t, err)
+	s, i := newTestPair(t, func(cfg *Config, idpCfg *idp.Config) {
+		cfg.IdPCerts = []*x509.Certificate{otherCert}
+		idpCfg.Audience = "https://other-sp.example.com"
+	})
+
+	received, rec := roundTrip(t, s, i)
+	assert.Equal(t, saml.VerdictSigFail, received.Verdict)
+	assert.NotEmpty(t, received.VerifyError)
+	assert.Contains(t, strings.Join(received.Warnings, "\n"), "audience restriction does not include http://127.0.0.1:8082/metadata")
+	assert.Contains(t, rec.Body.String(), "Signature verification failed")
+}
+
+func TestServer_ACSUnsolicited(t *testing.T) {
+	s, i := newTestPair(t, func(_ *Config, idpCfg *idp.Config) {
+		idpCfg.ACS = "http://127.0.0.1:8082/acs"
+		idpCfg.Audience = "http://127.0.0.1:8082/metadata"
+	})
+
+	rec := httptest.NewRecorder()
+	i.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, idp.LoginPath, nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	received := s.Receive(postedResponse(t, rec.Body.String()), "")
+	assert.Equal(t, saml.VerdictVerified, received.Verdict)
+	assert.Equal(t, []string{"unsolicited Response (IdP-initiated SSO)"}, received.Warnings)
+}
+
+func TestServer_ACSRejects(t *testing.T) {
+	s, _ := newTestPair(t, nil)
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, ACSPath, nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+
+	req := httptest.NewRequest(http.MethodPost, ACSPath, strings.NewReader(""))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "no SAMLResponse")
+
+	received := s.Receive("not base64!", "")
+	assert.Contains(t, received.Error, "failed to decode")
+}
+
+func TestServer_Metrics(t *testing.T) {
+	_, otherCert, err := saml.NewSelfSignedKey("other.example.com")
+	require.NoError(t, err)
+	s, i := newTestPair(t, func(cfg *Config, idpCfg *idp.Config) {
+		cfg.IdPCerts = []*x509.Certificate{otherCert}
+		idpCfg.EncryptionCert = cfg.Cert
+	})
+	s.WithMetrics(metrics.New())
+
+	roundTrip(t, s, i)
+	s.Receive("not base64!", "")
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, metrics.Path, nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	body := rec.Body.String()
+	assert.Contains(t, body, `samlurai_messages_total{type="Response (Encrypted)"} 1`)
+	assert.Contains(t, body, `samlurai_messages_total{type="Unknown"} 1`)
+	assert.Contains(t, body, "samlurai_parse_failures_total 1\n")
+	assert.Contains(t, body, "samlurai_signature_failures_total 1\n")
+	assert.Contains(t, body, "samlurai_decryption_failures_total 0\n")
+	assert.Contains(t, body, "samlurai_expired_assertions_total 0\n")
+}
+
+func TestNewServer_Invalid(t *testing.T) {
+	_, err := NewServer(Config{})
+	assert.ErrorContains(t, err, "entity ID is required")
+
+	_, err = NewServer(Config{EntityID: "https://sp.example.com", SignRequests: true})
+	assert.ErrorContains(t, err, "requires a key")
+
+	_, err = NewServer(Config{EntityID: "https://sp.example.com", Binding: "urn:example"})
+	assert.ErrorContains(t, err, "unsupported binding")
+}
+
+// signedAssertionResponse returns a Response with an assertion for nameID,
+// signed by key unless it is nil
+func signedAssertionResponse(t *testing.T, nameID string, key *rsa.PrivateKey, cert *x509.Certificate) *etree.Document {
+	t.Helper()
+	doc, err := saml.BuildResponse(saml.ResponseOptions{
+		Issuer:      "https://idp.example.com",
+		Destination: "https://sp.example.com/acs",
+		Audience:    "https://sp.example.com",
+		NameID:      nameID,
+	}, time.Now())
+	require.NoError(t, err)
+	if key != nil {
+		require.NoError(t, saml.SignEnveloped(saml.ResponseAssertion(doc.Root()), key, cert, saml.SigAlgRSASHA256))
+	}
+	return doc
+}
+
+func TestInspect_SignatureWrapping(t *testing.T) {
+	key, cert, err := saml.NewSelfSignedKey("idp.example.com")
+	require.NoError(t, err)
+	certs := []*x509.Certificate{cert}
+
+	// An unsigned assertion after the signed one is the one shown
+	doc := signedAssertionResponse(t, "alice@example.com", key, cert)
+	forged := saml.ResponseAssertion(doc.Root()).Copy()
+	forged.RemoveChild(forged.SelectElement("Signature"))
+	forged.CreateAttr("ID", "_forged")
+	forged.FindElement(".//NameID").SetText("eve@example.com")
+	doc.Root().AddChild(forged)
+	wrapped, err := doc.WriteToBytes()
+	require.NoError(t, err)
+
+	received := Inspect(wrapped, certs, nil)
+	require.NotNil(t, received.Info.Assertion)
+	assert.Equal(t, "eve@example.com", received.Info.Assertion.Subject.NameID)
+	assert.Equal(t, saml.VerdictSigFail, received.Verdict)
+	assert.Contains(t, received.VerifyError, "neither signed nor covered")
+
+	// The same with the unsigned assertion encrypted next to the signed one
+	spKey, spCert, err := saml.NewSelfSignedKey("sp.example.com")
+	require.NoError(t, err)
+	doc = signedAssertionResponse(t, "alice@example.com", key, cert)
+	unsigned := signedAssertionResponse(t, "eve@example.com", nil, nil)
+	require.NoError(t, saml.EncryptAssertion(unsigned.Root(), spCert))
+	doc.Root().AddChild(unsigned.Root().SelectElement("EncryptedAssertion"))
+	wrapped, err = doc.WriteToBytes()
+	require.NoError(t, err)
+
+	received = Inspect(wrapped, certs, saml.NewDecryptorWithKey(spKey))
+	assert.True(t, received.Decrypted)
+	require.NotNil(t, received.Info.Assertion)
+	assert.Equal(t, "eve@example.com", received.Info.Assertion.Subject.NameID)
+	assert.Equal(t, saml.VerdictSigFail, received.Verdict)
+}
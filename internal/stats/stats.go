@@ -0,0 +1,194 @@
+package stats
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gliwka/SAMLurai/internal/saml"
+)
+
+// Stats holds anonymized aggregate counts across many SAML captures.
+// No identifiers, subjects or attribute values are retained.
+type Stats struct {
+	Files               int            `json:"files"`
+	FilesFailed         int            `json:"files_failed"`
+	Messages            int            `json:"messages"`
+	MessageTypes        map[string]int `json:"message_types"`
+	SignatureAlgorithms map[string]int `json:"signature_algorithms"`
+	DigestAlgorithms    map[string]int `json:"digest_algorithms"`
+	Encryption          map[string]int `json:"encryption"`
+	NameIDFormats       map[string]int `json:"name_id_formats"`
+	ValidityWindows     map[string]int `json:"validity_windows"`
+	Vendors             map[string]int `json:"vendors"`
+}
+
+// Validity window buckets, from shortest to longest
+var validityBuckets = []struct {
+	max   time.Duration
+	label string
+}{
+	{time.Minute, "0-1m"},
+	{5 * time.Minute, "1-5m"},
+	{10 * time.Minute, "5-10m"},
+	{time.Hour, "10m-1h"},
+	{8 * time.Hour, "1-8h"},
+	{24 * time.Hour, "8-24h"},
+}
+
+// New creates an empty Stats
+func New() *Stats {
+	return &Stats{
+		MessageTypes:        map[string]int{},
+		SignatureAlgorithms: map[string]int{},
+		DigestAlgorithms:    map[string]int{},
+		Encryption:          map[string]int{},
+		NameIDFormats:       map[string]int{},
+		ValidityWindows:     map[string]int{},
+		Vendors:             map[string]int{},
+	}
+}
+
+// CollectDir walks dir recursively and adds every file to the statistics
+func (s *Stats) CollectDir(dir string) error {
+	return filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		s.AddFile(path)
+		return nil
+	})
+}
+
+// AddFile reads a HAR, SAML-tracer, XML or base64 file and adds its messages.
+// Files that cannot be read or contain no SAML are counted as failed.
+func (s *Stats) AddFile(path string) {
+	s.Files++
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		s.FilesFailed++
+		return
+	}
+
+	content := strings.TrimSpace(string(data))
+	if saml.LooksLikeHAR(content) || saml.LooksLikeSAMLTracer(content) {
+		results, err := saml.NewHARExtractor().Extract([]byte(content))
+		if err != nil || len(results) == 0 {
+			s.FilesFailed++
+			return
+		}
+		for _, r := range results {
+			s.AddXML(r.DecodedXML)
+		}
+		return
+	}
+
+	xmlData, err := saml.NewDecoder().SmartDecode(content)
+	if err != nil || !s.AddXML(xmlData) {
+		s.FilesFailed++
+	}
+}
+
+// AddXML adds a single SAML document. Encrypted assertions are counted but
+// only their envelope is inspected. It returns false if the document could
+// not be parsed.
+func (s *Stats) AddXML(xmlData []byte) bool {
+	parser := saml.NewParser()
+	encrypted := saml.IsEncrypted(xmlData)
+
+	var info *saml.SAMLInfo
+	var err error
+	if encrypted {
+		info, err = parser.ParsePartial(xmlData)
+	} else {
+		info, err = parser.Parse(xmlData)
+	}
+	if err != nil {
+		return false
+	}
+
+	s.Messages++
+	s.MessageTypes[info.Type]++
+
+	if info.Type == "AuthnRequest" {
+		return true
+	}
+
+	if encrypted {
+		s.Encryption["encrypted"]++
+	} else {
+		s.Encryption["plaintext"]++
+	}
+	s.Vendors[saml.DetectVendor(info)]++
+
+	if info.Type == "Assertion" {
+		s.addAssertion(info)
+		return true
+	}
+
+	s.addSignature(info.Signature)
+	if info.Assertion != nil {
+		s.addAssertion(info.Assertion)
+	}
+
+	return true
+}
+
+func (s *Stats) addAssertion(info *saml.SAMLInfo) {
+	s.addSignature(info.Signature)
+
+	if info.Subject != nil {
+		format := info.Subject.NameIDFormat
+		if format == "" {
+			format = "unspecified"
+		}
+		s.NameIDFormats[format]++
+	}
+
+	if info.Conditions != nil && info.Conditions.NotBefore != nil && info.Conditions.NotOnOrAfter != nil {
+		window := info.Conditions.NotOnOrAfter.Sub(*info.Conditions.NotBefore)
+		s.ValidityWindows[validityBucket(window)]++
+	}
+}
+
+func (s *Stats) addSignature(sig *saml.SignatureInfo) {
+	if sig == nil || !sig.Signed {
+		return
+	}
+	if sig.SignatureMethod != "" {
+		s.SignatureAlgorithms[sig.SignatureMethod]++
+	}
+	if sig.DigestMethod != "" {
+		s.DigestAlgorithms[sig.DigestMethod]++
+	}
+}
+
+func validityBucket(window time.Duration) string {
+	for _, b := range validityBuckets {
+		if window <= b.max {
+			return b.label
+		}
+	}
+	return "over 24h"
+}
+
+// SortedKeys returns the keys of counts ordered by descending count, then name
+func SortedKeys(counts map[string]int) []string {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
@@ -0,0 +1,68 @@
+package stats
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStats_CollectDir(t *testing.T) {
+	s := New()
+	require.NoError(t, s.CollectDir(filepath.Join("..", "..", "testdata", "fixtures", "assertions")))
+
+	assert.Equal(t, 3, s.Files)
+	assert.Equal(t, 0, s.FilesFailed)
+	assert.Equal(t, 3, s.Messages)
+	assert.Equal(t, map[string]int{"Response": 1, "Assertion": 1, "AuthnRequest": 1}, s.MessageTypes)
+	assert.Equal(t, map[string]int{"plaintext": 2}, s.Encryption)
+	assert.Equal(t, 2, s.NameIDFormats["urn:oasis:names:tc:SAML:2.0:nameid-format:emailAddress"])
+	assert.Equal(t, 2, s.ValidityWindows["5-10m"])
+}
+
+func TestStats_AddFile_NotSAML(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "notes.txt")
+	require.NoError(t, os.WriteFile(path, []byte("just some notes"), 0644))
+
+	s := New()
+	s.AddFile(path)
+
+	assert.Equal(t, 1, s.Files)
+	assert.Equal(t, 1, s.FilesFailed)
+	assert.Equal(t, 0, s.Messages)
+}
+
+func TestStats_AddXML_SignatureAndVendor(t *testing.T) {
+	response := `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_r1">
+  <saml:Issuer xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion">https://sts.windows.net/tenant/</saml:Issuer>
+  <ds:Signature xmlns:ds="http://www.w3.org/2000/09/xmldsig#">
+    <ds:SignedInfo>
+      <ds:SignatureMethod Algorithm="http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"/>
+      <ds:Reference><ds:DigestMethod Algorithm="http://www.w3.org/2001/04/xmlenc#sha256"/></ds:Reference>
+    </ds:SignedInfo>
+  </ds:Signature>
+</samlp:Response>`
+
+	s := New()
+	require.True(t, s.AddXML([]byte(response)))
+
+	assert.Equal(t, 1, s.SignatureAlgorithms["http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"])
+	assert.Equal(t, 1, s.DigestAlgorithms["http://www.w3.org/2001/04/xmlenc#sha256"])
+	assert.Equal(t, 1, s.Vendors["Microsoft Entra ID"])
+}
+
+func TestValidityBucket(t *testing.T) {
+	assert.Equal(t, "0-1m", validityBucket(30*time.Second))
+	assert.Equal(t, "5-10m", validityBucket(10*time.Minute))
+	assert.Equal(t, "1-8h", validityBucket(2*time.Hour))
+	assert.Equal(t, "over 24h", validityBucket(48*time.Hour))
+}
+
+func TestSortedKeys(t *testing.T) {
+	counts := map[string]int{"b": 2, "a": 2, "c": 5}
+	assert.Equal(t, []string{"c", "a", "b"}, SortedKeys(counts))
+}
@@ -0,0 +1,158 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+func init() {
+	Register("jsonl", OpenJSONL)
+}
+
+// jsonlStore appends one JSON record per line to a file. It needs no
+// dependencies and the file can be processed with jq, at the cost of a
+// full scan per query.
+type jsonlStore struct {
+	mu     sync.Mutex
+	path   string
+	file   *os.File
+	nextID int64
+}
+
+// OpenJSONL opens or creates a JSON Lines store at path
+func OpenJSONL(path string) (Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create store directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open store: %w", err)
+	}
+
+	s := &jsonlStore{path: path, file: file, nextID: 1}
+	end, err := s.scan(func(r Record) bool {
+		if r.ID >= s.nextID {
+			s.nextID = r.ID + 1
+		}
+		return true
+	})
+	if err == nil {
+		// Drop a line left incomplete by a crash, so the next record
+		// starts on a line of its own
+		var info os.FileInfo
+		if info, err = file.Stat(); err == nil && info.Size() > end {
+			err = file.Truncate(end)
+		}
+	}
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to open store: %w", err)
+	}
+	return s, nil
+}
+
+func (s *jsonlStore) Save(ctx context.Context, records []Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Records are written in one call so concurrent readers never see a
+	// partial batch
+	var buf []byte
+	for i := range records {
+		records[i].ID = s.nextID + int64(i)
+		line, err := json.Marshal(records[i])
+		if err != nil {
+			return fmt.Errorf("failed to encode record: %w", err)
+		}
+		buf = append(append(buf, line...), '\n')
+	}
+	if _, err := s.file.Write(buf); err != nil {
+		return fmt.Errorf("failed to write store: %w", err)
+	}
+	s.nextID += int64(len(records))
+	return nil
+}
+
+func (s *jsonlStore) List(ctx context.Context, filter Filter) ([]Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var records []Record
+	_, err := s.scan(func(r Record) bool {
+		if filter.Match(r) {
+			records = append(records, r)
+		}
+		return ctx.Err() == nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return Limit(records, filter.Limit), nil
+}
+
+func (s *jsonlStore) Get(ctx context.Context, id int64) (*Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var found *Record
+	_, err := s.scan(func(r Record) bool {
+		if r.ID == id {
+			found = &r
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if found == nil {
+		return nil, fmt.Errorf("%w: %d", ErrNotFound, id)
+	}
+	return found, nil
+}
+
+func (s *jsonlStore) Close() error {
+	return s.file.Close()
+}
+
+// scan calls fn for each record until it returns false, and returns the
+// offset after the last complete line read. A truncated last line, e.g.
+// from a crash during a write, is skipped.
+func (s *jsonlStore) scan(fn func(Record) bool) (int64, error) {
+	if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+		return 0, fmt.Errorf("failed to read store: %w", err)
+	}
+	reader := bufio.NewReader(s.file)
+	var end int64
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if len(data) > 0 && data[len(data)-1] == '\n' {
+			end += int64(len(data))
+			if len(bytes.TrimSpace(data)) > 0 {
+				var r Record
+				if jsonErr := json.Unmarshal(data, &r); jsonErr != nil {
+					return end, fmt.Errorf("%s:%d: invalid record: %w", s.path, line, jsonErr)
+				}
+				if !fn(r) {
+					return end, nil
+				}
+			}
+		}
+		if err == io.EOF {
+			return end, nil
+		}
+		if err != nil {
+			return end, fmt.Errorf("failed to read store: %w", err)
+		}
+	}
+}
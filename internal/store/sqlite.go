@@ -0,0 +1,190 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gliwka/SAMLurai/internal/saml"
+
+	// Pure-Go SQLite driver, so the binary needs no cgo
+	_ "modernc.org/sqlite"
+)
+
+func init() {
+	Register("sqlite", OpenSQLite)
+}
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS records (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	captured_at INTEGER NOT NULL,
+	origin      TEXT NOT NULL DEFAULT '',
+	type        TEXT NOT NULL DEFAULT '',
+	issuer      TEXT NOT NULL DEFAULT '',
+	name_id     TEXT NOT NULL DEFAULT '',
+	source      TEXT NOT NULL DEFAULT '',
+	url         TEXT NOT NULL DEFAULT '',
+	info        TEXT,
+	xml         TEXT NOT NULL DEFAULT '',
+	error       TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS records_captured_at ON records (captured_at);
+`
+
+const sqliteColumns = `id, captured_at, origin, type, issuer, name_id, source, url, info, xml, error`
+
+// sqliteStore keeps records in a SQLite database, indexed by capture time.
+// Other processes, such as db list during a serve session, can read it
+// while it is written.
+type sqliteStore struct {
+	db *sql.DB
+}
+
+// OpenSQLite opens or creates a SQLite store at path
+func OpenSQLite(path string) (Store, error) {
+	if strings.Contains(path, "?") {
+		return nil, fmt.Errorf("invalid store path %q: must not contain '?'", path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create store directory: %w", err)
+	}
+	// Create the file with restricted permissions before SQLite does
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open store: %w", err)
+	}
+	file.Close()
+
+	db, err := sql.Open("sqlite", path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open store: %w", err)
+	}
+	// SQLite allows a single writer; one connection serializes the saves
+	// of parallel requests instead of failing them as busy
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open store: %w", err)
+	}
+	return &sqliteStore{db: db}, nil
+}
+
+func (s *sqliteStore) Save(ctx context.Context, records []Record) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to write store: %w", err)
+	}
+	defer tx.Rollback()
+
+	ids := make([]int64, len(records))
+	for i, r := range records {
+		var info []byte
+		if r.Info != nil {
+			if info, err = json.Marshal(r.Info); err != nil {
+				return fmt.Errorf("failed to encode record: %w", err)
+			}
+		}
+		result, err := tx.ExecContext(ctx,
+			`INSERT INTO records (captured_at, origin, type, issuer, name_id, source, url, info, xml, error)
+			 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			r.CapturedAt.UnixNano(), r.Origin, r.Type, r.Issuer, r.NameID, r.Source, r.URL, info, r.XML, r.Error)
+		if err != nil {
+			return fmt.Errorf("failed to write store: %w", err)
+		}
+		if ids[i], err = result.LastInsertId(); err != nil {
+			return fmt.Errorf("failed to write store: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to write store: %w", err)
+	}
+
+	// IDs are only assigned once the batch is stored
+	for i := range records {
+		records[i].ID = ids[i]
+	}
+	return nil
+}
+
+func (s *sqliteStore) List(ctx context.Context, filter Filter) ([]Record, error) {
+	// The time range is selected by SQL; the substring filters by Match,
+	// which folds case beyond ASCII, unlike SQLite
+	var where []string
+	var args []any
+	if !filter.Since.IsZero() {
+		where = append(where, "captured_at >= ?")
+		args = append(args, filter.Since.UnixNano())
+	}
+	if !filter.Until.IsZero() {
+		where = append(where, "captured_at < ?")
+		args = append(args, filter.Until.UnixNano())
+	}
+	query := "SELECT " + sqliteColumns + " FROM records"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY id"
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read store: %w", err)
+	}
+	defer rows.Close()
+
+	var records []Record
+	for rows.Next() {
+		r, err := scanRecord(rows)
+		if err != nil {
+			return nil, err
+		}
+		if filter.Match(*r) {
+			records = append(records, *r)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read store: %w", err)
+	}
+	return Limit(records, filter.Limit), nil
+}
+
+func (s *sqliteStore) Get(ctx context.Context, id int64) (*Record, error) {
+	row := s.db.QueryRowContext(ctx, "SELECT "+sqliteColumns+" FROM records WHERE id = ?", id)
+	r, err := scanRecord(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %d", ErrNotFound, id)
+	}
+	return r, err
+}
+
+func (s *sqliteStore) Close() error {
+	return s.db.Close()
+}
+
+// scanRecord reads a row of sqliteColumns
+func scanRecord(row interface{ Scan(...any) error }) (*Record, error) {
+	var r Record
+	var capturedAt int64
+	var info sql.NullString
+	err := row.Scan(&r.ID, &capturedAt, &r.Origin, &r.Type, &r.Issuer, &r.NameID, &r.Source, &r.URL, &info, &r.XML, &r.Error)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read store: %w", err)
+	}
+	r.CapturedAt = time.Unix(0, capturedAt).UTC()
+	if info.Valid {
+		r.Info = &saml.SAMLInfo{}
+		if err := json.Unmarshal([]byte(info.String), r.Info); err != nil {
+			return nil, fmt.Errorf("record %d: invalid info: %w", r.ID, err)
+		}
+	}
+	return &r, nil
+}
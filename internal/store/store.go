@@ -0,0 +1,182 @@
+// Package store persists inspected SAML messages, such as everything
+// uploaded to the web UI during a test window, so they can be queried later.
+// Backends register themselves by name and are selected with a location of
+// the form "backend:path"; a bare path uses the default backend.
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gliwka/SAMLurai/internal/saml"
+)
+
+// DefaultBackend is used for locations without a backend prefix
+const DefaultBackend = "sqlite"
+
+// ErrNotFound is returned by Get for unknown record IDs
+var ErrNotFound = errors.New("record not found")
+
+// Record is a stored SAML message
+type Record struct {
+	// ID is assigned by the store when the record is saved
+	ID int64 `json:"id"`
+
+	CapturedAt time.Time `json:"captured_at"`
+
+	// Origin names what captured the message, e.g. "serve"
+	Origin string `json:"origin,omitempty"`
+
+	Type   string `json:"type"`
+	Issuer string `json:"issuer,omitempty"`
+	NameID string `json:"name_id,omitempty"`
+
+	// Source and URL locate messages extracted from a HAR file
+	Source string `json:"source,omitempty"`
+	URL    string `json:"url,omitempty"`
+
+	Info  *saml.SAMLInfo `json:"info,omitempty"`
+	XML   string         `json:"xml,omitempty"`
+	Error string         `json:"error,omitempty"`
+}
+
+// NewRecord summarizes a parsed message for storage. The issuer and NameID
+// fall back to those of an embedded assertion.
+func NewRecord(info *saml.SAMLInfo, xml []byte, capturedAt time.Time) Record {
+	r := Record{CapturedAt: capturedAt, Info: info, XML: string(xml)}
+	for i := info; i != nil; i = i.Assertion {
+		if r.Type == "" {
+			r.Type = i.Type
+		}
+		if r.Issuer == "" {
+			r.Issuer = i.Issuer
+		}
+		if r.NameID == "" && i.Subject != nil {
+			r.NameID = i.Subject.NameID
+		}
+	}
+	return r
+}
+
+// Filter selects records. Empty fields match everything; Issuer, Type and
+// NameID match case-insensitive substrings.
+type Filter struct {
+	Issuer string
+	Type   string
+	NameID string
+	Since  time.Time
+	Until  time.Time
+
+	// Limit keeps only the most recent records; zero means no limit
+	Limit int
+}
+
+// Match reports whether r is selected by the filter, ignoring Limit
+func (f Filter) Match(r Record) bool {
+	switch {
+	case !containsFold(r.Issuer, f.Issuer), !containsFold(r.Type, f.Type), !containsFold(r.NameID, f.NameID):
+		return false
+	case !f.Since.IsZero() && r.CapturedAt.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && !r.CapturedAt.Before(f.Until):
+		return false
+	}
+	return true
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+// Store is a storage backend. Implementations must be safe for concurrent
+// use, since the web UI saves messages from parallel requests.
+type Store interface {
+	// Save stores records and assigns their IDs
+	Save(ctx context.Context, records []Record) error
+
+	// List returns the records matching filter, oldest first
+	List(ctx context.Context, filter Filter) ([]Record, error)
+
+	// Get returns a single record, or ErrNotFound
+	Get(ctx context.Context, id int64) (*Record, error)
+
+	Close() error
+}
+
+// Opener opens a store at a backend-specific location
+type Opener func(location string) (Store, error)
+
+var (
+	backendsMu sync.RWMutex
+	backends   = map[string]Opener{}
+)
+
+// Register makes a backend available to Open under name
+func Register(name string, open Opener) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+	backends[name] = open
+}
+
+// Backends returns the names of the registered backends
+func Backends() []string {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Open opens the store at location, given as "backend:path" or a path for
+// the default backend. An empty location opens DefaultLocation.
+func Open(location string) (Store, error) {
+	if location == "" {
+		var err error
+		if location, err = DefaultLocation(); err != nil {
+			return nil, err
+		}
+	}
+
+	name, path := DefaultBackend, location
+	// A single letter before the colon is a Windows drive
+	if prefix, rest, ok := strings.Cut(location, ":"); ok && len(prefix) > 1 {
+		name, path = prefix, rest
+	}
+
+	backendsMu.RLock()
+	open, ok := backends[name]
+	backendsMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown store backend %q: must be one of %s", name, strings.Join(Backends(), ", "))
+	}
+	return open(path)
+}
+
+// DefaultLocation returns the store used when none is given, in the user
+// cache directory
+func DefaultLocation() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate cache directory: %w", err)
+	}
+	return filepath.Join(dir, "samlurai", "captures.db"), nil
+}
+
+// Limit keeps the last n records, for Store implementations applying
+// Filter.Limit after filtering
+func Limit(records []Record, n int) []Record {
+	if n > 0 && len(records) > n {
+		return records[len(records)-n:]
+	}
+	return records
+}
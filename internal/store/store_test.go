@@ -0,0 +1,229 @@
+package store
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var captured = time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+
+func testRecords() []Record {
+	response := &saml.SAMLInfo{
+		Type:   "Response",
+		Issuer: "https://idp.example.com",
+		Assertion: &saml.SAMLInfo{
+			Type:    "Assertion",
+			Issuer:  "https://idp.example.com",
+			Subject: &saml.Subject{NameID: "alice@example.com"},
+		},
+	}
+	request := &saml.SAMLInfo{Type: "AuthnRequest", Issuer: "https://sp.example.com"}
+	return []Record{
+		NewRecord(request, []byte("<AuthnRequest/>"), captured),
+		NewRecord(response, []byte("<Response/>"), captured.Add(time.Minute)),
+		NewRecord(request, []byte("<AuthnRequest/>"), captured.Add(time.Hour)),
+	}
+}
+
+func TestNewRecord(t *testing.T) {
+	r := testRecords()[1]
+	assert.Equal(t, "Response", r.Type)
+	assert.Equal(t, "https://idp.example.com", r.Issuer)
+	assert.Equal(t, "alice@example.com", r.NameID)
+}
+
+func TestJSONL(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "nested", "captures.jsonl")
+
+	st, err := Open("jsonl:" + path)
+	require.NoError(t, err)
+	records := testRecords()
+	require.NoError(t, st.Save(ctx, records[:2]))
+	assert.Equal(t, int64(2), records[1].ID)
+	require.NoError(t, st.Close())
+
+	// IDs continue after reopening
+	st, err = Open("jsonl:" + path)
+	require.NoError(t, err)
+	defer st.Close()
+	require.NoError(t, st.Save(ctx, records[2:]))
+	assert.Equal(t, int64(3), records[2].ID)
+
+	all, err := st.List(ctx, Filter{})
+	require.NoError(t, err)
+	require.Len(t, all, 3)
+	assert.Equal(t, "<Response/>", all[1].XML)
+	require.NotNil(t, all[1].Info)
+	assert.Equal(t, "alice@example.com", all[1].Info.Assertion.Subject.NameID)
+
+	got, err := st.Get(ctx, 2)
+	require.NoError(t, err)
+	assert.Equal(t, "Response", got.Type)
+
+	_, err = st.Get(ctx, 42)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestJSONL_Conditions(t *testing.T) {
+	ctx := context.Background()
+	st, err := OpenJSONL(filepath.Join(t.TempDir(), "captures.jsonl"))
+	require.NoError(t, err)
+	defer st.Close()
+
+	count := 1
+	conditions := saml.ConditionList{
+		&saml.OneTimeUse{},
+		&saml.ProxyRestriction{Count: &count, Audiences: []string{"https://app.example.com"}},
+	}
+	info := &saml.SAMLInfo{
+		Type:      "Response",
+		Assertion: &saml.SAMLInfo{Type: "Assertion", Conditions: &saml.Conditions{Other: conditions}},
+	}
+	require.NoError(t, st.Save(ctx, []Record{NewRecord(info, []byte("<Response/>"), captured)}))
+
+	got, err := st.Get(ctx, 1)
+	require.NoError(t, err)
+	require.NotNil(t, got.Info)
+	assert.Equal(t, conditions, got.Info.Assertion.Conditions.Other)
+}
+
+func TestJSONL_Filter(t *testing.T) {
+	ctx := context.Background()
+	st, err := OpenJSONL(filepath.Join(t.TempDir(), "captures.jsonl"))
+	require.NoError(t, err)
+	defer st.Close()
+	require.NoError(t, st.Save(ctx, testRecords()))
+
+	ids := func(filter Filter) []int64 {
+		t.Helper()
+		records, err := st.List(ctx, filter)
+		require.NoError(t, err)
+		var ids []int64
+		for _, r := range records {
+			ids = append(ids, r.ID)
+		}
+		return ids
+	}
+
+	assert.Equal(t, []int64{2}, ids(Filter{Issuer: "IDP.example"}))
+	assert.Equal(t, []int64{1, 3}, ids(Filter{Type: "authnrequest"}))
+	assert.Equal(t, []int64{2}, ids(Filter{NameID: "alice"}))
+	assert.Equal(t, []int64{2, 3}, ids(Filter{Since: captured.Add(time.Second)}))
+	assert.Equal(t, []int64{1, 2}, ids(Filter{Until: captured.Add(time.Hour)}))
+	assert.Equal(t, []int64{3}, ids(Filter{Limit: 1}))
+}
+
+func TestJSONL_TruncatedLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "captures.jsonl")
+	require.NoError(t, os.WriteFile(path, []byte(`{"id":1,"type":"Response"}`+"\n"+`{"id":2,"ty`), 0600))
+
+	st, err := OpenJSONL(path)
+	require.NoError(t, err)
+	defer st.Close()
+	records := []Record{{Type: "AuthnRequest"}}
+	require.NoError(t, st.Save(context.Background(), records))
+	assert.Equal(t, int64(2), records[0].ID)
+
+	all, err := st.List(context.Background(), Filter{})
+	require.NoError(t, err)
+	require.Len(t, all, 2)
+	assert.Equal(t, "Response", all[0].Type)
+	assert.Equal(t, "AuthnRequest", all[1].Type)
+}
+
+func TestOpen_UnknownBackend(t *testing.T) {
+	_, err := Open("bolt:captures.db")
+	assert.EqualError(t, err, `unknown store backend "bolt": must be one of jsonl, sqlite`)
+}
+
+func TestSQLite(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "nested", "captures.db")
+
+	st, err := Open(path)
+	require.NoError(t, err)
+	records := testRecords()
+	require.NoError(t, st.Save(ctx, records[:2]))
+	assert.Equal(t, int64(2), records[1].ID)
+	require.NoError(t, st.Close())
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// IDs continue after reopening
+	st, err = Open("sqlite:" + path)
+	require.NoError(t, err)
+	defer st.Close()
+	require.NoError(t, st.Save(ctx, records[2:]))
+	assert.Equal(t, int64(3), records[2].ID)
+
+	all, err := st.List(ctx, Filter{})
+	require.NoError(t, err)
+	require.Len(t, all, 3)
+	assert.Equal(t, records[1], all[1])
+
+	got, err := st.Get(ctx, 2)
+	require.NoError(t, err)
+	assert.Equal(t, "Response", got.Type)
+	assert.True(t, captured.Add(time.Minute).Equal(got.CapturedAt))
+
+	_, err = st.Get(ctx, 42)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestSQLite_Filter(t *testing.T) {
+	ctx := context.Background()
+	st, err := OpenSQLite(filepath.Join(t.TempDir(), "captures.db"))
+	require.NoError(t, err)
+	defer st.Close()
+	require.NoError(t, st.Save(ctx, testRecords()))
+
+	ids := func(filter Filter) []int64 {
+		t.Helper()
+		records, err := st.List(ctx, filter)
+		require.NoError(t, err)
+		var ids []int64
+		for _, r := range records {
+			ids = append(ids, r.ID)
+		}
+		return ids
+	}
+
+	assert.Equal(t, []int64{2}, ids(Filter{Issuer: "IDP.example"}))
+	assert.Equal(t, []int64{1, 3}, ids(Filter{Type: "authnrequest"}))
+	assert.Equal(t, []int64{2}, ids(Filter{NameID: "alice"}))
+	assert.Equal(t, []int64{2, 3}, ids(Filter{Since: captured.Add(time.Second)}))
+	assert.Equal(t, []int64{1, 2}, ids(Filter{Until: captured.Add(time.Hour)}))
+	assert.Equal(t, []int64{3}, ids(Filter{Limit: 1}))
+}
+
+func TestSQLite_Concurrent(t *testing.T) {
+	ctx := context.Background()
+	st, err := OpenSQLite(filepath.Join(t.TempDir(), "captures.db"))
+	require.NoError(t, err)
+	defer st.Close()
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, st.Save(ctx, testRecords()))
+		}()
+	}
+	wg.Wait()
+
+	all, err := st.List(ctx, Filter{})
+	require.NoError(t, err)
+	assert.Len(t, all, 24)
+}
@@ -0,0 +1,250 @@
+// Package tail follows a growing log or HAR file and reports the SAML
+// messages in what is appended to it, like tail -f for SAML traffic.
+package tail
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/gliwka/SAMLurai/internal/saml"
+)
+
+// FormatHAR follows a HAR file that is rewritten as requests are captured
+const FormatHAR = "har"
+
+// DefaultPollInterval is how often the file is checked for new data
+const DefaultPollInterval = 500 * time.Millisecond
+
+// Options configures Follow
+type Options struct {
+	// Format is FormatHAR or one of saml.LogFormats
+	Format string
+
+	// FromStart reports messages already in the file, not only new ones
+	FromStart bool
+
+	// Once stops at the end of the file instead of waiting for more data
+	Once bool
+
+	// PollInterval defaults to DefaultPollInterval
+	PollInterval time.Duration
+}
+
+// Handler receives the messages found in one log line or HAR entry, with
+// indexes numbered across the whole session
+type Handler func(messages []saml.ExtractedSAML) error
+
+// Follow reports the SAML messages appended to path until ctx is done, the
+// handler fails, or with Once, the end of the file is reached. Log files
+// that are truncated or replaced by log rotation are reopened.
+func Follow(ctx context.Context, path string, opts Options, handle Handler) error {
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = DefaultPollInterval
+	}
+	if opts.Format != FormatHAR {
+		if _, err := saml.ParseLogLine(opts.Format, ""); err != nil {
+			return err
+		}
+	}
+
+	f := &follower{path: path, opts: opts, handle: handle, extractor: saml.NewHARExtractor(), index: 1}
+	if opts.Format == FormatHAR {
+		return f.followHAR(ctx)
+	}
+	return f.followLog(ctx)
+}
+
+type follower struct {
+	path      string
+	opts      Options
+	handle    Handler
+	extractor *saml.HARExtractor
+	index     int
+}
+
+// emit numbers the messages of one line or entry and passes them to the
+// handler
+func (f *follower) emit(messages []saml.ExtractedSAML) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	for i := range messages {
+		messages[i].Index = f.index
+		f.index++
+	}
+	return f.handle(messages)
+}
+
+// wait sleeps for one poll interval, returning false once ctx is done
+func (f *follower) wait(ctx context.Context) bool {
+	timer := time.NewTimer(f.opts.PollInterval)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+func (f *follower) followLog(ctx context.Context) error {
+	file, err := os.Open(f.path)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer func() { file.Close() }()
+
+	if !f.opts.FromStart && !f.opts.Once {
+		if _, err := file.Seek(0, io.SeekEnd); err != nil {
+			return fmt.Errorf("failed to seek to end of file: %w", err)
+		}
+	}
+
+	reader := bufio.NewReader(file)
+	var partial []byte
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("failed to read file: %w", err)
+		}
+		partial = append(partial, line...)
+
+		// Only complete lines are parsed; the rest is kept until the writer
+		// finishes the line
+		if bytes.HasSuffix(partial, []byte("\n")) {
+			if err := f.emitLine(string(partial)); err != nil {
+				return err
+			}
+			partial = partial[:0]
+			continue
+		}
+
+		if f.opts.Once {
+			if len(partial) > 0 {
+				return f.emitLine(string(partial))
+			}
+			return nil
+		}
+		if !f.wait(ctx) {
+			return nil
+		}
+
+		if reopened, err := f.reopenIfRotated(file); err != nil {
+			return err
+		} else if reopened != nil {
+			file.Close()
+			file = reopened
+			reader.Reset(file)
+			partial = partial[:0]
+		}
+	}
+}
+
+func (f *follower) emitLine(line string) error {
+	messages, err := f.extractor.ExtractFromLogLine(f.opts.Format, line)
+	if err != nil {
+		return err
+	}
+	return f.emit(messages)
+}
+
+// reopenIfRotated returns a newly opened file if the one at path was
+// replaced or truncated, and nil if neither happened
+func (f *follower) reopenIfRotated(file *os.File) (*os.File, error) {
+	current, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+	atPath, err := os.Stat(f.path)
+	if err != nil {
+		// Between rotation and the new file being created
+		return nil, nil
+	}
+
+	if !os.SameFile(current, atPath) {
+		reopened, err := os.Open(f.path)
+		if err != nil {
+			return nil, nil
+		}
+		return reopened, nil
+	}
+
+	offset, err := file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file offset: %w", err)
+	}
+	if atPath.Size() < offset {
+		reopened, err := os.Open(f.path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to reopen truncated file: %w", err)
+		}
+		return reopened, nil
+	}
+	return nil, nil
+}
+
+// followHAR rereads the HAR whenever it changes and reports entries beyond
+// those seen before. Tools that save HAR files rewrite them as a whole, so
+// a version that does not parse is assumed to be mid-write and retried.
+func (f *follower) followHAR(ctx context.Context) error {
+	seen := -1
+	var lastSize int64 = -1
+	var lastMod time.Time
+
+	for {
+		info, err := os.Stat(f.path)
+		if err != nil && seen < 0 {
+			return fmt.Errorf("failed to open file: %w", err)
+		}
+
+		if err == nil && (info.Size() != lastSize || !info.ModTime().Equal(lastMod)) {
+			entries, perr := readHAREntries(f.path)
+			switch {
+			case perr == nil:
+				lastSize, lastMod = info.Size(), info.ModTime()
+				if seen < 0 && !f.opts.FromStart && !f.opts.Once {
+					seen = len(entries)
+				}
+				if seen < 0 || len(entries) < seen {
+					// First read, or the capture was cleared and restarted
+					seen = 0
+				}
+				for _, entry := range entries[seen:] {
+					if err := f.emit(f.extractor.ExtractFromEntry(entry)); err != nil {
+						return err
+					}
+				}
+				seen = len(entries)
+			case f.opts.Once:
+				return perr
+			}
+		}
+
+		if f.opts.Once || !f.wait(ctx) {
+			return nil
+		}
+	}
+}
+
+func readHAREntries(path string) ([]saml.HAREntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	defer file.Close()
+
+	var entries []saml.HAREntry
+	if err := saml.WalkHAR(file, func(entry saml.HAREntry) error {
+		entries = append(entries, entry)
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("failed to parse HAR file: %w", err)
+	}
+	return entries, nil
+}
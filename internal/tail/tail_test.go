@@ -0,0 +1,151 @@
+package tail
+
+import (
+	"context"
+	"encoding/base64"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gliwka/SAMLurai/internal/saml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testPoll = 10 * time.Millisecond
+
+func logLine(t *testing.T, id string) string {
+	t.Helper()
+	deflated, err := saml.NewDecoder().Deflate([]byte(`<samlp:AuthnRequest xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="` + id + `"/>`))
+	require.NoError(t, err)
+	param := url.QueryEscape(base64.StdEncoding.EncodeToString(deflated))
+	return `203.0.113.7 - - [10/Oct/2025:13:55:36 +0000] "GET /sso?SAMLRequest=` + param + ` HTTP/1.1" 302 0 "-" "curl"` + "\n"
+}
+
+func appendFile(t *testing.T, path, data string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
+	require.NoError(t, err)
+	_, err = f.WriteString(data)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+}
+
+// follow runs Follow in the background and returns a channel of the IDs
+// of the reported messages
+func follow(t *testing.T, path string, opts Options) <-chan string {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	ids := make(chan string, 16)
+	done := make(chan error, 1)
+	opts.PollInterval = testPoll
+	go func() {
+		done <- Follow(ctx, path, opts, func(messages []saml.ExtractedSAML) error {
+			for _, msg := range messages {
+				info, err := saml.NewParser().Parse(msg.DecodedXML)
+				if err != nil {
+					return err
+				}
+				ids <- info.ID
+			}
+			return nil
+		})
+	}()
+	t.Cleanup(func() {
+		cancel()
+		require.NoError(t, <-done)
+	})
+	return ids
+}
+
+func next(t *testing.T, ids <-chan string) string {
+	t.Helper()
+	select {
+	case id := <-ids:
+		return id
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for a message")
+		return ""
+	}
+}
+
+func TestFollow_Log(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	appendFile(t, path, logLine(t, "_old"))
+
+	ids := follow(t, path, Options{Format: saml.LogFormatCombined})
+	time.Sleep(5 * testPoll)
+
+	// A line written in two parts is only parsed once complete
+	line := logLine(t, "_new")
+	appendFile(t, path, line[:40])
+	time.Sleep(3 * testPoll)
+	appendFile(t, path, line[40:])
+	assert.Equal(t, "_new", next(t, ids))
+
+	// Rotation: the file is moved away and recreated
+	require.NoError(t, os.Rename(path, path+".1"))
+	appendFile(t, path, logLine(t, "_rotated"))
+	assert.Equal(t, "_rotated", next(t, ids))
+
+	// Truncation: copytruncate-style rotation
+	require.NoError(t, os.Truncate(path, 0))
+	time.Sleep(3 * testPoll)
+	appendFile(t, path, logLine(t, "_truncated"))
+	assert.Equal(t, "_truncated", next(t, ids))
+}
+
+func TestFollow_LogOnce(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	appendFile(t, path, logLine(t, "_a")+"unrelated line\n"+logLine(t, "_b"))
+
+	var indexes []int
+	err := Follow(context.Background(), path, Options{Format: saml.LogFormatCombined, Once: true}, func(messages []saml.ExtractedSAML) error {
+		for _, msg := range messages {
+			indexes = append(indexes, msg.Index)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 2}, indexes)
+}
+
+func harWith(t *testing.T, ids ...string) string {
+	t.Helper()
+	entries := ""
+	for i, id := range ids {
+		if i > 0 {
+			entries += ","
+		}
+		deflated, err := saml.NewDecoder().Deflate([]byte(`<samlp:AuthnRequest xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="` + id + `"/>`))
+		require.NoError(t, err)
+		entries += `{"request": {"method": "GET", "url": "https://idp.example.com/sso?SAMLRequest=` + url.QueryEscape(base64.StdEncoding.EncodeToString(deflated)) + `"}, "response": {"content": {"text": ""}}}`
+	}
+	return `{"log": {"entries": [` + entries + `]}}`
+}
+
+func TestFollow_HAR(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture.har")
+	require.NoError(t, os.WriteFile(path, []byte(harWith(t, "_one")), 0644))
+
+	ids := follow(t, path, Options{Format: FormatHAR, FromStart: true})
+	assert.Equal(t, "_one", next(t, ids))
+
+	// A half-written file is retried rather than reported
+	require.NoError(t, os.WriteFile(path, []byte(`{"log": {"entries": [`), 0644))
+	time.Sleep(3 * testPoll)
+	require.NoError(t, os.WriteFile(path, []byte(harWith(t, "_one", "_two")), 0644))
+	assert.Equal(t, "_two", next(t, ids))
+}
+
+func TestFollow_Errors(t *testing.T) {
+	noop := func([]saml.ExtractedSAML) error { return nil }
+
+	err := Follow(context.Background(), "access.log", Options{Format: "w3c"}, noop)
+	assert.ErrorContains(t, err, `unknown log format "w3c"`)
+
+	err = Follow(context.Background(), filepath.Join(t.TempDir(), "missing.log"), Options{Format: saml.LogFormatCombined}, noop)
+	assert.ErrorContains(t, err, "failed to open file")
+}
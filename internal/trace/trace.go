@@ -0,0 +1,140 @@
+// Package trace records the steps of the decode pipeline (URL decoding,
+// base64, inflate, type detection, decryption and parsing) with their
+// sizes and timings, to explain why an input did not decode as expected.
+package trace
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// Pipeline stages
+const (
+	StageURLDecode  = "url-decode"
+	StageBase64     = "base64"
+	StageInflate    = "inflate"
+	StageDetectType = "detect-type"
+	StageDecrypt    = "decrypt"
+	StageParse      = "parse"
+)
+
+// Outcomes of a stage
+const (
+	OutcomeOK      = "ok"
+	OutcomeSkipped = "skipped"
+	OutcomeFailed  = "failed"
+)
+
+// Step is one stage applied to one message
+type Step struct {
+	// Message is the 1-based position of the message in a HAR file; 0 for
+	// a single message
+	Message int `json:"message,omitempty"`
+
+	Stage   string `json:"stage"`
+	Outcome string `json:"outcome"`
+
+	// Detail explains the decision taken, e.g. the base64 variant that
+	// matched
+	Detail string `json:"detail,omitempty"`
+
+	BytesIn    int     `json:"bytes_in"`
+	BytesOut   int     `json:"bytes_out"`
+	DurationMS float64 `json:"duration_ms"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// Trace collects the steps of one run. A nil *Trace records nothing, so
+// code can trace unconditionally.
+type Trace struct {
+	steps *steps
+
+	// message is set on the Trace returned by ForMessage
+	message int
+}
+
+type steps struct {
+	mu   sync.Mutex
+	list []Step
+}
+
+// New returns an empty trace
+func New() *Trace {
+	return &Trace{steps: &steps{}}
+}
+
+// ForMessage returns a trace recording into t, labelling its steps with
+// the 1-based position of a HAR message
+func (t *Trace) ForMessage(index int) *Trace {
+	if t == nil {
+		return nil
+	}
+	return &Trace{steps: t.steps, message: index}
+}
+
+// Span times a stage between Begin and End
+type Span struct {
+	trace   *Trace
+	stage   string
+	bytesIn int
+	start   time.Time
+}
+
+// Begin starts timing a stage reading bytesIn bytes
+func (t *Trace) Begin(stage string, bytesIn int) *Span {
+	if t == nil {
+		return nil
+	}
+	return &Span{trace: t, stage: stage, bytesIn: bytesIn, start: time.Now()}
+}
+
+// End records the stage with its outcome, the bytes it produced and, for
+// a failed stage, its error
+func (s *Span) End(outcome string, bytesOut int, detail string, err error) {
+	if s == nil {
+		return
+	}
+	step := Step{
+		Message:    s.trace.message,
+		Stage:      s.stage,
+		Outcome:    outcome,
+		Detail:     detail,
+		BytesIn:    s.bytesIn,
+		BytesOut:   bytesOut,
+		DurationMS: float64(time.Since(s.start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		step.Error = err.Error()
+	}
+	s.trace.steps.mu.Lock()
+	s.trace.steps.list = append(s.trace.steps.list, step)
+	s.trace.steps.mu.Unlock()
+}
+
+// Skip records a stage that was not applied, and why
+func (t *Trace) Skip(stage string, bytesIn int, detail string) {
+	t.Begin(stage, bytesIn).End(OutcomeSkipped, bytesIn, detail, nil)
+}
+
+// Steps returns the steps recorded so far, in order
+func (t *Trace) Steps() []Step {
+	if t == nil {
+		return nil
+	}
+	t.steps.mu.Lock()
+	defer t.steps.mu.Unlock()
+	return append([]Step(nil), t.steps.list...)
+}
+
+// WriteJSONL writes one JSON object per step to w
+func (t *Trace) WriteJSONL(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	for _, step := range t.Steps() {
+		if err := enc.Encode(step); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package trace
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrace(t *testing.T) {
+	tr := New()
+	tr.Begin(StageBase64, 12).End(OutcomeOK, 8, "url-safe", nil)
+	tr.Skip(StageInflate, 8, "decoded content is XML")
+	tr.ForMessage(3).Begin(StageParse, 8).End(OutcomeFailed, 0, "", errors.New("unexpected EOF"))
+
+	steps := tr.Steps()
+	require.Len(t, steps, 3)
+	assert.Equal(t, Step{Stage: StageBase64, Outcome: OutcomeOK, Detail: "url-safe", BytesIn: 12, BytesOut: 8, DurationMS: steps[0].DurationMS}, steps[0])
+	assert.Equal(t, OutcomeSkipped, steps[1].Outcome)
+	assert.Equal(t, 3, steps[2].Message)
+	assert.Equal(t, "unexpected EOF", steps[2].Error)
+
+	var buf bytes.Buffer
+	require.NoError(t, tr.WriteJSONL(&buf))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 3)
+	var step Step
+	require.NoError(t, json.Unmarshal([]byte(lines[2]), &step))
+	assert.Equal(t, steps[2], step)
+}
+
+func TestTrace_Nil(t *testing.T) {
+	var tr *Trace
+	tr.Begin(StageBase64, 1).End(OutcomeOK, 1, "", nil)
+	tr.Skip(StageInflate, 1, "")
+	assert.Nil(t, tr.ForMessage(1))
+	assert.Empty(t, tr.Steps())
+}